@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// corsOrigins is the parsed -cors_origins allow-list
+type corsOrigins struct {
+	any     bool // "*": every origin, but never with credentials
+	allowed map[string]bool
+	// guardWrites refuses state-changing requests from unlisted cross-site origins. It is on with
+	// SameSite=None sessions, whose cookie the browser attaches to form posts from any site, so
+	// the listed origins are the only embedders that can act on a user's behalf.
+	guardWrites bool
+}
+
+// parseCORSOrigins reads a comma-separated list like "https://draw.example.com,http://localhost:5173".
+// Origins compare case-insensitively and a trailing slash is ignored; "*" anywhere allows any
+// other origin without credentials.
+func parseCORSOrigins(spec string) corsOrigins {
+	c := corsOrigins{allowed: map[string]bool{}}
+	for _, o := range strings.Split(spec, ",") {
+		o = normalizeOrigin(o)
+		if o == "" { continue }
+		if o == "*" { c.any = true; continue }
+		c.allowed[o] = true
+	}
+	return c
+}
+
+func normalizeOrigin(o string) string { return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(o), "/")) }
+
+// withCORS answers cross-origin requests from allowed origins only: a listed origin is reflected
+// with credentials, the "*" wildcard sends a literal * without them, and any other origin gets no
+// Access-Control-Allow-Origin at all so the browser blocks it. exposed lists response headers
+// cross-origin scripts may read.
+func withCORS(next http.Handler, origins corsOrigins, exposed ...string) http.Handler {
+	expose := strings.Join(exposed, ", ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		origin := r.Header.Get("Origin")
+		switch {
+		case origin == "":
+		case origins.allowed[normalizeOrigin(origin)]:
+			h.Set("Access-Control-Allow-Origin", origin)
+			h.Set("Access-Control-Allow-Credentials", "true")
+		case origins.any:
+			h.Set("Access-Control-Allow-Origin", "*")
+		}
+		if h.Get("Access-Control-Allow-Origin") != "" {
+			h.Set("Access-Control-Allow-Headers", "Content-Type")
+			h.Set("Access-Control-Allow-Methods", "GET,POST,OPTIONS")
+			if expose != "" { h.Set("Access-Control-Expose-Headers", expose) }
+		}
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if origins.guardWrites && origin != "" && !safeMethod(r.Method) && !origins.allowed[normalizeOrigin(origin)] && !sameOrigin(origin, r) {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func safeMethod(m string) bool { return m == http.MethodGet || m == http.MethodHead || m == http.MethodOptions }
+
+// sameOrigin reports whether origin names the host r was sent to, i.e. the request came from the
+// server's own pages
+func sameOrigin(origin string, r *http.Request) bool {
+	u, err := url.Parse(origin)
+	return err == nil && u.Host != "" && strings.EqualFold(u.Host, r.Host)
+}
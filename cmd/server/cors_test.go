@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func corsRequest(origins, method, origin string) *httptest.ResponseRecorder {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	req := httptest.NewRequest(method, "/api/me", nil)
+	if origin != "" { req.Header.Set("Origin", origin) }
+	rec := httptest.NewRecorder()
+	withCORS(next, parseCORSOrigins(origins), "X-Recognize-Duration-Ms").ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCORS_AllowedOriginIsReflectedWithCredentials(t *testing.T) {
+	rec := corsRequest("https://draw.example.com/, http://localhost:5173", "GET", "https://Draw.example.com")
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://Draw.example.com" {
+		t.Fatalf("Expected the origin to be reflected, got %q", got)
+	}
+	if rec.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Fatal("Expected credentials to be allowed for a listed origin")
+	}
+	if rec.Header().Get("Access-Control-Expose-Headers") != "X-Recognize-Duration-Ms" {
+		t.Fatalf("Expected exposed headers, got %q", rec.Header().Get("Access-Control-Expose-Headers"))
+	}
+	if rec.Header().Get("Vary") != "Origin" || rec.Code != 200 {
+		t.Fatalf("Expected Vary: Origin and the handler's 200, got %q %d", rec.Header().Get("Vary"), rec.Code)
+	}
+}
+
+func TestCORS_DisallowedOriginGetsNoHeaders(t *testing.T) {
+	for _, origins := range []string{"https://draw.example.com", ""} {
+		rec := corsRequest(origins, "GET", "https://evil.example.com")
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Fatalf("%q: expected no Access-Control-Allow-Origin, got %q", origins, got)
+		}
+		if rec.Header().Get("Access-Control-Allow-Credentials") != "" {
+			t.Fatalf("%q: expected no credentials header", origins)
+		}
+	}
+	// Preflight still short-circuits, it just carries no grant
+	rec := corsRequest("https://draw.example.com", "OPTIONS", "https://evil.example.com")
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("Expected a bare 204 preflight, got %d %q", rec.Code, rec.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
+
+func TestCORS_WildcardDisablesCredentials(t *testing.T) {
+	rec := corsRequest("*", "OPTIONS", "https://anywhere.example.com")
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected 204 for preflight, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Fatalf("Expected a literal *, got %q", got)
+	}
+	if rec.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Fatal("Wildcard must not allow credentials")
+	}
+	if rec.Header().Get("Access-Control-Allow-Methods") == "" {
+		t.Fatal("Expected preflight to list allowed methods")
+	}
+	// An explicitly listed origin keeps credentials next to the wildcard
+	if rec := corsRequest("*,https://draw.example.com", "GET", "https://draw.example.com"); rec.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Fatal("Expected a listed origin to keep credentials alongside *")
+	}
+}
+
+func TestCORS_GuardWritesRefusesUnlistedCrossSitePosts(t *testing.T) {
+	origins := parseCORSOrigins("https://embedder.example.com")
+	origins.guardWrites = true
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	for _, tc := range []struct {
+		method, origin string
+		want           int
+	}{
+		{"POST", "https://evil.example.com", http.StatusForbidden},
+		{"POST", "https://embedder.example.com", http.StatusOK},
+		{"POST", "http://draw.example.com", http.StatusOK}, // the server's own pages
+		{"POST", "", http.StatusOK},
+		{"GET", "https://evil.example.com", http.StatusOK}, // reads stay readable only by listed origins via CORS
+	} {
+		req := httptest.NewRequest(tc.method, "http://draw.example.com/api/strokes", nil)
+		if tc.origin != "" { req.Header.Set("Origin", tc.origin) }
+		rec := httptest.NewRecorder()
+		withCORS(next, origins).ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Fatalf("%s from %q: expected %d, got %d", tc.method, tc.origin, tc.want, rec.Code)
+		}
+	}
+	if rec := corsRequest("https://embedder.example.com", "POST", "https://evil.example.com"); rec.Code != http.StatusOK {
+		t.Fatalf("Expected writes to be unguarded with SameSite=Lax sessions, got %d", rec.Code)
+	}
+}
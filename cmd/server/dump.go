@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/deliium/drawing-board/internal/db"
+	"github.com/deliium/drawing-board/internal/httpapi"
+)
+
+// dumpVersion is bumped when the dump layout changes in a way older importers cannot read
+const dumpVersion = 1
+
+// dump is the portable form of the whole database written by `export` and read by `import`.
+// IDs are those of the exporting database; import assigns new ones and remaps references.
+// Dumps from before createdAt was recorded still import, stamped with the time of the import.
+type dump struct {
+	Version    int        `json:"version"`
+	ExportedAt time.Time  `json:"exportedAt"`
+	Users      []dumpUser `json:"users"`
+}
+
+type dumpUser struct {
+	ID           int64        `json:"id"`
+	Email        string       `json:"email"`
+	PasswordHash string       `json:"passwordHash,omitempty"` // only with -password_hashes; without it the user must reset their password
+	Public       bool         `json:"public"`
+	DisplayName  string       `json:"displayName,omitempty"`
+	CreatedAt    time.Time    `json:"createdAt,omitzero"`
+	Boards       []dumpBoard  `json:"boards"` // in creation order; the first is the user's default board
+	Strokes      []dumpStroke `json:"strokes"`
+}
+
+type dumpBoard struct {
+	ID        int64              `json:"id"`
+	Name      string             `json:"name"`
+	CreatedAt time.Time          `json:"createdAt,omitzero"`
+	Settings  *dumpBoardSettings `json:"settings,omitempty"` // omitted when the board uses the defaults
+}
+
+// dumpStroke is a stroke as the API returns it, plus when it was saved
+type dumpStroke struct {
+	httpapi.Stroke
+	CreatedAt time.Time `json:"createdAt,omitzero"`
+}
+
+type dumpBoardSettings struct {
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	Background string `json:"background"`
+}
+
+// exportDump reads every user with their boards and strokes
+func exportDump(store *db.Store, withHashes bool) (*dump, error) {
+	users, err := store.ListUsers()
+	if err != nil { return nil, err }
+	d := &dump{Version: dumpVersion, ExportedAt: time.Now().UTC(), Users: make([]dumpUser, 0, len(users))}
+	for _, u := range users {
+		du := dumpUser{ID: u.ID, Email: u.Email, Public: u.Public, DisplayName: u.DisplayName, CreatedAt: u.CreatedAt, Boards: []dumpBoard{}, Strokes: []dumpStroke{}}
+		if withHashes { du.PasswordHash = u.PasswordHash }
+		boards, err := store.ListBoardsByUser(u.ID)
+		if err != nil { return nil, err }
+		for _, b := range boards {
+			out := dumpBoard{ID: b.ID, Name: b.Name, CreatedAt: b.CreatedAt}
+			bs, err := store.GetBoardSettings(b.ID)
+			if err != nil { return nil, err }
+			if bs != nil { out.Settings = &dumpBoardSettings{Width: bs.Width, Height: bs.Height, Background: bs.Background} }
+			du.Boards = append(du.Boards, out)
+		}
+		strokes, err := store.ListStrokesByUser(u.ID)
+		if err != nil { return nil, err }
+		for _, s := range strokes {
+			pts := make([]httpapi.StrokePoint, 0, len(s.Points))
+			for _, p := range s.Points { pts = append(pts, httpapi.StrokePoint{X: p.X, Y: p.Y}) }
+			st := httpapi.Stroke{ID: s.ID, Points: pts, Color: s.Color, Width: s.Width, ClientID: s.ClientID, StartedAtUnixMs: s.StartedAtUnixMs, Note: s.Note, CreatedBy: s.CreatedBy, BoardID: s.BoardID, LineStyle: s.LineStyle}
+			du.Strokes = append(du.Strokes, dumpStroke{Stroke: st, CreatedAt: s.CreatedAt})
+		}
+		d.Users = append(d.Users, du)
+	}
+	return d, nil
+}
+
+// importDump recreates d's users, boards and strokes with their creation timestamps, in one
+// transaction: it changes nothing when any of the emails is already registered, so importing twice
+// does not interleave two copies, or when anything else fails part-way. Strokes go through
+// db.NormalizeStroke like every other save path.
+func importDump(store *db.Store, d *dump) (db.ImportResult, error) {
+	if d.Version != dumpVersion { return db.ImportResult{}, fmt.Errorf("unsupported dump version %d (want %d)", d.Version, dumpVersion) }
+	users := make([]db.ImportUser, 0, len(d.Users))
+	for _, u := range d.Users {
+		iu := db.ImportUser{ID: u.ID, Email: u.Email, PasswordHash: u.PasswordHash, Public: u.Public, DisplayName: u.DisplayName, CreatedAt: u.CreatedAt}
+		for _, b := range u.Boards {
+			ib := db.ImportBoard{ID: b.ID, Name: b.Name, CreatedAt: b.CreatedAt}
+			if b.Settings != nil { ib.Settings = &db.BoardSettings{Width: b.Settings.Width, Height: b.Settings.Height, Background: b.Settings.Background} }
+			iu.Boards = append(iu.Boards, ib)
+		}
+		for i, in := range u.Strokes {
+			pts := make([]db.StrokePoint, 0, len(in.Points))
+			for _, p := range in.Points { pts = append(pts, db.StrokePoint{X: p.X, Y: p.Y}) }
+			st := db.Stroke{Color: in.Color, Width: in.Width, StartedAtUnixMs: in.StartedAtUnixMs, Points: pts, Note: in.Note, ClientID: in.ClientID, LineStyle: in.LineStyle, BoardID: in.BoardID, CreatedBy: in.CreatedBy, CreatedAt: in.CreatedAt}
+			if errs := db.NormalizeStroke(&st, fmt.Sprintf("strokes[%d].", i)); errs.Any() { return db.ImportResult{}, fmt.Errorf("user %s: %w", u.Email, errs) }
+			iu.Strokes = append(iu.Strokes, st)
+		}
+		users = append(users, iu)
+	}
+	return store.ImportUsers(users)
+}
+
+// runDumpCommand implements `server export` and `server import` and returns the exit code
+func runDumpCommand(name string, args []string) int {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	dbPath := fs.String("db", getEnv("DB_PATH", "file:data.db?_fk=1"), "sqlite dsn or file path")
+	var path *string
+	var withHashes *bool
+	if name == "export" {
+		path = fs.String("out", "", "file to write the dump to (default stdout)")
+		withHashes = fs.Bool("password_hashes", false, "include password hashes so users keep their passwords; the dump is then as sensitive as the database")
+	} else {
+		path = fs.String("in", "", "dump file to read (default stdin)")
+	}
+	if err := fs.Parse(args); err != nil { return 2 }
+
+	store, err := db.Open(*dbPath)
+	if err != nil { log.Printf("open db: %v", err); return 1 }
+	defer store.Close()
+
+	if name == "export" {
+		d, err := exportDump(store, *withHashes)
+		if err != nil { log.Printf("export: %v", err); return 1 }
+		out := io.Writer(os.Stdout)
+		if *path != "" {
+			f, err := os.Create(*path)
+			if err != nil { log.Printf("export: %v", err); return 1 }
+			defer f.Close()
+			out = f
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(d); err != nil { log.Printf("export: %v", err); return 1 }
+		log.Printf("exported %d users", len(d.Users))
+		return 0
+	}
+
+	in := io.Reader(os.Stdin)
+	if *path != "" {
+		f, err := os.Open(*path)
+		if err != nil { log.Printf("import: %v", err); return 1 }
+		defer f.Close()
+		in = f
+	}
+	var d dump
+	if err := json.NewDecoder(in).Decode(&d); err != nil { log.Printf("import: %v", err); return 1 }
+	res, err := importDump(store, &d)
+	if err != nil { log.Printf("import: %v", err); return 1 }
+	log.Printf("imported %d users, %d boards, %d strokes", res.Users, res.Boards, res.Strokes)
+	return 0
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/deliium/drawing-board/internal/db"
+)
+
+func openDumpStore(t *testing.T, name string) *db.Store {
+	t.Helper()
+	store, err := db.Open(filepath.Join(t.TempDir(), name))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// seedDumpStore fills a store with a public user who has two boards and a stroke bob drew on them
+func seedDumpStore(t *testing.T, store *db.Store) {
+	t.Helper()
+	alice, err := store.CreateUser("alice@example.com", "$2a$10$c2FsdAc2FsdAc2FsdAc2FuGE4ZXkKa2V5a2V5a2V5a2V5a2V5a2V5")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	bob, err := store.CreateUser("bob@example.com", "$2a$10$c2FsdAc2FsdAc2FsdAc2FuYm9iYm9iYm9iYm9iYm9iYm9iYm9iYm9i")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	if err := store.SetUserPublic(alice, true); err != nil {
+		t.Fatalf("Failed to set public: %v", err)
+	}
+	if err := store.SetDisplayName(alice, "Alice"); err != nil {
+		t.Fatalf("Failed to set display name: %v", err)
+	}
+	kanji, err := store.CreateBoard(alice, "Kanji")
+	if err != nil {
+		t.Fatalf("Failed to create board: %v", err)
+	}
+	if err := store.SetBoardSettings(kanji, db.BoardSettings{Width: 800, Height: 600, Background: "#fafafa"}); err != nil {
+		t.Fatalf("Failed to save settings: %v", err)
+	}
+	strokes := []db.Stroke{
+		{Color: "#000000", Width: 2, StartedAtUnixMs: 1000, Points: []db.StrokePoint{{X: 1, Y: 2}, {X: 3, Y: 4}}, Note: "first"},
+		{Color: "#ff0000", Width: 4, StartedAtUnixMs: 2000, Points: []db.StrokePoint{{X: 5, Y: 6}}, BoardID: kanji, CreatedBy: bob, ClientID: "tab-1", LineStyle: db.LineDashed},
+	}
+	if _, err := store.SaveStrokes(alice, strokes, db.IDFresh); err != nil {
+		t.Fatalf("Failed to save strokes: %v", err)
+	}
+	// Back-dated, so an import that stamped rows with the time of the import would show
+	for _, table := range []string{"users", "boards", "strokes"} {
+		if _, err := store.SQL.Exec("UPDATE "+table+" SET created_at = datetime('2020-01-02 03:04:05', '+' || id || ' minutes')"); err != nil {
+			t.Fatalf("Failed to back-date %s: %v", table, err)
+		}
+	}
+}
+
+// portable replaces database IDs in d with what they point at, so dumps of different databases compare equal
+func portable(d *dump) []dumpUser {
+	emails := map[int64]string{}
+	for _, u := range d.Users { emails[u.ID] = u.Email }
+	out := make([]dumpUser, 0, len(d.Users))
+	for _, u := range d.Users {
+		boards := map[int64]int64{}
+		bs := make([]dumpBoard, 0, len(u.Boards))
+		for i, b := range u.Boards { boards[b.ID] = int64(i); b.ID = 0; bs = append(bs, b) }
+		u.Boards = bs
+		strokes := append(u.Strokes[:0:0], u.Strokes...)
+		for i := range strokes {
+			strokes[i].ID, strokes[i].BoardID = 0, boards[strokes[i].BoardID]
+			strokes[i].ClientID += "@" + emails[strokes[i].CreatedBy]
+			strokes[i].CreatedBy = 0
+		}
+		u.ID, u.Strokes = 0, strokes
+		out = append(out, u)
+	}
+	return out
+}
+
+func TestDump_RoundTrip(t *testing.T) {
+	src := openDumpStore(t, "src.db")
+	seedDumpStore(t, src)
+	exported, err := exportDump(src, true)
+	if err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(exported); err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	var decoded dump
+	if err := json.NewDecoder(&buf).Decode(&decoded); err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+
+	dst := openDumpStore(t, "dst.db")
+	// An unrelated user shifts every ID, so the import has to remap rather than copy them
+	if _, err := dst.CreateUser("carol@example.com", "hash"); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	res, err := importDump(dst, &decoded)
+	if err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+	if res != (db.ImportResult{Users: 2, Boards: 3, Strokes: 2}) {
+		t.Fatalf("Expected 2 users, 3 boards and 2 strokes, got %+v", res)
+	}
+	reexported, err := exportDump(dst, true)
+	if err != nil {
+		t.Fatalf("Failed to export the import: %v", err)
+	}
+	reexported.Users = reexported.Users[1:]
+	if got, want := portable(reexported), portable(exported); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected the import to reproduce the export\n got %+v\nwant %+v", got, want)
+	}
+	if u, _ := dst.GetUserByEmail("bob@example.com"); u == nil || u.PasswordHash != "$2a$10$c2FsdAc2FsdAc2FsdAc2FuYm9iYm9iYm9iYm9iYm9iYm9iYm9iYm9i" {
+		t.Fatalf("Expected bob's password hash to survive the round trip, got %+v", u)
+	}
+}
+
+func TestDump_OmitsPasswordHashesByDefault(t *testing.T) {
+	store := openDumpStore(t, "src.db")
+	seedDumpStore(t, store)
+	d, err := exportDump(store, false)
+	if err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	b, _ := json.Marshal(d)
+	if strings.Contains(string(b), "$2a$") || strings.Contains(string(b), "passwordHash") {
+		t.Fatalf("Expected no password hashes in the dump, got %s", b)
+	}
+}
+
+func TestDump_ImportRefusesExistingUsers(t *testing.T) {
+	store := openDumpStore(t, "src.db")
+	seedDumpStore(t, store)
+	d, err := exportDump(store, true)
+	if err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	if _, err := importDump(store, d); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("Expected importing into the same database to be refused, got %v", err)
+	}
+	if users, _ := store.ListUsers(); len(users) != 2 {
+		t.Fatalf("Expected nothing to be imported, got %d users", len(users))
+	}
+}
+
+func TestDump_ImportKeepsTimestamps(t *testing.T) {
+	src := openDumpStore(t, "src.db")
+	seedDumpStore(t, src)
+	exported, err := exportDump(src, false)
+	if err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	dst := openDumpStore(t, "dst.db")
+	if _, err := importDump(dst, exported); err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+	alice, _ := dst.GetUserByEmail("alice@example.com")
+	if alice == nil || !alice.CreatedAt.Equal(exported.Users[0].CreatedAt) || alice.CreatedAt.Year() != 2020 {
+		t.Fatalf("Expected alice's creation time %v, got %+v", exported.Users[0].CreatedAt, alice)
+	}
+	boards, _ := dst.ListBoardsByUser(alice.ID)
+	strokes, _ := dst.ListStrokesByUser(alice.ID)
+	if len(boards) != 2 || !boards[1].CreatedAt.Equal(exported.Users[0].Boards[1].CreatedAt) {
+		t.Fatalf("Expected board creation times to be kept, got %+v", boards)
+	}
+	if len(strokes) != 2 || !strokes[1].CreatedAt.Equal(exported.Users[0].Strokes[1].CreatedAt) {
+		t.Fatalf("Expected stroke creation times to be kept, got %+v", strokes)
+	}
+	// Retention compares stored times as text, so imported ones must be stored the same way
+	if n, err := dst.PurgeStrokesBefore(exported.Users[0].Strokes[1].CreatedAt); err != nil || n != 1 {
+		t.Fatalf("Expected only the older stroke to be purged, got %d, %v", n, err)
+	}
+}
+
+func TestDump_ImportIsAllOrNothing(t *testing.T) {
+	src := openDumpStore(t, "src.db")
+	seedDumpStore(t, src)
+	d, err := exportDump(src, false)
+	if err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	// The second copy of alice fails only once the first is already inserted
+	dup := d.Users[0]
+	dup.ID = 99
+	d.Users = append(d.Users, dup)
+	dst := openDumpStore(t, "dst.db")
+	if _, err := importDump(dst, d); !errors.Is(err, db.ErrDuplicate) {
+		t.Fatalf("Expected a duplicate email to fail the import, got %v", err)
+	}
+	if users, _ := dst.ListUsers(); len(users) != 0 {
+		t.Fatalf("Expected nothing to be imported, got %d users", len(users))
+	}
+}
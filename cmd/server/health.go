@@ -0,0 +1,24 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/deliium/drawing-board/internal/recognize"
+)
+
+// healthResponse is the /healthz body; Recognizer names the implementation that initialized, so
+// a probe shows when the server came up on the simple fallback
+type healthResponse struct {
+	Status     string `json:"status"`
+	Recognizer string `json:"recognizer"`
+}
+
+func healthHandler(rec recognize.Recognizer) http.HandlerFunc {
+	body, _ := json.Marshal(healthResponse{Status: "ok", Recognizer: rec.Name()})
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(body)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/deliium/drawing-board/internal/recognize"
+)
+
+func TestHealthHandler_ReportsRecognizer(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		rec  recognize.Recognizer
+		want string
+	}{
+		{"simple", recognize.NewSimpleRecognizer(), `{"status":"ok","recognizer":"simple"}`},
+		{"wrapped", recognize.NewCachedRecognizer(recognize.NewFallbackRecognizer(&recognize.HTTPRecognizer{}, recognize.NewSimpleRecognizer(), 0), time.Minute), `{"status":"ok","recognizer":"http"}`},
+	} {
+		rec := httptest.NewRecorder()
+		healthHandler(tc.rec)(rec, httptest.NewRequest("GET", "/healthz", nil))
+		if rec.Code != 200 || rec.Body.String() != tc.want {
+			t.Fatalf("%s: expected 200 %s, got %d %s", tc.name, tc.want, rec.Code, rec.Body.String())
+		}
+		if got := rec.Header().Get("Content-Type"); got != "application/json" {
+			t.Fatalf("%s: expected JSON, got %q", tc.name, got)
+		}
+	}
+}
@@ -2,56 +2,291 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"flag"
+	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/deliium/drawing-board/internal/auth"
+	"github.com/deliium/drawing-board/internal/blobstore"
 	"github.com/deliium/drawing-board/internal/db"
+	"github.com/deliium/drawing-board/internal/features"
 	"github.com/deliium/drawing-board/internal/httpapi"
+	"github.com/deliium/drawing-board/internal/metrics"
+	"github.com/deliium/drawing-board/internal/ratelimit"
+	"github.com/deliium/drawing-board/internal/raster"
 	"github.com/deliium/drawing-board/internal/recognize"
+	"github.com/deliium/drawing-board/internal/webhook"
 	"github.com/deliium/drawing-board/internal/ws"
 	"github.com/gorilla/mux"
-	"github.com/gorilla/sessions"
 )
 
 func main() {
+	if len(os.Args) > 1 && (os.Args[1] == "export" || os.Args[1] == "import") {
+		os.Exit(runDumpCommand(os.Args[1], os.Args[2:]))
+	}
 	var (
 		addr = flag.String("addr", getEnv("ADDR", ":8080"), "http service address")
+		tlsCert = flag.String("tls_cert", getEnv("TLS_CERT", ""), "PEM certificate file; with tls_key the server speaks HTTPS instead of plain HTTP")
+		tlsKey = flag.String("tls_key", getEnv("TLS_KEY", ""), "PEM private key file for tls_cert")
+		shutdownTimeout = flag.Duration("shutdown_timeout", getEnvDuration("SHUTDOWN_TIMEOUT", DefaultShutdownTimeout), "how long in-flight requests and WebSockets get to finish after SIGINT/SIGTERM")
 		staticDir = flag.String("static", getEnv("STATIC_DIR", ""), "directory to serve static files from (optional)")
 		dbPath = flag.String("db", getEnv("DB_PATH", "file:data.db?_fk=1"), "sqlite dsn or file path")
-		cookieKey = flag.String("cookie", getEnv("COOKIE_KEY", "change-me-please-32-bytes-min"), "cookie auth key")
-		onnxModel = flag.String("onnx_model", getEnv("ONNX_MODEL", "./models/handwriting.onnx"), "path to ONNX model")
+		cookieKey = flag.String("cookie", getEnv("COOKIE_KEY", defaultCookieKey), "cookie auth key")
+		requestTimeout = flag.Duration("request_timeout", getEnvDuration("REQUEST_TIMEOUT", 20*time.Second), "how long a request may run before it is cut off with 503 (0 disables); WebSockets and NDJSON streams are exempt")
+		requestTimeouts = flag.String("request_timeouts", getEnv("REQUEST_TIMEOUTS", ""), "comma-separated /path=duration overrides of request_timeout, longest prefix wins (e.g. /api/recognize=10s,/api/export.png=25s)")
+		sessionBackend = flag.String("session_backend", getEnv("SESSION_BACKEND", "cookie"), "where sessions live: cookie (signed into the session cookie) or redis (server-side at session_redis_url, shared by every instance)")
+		sessionRedisURL = flag.String("session_redis_url", getEnv("SESSION_REDIS_URL", "redis://localhost:6379/0"), "redis://[:password@]host[:port][/db] for session_backend=redis")
+		onnxModel = flag.String("onnx_model", getEnv("ONNX_MODEL", "./models/handwriting.onnx"), "path to ONNX model; labels are read from the same name with .labels, or labels.txt beside it")
+		onnxRuntimeLib = flag.String("onnxruntime_lib", getEnv("ONNXRUNTIME_LIB", ""), "onnxruntime shared library (default: onnxruntime.so on the loader path)")
+		retention = flag.Duration("retention", getEnvDuration("RETENTION", 0), "delete strokes older than this (0 keeps strokes forever)")
+		retentionInterval = flag.Duration("retention_interval", getEnvDuration("RETENTION_INTERVAL", time.Hour), "how often the retention purge runs")
+		webhookURL = flag.String("webhook_url", getEnv("WEBHOOK_URL", ""), "URL that receives POSTed stroke events (disabled when empty)")
+		recognizerKind = flag.String("recognizer", getEnv("RECOGNIZER", "onnx"), "recognizer implementation: onnx, simple or http")
+		recognizerURL = flag.String("recognizer_url", getEnv("RECOGNIZER_URL", ""), "endpoint of the remote recognizer when -recognizer=http")
+		recognizerHTTPTimeout = flag.Duration("recognizer_http_timeout", getEnvDuration("RECOGNIZER_HTTP_TIMEOUT", 2*time.Second), "per-attempt timeout for the remote recognizer")
+		recognizerRetries = flag.Int("recognizer_retries", getEnvInt("RECOGNIZER_RETRIES", 1), "extra attempts after a failed remote recognition")
+		recognizerHTTPDeadline = flag.Duration("recognizer_http_deadline", getEnvDuration("RECOGNIZER_HTTP_DEADLINE", 0), "total time for all remote recognizer attempts and backoff (0 is unbounded)")
+		recognizerBackoff = flag.Duration("recognizer_backoff", getEnvDuration("RECOGNIZER_BACKOFF", recognize.DefaultHTTPBackoff), "wait before the first remote retry; doubles per retry with jitter")
+		recognizerMaxBackoff = flag.Duration("recognizer_max_backoff", getEnvDuration("RECOGNIZER_MAX_BACKOFF", recognize.DefaultHTTPMaxBackoff), "longest wait between remote retries")
+		recognizerBreakerThreshold = flag.Int("recognizer_breaker_threshold", getEnvInt("RECOGNIZER_BREAKER_THRESHOLD", 5), "consecutive failed remote recognitions that open the circuit breaker (0 disables it)")
+		recognizerBreakerCooldown = flag.Duration("recognizer_breaker_cooldown", getEnvDuration("RECOGNIZER_BREAKER_COOLDOWN", 30*time.Second), "how long an open breaker sends everything to the simple recognizer before trying the remote again")
+		strokesPageSize = flag.Int("strokes_page_size", getEnvInt("STROKES_PAGE_SIZE", 500), "strokes returned by /api/strokes per page (0 returns all)")
+		wsWriteDeadline = flag.Duration("ws_write_deadline", getEnvDuration("WS_WRITE_DEADLINE", ws.DefaultWriteDeadline), "how long a WebSocket write may block before the client is dropped")
+		wsSendBuffer = flag.Int("ws_send_buffer", getEnvInt("WS_SEND_BUFFER", ws.DefaultSendBuffer), "messages queued per WebSocket client before it is dropped as too slow")
+		wsReadBuffer = flag.Int("ws_read_buffer", getEnvInt("WS_READ_BUFFER", ws.DefaultReadBufferSize), "WebSocket read buffer per connection, in bytes")
+		wsWriteBuffer = flag.Int("ws_write_buffer", getEnvInt("WS_WRITE_BUFFER", ws.DefaultWriteBufferSize), "WebSocket write buffer, in bytes")
+		wsWriteBufferPool = flag.Bool("ws_write_buffer_pool", getEnv("WS_WRITE_BUFFER_POOL", "true") != "false", "share WebSocket write buffers between connections instead of holding one per connection")
+		wsUpgradeRate = flag.Float64("ws_upgrade_rate", getEnvFloat("WS_UPGRADE_RATE", 1), "sustained /ws upgrades per second allowed per user or IP")
+		wsUpgradeBurst = flag.Int("ws_upgrade_burst", getEnvInt("WS_UPGRADE_BURST", 10), "burst of /ws upgrades allowed per user or IP")
+		recognizeColorLevels = flag.Int("recognize_color_levels", getEnvInt("RECOGNIZE_COLOR_LEVELS", recognize.DefaultColorLevels), "per-channel levels stroke colors are quantized to for byColor recognition (2-256)")
+		recognizeConcurrency = flag.Int("recognize_concurrency", getEnvInt("RECOGNIZE_CONCURRENCY", httpapi.DefaultRecognizeConcurrency), "max parallel recognitions for /api/recognize/all")
+		minStrokePoints = flag.Int("recognize_min_stroke_points", getEnvInt("RECOGNIZE_MIN_STROKE_POINTS", 2), "strokes with fewer points are ignored by recognition (still stored)")
+		minStrokeLength = flag.Float64("recognize_min_stroke_length", getEnvFloat("RECOGNIZE_MIN_STROKE_LENGTH", 5), "strokes shorter than this many pixels are ignored by recognition (still stored)")
+		wsMaxClients = flag.Int("ws_max_clients", getEnvInt("WS_MAX_CLIENTS", ws.DefaultMaxClients), "max concurrent WebSocket connections (0 is unlimited)")
+		wsPingInterval = flag.Duration("ws_ping_interval", getEnvDuration("WS_PING_INTERVAL", ws.DefaultPingInterval), "how often WebSocket clients are pinged")
+		wsPongWait = flag.Duration("ws_pong_wait", getEnvDuration("WS_PONG_WAIT", ws.DefaultPongWait), "drop a WebSocket client whose last pong is older than this (must exceed ws_ping_interval)")
+		wsStatsInterval = flag.Duration("ws_stats_interval", getEnvDuration("WS_STATS_INTERVAL", 5*time.Minute), "how often the WebSocket client count is logged (0 disables)")
+		simpleComplex = flag.String("simple_complex_candidates", getEnv("SIMPLE_COMPLEX_CANDIDATES", ""), "text:score list the simple recognizer suggests for 4+ strokes (default 国:0.5,学:0.4,生:0.3)")
+		minDensity = flag.Float64("recognize_min_density", getEnvFloat("RECOGNIZE_MIN_DENSITY", recognize.DefaultMinDensity), "share of the canvas (0-1) that must hold ink before recognition suggests anything; sparser boards get no candidates (0 disables)")
+		strokeTieBreak = flag.Bool("recognize_stroke_tiebreak", getEnv("RECOGNIZE_STROKE_TIEBREAK", "true") != "false", "rank equal-score candidates whose canonical stroke count matches the drawing first")
+		maxRasterPixels = flag.Int64("max_raster_pixels", int64(getEnvInt("MAX_RASTER_PIXELS", raster.DefaultMaxPixels)), "largest width*height any recognition, export or thumbnail raster may allocate")
+		featureSpec = flag.String("features", getEnv("FEATURES", ""), "comma-separated feature toggles, e.g. \"-export\" (see internal/features for names)")
+		featureDisabledStatus = flag.Int("features_disabled_status", getEnvInt("FEATURES_DISABLED_STATUS", http.StatusNotFound), "status returned by endpoints of disabled features (404 or 501)")
+		recognizeCacheTTL = flag.Duration("recognize_cache_ttl", getEnvDuration("RECOGNIZE_CACHE_TTL", 0), "how long identical recognitions are served from memory (0 disables the cache)")
+		recognizeCacheSweep = flag.Duration("recognize_cache_sweep_interval", getEnvDuration("RECOGNIZE_CACHE_SWEEP_INTERVAL", time.Minute), "how often expired recognition cache entries are evicted")
+		passwordCost = flag.Int("password_cost", getEnvInt("PASSWORD_COST", auth.DefaultPasswordCost), "bcrypt password hashing cost; older and weaker hashes are upgraded on login")
+		maxEmailLength = flag.Int("max_email_length", getEnvInt("MAX_EMAIL_LENGTH", auth.DefaultMaxEmailLength), "longest email, in characters, that can register")
+		emailDomainsDeny = flag.String("email_domains_deny", getEnv("EMAIL_DOMAINS_DENY", ""), "comma-separated email domains, with their subdomains, that cannot register (e.g. disposable-mail providers)")
+		emailDomainsDenyFile = flag.String("email_domains_deny_file", getEnv("EMAIL_DOMAINS_DENY_FILE", ""), "file of email domains that cannot register, one per line ('#' starts a comment); added to email_domains_deny")
+		emailDomainsAllow = flag.String("email_domains_allow", getEnv("EMAIL_DOMAINS_ALLOW", ""), "comma-separated email domains, with their subdomains, that alone may register; email_domains_deny still applies (empty allows any)")
+		emailDomainsAllowFile = flag.String("email_domains_allow_file", getEnv("EMAIL_DOMAINS_ALLOW_FILE", ""), "file of email domains that alone may register, one per line; added to email_domains_allow")
+		passwordPeppers = flag.String("password_peppers", getEnv("PASSWORD_PEPPERS", ""), "comma-separated id:secret peppers HMACed into passwords before hashing; the first makes new hashes, the rest still verify (empty disables)")
+		passwordPeppersFile = flag.String("password_peppers_file", getEnv("PASSWORD_PEPPERS_FILE", ""), "file holding the password_peppers list, one per line; takes precedence over password_peppers")
+		corsOrigins = flag.String("cors_origins", getEnv("CORS_ORIGINS", ""), "comma-separated origins allowed to make credentialed cross-origin requests; \"*\" allows any origin without credentials (empty allows none)")
+		cookieSameSite = flag.String("cookie_samesite", getEnv("COOKIE_SAMESITE", "lax"), "SameSite mode of the session cookie: lax, strict or none (none is for embedding in other sites; it forces Secure, so HTTPS, and needs explicit cors_origins)")
+		adminEmails = flag.String("admin_emails", getEnv("ADMIN_EMAILS", ""), "comma-separated emails allowed to use /api/admin endpoints")
+		loginAttempts = flag.Int("login_attempts", getEnvInt("LOGIN_ATTEMPTS", 10), "failed login or register attempts allowed per IP and per email within login_window before 429 (0 disables)")
+		loginWindow = flag.Duration("login_window", getEnvDuration("LOGIN_WINDOW", 15*time.Minute), "window over which login_attempts refill")
+		sessionTTL = flag.Duration("session_ttl", getEnvDuration("SESSION_TTL", auth.DefaultSessionTTL), "how long a session lasts after sign-in or its last /api/ping; 0 never expires it")
+		sessionRolePolicy = flag.String("session_role_policy", getEnv("SESSION_ROLE_POLICY", "refresh"), "when a signed-in user's role changes: refresh (update the session on its next request) or reauth (end it)")
+		autoMigrate = flag.Bool("auto_migrate", getEnv("AUTO_MIGRATE", "true") != "false", "apply pending schema migrations on startup (otherwise POST /api/admin/migrate)")
+		smoothMethod = flag.String("stroke_smoothing", getEnv("STROKE_SMOOTHING", "none"), "smoothing applied to stroke points before saving: none, moving or gaussian")
+		smoothWindow = flag.Int("stroke_smoothing_window", getEnvInt("STROKE_SMOOTHING_WINDOW", 5), "points in the stroke smoothing window (at least 3)")
+		wsBatchWindow = flag.Duration("ws_batch_window", getEnvDuration("WS_BATCH_WINDOW", 0), "save a connection's strokes in one transaction after this much inactivity (0 saves each stroke at once)")
+		wsBatchSize = flag.Int("ws_batch_size", getEnvInt("WS_BATCH_SIZE", ws.DefaultBatchSize), "flush a stroke batch early once this many strokes are waiting")
+		wsPresence = flag.Bool("ws_presence", getEnv("WS_PRESENCE", "true") != "false", "send each room the client IDs connected to it whenever someone joins or leaves")
+		wsCursorRate = flag.Float64("ws_cursor_rate", getEnvFloat("WS_CURSOR_RATE", ws.DefaultCursorRate), "cursor messages relayed per second per connection; extra ones are dropped")
+		wsCursorBurst = flag.Int("ws_cursor_burst", getEnvInt("WS_CURSOR_BURST", ws.DefaultCursorBurst), "cursor messages a connection may send at once before the rate applies")
+		recognizeImageFormats = flag.String("recognize_image_formats", getEnv("RECOGNIZE_IMAGE_FORMATS", recognize.DefaultImageFormats.String()), "image formats accepted by /api/recognize/image (png, jpeg); others get 415")
+		recognizePreprocess = flag.String("recognize_preprocess", getEnv("RECOGNIZE_PREPROCESS", ""), "ordered preprocessing stages run before recognition, e.g. \"dedupe:0.5,resample:32,normalize\" (empty disables)")
+		simplifyEpsilon = flag.Float64("simplify_epsilon", getEnvFloat("SIMPLIFY_EPSILON", 0), "drop stored stroke points within this many pixels of the simplified line (Douglas-Peucker; 0 keeps every point)")
+		dbMaxWriters = flag.Int("db_max_writers", getEnvInt("DB_MAX_WRITERS", db.DefaultMaxWriters), "concurrent write transactions allowed; the rest queue in-process (0 is unlimited)")
+		recognizeTimingHeader = flag.String("recognize_timing_header", getEnv("RECOGNIZE_TIMING_HEADER", httpapi.DefaultTimingHeader), "response header carrying /api/recognize compute time in ms (empty omits it)")
+		jsonMaxDepth = flag.Int("json_max_depth", getEnvInt("JSON_MAX_DEPTH", httpapi.DefaultJSONMaxDepth), "deepest object/array nesting accepted in import and tensor request bodies")
+		jsonMaxArray = flag.Int("json_max_array", getEnvInt("JSON_MAX_ARRAY", httpapi.DefaultJSONMaxArray), "longest array accepted in import and tensor bodies, unless the field has its own cap (strokes, points, tensor)")
+		exportStoreDir = flag.String("export_store_dir", getEnv("EXPORT_STORE_DIR", ""), "directory rendered PNG/SVG exports are cached in, one file per board version (empty renders every request)")
+		exportStoreURL = flag.String("export_store_url", getEnv("EXPORT_STORE_URL", ""), "public URL serving export_store_dir; exports then redirect there instead of being proxied")
+		exportStoreMaxAge = flag.Duration("export_store_max_age", getEnvDuration("EXPORT_STORE_MAX_AGE", 30*24*time.Hour), "remove cached exports not read or written for this long (0 keeps them)")
+		exportStoreMaxBytes = flag.Int64("export_store_max_bytes", int64(getEnvInt("EXPORT_STORE_MAX_BYTES", 0)), "remove the least recently used cached exports while export_store_dir holds more than this (0 is unlimited)")
+		exportStoreSweep = flag.Duration("export_store_sweep_interval", getEnvDuration("EXPORT_STORE_SWEEP_INTERVAL", time.Hour), "how often export_store_max_age and export_store_max_bytes are applied")
+		recognizeGlossary = flag.String("recognize_glossary", getEnv("RECOGNIZE_GLOSSARY", ""), "JSON file of candidate readings and descriptions per language, laid over the built-in en/ja glossary (empty uses the built-in one)")
+		debugRecognize = flag.Bool("debug_recognize", getEnv("DEBUG_RECOGNIZE", "false") == "true", "log every recognition's strokes, candidates and an ASCII dump of the pattern recognizer's tensor, and allow returnImage on /api/recognize (verbose; for local debugging)")
+		recognizeTimeout = flag.Duration("recognize_timeout", getEnvDuration("RECOGNIZE_TIMEOUT", 5*time.Second), "max time for the ONNX or HTTP recognizer before falling back to the simple recognizer")
 	)
 	flag.Parse()
 
-	store, err := db.Open(*dbPath)
+	raster.SetMaxPixels(*maxRasterPixels)
+	if err := auth.SetPasswordCost(*passwordCost); err != nil { log.Fatalf("password_cost: %v", err) }
+	if *passwordPeppersFile != "" {
+		b, err := os.ReadFile(*passwordPeppersFile)
+		if err != nil { log.Fatalf("password_peppers_file: %v", err) }
+		*passwordPeppers = string(b)
+	}
+	if err := auth.SetPeppers(*passwordPeppers); err != nil { log.Fatalf("password_peppers: %v", err) }
+
+	feats, err := features.New(*featureSpec)
+	if err != nil { log.Fatalf("features: %v", err) }
+	if *featureDisabledStatus != http.StatusNotFound && *featureDisabledStatus != http.StatusNotImplemented {
+		log.Fatalf("features_disabled_status must be 404 or 501, got %d", *featureDisabledStatus)
+	}
+	feats.DisabledStatus = *featureDisabledStatus
+
+	open := db.Open
+	if !*autoMigrate { open = db.OpenWithoutMigrations }
+	store, err := open(*dbPath)
 	if err != nil { log.Fatalf("open db: %v", err) }
+	store.SetMaxWriters(*dbMaxWriters)
+	store.SetSimplifyEpsilon(*simplifyEpsilon)
+	if st, err := store.SchemaStatus(); err != nil {
+		log.Printf("Warning: schema status: %v", err)
+	} else if len(st.Pending) > 0 {
+		log.Printf("Warning: schema at version %d, %d migrations pending", st.Version, len(st.Pending))
+	}
 
-	sessionStore := sessions.NewCookieStore([]byte(*cookieKey))
-	sessionStore.Options = &sessions.Options{ Path: "/", HttpOnly: true, SameSite: http.SameSiteLaxMode }
-	authSvc := &auth.Service{ Store: store, Sessions: sessionStore }
+	jobs := newBackground()
+	if *retention > 0 {
+		jobs.Go(func(stop <-chan struct{}) { store.RunRetention(*retention, *retentionInterval, stop) })
+	}
+
+	tf := tlsFiles{Cert: *tlsCert, Key: *tlsKey}
+	if !tf.enabled() && (tf.Cert != "" || tf.Key != "") { log.Printf("Warning: tls_cert and tls_key must both be set; serving plain HTTP") }
+	sameSite, err := auth.ParseSameSite(*cookieSameSite)
+	if err != nil { log.Fatalf("cookie_samesite: %v", err) }
+	origins := parseCORSOrigins(*corsOrigins)
+	if sameSite == http.SameSiteNoneMode {
+		// Without listed origins no embedder could use the session, and every other site could still post with it
+		if len(origins.allowed) == 0 { log.Fatalf("cookie_samesite=none needs the embedding sites listed in cors_origins") }
+		origins.guardWrites = true
+		if !tf.enabled() { log.Printf("Warning: cookie_samesite=none without tls_cert; the Secure session cookie only works behind an HTTPS proxy") }
+	}
+	sessionStore, err := newSessionStore(*sessionBackend, *sessionRedisURL, *cookieKey, sameSite, tf.enabled())
+	if err != nil { log.Fatalf("session_backend: %v", err) }
+	authSvc := newAuthService(store, sessionStore, sameSite, tf.enabled())
+	authSvc.MaxEmailLength = *maxEmailLength
+	domains := func(name, spec, file string) []string {
+		list, err := auth.ParseDomainList(spec)
+		if err != nil { log.Fatalf("%s: %v", name, err) }
+		if file == "" { return list }
+		more, err := auth.LoadDomainList(file)
+		if err != nil { log.Fatalf("%s_file: %v", name, err) }
+		return append(list, more...)
+	}
+	authSvc.EmailDomains = auth.EmailDomains{
+		Deny:  domains("email_domains_deny", *emailDomainsDeny, *emailDomainsDenyFile),
+		Allow: domains("email_domains_allow", *emailDomainsAllow, *emailDomainsAllowFile),
+	}
+	authSvc.SessionTTL = *sessionTTL
+	authSvc.Attempts = auth.NewAttemptLimiter(*loginAttempts, *loginWindow)
+	if *adminEmails != "" { authSvc.AdminEmails = strings.Split(*adminEmails, ",") }
+	if authSvc.RolePolicy, err = auth.ParseRolePolicy(*sessionRolePolicy); err != nil { log.Fatalf("session_role_policy: %v", err) }
 	
+	simple := recognize.NewSimpleRecognizer()
+	simple.NoStrokeTieBreak = !*strokeTieBreak
+	if *minDensity < 0 || *minDensity > 1 { log.Fatalf("recognize_min_density must be between 0 and 1, got %v", *minDensity) }
+	simple.MinDensity = *minDensity
+	if *simpleComplex != "" {
+		simple.ComplexCandidates, err = recognize.ParseCandidates(*simpleComplex)
+		if err != nil { log.Fatalf("simple_complex_candidates: %v", err) }
+	}
+
+	var debugLog *log.Logger // nil unless -debug_recognize
+	if *debugRecognize { debugLog = log.New(os.Stderr, "recognize: ", log.LstdFlags) }
 	var recognizer recognize.Recognizer
-	if *onnxModel != "" {
+	serving := *recognizerKind // reported in the startup summary
+	switch *recognizerKind {
+	case "simple":
+		recognizer = simple
+	case "http":
+		httpRec, err := recognize.NewHTTPRecognizer(*recognizerURL, *recognizerHTTPTimeout, *recognizerRetries)
+		if err != nil {
+			recognize.RecordFallback(fmt.Sprintf("failed to initialize HTTP recognizer: %v", err))
+			recognizer, serving = simple, "simple (http init failed)"
+		} else {
+			httpRec.Deadline, httpRec.Backoff, httpRec.MaxBackoff = *recognizerHTTPDeadline, *recognizerBackoff, *recognizerMaxBackoff
+			httpRec.Breaker = recognize.NewBreaker(*recognizerBreakerThreshold, *recognizerBreakerCooldown)
+			recognizer = recognize.NewFallbackRecognizer(httpRec, simple, *recognizeTimeout)
+		}
+	case "onnx":
+		if *onnxModel == "" {
+			recognizer, serving = simple, "simple (no onnx model)"
+			break
+		}
+		recognize.SetONNXRuntimeLibrary(*onnxRuntimeLib)
 		onnxRec, err := recognize.NewONNXRecognizer(*onnxModel)
 		if err != nil {
-			log.Printf("Warning: failed to initialize ONNX recognizer: %v", err)
-			log.Printf("Falling back to simple recognizer")
-			recognizer = recognize.NewSimpleRecognizer()
+			recognize.RecordFallback(fmt.Sprintf("failed to initialize ONNX recognizer: %v", err))
+			recognizer, serving = simple, "simple (onnx init failed)"
 		} else {
-			recognizer = onnxRec
+			onnxRec.DebugLog, onnxRec.NoStrokeTieBreak, onnxRec.MinDensity = debugLog, !*strokeTieBreak, *minDensity
+			recognizer, serving = recognize.NewFallbackRecognizer(onnxRec, simple, *recognizeTimeout), "onnx ("+onnxRec.Backend()+")"
 		}
-	} else {
-		recognizer = recognize.NewSimpleRecognizer()
+	default:
+		log.Fatalf("unknown recognizer %q (want onnx, simple or http)", *recognizerKind)
+	}
+	if *recognizeCacheTTL > 0 {
+		cached := recognize.NewCachedRecognizer(recognizer, *recognizeCacheTTL)
+		jobs.Go(func(stop <-chan struct{}) { cached.RunSweeper(*recognizeCacheSweep, stop) })
+		recognizer = cached
+		serving += " cached"
 	}
 	
-	api := &httpapi.API{ Auth: authSvc, Store: store, Recognizer: recognizer }
-	ws.Init(store, authSvc)
+	var notifier *webhook.Notifier
+	if *webhookURL != "" {
+		notifier = webhook.New(*webhookURL, 256)
+	}
+
+	api := &httpapi.API{ Auth: authSvc, Store: store, Recognizer: recognizer, Webhook: notifier, DefaultStrokeLimit: *strokesPageSize, RecognizeConcurrency: *recognizeConcurrency,
+		StrokeFilter: recognize.StrokeFilter{ MinPoints: *minStrokePoints, MinLength: *minStrokeLength } }
+	api.Preprocess, err = recognize.ParsePipeline(*recognizePreprocess)
+	if err != nil { log.Fatalf("recognize_preprocess: %v", err) }
+	if len(api.Preprocess) > 0 { log.Printf("recognition preprocessing: %s", api.Preprocess) }
+	api.TimingHeader = *recognizeTimingHeader
+	api.ColorLevels = *recognizeColorLevels
+	api.DebugLog, api.DebugImages = debugLog, *debugRecognize
+	if *recognizeGlossary != "" {
+		if api.Glossary, err = recognize.LoadGlossary(*recognizeGlossary); err != nil { log.Fatalf("recognize_glossary: %v", err) }
+	}
+	api.JSONLimits = httpapi.JSONLimits{MaxDepth: *jsonMaxDepth, MaxArray: *jsonMaxArray}
+	api.ImageFormats, err = recognize.ParseImageFormats(*recognizeImageFormats)
+	if err != nil { log.Fatalf("recognize image formats: %v", err) }
+	if *exportStoreDir != "" {
+		dir, err := blobstore.NewDir(*exportStoreDir)
+		if err != nil { log.Fatalf("export store: %v", err) }
+		dir.MaxAge, dir.MaxBytes = *exportStoreMaxAge, *exportStoreMaxBytes
+		jobs.Go(func(stop <-chan struct{}) { dir.RunSweeper(*exportStoreSweep, stop) })
+		api.ExportStore, api.ExportStoreURL = dir, *exportStoreURL
+		log.Printf("caching exports in %s", *exportStoreDir)
+	} else if *exportStoreURL != "" {
+		log.Printf("Warning: export_store_url is ignored without export_store_dir")
+	}
+	hub := ws.Init(store, authSvc)
+	hub.Webhook = notifier
+	hub.WriteDeadline = *wsWriteDeadline
+	hub.SendBuffer = *wsSendBuffer
+	hub.ReadBufferSize, hub.WriteBufferSize, hub.PoolWriteBuffers = *wsReadBuffer, *wsWriteBuffer, *wsWriteBufferPool
+	hub.MaxClients = *wsMaxClients
+	hub.PingInterval = *wsPingInterval
+	hub.PongWait = *wsPongWait
+	if *wsPongWait <= *wsPingInterval { log.Printf("Warning: ws_pong_wait %v does not exceed ws_ping_interval %v; idle clients will be dropped", *wsPongWait, *wsPingInterval) }
+	hub.BatchWindow = *wsBatchWindow
+	hub.BatchSize = *wsBatchSize
+	hub.Presence = *wsPresence
+	hub.CursorRate = *wsCursorRate
+	hub.CursorBurst = *wsCursorBurst
+	hub.Smoothing, err = ws.ParseSmoothing(*smoothMethod, *smoothWindow)
+	if err != nil { log.Fatalf("stroke smoothing: %v", err) }
+	api.Hub = hub
+	jobs.Go(func(stop <-chan struct{}) { hub.LogClientCount(*wsStatsInterval, stop) })
 
 	r := mux.NewRouter()
 
@@ -60,22 +295,58 @@ func main() {
 	r.HandleFunc("/api/login", authSvc.Login).Methods(http.MethodPost)
 	r.HandleFunc("/api/logout", authSvc.Logout).Methods(http.MethodPost)
 	r.HandleFunc("/api/me", authSvc.Me).Methods(http.MethodGet)
+	r.HandleFunc("/api/ping", authSvc.Ping).Methods(http.MethodGet)
+	r.Handle("/api/password", authSvc.RequireAuth(http.HandlerFunc(authSvc.ChangePassword))).Methods(http.MethodPost)
+	r.Handle("/api/account/delete", authSvc.RequireAuth(http.HandlerFunc(authSvc.DeleteAccount))).Methods(http.MethodPost)
+	r.Handle("/api/me/public", authSvc.RequireAuth(http.HandlerFunc(api.SetPublic))).Methods(http.MethodPost)
+	r.Handle("/api/me/display_name", authSvc.RequireAuth(http.HandlerFunc(api.SetDisplayName))).Methods(http.MethodPost)
+	r.Handle("/api/account/merge", authSvc.RequireAuth(http.HandlerFunc(api.MergeAccounts))).Methods(http.MethodPost)
+	// Public gallery profiles (no auth; private users are 404)
+	r.HandleFunc("/api/users/{id:[0-9]+}/profile", api.UserProfile).Methods(http.MethodGet)
+
+	// Boards
+	r.Handle("/api/boards", authSvc.RequireAuth(http.HandlerFunc(api.ListBoards))).Methods(http.MethodGet)
+	r.Handle("/api/boards", authSvc.RequireAuth(http.HandlerFunc(api.CreateBoard))).Methods(http.MethodPost)
+	r.Handle("/api/boards/{id:[0-9]+}/settings", authSvc.RequireAuth(http.HandlerFunc(api.GetBoardSettings))).Methods(http.MethodGet)
+	r.Handle("/api/boards/{id:[0-9]+}/settings", authSvc.RequireAuth(http.HandlerFunc(api.SetBoardSettings))).Methods(http.MethodPost)
 
 	// Strokes endpoints
 	r.Handle("/api/strokes", authSvc.RequireAuth(http.HandlerFunc(api.ListStrokes))).Methods(http.MethodGet)
 	r.Handle("/api/strokes/clear", authSvc.RequireAuth(http.HandlerFunc(api.ClearStrokes))).Methods(http.MethodPost)
 	r.Handle("/api/strokes/delete", authSvc.RequireAuth(http.HandlerFunc(api.DeleteStroke))).Methods(http.MethodPost)
+	r.Handle("/api/strokes/note", authSvc.RequireAuth(http.HandlerFunc(api.SetStrokeNote))).Methods(http.MethodPost)
+	r.Handle("/api/strokes/recolor", authSvc.RequireAuth(http.HandlerFunc(api.RecolorStrokes))).Methods(http.MethodPost)
+	r.Handle("/api/strokes/orientation", authSvc.RequireAuth(http.HandlerFunc(api.Orientation))).Methods(http.MethodGet)
+	r.Handle("/api/strokes/sessions", authSvc.RequireAuth(http.HandlerFunc(api.CountSessions))).Methods(http.MethodGet)
+	r.Handle("/api/strokes/recent", authSvc.RequireAuth(http.HandlerFunc(api.RecentStrokes))).Methods(http.MethodGet)
+	r.Handle("/api/strokes/stream", authSvc.RequireAuth(http.HandlerFunc(hub.ServeStream))).Methods(http.MethodGet)
+	r.Handle("/api/strokes/import", authSvc.RequireAuth(http.HandlerFunc(api.ImportStrokes))).Methods(http.MethodPost)
+	// Export
+	r.Handle("/api/export.png", feats.Require(features.Export, authSvc.RequireAuth(http.HandlerFunc(api.ExportPNG)))).Methods(http.MethodGet)
+	r.Handle("/api/export.svg", feats.Require(features.Export, authSvc.RequireAuth(http.HandlerFunc(api.ExportSVG)))).Methods(http.MethodGet)
 	// Recognize
 	r.Handle("/api/recognize", authSvc.RequireAuth(http.HandlerFunc(api.Recognize))).Methods(http.MethodPost)
+	r.Handle("/api/recognize/tensor", authSvc.RequireAuth(http.HandlerFunc(api.RecognizeTensor))).Methods(http.MethodPost)
+	r.Handle("/api/recognize/image", authSvc.RequireAuth(http.HandlerFunc(api.RecognizeImage))).Methods(http.MethodPost)
+	r.Handle("/api/recognize/all", authSvc.RequireAuth(http.HandlerFunc(api.RecognizeAll))).Methods(http.MethodPost)
+	// Admin
+	r.Handle("/api/admin/schema", authSvc.RequireAdmin(http.HandlerFunc(api.SchemaStatus))).Methods(http.MethodGet)
+	r.Handle("/api/admin/migrate", authSvc.RequireAdmin(http.HandlerFunc(api.Migrate))).Methods(http.MethodPost)
+	r.Handle("/api/admin/optimize", authSvc.RequireAdmin(http.HandlerFunc(api.Optimize))).Methods(http.MethodPost)
+
+	// WebSocket endpoint (auth required), throttled per user (or IP) to absorb reconnect storms
+	upgradeLimiter := ratelimit.New(*wsUpgradeRate, *wsUpgradeBurst)
+	upgradeKey := func(r *http.Request) string {
+		if uid, ok := authSvc.UserIDFromRequest(r); ok { return "user:" + strconv.FormatInt(uid, 10) }
+		return "ip:" + ratelimit.ClientIP(r)
+	}
+	r.Handle("/ws", authSvc.RequireAuth(upgradeLimiter.Middleware(upgradeKey, http.HandlerFunc(handleWebSocket))))
 
-	// WebSocket endpoint (auth required)
-	r.Handle("/ws", authSvc.RequireAuth(http.HandlerFunc(handleWebSocket)))
+	// Metrics
+	r.Handle("/metrics", metrics.Handler()).Methods(http.MethodGet)
 
 	// Health check
-	r.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte("ok"))
-	}).Methods(http.MethodGet)
+	r.Handle("/healthz", healthHandler(recognizer)).Methods(http.MethodGet)
 
 	// Optionally serve static files (built frontend)
 	if *staticDir != "" {
@@ -84,7 +355,13 @@ func main() {
 	}
 
 	// Compose middlewares: CORS -> Router, then logging wrapper
-	handler := withCORS(r)
+	var exposed []string
+	if *recognizeTimingHeader != "" { exposed = append(exposed, *recognizeTimingHeader) }
+	timeouts, err := parseRouteTimeouts(*requestTimeout, *requestTimeouts)
+	if err != nil { log.Fatalf("request_timeouts: %v", err) }
+	// Streams run for as long as the client stays, and withTimeouts would buffer them
+	timeouts.Routes["/ws"], timeouts.Routes["/api/strokes/stream"] = 0, 0
+	handler := withCORS(withTimeouts(r, timeouts), origins, exposed...)
 	logged := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		start := time.Now()
 		rw := &statusWriter{ResponseWriter: w, status: 200}
@@ -101,10 +378,27 @@ func main() {
 		ReadHeaderTimeout: 5 * time.Second,
 	}
 
-	log.Printf("listening on %s", *addr)
-	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Fatalf("server error: %v", err)
-	}
+	log.Print(startupConfig{Addr: *addr, DBPath: *dbPath, AutoMigrate: *autoMigrate, CookieKey: *cookieKey, StaticDir: *staticDir,
+		Recognizer: serving, RecognizerURL: *recognizerURL, WebhookURL: *webhookURL, CORSOrigins: *corsOrigins,
+		Admins: len(authSvc.AdminEmails), Features: feats.Enabled()}.summary())
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil { log.Fatalf("server error: %v", err) }
+	scheme := "http"
+	if tf.enabled() { scheme = "https" }
+	log.Printf("listening on %s (%s)", *addr, scheme)
+	// Hijacked WebSockets are invisible to srv.Shutdown, so the hub closes them itself and is
+	// waited on before the database goes away
+	srv.RegisterOnShutdown(hub.Shutdown)
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	err = serve(srv, ln, tf, stop, *shutdownTimeout, func(ctx context.Context) {
+		if err := hub.Wait(ctx); err != nil { log.Printf("Warning: websocket handlers still running: %v", err) }
+		if err := jobs.Stop(ctx); err != nil { log.Printf("Warning: background jobs still running: %v", err) }
+		if err := store.Close(); err != nil { log.Printf("Warning: closing database: %v", err) }
+		if rs, ok := sessionStore.(*auth.RedisStore); ok { rs.Close() }
+	})
+	if err != nil { log.Fatalf("server error: %v", err) }
+	log.Printf("shutdown complete")
 }
 
 type statusWriter struct {
@@ -114,6 +408,9 @@ type statusWriter struct {
 
 func (w *statusWriter) WriteHeader(code int) { w.status = code; w.ResponseWriter.WriteHeader(code) }
 
+// Unwrap lets http.ResponseController reach the server's writer for flushing and deadlines
+func (w *statusWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
 // Implement http.Hijacker passthrough so WebSocket upgrades work through the wrapper
 func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	if hj, ok := w.ResponseWriter.(http.Hijacker); ok {
@@ -129,17 +426,27 @@ func getEnv(key, def string) string {
 	return def
 }
 
-func withCORS(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", r.Header.Get("Origin"))
-		w.Header().Set("Vary", "Origin")
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-		w.Header().Set("Access-Control-Allow-Methods", "GET,POST,OPTIONS")
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusNoContent)
-			return
-		}
-		next.ServeHTTP(w, r)
-	})
+func getEnvInt(key string, def int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil { return n }
+		log.Printf("Warning: invalid integer %s=%q, using %d", key, v, def)
+	}
+	return def
 }
+
+func getEnvFloat(key string, def float64) float64 {
+	if v := os.Getenv(key); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil { return f }
+		log.Printf("Warning: invalid number %s=%q, using %v", key, v, def)
+	}
+	return def
+}
+
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil { return d }
+		log.Printf("Warning: invalid duration %s=%q, using %v", key, v, def)
+	}
+	return def
+}
+
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultShutdownTimeout is how long in-flight requests get to finish after SIGINT or SIGTERM
+const DefaultShutdownTimeout = 15 * time.Second
+
+// serve runs srv on ln, over TLS when tf is enabled, until a signal arrives on stop, then shuts down gracefully: the listener
+// closes at once, hooks registered with srv.RegisterOnShutdown run, and in-flight requests get
+// up to timeout to finish. drained (optional) is called with the same deadline afterwards, for
+// cleanup that must wait for the handlers, like saving buffered strokes or closing the database.
+// It returns an error only when srv fails to serve; shutdown problems are logged.
+func serve(srv *http.Server, ln net.Listener, tf tlsFiles, stop <-chan os.Signal, timeout time.Duration, drained func(ctx context.Context)) error {
+	errc := make(chan error, 1)
+	go func() {
+		if tf.enabled() { errc <- srv.ServeTLS(ln, tf.Cert, tf.Key); return }
+		errc <- srv.Serve(ln)
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case sig := <-stop:
+		log.Printf("received %v, shutting down (timeout %v)", sig, timeout)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil { log.Printf("Warning: shutdown: %v", err) }
+	if err := <-errc; err != nil && !errors.Is(err, http.ErrServerClosed) { log.Printf("Warning: serve: %v", err) }
+	if drained != nil { drained(ctx) }
+	return nil
+}
+
+// background runs the periodic jobs (retention purges, cache sweeps, client-count logs) so the
+// shutdown can stop them, and wait for a purge in progress, before the database is closed
+type background struct {
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newBackground() *background { return &background{stop: make(chan struct{})} }
+
+// Go runs job in its own goroutine; job returns once stop is closed
+func (b *background) Go(job func(stop <-chan struct{})) {
+	b.wg.Add(1)
+	go func() { defer b.wg.Done(); job(b.stop) }()
+}
+
+// Stop tells every job to return and waits for them, giving up with ctx.Err() once ctx ends
+func (b *background) Stop(ctx context.Context) error {
+	close(b.stop)
+	done := make(chan struct{})
+	go func() { b.wg.Wait(); close(done) }()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestServe_DrainsInFlightRequestAndRefusesNewConnections(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		_, _ = w.Write([]byte("done"))
+	}))
+	addr := ts.Listener.Addr().String()
+	stop := make(chan os.Signal, 1)
+	drained := make(chan struct{})
+	served := make(chan error, 1)
+	go func() { served <- serve(ts.Config, ts.Listener, tlsFiles{}, stop, 5*time.Second, func(context.Context) { close(drained) }) }()
+
+	type result struct {
+		body string
+		err  error
+	}
+	inFlight := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr)
+		if err != nil { inFlight <- result{err: err}; return }
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		inFlight <- result{string(b), err}
+	}()
+	<-started
+	stop <- syscall.SIGTERM
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		c, err := net.DialTimeout("tcp", addr, 100*time.Millisecond)
+		if err != nil { break }
+		c.Close()
+		if time.Now().After(deadline) {
+			t.Fatal("Expected new connections to be refused after the signal")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case <-drained:
+		t.Fatal("Drain hook ran before the in-flight request finished")
+	default:
+	}
+
+	close(release)
+	res := <-inFlight
+	if res.err != nil || res.body != "done" {
+		t.Fatalf("Expected the in-flight request to finish, got %q (%v)", res.body, res.err)
+	}
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatalf("Expected a clean shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not return after draining")
+	}
+	select {
+	case <-drained:
+	default:
+		t.Fatal("Expected the drain hook to run")
+	}
+}
+
+func TestServe_ReturnsServeError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.Close()
+	if err := serve(&http.Server{}, ln, tlsFiles{}, make(chan os.Signal), time.Second, nil); err == nil {
+		t.Fatal("Expected an error from a closed listener")
+	}
+}
+
+func TestBackground_StopWaitsForJobs(t *testing.T) {
+	jobs := newBackground()
+	finished := make(chan struct{})
+	jobs.Go(func(stop <-chan struct{}) {
+		<-stop
+		time.Sleep(20 * time.Millisecond) // a purge still running when shutdown begins
+		close(finished)
+	})
+	if err := jobs.Stop(context.Background()); err != nil {
+		t.Fatalf("Should not return error: %v", err)
+	}
+	select {
+	case <-finished:
+	default:
+		t.Fatal("Expected Stop to wait for the job to return")
+	}
+
+	stuck, release := newBackground(), make(chan struct{})
+	defer close(release)
+	stuck.Go(func(stop <-chan struct{}) { <-release })
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := stuck.Stop(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Expected the deadline once a job ignores stop, got %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const redacted = "[redacted]"
+
+// defaultCookieKey is the placeholder -cookie ships with; the summary flags it so it is not left in production
+const defaultCookieKey = "change-me-please-32-bytes-min"
+
+// startupConfig is the resolved configuration reported once at boot. Fields holding secrets or
+// URLs are redacted by summary, never by the caller.
+type startupConfig struct {
+	Addr          string
+	DBPath        string
+	AutoMigrate   bool
+	CookieKey     string
+	StaticDir     string
+	Recognizer    string // what is actually serving, e.g. "simple" after a failed ONNX init
+	RecognizerURL string
+	WebhookURL    string
+	CORSOrigins   string
+	Admins        int
+	Features      []string
+}
+
+// summary renders the config as space-separated key="value" pairs so log tooling can parse it
+func (c startupConfig) summary() string {
+	cookie := redacted
+	if c.CookieKey == defaultCookieKey { cookie = "[default, change it]" }
+	fields := []struct{ key, value string }{
+		{"addr", c.Addr},
+		{"db", redactURL(c.DBPath)},
+		{"auto_migrate", fmt.Sprint(c.AutoMigrate)},
+		{"cookie_key", cookie},
+		{"static", c.StaticDir},
+		{"recognizer", c.Recognizer},
+		{"recognizer_url", redactURL(c.RecognizerURL)},
+		{"webhook_url", redactURL(c.WebhookURL)},
+		{"cors_origins", c.CORSOrigins},
+		{"admins", fmt.Sprint(c.Admins)},
+		{"features", strings.Join(c.Features, ",")},
+	}
+	parts := make([]string, len(fields))
+	for i, f := range fields { parts[i] = fmt.Sprintf("%s=%q", f.key, f.value) }
+	return "startup: " + strings.Join(parts, " ")
+}
+
+// secretParams are query parameters whose values never reach the log: the sqlite driver's
+// _auth_* options and the usual names for tokens in webhook or recognizer URLs
+var secretParams = []string{"pass", "pwd", "secret", "token", "key", "auth", "sig"}
+
+// redactURL hides the password in userinfo and the values of secret-looking query parameters,
+// keeping everything else (including parameter order) so the DSN stays recognizable
+func redactURL(raw string) string {
+	base, query, hasQuery := strings.Cut(raw, "?")
+	if scheme, rest, ok := strings.Cut(base, "://"); ok {
+		if at := strings.LastIndex(rest, "@"); at >= 0 {
+			user, _, _ := strings.Cut(rest[:at], ":")
+			base = scheme + "://" + user + ":" + redacted + rest[at:]
+		}
+	}
+	if !hasQuery { return base }
+	params := strings.Split(query, "&")
+	for i, p := range params {
+		name, _, ok := strings.Cut(p, "=")
+		if ok && isSecretParam(name) { params[i] = name + "=" + redacted }
+	}
+	return base + "?" + strings.Join(params, "&")
+}
+
+func isSecretParam(name string) bool {
+	if n, err := url.QueryUnescape(name); err == nil { name = n }
+	name = strings.ToLower(name)
+	for _, s := range secretParams {
+		if strings.Contains(name, s) { return true }
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/deliium/drawing-board/internal/metrics"
+)
+
+var timedOutRequests = metrics.NewCounter("http_request_timeouts_total", "Requests answered 503 because their handler outlived the route's -request_timeouts deadline.")
+
+// routeTimeouts bounds how long a request may run: Default, or the Routes entry with the longest
+// path prefix matching the request (whole segments only, so /api/recognize covers
+// /api/recognize/all but not /api/recognizer). A timeout of 0 leaves the route unbounded.
+type routeTimeouts struct {
+	Default time.Duration
+	Routes  map[string]time.Duration
+}
+
+// parseRouteTimeouts reads a comma-separated "prefix=duration" list such as
+// "/api/recognize=10s,/api/export.png=25s,/ws=0"
+func parseRouteTimeouts(def time.Duration, spec string) (routeTimeouts, error) {
+	rt := routeTimeouts{Default: def, Routes: map[string]time.Duration{}}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" { continue }
+		prefix, d, ok := strings.Cut(item, "=")
+		prefix = strings.TrimSuffix(strings.TrimSpace(prefix), "/")
+		if !ok || !strings.HasPrefix(prefix, "/") { return rt, fmt.Errorf("request timeout %q: want /path=duration", item) }
+		timeout, err := time.ParseDuration(strings.TrimSpace(d))
+		if err != nil || timeout < 0 { return rt, fmt.Errorf("request timeout %q: want a duration of 0 or more", item) }
+		rt.Routes[prefix] = timeout
+	}
+	return rt, nil
+}
+
+// forPath is the timeout of the route serving path
+func (rt routeTimeouts) forPath(path string) time.Duration {
+	best, timeout := -1, rt.Default
+	for prefix, d := range rt.Routes {
+		if len(prefix) > best && (path == prefix || strings.HasPrefix(path, prefix+"/")) { best, timeout = len(prefix), d }
+	}
+	return timeout
+}
+
+// withTimeouts gives every request a context deadline from rt. Handlers see it through
+// r.Context(), so database queries and the remote recognizer give up with it; a handler still
+// running at the deadline is cut off with a 503 JSON error and whatever it writes later is
+// dropped. The response is buffered until the handler returns, which is why streaming routes
+// (WebSockets, NDJSON streams) must have a timeout of 0: they are passed through untouched.
+func withTimeouts(next http.Handler, rt routeTimeouts) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout := rt.forPath(r.URL.Path)
+		if timeout <= 0 { next.ServeHTTP(w, r); return }
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		tw := &timeoutWriter{header: make(http.Header)}
+		done, panicked := make(chan struct{}), make(chan interface{}, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil { panicked <- p }
+			}()
+			next.ServeHTTP(tw, r.WithContext(ctx))
+			close(done)
+		}()
+		select {
+		case p := <-panicked:
+			panic(p)
+		case <-done:
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			for k, v := range tw.header { w.Header()[k] = v }
+			if tw.code == 0 { tw.code = http.StatusOK }
+			w.WriteHeader(tw.code)
+			_, _ = w.Write(tw.buf.Bytes())
+		case <-ctx.Done():
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			tw.timedOut = true
+			// A client that went away gets no answer; only a deadline is reported
+			if ctx.Err() != context.DeadlineExceeded { return }
+			timedOutRequests.Inc()
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("request timed out after %v", timeout)})
+		}
+	})
+}
+
+// timeoutWriter buffers a handler's response until withTimeouts knows whether it beat the deadline
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	buf      bytes.Buffer
+	code     int
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header { return tw.header }
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut { return 0, http.ErrHandlerTimeout }
+	if tw.code == 0 { tw.code = http.StatusOK }
+	return tw.buf.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.code != 0 { return }
+	tw.code = code
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRouteTimeouts(t *testing.T) {
+	rt, err := parseRouteTimeouts(20*time.Second, " /api/recognize=10s, /api/export.png/=25s,/ws=0")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	for path, want := range map[string]time.Duration{
+		"/api/recognize":     10 * time.Second,
+		"/api/recognize/all": 10 * time.Second,
+		"/api/recognizer":    20 * time.Second,
+		"/api/export.png":    25 * time.Second,
+		"/ws":                0,
+		"/api/strokes":       20 * time.Second,
+	} {
+		if got := rt.forPath(path); got != want {
+			t.Fatalf("Expected %v for %s, got %v", want, path, got)
+		}
+	}
+	rt, _ = parseRouteTimeouts(0, "/api=5s,/api/recognize=1s")
+	if got := rt.forPath("/api/recognize/image"); got != time.Second {
+		t.Fatalf("Expected the longest prefix to win, got %v", got)
+	}
+	for _, bad := range []string{"api=5s", "/api", "/api=soon", "/api=-1s"} {
+		if _, err := parseRouteTimeouts(0, bad); err == nil {
+			t.Fatalf("Expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestWithTimeouts_CutsOffSlowHandler(t *testing.T) {
+	rt, _ := parseRouteTimeouts(time.Minute, "/slow=50ms")
+	handlerErr := make(chan error, 1)
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+		handlerErr <- r.Context().Err()
+		w.WriteHeader(http.StatusTeapot)
+		_, _ = w.Write([]byte("too late"))
+	})
+	before, start := timedOutRequests.Value(), time.Now()
+	rec := httptest.NewRecorder()
+	withTimeouts(slow, rt).ServeHTTP(rec, httptest.NewRequest("GET", "/slow", nil))
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected the handler to be cut off at 50ms, took %v", elapsed)
+	}
+	var body map[string]string
+	if rec.Code != http.StatusServiceUnavailable || json.Unmarshal(rec.Body.Bytes(), &body) != nil || !strings.Contains(body["error"], "timed out") {
+		t.Fatalf("Expected 503 with a JSON error, got %d %s", rec.Code, rec.Body.String())
+	}
+	if err := <-handlerErr; err != context.DeadlineExceeded {
+		t.Fatalf("Expected the handler's context to hit its deadline, got %v", err)
+	}
+	if strings.Contains(rec.Body.String(), "too late") || timedOutRequests.Value() != before+1 {
+		t.Fatalf("Expected the late write to be dropped and the timeout counted, got %s", rec.Body.String())
+	}
+}
+
+func TestWithTimeouts_FastHandlerPassesThrough(t *testing.T) {
+	rt, _ := parseRouteTimeouts(time.Second, "")
+	fast := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); !ok {
+			t.Error("Expected the request context to carry a deadline")
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("png"))
+	})
+	rec := httptest.NewRecorder()
+	withTimeouts(fast, rt).ServeHTTP(rec, httptest.NewRequest("GET", "/api/export.png", nil))
+	if rec.Code != http.StatusCreated || rec.Header().Get("Content-Type") != "image/png" || rec.Body.String() != "png" {
+		t.Fatalf("Expected the handler's response unchanged, got %d %v %q", rec.Code, rec.Header(), rec.Body.String())
+	}
+}
+
+func TestWithTimeouts_ExemptRouteIsNotWrapped(t *testing.T) {
+	rt, _ := parseRouteTimeouts(10*time.Millisecond, "/api/strokes/stream=0")
+	stream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); ok {
+			t.Error("Expected no deadline on an exempt route")
+		}
+		if _, ok := w.(http.Flusher); !ok {
+			t.Error("Expected the original, flushable writer")
+		}
+		time.Sleep(30 * time.Millisecond)
+		_, _ = w.Write([]byte("{}\n"))
+	})
+	rec := httptest.NewRecorder()
+	withTimeouts(stream, rt).ServeHTTP(rec, httptest.NewRequest("GET", "/api/strokes/stream", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "{}\n" {
+		t.Fatalf("Expected the stream to outlive the default timeout, got %d %q", rec.Code, rec.Body.String())
+	}
+}
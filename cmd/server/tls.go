@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/deliium/drawing-board/internal/auth"
+	"github.com/deliium/drawing-board/internal/db"
+	"github.com/gorilla/sessions"
+)
+
+// tlsFiles are the -tls_cert and -tls_key PEM files; the server speaks HTTPS only when both are set
+type tlsFiles struct {
+	Cert, Key string
+}
+
+func (tf tlsFiles) enabled() bool { return tf.Cert != "" && tf.Key != "" }
+
+// newSessionStore keeps sessions in signed cookies (backend "cookie") or in the Redis at redisURL
+// (backend "redis"), signing with cookieKey either way. The cookie is Secure when the server speaks
+// HTTPS itself (https) or SameSite=None requires it, so plain-HTTP development still gets a session.
+func newSessionStore(backend, redisURL, cookieKey string, sameSite http.SameSite, https bool) (sessions.Store, error) {
+	opts := sessions.Options{Path: "/", HttpOnly: true, SameSite: sameSite, Secure: https || sameSite == http.SameSiteNoneMode}
+	switch backend {
+	case "", "cookie":
+		sessionStore := sessions.NewCookieStore([]byte(cookieKey))
+		sessionStore.Options = &opts
+		return sessionStore, nil
+	case "redis":
+		sessionStore, err := auth.NewRedisStore(redisURL, []byte(cookieKey))
+		if err != nil { return nil, err }
+		opts.MaxAge = sessionStore.Options.MaxAge
+		sessionStore.Options = &opts
+		return sessionStore, nil
+	}
+	return nil, fmt.Errorf("unknown session backend %q (want cookie or redis)", backend)
+}
+
+func newAuthService(store *db.Store, sessionStore sessions.Store, sameSite http.SameSite, https bool) *auth.Service {
+	return &auth.Service{Store: store, Sessions: sessionStore, SameSite: sameSite, Secure: https}
+}
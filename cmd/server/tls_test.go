@@ -0,0 +1,169 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deliium/drawing-board/internal/db"
+)
+
+// authServer serves register and me with session cookies configured as main does for https
+func authServer(t *testing.T, https bool) (*httptest.Server, *http.Client) {
+	t.Helper()
+	store, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	sessionStore, err := newSessionStore("cookie", "", "test-secret", http.SameSiteLaxMode, https)
+	if err != nil {
+		t.Fatalf("Failed to create session store: %v", err)
+	}
+	svc := newAuthService(store, sessionStore, http.SameSiteLaxMode, https)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/register", svc.Register)
+	mux.HandleFunc("/api/me", svc.Me)
+	var ts *httptest.Server
+	if https { ts = httptest.NewTLSServer(mux) } else { ts = httptest.NewServer(mux) }
+	t.Cleanup(ts.Close)
+	client := ts.Client()
+	client.Jar, _ = cookiejar.New(nil)
+	return ts, client
+}
+
+// register signs up over client and returns the session cookie it was given
+func register(t *testing.T, ts *httptest.Server, client *http.Client) *http.Cookie {
+	t.Helper()
+	resp, err := client.Post(ts.URL+"/api/register", "application/json", strings.NewReader(`{"email":"tls@example.com","password":"password123"}`))
+	if err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Fatalf("Expected 200 from register, got %d", resp.StatusCode)
+	}
+	for _, c := range resp.Cookies() {
+		if c.Name == "sid" { return c }
+	}
+	t.Fatal("Expected a session cookie")
+	return nil
+}
+
+func TestNewAuthService_SessionOverTLS(t *testing.T) {
+	ts, client := authServer(t, true)
+	if c := register(t, ts, client); !c.Secure || !c.HttpOnly || c.SameSite != http.SameSiteLaxMode {
+		t.Fatalf("Expected a Secure HttpOnly Lax cookie over TLS, got %+v", c)
+	}
+	resp, err := client.Get(ts.URL + "/api/me")
+	if err != nil {
+		t.Fatalf("Failed to get /api/me: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != 200 || !strings.Contains(string(body), "tls@example.com") {
+		t.Fatalf("Expected the session to authenticate /api/me, got %d %s", resp.StatusCode, body)
+	}
+}
+
+func TestNewAuthService_PlainHTTPCookieIsNotSecure(t *testing.T) {
+	ts, client := authServer(t, false)
+	if c := register(t, ts, client); c.Secure {
+		t.Fatalf("Expected no Secure attribute over plain HTTP, got %+v", c)
+	}
+}
+
+// writeSelfSigned writes a certificate for 127.0.0.1 and its key as PEM files, returning the pool that trusts it
+func writeSelfSigned(t *testing.T, dir string) (tlsFiles, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "drawing-board test"}, NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour), IPAddresses: []net.IP{net.ParseIP("127.0.0.1")}, KeyUsage: x509.KeyUsageDigitalSignature, ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	tf := tlsFiles{Cert: filepath.Join(dir, "cert.pem"), Key: filepath.Join(dir, "key.pem")}
+	if err := os.WriteFile(tf.Cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(tf.Key, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tf, pool
+}
+
+func TestServe_TLSWhenBothFilesAreSet(t *testing.T) {
+	tf, pool := writeSelfSigned(t, t.TempDir())
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil { w.WriteHeader(http.StatusBadRequest) }
+	})}
+	stop := make(chan os.Signal, 1)
+	served := make(chan error, 1)
+	go func() { served <- serve(srv, ln, tf, stop, time.Second, nil) }()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	resp, err := client.Get("https://" + ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Expected an HTTPS response, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Fatalf("Expected the request to arrive over TLS, got %d", resp.StatusCode)
+	}
+	stop <- os.Interrupt
+	if err := <-served; err != nil {
+		t.Fatalf("Expected a clean shutdown, got %v", err)
+	}
+}
+
+func TestTLSFiles_NeedsBoth(t *testing.T) {
+	for _, tf := range []tlsFiles{{}, {Cert: "cert.pem"}, {Key: "key.pem"}} {
+		if tf.enabled() {
+			t.Fatalf("Expected %+v to serve plain HTTP", tf)
+		}
+	}
+	if !(tlsFiles{Cert: "cert.pem", Key: "key.pem"}).enabled() {
+		t.Fatal("Expected TLS with both files set")
+	}
+}
+
+func TestNewSessionStore_RejectsUnknownBackend(t *testing.T) {
+	if _, err := newSessionStore("memcached", "", "test-secret", http.SameSiteLaxMode, false); err == nil || !strings.Contains(err.Error(), "memcached") {
+		t.Fatalf("Expected an unknown backend to be rejected, got %v", err)
+	}
+	if _, err := newSessionStore("redis", "redis://127.0.0.1:1", "test-secret", http.SameSiteLaxMode, false); err == nil {
+		t.Fatal("Expected an unreachable Redis to fail at startup")
+	}
+}
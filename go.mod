@@ -1,6 +1,6 @@
 module github.com/deliium/drawing-board
 
-go 1.22.0
+go 1.26.0
 
 require (
 	github.com/gorilla/mux v1.8.1
@@ -8,6 +8,7 @@ require (
 	github.com/gorilla/websocket v1.5.3
 	github.com/mattn/go-sqlite3 v1.14.22
 	github.com/yalue/onnxruntime_go v1.4.0
+	golang.org/x/crypto v0.57.0
 )
 
-require github.com/gorilla/securecookie v1.1.2 // indirect
+require github.com/gorilla/securecookie v1.1.2
@@ -1,23 +1,37 @@
 package auth
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/deliium/drawing-board/internal/clock"
 	"github.com/deliium/drawing-board/internal/db"
+	"github.com/deliium/drawing-board/internal/ratelimit"
+	"github.com/deliium/drawing-board/internal/validate"
 	"github.com/gorilla/sessions"
 )
 
 type Service struct {
 	Store    *db.Store
-	Sessions *sessions.CookieStore
+	Sessions sessions.Store // where sessions live: a *sessions.CookieStore keeps them in the cookie, a *RedisStore server-side
+	Clock    clock.Clock   // nil uses the wall clock
+	SessionTTL time.Duration // how long a session lasts from sign-in or its last Ping, see setExpiry; 0 never expires
+	AdminEmails []string // accounts allowed through RequireAdmin, compared case-insensitively
+	RolePolicy RolePolicy // applied by RequireAuth, RequireAdmin and Me when a session's role changed since sign-in
+	SameSite http.SameSite // session cookie mode, see ParseSameSite; 0 uses Lax, and None always adds Secure
+	Secure   bool          // mark the session cookie Secure whatever SameSite is; cmd/server sets it when serving HTTPS
+	MaxEmailLength int // longest email Register accepts, in characters; 0 uses DefaultMaxEmailLength
+	EmailDomains EmailDomains // domains Register refuses or, with Allow set, the only ones it accepts
+	Attempts *ratelimit.Limiter // optional; throttles Login and Register per IP and per email, see NewAttemptLimiter
 }
 
-func NewService(store *db.Store, sessions *sessions.CookieStore) *Service {
+func NewService(store *db.Store, sessions sessions.Store) *Service {
 	return &Service{
 		Store:    store,
 		Sessions: sessions,
@@ -32,13 +46,46 @@ type credentials struct {
 type userView struct {
 	ID    int64  `json:"id"`
 	Email string `json:"email"`
+	Public bool  `json:"public"` // listed in the public gallery
+	DisplayName string `json:"displayName"` // shown to collaborators instead of the email
+	Admin bool `json:"admin"` // listed in AdminEmails
 }
 
 const sessionName = "sid"
 
-func hashPassword(pw string) string {
-	s := sha256.Sum256([]byte(pw))
-	return hex.EncodeToString(s[:])
+// DefaultSessionTTL is the session lifetime cmd/server configures unless told otherwise; it
+// matches the cookie store's default MaxAge
+const DefaultSessionTTL = 30 * 24 * time.Hour
+
+// MinPasswordLength is the shortest password Register accepts
+const MinPasswordLength = 8
+
+func validateRegister(c credentials, maxEmail int) validate.Errors {
+	var errs validate.Errors
+	checkEmail(&errs, c.Email, maxEmail)
+	if c.Password == "" { errs.Add("password", "is required") } else { errs.Check(len(c.Password) >= MinPasswordLength, "password", fmt.Sprintf("must be at least %d characters", MinPasswordLength)) }
+	errs.Check(len(c.Password) <= MaxPasswordLength, "password", fmt.Sprintf("must be at most %d bytes", MaxPasswordLength))
+	return errs
+}
+
+type passwordChange struct {
+	OldPassword string `json:"oldPassword"`
+	NewPassword string `json:"newPassword"`
+}
+
+func validatePasswordChange(c passwordChange) validate.Errors {
+	var errs validate.Errors
+	errs.Check(c.OldPassword != "", "oldPassword", "is required")
+	if c.NewPassword == "" { errs.Add("newPassword", "is required") } else { errs.Check(len(c.NewPassword) >= MinPasswordLength, "newPassword", fmt.Sprintf("must be at least %d characters", MinPasswordLength)) }
+	errs.Check(len(c.NewPassword) <= MaxPasswordLength, "newPassword", fmt.Sprintf("must be at most %d bytes", MaxPasswordLength))
+	return errs
+}
+
+func validateLogin(c credentials) validate.Errors {
+	var errs validate.Errors
+	errs.Check(c.Email != "", "email", "is required")
+	errs.Check(c.Password != "", "password", "is required")
+	return errs
 }
 
 func writeJSON(w http.ResponseWriter, code int, v interface{}) {
@@ -50,68 +97,255 @@ func writeJSON(w http.ResponseWriter, code int, v interface{}) {
 func (s *Service) Register(w http.ResponseWriter, r *http.Request) {
 	var c credentials
 	if err := json.NewDecoder(r.Body).Decode(&c); err != nil { writeJSON(w, 400, map[string]string{"error":"bad json"}); return }
-	c.Email = strings.TrimSpace(strings.ToLower(c.Email))
-	if c.Email == "" || c.Password == "" { writeJSON(w, 400, map[string]string{"error":"missing fields"}); return }
-	if u, _ := s.Store.GetUserByEmail(c.Email); u != nil { writeJSON(w, 409, map[string]string{"error":"email exists"}); return }
-	uid, err := s.Store.CreateUser(c.Email, hashPassword(c.Password))
+	c.Email = normalizeEmail(c.Email)
+	errs := validateRegister(c, s.maxEmailLength())
+	s.checkEmailDomain(&errs, c.Email)
+	if errs.Any() { writeJSON(w, 400, errs.Response()); return }
+	if s.throttled(w, r, c.Email) { return }
+	if u, _ := s.Store.GetUserByEmailContext(r.Context(), c.Email); u != nil { writeJSON(w, 409, map[string]string{"error":"email exists"}); return }
+	hash, err := hashPassword(c.Password)
 	if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
-	s.startSession(w, r, uid)
-	writeJSON(w, 200, userView{ID: uid, Email: c.Email})
+	uid, err := s.Store.CreateUserContext(r.Context(), c.Email, hash)
+	// Two concurrent registrations can both pass the lookup above; the unique index decides
+	if errors.Is(err, db.ErrDuplicate) { writeJSON(w, 409, map[string]string{"error":"email exists"}); return }
+	if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	admin := s.startSession(w, r, uid)
+	writeJSON(w, 200, userView{ID: uid, Email: c.Email, DisplayName: db.DefaultDisplayName(c.Email), Admin: admin})
 }
 
 func (s *Service) Login(w http.ResponseWriter, r *http.Request) {
 	var c credentials
 	if err := json.NewDecoder(r.Body).Decode(&c); err != nil { writeJSON(w, 400, map[string]string{"error":"bad json"}); return }
-	u, err := s.Store.GetUserByEmail(strings.TrimSpace(strings.ToLower(c.Email)))
+	c.Email = normalizeEmail(c.Email)
+	if errs := validateLogin(c); errs.Any() { writeJSON(w, 400, errs.Response()); return }
+	if s.throttled(w, r, c.Email) { return }
+	u, err := s.AuthenticateContext(r.Context(), c.Email, c.Password)
+	if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	if u == nil { writeJSON(w, 401, map[string]string{"error":"invalid credentials"}); return }
+	s.resetAttempts(r, c.Email)
+	admin := s.startSession(w, r, u.ID)
+	writeJSON(w, 200, userView{ID: u.ID, Email: u.Email, Public: u.Public, DisplayName: u.PublicName(), Admin: admin})
+}
+
+// ChangePassword replaces the signed-in user's password once their current one is verified; wrong
+// guesses count against Attempts like failed logins. Every other session of the user is signed out
+// through their session epoch, see Store.SessionEpoch; this one is signed in again under it.
+func (s *Service) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	_, uid, ok := s.session(r)
+	if !ok { writeJSON(w, 401, map[string]string{"error":"unauthorized"}); return }
+	var c passwordChange
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil { writeJSON(w, 400, map[string]string{"error":"bad json"}); return }
+	if errs := validatePasswordChange(c); errs.Any() { writeJSON(w, 400, errs.Response()); return }
+	u, err := s.Store.GetUserByIDContext(r.Context(), uid)
 	if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
-	if u == nil || u.PasswordHash != hashPassword(c.Password) { writeJSON(w, 401, map[string]string{"error":"invalid credentials"}); return }
-	s.startSession(w, r, u.ID)
-	writeJSON(w, 200, userView{ID: u.ID, Email: u.Email})
+	if u == nil { writeJSON(w, 401, map[string]string{"error":"unauthorized"}); return }
+	if s.throttled(w, r, u.Email) { return }
+	if !comparePassword(u.PasswordHash, c.OldPassword) { writeJSON(w, 401, map[string]string{"error":"invalid credentials"}); return }
+	hash, err := hashPassword(c.NewPassword)
+	if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	if err := s.Store.ChangePasswordHashContext(r.Context(), uid, hash); err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	s.resetAttempts(r, u.Email)
+	s.startSession(w, r, uid)
+	writeJSON(w, 200, map[string]string{"ok":"true"})
+}
+
+// DeleteAccount removes the signed-in user and everything they own once their password is
+// verified, then ends the session
+func (s *Service) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	sess, uid, ok := s.session(r)
+	if !ok { writeJSON(w, 401, map[string]string{"error":"unauthorized"}); return }
+	var c struct{ Password string `json:"password"` }
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil { writeJSON(w, 400, map[string]string{"error":"bad json"}); return }
+	var errs validate.Errors
+	if errs.Check(c.Password != "", "password", "is required"); errs.Any() { writeJSON(w, 400, errs.Response()); return }
+	u, err := s.Store.GetUserByIDContext(r.Context(), uid)
+	if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	if u == nil { writeJSON(w, 401, map[string]string{"error":"unauthorized"}); return }
+	if s.throttled(w, r, u.Email) { return }
+	if !comparePassword(u.PasswordHash, c.Password) { writeJSON(w, 401, map[string]string{"error":"invalid credentials"}); return }
+	if err := s.Store.DeleteUserContext(r.Context(), uid); err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	s.resetAttempts(r, u.Email)
+	sess.Options.MaxAge = -1 // delete cookie
+	_ = s.save(sess, r, w)
+	writeJSON(w, 200, map[string]string{"ok":"true"})
+}
+
+// Authenticate returns the user with these credentials, or nil when the email is unknown or the
+// password is wrong. A legacy or weaker hash is replaced once the password has been verified.
+func (s *Service) Authenticate(email, password string) (*db.User, error) {
+	return s.AuthenticateContext(context.Background(), email, password)
+}
+
+func (s *Service) AuthenticateContext(ctx context.Context, email, password string) (*db.User, error) {
+	u, err := s.Store.GetUserByEmailContext(ctx, normalizeEmail(email))
+	if err != nil { return nil, err }
+	// Accounts registered before emails were normalized keep the spelling they signed up with
+	if legacy := strings.TrimSpace(strings.ToLower(email)); u == nil && legacy != normalizeEmail(email) {
+		if u, err = s.Store.GetUserByEmailContext(ctx, legacy); err != nil { return nil, err }
+	}
+	// An unknown email still pays for a comparison, so response time doesn't reveal which accounts exist
+	if u == nil { comparePassword(dummyHash(), password); return nil, nil }
+	if !comparePassword(u.PasswordHash, password) { return nil, nil }
+	if needsRehash(u.PasswordHash) {
+		// A failed upgrade must not block the login; the old hash still works next time
+		if hash, err := hashPassword(password); err != nil {
+			log.Printf("Warning: rehash password for user %d: %v", u.ID, err)
+		} else if err := s.Store.SetPasswordHashContext(ctx, u.ID, hash); err != nil {
+			log.Printf("Warning: rehash password for user %d: %v", u.ID, err)
+		} else {
+			u.PasswordHash = hash
+		}
+	}
+	return u, nil
 }
 
 func (s *Service) Logout(w http.ResponseWriter, r *http.Request) {
 	sess, _ := s.Sessions.Get(r, sessionName)
 	sess.Options.MaxAge = -1 // delete cookie
-	_ = sess.Save(r, w)
+	_ = s.save(sess, r, w)
 	writeJSON(w, 200, map[string]string{"ok":"true"})
 }
 
 func (s *Service) Me(w http.ResponseWriter, r *http.Request) {
-	uid, ok := s.UserIDFromRequest(r)
+	sess, uid, ok := s.session(r)
 	if !ok { writeJSON(w, 401, map[string]string{"error":"unauthorized"}); return }
-	u, err := s.Store.GetUserByID(uid)
+	admin, ok, err := s.syncRole(w, r, sess, uid)
+	if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	if !ok { writeJSON(w, 401, map[string]string{"error":"role changed, sign in again"}); return }
+	u, err := s.Store.GetUserByIDContext(r.Context(), uid)
 	if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
 	if u == nil { writeJSON(w, 401, map[string]string{"error":"unauthorized"}); return }
-	writeJSON(w, 200, userView{ID: u.ID, Email: u.Email})
+	writeJSON(w, 200, userView{ID: u.ID, Email: u.Email, Public: u.Public, DisplayName: u.PublicName(), Admin: admin})
 }
 
 func (s *Service) UserIDFromRequest(r *http.Request) (int64, bool) {
+	_, uid, ok := s.session(r)
+	return uid, ok
+}
+
+// session returns the caller's unexpired session and its user, as long as the user's password
+// has not changed since the session signed in
+func (s *Service) session(r *http.Request) (*sessions.Session, int64, bool) {
+	sess, uid, ok := s.cookieSession(r)
+	if !ok { return nil, 0, false }
+	// Sessions from before epochs were recorded count as epoch 0, the value of an unchanged password
+	recorded, _ := sess.Values["epoch"].(int64)
+	epoch, err := s.Store.SessionEpochContext(r.Context(), uid)
+	if err != nil { log.Printf("Warning: session epoch of user %d: %v", uid, err); return nil, 0, false }
+	if epoch != recorded { return nil, 0, false }
+	return sess, uid, true
+}
+
+// cookieSession is session without the epoch check, from the cookie alone
+func (s *Service) cookieSession(r *http.Request) (*sessions.Session, int64, bool) {
 	sess, err := s.Sessions.Get(r, sessionName)
-	if err != nil { return 0, false }
+	if err != nil { return nil, 0, false }
+	if s.sessionExpired(sess) { return nil, 0, false }
 	v, ok := sess.Values["user_id"].(int64)
-	if ok { return v, true }
-	if f, ok := sess.Values["user_id"].(float64); ok { return int64(f), true }
-	return 0, false
+	if ok { return sess, v, true }
+	if f, ok := sess.Values["user_id"].(float64); ok { return sess, int64(f), true }
+	return nil, 0, false
+}
+
+// sessionExpired reports whether sess is past the expires_at it was given, however long the
+// browser kept the cookie. Sessions from before expires_at was recorded fall back to their issue
+// time plus SessionTTL, and without an issue time are treated as expired once a TTL is set.
+func (s *Service) sessionExpired(sess *sessions.Session) bool {
+	now := clock.Or(s.Clock).Now()
+	if expires, ok := sess.Values["expires_at"].(int64); ok { return !now.Before(time.Unix(expires, 0)) }
+	if s.SessionTTL <= 0 { return false }
+	issued, ok := sess.Values["issued_at"].(int64)
+	if !ok { return true }
+	return now.Sub(time.Unix(issued, 0)) > s.SessionTTL
+}
+
+// setExpiry starts a SessionTTL window at now: as an absolute expires_at in the session, which
+// sessionExpired enforces, and as the cookie's MaxAge so the browser drops it at the same time.
+// With no TTL the session never expires and the cookie store's MaxAge applies.
+func (s *Service) setExpiry(sess *sessions.Session, now time.Time) {
+	if s.SessionTTL <= 0 { delete(sess.Values, "expires_at"); return }
+	sess.Values["expires_at"] = now.Add(s.SessionTTL).Unix()
+	sess.Options.MaxAge = int(s.SessionTTL / time.Second)
+}
+
+// Ping answers 204 to a signed-in caller and restarts their session's SessionTTL, 401 otherwise.
+// It reads only the cookie, so clients can call it often to keep a session alive; role and
+// password changes are picked up by the next RequireAuth request instead.
+func (s *Service) Ping(w http.ResponseWriter, r *http.Request) {
+	sess, _, ok := s.cookieSession(r)
+	if !ok { writeJSON(w, 401, map[string]string{"error":"unauthorized"}); return }
+	s.setExpiry(sess, clock.Or(s.Clock).Now())
+	if err := s.save(sess, r, w); err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	w.WriteHeader(http.StatusNoContent)
 }
 
 func (s *Service) RequireAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if _, ok := s.UserIDFromRequest(r); !ok { writeJSON(w, 401, map[string]string{"error":"unauthorized"}); return }
+		sess, uid, ok := s.session(r)
+		if !ok { writeJSON(w, 401, map[string]string{"error":"unauthorized"}); return }
+		_, ok, err := s.syncRole(w, r, sess, uid)
+		if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+		if !ok { writeJSON(w, 401, map[string]string{"error":"role changed, sign in again"}); return }
+		next.ServeHTTP(w, r)
+	})
+}
+
+// IsAdmin reports whether uid belongs to one of AdminEmails
+func (s *Service) IsAdmin(uid int64) (bool, error) { return s.IsAdminContext(context.Background(), uid) }
+
+func (s *Service) IsAdminContext(ctx context.Context, uid int64) (bool, error) {
+	if len(s.AdminEmails) == 0 { return false, nil }
+	u, err := s.Store.GetUserByIDContext(ctx, uid)
+	if err != nil || u == nil { return false, err }
+	for _, e := range s.AdminEmails {
+		if strings.EqualFold(strings.TrimSpace(e), u.Email) { return true, nil }
+	}
+	return false, nil
+}
+
+// RequireAdmin is RequireAuth plus a 403 for signed-in users who are not admins
+func (s *Service) RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sess, uid, ok := s.session(r)
+		if !ok { writeJSON(w, 401, map[string]string{"error":"unauthorized"}); return }
+		admin, ok, err := s.syncRole(w, r, sess, uid)
+		if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+		if !ok { writeJSON(w, 401, map[string]string{"error":"role changed, sign in again"}); return }
+		if !admin { writeJSON(w, 403, map[string]string{"error":"forbidden"}); return }
 		next.ServeHTTP(w, r)
 	})
 }
 
-func (s *Service) startSession(w http.ResponseWriter, r *http.Request, userID int64) {
+// sessionRenewer is a store whose sessions have server-side IDs to replace at sign-in, like RedisStore
+type sessionRenewer interface {
+	Renew(session *sessions.Session) error
+}
+
+// startSession signs userID in, recording their current role for syncRole, and reports whether they are an admin
+func (s *Service) startSession(w http.ResponseWriter, r *http.Request, userID int64) bool {
 	sess, _ := s.Sessions.Get(r, sessionName)
+	// Nothing from before sign-in carries over, the session ID included where the store keeps one
+	if rs, ok := s.Sessions.(sessionRenewer); ok {
+		if err := rs.Renew(sess); err != nil { log.Printf("Warning: drop session before sign-in: %v", err) }
+	} else {
+		sess.Values = map[interface{}]interface{}{}
+	}
+	now := clock.Or(s.Clock).Now()
 	sess.Values["user_id"] = userID
-	sess.Options.Path = "/"
-	sess.Options.HttpOnly = true
-	sess.Options.SameSite = http.SameSiteLaxMode
-	_ = sess.Save(r, w)
+	sess.Values["issued_at"] = now.Unix()
+	s.setExpiry(sess, now)
+	// Left unrecorded on failure the session counts as epoch 0, so it only ends sooner
+	if epoch, err := s.Store.SessionEpochContext(r.Context(), userID); err != nil { log.Printf("Warning: session epoch of user %d: %v", userID, err) } else { sess.Values["epoch"] = epoch }
+	// A failed lookup leaves the role unrecorded; the next request adopts whatever it is then
+	admin, err := s.IsAdminContext(r.Context(), userID)
+	if err != nil { log.Printf("Warning: role of user %d: %v", userID, err) } else { sess.Values["role"] = roleName(admin) }
+	_ = s.save(sess, r, w)
+	return admin
 }
 
-func IsUniqueConstraint(err error) bool {
-	return err != nil && strings.Contains(err.Error(), "UNIQUE")
-}
+// IsUniqueConstraint reports whether err is a duplicate-key failure from the store.
+//
+// Deprecated: use errors.Is(err, db.ErrDuplicate).
+func IsUniqueConstraint(err error) bool { return errors.Is(err, db.ErrDuplicate) }
 
 var ErrUnauthorized = errors.New("unauthorized")
@@ -1,9 +1,18 @@
 package auth
 
 import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/deliium/drawing-board/internal/clock"
 	"github.com/deliium/drawing-board/internal/db"
+	"github.com/deliium/drawing-board/internal/validate"
 	"github.com/gorilla/sessions"
 )
 
@@ -38,4 +47,435 @@ func TestService_Structure(t *testing.T) {
 	if service.Sessions == nil {
 		t.Fatal("Sessions should not be nil")
 	}
-}
\ No newline at end of file
+}
+func TestRegister_ReportsAllValidationErrors(t *testing.T) {
+	service := NewService(&db.Store{}, sessions.NewCookieStore([]byte("test-secret")))
+
+	req := httptest.NewRequest("POST", "/api/register", strings.NewReader(`{"email":"not-an-email","password":"short"}`))
+	rec := httptest.NewRecorder()
+	service.Register(rec, req)
+
+	if rec.Code != 400 {
+		t.Fatalf("Expected 400, got %d", rec.Code)
+	}
+	var body struct {
+		Errors []validate.FieldError `json:"errors"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(body.Errors) != 2 {
+		t.Fatalf("Expected 2 errors, got %v", body.Errors)
+	}
+	if body.Errors[0].Field != "email" || body.Errors[1].Field != "password" {
+		t.Fatalf("Expected email and password errors, got %v", body.Errors)
+	}
+}
+
+func TestLogin_ReportsMissingFields(t *testing.T) {
+	service := NewService(&db.Store{}, sessions.NewCookieStore([]byte("test-secret")))
+
+	rec := httptest.NewRecorder()
+	service.Login(rec, httptest.NewRequest("POST", "/api/login", strings.NewReader(`{}`)))
+
+	if rec.Code != 400 {
+		t.Fatalf("Expected 400, got %d", rec.Code)
+	}
+	var body struct {
+		Errors []validate.FieldError `json:"errors"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(body.Errors) != 2 {
+		t.Fatalf("Expected 2 errors, got %v", body.Errors)
+	}
+}
+
+func TestUserIDFromRequest_SessionExpiry(t *testing.T) {
+	store, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer store.SQL.Close()
+
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	service := NewService(store, sessions.NewCookieStore([]byte("test-secret")))
+	service.Clock = fake
+	service.SessionTTL = time.Hour
+
+	rec := httptest.NewRecorder()
+	service.Register(rec, httptest.NewRequest("POST", "/api/register", strings.NewReader(`{"email":"ttl@example.com","password":"password123"}`)))
+	if rec.Code != 200 {
+		t.Fatalf("Failed to register: %d %s", rec.Code, rec.Body.String())
+	}
+	authed := func() bool {
+		req := httptest.NewRequest("GET", "/api/me", nil)
+		for _, c := range rec.Result().Cookies() {
+			req.AddCookie(c)
+		}
+		_, ok := service.UserIDFromRequest(req)
+		return ok
+	}
+
+	if !authed() {
+		t.Fatal("Fresh session should authenticate")
+	}
+	fake.Advance(59 * time.Minute)
+	if !authed() {
+		t.Fatal("Session within TTL should authenticate")
+	}
+	fake.Advance(2 * time.Minute)
+	if authed() {
+		t.Fatal("Session past TTL should be rejected")
+	}
+}
+
+func TestStartSession_RecordsExpiryAndMaxAge(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	store, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer store.SQL.Close()
+	cookies := sessions.NewCookieStore([]byte("test-secret"))
+	service := NewService(store, cookies)
+	service.Clock = fake
+	service.SessionTTL = 2 * time.Hour
+	login := httptest.NewRecorder()
+	service.startSession(login, httptest.NewRequest("POST", "/api/login", nil), 1)
+	authed := func(cs []*http.Cookie) bool {
+		req := httptest.NewRequest("GET", "/api/me", nil)
+		for _, c := range cs {
+			req.AddCookie(c)
+		}
+		_, ok := service.UserIDFromRequest(req)
+		return ok
+	}
+
+	issued := login.Result().Cookies()
+	if len(issued) != 1 || issued[0].MaxAge != 7200 {
+		t.Fatalf("Expected one cookie with MaxAge 7200, got %+v", issued)
+	}
+	if !authed(issued) {
+		t.Fatal("Fresh session should authenticate")
+	}
+
+	// A cookie the browser kept past its MaxAge is refused on its recorded expiry alone, even
+	// once the server no longer configures a TTL
+	fake.Advance(2 * time.Hour)
+	service.SessionTTL = 0
+	if authed(issued) {
+		t.Fatal("Session past its expires_at should be rejected")
+	}
+
+	// As is one whose recorded expiry was set in the past
+	req := httptest.NewRequest("GET", "/", nil)
+	sess, _ := cookies.Get(req, sessionName)
+	sess.Values["user_id"] = int64(1)
+	sess.Values["issued_at"] = fake.Now().Unix()
+	sess.Values["expires_at"] = fake.Now().Add(-time.Second).Unix()
+	aged := httptest.NewRecorder()
+	if err := sess.Save(req, aged); err != nil {
+		t.Fatalf("Failed to save session: %v", err)
+	}
+	if authed(aged.Result().Cookies()) {
+		t.Fatal("Artificially aged session should be rejected")
+	}
+}
+
+func TestPing_RenewsSessionWithoutTheDatabase(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	store, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer store.SQL.Close()
+	service := NewService(store, sessions.NewCookieStore([]byte("test-secret")))
+	service.Clock = fake
+	service.SessionTTL = time.Hour
+	login := httptest.NewRecorder()
+	service.startSession(login, httptest.NewRequest("POST", "/api/login", nil), 1)
+	// A zero store fails any query, so a 204 shows Ping never reached the database
+	service.Store = &db.Store{}
+	ping := func(cookies []*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/ping", nil)
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		service.Ping(rec, req)
+		return rec
+	}
+
+	if rec := ping(nil); rec.Code != 401 {
+		t.Fatalf("Expected 401 without a session, got %d", rec.Code)
+	}
+	fake.Advance(50 * time.Minute)
+	renewed := ping(login.Result().Cookies())
+	if renewed.Code != 204 || renewed.Body.Len() != 0 {
+		t.Fatalf("Expected 204 with no body, got %d %q", renewed.Code, renewed.Body.String())
+	}
+	if len(renewed.Result().Cookies()) == 0 {
+		t.Fatal("Expected the renewed session cookie")
+	}
+	fake.Advance(50 * time.Minute)
+	if rec := ping(login.Result().Cookies()); rec.Code != 401 {
+		t.Fatalf("Expected the original cookie to expire an hour after sign-in, got %d", rec.Code)
+	}
+	if rec := ping(renewed.Result().Cookies()); rec.Code != 204 {
+		t.Fatalf("Expected the renewed cookie to last an hour from the ping, got %d", rec.Code)
+	}
+}
+
+func TestIsUniqueConstraint_UsesTypedError(t *testing.T) {
+	store, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer store.SQL.Close()
+	if _, err := store.CreateUser("same@example.com", "hash"); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	_, err = store.CreateUser("same@example.com", "hash")
+	if !IsUniqueConstraint(err) {
+		t.Fatalf("Expected duplicate email to be a unique constraint error, got %v", err)
+	}
+	if IsUniqueConstraint(errors.New("UNIQUE")) {
+		t.Fatal("A message mentioning UNIQUE should not count")
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	store, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer store.SQL.Close()
+	service := NewService(store, sessions.NewCookieStore([]byte("test-secret")))
+	id, err := store.CreateUser("auth@example.com", mustHash(t, "password123"))
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	if u, err := service.Authenticate(" Auth@Example.com ", "password123"); err != nil || u == nil || u.ID != id {
+		t.Fatalf("Expected user %d, got %v, %v", id, u, err)
+	}
+	if u, err := service.Authenticate("auth@example.com", "wrong"); err != nil || u != nil {
+		t.Fatalf("Expected nil user for a wrong password, got %v, %v", u, err)
+	}
+	if u, err := service.Authenticate("nobody@example.com", "password123"); err != nil || u != nil {
+		t.Fatalf("Expected nil user for an unknown email, got %v, %v", u, err)
+	}
+}
+
+// changePassword registers email with password123 and posts body to ChangePassword from its session
+func changePassword(t *testing.T, service *Service, email, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	reg := httptest.NewRecorder()
+	service.Register(reg, httptest.NewRequest("POST", "/api/register", strings.NewReader(`{"email":"`+email+`","password":"password123"}`)))
+	if reg.Code != 200 {
+		t.Fatalf("Failed to register: %d", reg.Code)
+	}
+	req := httptest.NewRequest("POST", "/api/password", strings.NewReader(body))
+	for _, c := range reg.Result().Cookies() { req.AddCookie(c) }
+	rec := httptest.NewRecorder()
+	service.ChangePassword(rec, req)
+	return rec
+}
+
+func TestChangePassword_ReplacesThePassword(t *testing.T) {
+	service, _ := newEmailService(t)
+	rec := changePassword(t, service, "mover@example.com", `{"oldPassword":"password123","newPassword":"new-password"}`)
+	if rec.Code != 200 {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := login(service, "198.51.100.1", "mover@example.com", "password123"); rec.Code != 401 {
+		t.Fatalf("Expected the old password to stop working, got %d", rec.Code)
+	}
+	if rec := login(service, "198.51.100.1", "mover@example.com", "new-password"); rec.Code != 200 {
+		t.Fatalf("Expected the new password to sign in, got %d", rec.Code)
+	}
+}
+
+func TestChangePassword_SignsOutOtherSessions(t *testing.T) {
+	service, _ := newEmailService(t)
+	if code, _ := registerEmail(t, service, "shared@example.com"); code != 200 {
+		t.Fatalf("Failed to register: %d", code)
+	}
+	laptop := login(service, "198.51.100.1", "shared@example.com", "password123")
+	phone := login(service, "198.51.100.2", "shared@example.com", "password123")
+	signedIn := func(cookies []*http.Cookie) bool {
+		req := httptest.NewRequest("GET", "/api/me", nil)
+		for _, c := range cookies { req.AddCookie(c) }
+		_, ok := service.UserIDFromRequest(req)
+		return ok
+	}
+	if !signedIn(laptop.Result().Cookies()) || !signedIn(phone.Result().Cookies()) {
+		t.Fatal("Expected both sessions to be signed in")
+	}
+
+	req := httptest.NewRequest("POST", "/api/password", strings.NewReader(`{"oldPassword":"password123","newPassword":"new-password"}`))
+	for _, c := range laptop.Result().Cookies() { req.AddCookie(c) }
+	rec := httptest.NewRecorder()
+	service.ChangePassword(rec, req)
+	if rec.Code != 200 {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if signedIn(phone.Result().Cookies()) {
+		t.Fatal("Expected the other session to be signed out by the password change")
+	}
+	if signedIn(laptop.Result().Cookies()) {
+		t.Fatal("Expected the changing session's old cookie to be signed out too")
+	}
+	if !signedIn(rec.Result().Cookies()) {
+		t.Fatal("Expected the session that changed the password to stay signed in with its new cookie")
+	}
+	if again := login(service, "198.51.100.2", "shared@example.com", "new-password"); !signedIn(again.Result().Cookies()) {
+		t.Fatal("Expected signing in again to work")
+	}
+}
+
+func TestChangePassword_WrongOldPasswordGets401(t *testing.T) {
+	service, _ := newEmailService(t)
+	rec := changePassword(t, service, "guesser@example.com", `{"oldPassword":"not-it-at-all","newPassword":"new-password"}`)
+	if rec.Code != 401 {
+		t.Fatalf("Expected 401, got %d", rec.Code)
+	}
+	if rec := login(service, "198.51.100.1", "guesser@example.com", "password123"); rec.Code != 200 {
+		t.Fatalf("Expected the password to be unchanged, got %d", rec.Code)
+	}
+}
+
+func TestChangePassword_WeakNewPasswordGets400(t *testing.T) {
+	service, _ := newEmailService(t)
+	rec := changePassword(t, service, "weak@example.com", `{"oldPassword":"password123","newPassword":"short"}`)
+	if rec.Code != 400 {
+		t.Fatalf("Expected 400, got %d", rec.Code)
+	}
+	var resp struct {
+		Errors []validate.FieldError `json:"errors"`
+	}
+	_ = json.NewDecoder(rec.Body).Decode(&resp)
+	if len(resp.Errors) != 1 || resp.Errors[0].Field != "newPassword" {
+		t.Fatalf("Expected a newPassword error, got %+v", resp.Errors)
+	}
+}
+
+func TestChangePassword_RequiresASession(t *testing.T) {
+	service, _ := newEmailService(t)
+	rec := httptest.NewRecorder()
+	service.ChangePassword(rec, httptest.NewRequest("POST", "/api/password", strings.NewReader(`{"oldPassword":"password123","newPassword":"new-password"}`)))
+	if rec.Code != 401 {
+		t.Fatalf("Expected 401, got %d", rec.Code)
+	}
+}
+
+func TestDeleteAccount_RemovesTheUserAndEndsTheSession(t *testing.T) {
+	service, store := newEmailService(t)
+	reg := httptest.NewRecorder()
+	service.Register(reg, httptest.NewRequest("POST", "/api/register", strings.NewReader(`{"email":"leaver@example.com","password":"password123"}`)))
+	if reg.Code != 200 {
+		t.Fatalf("Failed to register: %d", reg.Code)
+	}
+	deleteAccount := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/account/delete", strings.NewReader(body))
+		for _, c := range reg.Result().Cookies() { req.AddCookie(c) }
+		rec := httptest.NewRecorder()
+		service.DeleteAccount(rec, req)
+		return rec
+	}
+
+	if rec := deleteAccount(`{"password":"not-it-at-all"}`); rec.Code != 401 {
+		t.Fatalf("Expected 401 for a wrong password, got %d", rec.Code)
+	}
+	rec := deleteAccount(`{"password":"password123"}`)
+	if rec.Code != 200 {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if u, _ := store.GetUserByEmail("leaver@example.com"); u != nil {
+		t.Fatal("Expected the user to be deleted")
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != sessionName || cookies[0].MaxAge >= 0 {
+		t.Fatalf("Expected the session cookie to be cleared, got %v", cookies)
+	}
+}
+
+// memStore is a sessions.Store that keeps values in memory under an unsigned ID cookie, so the
+// tests below exercise Service without a CookieStore anywhere
+type memStore struct {
+	data  map[string]map[interface{}]interface{}
+	saved int
+}
+
+func (m *memStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(m, name)
+}
+
+func (m *memStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	sess := sessions.NewSession(m, name)
+	sess.Options = &sessions.Options{Path: "/"}
+	sess.IsNew = true
+	if c, err := r.Cookie(name); err == nil {
+		if values, ok := m.data[c.Value]; ok {
+			sess.ID, sess.IsNew = c.Value, false
+			for k, v := range values { sess.Values[k] = v }
+		}
+	}
+	return sess, nil
+}
+
+func (m *memStore) Save(r *http.Request, w http.ResponseWriter, sess *sessions.Session) error {
+	m.saved++
+	if sess.Options.MaxAge < 0 {
+		delete(m.data, sess.ID)
+		http.SetCookie(w, sessions.NewCookie(sess.Name(), "", sess.Options))
+		return nil
+	}
+	if sess.ID == "" { sess.ID = "mem-" + strings.Repeat("x", m.saved) }
+	values := map[interface{}]interface{}{}
+	for k, v := range sess.Values { values[k] = v }
+	m.data[sess.ID] = values
+	http.SetCookie(w, sessions.NewCookie(sess.Name(), sess.ID, sess.Options))
+	return nil
+}
+
+func TestService_WorksWithAnySessionStore(t *testing.T) {
+	dbStore, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { dbStore.Close() })
+	mem := &memStore{data: map[string]map[interface{}]interface{}{}}
+	service := NewService(dbStore, mem)
+	service.SessionTTL = time.Hour
+
+	reg := httptest.NewRecorder()
+	service.Register(reg, httptest.NewRequest("POST", "/api/register", strings.NewReader(`{"email":"mem@example.com","password":"password123"}`)))
+	if reg.Code != 200 || len(mem.data) != 1 {
+		t.Fatalf("Expected register to start a session in the store, got %d with %d sessions", reg.Code, len(mem.data))
+	}
+	withSession := func(method, target string) *http.Request {
+		req := httptest.NewRequest(method, target, nil)
+		for _, c := range reg.Result().Cookies() { req.AddCookie(c) }
+		return req
+	}
+	uid, ok := service.UserIDFromRequest(withSession("GET", "/api/me"))
+	if !ok || uid == 0 {
+		t.Fatalf("Expected UserIDFromRequest to find the session, got %d %v", uid, ok)
+	}
+	me := httptest.NewRecorder()
+	service.Me(me, withSession("GET", "/api/me"))
+	if me.Code != 200 || !strings.Contains(me.Body.String(), "mem@example.com") {
+		t.Fatalf("Expected /api/me to authenticate, got %d %s", me.Code, me.Body.String())
+	}
+
+	service.Logout(httptest.NewRecorder(), withSession("POST", "/api/logout"))
+	if len(mem.data) != 0 {
+		t.Fatalf("Expected logout to delete the stored session, %d left", len(mem.data))
+	}
+	if _, ok := service.UserIDFromRequest(withSession("GET", "/api/me")); ok {
+		t.Fatal("Expected the old cookie to be refused after logout")
+	}
+}
@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/sessions"
+)
+
+// ParseSameSite maps "lax" (or ""), "strict" and "none" to the SameSite mode of session cookies.
+//
+// "none" is for embedding the frontend in another site, where Lax cookies are never sent. It
+// hands the browser's cross-site protection to the server: the session then rides along on
+// requests any page starts, so the cookie is always Secure (browsers drop SameSite=None without
+// it, and it must not travel over plain HTTP) and cmd/server refuses cross-site writes from
+// origins outside -cors_origins.
+func ParseSameSite(s string) (http.SameSite, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "lax": return http.SameSiteLaxMode, nil
+	case "strict": return http.SameSiteStrictMode, nil
+	case "none": return http.SameSiteNoneMode, nil
+	}
+	return http.SameSiteLaxMode, fmt.Errorf("unknown SameSite mode %q (want lax, strict or none)", s)
+}
+
+// save writes sess with the attributes every session cookie carries, including the ones that
+// delete it: a browser only accepts a SameSite=None cookie, or its removal, when it is Secure
+func (s *Service) save(sess *sessions.Session, r *http.Request, w http.ResponseWriter) error {
+	sess.Options.Path = "/"
+	sess.Options.HttpOnly = true
+	sess.Options.SameSite = s.SameSite
+	if s.SameSite == 0 || s.SameSite == http.SameSiteDefaultMode { sess.Options.SameSite = http.SameSiteLaxMode }
+	if s.Secure || sess.Options.SameSite == http.SameSiteNoneMode { sess.Options.Secure = true }
+	return sess.Save(r, w)
+}
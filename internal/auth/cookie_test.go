@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/deliium/drawing-board/internal/db"
+	"github.com/gorilla/sessions"
+)
+
+func TestParseSameSite(t *testing.T) {
+	for in, want := range map[string]http.SameSite{"": http.SameSiteLaxMode, "lax": http.SameSiteLaxMode, "Strict": http.SameSiteStrictMode, " none ": http.SameSiteNoneMode} {
+		if got, err := ParseSameSite(in); err != nil || got != want {
+			t.Fatalf("ParseSameSite(%q): expected %v, got %v (%v)", in, want, got, err)
+		}
+	}
+	if _, err := ParseSameSite("sometimes"); err == nil {
+		t.Fatal("Expected an unknown mode to be rejected")
+	}
+}
+
+// sessionCookie registers a user under sameSite, with the store's cookies not Secure by default
+// as cmd/server configures them, and returns the session cookie it was given
+func sessionCookie(t *testing.T, sameSite http.SameSite) (*Service, *http.Cookie) {
+	t.Helper()
+	store, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { store.SQL.Close() })
+	cookies := sessions.NewCookieStore([]byte("test-secret"))
+	cookies.Options.Secure = false
+	service := NewService(store, cookies)
+	service.SameSite = sameSite
+	rec := httptest.NewRecorder()
+	service.Register(rec, httptest.NewRequest("POST", "/api/register", strings.NewReader(`{"email":"cookie@example.com","password":"password123"}`)))
+	if rec.Code != 200 {
+		t.Fatalf("Failed to register: %d %s", rec.Code, rec.Body.String())
+	}
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == sessionName { return service, c }
+	}
+	t.Fatal("Expected a session cookie")
+	return nil, nil
+}
+
+func TestSessionCookie_SameSiteNoneIsSecure(t *testing.T) {
+	service, c := sessionCookie(t, http.SameSiteNoneMode)
+	if c.SameSite != http.SameSiteNoneMode || !c.Secure || !c.HttpOnly {
+		t.Fatalf("Expected SameSite=None; Secure; HttpOnly, got %+v", c)
+	}
+
+	// The cookie that signs out must match, or the browser ignores it on a cross-site response
+	req := httptest.NewRequest("POST", "/api/logout", nil)
+	req.AddCookie(c)
+	rec := httptest.NewRecorder()
+	service.Logout(rec, req)
+	cleared := rec.Result().Cookies()
+	if len(cleared) != 1 || cleared[0].MaxAge >= 0 || cleared[0].SameSite != http.SameSiteNoneMode || !cleared[0].Secure {
+		t.Fatalf("Expected a Secure SameSite=None deletion, got %+v", cleared)
+	}
+}
+
+func TestSessionCookie_DefaultsToLax(t *testing.T) {
+	_, c := sessionCookie(t, 0)
+	if c.SameSite != http.SameSiteLaxMode || c.Secure {
+		t.Fatalf("Expected SameSite=Lax without Secure, got %+v", c)
+	}
+}
+
+func TestSessionCookie_SecureServiceMarksLaxCookieSecure(t *testing.T) {
+	service, c := sessionCookie(t, 0)
+	service.Secure = true
+	req := httptest.NewRequest("POST", "/api/logout", nil)
+	req.AddCookie(c)
+	rec := httptest.NewRecorder()
+	service.Logout(rec, req)
+	cleared := rec.Result().Cookies()
+	if len(cleared) != 1 || !cleared[0].Secure || cleared[0].SameSite != http.SameSiteLaxMode {
+		t.Fatalf("Expected a Secure Lax cookie from a Secure service, got %+v", cleared)
+	}
+}
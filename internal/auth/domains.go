@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/deliium/drawing-board/internal/validate"
+)
+
+// EmailDomains restricts which email domains Register accepts. A listed domain also covers its
+// subdomains. When Allow is non-empty only its domains may register; Deny wins over Allow, and
+// the zero value accepts every domain.
+type EmailDomains struct {
+	Deny  []string
+	Allow []string
+}
+
+// ParseDomainList reads a comma- or newline-separated list of domains, the format of
+// EMAIL_DOMAINS_DENY and its file. Blank lines and lines starting with '#' are skipped, and a
+// leading "@" or "." is dropped, so "@mailinator.com" and ".mailinator.com" are mailinator.com.
+func ParseDomainList(spec string) ([]string, error) {
+	var out []string
+	for _, line := range strings.FieldsFunc(spec, func(r rune) bool { return r == '\n' || r == '\r' }) {
+		if line = strings.TrimSpace(line); line == "" || strings.HasPrefix(line, "#") { continue }
+		for _, item := range strings.Split(line, ",") {
+			item = strings.TrimLeft(normalizeEmail(item), "@.")
+			if item == "" { continue }
+			if strings.ContainsAny(item, "@ ") || !strings.Contains(item, ".") { return nil, fmt.Errorf("email domain %q: want a domain like example.com", item) }
+			out = append(out, item)
+		}
+	}
+	return out, nil
+}
+
+// LoadDomainList reads ParseDomainList's format from a file
+func LoadDomainList(path string) ([]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil { return nil, err }
+	return ParseDomainList(string(b))
+}
+
+// Permits reports whether a normalized email may register
+func (d EmailDomains) Permits(email string) bool {
+	domain := email[strings.LastIndexByte(email, '@')+1:]
+	if matchesDomain(domain, d.Deny) { return false }
+	return len(d.Allow) == 0 || matchesDomain(domain, d.Allow)
+}
+
+// matchesDomain reports whether domain is one of list or a subdomain of one
+func matchesDomain(domain string, list []string) bool {
+	for _, l := range list {
+		if domain == l || strings.HasSuffix(domain, "."+l) { return true }
+	}
+	return false
+}
+
+// checkEmailDomain adds an error for a well-formed email whose domain s.EmailDomains refuses;
+// malformed ones already have checkEmail's error
+func (s *Service) checkEmailDomain(errs *validate.Errors, email string) {
+	if !validate.IsEmail(email) { return }
+	errs.Check(s.EmailDomains.Permits(email), "email", "uses a domain that cannot register")
+}
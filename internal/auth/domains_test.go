@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseDomainList(t *testing.T) {
+	got, err := ParseDomainList("Mailinator.com, @guerrillamail.com\n# disposable\n\n.temp-mail.org\r\n")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	if want := []string{"mailinator.com", "guerrillamail.com", "temp-mail.org"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for _, bad := range []string{"user@mailinator.com", "localhost", "two words.com"} {
+		if _, err := ParseDomainList(bad); err == nil {
+			t.Fatalf("Expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestLoadDomainList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "domains.txt")
+	if err := os.WriteFile(path, []byte("# blocked\nmailinator.com\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write list: %v", err)
+	}
+	if got, err := LoadDomainList(path); err != nil || !reflect.DeepEqual(got, []string{"mailinator.com"}) {
+		t.Fatalf("Expected [mailinator.com], got %v, %v", got, err)
+	}
+	if _, err := LoadDomainList(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Fatalf("Expected an error for a missing file")
+	}
+}
+
+func TestEmailDomains_Permits(t *testing.T) {
+	d := EmailDomains{Deny: []string{"mailinator.com"}}
+	for email, want := range map[string]bool{
+		"a@mailinator.com":    false,
+		"a@eu.mailinator.com": false,
+		"a@notmailinator.com": true,
+		"a@example.com":       true,
+	} {
+		if got := d.Permits(email); got != want {
+			t.Fatalf("Expected Permits(%q) = %v with a deny list", email, want)
+		}
+	}
+	d = EmailDomains{Allow: []string{"example.com"}, Deny: []string{"guests.example.com"}}
+	for email, want := range map[string]bool{
+		"a@example.com":        true,
+		"a@staff.example.com":  true,
+		"a@guests.example.com": false,
+		"a@other.org":          false,
+	} {
+		if got := d.Permits(email); got != want {
+			t.Fatalf("Expected Permits(%q) = %v with an allow list", email, want)
+		}
+	}
+	if !(EmailDomains{}).Permits("a@anything.test") {
+		t.Fatalf("Expected the zero value to permit every domain")
+	}
+}
+
+func TestRegister_RejectsDeniedDomain(t *testing.T) {
+	service, store := newEmailService(t)
+	service.EmailDomains = EmailDomains{Deny: []string{"mailinator.com"}}
+
+	code, errs := registerEmail(t, service, "Someone@Mailinator.com")
+	if code != 400 || len(errs) != 1 || errs[0].Field != "email" {
+		t.Fatalf("Expected 400 with an email error, got %d %+v", code, errs)
+	}
+	if u, _ := store.GetUserByEmail("someone@mailinator.com"); u != nil {
+		t.Fatalf("Expected no account to be created, got %+v", u)
+	}
+	if code, errs := registerEmail(t, service, "someone@example.com"); code != 200 {
+		t.Fatalf("Expected an allowed domain to register, got %d %+v", code, errs)
+	}
+}
+
+func TestRegister_AllowListAdmitsOnlyListedDomains(t *testing.T) {
+	service, _ := newEmailService(t)
+	service.EmailDomains = EmailDomains{Allow: []string{"example.com"}}
+
+	if code, errs := registerEmail(t, service, "staff@example.com"); code != 200 {
+		t.Fatalf("Expected a listed domain to register, got %d %+v", code, errs)
+	}
+	if code, _ := registerEmail(t, service, "someone@other.org"); code != 400 {
+		t.Fatalf("Expected 400 for an unlisted domain, got %d", code)
+	}
+}
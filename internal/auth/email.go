@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/deliium/drawing-board/internal/nfc"
+	"github.com/deliium/drawing-board/internal/validate"
+)
+
+// DefaultMaxEmailLength is used when Service.MaxEmailLength is zero; it is the longest address
+// SMTP can deliver to (RFC 5321)
+const DefaultMaxEmailLength = 254
+
+func (s *Service) maxEmailLength() int {
+	if s.MaxEmailLength > 0 { return s.MaxEmailLength }
+	return DefaultMaxEmailLength
+}
+
+// normalizeEmail is the form emails are stored and looked up in: trimmed, lowercased and in NFC,
+// so spellings that look the same, like a precomposed "é" and "e" plus a combining accent, are
+// one account
+func normalizeEmail(email string) string { return nfc.String(strings.ToLower(strings.TrimSpace(email))) }
+
+// checkEmail adds an error for a normalized email that is malformed, longer than max characters
+// or holds control characters, which IsEmail's shape check lets through
+func checkEmail(errs *validate.Errors, email string, max int) {
+	if email == "" { errs.Add("email", "is required"); return }
+	errs.Check(validate.IsEmail(email), "email", "is not a valid email address")
+	errs.Check(utf8.RuneCountInString(email) <= max, "email", fmt.Sprintf("must be at most %d characters", max))
+	errs.Check(strings.IndexFunc(email, unicode.IsControl) < 0, "email", "must not contain control characters")
+}
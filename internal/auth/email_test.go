@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/deliium/drawing-board/internal/db"
+	"github.com/deliium/drawing-board/internal/validate"
+	"github.com/gorilla/sessions"
+)
+
+func newEmailService(t *testing.T) (*Service, *db.Store) {
+	t.Helper()
+	store, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { store.SQL.Close() })
+	return NewService(store, sessions.NewCookieStore([]byte("test-secret"))), store
+}
+
+// registerEmail posts a registration and returns the status and any validation errors
+func registerEmail(t *testing.T, service *Service, email string) (int, []validate.FieldError) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"email": email, "password": "password123"})
+	rec := httptest.NewRecorder()
+	service.Register(rec, httptest.NewRequest("POST", "/api/register", strings.NewReader(string(body))))
+	var resp struct {
+		Errors []validate.FieldError `json:"errors"`
+	}
+	_ = json.NewDecoder(rec.Body).Decode(&resp)
+	return rec.Code, resp.Errors
+}
+
+func TestRegister_RejectsOverLongEmail(t *testing.T) {
+	service, _ := newEmailService(t)
+	long := strings.Repeat("a", DefaultMaxEmailLength-len("@example.com")+1) + "@example.com"
+	if code, errs := registerEmail(t, service, long); code != 400 || len(errs) != 1 || errs[0].Field != "email" || !strings.Contains(errs[0].Message, "at most 254") {
+		t.Fatalf("Expected 400 for a %d-character email, got %d %v", len(long), code, errs)
+	}
+
+	service.MaxEmailLength = 20
+	if code, _ := registerEmail(t, service, "twenty-one@example.c"); code != 200 {
+		t.Fatalf("Expected a 20-character email to register, got %d", code)
+	}
+	if code, _ := registerEmail(t, service, "twenty-one1@example.c"); code != 400 {
+		t.Fatalf("Expected a 21-character email to be rejected, got %d", code)
+	}
+}
+
+func TestRegister_RejectsControlCharacters(t *testing.T) {
+	service, _ := newEmailService(t)
+	for _, email := range []string{"a\x00b@example.com", "a\u0085b@example.com", "ab@exam\x7fple.com"} {
+		if code, errs := registerEmail(t, service, email); code != 400 || len(errs) != 1 || !strings.Contains(errs[0].Message, "control") {
+			t.Fatalf("Expected %q to be rejected, got %d %v", email, code, errs)
+		}
+	}
+}
+
+func TestRegister_NormalizedEmailsCollide(t *testing.T) {
+	service, store := newEmailService(t)
+	if code, _ := registerEmail(t, service, "Jose\u0301@example.com"); code != 200 {
+		t.Fatalf("Expected the decomposed spelling to register, got %d", code)
+	}
+	if code, _ := registerEmail(t, service, "jos\u00E9@example.com"); code != 409 {
+		t.Fatalf("Expected the precomposed spelling to collide, got %d", code)
+	}
+	u, err := store.GetUserByEmail("jos\u00E9@example.com")
+	if err != nil || u == nil {
+		t.Fatalf("Expected the email stored in NFC, got %v, %v", u, err)
+	}
+	if got, err := service.Authenticate("JOSE\u0301@example.com", "password123"); err != nil || got == nil || got.ID != u.ID {
+		t.Fatalf("Expected either spelling to sign in, got %v, %v", got, err)
+	}
+}
+
+func TestAuthenticate_FindsUnnormalizedLegacyEmail(t *testing.T) {
+	service, store := newEmailService(t)
+	id, err := store.CreateUser("rene\u0301@example.com", mustHash(t, "password123"))
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	if u, err := service.Authenticate("rene\u0301@example.com", "password123"); err != nil || u == nil || u.ID != id {
+		t.Fatalf("Expected legacy user %d, got %v, %v", id, u, err)
+	}
+}
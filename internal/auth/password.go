@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password hashes are bcrypt, followed by "$<pepper id>" when the password was peppered. The
+// cost is bcrypt's: each step doubles the work. Hashes from before salting (bare hex SHA-256)
+// still verify and are replaced on the next login.
+const (
+	DefaultPasswordCost = 10 // bcrypt.DefaultCost
+	MinPasswordCost     = 10
+	MaxPasswordCost     = 16
+
+	// MaxPasswordLength is the most bytes bcrypt reads of a password; longer ones are refused
+	// rather than silently truncated
+	MaxPasswordLength = 72
+
+	bcryptHashLength = 60
+)
+
+var passwordCost atomic.Int32
+
+func init() { passwordCost.Store(DefaultPasswordCost) }
+
+// PasswordCost returns the cost new hashes are created with
+func PasswordCost() int { return int(passwordCost.Load()) }
+
+// SetPasswordCost changes the cost for new hashes; existing hashes keep theirs until the next
+// login re-hashes them. Values outside [MinPasswordCost, MaxPasswordCost] are rejected.
+func SetPasswordCost(cost int) error {
+	if cost < MinPasswordCost || cost > MaxPasswordCost {
+		return fmt.Errorf("password cost %d outside [%d, %d]", cost, MinPasswordCost, MaxPasswordCost)
+	}
+	passwordCost.Store(int32(cost))
+	return nil
+}
+
+func hashPassword(pw string) (string, error) {
+	cost, id := PasswordCost(), CurrentPepperID()
+	peppered, ok := pepper(pw, id)
+	if !ok { return "", fmt.Errorf("pepper %q is not configured", id) }
+	key, err := bcrypt.GenerateFromPassword(peppered, cost)
+	if err != nil { return "", err }
+	hash := string(key)
+	if id != "" { hash += "$" + id }
+	return hash, nil
+}
+
+// comparePassword reports whether pw matches hash, in either the current or the legacy format.
+// A hash peppered with an ID that is no longer configured never matches.
+func comparePassword(hash, pw string) bool {
+	if isLegacyHash(hash) {
+		sum := sha256.Sum256([]byte(pw))
+		return subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(strings.ToLower(hash))) == 1
+	}
+	key, id, ok := parseHash(hash)
+	if !ok { return false }
+	peppered, ok := pepper(pw, id)
+	if !ok { return false }
+	return bcrypt.CompareHashAndPassword(key, peppered) == nil
+}
+
+// dummy is a hash of no one's password at the current cost, compared against when the email is
+// unknown so a failed login takes as long whether or not the account exists
+var dummy struct {
+	sync.Mutex
+	cost   int
+	pepper string
+	hash   string
+}
+
+// dummyHash returns the comparison target for unknown emails, remade when the cost or pepper
+// changes so its work keeps matching real hashes; a retired pepper would otherwise fail it early
+func dummyHash() string {
+	dummy.Lock()
+	defer dummy.Unlock()
+	if cost, id := PasswordCost(), CurrentPepperID(); dummy.cost != cost || dummy.pepper != id || dummy.hash == "" {
+		hash, err := hashPassword("")
+		if err != nil { return dummy.hash }
+		dummy.cost, dummy.pepper, dummy.hash = cost, id, hash
+	}
+	return dummy.hash
+}
+
+// needsRehash reports whether hash should be replaced after a successful login: it is a legacy
+// unsalted hash, was made with a lower cost than the current one, or with another pepper
+func needsRehash(hash string) bool {
+	if isLegacyHash(hash) { return true }
+	key, id, ok := parseHash(hash)
+	if !ok { return true }
+	cost, err := bcrypt.Cost(key)
+	return err != nil || cost < PasswordCost() || id != CurrentPepperID()
+}
+
+// isLegacyHash matches the unsalted hex SHA-256 hashes stored before salting
+func isLegacyHash(hash string) bool {
+	if len(hash) != 2*sha256.Size { return false }
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
+
+// parseHash splits a stored hash into the bcrypt hash and its pepper ID, "" for an unpeppered one
+func parseHash(hash string) (key []byte, pepperID string, ok bool) {
+	if len(hash) < bcryptHashLength || !strings.HasPrefix(hash, "$2") { return nil, "", false }
+	key, rest := []byte(hash[:bcryptHashLength]), hash[bcryptHashLength:]
+	if rest != "" {
+		if pepperID, ok = strings.CutPrefix(rest, "$"); !ok || pepperID == "" { return nil, "", false }
+	}
+	return key, pepperID, true
+}
@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deliium/drawing-board/internal/db"
+	"github.com/gorilla/sessions"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func mustHash(t *testing.T, pw string) string {
+	t.Helper()
+	h, err := hashPassword(pw)
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	return h
+}
+
+func legacyHash(pw string) string {
+	s := sha256.Sum256([]byte(pw))
+	return hex.EncodeToString(s[:])
+}
+
+func TestHashPassword_SaltedAndVerifiable(t *testing.T) {
+	a, b := mustHash(t, "password123"), mustHash(t, "password123")
+	if a == b {
+		t.Fatal("Identical passwords should hash differently")
+	}
+	if cost, err := bcrypt.Cost([]byte(a)); err != nil || cost != PasswordCost() {
+		t.Fatalf("Expected a bcrypt hash at the current cost, got %q", a)
+	}
+	if !comparePassword(a, "password123") || comparePassword(a, "password124") {
+		t.Fatal("Hash should verify the right password only")
+	}
+	if needsRehash(a) {
+		t.Fatal("A fresh hash should not need rehashing")
+	}
+	if comparePassword("$2a$10$x", "password123") || comparePassword(a+"$", "password123") || comparePassword("", "") {
+		t.Fatal("Malformed hashes should never verify")
+	}
+}
+
+func TestSetPasswordCost(t *testing.T) {
+	defer SetPasswordCost(DefaultPasswordCost)
+	if err := SetPasswordCost(MinPasswordCost - 1); err == nil {
+		t.Fatal("Expected a cost below the minimum to be rejected")
+	}
+	if err := SetPasswordCost(MinPasswordCost); err != nil {
+		t.Fatalf("Should not return error: %v", err)
+	}
+	weak := mustHash(t, "password123")
+	if !strings.HasPrefix(weak, "$2a$10$") {
+		t.Fatalf("Expected cost 10 in the hash, got %q", weak)
+	}
+	SetPasswordCost(MinPasswordCost + 1)
+	if !needsRehash(weak) {
+		t.Fatal("A hash below the current cost should need rehashing")
+	}
+}
+
+func TestLogin_UpgradesLegacyHash(t *testing.T) {
+	store, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer store.SQL.Close()
+	service := NewService(store, sessions.NewCookieStore([]byte("test-secret")))
+	id, err := store.CreateUser("legacy@example.com", legacyHash("password123"))
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	service.Login(rec, httptest.NewRequest("POST", "/api/login", strings.NewReader(`{"email":"legacy@example.com","password":"wrongpass"}`)))
+	if rec.Code != 401 {
+		t.Fatalf("Expected 401 for a wrong password, got %d", rec.Code)
+	}
+	if u, _ := store.GetUserByID(id); u.PasswordHash != legacyHash("password123") {
+		t.Fatal("A failed login must not touch the stored hash")
+	}
+
+	rec = httptest.NewRecorder()
+	service.Login(rec, httptest.NewRequest("POST", "/api/login", strings.NewReader(`{"email":"legacy@example.com","password":"password123"}`)))
+	if rec.Code != 200 {
+		t.Fatalf("Expected legacy hash to authenticate, got %d %s", rec.Code, rec.Body.String())
+	}
+	u, _ := store.GetUserByID(id)
+	if isLegacyHash(u.PasswordHash) || !comparePassword(u.PasswordHash, "password123") {
+		t.Fatalf("Expected the hash to be upgraded, got %q", u.PasswordHash)
+	}
+
+	rec = httptest.NewRecorder()
+	service.Login(rec, httptest.NewRequest("POST", "/api/login", strings.NewReader(`{"email":"legacy@example.com","password":"password123"}`)))
+	if rec.Code != 200 {
+		t.Fatalf("Expected the upgraded hash to authenticate, got %d", rec.Code)
+	}
+}
+
+func TestDummyHash_FollowsCost(t *testing.T) {
+	defer SetPasswordCost(DefaultPasswordCost)
+	for _, cost := range []int{MinPasswordCost, MinPasswordCost + 1} {
+		SetPasswordCost(cost)
+		if got, err := bcrypt.Cost([]byte(dummyHash())); err != nil || got != cost {
+			t.Fatalf("Expected a dummy hash at cost %d, got %q", cost, dummyHash())
+		}
+	}
+}
+
+func TestLogin_UnknownEmailTakesAsLongAsWrongPassword(t *testing.T) {
+	defer SetPasswordCost(DefaultPasswordCost)
+	SetPasswordCost(MinPasswordCost + 1)
+	store, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer store.SQL.Close()
+	service := NewService(store, sessions.NewCookieStore([]byte("test-secret")))
+	if _, err := store.CreateUser("known@example.com", mustHash(t, "password123")); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	dummyHash() // made once per cost; keep that out of the measurement
+
+	// The fastest of a few attempts filters out scheduler noise
+	fastest := func(email string) time.Duration {
+		best := time.Duration(1<<63 - 1)
+		for i := 0; i < 5; i++ {
+			start := time.Now()
+			if u, err := service.Authenticate(email, "wrongpass"); u != nil || err != nil {
+				t.Fatalf("Expected no user for %s, got %v (%v)", email, u, err)
+			}
+			if d := time.Since(start); d < best { best = d }
+		}
+		return best
+	}
+	wrong, unknown := fastest("known@example.com"), fastest("nobody@example.com")
+	// Without the dummy comparison an unknown email costs one indexed lookup, orders of magnitude less
+	if unknown < wrong/3 {
+		t.Fatalf("Expected an unknown email to take about as long as a wrong password, got %v vs %v", unknown, wrong)
+	}
+}
+
+func TestRegister_RejectsPasswordsBcryptWouldTruncate(t *testing.T) {
+	if errs := validateRegister(credentials{Email: "long@example.com", Password: strings.Repeat("a", MaxPasswordLength)}, DefaultMaxEmailLength); errs.Any() {
+		t.Fatalf("Expected a %d-byte password to be accepted, got %v", MaxPasswordLength, errs)
+	}
+	if errs := validateRegister(credentials{Email: "long@example.com", Password: strings.Repeat("a", MaxPasswordLength+1)}, DefaultMaxEmailLength); !errs.Any() {
+		t.Fatal("Expected a password longer than bcrypt reads to be rejected")
+	}
+	if errs := validatePasswordChange(passwordChange{OldPassword: "password123", NewPassword: strings.Repeat("a", MaxPasswordLength+1)}); !errs.Any() {
+		t.Fatal("Expected a new password longer than bcrypt reads to be rejected")
+	}
+}
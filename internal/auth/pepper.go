@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// A pepper is an application-wide secret HMACed into every password before it is hashed, so a
+// leaked database alone is not enough to test guesses. Peppers are configured as "id:secret"
+// entries and each hash records the ID it was made with; the first entry peppers new hashes and
+// the rest only verify, so a pepper is rotated by prepending a new one and keeping the old until
+// its users have signed in again (needsRehash moves them over).
+type pepperRing struct {
+	current string // "" when no pepper is configured
+	secrets map[string][]byte
+}
+
+var peppers atomic.Pointer[pepperRing]
+
+func init() { peppers.Store(&pepperRing{}) }
+
+// parsePeppers reads a comma- or newline-separated "id:secret" list. IDs may not contain '$' or
+// ':'; an empty spec means no pepper.
+func parsePeppers(spec string) (*pepperRing, error) {
+	ring := &pepperRing{secrets: map[string][]byte{}}
+	for _, item := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' }) {
+		item = strings.TrimSpace(item)
+		if item == "" { continue }
+		id, secret, ok := strings.Cut(item, ":")
+		if !ok || id == "" || secret == "" || strings.Contains(id, "$") {
+			return nil, fmt.Errorf("pepper %q: want id:secret", redactPepper(item))
+		}
+		if _, dup := ring.secrets[id]; dup { return nil, fmt.Errorf("pepper id %q listed twice", id) }
+		if ring.current == "" { ring.current = id }
+		ring.secrets[id] = []byte(secret)
+	}
+	return ring, nil
+}
+
+// SetPeppers replaces the configured peppers with a comma- or newline-separated "id:secret" list,
+// the format of PASSWORD_PEPPERS and its secret file; the first entry is current
+func SetPeppers(spec string) error {
+	ring, err := parsePeppers(spec)
+	if err != nil { return err }
+	peppers.Store(ring)
+	return nil
+}
+
+// CurrentPepperID is the ID new hashes are peppered with, "" when peppering is off
+func CurrentPepperID() string { return peppers.Load().current }
+
+// pepper mixes the secret with the given ID into pw; ok is false when that ID is not configured.
+// The empty ID is an unpeppered hash and passes pw through.
+func pepper(pw, id string) (out []byte, ok bool) {
+	if id == "" { return []byte(pw), true }
+	secret, ok := peppers.Load().secrets[id]
+	if !ok { return nil, false }
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(pw))
+	return mac.Sum(nil), true
+}
+
+// redactPepper keeps an error message from echoing the secret of a malformed entry
+func redactPepper(item string) string {
+	if id, _, ok := strings.Cut(item, ":"); ok { return id + ":" + redacted }
+	return redacted
+}
+
+const redacted = "[redacted]"
@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/deliium/drawing-board/internal/db"
+	"github.com/gorilla/sessions"
+)
+
+func setPeppers(t *testing.T, spec string) {
+	t.Helper()
+	if err := SetPeppers(spec); err != nil {
+		t.Fatalf("Failed to set peppers %q: %v", spec, err)
+	}
+}
+
+func TestPepper_WrongPepperDoesNotVerify(t *testing.T) {
+	defer SetPeppers("")
+	setPeppers(t, "1:alpha")
+	hash := mustHash(t, "password123")
+	if !strings.HasSuffix(hash, "$1") {
+		t.Fatalf("Expected the hash to record pepper 1, got %q", hash)
+	}
+	if !comparePassword(hash, "password123") {
+		t.Fatal("Expected the password to verify with the pepper it was hashed with")
+	}
+	setPeppers(t, "1:beta")
+	if comparePassword(hash, "password123") {
+		t.Fatal("Expected a different pepper under the same ID not to verify")
+	}
+	setPeppers(t, "")
+	if comparePassword(hash, "password123") {
+		t.Fatal("Expected a peppered hash not to verify once its pepper is gone")
+	}
+	setPeppers(t, "1:alpha")
+	if !comparePassword(hash, "password123") || comparePassword(hash, "password124") {
+		t.Fatal("Expected the restored pepper to verify the right password only")
+	}
+}
+
+func TestPepper_MixesSecretIntoInput(t *testing.T) {
+	defer SetPeppers("")
+	setPeppers(t, "a:alpha,b:beta")
+	a, _ := pepper("password123", "a")
+	b, _ := pepper("password123", "b")
+	if string(a) == string(b) || string(a) == "password123" {
+		t.Fatal("Expected each pepper to change the hashed input differently")
+	}
+}
+
+func TestPepper_RotationKeepsOldHashesVerifying(t *testing.T) {
+	defer SetPeppers("")
+	unpeppered := mustHash(t, "password123")
+	setPeppers(t, "1:alpha")
+	if !comparePassword(unpeppered, "password123") || !needsRehash(unpeppered) {
+		t.Fatal("Expected hashes from before peppering to verify and be upgraded")
+	}
+	old := mustHash(t, "password123")
+	setPeppers(t, "2:beta,1:alpha")
+	if CurrentPepperID() != "2" {
+		t.Fatalf("Expected the first entry to be current, got %q", CurrentPepperID())
+	}
+	if !comparePassword(old, "password123") || !needsRehash(old) {
+		t.Fatal("Expected a hash with the previous pepper to verify and be upgraded")
+	}
+	if fresh := mustHash(t, "password123"); !strings.HasSuffix(fresh, "$2") || needsRehash(fresh) {
+		t.Fatalf("Expected a new hash under pepper 2, got %q", fresh)
+	}
+}
+
+func TestSetPeppers_RejectsMalformed(t *testing.T) {
+	defer SetPeppers("")
+	setPeppers(t, "1:alpha")
+	for _, spec := range []string{"alpha", ":alpha", "1:", "a$b:alpha", "1:alpha,1:beta"} {
+		err := SetPeppers(spec)
+		if err == nil {
+			t.Fatalf("Expected %q to be rejected", spec)
+		}
+		if strings.Contains(err.Error(), "alpha") {
+			t.Fatalf("Expected the error for %q not to echo the secret, got %v", spec, err)
+		}
+	}
+	if CurrentPepperID() != "1" {
+		t.Fatal("Expected a rejected spec to leave the peppers unchanged")
+	}
+	setPeppers(t, "2:beta\n1:alpha\n")
+	if CurrentPepperID() != "2" {
+		t.Fatal("Expected newline-separated peppers, as in a secret file")
+	}
+}
+
+func TestLogin_MovesHashToCurrentPepper(t *testing.T) {
+	defer SetPeppers("")
+	store, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer store.SQL.Close()
+	service := NewService(store, sessions.NewCookieStore([]byte("test-secret")))
+	setPeppers(t, "1:alpha")
+	id, err := store.CreateUser("pepper@example.com", mustHash(t, "password123"))
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	setPeppers(t, "2:beta,1:alpha")
+	rec := httptest.NewRecorder()
+	service.Login(rec, httptest.NewRequest("POST", "/api/login", strings.NewReader(`{"email":"pepper@example.com","password":"password123"}`)))
+	if rec.Code != 200 {
+		t.Fatalf("Expected the old pepper to still authenticate, got %d %s", rec.Code, rec.Body.String())
+	}
+	u, _ := store.GetUserByID(id)
+	if !strings.HasSuffix(u.PasswordHash, "$2") {
+		t.Fatalf("Expected the hash to move to pepper 2, got %q", u.PasswordHash)
+	}
+
+	// With the old pepper retired the upgraded hash is all that is needed
+	setPeppers(t, "2:beta")
+	rec = httptest.NewRecorder()
+	service.Login(rec, httptest.NewRequest("POST", "/api/login", strings.NewReader(`{"email":"pepper@example.com","password":"password123"}`)))
+	if rec.Code != 200 {
+		t.Fatalf("Expected the upgraded hash to authenticate, got %d", rec.Code)
+	}
+}
+
+func TestDummyHash_FollowsPepper(t *testing.T) {
+	defer SetPeppers("")
+	setPeppers(t, "1:alpha")
+	dummyHash()
+	setPeppers(t, "2:beta")
+	if h := dummyHash(); !strings.HasSuffix(h, "$2") {
+		t.Fatalf("Expected the dummy hash to use the current pepper, got %q", h)
+	}
+}
@@ -0,0 +1,231 @@
+package auth
+
+import (
+	"bufio"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// RedisStore keeps session values in Redis and only a signed session ID in the cookie, so
+// sessions are shared by every instance pointed at the same Redis and can be ended server-side.
+// It follows sessions.FilesystemStore: values are encoded with Codecs before they are stored, and
+// a MaxAge below zero deletes the session. Redis expires each session with its cookie; a MaxAge of
+// 0 (a browser-session cookie) is kept for DefaultRedisSessionAge.
+type RedisStore struct {
+	Codecs    []securecookie.Codec
+	Options   *sessions.Options // defaults copied into every new session
+	KeyPrefix string            // prepended to session IDs to form Redis keys
+
+	client *redisClient
+}
+
+// DefaultRedisSessionAge is how long Redis keeps a session saved with MaxAge 0, in seconds
+const DefaultRedisSessionAge = 30 * 24 * 60 * 60
+
+// NewRedisStore connects sessions to the Redis at rawURL, redis://[:password@]host[:port][/db],
+// signing session IDs with keyPairs as sessions.NewCookieStore does. The connection is checked with
+// a PING so a wrong address fails at startup rather than on the first sign-in.
+func NewRedisStore(rawURL string, keyPairs ...[]byte) (*RedisStore, error) {
+	client, err := newRedisClient(rawURL)
+	if err != nil { return nil, err }
+	if _, err := client.do("PING"); err != nil { return nil, fmt.Errorf("redis %s: %w", client.addr, err) }
+	return &RedisStore{
+		Codecs:    securecookie.CodecsFromPairs(keyPairs...),
+		Options:   &sessions.Options{Path: "/", MaxAge: DefaultRedisSessionAge},
+		KeyPrefix: "drawing-board:session:",
+		client:    client,
+	}, nil
+}
+
+// Get returns the request's session after adding it to the registry, see sessions.CookieStore.Get
+func (s *RedisStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New returns the session named by the request's cookie, or a new one when there is no cookie or
+// Redis no longer has it. It always returns a session, with an error when the cookie does not
+// decode or Redis cannot be reached.
+func (s *RedisStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	opts := *s.Options
+	session.Options = &opts
+	session.IsNew = true
+	c, err := r.Cookie(name)
+	if err != nil { return session, nil }
+	if err := securecookie.DecodeMulti(name, c.Value, &session.ID, s.Codecs...); err != nil { return session, err }
+	found, err := s.load(session)
+	if err != nil { return session, err }
+	// An ID Redis does not know is never reused, so a cookie cannot pick the next session's ID
+	if !found { session.ID = ""; return session, nil }
+	session.IsNew = false
+	return session, nil
+}
+
+var base32RawStdEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Save stores session and sets its cookie, or deletes both when Options.MaxAge is below zero
+func (s *RedisStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			if _, err := s.client.do("DEL", s.KeyPrefix+session.ID); err != nil { return err }
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+	if session.ID == "" { session.ID = base32RawStdEncoding.EncodeToString(securecookie.GenerateRandomKey(32)) }
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.Values, s.Codecs...)
+	if err != nil { return err }
+	age := session.Options.MaxAge
+	if age == 0 { age = DefaultRedisSessionAge }
+	if _, err := s.client.do("SET", s.KeyPrefix+session.ID, encoded, "EX", strconv.Itoa(age)); err != nil { return err }
+	cookie, err := securecookie.EncodeMulti(session.Name(), session.ID, s.Codecs...)
+	if err != nil { return err }
+	http.SetCookie(w, sessions.NewCookie(session.Name(), cookie, session.Options))
+	return nil
+}
+
+// Renew deletes session from Redis and clears its ID and values, so the next Save stores it under
+// a fresh ID. Sign-in renews the session it was given, which keeps an ID planted in a browser
+// before sign-in from naming the signed-in session.
+func (s *RedisStore) Renew(session *sessions.Session) error {
+	id := session.ID
+	session.ID, session.Values = "", map[interface{}]interface{}{}
+	if id == "" { return nil }
+	_, err := s.client.do("DEL", s.KeyPrefix+id)
+	return err
+}
+
+// Close drops the idle Redis connections
+func (s *RedisStore) Close() error { return s.client.close() }
+
+// load reads session.Values from Redis; found is false when the session has expired or was deleted
+func (s *RedisStore) load(session *sessions.Session) (found bool, err error) {
+	reply, err := s.client.do("GET", s.KeyPrefix+session.ID)
+	if err != nil || reply == nil { return false, err }
+	data, ok := reply.(string)
+	if !ok { return false, fmt.Errorf("redis GET: unexpected reply %T", reply) }
+	if err := securecookie.DecodeMulti(session.Name(), data, &session.Values, s.Codecs...); err != nil { return false, err }
+	return true, nil
+}
+
+// redisClient speaks just enough RESP for RedisStore, one command at a time per connection, over
+// a small pool of idle connections
+type redisClient struct {
+	addr, password string
+	db             int
+	timeout        time.Duration
+	idle           chan *redisConn
+}
+
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// redisIdleConns bounds the connections kept open between requests; more are dialed under load
+const redisIdleConns = 8
+
+func newRedisClient(rawURL string) (*redisClient, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil { return nil, fmt.Errorf("redis url: %w", err) }
+	if u.Scheme != "redis" || u.Host == "" { return nil, fmt.Errorf("redis url %q: want redis://[:password@]host[:port][/db]", u.Redacted()) }
+	c := &redisClient{addr: u.Host, timeout: 5 * time.Second, idle: make(chan *redisConn, redisIdleConns)}
+	if u.Port() == "" { c.addr = net.JoinHostPort(u.Hostname(), "6379") }
+	if u.User != nil { c.password, _ = u.User.Password() }
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if c.db, err = strconv.Atoi(db); err != nil || c.db < 0 { return nil, fmt.Errorf("redis url %q: database must be a number", u.Redacted()) }
+	}
+	return c, nil
+}
+
+// do runs one command and returns its reply: a string, an int64, or nil for a missing value
+func (c *redisClient) do(args ...string) (interface{}, error) {
+	conn, err := c.conn()
+	if err != nil { return nil, err }
+	reply, err := conn.command(c.timeout, args...)
+	var redisErr redisError
+	if err != nil && !errors.As(err, &redisErr) {
+		// The stream may be mid-reply; a fresh connection is cheaper than resynchronizing
+		conn.Close()
+		return nil, err
+	}
+	select {
+	case c.idle <- conn:
+	default: conn.Close()
+	}
+	return reply, err
+}
+
+// conn takes an idle connection or dials one, authenticating and selecting the database
+func (c *redisClient) conn() (*redisConn, error) {
+	select {
+	case conn := <-c.idle: return conn, nil
+	default:
+	}
+	nc, err := net.DialTimeout("tcp", c.addr, c.timeout)
+	if err != nil { return nil, err }
+	conn := &redisConn{Conn: nc, r: bufio.NewReader(nc)}
+	if c.password != "" {
+		if _, err := conn.command(c.timeout, "AUTH", c.password); err != nil { nc.Close(); return nil, fmt.Errorf("redis AUTH: %w", err) }
+	}
+	if c.db != 0 {
+		if _, err := conn.command(c.timeout, "SELECT", strconv.Itoa(c.db)); err != nil { nc.Close(); return nil, fmt.Errorf("redis SELECT: %w", err) }
+	}
+	return conn, nil
+}
+
+func (c *redisClient) close() error {
+	for {
+		select {
+		case conn := <-c.idle: conn.Close()
+		default: return nil
+		}
+	}
+}
+
+// redisError is an error reply; the connection stays usable after one
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// command writes args as a RESP array of bulk strings and reads the reply
+func (conn *redisConn) command(timeout time.Duration, args ...string) (interface{}, error) {
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil { return nil, err }
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args { fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a) }
+	if _, err := conn.Write([]byte(b.String())); err != nil { return nil, err }
+	return readRESP(conn.r)
+}
+
+// readRESP reads one simple string, error, integer or bulk string reply
+func readRESP(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil { return nil, err }
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") { return nil, fmt.Errorf("redis: malformed reply %q", line) }
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+': return body, nil
+	case '-': return nil, redisError(body)
+	case ':': return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil { return nil, fmt.Errorf("redis: malformed bulk length %q", body) }
+		if n < 0 { return nil, nil }
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil { return nil, err }
+		return string(buf[:n]), nil
+	}
+	return nil, fmt.Errorf("redis: unsupported reply type %q", kind)
+}
@@ -0,0 +1,282 @@
+package auth
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/deliium/drawing-board/internal/db"
+	"github.com/gorilla/securecookie"
+)
+
+// fakeRedis answers the commands RedisStore sends from an in-memory map
+type fakeRedis struct {
+	ln       net.Listener
+	password string
+
+	mu   sync.Mutex
+	data map[string]string
+	ttl  map[string]int
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	f := &fakeRedis{ln: ln, password: password, data: map[string]string{}, ttl: map[string]int{}}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil { return }
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeRedis) url() string {
+	if f.password != "" { return "redis://:" + f.password + "@" + f.ln.Addr().String() + "/2" }
+	return "redis://" + f.ln.Addr().String()
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authed := f.password == ""
+	for {
+		args, err := readCommand(r)
+		if err != nil { return }
+		f.mu.Lock()
+		var reply string
+		switch cmd := strings.ToUpper(args[0]); {
+		case cmd == "AUTH":
+			authed = args[1] == f.password
+			reply = "+OK\r\n"
+			if !authed { reply = "-WRONGPASS invalid password\r\n" }
+		case !authed:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case cmd == "PING":
+			reply = "+PONG\r\n"
+		case cmd == "SELECT":
+			reply = "+OK\r\n"
+		case cmd == "GET":
+			if v, ok := f.data[args[1]]; ok { reply = fmt.Sprintf("$%d\r\n%s\r\n", len(v), v) } else { reply = "$-1\r\n" }
+		case cmd == "SET":
+			f.data[args[1]] = args[2]
+			f.ttl[args[1]], _ = strconv.Atoi(args[4])
+			reply = "+OK\r\n"
+		case cmd == "DEL":
+			_, ok := f.data[args[1]]
+			delete(f.data, args[1])
+			reply = ":0\r\n"
+			if ok { reply = ":1\r\n" }
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		f.mu.Unlock()
+		if _, err := io.WriteString(conn, reply); err != nil { return }
+	}
+}
+
+// readCommand reads a RESP array of bulk strings
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil { return nil, err }
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil || line[0] != '*' { return nil, fmt.Errorf("bad command %q", line) }
+	args := make([]string, n)
+	for i := range args {
+		if _, err := r.ReadString('\n'); err != nil { return nil, err }
+		arg, err := r.ReadString('\n')
+		if err != nil { return nil, err }
+		args[i] = strings.TrimSuffix(arg, "\r\n")
+	}
+	return args, nil
+}
+
+func (f *fakeRedis) sessions() map[string]int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := map[string]int{}
+	for k := range f.data { out[k] = f.ttl[k] }
+	return out
+}
+
+func redisService(t *testing.T, fake *fakeRedis) (*Service, *RedisStore) {
+	t.Helper()
+	rs, err := NewRedisStore(fake.url(), []byte("test-secret"))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { rs.Close() })
+	store, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	service := NewService(store, rs)
+	service.SessionTTL = time.Hour
+	return service, rs
+}
+
+func TestRedisStore_SessionLivesInRedis(t *testing.T) {
+	fake := newFakeRedis(t, "hunter22")
+	service, _ := redisService(t, fake)
+
+	reg := httptest.NewRecorder()
+	service.Register(reg, httptest.NewRequest("POST", "/api/register", strings.NewReader(`{"email":"redis@example.com","password":"password123"}`)))
+	if reg.Code != 200 {
+		t.Fatalf("Failed to register: %d %s", reg.Code, reg.Body.String())
+	}
+	stored := fake.sessions()
+	if len(stored) != 1 {
+		t.Fatalf("Expected one session in Redis, got %v", stored)
+	}
+	for key, ttl := range stored {
+		if !strings.HasPrefix(key, "drawing-board:session:") || ttl != 3600 {
+			t.Fatalf("Expected a prefixed key expiring with SessionTTL, got %s expiring in %d", key, ttl)
+		}
+	}
+	cookies := reg.Result().Cookies()
+	if len(cookies) != 1 || strings.Contains(cookies[0].Value, "redis@example.com") {
+		t.Fatalf("Expected one session cookie holding only an ID, got %v", cookies)
+	}
+
+	// A second service on the same Redis, like another instance, sees the session
+	other, _ := redisService(t, fake)
+	req := httptest.NewRequest("GET", "/api/me", nil)
+	req.AddCookie(cookies[0])
+	if _, ok := other.UserIDFromRequest(req); !ok {
+		t.Fatal("Expected another instance to find the session")
+	}
+
+	logout := httptest.NewRequest("POST", "/api/logout", nil)
+	logout.AddCookie(cookies[0])
+	service.Logout(httptest.NewRecorder(), logout)
+	if len(fake.sessions()) != 0 {
+		t.Fatal("Expected logout to delete the session from Redis")
+	}
+	req = httptest.NewRequest("GET", "/api/me", nil)
+	req.AddCookie(cookies[0])
+	if _, ok := other.UserIDFromRequest(req); ok {
+		t.Fatal("Expected the cookie to be refused once the session is deleted")
+	}
+}
+
+func TestRedisStore_UnknownIDIsNotReused(t *testing.T) {
+	fake := newFakeRedis(t, "")
+	_, rs := redisService(t, fake)
+	saved := httptest.NewRecorder()
+	// A validly signed cookie for a session Redis no longer has, as an expired one would carry
+	req := httptest.NewRequest("GET", "/", nil)
+	s, _ := rs.New(req, sessionName)
+	s.ID = "chosen-by-attacker"
+	if err := rs.Save(req, saved, s); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+	fake.mu.Lock()
+	delete(fake.data, rs.KeyPrefix+"chosen-by-attacker")
+	fake.mu.Unlock()
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(saved.Result().Cookies()[0])
+	s, err := rs.New(req, sessionName)
+	if err != nil || !s.IsNew || s.ID != "" {
+		t.Fatalf("Expected a new session with no ID, got %+v, %v", s, err)
+	}
+}
+
+func TestRedisStore_SignInRenewsSessionID(t *testing.T) {
+	fake := newFakeRedis(t, "")
+	service, rs := redisService(t, fake)
+	cookieID := func(rec *httptest.ResponseRecorder) string {
+		t.Helper()
+		var id string
+		cookies := rec.Result().Cookies()
+		if len(cookies) != 1 || securecookie.DecodeMulti(sessionName, cookies[0].Value, &id, rs.Codecs...) != nil {
+			t.Fatalf("Expected one session cookie, got %v", cookies)
+		}
+		return id
+	}
+	// An anonymous session an attacker could have planted in the victim's browser
+	planted := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	s, _ := rs.New(req, sessionName)
+	if err := rs.Save(req, planted, s); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+	plantedID := cookieID(planted)
+
+	reg := httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/api/register", strings.NewReader(`{"email":"renew@example.com","password":"password123"}`))
+	req.AddCookie(planted.Result().Cookies()[0])
+	service.Register(reg, req)
+	if reg.Code != 200 {
+		t.Fatalf("Failed to register: %d %s", reg.Code, reg.Body.String())
+	}
+	regID := cookieID(reg)
+	if regID == plantedID {
+		t.Fatal("Expected registering to issue a new session ID")
+	}
+
+	login := httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/api/login", strings.NewReader(`{"email":"renew@example.com","password":"password123"}`))
+	req.AddCookie(reg.Result().Cookies()[0])
+	service.Login(login, req)
+	if login.Code != 200 {
+		t.Fatalf("Failed to log in: %d %s", login.Code, login.Body.String())
+	}
+	loginID := cookieID(login)
+	if loginID == regID {
+		t.Fatal("Expected logging in to issue a new session ID")
+	}
+	stored := fake.sessions()
+	if _, ok := stored[rs.KeyPrefix+loginID]; len(stored) != 1 || !ok {
+		t.Fatalf("Expected only the new session in Redis, got %v", stored)
+	}
+	req = httptest.NewRequest("GET", "/api/me", nil)
+	req.AddCookie(planted.Result().Cookies()[0])
+	if _, ok := service.UserIDFromRequest(req); ok {
+		t.Fatal("Expected the planted cookie not to be signed in")
+	}
+}
+
+func TestNewRedisStore_RejectsBadURLAndUnreachableServer(t *testing.T) {
+	for _, u := range []string{"http://localhost:6379", "redis://", "redis://localhost/notanumber"} {
+		if _, err := NewRedisStore(u, []byte("k")); err == nil {
+			t.Fatalf("Expected %q to be rejected", u)
+		}
+	}
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := ln.Addr().String()
+	ln.Close()
+	if _, err := NewRedisStore("redis://"+addr, []byte("k")); err == nil {
+		t.Fatal("Expected an unreachable Redis to fail")
+	}
+	fake := newFakeRedis(t, "right")
+	if _, err := NewRedisStore("redis://:wrong@"+fake.ln.Addr().String(), []byte("k")); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Fatalf("Expected a wrong password to fail, got %v", err)
+	}
+}
+
+func TestRedisStore_ErrorReplyKeepsConnection(t *testing.T) {
+	fake := newFakeRedis(t, "")
+	_, rs := redisService(t, fake)
+	if _, err := rs.client.do("BOGUS"); err == nil {
+		t.Fatal("Expected an error reply")
+	}
+	if reply, err := rs.client.do("PING"); err != nil || reply != "PONG" {
+		t.Fatalf("Expected PING to work after an error reply, got %v, %v", reply, err)
+	}
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/sessions"
+)
+
+// RolePolicy decides what happens to a session whose role changed after it was issued, e.g. when
+// its account was added to or removed from AdminEmails
+type RolePolicy int
+
+const (
+	// RoleRefresh records the new role in the session on its next request; no sign-in needed
+	RoleRefresh RolePolicy = iota
+	// RoleReauth ends the session so the user signs in again under the new role
+	RoleReauth
+)
+
+// ParseRolePolicy maps "refresh" (or "") and "reauth" to a RolePolicy
+func ParseRolePolicy(s string) (RolePolicy, error) {
+	switch s {
+	case "", "refresh": return RoleRefresh, nil
+	case "reauth": return RoleReauth, nil
+	}
+	return RoleRefresh, fmt.Errorf("unknown role policy %q", s)
+}
+
+const (
+	roleUser  = "user"
+	roleAdmin = "admin"
+)
+
+func roleName(admin bool) string {
+	if admin { return roleAdmin }
+	return roleUser
+}
+
+// syncRole compares uid's current role with the one sess recorded at sign-in and applies
+// RolePolicy when they differ. Sessions issued before roles were recorded adopt the current role
+// under either policy. ok is false when the session was ended; its cookie is cleared.
+func (s *Service) syncRole(w http.ResponseWriter, r *http.Request, sess *sessions.Session, uid int64) (admin, ok bool, err error) {
+	admin, err = s.IsAdminContext(r.Context(), uid)
+	if err != nil { return false, false, err }
+	role := roleName(admin)
+	recorded, had := sess.Values["role"].(string)
+	if recorded == role { return admin, true, nil }
+	if had && s.RolePolicy == RoleReauth {
+		log.Printf("session of user %d ended: role changed from %s to %s", uid, recorded, role)
+		sess.Options.MaxAge = -1
+		_ = s.save(sess, r, w)
+		return admin, false, nil
+	}
+	sess.Values["role"] = role
+	if err := s.save(sess, r, w); err != nil { log.Printf("Warning: refresh session role for user %d: %v", uid, err) }
+	return admin, true, nil
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/deliium/drawing-board/internal/db"
+	"github.com/gorilla/sessions"
+)
+
+func TestParseRolePolicy(t *testing.T) {
+	for in, want := range map[string]RolePolicy{"": RoleRefresh, "refresh": RoleRefresh, "reauth": RoleReauth} {
+		if got, err := ParseRolePolicy(in); err != nil || got != want {
+			t.Fatalf("ParseRolePolicy(%q): expected %v, got %v (%v)", in, want, got, err)
+		}
+	}
+	if _, err := ParseRolePolicy("logout"); err == nil {
+		t.Fatal("Expected an unknown policy to be rejected")
+	}
+}
+
+// promotedSession registers a regular user, then lists them in AdminEmails as if the config changed
+func promotedSession(t *testing.T, policy RolePolicy) (*Service, []*http.Cookie) {
+	t.Helper()
+	store, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { store.SQL.Close() })
+	service := NewService(store, sessions.NewCookieStore([]byte("test-secret")))
+	service.RolePolicy = policy
+	service.AdminEmails = []string{"ops@example.com"}
+	rec := httptest.NewRecorder()
+	service.Register(rec, httptest.NewRequest("POST", "/api/register", strings.NewReader(`{"email":"dev@example.com","password":"password123"}`)))
+	if rec.Code != 200 {
+		t.Fatalf("Failed to register: %d %s", rec.Code, rec.Body.String())
+	}
+	service.AdminEmails = append(service.AdminEmails, "dev@example.com")
+	return service, rec.Result().Cookies()
+}
+
+func serveWith(h http.Handler, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/api/admin/schema", nil)
+	for _, c := range cookies { req.AddCookie(c) }
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+
+func TestRoleRefresh_PromotionTakesEffectWithoutSignIn(t *testing.T) {
+	service, cookies := promotedSession(t, RoleRefresh)
+	rec := serveWith(service.RequireAdmin(okHandler), cookies)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected the promoted session to pass RequireAdmin, got %d %s", rec.Code, rec.Body.String())
+	}
+	refreshed := rec.Result().Cookies()
+	if len(refreshed) != 1 || refreshed[0].MaxAge < 0 {
+		t.Fatalf("Expected the session cookie to be rewritten with the new role, got %v", refreshed)
+	}
+	rec = serveWith(http.HandlerFunc(service.Me), refreshed)
+	var me userView
+	if err := json.Unmarshal(rec.Body.Bytes(), &me); err != nil || !me.Admin {
+		t.Fatalf("Expected /api/me to report admin, got %s (%v)", rec.Body.String(), err)
+	}
+	if len(rec.Result().Cookies()) != 0 {
+		t.Fatal("Expected no rewrite once the session records the current role")
+	}
+}
+
+func TestRoleReauth_RoleChangeEndsSession(t *testing.T) {
+	service, cookies := promotedSession(t, RoleReauth)
+	rec := serveWith(service.RequireAuth(okHandler), cookies)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected 401 after the role changed, got %d", rec.Code)
+	}
+	cleared := rec.Result().Cookies()
+	if len(cleared) != 1 || cleared[0].MaxAge >= 0 {
+		t.Fatalf("Expected the session cookie to be cleared, got %v", cleared)
+	}
+
+	rec = httptest.NewRecorder()
+	service.Login(rec, httptest.NewRequest("POST", "/api/login", strings.NewReader(`{"email":"dev@example.com","password":"password123"}`)))
+	var me userView
+	if err := json.Unmarshal(rec.Body.Bytes(), &me); err != nil || rec.Code != 200 || !me.Admin {
+		t.Fatalf("Expected signing in again to report admin, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := serveWith(service.RequireAdmin(okHandler), rec.Result().Cookies()); rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected the new session to pass RequireAdmin, got %d", rec.Code)
+	}
+}
+
+func TestRoleReauth_DemotionEndsSession(t *testing.T) {
+	service, cookies := promotedSession(t, RoleReauth)
+	service.AdminEmails = service.AdminEmails[:1]
+	// Register recorded "user", so removing the promotion leaves the session untouched
+	if rec := serveWith(service.RequireAuth(okHandler), cookies); rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected an unchanged role to pass, got %d", rec.Code)
+	}
+
+	rec := httptest.NewRecorder()
+	service.AdminEmails = append(service.AdminEmails, "dev@example.com")
+	service.Login(rec, httptest.NewRequest("POST", "/api/login", strings.NewReader(`{"email":"dev@example.com","password":"password123"}`)))
+	admin := rec.Result().Cookies()
+	service.AdminEmails = service.AdminEmails[:1]
+	if rec := serveWith(service.RequireAdmin(okHandler), admin); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected a demoted admin session to be ended, got %d", rec.Code)
+	}
+}
+
+func TestSyncRole_LegacySessionAdoptsRole(t *testing.T) {
+	service, _ := promotedSession(t, RoleReauth)
+	// A session from before roles were recorded carries only the user ID
+	req := httptest.NewRequest("GET", "/", nil)
+	sess, _ := service.Sessions.Get(req, sessionName)
+	sess.Values["user_id"] = int64(1)
+	rec := httptest.NewRecorder()
+	if err := sess.Save(req, rec); err != nil {
+		t.Fatalf("Failed to save session: %v", err)
+	}
+	if rec := serveWith(service.RequireAdmin(okHandler), rec.Result().Cookies()); rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected a legacy session to adopt its current role, got %d", rec.Code)
+	}
+}
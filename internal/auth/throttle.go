@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/deliium/drawing-board/internal/ratelimit"
+)
+
+// NewAttemptLimiter allows attempts sign-in or registration attempts per window from each IP and
+// for each email, refilling steadily; it returns nil, which disables throttling, when either is 0
+func NewAttemptLimiter(attempts int, window time.Duration) *ratelimit.Limiter {
+	if attempts <= 0 || window <= 0 { return nil }
+	return ratelimit.New(float64(attempts)/window.Seconds(), attempts)
+}
+
+// throttled takes an attempt from the caller's IP and from email, answering 429 with Retry-After
+// and reporting true when either has none left. Keying by email too stops stuffing spread over
+// many addresses, at the cost that anyone can hold off one account's sign-in for a window.
+func (s *Service) throttled(w http.ResponseWriter, r *http.Request, email string) bool {
+	if s.Attempts == nil { return false }
+	for _, key := range attemptKeys(r, email) {
+		if ok, wait := s.Attempts.Reserve(key); !ok { ratelimit.TooManyRequests(w, wait); return true }
+	}
+	return false
+}
+
+// resetAttempts clears email's failures after its owner signed in, so a legitimate user is not
+// held off by mistyping. The IP bucket is left alone: a stuffer holding one valid account could
+// otherwise sign into it between guesses to refill their address.
+func (s *Service) resetAttempts(r *http.Request, email string) {
+	if s.Attempts == nil || email == "" { return }
+	s.Attempts.Reset("email:" + email)
+}
+
+func attemptKeys(r *http.Request, email string) []string {
+	keys := []string{"ip:" + ratelimit.ClientIP(r)}
+	if email != "" { keys = append(keys, "email:"+email) }
+	return keys
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// login posts credentials from ip and returns the recorder
+func login(service *Service, ip, email, password string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/api/login", strings.NewReader(`{"email":"`+email+`","password":"`+password+`"}`))
+	req.RemoteAddr = ip + ":4321"
+	rec := httptest.NewRecorder()
+	service.Login(rec, req)
+	return rec
+}
+
+func TestLogin_RepeatedFailuresGet429(t *testing.T) {
+	service, _ := newEmailService(t)
+	if code, _ := registerEmail(t, service, "stuffed@example.com"); code != 200 {
+		t.Fatalf("Failed to register: %d", code)
+	}
+	service.Attempts = NewAttemptLimiter(3, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		if rec := login(service, "198.51.100.1", "stuffed@example.com", "wrong"); rec.Code != 401 {
+			t.Fatalf("Expected attempt %d to fail with 401, got %d", i+1, rec.Code)
+		}
+	}
+	rec := login(service, "198.51.100.1", "stuffed@example.com", "password123")
+	if rec.Code != 429 {
+		t.Fatalf("Expected 429 once the attempts are used up, got %d", rec.Code)
+	}
+	if secs, err := strconv.Atoi(rec.Header().Get("Retry-After")); err != nil || secs < 1 {
+		t.Fatalf("Expected a Retry-After in seconds, got %q", rec.Header().Get("Retry-After"))
+	}
+	// The email's bucket is spent too, so another address cannot carry on guessing
+	if rec := login(service, "198.51.100.2", "stuffed@example.com", "wrong"); rec.Code != 429 {
+		t.Fatalf("Expected 429 for the same email from another IP, got %d", rec.Code)
+	}
+	if rec := login(service, "198.51.100.3", "other@example.com", "wrong"); rec.Code != 401 {
+		t.Fatalf("Expected an untouched IP and email to get through, got %d", rec.Code)
+	}
+}
+
+func TestLogin_SuccessClearsFailures(t *testing.T) {
+	service, _ := newEmailService(t)
+	if code, _ := registerEmail(t, service, "forgetful@example.com"); code != 200 {
+		t.Fatalf("Failed to register: %d", code)
+	}
+	service.Attempts = NewAttemptLimiter(3, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		login(service, "198.51.100.1", "forgetful@example.com", "wrong")
+	}
+	if rec := login(service, "198.51.100.1", "forgetful@example.com", "password123"); rec.Code != 200 {
+		t.Fatalf("Expected the third attempt to sign in, got %d", rec.Code)
+	}
+	for i := 0; i < 3; i++ {
+		if rec := login(service, "198.51.100.2", "forgetful@example.com", "wrong"); rec.Code != 401 {
+			t.Fatalf("Expected a full set of attempts for the email after signing in, attempt %d got %d", i+1, rec.Code)
+		}
+	}
+}
+
+func TestLogin_SuccessKeepsIPFailures(t *testing.T) {
+	service, _ := newEmailService(t)
+	if code, _ := registerEmail(t, service, "mine@example.com"); code != 200 {
+		t.Fatalf("Failed to register: %d", code)
+	}
+	service.Attempts = NewAttemptLimiter(3, time.Minute)
+
+	// Signing into an account the caller owns must not buy more guesses at other accounts
+	login(service, "198.51.100.1", "victim@example.com", "guess1")
+	if rec := login(service, "198.51.100.1", "mine@example.com", "password123"); rec.Code != 200 {
+		t.Fatalf("Expected the caller's own account to sign in, got %d", rec.Code)
+	}
+	login(service, "198.51.100.1", "victim2@example.com", "guess2")
+	if rec := login(service, "198.51.100.1", "victim3@example.com", "guess3"); rec.Code != 429 {
+		t.Fatalf("Expected the IP to stay limited after a sign-in, got %d", rec.Code)
+	}
+}
+
+func TestRegister_Throttled(t *testing.T) {
+	service, _ := newEmailService(t)
+	service.Attempts = NewAttemptLimiter(2, time.Minute)
+	for i, want := range []int{200, 409, 429} {
+		if code, _ := registerEmail(t, service, "taken@example.com"); code != want {
+			t.Fatalf("Expected registration %d to get %d, got %d", i+1, want, code)
+		}
+	}
+}
+
+func TestNewAttemptLimiter_ZeroDisables(t *testing.T) {
+	if NewAttemptLimiter(0, time.Minute) != nil || NewAttemptLimiter(5, 0) != nil {
+		t.Fatal("Expected no limiter without attempts and a window")
+	}
+}
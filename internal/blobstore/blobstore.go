@@ -0,0 +1,141 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned by Get for a key nothing was stored under
+var ErrNotFound = errors.New("blob not found")
+
+// Store keeps generated files, such as rendered exports, by key. Keys are slash-separated paths
+// whose segments use only a-z, 0-9, '.', '_' and '-' and do not start with '.', so every backend
+// can map them to a file name or object key unchanged. Dir is the built-in backend; an
+// S3-compatible bucket implements the same two methods.
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Put(ctx context.Context, key string, data []byte) error
+}
+
+// ErrBadKey is returned for keys outside the format Store documents
+var ErrBadKey = errors.New("invalid blob key")
+
+// ValidKey reports whether key follows the Store key format
+func ValidKey(key string) bool {
+	if key == "" { return false }
+	for _, seg := range strings.Split(key, "/") {
+		if seg == "" || seg[0] == '.' { return false }
+		for _, r := range seg {
+			if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '.' || r == '_' || r == '-') { return false }
+		}
+	}
+	return true
+}
+
+// Dir stores each blob as a file under Root. Nothing is removed unless Sweep runs (see
+// RunSweeper) with MaxAge or MaxBytes set; a file's modification time is when it was last written
+// or read.
+type Dir struct {
+	Root     string
+	MaxAge   time.Duration // Sweep removes blobs unused for longer; 0 keeps them
+	MaxBytes int64         // Sweep then removes the least recently used until the rest fit; 0 is unlimited
+}
+
+// NewDir creates root when it does not exist yet
+func NewDir(root string) (*Dir, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil { return nil, err }
+	return &Dir{Root: root}, nil
+}
+
+func (d *Dir) path(key string) (string, error) {
+	if !ValidKey(key) { return "", ErrBadKey }
+	return filepath.Join(d.Root, filepath.FromSlash(key)), nil
+}
+
+func (d *Dir) Get(ctx context.Context, key string) ([]byte, error) {
+	p, err := d.path(key)
+	if err != nil { return nil, err }
+	b, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) { return nil, ErrNotFound }
+	if err == nil {
+		now := time.Now()
+		_ = os.Chtimes(p, now, now) // best effort: only makes Sweep keep what is still read
+	}
+	return b, err
+}
+
+// Put writes to a temporary file and renames it into place, so a concurrent Get sees either
+// nothing or the whole blob
+func (d *Dir) Put(ctx context.Context, key string, data []byte) error {
+	p, err := d.path(key)
+	if err != nil { return err }
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil { return err }
+	f, err := os.CreateTemp(filepath.Dir(p), ".tmp-*")
+	if err != nil { return err }
+	if _, err := f.Write(data); err != nil { f.Close(); os.Remove(f.Name()); return err }
+	if err := f.Close(); err != nil { os.Remove(f.Name()); return err }
+	if err := os.Rename(f.Name(), p); err != nil { os.Remove(f.Name()); return err }
+	return nil
+}
+
+// Sweep removes the blobs older than MaxAge, then the least recently used ones while the rest
+// take more than MaxBytes, and returns how many files it removed. Temporary files of a Put still
+// in progress are only removed by age.
+func (d *Dir) Sweep() (int, error) {
+	if d.MaxAge <= 0 && d.MaxBytes <= 0 { return 0, nil }
+	type blob struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var blobs []blob
+	var total int64
+	removed := 0
+	now := time.Now()
+	err := filepath.WalkDir(d.Root, func(p string, e fs.DirEntry, err error) error {
+		if err != nil || e.IsDir() { return err }
+		info, err := e.Info()
+		if errors.Is(err, os.ErrNotExist) { return nil } // removed since the walk listed it
+		if err != nil { return err }
+		if d.MaxAge > 0 && now.Sub(info.ModTime()) > d.MaxAge {
+			if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) { return err }
+			removed++
+			return nil
+		}
+		if strings.HasPrefix(e.Name(), ".tmp-") { return nil }
+		blobs = append(blobs, blob{p, info.Size(), info.ModTime()})
+		total += info.Size()
+		return nil
+	})
+	if err != nil || d.MaxBytes <= 0 || total <= d.MaxBytes { return removed, err }
+	sort.Slice(blobs, func(i, j int) bool { return blobs[i].modTime.Before(blobs[j].modTime) })
+	for _, b := range blobs {
+		if total <= d.MaxBytes { break }
+		if err := os.Remove(b.path); err != nil && !errors.Is(err, os.ErrNotExist) { return removed, err }
+		total -= b.size
+		removed++
+	}
+	return removed, nil
+}
+
+// RunSweeper calls Sweep every interval until stop is closed
+func (d *Dir) RunSweeper(interval time.Duration, stop <-chan struct{}) {
+	if interval <= 0 || d.MaxAge <= 0 && d.MaxBytes <= 0 { return }
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if _, err := d.Sweep(); err != nil { log.Printf("Warning: blob store sweep %s: %v", d.Root, err) }
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestValidKey(t *testing.T) {
+	for key, want := range map[string]bool{
+		"exports/ab12.png": true, "a": true, "a-b_c.d/e": true,
+		"": false, "/a": false, "a/": false, "a//b": false, "../a": false, "a/.hidden": false, "A.png": false, `a\b`: false,
+	} {
+		if got := ValidKey(key); got != want {
+			t.Fatalf("ValidKey(%q): expected %v, got %v", key, want, got)
+		}
+	}
+}
+
+func TestDir_PutThenGet(t *testing.T) {
+	ctx := context.Background()
+	d, err := NewDir(filepath.Join(t.TempDir(), "blobs"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	if _, err := d.Get(ctx, "exports/x.png"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound before Put, got %v", err)
+	}
+	if err := d.Put(ctx, "exports/x.png", []byte("one")); err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	if err := d.Put(ctx, "exports/x.png", []byte("two")); err != nil {
+		t.Fatalf("Failed to overwrite: %v", err)
+	}
+	if b, err := d.Get(ctx, "exports/x.png"); err != nil || string(b) != "two" {
+		t.Fatalf("Expected the latest blob, got %q (%v)", b, err)
+	}
+	entries, _ := os.ReadDir(filepath.Join(d.Root, "exports"))
+	if len(entries) != 1 {
+		t.Fatalf("Expected no temporary files left behind, got %v", entries)
+	}
+	if err := d.Put(ctx, "../escape", nil); !errors.Is(err, ErrBadKey) {
+		t.Fatalf("Expected a key outside the format to be refused, got %v", err)
+	}
+}
+
+// putAged stores data under key and backdates it by age
+func putAged(t *testing.T, d *Dir, key, data string, age time.Duration) {
+	t.Helper()
+	if err := d.Put(context.Background(), key, []byte(data)); err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	at := time.Now().Add(-age)
+	if err := os.Chtimes(filepath.Join(d.Root, filepath.FromSlash(key)), at, at); err != nil {
+		t.Fatalf("Failed to backdate: %v", err)
+	}
+}
+
+func TestDir_SweepByAgeAndSize(t *testing.T) {
+	ctx := context.Background()
+	d, err := NewDir(filepath.Join(t.TempDir(), "blobs"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	putAged(t, d, "exports/stale.png", "xxxx", 48*time.Hour)
+	putAged(t, d, "exports/old.png", "xxxx", 3*time.Hour)
+	putAged(t, d, "exports/read.png", "xxxx", 2*time.Hour)
+	putAged(t, d, "exports/new.png", "xxxx", time.Hour)
+	if n, err := d.Sweep(); err != nil || n != 0 {
+		t.Fatalf("Expected nothing removed without limits, got %d, %v", n, err)
+	}
+
+	// Reading a blob makes it the most recently used
+	if _, err := d.Get(ctx, "exports/read.png"); err != nil {
+		t.Fatalf("Failed to get: %v", err)
+	}
+	d.MaxAge, d.MaxBytes = 24*time.Hour, 8
+	if n, err := d.Sweep(); err != nil || n != 2 {
+		t.Fatalf("Expected the stale and the least recently used blob removed, got %d, %v", n, err)
+	}
+	for key, want := range map[string]bool{"exports/stale.png": false, "exports/old.png": false, "exports/read.png": true, "exports/new.png": true} {
+		if _, err := d.Get(ctx, key); (err == nil) != want {
+			t.Fatalf("%s: expected present=%v, got %v", key, want, err)
+		}
+	}
+}
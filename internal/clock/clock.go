@@ -0,0 +1,97 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the source of time for code that needs deterministic tests.
+// Network deadlines still use the real clock since the OS enforces them.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Real is the wall clock
+type Real struct{}
+
+func (Real) Now() time.Time                   { return time.Now() }
+func (Real) NewTicker(d time.Duration) Ticker { return realTicker{time.NewTicker(d)} }
+
+type realTicker struct{ t *time.Ticker }
+
+func (r realTicker) C() <-chan time.Time { return r.t.C }
+func (r realTicker) Stop()               { r.t.Stop() }
+
+// Or returns c, or the real clock when c is nil
+func Or(c Clock) Clock {
+	if c == nil { return Real{} }
+	return c
+}
+
+// Fake is a manually advanced clock for tests
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+func NewFake(now time.Time) *Fake { return &Fake{now: now} }
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := &fakeTicker{clock: f, period: d, next: f.now.Add(d), c: make(chan time.Time, 1)}
+	f.tickers = append(f.tickers, t)
+	return t
+}
+
+// Advance moves time forward by d, firing every ticker that comes due. Like time.Ticker,
+// a tick is dropped if the previous one has not been received yet.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	for _, t := range f.tickers {
+		for !t.next.After(f.now) {
+			select { case t.c <- t.next: default: }
+			t.next = t.next.Add(t.period)
+		}
+	}
+}
+
+// Tickers reports how many tickers are running, so tests can wait for one to be created
+func (f *Fake) Tickers() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.tickers)
+}
+
+type fakeTicker struct {
+	clock  *Fake
+	period time.Duration
+	next   time.Time
+	c      chan time.Time
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.c }
+
+func (t *fakeTicker) Stop() {
+	f := t.clock
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, other := range f.tickers {
+		if other == t { f.tickers = append(f.tickers[:i], f.tickers[i+1:]...); return }
+	}
+}
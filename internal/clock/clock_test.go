@@ -0,0 +1,61 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake_AdvanceFiresTicker(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+	tk := f.NewTicker(10 * time.Second)
+
+	f.Advance(9 * time.Second)
+	select {
+	case <-tk.C():
+		t.Fatal("Ticker should not fire before its period")
+	default:
+	}
+
+	f.Advance(time.Second)
+	select {
+	case at := <-tk.C():
+		if !at.Equal(start.Add(10 * time.Second)) {
+			t.Fatalf("Unexpected tick time %v", at)
+		}
+	default:
+		t.Fatal("Ticker should fire after its period")
+	}
+
+	if !f.Now().Equal(start.Add(10 * time.Second)) {
+		t.Fatalf("Unexpected Now %v", f.Now())
+	}
+}
+
+func TestFake_StopRemovesTicker(t *testing.T) {
+	f := NewFake(time.Unix(0, 0))
+	tk := f.NewTicker(time.Second)
+	if f.Tickers() != 1 {
+		t.Fatal("Expected 1 ticker")
+	}
+	tk.Stop()
+	if f.Tickers() != 0 {
+		t.Fatal("Expected ticker to be removed")
+	}
+	f.Advance(time.Minute)
+	select {
+	case <-tk.C():
+		t.Fatal("Stopped ticker should not fire")
+	default:
+	}
+}
+
+func TestOr(t *testing.T) {
+	if _, ok := Or(nil).(Real); !ok {
+		t.Fatal("Or(nil) should return the real clock")
+	}
+	f := NewFake(time.Unix(0, 0))
+	if Or(f) != Clock(f) {
+		t.Fatal("Or should return a non-nil clock unchanged")
+	}
+}
@@ -0,0 +1,76 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/deliium/drawing-board/internal/validate"
+)
+
+// MaxCanvasSize bounds a board's stored width and height, in pixels
+const MaxCanvasSize = 4096
+
+// DefaultBackground is the canvas color of a board without settings
+const DefaultBackground = "#ffffff"
+
+// BoardSettings is the canvas a board is drawn on; exports and thumbnails use it when the caller
+// gives no size of its own
+type BoardSettings struct {
+	Width      int
+	Height     int
+	Background string
+}
+
+// NormalizeBoardSettings applies the same color rules as NormalizeStroke, an empty background
+// becoming DefaultBackground, and reports sizes outside [1, MaxCanvasSize]
+func NormalizeBoardSettings(bs *BoardSettings) validate.Errors {
+	var errs validate.Errors
+	errs.Check(bs.Width >= 1 && bs.Width <= MaxCanvasSize, "width", fmt.Sprintf("must be between 1 and %d", MaxCanvasSize))
+	errs.Check(bs.Height >= 1 && bs.Height <= MaxCanvasSize, "height", fmt.Sprintf("must be between 1 and %d", MaxCanvasSize))
+	if bs.Background == "" { bs.Background = DefaultBackground }
+	var ok bool
+	if bs.Background, ok = normalizeColor(bs.Background); !ok { errs.Add("background", "must be a #rgb or #rrggbb color") }
+	return errs
+}
+
+// GetBoardSettings returns nil, nil when the board has none saved
+func (s *Store) GetBoardSettings(boardID int64) (*BoardSettings, error) {
+	return s.GetBoardSettingsContext(context.Background(), boardID)
+}
+
+func (s *Store) GetBoardSettingsContext(ctx context.Context, boardID int64) (*BoardSettings, error) {
+	bs := BoardSettings{}
+	err := s.SQL.QueryRowContext(ctx, "SELECT width, height, background FROM board_settings WHERE board_id = ?", boardID).Scan(&bs.Width, &bs.Height, &bs.Background)
+	if errors.Is(err, sql.ErrNoRows) { return nil, nil }
+	if err != nil { return nil, err }
+	return &bs, nil
+}
+
+// SetBoardSettings replaces the board's settings; callers normalize them first
+func (s *Store) SetBoardSettings(boardID int64, bs BoardSettings) error {
+	return s.SetBoardSettingsContext(context.Background(), boardID, bs)
+}
+
+func (s *Store) SetBoardSettingsContext(ctx context.Context, boardID int64, bs BoardSettings) error {
+	release, err := s.lockWriteContext(ctx)
+	if err != nil { return err }
+	defer release()
+	_, err = s.SQL.ExecContext(ctx, `INSERT INTO board_settings(board_id, width, height, background) VALUES(?, ?, ?, ?)
+		ON CONFLICT(board_id) DO UPDATE SET width = excluded.width, height = excluded.height, background = excluded.background`,
+		boardID, bs.Width, bs.Height, bs.Background)
+	return wrapErr(err)
+}
+
+// DefaultBoardID returns the board userID started with, where strokes without a board land; 0
+// when the user has no boards
+func (s *Store) DefaultBoardID(userID int64) (int64, error) {
+	return s.DefaultBoardIDContext(context.Background(), userID)
+}
+
+func (s *Store) DefaultBoardIDContext(ctx context.Context, userID int64) (int64, error) {
+	var id int64
+	err := s.SQL.QueryRowContext(ctx, "SELECT COALESCE(MIN(id), 0) FROM boards WHERE owner_id = ?", userID).Scan(&id)
+	return id, err
+}
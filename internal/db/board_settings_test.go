@@ -0,0 +1,44 @@
+package db
+
+import "testing"
+
+func TestBoardSettings_RoundTrip(t *testing.T) {
+	store, alice, _ := openImportStore(t)
+	board, err := store.DefaultBoardID(alice)
+	if err != nil || board == 0 {
+		t.Fatalf("Expected alice to have a default board, got %d (%v)", board, err)
+	}
+	if bs, err := store.GetBoardSettings(board); err != nil || bs != nil {
+		t.Fatalf("Expected no settings on a new board, got %+v (%v)", bs, err)
+	}
+	if err := store.SetBoardSettings(board, BoardSettings{Width: 800, Height: 600, Background: "#fafafa"}); err != nil {
+		t.Fatalf("Failed to save settings: %v", err)
+	}
+	if err := store.SetBoardSettings(board, BoardSettings{Width: 1024, Height: 768, Background: "#000000"}); err != nil {
+		t.Fatalf("Failed to replace settings: %v", err)
+	}
+	bs, err := store.GetBoardSettings(board)
+	if err != nil || bs == nil || *bs != (BoardSettings{Width: 1024, Height: 768, Background: "#000000"}) {
+		t.Fatalf("Expected the replaced settings back, got %+v (%v)", bs, err)
+	}
+	other, _ := store.CreateBoard(alice, "Second")
+	if bs, _ := store.GetBoardSettings(other); bs != nil {
+		t.Fatalf("Expected settings to stay on their board, got %+v", bs)
+	}
+}
+
+func TestNormalizeBoardSettings(t *testing.T) {
+	bs := BoardSettings{Width: 640, Height: 480, Background: " #ABC "}
+	if errs := NormalizeBoardSettings(&bs); errs.Any() || bs.Background != "#aabbcc" {
+		t.Fatalf("Expected #ABC to normalize to #aabbcc, got %+v (%v)", bs, errs)
+	}
+	bs = BoardSettings{Width: 640, Height: 480}
+	if errs := NormalizeBoardSettings(&bs); errs.Any() || bs.Background != DefaultBackground {
+		t.Fatalf("Expected an empty background to default, got %+v (%v)", bs, errs)
+	}
+	bs = BoardSettings{Width: 0, Height: MaxCanvasSize + 1, Background: "white"}
+	errs := NormalizeBoardSettings(&bs)
+	if len(errs) != 3 || errs[0].Field != "width" || errs[1].Field != "height" || errs[2].Field != "background" {
+		t.Fatalf("Expected width, height and background errors, got %v", errs)
+	}
+}
@@ -0,0 +1,104 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// DefaultBoardName is given to the board every user starts with
+const DefaultBoardName = "My board"
+
+// MaxBoardNameLength caps board names, in runes; handlers reject longer names
+const MaxBoardNameLength = 80
+
+// Board is a separate canvas; every stroke belongs to exactly one
+type Board struct {
+	ID int64
+	OwnerID int64
+	Name string
+	CreatedAt time.Time
+}
+
+// CreateBoard adds an empty board owned by ownerID
+func (s *Store) CreateBoard(ownerID int64, name string) (int64, error) {
+	return s.CreateBoardContext(context.Background(), ownerID, name)
+}
+
+func (s *Store) CreateBoardContext(ctx context.Context, ownerID int64, name string) (int64, error) {
+	release, err := s.lockWriteContext(ctx)
+	if err != nil { return 0, err }
+	defer release()
+	res, err := s.SQL.ExecContext(ctx, "INSERT INTO boards(owner_id, name) VALUES(?, ?)", ownerID, name)
+	if err != nil { return 0, wrapErr(err) }
+	return res.LastInsertId()
+}
+
+// RenameBoard changes a board's name
+func (s *Store) RenameBoard(id int64, name string) error {
+	return s.RenameBoardContext(context.Background(), id, name)
+}
+
+func (s *Store) RenameBoardContext(ctx context.Context, id int64, name string) error {
+	return s.execWrite(ctx, "UPDATE boards SET name = ? WHERE id = ?", name, id)
+}
+
+// GetBoard returns nil, nil when the board does not exist
+func (s *Store) GetBoard(id int64) (*Board, error) { return s.GetBoardContext(context.Background(), id) }
+
+func (s *Store) GetBoardContext(ctx context.Context, id int64) (*Board, error) {
+	b := Board{}
+	err := s.SQL.QueryRowContext(ctx, "SELECT id, owner_id, name, created_at FROM boards WHERE id = ?", id).Scan(&b.ID, &b.OwnerID, &b.Name, &b.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) { return nil, nil }
+	if err != nil { return nil, err }
+	return &b, nil
+}
+
+// ListBoardsByUser returns userID's boards in creation order, so the default board comes first
+func (s *Store) ListBoardsByUser(userID int64) ([]Board, error) {
+	return s.ListBoardsByUserContext(context.Background(), userID)
+}
+
+func (s *Store) ListBoardsByUserContext(ctx context.Context, userID int64) ([]Board, error) {
+	rows, err := s.SQL.QueryContext(ctx, "SELECT id, owner_id, name, created_at FROM boards WHERE owner_id = ? ORDER BY id", userID)
+	if err != nil { return nil, err }
+	defer rows.Close()
+	var out []Board
+	for rows.Next() {
+		var b Board
+		if err := rows.Scan(&b.ID, &b.OwnerID, &b.Name, &b.CreatedAt); err != nil { return nil, err }
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
+
+// StrokeBoardID returns the board a stroke is on, 0 for a stroke saved before boards existed;
+// ErrNotFound when the stroke does not exist
+func (s *Store) StrokeBoardID(strokeID int64) (int64, error) {
+	return s.StrokeBoardIDContext(context.Background(), strokeID)
+}
+
+func (s *Store) StrokeBoardIDContext(ctx context.Context, strokeID int64) (int64, error) {
+	var id int64
+	err := s.SQL.QueryRowContext(ctx, "SELECT COALESCE(board_id, 0) FROM strokes WHERE id = ?", strokeID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) { return 0, ErrNotFound }
+	return id, err
+}
+
+func (s *Store) ListStrokesByBoard(boardID int64) ([]Stroke, error) {
+	return s.ListStrokesByBoardPaged(boardID, 0, 0)
+}
+
+func (s *Store) ListStrokesByBoardContext(ctx context.Context, boardID int64) ([]Stroke, error) {
+	return s.ListStrokesByBoardPagedContext(ctx, boardID, 0, 0)
+}
+
+// ListStrokesByBoardPaged pages like ListStrokesByUserPaged; it does not check who is asking
+func (s *Store) ListStrokesByBoardPaged(boardID int64, limit int, afterID int64) ([]Stroke, error) {
+	return s.ListStrokesByBoardPagedContext(context.Background(), boardID, limit, afterID)
+}
+
+func (s *Store) ListStrokesByBoardPagedContext(ctx context.Context, boardID int64, limit int, afterID int64) ([]Stroke, error) {
+	return s.listStrokes(ctx, "board_id", boardID, limit, afterID)
+}
@@ -0,0 +1,108 @@
+package db
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestBoards_CreateAndList(t *testing.T) {
+	store, alice, bob := openImportStore(t)
+	boards, err := store.ListBoardsByUser(alice)
+	if err != nil || len(boards) != 1 || boards[0].Name != DefaultBoardName {
+		t.Fatalf("Expected a new user to start with the default board, got %+v (%v)", boards, err)
+	}
+	id, err := store.CreateBoard(alice, "Kanji practice")
+	if err != nil {
+		t.Fatalf("Failed to create board: %v", err)
+	}
+	boards, _ = store.ListBoardsByUser(alice)
+	if len(boards) != 2 || boards[1].ID != id || boards[1].Name != "Kanji practice" || boards[1].OwnerID != alice {
+		t.Fatalf("Expected the new board after the default one, got %+v", boards)
+	}
+	if b, err := store.GetBoard(id); err != nil || b == nil || b.OwnerID != alice {
+		t.Fatalf("Expected GetBoard to find alice's board, got %+v (%v)", b, err)
+	}
+	if b, err := store.GetBoard(id + 100); err != nil || b != nil {
+		t.Fatalf("Expected nil for a missing board, got %+v (%v)", b, err)
+	}
+	if boards, _ := store.ListBoardsByUser(bob); len(boards) != 1 {
+		t.Fatalf("Expected bob's boards to be his own, got %+v", boards)
+	}
+}
+
+func TestBoards_StrokesLandOnTheirBoard(t *testing.T) {
+	store, alice, _ := openImportStore(t)
+	boards, _ := store.ListBoardsByUser(alice)
+	def := boards[0].ID
+	other, err := store.CreateBoard(alice, "Second")
+	if err != nil {
+		t.Fatalf("Failed to create board: %v", err)
+	}
+	first, _ := store.SaveStrokeRecord(alice, importStroke(0))
+	st := importStroke(0)
+	st.BoardID = other
+	second, _ := store.SaveStrokeRecord(alice, st)
+
+	onDefault, err := store.ListStrokesByBoard(def)
+	if err != nil || len(onDefault) != 1 || onDefault[0].ID != first || onDefault[0].BoardID != def {
+		t.Fatalf("Expected an unplaced stroke on the default board, got %+v (%v)", onDefault, err)
+	}
+	onOther, _ := store.ListStrokesByBoard(other)
+	if len(onOther) != 1 || onOther[0].ID != second || onOther[0].UserID != alice {
+		t.Fatalf("Expected the second stroke on the new board, got %+v", onOther)
+	}
+	if all, _ := store.ListStrokesByUser(alice); len(all) != 2 {
+		t.Fatalf("Expected ListStrokesByUser to span boards, got %d strokes", len(all))
+	}
+}
+
+func TestMigrate_BackfillsDefaultBoards(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "boards.db")
+	old, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	// Schema as of version 6: one implicit canvas per user
+	for _, m := range migrations[:6] {
+		if err := m.up(old); err != nil {
+			t.Fatalf("Failed to build old schema: %v", err)
+		}
+	}
+	for _, q := range []string{
+		"INSERT INTO users(id, email, password_hash) VALUES(7, 'old@example.com', 'hash'), (8, 'empty@example.com', 'hash')",
+		"INSERT INTO strokes(user_id, color, width, started_at_unix_ms, created_by) VALUES(7, '#000000', 2, 0, 7), (7, '#ff0000', 2, 0, 7)",
+	} {
+		if _, err := old.Exec(q); err != nil {
+			t.Fatalf("Failed to seed old data: %v", err)
+		}
+	}
+	old.Close()
+
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer store.SQL.Close()
+	for _, uid := range []int64{7, 8} {
+		if boards, err := store.ListBoardsByUser(uid); err != nil || len(boards) != 1 || boards[0].Name != DefaultBoardName {
+			t.Fatalf("Expected user %d to get a default board, got %+v (%v)", uid, boards, err)
+		}
+	}
+	boards, _ := store.ListBoardsByUser(7)
+	strokes, err := store.ListStrokesByBoard(boards[0].ID)
+	if err != nil || len(strokes) != 2 {
+		t.Fatalf("Expected both old strokes on the default board, got %d (%v)", len(strokes), err)
+	}
+}
+
+func TestRenameBoard(t *testing.T) {
+	store, alice, _ := openImportStore(t)
+	boards, _ := store.ListBoardsByUser(alice)
+	if err := store.RenameBoard(boards[0].ID, "Sketches"); err != nil {
+		t.Fatalf("Failed to rename board: %v", err)
+	}
+	if b, err := store.GetBoard(boards[0].ID); err != nil || b == nil || b.Name != "Sketches" {
+		t.Fatalf("Expected the board to be renamed, got %+v (%v)", b, err)
+	}
+}
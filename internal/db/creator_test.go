@@ -0,0 +1,56 @@
+package db
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStrokeCreator_DefaultsToOwner(t *testing.T) {
+	store, alice, _ := openImportStore(t)
+	if _, err := store.SaveStrokeRecord(alice, importStroke(0)); err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+	strokes, _ := store.ListStrokesByUser(alice)
+	if len(strokes) != 1 || strokes[0].CreatedBy != alice {
+		t.Fatalf("Expected the owner as creator, got %+v", strokes)
+	}
+}
+
+func TestStrokeCreator_OnlyCreatorOrOwnerMayModify(t *testing.T) {
+	store, owner, creator := openImportStore(t)
+	other, err := store.CreateUser("carol@example.com", "hash")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	// creator drew on owner's board
+	st := importStroke(0)
+	st.CreatedBy = creator
+	id, err := store.SaveStrokeRecord(owner, st)
+	if err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+
+	if err := store.DeleteStroke(other, id); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected a non-creator, non-owner delete to be ErrNotFound, got %v", err)
+	}
+	if found, err := store.SetStrokeNote(other, id, "mine now"); err != nil || found {
+		t.Fatalf("Expected a non-creator, non-owner note edit to be refused, got %v, %v", found, err)
+	}
+	if strokes, _ := store.ListStrokesByUser(owner); len(strokes) != 1 || strokes[0].Note != "" {
+		t.Fatalf("Expected the stroke untouched, got %+v", strokes)
+	}
+	if found, err := store.SetStrokeNote(creator, id, "by me"); err != nil || !found {
+		t.Fatalf("Creator should be able to annotate, got %v, %v", found, err)
+	}
+	if err := store.DeleteStroke(creator, id); err != nil {
+		t.Fatalf("Creator should be able to delete: %v", err)
+	}
+
+	id, err = store.SaveStrokeRecord(owner, st)
+	if err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+	if err := store.DeleteStroke(owner, id); err != nil {
+		t.Fatalf("Owner should be able to delete another member's stroke: %v", err)
+	}
+}
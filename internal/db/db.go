@@ -1,24 +1,46 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 )
 
+// Store methods that touch the database have a ...Context variant that abandons the query, or
+// the wait for a write slot, with ctx.Err() once ctx ends; the plain form uses context.Background.
+// Migrate is the exception: a migration that has started runs to completion.
 type Store struct {
 	SQL *sql.DB
+	writes chan struct{} // write slots, see SetMaxWriters; nil is unlimited
+	simplifyEpsilon float64 // see SetSimplifyEpsilon; 0 stores points as given
 }
 
 type User struct {
 	ID int64
 	Email string
 	PasswordHash string
+	Public bool // opted into the public gallery profile
+	DisplayName string // chosen name; empty falls back to the email local-part
 	CreatedAt time.Time
 }
 
+// PublicName is how the user is shown to others; never the full email address
+func (u *User) PublicName() string {
+	if u.DisplayName != "" { return u.DisplayName }
+	return DefaultDisplayName(u.Email)
+}
+
+// DefaultDisplayName derives a name from the local-part of an email address
+func DefaultDisplayName(email string) string {
+	local, _, _ := strings.Cut(email, "@")
+	return local
+}
+
 type StrokePoint struct { X float64; Y float64 }
 
 type Stroke struct {
@@ -28,122 +50,370 @@ type Stroke struct {
 	Width int
 	StartedAtUnixMs int64
 	Points []StrokePoint
+	Note string
+	ClientID string
+	CreatedBy int64 // member who drew it; the owner (UserID) unless it came from someone else, 0 means UserID on insert
+	BoardID int64 // board it is drawn on; 0 means the owner's default board on insert
+	LineStyle string // LineSolid, LineDashed or LineDotted; "" is stored as LineSolid
 	CreatedAt time.Time
+	AuthorName string // PublicName of CreatedBy, filled in by listings; "" when that user no longer exists
 }
 
+// Line styles a stroke can be drawn with
+const (
+	LineSolid  = "solid"
+	LineDashed = "dashed"
+	LineDotted = "dotted"
+)
+
+// Open connects to path and applies every pending migration
 func Open(path string) (*Store, error) {
-	db, err := sql.Open("sqlite3", path)
+	s, err := OpenWithoutMigrations(path)
+	if err != nil { return nil, err }
+	if _, err := s.Migrate(); err != nil { s.SQL.Close(); return nil, err }
+	return s, nil
+}
+
+// OpenWithoutMigrations connects to path but leaves the schema for a later Migrate call
+func OpenWithoutMigrations(path string) (*Store, error) {
+	db, err := sql.Open(driverName, path)
 	if err != nil { return nil, err }
 	db.SetMaxOpenConns(4)
 	db.SetMaxIdleConns(4)
 	if _, err := db.Exec("PRAGMA journal_mode=WAL;"); err != nil { return nil, err }
 	if _, err := db.Exec("PRAGMA busy_timeout=5000;"); err != nil { return nil, err }
-	if err := migrate(db); err != nil { return nil, err }
-	return &Store{SQL: db}, nil
-}
-
-func migrate(db *sql.DB) error {
-	_, err := db.Exec(`
-	CREATE TABLE IF NOT EXISTS users (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		email TEXT NOT NULL UNIQUE,
-		password_hash TEXT NOT NULL,
-		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
-	);
-	CREATE TABLE IF NOT EXISTS strokes (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-		color TEXT NOT NULL,
-		width INTEGER NOT NULL,
-		started_at_unix_ms INTEGER NOT NULL,
-		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
-	);
-	CREATE TABLE IF NOT EXISTS stroke_points (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		stroke_id INTEGER NOT NULL REFERENCES strokes(id) ON DELETE CASCADE,
-		x REAL NOT NULL,
-		y REAL NOT NULL
-	);
-	CREATE INDEX IF NOT EXISTS idx_strokes_user ON strokes(user_id);
-	CREATE INDEX IF NOT EXISTS idx_stroke_points_stroke ON stroke_points(stroke_id);
-	`)
-	return err
+	if err := checkCascades(db); err != nil { db.Close(); return nil, err }
+	s := &Store{SQL: db}
+	s.SetMaxWriters(DefaultMaxWriters)
+	return s, nil
+}
+
+// driverName is go-sqlite3 with PRAGMA foreign_keys=ON run on every connection it opens. SQLite
+// enforces foreign keys per connection and only when asked, and the ON DELETE CASCADE clauses that
+// clean up after deleted users, boards and strokes depend on it; a PRAGMA run once on the pool
+// would reach only one connection, and the DSN's _fk is easy to leave out.
+const driverName = "sqlite3_fk"
+
+func init() {
+	sql.Register(driverName, &sqlite3.SQLiteDriver{ConnectHook: func(c *sqlite3.SQLiteConn) error {
+		_, err := c.Exec("PRAGMA foreign_keys=ON;", nil)
+		return err
+	}})
 }
 
+// checkCascades deletes a throwaway parent row in temp tables and confirms its child went with
+// it, so a driver that ignores foreign keys fails at startup instead of leaving orphaned strokes.
+// It runs in a transaction that is rolled back, which also drops the tables.
+func checkCascades(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil { return err }
+	defer tx.Rollback()
+	if _, err := tx.Exec(`
+		CREATE TEMP TABLE fk_check_parent (id INTEGER PRIMARY KEY);
+		CREATE TEMP TABLE fk_check_child (parent_id INTEGER NOT NULL REFERENCES fk_check_parent(id) ON DELETE CASCADE);
+		INSERT INTO fk_check_parent(id) VALUES(1);
+		INSERT INTO fk_check_child(parent_id) VALUES(1);
+		DELETE FROM fk_check_parent WHERE id = 1;
+	`); err != nil { return fmt.Errorf("foreign key self-check: %w", err) }
+	var left int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM fk_check_child").Scan(&left); err != nil { return fmt.Errorf("foreign key self-check: %w", err) }
+	if left != 0 { return errors.New("foreign key self-check: ON DELETE CASCADE did not fire, so SQLite is not enforcing foreign keys; deleted users would leave their strokes behind") }
+	return nil
+}
+
+// Close closes the database; call it once nothing else uses the store
+func (s *Store) Close() error { return s.SQL.Close() }
+
+// CreateUser inserts a user together with their default board; an email that is already
+// registered fails with ErrDuplicate
 func (s *Store) CreateUser(email, passwordHash string) (int64, error) {
-	res, err := s.SQL.Exec("INSERT INTO users(email, password_hash) VALUES(?, ?)", email, passwordHash)
+	return s.CreateUserContext(context.Background(), email, passwordHash)
+}
+
+func (s *Store) CreateUserContext(ctx context.Context, email, passwordHash string) (int64, error) {
+	release, err := s.lockWriteContext(ctx)
+	if err != nil { return 0, err }
+	defer release()
+	tx, err := s.SQL.BeginTx(ctx, nil)
 	if err != nil { return 0, err }
-	return res.LastInsertId()
+	res, err := tx.ExecContext(ctx, "INSERT INTO users(email, password_hash) VALUES(?, ?)", email, passwordHash)
+	if err != nil { _ = tx.Rollback(); return 0, wrapErr(err) }
+	id, err := res.LastInsertId()
+	if err != nil { _ = tx.Rollback(); return 0, err }
+	if _, err := tx.ExecContext(ctx, "INSERT INTO boards(owner_id, name) VALUES(?, ?)", id, DefaultBoardName); err != nil { _ = tx.Rollback(); return 0, err }
+	return id, tx.Commit()
 }
 
+// GetUserByEmail returns nil, nil when no user has that email
 func (s *Store) GetUserByEmail(email string) (*User, error) {
-	row := s.SQL.QueryRow("SELECT id, email, password_hash, created_at FROM users WHERE email = ?", email)
+	return s.GetUserByEmailContext(context.Background(), email)
+}
+
+func (s *Store) GetUserByEmailContext(ctx context.Context, email string) (*User, error) {
+	row := s.SQL.QueryRowContext(ctx, "SELECT id, email, password_hash, public, display_name, created_at FROM users WHERE email = ?", email)
 	u := User{}
-	if err := row.Scan(&u.ID, &u.Email, &u.PasswordHash, &u.CreatedAt); err != nil {
+	if err := row.Scan(&u.ID, &u.Email, &u.PasswordHash, &u.Public, &u.DisplayName, &u.CreatedAt); err != nil {
 		if errors.Is(err, sql.ErrNoRows) { return nil, nil }
 		return nil, err
 	}
 	return &u, nil
 }
 
+// GetUserByID returns nil, nil when the user does not exist
 func (s *Store) GetUserByID(id int64) (*User, error) {
-	row := s.SQL.QueryRow("SELECT id, email, password_hash, created_at FROM users WHERE id = ?", id)
+	return s.GetUserByIDContext(context.Background(), id)
+}
+
+func (s *Store) GetUserByIDContext(ctx context.Context, id int64) (*User, error) {
+	row := s.SQL.QueryRowContext(ctx, "SELECT id, email, password_hash, public, display_name, created_at FROM users WHERE id = ?", id)
 	u := User{}
-	if err := row.Scan(&u.ID, &u.Email, &u.PasswordHash, &u.CreatedAt); err != nil {
+	if err := row.Scan(&u.ID, &u.Email, &u.PasswordHash, &u.Public, &u.DisplayName, &u.CreatedAt); err != nil {
 		if errors.Is(err, sql.ErrNoRows) { return nil, nil }
 		return nil, err
 	}
 	return &u, nil
 }
 
+// ListUsers returns every user in ID order
+func (s *Store) ListUsers() ([]User, error) {
+	return s.ListUsersContext(context.Background())
+}
+
+func (s *Store) ListUsersContext(ctx context.Context) ([]User, error) {
+	rows, err := s.SQL.QueryContext(ctx, "SELECT id, email, password_hash, public, display_name, created_at FROM users ORDER BY id")
+	if err != nil { return nil, err }
+	defer rows.Close()
+	var out []User
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Email, &u.PasswordHash, &u.Public, &u.DisplayName, &u.CreatedAt); err != nil { return nil, err }
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
+// SetUserPublic opts a user into (or out of) the public gallery profile
+func (s *Store) SetUserPublic(id int64, public bool) error {
+	return s.SetUserPublicContext(context.Background(), id, public)
+}
+
+func (s *Store) SetUserPublicContext(ctx context.Context, id int64, public bool) error {
+	return s.execWrite(ctx, "UPDATE users SET public = ? WHERE id = ?", public, id)
+}
+
+// DeleteUser removes a user; their boards, strokes and points go with them through ON DELETE
+// CASCADE. Strokes they drew on other users' boards stay with those boards.
+func (s *Store) DeleteUser(id int64) error {
+	return s.DeleteUserContext(context.Background(), id)
+}
+
+func (s *Store) DeleteUserContext(ctx context.Context, id int64) error {
+	return s.execWrite(ctx, "DELETE FROM users WHERE id = ?", id)
+}
+
+// SetPasswordHash replaces a user's stored password hash, e.g. when upgrading its scheme; their
+// sessions are kept, unlike with ChangePasswordHash
+func (s *Store) SetPasswordHash(id int64, hash string) error {
+	return s.SetPasswordHashContext(context.Background(), id, hash)
+}
+
+func (s *Store) SetPasswordHashContext(ctx context.Context, id int64, hash string) error {
+	return s.execWrite(ctx, "UPDATE users SET password_hash = ? WHERE id = ?", hash, id)
+}
+
+// ChangePasswordHash replaces a user's password hash and advances their SessionEpoch, which ends
+// every session signed in before the change
+func (s *Store) ChangePasswordHash(id int64, hash string) error {
+	return s.ChangePasswordHashContext(context.Background(), id, hash)
+}
+
+func (s *Store) ChangePasswordHashContext(ctx context.Context, id int64, hash string) error {
+	return s.execWrite(ctx, "UPDATE users SET password_hash = ?, session_epoch = session_epoch + 1 WHERE id = ?", hash, id)
+}
+
+// SessionEpoch counts a user's password changes; a session records it at sign-in and is valid only
+// while it still matches. 0 for an unknown user.
+func (s *Store) SessionEpoch(id int64) (int64, error) {
+	return s.SessionEpochContext(context.Background(), id)
+}
+
+func (s *Store) SessionEpochContext(ctx context.Context, id int64) (int64, error) {
+	var epoch int64
+	err := s.SQL.QueryRowContext(ctx, "SELECT session_epoch FROM users WHERE id = ?", id).Scan(&epoch)
+	if errors.Is(err, sql.ErrNoRows) { return 0, nil }
+	return epoch, err
+}
+
+// MaxDisplayNameLength caps display names, in runes
+const MaxDisplayNameLength = 40
+
+// SetDisplayName stores a user's display name; "" reverts to the derived default
+func (s *Store) SetDisplayName(id int64, name string) error {
+	return s.SetDisplayNameContext(context.Background(), id, name)
+}
+
+func (s *Store) SetDisplayNameContext(ctx context.Context, id int64, name string) error {
+	return s.execWrite(ctx, "UPDATE users SET display_name = ? WHERE id = ?", name, id)
+}
+
+// execWrite runs a single-statement write under a write slot
+func (s *Store) execWrite(ctx context.Context, query string, args ...any) error {
+	release, err := s.lockWriteContext(ctx)
+	if err != nil { return err }
+	defer release()
+	_, err = s.SQL.ExecContext(ctx, query, args...)
+	return err
+}
+
 func (s *Store) SaveStroke(userID int64, color string, width int, startedAtUnixMs int64, points []StrokePoint) (int64, error) {
-	tx, err := s.SQL.Begin()
-	if err != nil { return 0, err }
-	defer func(){ if err != nil { _ = tx.Rollback() } }()
-	res, err := tx.Exec("INSERT INTO strokes(user_id, color, width, started_at_unix_ms) VALUES(?, ?, ?, ?)", userID, color, width, startedAtUnixMs)
+	return s.SaveStrokeContext(context.Background(), userID, color, width, startedAtUnixMs, points)
+}
+
+func (s *Store) SaveStrokeContext(ctx context.Context, userID int64, color string, width int, startedAtUnixMs int64, points []StrokePoint) (int64, error) {
+	return s.SaveStrokeRecordContext(ctx, userID, Stroke{Color: color, Width: width, StartedAtUnixMs: startedAtUnixMs, Points: points})
+}
+
+// SaveStrokeRecord saves st for userID with a fresh ID, keeping its client ID and note
+func (s *Store) SaveStrokeRecord(userID int64, st Stroke) (int64, error) {
+	return s.SaveStrokeRecordContext(context.Background(), userID, st)
+}
+
+func (s *Store) SaveStrokeRecordContext(ctx context.Context, userID int64, st Stroke) (int64, error) {
+	release, err := s.lockWriteContext(ctx)
 	if err != nil { return 0, err }
-	strokeID, err := res.LastInsertId()
+	defer release()
+	tx, err := s.SQL.BeginTx(ctx, nil)
 	if err != nil { return 0, err }
-	if len(points) > 0 {
-		stmt, err := tx.Prepare("INSERT INTO stroke_points(stroke_id, x, y) VALUES(?, ?, ?)")
-		if err != nil { return 0, err }
-		for _, p := range points {
-			if _, err := stmt.Exec(strokeID, p.X, p.Y); err != nil { _ = stmt.Close(); return 0, err }
-		}
-		_ = stmt.Close()
-	}
+	strokeID, err := insertStroke(ctx, tx, userID, 0, s.simplified(st))
+	if err != nil { _ = tx.Rollback(); return 0, err }
 	if err := tx.Commit(); err != nil { return 0, err }
 	return strokeID, nil
 }
 
 func (s *Store) ListStrokesByUser(userID int64) ([]Stroke, error) {
-	rows, err := s.SQL.Query("SELECT id, color, width, started_at_unix_ms, created_at FROM strokes WHERE user_id = ? ORDER BY id", userID)
+	return s.ListStrokesByUserPaged(userID, 0, 0)
+}
+
+func (s *Store) ListStrokesByUserContext(ctx context.Context, userID int64) ([]Stroke, error) {
+	return s.ListStrokesByUserPagedContext(ctx, userID, 0, 0)
+}
+
+// ListStrokesByUserPaged returns up to limit strokes with id > afterID in id order; limit <= 0 means no limit
+func (s *Store) ListStrokesByUserPaged(userID int64, limit int, afterID int64) ([]Stroke, error) {
+	return s.ListStrokesByUserPagedContext(context.Background(), userID, limit, afterID)
+}
+
+func (s *Store) ListStrokesByUserPagedContext(ctx context.Context, userID int64, limit int, afterID int64) ([]Stroke, error) {
+	return s.listStrokes(ctx, "user_id", userID, limit, afterID)
+}
+
+// listStrokes pages through the strokes whose column equals value; column is a constant, never input.
+// Points for the whole page come from one query and are grouped in memory, keeping their order.
+func (s *Store) listStrokes(ctx context.Context, column string, value int64, limit int, afterID int64) ([]Stroke, error) {
+	if limit <= 0 { limit = -1 }
+	out, err := s.scanStrokes(ctx, "SELECT "+strokeColumns+" FROM "+strokeTables+" WHERE strokes."+column+" = ? AND strokes.id > ? ORDER BY strokes.id LIMIT ?", value, afterID, limit)
+	if err != nil { return nil, err }
+	return out, s.loadPoints(ctx, column, value, out)
+}
+
+// strokeColumns are selected from strokeTables, which joins in the author for AuthorName
+const (
+	strokeColumns = "strokes.id, strokes.user_id, strokes.color, strokes.width, strokes.started_at_unix_ms, strokes.note, strokes.client_id, strokes.created_by, COALESCE(strokes.board_id, 0), strokes.line_style, strokes.created_at, COALESCE(author.email, ''), COALESCE(author.display_name, '')"
+	strokeTables  = "strokes LEFT JOIN users author ON author.id = strokes.created_by"
+)
+
+// scanStrokes runs a query selecting strokeColumns from strokeTables; the strokes come back without points
+func (s *Store) scanStrokes(ctx context.Context, query string, args ...any) ([]Stroke, error) {
+	rows, err := s.SQL.QueryContext(ctx, query, args...)
 	if err != nil { return nil, err }
 	defer rows.Close()
 	var out []Stroke
 	for rows.Next() {
 		var st Stroke
-		st.UserID = userID
-		if err := rows.Scan(&st.ID, &st.Color, &st.Width, &st.StartedAtUnixMs, &st.CreatedAt); err != nil { return nil, err }
-		pr, err := s.SQL.Query("SELECT x, y FROM stroke_points WHERE stroke_id = ? ORDER BY id", st.ID)
-		if err != nil { return nil, err }
-		for pr.Next() {
-			var x, y float64
-			if err := pr.Scan(&x, &y); err != nil { pr.Close(); return nil, err }
-			st.Points = append(st.Points, StrokePoint{X: x, Y: y})
-		}
-		pr.Close()
+		var author User
+		if err := rows.Scan(&st.ID, &st.UserID, &st.Color, &st.Width, &st.StartedAtUnixMs, &st.Note, &st.ClientID, &st.CreatedBy, &st.BoardID, &st.LineStyle, &st.CreatedAt, &author.Email, &author.DisplayName); err != nil { return nil, err }
+		if author.Email != "" { st.AuthorName = author.PublicName() }
 		out = append(out, st)
 	}
-	return out, nil
+	return out, rows.Err()
+}
+
+// loadPoints fills in the points of out, which must be every stroke whose column equals value
+// within its id range, in id order: the range bounds then select exactly their points
+func (s *Store) loadPoints(ctx context.Context, column string, value int64, out []Stroke) error {
+	if len(out) == 0 { return nil }
+	index := make(map[int64]int, len(out))
+	for i, st := range out { index[st.ID] = i }
+	pr, err := s.SQL.QueryContext(ctx, "SELECT sp.stroke_id, sp.x, sp.y FROM stroke_points sp JOIN strokes s ON s.id = sp.stroke_id WHERE s."+column+" = ? AND s.id BETWEEN ? AND ? ORDER BY sp.stroke_id, sp.id", value, out[0].ID, out[len(out)-1].ID)
+	if err != nil { return err }
+	defer pr.Close()
+	for pr.Next() {
+		var id int64
+		var p StrokePoint
+		if err := pr.Scan(&id, &p.X, &p.Y); err != nil { return err }
+		if i, ok := index[id]; ok { out[i].Points = append(out[i].Points, p) }
+	}
+	return pr.Err()
+}
+
+// ListRecentStrokesByUser returns userID's last n strokes in drawing (id) order without reading
+// the rest of the board; n <= 0 returns none
+func (s *Store) ListRecentStrokesByUser(userID int64, n int) ([]Stroke, error) {
+	return s.ListRecentStrokesByUserContext(context.Background(), userID, n)
+}
+
+func (s *Store) ListRecentStrokesByUserContext(ctx context.Context, userID int64, n int) ([]Stroke, error) {
+	if n <= 0 { return []Stroke{}, nil }
+	out, err := s.scanStrokes(ctx, "SELECT "+strokeColumns+" FROM "+strokeTables+" WHERE strokes.user_id = ? ORDER BY strokes.id DESC LIMIT ?", userID, n)
+	if err != nil { return nil, err }
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 { out[i], out[j] = out[j], out[i] }
+	return out, s.loadPoints(ctx, "user_id", userID, out)
 }
 
 func (s *Store) ClearStrokesByUser(userID int64) error {
-	_, err := s.SQL.Exec("DELETE FROM strokes WHERE user_id = ?", userID)
-	return err
+	return s.ClearStrokesByUserContext(context.Background(), userID)
+}
+
+func (s *Store) ClearStrokesByUserContext(ctx context.Context, userID int64) error {
+	return s.execWrite(ctx, "DELETE FROM strokes WHERE user_id = ?", userID)
 }
 
+// canModify restricts a stroke statement to strokes the acting user owns or created; it takes
+// the actor's ID twice
+const canModify = "(user_id = ? OR created_by = ?)"
+
+// DeleteStroke removes a stroke userID owns or created; ErrNotFound when it does not exist or
+// they may not touch it, so other members' strokes are indistinguishable from missing ones
 func (s *Store) DeleteStroke(userID int64, strokeID int64) error {
-	_, err := s.SQL.Exec("DELETE FROM strokes WHERE id = ? AND user_id = ?", strokeID, userID)
-	return err
+	return s.DeleteStrokeContext(context.Background(), userID, strokeID)
+}
+
+func (s *Store) DeleteStrokeContext(ctx context.Context, userID int64, strokeID int64) error {
+	release, err := s.lockWriteContext(ctx)
+	if err != nil { return err }
+	defer release()
+	res, err := s.SQL.ExecContext(ctx, "DELETE FROM strokes WHERE id = ? AND "+canModify, strokeID, userID, userID)
+	if err != nil { return err }
+	n, err := res.RowsAffected()
+	if err != nil { return err }
+	if n == 0 { return ErrNotFound }
+	return nil
+}
+
+// MaxNoteLength caps stroke notes, in runes; handlers reject longer notes
+const MaxNoteLength = 500
+
+// SetStrokeNote replaces the note on a stroke userID owns or created, reporting whether it was found
+func (s *Store) SetStrokeNote(userID int64, strokeID int64, note string) (bool, error) {
+	return s.SetStrokeNoteContext(context.Background(), userID, strokeID, note)
+}
+
+func (s *Store) SetStrokeNoteContext(ctx context.Context, userID int64, strokeID int64, note string) (bool, error) {
+	release, err := s.lockWriteContext(ctx)
+	if err != nil { return false, err }
+	defer release()
+	res, err := s.SQL.ExecContext(ctx, "UPDATE strokes SET note = ? WHERE id = ? AND "+canModify, note, strokeID, userID, userID)
+	if err != nil { return false, err }
+	n, err := res.RowsAffected()
+	return n > 0, err
 }
@@ -1,7 +1,13 @@
 package db
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -280,3 +286,399 @@ func TestDeleteStroke(t *testing.T) {
 		t.Fatalf("Expected 0 strokes after delete, got %d", len(strokes))
 	}
 }
+
+func TestSetStrokeNote(t *testing.T) {
+	tmpFile := "test_stroke_note.db"
+	defer os.Remove(tmpFile)
+
+	store, err := Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer store.SQL.Close()
+
+	userID, err := store.CreateUser("test@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	otherID, err := store.CreateUser("other@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	strokeID, err := store.SaveStroke(userID, "#000000", 2, 0, []StrokePoint{{X: 1, Y: 1}})
+	if err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+
+	ok, err := store.SetStrokeNote(userID, strokeID, "label A")
+	if err != nil || !ok {
+		t.Fatalf("Failed to set note: ok=%v err=%v", ok, err)
+	}
+	strokes, err := store.ListStrokesByUser(userID)
+	if err != nil {
+		t.Fatalf("Failed to list strokes: %v", err)
+	}
+	if strokes[0].Note != "label A" {
+		t.Fatalf("Expected note %q, got %q", "label A", strokes[0].Note)
+	}
+
+	if ok, _ := store.SetStrokeNote(otherID, strokeID, "hijack"); ok {
+		t.Fatal("Should not set a note on another user's stroke")
+	}
+	if ok, _ := store.SetStrokeNote(userID, strokeID+100, "missing"); ok {
+		t.Fatal("Should report a missing stroke")
+	}
+}
+
+func TestMigrate_AddsNoteToExistingDatabase(t *testing.T) {
+	tmpFile := "test_migrate_note.db"
+	defer os.Remove(tmpFile)
+
+	legacy, err := sql.Open("sqlite3", tmpFile)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if _, err := legacy.Exec(`CREATE TABLE strokes (id INTEGER PRIMARY KEY AUTOINCREMENT, user_id INTEGER NOT NULL, color TEXT NOT NULL, width INTEGER NOT NULL, started_at_unix_ms INTEGER NOT NULL, created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP);
+		INSERT INTO strokes(user_id, color, width, started_at_unix_ms) VALUES(1, '#000000', 2, 0);`); err != nil {
+		t.Fatalf("Failed to create legacy schema: %v", err)
+	}
+	legacy.Close()
+
+	store, err := Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+	defer store.SQL.Close()
+	strokes, err := store.ListStrokesByUser(1)
+	if err != nil {
+		t.Fatalf("Failed to list strokes: %v", err)
+	}
+	if len(strokes) != 1 || strokes[0].Note != "" {
+		t.Fatalf("Expected one stroke with an empty note, got %+v", strokes)
+	}
+}
+
+func TestSaveStrokeRecord_KeepsClientID(t *testing.T) {
+	tmpFile := "test_stroke_client_id.db"
+	defer os.Remove(tmpFile)
+
+	store, err := Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer store.SQL.Close()
+
+	userID, err := store.CreateUser("test@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	if _, err := store.SaveStrokeRecord(userID, Stroke{Color: "#000000", Width: 2, ClientID: "abc", Points: []StrokePoint{{X: 1, Y: 1}}}); err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+	strokes, err := store.ListStrokesByUser(userID)
+	if err != nil {
+		t.Fatalf("Failed to list strokes: %v", err)
+	}
+	if len(strokes) != 1 || strokes[0].ClientID != "abc" || len(strokes[0].Points) != 1 {
+		t.Fatalf("Expected stroke from client abc with 1 point, got %+v", strokes)
+	}
+}
+
+func TestSaveStrokeRecord_KeepsLineStyle(t *testing.T) {
+	store, alice, _ := openImportStore(t)
+	for _, style := range []string{LineDashed, LineDotted, ""} {
+		st := importStroke(0)
+		st.LineStyle = style
+		if _, err := store.SaveStrokeRecord(alice, st); err != nil {
+			t.Fatalf("Failed to save stroke: %v", err)
+		}
+	}
+	strokes, err := store.ListStrokesByUser(alice)
+	if err != nil {
+		t.Fatalf("Failed to list strokes: %v", err)
+	}
+	got := []string{}
+	for _, s := range strokes { got = append(got, s.LineStyle) }
+	if !reflect.DeepEqual(got, []string{LineDashed, LineDotted, LineSolid}) {
+		t.Fatalf("Expected dashed, dotted and solid (the default), got %v", got)
+	}
+}
+
+func TestSetUserPublic(t *testing.T) {
+	tmpFile := "test_user_public.db"
+	defer os.Remove(tmpFile)
+
+	store, err := Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer store.SQL.Close()
+
+	userID, err := store.CreateUser("artist@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	u, _ := store.GetUserByID(userID)
+	if u.Public {
+		t.Fatal("Users should be private by default")
+	}
+	if err := store.SetUserPublic(userID, true); err != nil {
+		t.Fatalf("Failed to set public: %v", err)
+	}
+	u, _ = store.GetUserByEmail("artist@example.com")
+	if !u.Public {
+		t.Fatal("Expected user to be public")
+	}
+	if got := u.PublicName(); got != "artist" {
+		t.Fatalf("Expected public name %q, got %q", "artist", got)
+	}
+}
+
+func TestSetDisplayName(t *testing.T) {
+	tmpFile := "test_display_name.db"
+	defer os.Remove(tmpFile)
+
+	store, err := Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer store.SQL.Close()
+
+	userID, err := store.CreateUser("grace@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	if err := store.SetDisplayName(userID, "Grace H."); err != nil {
+		t.Fatalf("Failed to set display name: %v", err)
+	}
+	u, _ := store.GetUserByID(userID)
+	if u.DisplayName != "Grace H." || u.PublicName() != "Grace H." {
+		t.Fatalf("Expected display name to round-trip, got %q / %q", u.DisplayName, u.PublicName())
+	}
+	if err := store.SetDisplayName(userID, ""); err != nil {
+		t.Fatalf("Failed to clear display name: %v", err)
+	}
+	u, _ = store.GetUserByEmail("grace@example.com")
+	if got := u.PublicName(); got != "grace" {
+		t.Fatalf("Expected cleared name to fall back to %q, got %q", "grace", got)
+	}
+}
+
+func TestDeleteUser_CascadesToStrokesAndPoints(t *testing.T) {
+	tmpFile := "test_delete_user.db"
+	defer os.Remove(tmpFile)
+
+	store, err := Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer store.SQL.Close()
+
+	var fk int
+	if err := store.SQL.QueryRow("PRAGMA foreign_keys").Scan(&fk); err != nil || fk != 1 {
+		t.Fatalf("Expected foreign keys to be enforced, got %d (%v)", fk, err)
+	}
+	gone, _ := store.CreateUser("gone@example.com", "password123")
+	kept, _ := store.CreateUser("kept@example.com", "password123")
+	for _, uid := range []int64{gone, gone, kept} {
+		if _, err := store.SaveStroke(uid, "#000000", 2, 0, []StrokePoint{{X: 1, Y: 2}, {X: 3, Y: 4}}); err != nil {
+			t.Fatalf("Failed to save stroke: %v", err)
+		}
+	}
+
+	if err := store.DeleteUser(gone); err != nil {
+		t.Fatalf("Failed to delete user: %v", err)
+	}
+	if u, _ := store.GetUserByID(gone); u != nil {
+		t.Fatal("Expected the user to be deleted")
+	}
+	count := func(query string, args ...any) int {
+		var n int
+		if err := store.SQL.QueryRow(query, args...).Scan(&n); err != nil {
+			t.Fatalf("Failed to count: %v", err)
+		}
+		return n
+	}
+	if n := count("SELECT COUNT(*) FROM strokes WHERE user_id = ?", gone); n != 0 {
+		t.Fatalf("Expected the user's strokes to be deleted, got %d", n)
+	}
+	if n := count("SELECT COUNT(*) FROM boards WHERE owner_id = ?", gone); n != 0 {
+		t.Fatalf("Expected the user's boards to be deleted, got %d", n)
+	}
+	if n := count("SELECT COUNT(*) FROM stroke_points"); n != 2 {
+		t.Fatalf("Expected only the other user's 2 points to remain, got %d", n)
+	}
+	if rows, _ := store.ListStrokesByUser(kept); len(rows) != 1 {
+		t.Fatalf("Expected the other user's stroke to remain, got %d", len(rows))
+	}
+}
+
+func TestOpen_EnforcesForeignKeysWhateverTheDSN(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "fk.db") + "?_fk=0")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer store.SQL.Close()
+	var fk int
+	if err := store.SQL.QueryRow("PRAGMA foreign_keys").Scan(&fk); err != nil || fk != 1 {
+		t.Fatalf("Expected foreign keys to be enforced, got %d (%v)", fk, err)
+	}
+}
+
+func TestCheckCascades_FailsWithoutForeignKeys(t *testing.T) {
+	plain, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "plain.db")+"?_fk=0")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer plain.Close()
+	if err := checkCascades(plain); err == nil || !strings.Contains(err.Error(), "not enforcing foreign keys") {
+		t.Fatalf("Expected the self-check to fail, got %v", err)
+	}
+}
+
+// listStrokesPerStroke is the original listStrokes, one points query per stroke, kept as the
+// reference the batched query must match
+func listStrokesPerStroke(s *Store, column string, value int64, limit int, afterID int64) ([]Stroke, error) {
+	if limit <= 0 { limit = -1 }
+	rows, err := s.SQL.Query("SELECT id, user_id, color, width, started_at_unix_ms, note, client_id, created_by, COALESCE(board_id, 0), line_style, created_at FROM strokes WHERE "+column+" = ? AND id > ? ORDER BY id LIMIT ?", value, afterID, limit)
+	if err != nil { return nil, err }
+	defer rows.Close()
+	var out []Stroke
+	for rows.Next() {
+		var st Stroke
+		if err := rows.Scan(&st.ID, &st.UserID, &st.Color, &st.Width, &st.StartedAtUnixMs, &st.Note, &st.ClientID, &st.CreatedBy, &st.BoardID, &st.LineStyle, &st.CreatedAt); err != nil { return nil, err }
+		author, err := s.GetUserByID(st.CreatedBy)
+		if err != nil { return nil, err }
+		if author != nil { st.AuthorName = author.PublicName() }
+		pr, err := s.SQL.Query("SELECT x, y FROM stroke_points WHERE stroke_id = ? ORDER BY id", st.ID)
+		if err != nil { return nil, err }
+		for pr.Next() {
+			var x, y float64
+			if err := pr.Scan(&x, &y); err != nil { pr.Close(); return nil, err }
+			st.Points = append(st.Points, StrokePoint{X: x, Y: y})
+		}
+		pr.Close()
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+// seedStrokes saves n strokes per user, alternating owners so each user's ids are not contiguous.
+// Point counts vary and coordinates run backwards so ordering by value would be caught.
+func seedStrokes(t testing.TB, store *Store, users []int64, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		for _, uid := range users {
+			pts := make([]StrokePoint, i%7)
+			for j := range pts { pts[j] = StrokePoint{X: float64(100 - j), Y: float64(i*10 - j)} }
+			if _, err := store.SaveStroke(uid, "#000000", 2, int64(i), pts); err != nil {
+				t.Fatalf("Failed to save stroke: %v", err)
+			}
+		}
+	}
+}
+
+func TestListStrokes_MatchesPerStrokeQueries(t *testing.T) {
+	store, alice, bob := openImportStore(t)
+	seedStrokes(t, store, []int64{alice, bob}, 12)
+	boards, err := store.ListBoardsByUser(alice)
+	if err != nil {
+		t.Fatalf("Failed to list boards: %v", err)
+	}
+	cases := []struct {
+		column  string
+		value   int64
+		limit   int
+		afterID int64
+	}{
+		{"user_id", alice, 0, 0},
+		{"user_id", bob, 0, 0},
+		{"user_id", alice, 5, 0},
+		{"user_id", alice, 4, 9},
+		{"board_id", boards[0].ID, 0, 0},
+		{"board_id", boards[0].ID, 3, 5},
+		{"user_id", alice, 0, 1 << 40},
+	}
+	for _, c := range cases {
+		want, err := listStrokesPerStroke(store, c.column, c.value, c.limit, c.afterID)
+		if err != nil {
+			t.Fatalf("Reference query failed: %v", err)
+		}
+		got, err := store.listStrokes(context.Background(), c.column, c.value, c.limit, c.afterID)
+		if err != nil {
+			t.Fatalf("listStrokes failed: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%+v: expected %+v, got %+v", c, want, got)
+		}
+	}
+}
+
+func TestListStrokesByUserContext_Canceled(t *testing.T) {
+	store, alice, _ := openImportStore(t)
+	seedStrokes(t, store, []int64{alice}, 3)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := store.ListStrokesByUserContext(ctx, alice); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	strokes, err := store.ListStrokesByUserContext(context.Background(), alice)
+	if err != nil || len(strokes) != 3 {
+		t.Fatalf("Expected 3 strokes with a live context, got %d (%v)", len(strokes), err)
+	}
+}
+
+func BenchmarkListStrokesByUser(b *testing.B) {
+	store, alice, _ := openImportStore(b)
+	seedStrokes(b, store, []int64{alice}, 500)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := store.ListStrokesByUser(alice); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	store, alice, bob := openImportStore(t)
+	users, err := store.ListUsers()
+	if err != nil {
+		t.Fatalf("Failed to list users: %v", err)
+	}
+	if len(users) != 2 || users[0].ID != alice || users[1].ID != bob || users[0].PasswordHash == "" {
+		t.Fatalf("Expected alice then bob with their hashes, got %+v", users)
+	}
+}
+
+func TestListRecentStrokesByUser(t *testing.T) {
+	store, alice, bob := openImportStore(t)
+	// Interleave bob's strokes so alice's last strokes are not a contiguous id range of the table
+	for i := 1; i <= 5; i++ {
+		pts := []StrokePoint{{X: float64(i), Y: 0}, {X: float64(i), Y: 10}}
+		if _, err := store.SaveStroke(alice, "#000000", i, 0, pts); err != nil {
+			t.Fatalf("Failed to save stroke: %v", err)
+		}
+		if _, err := store.SaveStroke(bob, "#ffffff", 1, 0, []StrokePoint{{X: 99, Y: 99}}); err != nil {
+			t.Fatalf("Failed to save stroke: %v", err)
+		}
+	}
+	got, err := store.ListRecentStrokesByUser(alice, 3)
+	if err != nil {
+		t.Fatalf("Failed to list recent strokes: %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("Expected 3 strokes, got %d", len(got))
+	}
+	for i, st := range got {
+		want := 3 + i // widths 3, 4, 5: the last three, oldest first
+		if st.UserID != alice || st.Width != want || len(st.Points) != 2 || st.Points[0].X != float64(want) {
+			t.Fatalf("Stroke %d: expected alice's stroke of width %d with its 2 points, got %+v", i, want, st)
+		}
+	}
+	if all, _ := store.ListRecentStrokesByUser(alice, 50); len(all) != 5 {
+		t.Fatalf("Expected n beyond the board to return all 5 strokes, got %d", len(all))
+	}
+	if none, err := store.ListRecentStrokesByUser(alice, 0); err != nil || len(none) != 0 {
+		t.Fatalf("Expected no strokes for n=0, got %v (%v)", none, err)
+	}
+}
@@ -0,0 +1,43 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Sentinels for the failures callers branch on; test with errors.Is, never on the message
+var (
+	ErrNotFound  = errors.New("db: not found")
+	ErrDuplicate = errors.New("db: duplicate")
+)
+
+// Error pairs a sentinel with the driver error it was mapped from, so errors.Is matches the
+// sentinel and errors.As still reaches the driver's own type
+type Error struct {
+	Kind error // ErrNotFound or ErrDuplicate
+	Err  error
+}
+
+func (e *Error) Error() string   { return e.Kind.Error() + ": " + e.Err.Error() }
+func (e *Error) Unwrap() []error { return []error{e.Kind, e.Err} }
+
+// sqlStater is implemented by Postgres driver errors (pgx's PgError, lib/pq's Error)
+type sqlStater interface{ SQLState() string }
+
+// pgUniqueViolation is the SQLSTATE for unique_violation
+const pgUniqueViolation = "23505"
+
+// wrapErr maps driver errors onto the package sentinels and returns anything else unchanged
+func wrapErr(err error) error {
+	if err == nil { return nil }
+	if errors.Is(err, sql.ErrNoRows) { return &Error{Kind: ErrNotFound, Err: err} }
+	var se sqlite3.Error
+	if errors.As(err, &se) && (se.ExtendedCode == sqlite3.ErrConstraintUnique || se.ExtendedCode == sqlite3.ErrConstraintPrimaryKey) {
+		return &Error{Kind: ErrDuplicate, Err: err}
+	}
+	var ps sqlStater
+	if errors.As(err, &ps) && ps.SQLState() == pgUniqueViolation { return &Error{Kind: ErrDuplicate, Err: err} }
+	return err
+}
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+type pgError struct{ code, msg string }
+
+func (e *pgError) Error() string    { return e.msg }
+func (e *pgError) SQLState() string { return e.code }
+
+func TestWrapErr_MapsByCodeNotMessage(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"sqlite unique", sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintUnique}, ErrDuplicate},
+		{"sqlite primary key", fmt.Errorf("insert: %w", sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintPrimaryKey}), ErrDuplicate},
+		{"postgres unique", &pgError{code: "23505", msg: "llave duplicada viola restricción de unicidad"}, ErrDuplicate},
+		{"no rows", fmt.Errorf("scan: %w", sql.ErrNoRows), ErrNotFound},
+	}
+	for _, c := range cases {
+		if got := wrapErr(c.err); !errors.Is(got, c.want) || !errors.Is(got, c.err) {
+			t.Fatalf("%s: expected %v wrapping the driver error, got %v", c.name, c.want, got)
+		}
+	}
+
+	// A message that merely mentions UNIQUE is not a duplicate
+	other := errors.New("UNIQUE but unrelated")
+	if got := wrapErr(other); got != other {
+		t.Fatalf("Expected unrelated error unchanged, got %v", got)
+	}
+	if got := wrapErr(&pgError{code: "23503", msg: "UNIQUE"}); errors.Is(got, ErrDuplicate) {
+		t.Fatal("Foreign key violation should not map to ErrDuplicate")
+	}
+	if wrapErr(nil) != nil {
+		t.Fatal("Expected nil to stay nil")
+	}
+}
+
+func TestStore_TypedErrors(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "errors.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer store.SQL.Close()
+
+	uid, err := store.CreateUser("dup@example.com", "hash")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	if _, err := store.CreateUser("dup@example.com", "hash"); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("Expected ErrDuplicate, got %v", err)
+	}
+	var se sqlite3.Error
+	if _, err := store.CreateUser("dup@example.com", "hash"); !errors.As(err, &se) {
+		t.Fatalf("Expected the sqlite error to stay reachable, got %T", err)
+	}
+
+	if err := store.DeleteStroke(uid, 12345); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound for a missing stroke, got %v", err)
+	}
+	id, _ := store.SaveStroke(uid, "#000000", 1, 0, nil)
+	if err := store.DeleteStroke(uid+1, id); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound for another user's stroke, got %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// IDPolicy controls how SaveStrokes treats the IDs carried by imported strokes
+type IDPolicy int
+
+const (
+	// IDFresh ignores incoming IDs and lets the database assign new ones
+	IDFresh IDPolicy = iota
+	// IDPreserve keeps an incoming ID when no stroke uses it yet and it is at most MaxPreservedID,
+	// and assigns a fresh one otherwise
+	IDPreserve
+)
+
+// MaxPreservedID is the largest incoming stroke ID IDPreserve keeps, the largest integer a
+// JavaScript client holds exactly. SQLite's AUTOINCREMENT continues from the highest ID ever used,
+// so a kept ID near the int64 limit would leave no IDs for anyone's next stroke.
+const MaxPreservedID = 1<<53 - 1
+
+// ParseIDPolicy maps "fresh" (or "") and "preserve" to an IDPolicy
+func ParseIDPolicy(s string) (IDPolicy, error) {
+	switch s {
+	case "", "fresh": return IDFresh, nil
+	case "preserve": return IDPreserve, nil
+	}
+	return IDFresh, fmt.Errorf("unknown id policy %q", s)
+}
+
+// IDMapping records the ID a stroke was imported under; Old is 0 when the stroke carried no ID
+type IDMapping struct {
+	Old int64 `json:"old"`
+	New int64 `json:"new"`
+}
+
+// SaveStrokes inserts strokes for userID in one transaction and returns old→new IDs in input order.
+// Stroke IDs are a table-wide primary key, so under IDPreserve an ID owned by any user, or repeated
+// earlier in the same batch, collides and gets a fresh ID instead, as does one over MaxPreservedID.
+func (s *Store) SaveStrokes(userID int64, strokes []Stroke, policy IDPolicy) ([]IDMapping, error) {
+	return s.SaveStrokesContext(context.Background(), userID, strokes, policy)
+}
+
+func (s *Store) SaveStrokesContext(ctx context.Context, userID int64, strokes []Stroke, policy IDPolicy) ([]IDMapping, error) {
+	release, err := s.lockWriteContext(ctx)
+	if err != nil { return nil, err }
+	defer release()
+	tx, err := s.SQL.BeginTx(ctx, nil)
+	if err != nil { return nil, err }
+	out := make([]IDMapping, 0, len(strokes))
+	for _, st := range strokes {
+		id := int64(0)
+		if policy == IDPreserve && st.ID > 0 && st.ID <= MaxPreservedID {
+			taken, err := strokeIDTaken(ctx, tx, st.ID)
+			if err != nil { _ = tx.Rollback(); return nil, err }
+			if !taken { id = st.ID }
+		}
+		newID, err := insertStroke(ctx, tx, userID, id, s.simplified(st))
+		if err != nil { _ = tx.Rollback(); return nil, err }
+		out = append(out, IDMapping{Old: st.ID, New: newID})
+	}
+	if err := tx.Commit(); err != nil { return nil, err }
+	return out, nil
+}
+
+func strokeIDTaken(ctx context.Context, tx *sql.Tx, id int64) (bool, error) {
+	var n int
+	err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM strokes WHERE id = ?", id).Scan(&n)
+	return n > 0, err
+}
+
+// insertStroke writes st and its points; id 0 lets SQLite pick the next ID, a zero st.CreatedBy
+// attributes the stroke to userID and a zero st.BoardID puts it on userID's default board. Callers
+// check that a non-zero st.BoardID belongs to userID.
+func insertStroke(ctx context.Context, tx *sql.Tx, userID, id int64, st Stroke) (int64, error) {
+	var res sql.Result
+	var err error
+	createdBy := st.CreatedBy
+	if createdBy == 0 { createdBy = userID }
+	var board any
+	if st.BoardID > 0 { board = st.BoardID }
+	style := st.LineStyle
+	if style == "" { style = LineSolid }
+	const boardValue = "COALESCE(?, (SELECT MIN(id) FROM boards WHERE owner_id = ?))"
+	if id > 0 {
+		res, err = tx.ExecContext(ctx, "INSERT INTO strokes(id, user_id, color, width, started_at_unix_ms, note, client_id, created_by, line_style, board_id) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, "+boardValue+")", id, userID, st.Color, st.Width, st.StartedAtUnixMs, st.Note, st.ClientID, createdBy, style, board, userID)
+	} else {
+		res, err = tx.ExecContext(ctx, "INSERT INTO strokes(user_id, color, width, started_at_unix_ms, note, client_id, created_by, line_style, board_id) VALUES(?, ?, ?, ?, ?, ?, ?, ?, "+boardValue+")", userID, st.Color, st.Width, st.StartedAtUnixMs, st.Note, st.ClientID, createdBy, style, board, userID)
+	}
+	if err != nil { return 0, wrapErr(err) }
+	strokeID, err := res.LastInsertId()
+	if err != nil { return 0, err }
+	if len(st.Points) > 0 {
+		stmt, err := tx.PrepareContext(ctx, "INSERT INTO stroke_points(stroke_id, x, y) VALUES(?, ?, ?)")
+		if err != nil { return 0, err }
+		defer stmt.Close()
+		for _, p := range st.Points {
+			if _, err := stmt.ExecContext(ctx, strokeID, p.X, p.Y); err != nil { return 0, err }
+		}
+	}
+	return strokeID, nil
+}
@@ -0,0 +1,146 @@
+package db
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func openImportStore(t testing.TB) (*Store, int64, int64) {
+	t.Helper()
+	store, err := Open(filepath.Join(t.TempDir(), "import.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { store.SQL.Close() })
+	alice, err := store.CreateUser("alice@example.com", "hash")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	bob, err := store.CreateUser("bob@example.com", "hash")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	return store, alice, bob
+}
+
+func importStroke(id int64) Stroke {
+	return Stroke{ID: id, Color: "#000000", Width: 2, StartedAtUnixMs: 1, Points: []StrokePoint{{X: 1, Y: 2}, {X: 3, Y: 4}}}
+}
+
+func TestSaveStrokes_FreshIDs(t *testing.T) {
+	store, alice, _ := openImportStore(t)
+	existing, err := store.SaveStroke(alice, "#ffffff", 1, 0, nil)
+	if err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+
+	mapping, err := store.SaveStrokes(alice, []Stroke{importStroke(existing), importStroke(500)}, IDFresh)
+	if err != nil {
+		t.Fatalf("Failed to import strokes: %v", err)
+	}
+	if len(mapping) != 2 {
+		t.Fatalf("Expected 2 mappings, got %d", len(mapping))
+	}
+	if mapping[0].Old != existing || mapping[1].Old != 500 {
+		t.Fatalf("Expected old IDs to be reported in input order, got %+v", mapping)
+	}
+	for _, m := range mapping {
+		if m.New == existing || m.New == 500 {
+			t.Fatalf("Expected fresh IDs, got %+v", mapping)
+		}
+	}
+
+	strokes, err := store.ListStrokesByUser(alice)
+	if err != nil {
+		t.Fatalf("Failed to list strokes: %v", err)
+	}
+	if len(strokes) != 3 {
+		t.Fatalf("Expected 3 strokes, got %d", len(strokes))
+	}
+	if len(strokes[1].Points) != 2 {
+		t.Fatalf("Expected imported points to be saved, got %d", len(strokes[1].Points))
+	}
+}
+
+func TestSaveStrokes_RollsBackOnFailure(t *testing.T) {
+	store, alice, _ := openImportStore(t)
+	bad := importStroke(0)
+	// SQLite stores NaN as NULL, which the NOT NULL point columns refuse mid-batch
+	bad.Points = []StrokePoint{{X: math.NaN(), Y: 1}}
+	if _, err := store.SaveStrokes(alice, []Stroke{importStroke(0), importStroke(0), bad}, IDFresh); err == nil {
+		t.Fatal("Expected the batch to fail")
+	}
+	strokes, err := store.ListStrokesByUser(alice)
+	if err != nil {
+		t.Fatalf("Failed to list strokes: %v", err)
+	}
+	if len(strokes) != 0 {
+		t.Fatalf("Expected the strokes before the failure to be rolled back, got %d", len(strokes))
+	}
+}
+
+func TestSaveStrokes_PreserveRefusesIDsNearTheLimit(t *testing.T) {
+	store, alice, bob := openImportStore(t)
+	mapping, err := store.SaveStrokes(alice, []Stroke{importStroke(math.MaxInt64), importStroke(MaxPreservedID + 1)}, IDPreserve)
+	if err != nil {
+		t.Fatalf("Failed to import strokes: %v", err)
+	}
+	for _, m := range mapping {
+		if m.New > MaxPreservedID {
+			t.Fatalf("Expected ID %d to be replaced by a fresh one, got %d", m.Old, m.New)
+		}
+	}
+	// Before the cap, the kept max-int64 ID used up the sequence and this failed with "database or disk is full"
+	if _, err := store.SaveStroke(bob, "#000000", 1, 0, nil); err != nil {
+		t.Fatalf("Expected later strokes to still get IDs: %v", err)
+	}
+}
+
+func TestSaveStrokes_PreserveIDs(t *testing.T) {
+	store, alice, bob := openImportStore(t)
+	mine, err := store.SaveStroke(alice, "#ffffff", 1, 0, nil)
+	if err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+	theirs, err := store.SaveStroke(bob, "#ffffff", 1, 0, nil)
+	if err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+
+	batch := []Stroke{importStroke(900), importStroke(mine), importStroke(theirs), importStroke(900), importStroke(0)}
+	mapping, err := store.SaveStrokes(alice, batch, IDPreserve)
+	if err != nil {
+		t.Fatalf("Failed to import strokes: %v", err)
+	}
+	if mapping[0].New != 900 {
+		t.Fatalf("Expected unused ID 900 to be preserved, got %d", mapping[0].New)
+	}
+	seen := map[int64]bool{mine: true, theirs: true, 900: true}
+	for _, m := range mapping[1:] {
+		if seen[m.New] {
+			t.Fatalf("Expected colliding ID %d to be reassigned, got %+v", m.Old, mapping)
+		}
+		seen[m.New] = true
+	}
+
+	strokes, err := store.ListStrokesByUser(bob)
+	if err != nil {
+		t.Fatalf("Failed to list strokes: %v", err)
+	}
+	if len(strokes) != 1 || strokes[0].ID != theirs {
+		t.Fatalf("Expected other user's stroke to be untouched, got %+v", strokes)
+	}
+}
+
+func TestParseIDPolicy(t *testing.T) {
+	for in, want := range map[string]IDPolicy{"": IDFresh, "fresh": IDFresh, "preserve": IDPreserve} {
+		got, err := ParseIDPolicy(in)
+		if err != nil || got != want {
+			t.Fatalf("ParseIDPolicy(%q) = %v, %v", in, got, err)
+		}
+	}
+	if _, err := ParseIDPolicy("keep"); err == nil {
+		t.Fatal("Expected error for unknown policy")
+	}
+}
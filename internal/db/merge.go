@@ -0,0 +1,49 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// MergeResult summarizes a MergeUsers call
+type MergeResult struct {
+	MovedStrokes     int64 `json:"movedStrokes"`
+	MovedBoards      int64 `json:"movedBoards"` // the source's boards, default board included, now owned by the target
+	RenamedClientIDs int64 `json:"renamedClientIds"` // source strokes whose client ID was already used by the target
+}
+
+// MergeUsers moves every stroke and board of sourceID to targetID and deletes sourceID, all in one
+// transaction. Stroke IDs are global so they never collide; client IDs can, and a colliding
+// source client ID gets a ":<sourceID>" suffix so clients keep telling the two histories apart.
+// Either user missing is ErrNotFound.
+func (s *Store) MergeUsers(sourceID, targetID int64) (MergeResult, error) {
+	return s.MergeUsersContext(context.Background(), sourceID, targetID)
+}
+
+func (s *Store) MergeUsersContext(ctx context.Context, sourceID, targetID int64) (MergeResult, error) {
+	var out MergeResult
+	if sourceID == targetID { return out, fmt.Errorf("cannot merge user %d into itself", sourceID) }
+	release, err := s.lockWriteContext(ctx)
+	if err != nil { return out, err }
+	defer release()
+	tx, err := s.SQL.BeginTx(ctx, nil)
+	if err != nil { return out, err }
+	defer func(){ if err != nil { _ = tx.Rollback() } }()
+	var n int
+	if err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE id IN (?, ?)", sourceID, targetID).Scan(&n); err != nil { return out, err }
+	if n != 2 { err = ErrNotFound; return out, err }
+
+	res, err := tx.ExecContext(ctx, `UPDATE strokes SET client_id = client_id || ? WHERE user_id = ? AND client_id != ''
+		AND client_id IN (SELECT client_id FROM strokes WHERE user_id = ?)`, ":"+strconv.FormatInt(sourceID, 10), sourceID, targetID)
+	if err != nil { return out, err }
+	if out.RenamedClientIDs, err = res.RowsAffected(); err != nil { return out, err }
+	if res, err = tx.ExecContext(ctx, "UPDATE strokes SET user_id = ? WHERE user_id = ?", targetID, sourceID); err != nil { return out, err }
+	if out.MovedStrokes, err = res.RowsAffected(); err != nil { return out, err }
+	// Strokes stay on their boards; moving the boards too keeps them from cascading away with the source
+	if res, err = tx.ExecContext(ctx, "UPDATE boards SET owner_id = ? WHERE owner_id = ?", targetID, sourceID); err != nil { return out, err }
+	if out.MovedBoards, err = res.RowsAffected(); err != nil { return out, err }
+	if _, err = tx.ExecContext(ctx, "DELETE FROM users WHERE id = ?", sourceID); err != nil { return out, err }
+	err = tx.Commit()
+	return out, err
+}
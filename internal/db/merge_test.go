@@ -0,0 +1,85 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestMergeUsers_MovesStrokesAndDeletesSource(t *testing.T) {
+	store, alice, bob := openImportStore(t)
+	shared, own := importStroke(0), importStroke(0)
+	shared.ClientID, own.ClientID = "tab-1", "tab-2"
+	for _, st := range []Stroke{shared, own, importStroke(0)} {
+		if _, err := store.SaveStrokeRecord(bob, st); err != nil {
+			t.Fatalf("Failed to save source stroke: %v", err)
+		}
+	}
+	target := importStroke(0)
+	target.ClientID = "tab-1"
+	if _, err := store.SaveStrokeRecord(alice, target); err != nil {
+		t.Fatalf("Failed to save target stroke: %v", err)
+	}
+
+	res, err := store.MergeUsers(bob, alice)
+	if err != nil {
+		t.Fatalf("Should not return error: %v", err)
+	}
+	if res.MovedStrokes != 3 || res.RenamedClientIDs != 1 {
+		t.Fatalf("Expected 3 moved and 1 renamed, got %+v", res)
+	}
+	strokes, _ := store.ListStrokesByUser(alice)
+	if len(strokes) != 4 {
+		t.Fatalf("Expected 4 strokes on the target, got %d", len(strokes))
+	}
+	clientIDs := map[string]int{}
+	for _, st := range strokes { clientIDs[st.ClientID]++ }
+	if clientIDs["tab-1"] != 1 || clientIDs[fmt.Sprintf("tab-1:%d", bob)] != 1 || clientIDs["tab-2"] != 1 || clientIDs[""] != 1 {
+		t.Fatalf("Expected only the colliding client ID to be suffixed, got %v", clientIDs)
+	}
+	if u, err := store.GetUserByID(bob); err != nil || u != nil {
+		t.Fatalf("Expected source user to be deleted, got %v, %v", u, err)
+	}
+}
+
+func TestMergeUsers_MissingUserLeavesDataUntouched(t *testing.T) {
+	store, alice, _ := openImportStore(t)
+	if _, err := store.SaveStrokeRecord(alice, importStroke(0)); err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+	if _, err := store.MergeUsers(alice, 999); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+	if strokes, _ := store.ListStrokesByUser(alice); len(strokes) != 1 {
+		t.Fatalf("Expected strokes to stay with the source, got %d", len(strokes))
+	}
+	if _, err := store.MergeUsers(alice, alice); err == nil {
+		t.Fatal("Expected an error merging a user into itself")
+	}
+}
+
+func TestMergeUsers_MovesBoards(t *testing.T) {
+	store, alice, bob := openImportStore(t)
+	extra, err := store.CreateBoard(bob, "Bob's sketches")
+	if err != nil {
+		t.Fatalf("Failed to create board: %v", err)
+	}
+	st := importStroke(0)
+	st.BoardID = extra
+	if _, err := store.SaveStrokeRecord(bob, st); err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+	res, err := store.MergeUsers(bob, alice)
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if res.MovedBoards != 2 {
+		t.Fatalf("Expected bob's default and extra boards to move, got %d", res.MovedBoards)
+	}
+	if boards, _ := store.ListBoardsByUser(alice); len(boards) != 3 {
+		t.Fatalf("Expected alice to own 3 boards, got %+v", boards)
+	}
+	if strokes, _ := store.ListStrokesByBoard(extra); len(strokes) != 1 || strokes[0].UserID != alice {
+		t.Fatalf("Expected the stroke to stay on its board under alice, got %+v", strokes)
+	}
+}
@@ -0,0 +1,208 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// Migration is one numbered schema change. Steps are written to be idempotent so databases
+// created before versioning existed can replay them safely.
+type Migration struct {
+	Version int
+	Name    string
+	up      func(q querier) error
+}
+
+// querier is what migration steps need; both *sql.DB and *sql.Tx satisfy it
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// migrations is append-only: never renumber or edit a released step, add a new one
+var migrations = []Migration{
+	{1, "initial schema", func(q querier) error {
+		_, err := q.Exec(`
+		CREATE TABLE IF NOT EXISTS users (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			email TEXT NOT NULL UNIQUE,
+			password_hash TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE IF NOT EXISTS strokes (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			color TEXT NOT NULL,
+			width INTEGER NOT NULL,
+			started_at_unix_ms INTEGER NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE IF NOT EXISTS stroke_points (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			stroke_id INTEGER NOT NULL REFERENCES strokes(id) ON DELETE CASCADE,
+			x REAL NOT NULL,
+			y REAL NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_strokes_user ON strokes(user_id);
+		CREATE INDEX IF NOT EXISTS idx_stroke_points_stroke ON stroke_points(stroke_id);
+		`)
+		return err
+	}},
+	{2, "stroke notes", func(q querier) error { return addColumnIfMissing(q, "strokes", "note", "TEXT NOT NULL DEFAULT ''") }},
+	{3, "stroke client ids", func(q querier) error { return addColumnIfMissing(q, "strokes", "client_id", "TEXT NOT NULL DEFAULT ''") }},
+	{4, "public profiles", func(q querier) error { return addColumnIfMissing(q, "users", "public", "INTEGER NOT NULL DEFAULT 0") }},
+	{5, "display names", func(q querier) error { return addColumnIfMissing(q, "users", "display_name", "TEXT NOT NULL DEFAULT ''") }},
+	{6, "stroke creators", func(q querier) error {
+		if err := addColumnIfMissing(q, "strokes", "created_by", "INTEGER NOT NULL DEFAULT 0"); err != nil { return err }
+		// Every existing stroke was drawn by its owner
+		_, err := q.Exec("UPDATE strokes SET created_by = user_id WHERE created_by = 0")
+		return err
+	}},
+	{7, "boards", func(q querier) error {
+		if _, err := q.Exec(`
+		CREATE TABLE IF NOT EXISTS boards (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			owner_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS idx_boards_owner ON boards(owner_id);
+		`); err != nil { return err }
+		if err := addColumnIfMissing(q, "strokes", "board_id", "INTEGER REFERENCES boards(id) ON DELETE CASCADE"); err != nil { return err }
+		// Every existing user gets a default board holding the strokes they already drew
+		if _, err := q.Exec("INSERT INTO boards(owner_id, name) SELECT id, ? FROM users WHERE id NOT IN (SELECT owner_id FROM boards)", DefaultBoardName); err != nil { return err }
+		_, err := q.Exec(`
+		UPDATE strokes SET board_id = (SELECT MIN(id) FROM boards WHERE owner_id = strokes.user_id) WHERE board_id IS NULL;
+		CREATE INDEX IF NOT EXISTS idx_strokes_board ON strokes(board_id);
+		`)
+		return err
+	}},
+	{8, "stroke line styles", func(q querier) error { return addColumnIfMissing(q, "strokes", "line_style", "TEXT NOT NULL DEFAULT 'solid'") }},
+	{9, "board settings", func(q querier) error {
+		_, err := q.Exec(`
+		CREATE TABLE IF NOT EXISTS board_settings (
+			board_id INTEGER PRIMARY KEY REFERENCES boards(id) ON DELETE CASCADE,
+			width INTEGER NOT NULL,
+			height INTEGER NOT NULL,
+			background TEXT NOT NULL
+		);
+		`)
+		return err
+	}},
+	{10, "session epochs", func(q querier) error { return addColumnIfMissing(q, "users", "session_epoch", "INTEGER NOT NULL DEFAULT 0") }},
+}
+
+// LatestVersion is the schema version this build expects
+func LatestVersion() int { return migrations[len(migrations)-1].Version }
+
+// AppliedMigration is a migration recorded in schema_migrations
+type AppliedMigration struct {
+	Version   int       `json:"version"`
+	Name      string    `json:"name"`
+	AppliedAt time.Time `json:"appliedAt"`
+}
+
+// PendingMigration is a migration this build knows about but the database has not run
+type PendingMigration struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+}
+
+// SchemaStatus reports where the database stands relative to this build
+type SchemaStatus struct {
+	Version       int                `json:"version"` // highest applied migration, 0 for an empty database
+	LatestVersion int                `json:"latestVersion"`
+	Applied       []AppliedMigration `json:"applied"`
+	Pending       []PendingMigration `json:"pending"`
+}
+
+// migrateMu serializes Migrate calls within the process so a runtime migrate never races startup
+var migrateMu sync.Mutex
+
+func ensureMigrationsTable(q querier) error {
+	_, err := q.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	return err
+}
+
+func appliedVersions(q querier) (map[int]bool, error) {
+	rows, err := q.Query("SELECT version FROM schema_migrations")
+	if err != nil { return nil, err }
+	defer rows.Close()
+	out := map[int]bool{}
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil { return nil, err }
+		out[v] = true
+	}
+	return out, rows.Err()
+}
+
+// Migrate applies every pending migration in order, each in its own transaction, and returns
+// the ones it ran. Calling it on an up-to-date database is a no-op.
+func (s *Store) Migrate() ([]PendingMigration, error) {
+	migrateMu.Lock()
+	defer migrateMu.Unlock()
+	defer s.lockWrite()()
+	if err := ensureMigrationsTable(s.SQL); err != nil { return nil, err }
+	done, err := appliedVersions(s.SQL)
+	if err != nil { return nil, err }
+	ran := []PendingMigration{}
+	for _, m := range migrations {
+		if done[m.Version] { continue }
+		tx, err := s.SQL.Begin()
+		if err != nil { return ran, err }
+		if err := m.up(tx); err != nil { _ = tx.Rollback(); return ran, err }
+		if _, err := tx.Exec("INSERT INTO schema_migrations(version, name) VALUES(?, ?)", m.Version, m.Name); err != nil { _ = tx.Rollback(); return ran, err }
+		if err := tx.Commit(); err != nil { return ran, err }
+		ran = append(ran, PendingMigration{Version: m.Version, Name: m.Name})
+	}
+	return ran, nil
+}
+
+// SchemaStatus lists applied and pending migrations
+func (s *Store) SchemaStatus() (SchemaStatus, error) { return s.SchemaStatusContext(context.Background()) }
+
+func (s *Store) SchemaStatusContext(ctx context.Context) (SchemaStatus, error) {
+	st := SchemaStatus{LatestVersion: LatestVersion(), Applied: []AppliedMigration{}, Pending: []PendingMigration{}}
+	if err := ensureMigrationsTable(s.SQL); err != nil { return st, err }
+	rows, err := s.SQL.QueryContext(ctx, "SELECT version, name, applied_at FROM schema_migrations ORDER BY version")
+	if err != nil { return st, err }
+	defer rows.Close()
+	done := map[int]bool{}
+	for rows.Next() {
+		var a AppliedMigration
+		if err := rows.Scan(&a.Version, &a.Name, &a.AppliedAt); err != nil { return st, err }
+		st.Applied = append(st.Applied, a)
+		done[a.Version] = true
+		if a.Version > st.Version { st.Version = a.Version }
+	}
+	if err := rows.Err(); err != nil { return st, err }
+	for _, m := range migrations {
+		if !done[m.Version] { st.Pending = append(st.Pending, PendingMigration{Version: m.Version, Name: m.Name}) }
+	}
+	return st, nil
+}
+
+// addColumnIfMissing upgrades databases created before a column existed; SQLite has no ADD COLUMN IF NOT EXISTS
+func addColumnIfMissing(q querier, table, column, decl string) error {
+	rows, err := q.Query("PRAGMA table_info(" + table + ")")
+	if err != nil { return err }
+	defer rows.Close()
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, typ string
+		var def sql.NullString
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &def, &pk); err != nil { return err }
+		if name == column { return nil }
+	}
+	if err := rows.Err(); err != nil { return err }
+	rows.Close()
+	_, err = q.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + decl)
+	return err
+}
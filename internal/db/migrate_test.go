@@ -0,0 +1,104 @@
+package db
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrate_StatusAndIdempotent(t *testing.T) {
+	store, err := OpenWithoutMigrations(filepath.Join(t.TempDir(), "schema.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer store.SQL.Close()
+
+	st, err := store.SchemaStatus()
+	if err != nil {
+		t.Fatalf("Failed to read status: %v", err)
+	}
+	if st.Version != 0 || len(st.Pending) != len(migrations) {
+		t.Fatalf("Expected every migration pending on an empty database, got %+v", st)
+	}
+
+	ran, err := store.Migrate()
+	if err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if len(ran) != len(migrations) {
+		t.Fatalf("Expected %d migrations to run, got %d", len(migrations), len(ran))
+	}
+	st, _ = store.SchemaStatus()
+	if st.Version != LatestVersion() || len(st.Pending) != 0 || len(st.Applied) != len(migrations) {
+		t.Fatalf("Expected an up-to-date schema, got %+v", st)
+	}
+	if st.Applied[0].AppliedAt.IsZero() {
+		t.Fatal("Expected applied migrations to record a time")
+	}
+
+	if ran, err := store.Migrate(); err != nil || len(ran) != 0 {
+		t.Fatalf("Expected a second migrate to be a no-op, got %v, %v", ran, err)
+	}
+	if _, err := store.CreateUser("m@example.com", "hash"); err != nil {
+		t.Fatalf("Failed to use migrated schema: %v", err)
+	}
+}
+
+func TestMigrate_AdoptsUnversionedDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	legacy, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	// A database built by the pre-versioning migrate: full schema, no schema_migrations table
+	for _, m := range migrations {
+		if err := m.up(legacy); err != nil {
+			t.Fatalf("Failed to build legacy schema: %v", err)
+		}
+	}
+	legacy.Close()
+
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open legacy database: %v", err)
+	}
+	defer store.SQL.Close()
+	st, err := store.SchemaStatus()
+	if err != nil || st.Version != LatestVersion() || len(st.Pending) != 0 {
+		t.Fatalf("Expected legacy database to be adopted at the latest version, got %+v (%v)", st, err)
+	}
+}
+
+func TestMigrate_BackfillsStrokeCreators(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "creators.db")
+	old, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	// Schema as of version 5, with a stroke saved before created_by existed
+	for _, m := range migrations[:5] {
+		if err := m.up(old); err != nil {
+			t.Fatalf("Failed to build old schema: %v", err)
+		}
+	}
+	if _, err := old.Exec("INSERT INTO users(id, email, password_hash) VALUES(7, 'old@example.com', 'hash')"); err != nil {
+		t.Fatalf("Failed to insert user: %v", err)
+	}
+	if _, err := old.Exec("INSERT INTO strokes(user_id, color, width, started_at_unix_ms) VALUES(7, '#000000', 2, 0)"); err != nil {
+		t.Fatalf("Failed to insert stroke: %v", err)
+	}
+	old.Close()
+
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer store.SQL.Close()
+	strokes, err := store.ListStrokesByUser(7)
+	if err != nil || len(strokes) != 1 {
+		t.Fatalf("Expected the old stroke, got %v (%v)", strokes, err)
+	}
+	if strokes[0].CreatedBy != 7 {
+		t.Fatalf("Expected created_by backfilled to the owner, got %d", strokes[0].CreatedBy)
+	}
+}
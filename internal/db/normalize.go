@@ -0,0 +1,51 @@
+package db
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/deliium/drawing-board/internal/validate"
+)
+
+// Bounds every stroke must satisfy, however it reaches the store
+const (
+	MaxStrokeWidth  = 64
+	MaxStrokePoints = 10000
+	MaxCoordinate   = 1e6 // absolute bound on x and y; canvases are far smaller
+)
+
+// NormalizeStroke applies the shared stroke rules in place: colors are lowercased and #rgb is
+// expanded to #rrggbb, an empty line style becomes LineSolid, everything else out of bounds is reported. Field names are prefixed
+// with prefix (e.g. "strokes[2].") so batch callers can say which stroke failed.
+func NormalizeStroke(st *Stroke, prefix string) validate.Errors {
+	var errs validate.Errors
+	var ok bool
+	if st.Color, ok = normalizeColor(st.Color); !ok { errs.Add(prefix+"color", "must be a #rgb or #rrggbb color") }
+	errs.Check(st.Width >= 1 && st.Width <= MaxStrokeWidth, prefix+"width", fmt.Sprintf("must be between 1 and %d", MaxStrokeWidth))
+	errs.Check(len(st.Points) >= 1, prefix+"points", "must not be empty")
+	errs.Check(len(st.Points) <= MaxStrokePoints, prefix+"points", fmt.Sprintf("must have at most %d points", MaxStrokePoints))
+	for i, p := range st.Points {
+		if !finiteCoord(p.X) || !finiteCoord(p.Y) {
+			errs.Add(fmt.Sprintf("%spoints[%d]", prefix, i), fmt.Sprintf("must be finite and within ±%g", float64(MaxCoordinate)))
+			break
+		}
+	}
+	errs.Check(st.StartedAtUnixMs >= 0, prefix+"startedAtUnixMs", "must not be negative")
+	st.LineStyle = strings.ToLower(strings.TrimSpace(st.LineStyle))
+	if st.LineStyle == "" { st.LineStyle = LineSolid }
+	errs.Check(st.LineStyle == LineSolid || st.LineStyle == LineDashed || st.LineStyle == LineDotted, prefix+"lineStyle", "must be solid, dashed or dotted")
+	errs.Check(utf8.RuneCountInString(st.Note) <= MaxNoteLength, prefix+"note", fmt.Sprintf("must be at most %d characters", MaxNoteLength))
+	return errs
+}
+
+// normalizeColor lowercases a hex color and expands #rgb to #rrggbb, reporting whether it is one
+func normalizeColor(c string) (string, bool) {
+	c = strings.ToLower(strings.TrimSpace(c))
+	if !validate.IsHexColor(c) { return c, false }
+	if len(c) == 4 { c = "#" + strings.Repeat(c[1:2], 2) + strings.Repeat(c[2:3], 2) + strings.Repeat(c[3:4], 2) }
+	return c, true
+}
+
+func finiteCoord(v float64) bool { return !math.IsNaN(v) && math.Abs(v) <= MaxCoordinate }
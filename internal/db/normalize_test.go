@@ -0,0 +1,51 @@
+package db
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNormalizeStroke(t *testing.T) {
+	st := Stroke{Color: " #1D4ED8", Width: 4, Points: []StrokePoint{{X: 1, Y: 2}}}
+	if errs := NormalizeStroke(&st, ""); errs.Any() || st.Color != "#1d4ed8" {
+		t.Fatalf("Expected a valid lowercased stroke, got %q %v", st.Color, errs)
+	}
+	st = Stroke{Color: "#FfF", Width: 1, Points: []StrokePoint{{X: 0, Y: 0}}}
+	if errs := NormalizeStroke(&st, ""); errs.Any() || st.Color != "#ffffff" {
+		t.Fatalf("Expected #rgb to expand, got %q %v", st.Color, errs)
+	}
+
+	st = Stroke{Color: "red", Width: MaxStrokeWidth + 1, Points: []StrokePoint{{X: math.NaN(), Y: 0}}, Note: string(make([]rune, MaxNoteLength+1))}
+	errs := NormalizeStroke(&st, "s.")
+	want := []string{"s.color", "s.width", "s.points[0]", "s.note"}
+	if len(errs) != len(want) {
+		t.Fatalf("Expected %d errors, got %v", len(want), errs)
+	}
+	for i, f := range want {
+		if errs[i].Field != f {
+			t.Fatalf("Expected error %d on %s, got %s", i, f, errs[i].Field)
+		}
+	}
+
+	if errs := NormalizeStroke(&Stroke{Color: "#000", Width: 2}, ""); len(errs) != 1 || errs[0].Field != "points" {
+		t.Fatalf("Expected an empty stroke to be rejected, got %v", errs)
+	}
+	if errs := NormalizeStroke(&Stroke{Color: "#000", Width: 2, Points: []StrokePoint{{X: math.Inf(1), Y: 0}}}, ""); !errs.Any() {
+		t.Fatal("Expected an infinite coordinate to be rejected")
+	}
+}
+
+func TestNormalizeStroke_LineStyle(t *testing.T) {
+	st := Stroke{Color: "#000", Width: 2, Points: []StrokePoint{{X: 0, Y: 0}}}
+	if errs := NormalizeStroke(&st, ""); errs.Any() || st.LineStyle != LineSolid {
+		t.Fatalf("Expected an empty line style to default to solid, got %q %v", st.LineStyle, errs)
+	}
+	st.LineStyle = " Dashed"
+	if errs := NormalizeStroke(&st, ""); errs.Any() || st.LineStyle != LineDashed {
+		t.Fatalf("Expected dashed, got %q %v", st.LineStyle, errs)
+	}
+	st.LineStyle = "wavy"
+	if errs := NormalizeStroke(&st, "s."); len(errs) != 1 || errs[0].Field != "s.lineStyle" {
+		t.Fatalf("Expected an unknown line style to be rejected, got %v", errs)
+	}
+}
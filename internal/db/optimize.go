@@ -0,0 +1,34 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// OptimizeStep is one maintenance statement run by Optimize and how long it took
+type OptimizeStep struct {
+	Name       string  `json:"name"` // "reindex", "analyze" or "optimize"
+	DurationMs float64 `json:"durationMs"`
+}
+
+// Optimize refreshes the query planner statistics (ANALYZE, then PRAGMA optimize), rebuilding
+// every index first when reindex is set. It takes a write slot, so concurrent writers queue
+// behind it; the steps that ran are returned even when a later one fails.
+func (s *Store) Optimize(reindex bool) ([]OptimizeStep, error) {
+	return s.OptimizeContext(context.Background(), reindex)
+}
+
+func (s *Store) OptimizeContext(ctx context.Context, reindex bool) ([]OptimizeStep, error) {
+	release, err := s.lockWriteContext(ctx)
+	if err != nil { return nil, err }
+	defer release()
+	stmts := []struct{ name, sql string }{{"analyze", "ANALYZE"}, {"optimize", "PRAGMA optimize"}}
+	if reindex { stmts = append([]struct{ name, sql string }{{"reindex", "REINDEX"}}, stmts...) }
+	steps := make([]OptimizeStep, 0, len(stmts))
+	for _, st := range stmts {
+		start := time.Now()
+		if _, err := s.SQL.ExecContext(ctx, st.sql); err != nil { return steps, err }
+		steps = append(steps, OptimizeStep{Name: st.name, DurationMs: float64(time.Since(start).Microseconds()) / 1000})
+	}
+	return steps, nil
+}
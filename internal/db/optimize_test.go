@@ -0,0 +1,34 @@
+package db
+
+import "testing"
+
+func TestOptimize_PopulatedDatabase(t *testing.T) {
+	store, alice, bob := openImportStore(t)
+	for i := int64(1); i <= 20; i++ {
+		owner := alice
+		if i%2 == 0 { owner = bob }
+		if _, err := store.SaveStrokeRecord(owner, importStroke(0)); err != nil {
+			t.Fatalf("Failed to save stroke: %v", err)
+		}
+	}
+	steps, err := store.Optimize(true)
+	if err != nil {
+		t.Fatalf("Optimize failed: %v", err)
+	}
+	if len(steps) != 3 || steps[0].Name != "reindex" || steps[1].Name != "analyze" || steps[2].Name != "optimize" {
+		t.Fatalf("Expected reindex, analyze, optimize, got %+v", steps)
+	}
+	var stats int
+	if err := store.SQL.QueryRow("SELECT COUNT(*) FROM sqlite_stat1").Scan(&stats); err != nil || stats == 0 {
+		t.Fatalf("Expected ANALYZE to record planner statistics, got %d (%v)", stats, err)
+	}
+	strokes, err := store.ListStrokesByUser(alice)
+	if err != nil || len(strokes) != 10 {
+		t.Fatalf("Expected data to survive optimization, got %d strokes (%v)", len(strokes), err)
+	}
+
+	steps, err = store.Optimize(false)
+	if err != nil || len(steps) != 2 || steps[0].Name != "analyze" {
+		t.Fatalf("Expected analyze and optimize without reindex, got %+v (%v)", steps, err)
+	}
+}
@@ -0,0 +1,39 @@
+package db
+
+import (
+	"context"
+
+	"github.com/deliium/drawing-board/internal/validate"
+)
+
+// NormalizeRecolor applies the stroke color rules to both colors of a RecolorStrokes call
+func NormalizeRecolor(from, to *string) validate.Errors {
+	var errs validate.Errors
+	var ok bool
+	if *from, ok = normalizeColor(*from); !ok { errs.Add("from", "must be a #rgb or #rrggbb color") }
+	if *to, ok = normalizeColor(*to); !ok { errs.Add("to", "must be a #rgb or #rrggbb color") }
+	return errs
+}
+
+// RecolorStrokes changes every stroke userID owns from one color to another in a single UPDATE
+// and returns the IDs it changed by board (0 for strokes saved before boards existed); callers
+// normalize both colors first
+func (s *Store) RecolorStrokes(userID int64, from, to string) (map[int64][]int64, error) {
+	return s.RecolorStrokesContext(context.Background(), userID, from, to)
+}
+
+func (s *Store) RecolorStrokesContext(ctx context.Context, userID int64, from, to string) (map[int64][]int64, error) {
+	release, err := s.lockWriteContext(ctx)
+	if err != nil { return nil, err }
+	defer release()
+	rows, err := s.SQL.QueryContext(ctx, "UPDATE strokes SET color = ? WHERE user_id = ? AND color = ? RETURNING id, COALESCE(board_id, 0)", to, userID, from)
+	if err != nil { return nil, err }
+	defer rows.Close()
+	out := map[int64][]int64{}
+	for rows.Next() {
+		var id, board int64
+		if err := rows.Scan(&id, &board); err != nil { return nil, err }
+		out[board] = append(out[board], id)
+	}
+	return out, rows.Err()
+}
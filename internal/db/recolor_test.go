@@ -0,0 +1,46 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRecolorStrokes_ChangesOnlyMatchingStrokes(t *testing.T) {
+	store, alice, bob := openImportStore(t)
+	save := func(uid int64, color string) int64 {
+		id, err := store.SaveStroke(uid, color, 2, 1, []StrokePoint{{X: 1, Y: 2}})
+		if err != nil {
+			t.Fatalf("Failed to save stroke: %v", err)
+		}
+		return id
+	}
+	red1, blue, red2, bobRed := save(alice, "#ff0000"), save(alice, "#0000ff"), save(alice, "#ff0000"), save(bob, "#ff0000")
+	board, _ := store.DefaultBoardID(alice)
+
+	from, to := " #F00", "#1D4ED8"
+	if errs := NormalizeRecolor(&from, &to); errs.Any() {
+		t.Fatalf("Expected valid colors, got %v", errs)
+	}
+	got, err := store.RecolorStrokes(alice, from, to)
+	if err != nil {
+		t.Fatalf("Failed to recolor: %v", err)
+	}
+	if want := map[int64][]int64{board: {red1, red2}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected %v recolored, got %v", want, got)
+	}
+	colors := map[int64]string{}
+	for _, uid := range []int64{alice, bob} {
+		rows, _ := store.ListStrokesByUser(uid)
+		for _, s := range rows { colors[s.ID] = s.Color }
+	}
+	if want := map[int64]string{red1: "#1d4ed8", blue: "#0000ff", red2: "#1d4ed8", bobRed: "#ff0000"}; !reflect.DeepEqual(colors, want) {
+		t.Fatalf("Expected colors %v, got %v", want, colors)
+	}
+}
+
+func TestNormalizeRecolor_ReportsBothColors(t *testing.T) {
+	from, to := "red", "#12345"
+	if errs := NormalizeRecolor(&from, &to); len(errs) != 2 || errs[0].Field != "from" || errs[1].Field != "to" {
+		t.Fatalf("Expected errors on from and to, got %v", errs)
+	}
+}
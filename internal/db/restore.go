@@ -0,0 +1,99 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ImportUser is an account to recreate with ImportUsers. ID and the board and creator IDs of its
+// strokes are those of the database it came from; they only tie the strokes to the imported rows.
+type ImportUser struct {
+	ID           int64
+	Email        string
+	PasswordHash string
+	Public       bool
+	DisplayName  string
+	CreatedAt    time.Time     // zero means now
+	Boards       []ImportBoard // in creation order; the first becomes the default board
+	Strokes      []Stroke      // an unknown BoardID or CreatedBy falls back to the default board and the owner
+}
+
+// ImportBoard is one board of an ImportUser; nil Settings leaves the defaults
+type ImportBoard struct {
+	ID        int64
+	Name      string
+	CreatedAt time.Time // zero means now
+	Settings  *BoardSettings
+}
+
+// ImportResult counts what ImportUsers created
+type ImportResult struct {
+	Users, Boards, Strokes int
+}
+
+// ImportUsers recreates users with their boards and strokes under fresh IDs, keeping their creation
+// timestamps, all in one transaction: any failure, an email that is already registered included
+// (ErrDuplicate), leaves the database as it was. Strokes are saved as given apart from
+// simplification, so callers normalize them first.
+func (s *Store) ImportUsers(users []ImportUser) (ImportResult, error) {
+	return s.ImportUsersContext(context.Background(), users)
+}
+
+func (s *Store) ImportUsersContext(ctx context.Context, users []ImportUser) (ImportResult, error) {
+	var out ImportResult
+	release, err := s.lockWriteContext(ctx)
+	if err != nil { return out, err }
+	defer release()
+	tx, err := s.SQL.BeginTx(ctx, nil)
+	if err != nil { return out, err }
+	defer func(){ if err != nil { _ = tx.Rollback() } }()
+	var res sql.Result
+	var id int64
+
+	// Users first, so strokes drawn on someone else's board can refer to their new creator ID
+	userIDs := make(map[int64]int64, len(users))
+	for _, u := range users {
+		var n int
+		if err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE email = ?", u.Email).Scan(&n); err != nil { return out, err }
+		if n > 0 { err = fmt.Errorf("user %s already exists: %w", u.Email, ErrDuplicate); return out, err }
+		res, err = tx.ExecContext(ctx, "INSERT INTO users(email, password_hash, public, display_name, created_at) VALUES(?, ?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP))", u.Email, u.PasswordHash, u.Public, u.DisplayName, sqliteTime(u.CreatedAt))
+		if err != nil { return out, fmt.Errorf("user %s: %w", u.Email, wrapErr(err)) }
+		if userIDs[u.ID], err = res.LastInsertId(); err != nil { return out, err }
+		out.Users++
+	}
+	for _, u := range users {
+		uid := userIDs[u.ID]
+		boards := u.Boards
+		// Every user has a default board, even one dumped without any
+		if len(boards) == 0 { boards = []ImportBoard{{Name: DefaultBoardName}} } else { out.Boards += len(boards) }
+		boardIDs := make(map[int64]int64, len(boards))
+		for _, b := range boards {
+			res, err = tx.ExecContext(ctx, "INSERT INTO boards(owner_id, name, created_at) VALUES(?, ?, COALESCE(?, CURRENT_TIMESTAMP))", uid, b.Name, sqliteTime(b.CreatedAt))
+			if err != nil { return out, fmt.Errorf("user %s: %w", u.Email, wrapErr(err)) }
+			if id, err = res.LastInsertId(); err != nil { return out, err }
+			if b.ID != 0 { boardIDs[b.ID] = id }
+			if bs := b.Settings; bs != nil {
+				if _, err = tx.ExecContext(ctx, "INSERT INTO board_settings(board_id, width, height, background) VALUES(?, ?, ?, ?)", id, bs.Width, bs.Height, bs.Background); err != nil { return out, err }
+			}
+		}
+		for _, st := range u.Strokes {
+			st.BoardID, st.CreatedBy = boardIDs[st.BoardID], userIDs[st.CreatedBy]
+			if id, err = insertStroke(ctx, tx, uid, 0, s.simplified(st)); err != nil { return out, fmt.Errorf("user %s: %w", u.Email, err) }
+			if !st.CreatedAt.IsZero() {
+				if _, err = tx.ExecContext(ctx, "UPDATE strokes SET created_at = ? WHERE id = ?", sqliteTime(st.CreatedAt), id); err != nil { return out, err }
+			}
+			out.Strokes++
+		}
+	}
+	err = tx.Commit()
+	return out, err
+}
+
+// sqliteTime formats t like CURRENT_TIMESTAMP so it compares correctly with stored times; the zero
+// time is NULL
+func sqliteTime(t time.Time) any {
+	if t.IsZero() { return nil }
+	return t.UTC().Format(sqliteTimeLayout)
+}
@@ -0,0 +1,27 @@
+package db
+
+import (
+	"testing"
+)
+
+func TestImportUsers_UserWithoutBoardsGetsADefault(t *testing.T) {
+	store, _, _ := openImportStore(t)
+	st := importStroke(7)
+	st.BoardID, st.CreatedBy = 123, 456 // neither is in the import
+	res, err := store.ImportUsers([]ImportUser{{ID: 1, Email: "carol@example.com", Strokes: []Stroke{st}}})
+	if err != nil {
+		t.Fatalf("Should not return error: %v", err)
+	}
+	if res != (ImportResult{Users: 1, Strokes: 1}) {
+		t.Fatalf("Expected 1 user and 1 stroke, got %+v", res)
+	}
+	carol, _ := store.GetUserByEmail("carol@example.com")
+	boards, _ := store.ListBoardsByUser(carol.ID)
+	if len(boards) != 1 || boards[0].Name != DefaultBoardName {
+		t.Fatalf("Expected a default board, got %+v", boards)
+	}
+	strokes, _ := store.ListStrokesByUser(carol.ID)
+	if len(strokes) != 1 || strokes[0].BoardID != boards[0].ID || strokes[0].CreatedBy != carol.ID {
+		t.Fatalf("Expected the stroke on carol's default board and drawn by her, got %+v", strokes)
+	}
+}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const sqliteTimeLayout = "2006-01-02 15:04:05"
+
+// PurgeStrokesBefore deletes every stroke created before cutoff and returns how many were removed
+func (s *Store) PurgeStrokesBefore(cutoff time.Time) (int64, error) {
+	return s.PurgeStrokesBeforeContext(context.Background(), cutoff)
+}
+
+func (s *Store) PurgeStrokesBeforeContext(ctx context.Context, cutoff time.Time) (int64, error) {
+	release, err := s.lockWriteContext(ctx)
+	if err != nil { return 0, err }
+	defer release()
+	ts := cutoff.UTC().Format(sqliteTimeLayout)
+	tx, err := s.SQL.BeginTx(ctx, nil)
+	if err != nil { return 0, err }
+	defer func(){ if err != nil { _ = tx.Rollback() } }()
+	// Points are removed explicitly so the purge works even when foreign keys are off
+	if _, err = tx.ExecContext(ctx, "DELETE FROM stroke_points WHERE stroke_id IN (SELECT id FROM strokes WHERE created_at < ?)", ts); err != nil { return 0, err }
+	res, err := tx.ExecContext(ctx, "DELETE FROM strokes WHERE created_at < ?", ts)
+	if err != nil { return 0, err }
+	n, err := res.RowsAffected()
+	if err != nil { return 0, err }
+	if err = tx.Commit(); err != nil { return 0, err }
+	return n, nil
+}
+
+// RunRetention purges strokes older than retention every interval until stop is closed
+func (s *Store) RunRetention(retention, interval time.Duration, stop <-chan struct{}) {
+	if retention <= 0 { return }
+	if interval <= 0 { interval = time.Hour }
+	purge := func() {
+		n, err := s.PurgeStrokesBefore(time.Now().Add(-retention))
+		if err != nil { log.Printf("retention purge: %v", err); return }
+		log.Printf("retention purge: removed %d strokes older than %v", n, retention)
+	}
+	purge()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			purge()
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestPurgeStrokesBefore(t *testing.T) {
+	tmpFile := "test_retention.db"
+	defer os.Remove(tmpFile)
+
+	store, err := Open(tmpFile)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer store.SQL.Close()
+
+	userID, err := store.CreateUser("test@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	oldID, err := store.SaveStroke(userID, "#000000", 2, 0, []StrokePoint{{X: 1, Y: 1}, {X: 2, Y: 2}})
+	if err != nil {
+		t.Fatalf("Failed to save old stroke: %v", err)
+	}
+	newID, err := store.SaveStroke(userID, "#000000", 2, 0, []StrokePoint{{X: 3, Y: 3}, {X: 4, Y: 4}})
+	if err != nil {
+		t.Fatalf("Failed to save new stroke: %v", err)
+	}
+
+	// Age the first stroke well past the retention window
+	if _, err := store.SQL.Exec("UPDATE strokes SET created_at = '2000-01-01 00:00:00' WHERE id = ?", oldID); err != nil {
+		t.Fatalf("Failed to age stroke: %v", err)
+	}
+
+	n, err := store.PurgeStrokesBefore(time.Now().Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to purge strokes: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("Expected 1 purged stroke, got %d", n)
+	}
+
+	strokes, err := store.ListStrokesByUser(userID)
+	if err != nil {
+		t.Fatalf("Failed to list strokes: %v", err)
+	}
+	if len(strokes) != 1 || strokes[0].ID != newID {
+		t.Fatalf("Expected only stroke %d to be retained, got %v", newID, strokes)
+	}
+
+	var orphaned int
+	if err := store.SQL.QueryRow("SELECT COUNT(*) FROM stroke_points WHERE stroke_id = ?", oldID).Scan(&orphaned); err != nil {
+		t.Fatalf("Failed to count points: %v", err)
+	}
+	if orphaned != 0 {
+		t.Fatalf("Expected purged stroke points to be removed, got %d", orphaned)
+	}
+}
@@ -0,0 +1,25 @@
+package db
+
+import "context"
+
+// CountSessions groups userID's strokes by start time into drawing sessions, a new one beginning
+// wherever consecutive strokes start more than gapMs apart. Strokes without a start time (imported
+// ones may carry 0) are not counted.
+func (s *Store) CountSessions(userID, gapMs int64) (int, error) {
+	return s.CountSessionsContext(context.Background(), userID, gapMs)
+}
+
+func (s *Store) CountSessionsContext(ctx context.Context, userID, gapMs int64) (int, error) {
+	rows, err := s.SQL.QueryContext(ctx, "SELECT started_at_unix_ms FROM strokes WHERE user_id = ? AND started_at_unix_ms > 0 ORDER BY started_at_unix_ms", userID)
+	if err != nil { return 0, err }
+	defer rows.Close()
+	sessions := 0
+	var last int64
+	for rows.Next() {
+		var at int64
+		if err := rows.Scan(&at); err != nil { return 0, err }
+		if sessions == 0 || at-last > gapMs { sessions++ }
+		last = at
+	}
+	return sessions, rows.Err()
+}
@@ -0,0 +1,32 @@
+package db
+
+import "testing"
+
+func TestCountSessions(t *testing.T) {
+	store, alice, bob := openImportStore(t)
+	const minute = 60 * 1000
+	for _, at := range []int64{0, 10 * minute, 11 * minute, 12 * minute, 90 * minute, 91 * minute} {
+		if _, err := store.SaveStroke(alice, "#000000", 2, at, []StrokePoint{{X: 1, Y: 1}}); err != nil {
+			t.Fatalf("Failed to save stroke: %v", err)
+		}
+	}
+	if _, err := store.SaveStroke(bob, "#000000", 2, 50*minute, []StrokePoint{{X: 1, Y: 1}}); err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+	for _, tc := range []struct {
+		gap  int64
+		want int
+	}{
+		{30 * minute, 2}, // the hour-long gap splits; the 0 start time is skipped
+		{2 * minute, 2},
+		{30 * 1000, 5},
+		{2 * 60 * minute, 1},
+	} {
+		if got, err := store.CountSessions(alice, tc.gap); err != nil || got != tc.want {
+			t.Fatalf("Gap %dms: expected %d sessions, got %d (%v)", tc.gap, tc.want, got, err)
+		}
+	}
+	if got, _ := store.CountSessions(alice+bob+1, minute); got != 0 {
+		t.Fatalf("Expected no sessions for a user without strokes, got %d", got)
+	}
+}
@@ -0,0 +1,51 @@
+package db
+
+import "math"
+
+// SetSimplifyEpsilon makes SaveStroke and SaveStrokes drop points that lie within epsilon pixels
+// of the simplified line (see Simplify); epsilon <= 0 stores points as given. Call it before the
+// store is shared, typically right after Open.
+func (s *Store) SetSimplifyEpsilon(epsilon float64) { s.simplifyEpsilon = epsilon }
+
+// simplified is st with its points simplified under the store's epsilon
+func (s *Store) simplified(st Stroke) Stroke {
+	if s.simplifyEpsilon > 0 { st.Points = Simplify(st.Points, s.simplifyEpsilon) }
+	return st
+}
+
+// Simplify reduces a polyline with Ramer–Douglas–Peucker: a point is kept only when it is more
+// than epsilon from the segment joining the points kept around it. The first and last points are
+// always kept, so a straight line collapses to its two ends. points is not modified.
+func Simplify(points []StrokePoint, epsilon float64) []StrokePoint {
+	if len(points) <= 2 || epsilon <= 0 { return append([]StrokePoint(nil), points...) }
+	keep := make([]bool, len(points))
+	keep[0], keep[len(points)-1] = true, true
+	// An explicit stack of spans, since a 10000-point stroke could recurse that deep
+	stack := [][2]int{{0, len(points) - 1}}
+	for len(stack) > 0 {
+		span := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		first, last := span[0], span[1]
+		far, farDist := -1, epsilon
+		for i := first + 1; i < last; i++ {
+			if d := segmentDistance(points[i], points[first], points[last]); d > farDist { far, farDist = i, d }
+		}
+		if far < 0 { continue }
+		keep[far] = true
+		stack = append(stack, [2]int{first, far}, [2]int{far, last})
+	}
+	out := make([]StrokePoint, 0, len(points))
+	for i, p := range points {
+		if keep[i] { out = append(out, p) }
+	}
+	return out
+}
+
+// segmentDistance is the distance from p to the segment a-b; a closed stroke (a == b) measures
+// from a, so loops keep their far side
+func segmentDistance(p, a, b StrokePoint) float64 {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	t := 0.0
+	if l := dx*dx + dy*dy; l > 0 { t = math.Max(0, math.Min(1, ((p.X-a.X)*dx+(p.Y-a.Y)*dy)/l)) }
+	return math.Hypot(p.X-(a.X+t*dx), p.Y-(a.Y+t*dy))
+}
@@ -0,0 +1,125 @@
+package db
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/deliium/drawing-board/internal/recognize"
+)
+
+func TestSimplify_KnownPolyline(t *testing.T) {
+	// The classic RDP example: with epsilon 1 only the corners that bend the line survive
+	in := []StrokePoint{{0, 0}, {1, 0.1}, {2, -0.1}, {3, 5}, {4, 6}, {5, 7}, {6, 8.1}, {7, 9}, {8, 9}, {9, 9}}
+	want := []StrokePoint{{0, 0}, {2, -0.1}, {3, 5}, {7, 9}, {9, 9}}
+	if got := Simplify(in, 1); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	if len(in) != 10 || in[1] != (StrokePoint{1, 0.1}) {
+		t.Fatal("Expected the input to be left alone")
+	}
+}
+
+func TestSimplify_StraightLineCollapsesToEnds(t *testing.T) {
+	var line []StrokePoint
+	for i := 0; i <= 100; i++ { line = append(line, StrokePoint{X: float64(i), Y: 2*float64(i) + 0.01*math.Sin(float64(i))}) }
+	got := Simplify(line, 0.5)
+	if len(got) != 2 || got[0] != line[0] || got[1] != line[100] {
+		t.Fatalf("Expected only the endpoints, got %v", got)
+	}
+}
+
+func TestSimplify_KeepsEndpointsAndLoops(t *testing.T) {
+	// A closed square starts and ends at the same point; its far corners must survive
+	square := []StrokePoint{{0, 0}, {5, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}}
+	got := Simplify(square, 0.5)
+	want := []StrokePoint{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected the square's corners, got %v", got)
+	}
+	for _, in := range [][]StrokePoint{nil, {{1, 1}}, {{1, 1}, {2, 2}}} {
+		if got := Simplify(in, 10); len(got) != len(in) {
+			t.Fatalf("Expected %v unchanged, got %v", in, got)
+		}
+	}
+	if got := Simplify(square, 0); !reflect.DeepEqual(got, square) {
+		t.Fatalf("Expected epsilon 0 to keep every point, got %v", got)
+	}
+}
+
+func TestSaveStroke_SimplifiesWithEpsilon(t *testing.T) {
+	store, alice, _ := openImportStore(t)
+	var dense []StrokePoint
+	for x := 0; x <= 200; x++ { dense = append(dense, StrokePoint{X: float64(x), Y: 100}) }
+	store.SetSimplifyEpsilon(1)
+	if _, err := store.SaveStroke(alice, "#000000", 2, 0, dense); err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+	if _, err := store.SaveStrokes(alice, []Stroke{{Color: "#000000", Width: 2, Points: dense}}, IDFresh); err != nil {
+		t.Fatalf("Failed to save strokes: %v", err)
+	}
+	store.SetSimplifyEpsilon(0)
+	if _, err := store.SaveStroke(alice, "#000000", 2, 0, dense); err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+	rows, err := store.ListStrokesByUser(alice)
+	if err != nil {
+		t.Fatalf("Failed to list strokes: %v", err)
+	}
+	if got := []int{len(rows[0].Points), len(rows[1].Points), len(rows[2].Points)}; !reflect.DeepEqual(got, []int{2, 2, 201}) {
+		t.Fatalf("Expected 2, 2 and 201 stored points, got %v", got)
+	}
+}
+
+func TestSimplify_RecognitionUnchanged(t *testing.T) {
+	// Hand-drawn shapes: dense, slightly wobbly strokes
+	wobbly := func(x0, y0, x1, y1 float64) []StrokePoint {
+		var pts []StrokePoint
+		for i := 0; i <= 60; i++ {
+			f := float64(i) / 60
+			pts = append(pts, StrokePoint{X: x0 + f*(x1-x0) + 0.4*math.Sin(f*20), Y: y0 + f*(y1-y0) + 0.4*math.Cos(f*20)})
+		}
+		return pts
+	}
+	shapes := map[string][][]StrokePoint{
+		"一": {wobbly(50, 150, 250, 150)},
+		"十": {wobbly(50, 150, 250, 150), wobbly(150, 50, 150, 250)},
+		"三": {wobbly(80, 80, 220, 80), wobbly(90, 150, 210, 150), wobbly(60, 220, 240, 220)},
+		"二": {wobbly(80, 100, 220, 100), wobbly(50, 200, 250, 200)},
+	}
+	toRecognize := func(strokes [][]StrokePoint) []recognize.Stroke {
+		out := make([]recognize.Stroke, 0, len(strokes))
+		for _, s := range strokes {
+			pts := make([]recognize.Point, 0, len(s))
+			for _, p := range s { pts = append(pts, recognize.Point{X: p.X, Y: p.Y}) }
+			out = append(out, recognize.Stroke{Points: pts})
+		}
+		return out
+	}
+	// Only the top candidate is compared: the simple recognizer also pads dense drawings (over 20
+	// points) with 書 and 字, which simplification rightly stops triggering
+	r := recognize.NewSimpleRecognizer()
+	for name, strokes := range shapes {
+		simplified := make([][]StrokePoint, 0, len(strokes))
+		for _, s := range strokes {
+			simplified = append(simplified, Simplify(s, 2))
+			if n := len(simplified[len(simplified)-1]); n >= len(s) {
+				t.Fatalf("%s: expected the wobbly stroke to lose points, kept %d of %d", name, n, len(s))
+			}
+		}
+		before, err := r.Recognize(toRecognize(strokes), 300, 300, 3)
+		if err != nil {
+			t.Fatalf("%s: failed to recognize: %v", name, err)
+		}
+		after, err := r.Recognize(toRecognize(simplified), 300, 300, 3)
+		if err != nil {
+			t.Fatalf("%s: failed to recognize: %v", name, err)
+		}
+		if len(before) == 0 || len(after) == 0 || before[0].Text != name || after[0].Text != name {
+			t.Fatalf("%s: expected the same top candidate, got %v and %v", name, before, after)
+		}
+		if math.Abs(before[0].Score-after[0].Score) > 0.05 {
+			t.Fatalf("%s: expected a similar top score, got %.2f and %.2f", name, before[0].Score, after[0].Score)
+		}
+	}
+}
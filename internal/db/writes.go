@@ -0,0 +1,32 @@
+package db
+
+import "context"
+
+// DefaultMaxWriters matches SQLite's single writer: writes queue in-process instead of
+// contending for the database lock and burning busy_timeout retries
+const DefaultMaxWriters = 1
+
+// SetMaxWriters bounds how many write transactions run at once; n <= 0 removes the bound.
+// Call it before the store is shared, typically right after Open. Reads are never limited.
+func (s *Store) SetMaxWriters(n int) {
+	if n <= 0 { s.writes = nil; return }
+	s.writes = make(chan struct{}, n)
+}
+
+// lockWrite waits for a write slot and returns its release; use as `defer s.lockWrite()()`.
+// A Store built without Open has no limit.
+func (s *Store) lockWrite() func() {
+	release, _ := s.lockWriteContext(context.Background())
+	return release
+}
+
+// lockWriteContext is lockWrite giving up with ctx.Err() when ctx ends while queued for a slot
+func (s *Store) lockWriteContext(ctx context.Context) (func(), error) {
+	if s.writes == nil { return func() {}, nil }
+	select {
+	case s.writes <- struct{}{}:
+		return func() { <-s.writes }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
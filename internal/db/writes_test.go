@@ -0,0 +1,112 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestConcurrentSaveStroke_AllSucceed(t *testing.T) {
+	store, alice, _ := openImportStore(t)
+	const n = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := store.SaveStroke(alice, "#000000", 2, int64(i), []StrokePoint{{X: float64(i), Y: 1}, {X: 2, Y: 3}})
+			if err != nil { errs <- fmt.Errorf("stroke %d: %w", i, err) }
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("Concurrent save should not fail: %v", err)
+	}
+	strokes, err := store.ListStrokesByUser(alice)
+	if err != nil {
+		t.Fatalf("Failed to list strokes: %v", err)
+	}
+	if len(strokes) != n {
+		t.Fatalf("Expected %d strokes, got %d", n, len(strokes))
+	}
+}
+
+func TestLockWrite_BoundsConcurrency(t *testing.T) {
+	store := &Store{}
+	store.SetMaxWriters(2)
+	var active, peak int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer store.lockWrite()()
+			now := atomic.AddInt32(&active, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if now <= p || atomic.CompareAndSwapInt32(&peak, p, now) { break }
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&active, -1)
+		}()
+	}
+	wg.Wait()
+	if peak != 2 {
+		t.Fatalf("Expected at most 2 concurrent writers (and to reach 2), got %d", peak)
+	}
+}
+
+func TestLockWrite_ReadsNotBlocked(t *testing.T) {
+	store, alice, _ := openImportStore(t)
+	if _, err := store.SaveStrokeRecord(alice, importStroke(0)); err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+	release := store.lockWrite()
+	defer release()
+
+	done := make(chan error, 1)
+	go func() { _, err := store.ListStrokesByUser(alice); done <- err }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Read should succeed while a write slot is held: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Read blocked behind the write limit")
+	}
+}
+
+func TestSetMaxWriters_Unlimited(t *testing.T) {
+	store := &Store{}
+	store.SetMaxWriters(0)
+	a, b := store.lockWrite(), store.lockWrite()
+	a(); b()
+	if store.writes != nil {
+		t.Fatalf("Expected no write limit")
+	}
+}
+
+func TestSaveStrokeContext_CanceledWhileQueued(t *testing.T) {
+	store, alice, _ := openImportStore(t)
+	release := store.lockWrite()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := store.SaveStrokeRecordContext(ctx, alice, importStroke(0))
+	release()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected a queued write to give up with its context, got %v", err)
+	}
+	strokes, err := store.ListStrokesByUser(alice)
+	if err != nil {
+		t.Fatalf("Failed to list strokes: %v", err)
+	}
+	if len(strokes) != 0 {
+		t.Fatalf("Expected the abandoned write to save nothing, got %d strokes", len(strokes))
+	}
+}
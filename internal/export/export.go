@@ -0,0 +1,142 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/deliium/drawing-board/internal/db"
+	"github.com/deliium/drawing-board/internal/raster"
+)
+
+// SVG renders strokes as polylines on a white width x height canvas
+func SVG(strokes []db.Stroke, width, height int) []byte {
+	return SVGCanvas(strokes, db.BoardSettings{Width: width, Height: height, Background: db.DefaultBackground})
+}
+
+// SVGCanvas renders strokes on canvas, filled with its background
+func SVGCanvas(strokes []db.Stroke, canvas db.BoardSettings) []byte {
+	width, height := canvas.Width, canvas.Height
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, width, height, width, height)
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="%s"/>`, html.EscapeString(hexOr(canvas.Background, db.DefaultBackground)))
+	for _, s := range strokes {
+		if len(s.Points) == 0 { continue }
+		pts := make([]string, 0, len(s.Points))
+		for _, p := range s.Points { pts = append(pts, strconv.FormatFloat(p.X, 'f', -1, 64)+","+strconv.FormatFloat(p.Y, 'f', -1, 64)) }
+		dash := ""
+		if p := dashPattern(s.LineStyle, s.Width); p != nil {
+			dash = ` stroke-dasharray="` + strconv.FormatFloat(p[0], 'f', -1, 64) + " " + strconv.FormatFloat(p[1], 'f', -1, 64) + `"`
+		}
+		fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="%d" stroke-linecap="round" stroke-linejoin="round"%s/>`,
+			strings.Join(pts, " "), html.EscapeString(hexOr(s.Color, "#000000")), s.Width, dash)
+	}
+	b.WriteString(`</svg>`)
+	return b.Bytes()
+}
+
+// PNG rasterizes strokes onto a white width x height image, refusing sizes over raster.MaxPixels
+func PNG(strokes []db.Stroke, width, height int) ([]byte, error) {
+	return PNGCanvas(strokes, db.BoardSettings{Width: width, Height: height, Background: db.DefaultBackground})
+}
+
+// PNGCanvas rasterizes strokes onto canvas, filled with its background
+func PNGCanvas(strokes []db.Stroke, canvas db.BoardSettings) ([]byte, error) {
+	if err := raster.Check(canvas.Width, canvas.Height); err != nil { return nil, err }
+	bg, ok := parseHex(canvas.Background)
+	if !ok { bg = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff} }
+	img := RasterizeOn(strokes, canvas.Width, canvas.Height, bg)
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil { return nil, err }
+	return b.Bytes(), nil
+}
+
+// Rasterize draws each stroke as round-capped segments of its width, dashed or dotted like the SVG
+func Rasterize(strokes []db.Stroke, width, height int) *image.RGBA {
+	return RasterizeOn(strokes, width, height, color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff})
+}
+
+// RasterizeOn is Rasterize onto a background of bg
+func RasterizeOn(strokes []db.Stroke, width, height int, bg color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < len(img.Pix); i += 4 { img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = bg.R, bg.G, bg.B, bg.A }
+	for _, s := range strokes {
+		c := parseColor(s.Color)
+		r := math.Max(0.5, float64(s.Width)/2)
+		pattern := dashPattern(s.LineStyle, s.Width)
+		dist := 0.0 // along the stroke, so dashes continue across points
+		for i, p := range s.Points {
+			if i == 0 { stamp(img, p.X, p.Y, r, c); continue }
+			q := s.Points[i-1]
+			seg := math.Hypot(p.X-q.X, p.Y-q.Y)
+			steps := int(seg) + 1
+			for j := 1; j <= steps; j++ {
+				t := float64(j) / float64(steps)
+				if dashOn(pattern, dist+t*seg) { stamp(img, q.X+t*(p.X-q.X), q.Y+t*(p.Y-q.Y), r, c) }
+			}
+			dist += seg
+		}
+	}
+	return img
+}
+
+// dashPattern is the on/off lengths of a line style, scaled with the stroke width so thumbnails
+// keep the look of the full drawing; nil means solid. The round caps add half a width to each end
+// of a dash, which is what turns the zero-length dashes of dotted lines into dots.
+func dashPattern(style string, width int) []float64 {
+	w := math.Max(1, float64(width))
+	switch style {
+	case db.LineDashed:
+		return []float64{3 * w, 3 * w}
+	case db.LineDotted:
+		return []float64{0, 2 * w}
+	}
+	return nil
+}
+
+// dashOn reports whether distance d along a stroke falls in a dash. Stamps are under a pixel
+// apart, so a dash shorter than a pixel still gets one.
+func dashOn(pattern []float64, d float64) bool {
+	if pattern == nil { return true }
+	return math.Mod(d, pattern[0]+pattern[1]) < math.Max(pattern[0], 1)
+}
+
+func stamp(img *image.RGBA, cx, cy, r float64, c color.RGBA) {
+	b := img.Bounds()
+	for y := int(math.Floor(cy - r)); y <= int(math.Ceil(cy+r)); y++ {
+		for x := int(math.Floor(cx - r)); x <= int(math.Ceil(cx+r)); x++ {
+			if x < b.Min.X || x >= b.Max.X || y < b.Min.Y || y >= b.Max.Y { continue }
+			dx, dy := float64(x)+0.5-cx, float64(y)+0.5-cy
+			if dx*dx+dy*dy <= r*r { img.SetRGBA(x, y, c) }
+		}
+	}
+}
+
+// hexOr returns s when it is a usable color and def otherwise
+func hexOr(s, def string) string {
+	if _, ok := parseHex(s); ok { return s }
+	return def
+}
+
+func parseColor(s string) color.RGBA {
+	c, _ := parseHex(s)
+	return c
+}
+
+// parseHex accepts #rgb and #rrggbb, returning opaque black otherwise
+func parseHex(s string) (color.RGBA, bool) {
+	black := color.RGBA{A: 0xff}
+	if !strings.HasPrefix(s, "#") { return black, false }
+	h := s[1:]
+	if len(h) == 3 { h = string([]byte{h[0], h[0], h[1], h[1], h[2], h[2]}) }
+	if len(h) != 6 { return black, false }
+	v, err := strconv.ParseUint(h, 16, 32)
+	if err != nil { return black, false }
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, true
+}
@@ -0,0 +1,125 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/deliium/drawing-board/internal/db"
+	"github.com/deliium/drawing-board/internal/raster"
+)
+
+var testStrokes = []db.Stroke{
+	{Color: "#ff0000", Width: 4, Points: []db.StrokePoint{{X: 10, Y: 50}, {X: 90, Y: 50}}},
+	{Color: "not-a-color", Width: 2, Points: []db.StrokePoint{{X: 50, Y: 10}, {X: 50, Y: 90}}},
+}
+
+func TestSVG(t *testing.T) {
+	out := string(SVG(testStrokes, 100, 100))
+	if !strings.HasPrefix(out, `<svg xmlns="http://www.w3.org/2000/svg" width="100" height="100"`) {
+		t.Fatalf("Unexpected SVG header: %s", out)
+	}
+	if !strings.Contains(out, `points="10,50 90,50" fill="none" stroke="#ff0000" stroke-width="4"`) {
+		t.Fatalf("Expected red polyline, got %s", out)
+	}
+	if strings.Contains(out, "not-a-color") {
+		t.Fatal("Invalid colors should not be written into the SVG")
+	}
+}
+
+func TestPNG(t *testing.T) {
+	data, err := PNG(testStrokes, 100, 80)
+	if err != nil {
+		t.Fatalf("Failed to encode PNG: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to decode PNG: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 100 || b.Dy() != 80 {
+		t.Fatalf("Expected 100x80, got %v", b)
+	}
+	if r, g, b, _ := img.At(30, 50).RGBA(); r>>8 != 0xff || g != 0 || b != 0 {
+		t.Fatalf("Expected red pixel on the stroke, got %d %d %d", r>>8, g>>8, b>>8)
+	}
+	if r, g, b, _ := img.At(5, 5).RGBA(); r>>8 != 0xff || g>>8 != 0xff || b>>8 != 0xff {
+		t.Fatal("Expected white background")
+	}
+}
+
+func TestSVG_LineStyles(t *testing.T) {
+	strokes := []db.Stroke{
+		{Color: "#000000", Width: 2, LineStyle: db.LineDashed, Points: []db.StrokePoint{{X: 0, Y: 0}, {X: 10, Y: 0}}},
+		{Color: "#000000", Width: 2, LineStyle: db.LineDotted, Points: []db.StrokePoint{{X: 0, Y: 5}, {X: 10, Y: 5}}},
+		{Color: "#000000", Width: 2, LineStyle: db.LineSolid, Points: []db.StrokePoint{{X: 0, Y: 9}, {X: 10, Y: 9}}},
+	}
+	out := string(SVG(strokes, 20, 20))
+	if !strings.Contains(out, `points="0,0 10,0" fill="none" stroke="#000000" stroke-width="2" stroke-linecap="round" stroke-linejoin="round" stroke-dasharray="6 6"/>`) {
+		t.Fatalf("Expected a dashed polyline, got %s", out)
+	}
+	if !strings.Contains(out, `points="0,5 10,5" fill="none" stroke="#000000" stroke-width="2" stroke-linecap="round" stroke-linejoin="round" stroke-dasharray="0 4"/>`) {
+		t.Fatalf("Expected a dotted polyline, got %s", out)
+	}
+	if strings.Count(out, "stroke-dasharray") != 2 {
+		t.Fatalf("Expected solid strokes to have no dash array, got %s", out)
+	}
+}
+
+func TestRasterize_DashedLeavesGaps(t *testing.T) {
+	line := func(style string) db.Stroke {
+		return db.Stroke{Color: "#000000", Width: 2, LineStyle: style, Points: []db.StrokePoint{{X: 5, Y: 10}, {X: 95, Y: 10}}}
+	}
+	inked := func(style string) int {
+		img := Rasterize([]db.Stroke{line(style)}, 100, 20)
+		n := 0
+		for x := 0; x < 100; x++ {
+			if img.RGBAAt(x, 10).R == 0 { n++ }
+		}
+		return n
+	}
+	solid, dashed, dotted := inked(db.LineSolid), inked(db.LineDashed), inked(db.LineDotted)
+	if solid < 90 {
+		t.Fatalf("Expected a solid line across the image, got %d inked pixels", solid)
+	}
+	if dashed >= solid || dashed < solid/2 {
+		t.Fatalf("Expected dashes to cover roughly two thirds of the line, got %d of %d", dashed, solid)
+	}
+	if dotted >= dashed || dotted == 0 {
+		t.Fatalf("Expected dots to cover less than dashes, got %d (dashed %d)", dotted, dashed)
+	}
+	if img := Rasterize([]db.Stroke{line(db.LineDashed)}, 100, 20); img.RGBAAt(5+6+2, 10).R != 0xff {
+		t.Fatal("Expected a gap after the first dash")
+	}
+}
+
+func TestPNG_RespectsMaxRasterPixels(t *testing.T) {
+	defer raster.SetMaxPixels(0)
+	raster.SetMaxPixels(100 * 100)
+
+	if _, err := PNG(testStrokes, 100, 100); err != nil {
+		t.Fatalf("Expected 100x100 to fit the limit: %v", err)
+	}
+	if _, err := PNG(testStrokes, 101, 100); !errors.Is(err, raster.ErrTooLarge) {
+		t.Fatalf("Expected ErrTooLarge, got %v", err)
+	}
+}
+
+func TestCanvas_PaintsBackground(t *testing.T) {
+	canvas := db.BoardSettings{Width: 40, Height: 30, Background: "#112233"}
+	if out := string(SVGCanvas(nil, canvas)); !strings.Contains(out, `width="40" height="30"`) || !strings.Contains(out, `fill="#112233"`) {
+		t.Fatalf("Expected a 40x30 SVG on #112233, got %s", out)
+	}
+	data, err := PNGCanvas(nil, canvas)
+	if err != nil {
+		t.Fatalf("Failed to render PNG: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to decode PNG: %v", err)
+	}
+	if r, g, b, _ := img.At(39, 29).RGBA(); r>>8 != 0x11 || g>>8 != 0x22 || b>>8 != 0x33 {
+		t.Fatalf("Expected the background in the corner, got %x %x %x", r>>8, g>>8, b>>8)
+	}
+}
@@ -0,0 +1,58 @@
+package export
+
+import (
+	"math"
+
+	"github.com/deliium/drawing-board/internal/db"
+)
+
+// DefaultThumbnailSize is the side of a gallery thumbnail in pixels
+const DefaultThumbnailSize = 128
+
+// Thumbnail renders strokes scaled to fit a size x size PNG with a small margin. Without a stored
+// canvas the drawing's own bounding box is what gets fitted.
+func Thumbnail(strokes []db.Stroke, size int) ([]byte, error) {
+	return PNG(fitStrokes(strokes, size), size, size)
+}
+
+// ThumbnailCanvas fits canvas rather than the drawing into the size x size PNG, so the thumbnail
+// shows the board as it is laid out, on its background; strokes off the canvas are cut off
+func ThumbnailCanvas(strokes []db.Stroke, size int, canvas db.BoardSettings) ([]byte, error) {
+	scale := float64(size) / math.Max(float64(canvas.Width), float64(canvas.Height))
+	offX := (float64(size) - float64(canvas.Width)*scale) / 2
+	offY := (float64(size) - float64(canvas.Height)*scale) / 2
+	return PNGCanvas(scaleStrokes(strokes, scale, offX, offY, 0, 0), db.BoardSettings{Width: size, Height: size, Background: canvas.Background})
+}
+
+// fitStrokes maps strokes into a size x size box, centered and aspect-preserving; widths shrink with the drawing but stay visible
+func fitStrokes(strokes []db.Stroke, size int) []db.Stroke {
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, s := range strokes {
+		for _, p := range s.Points {
+			minX, maxX = math.Min(minX, p.X), math.Max(maxX, p.X)
+			minY, maxY = math.Min(minY, p.Y), math.Max(maxY, p.Y)
+		}
+	}
+	if math.IsInf(minX, 1) { return nil }
+	margin := float64(size) / 16
+	span := math.Max(math.Max(maxX-minX, maxY-minY), 1)
+	scale := (float64(size) - 2*margin) / span
+	offX := (float64(size) - (maxX-minX)*scale) / 2
+	offY := (float64(size) - (maxY-minY)*scale) / 2
+	return scaleStrokes(strokes, scale, offX, offY, minX, minY)
+}
+
+// scaleStrokes maps each point p to off + (p-min)*scale
+func scaleStrokes(strokes []db.Stroke, scale, offX, offY, minX, minY float64) []db.Stroke {
+	out := make([]db.Stroke, 0, len(strokes))
+	for _, s := range strokes {
+		fit := s
+		fit.Width = int(math.Max(2, math.Round(float64(s.Width)*scale))) // thinner lines vanish under disc stamping
+		fit.Points = make([]db.StrokePoint, len(s.Points))
+		for i, p := range s.Points {
+			fit.Points[i] = db.StrokePoint{X: offX + (p.X-minX)*scale, Y: offY + (p.Y-minY)*scale}
+		}
+		out = append(out, fit)
+	}
+	return out
+}
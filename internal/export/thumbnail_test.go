@@ -0,0 +1,66 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"testing"
+
+	"github.com/deliium/drawing-board/internal/db"
+	"github.com/deliium/drawing-board/internal/raster"
+)
+
+func TestThumbnail(t *testing.T) {
+	// A drawing far from the origin still lands inside the thumbnail
+	far := []db.Stroke{{Color: "#000000", Width: 20, Points: []db.StrokePoint{{X: 1000, Y: 1000}, {X: 2000, Y: 1000}}}}
+	out, err := Thumbnail(far, 64)
+	if err != nil {
+		t.Fatalf("Failed to render thumbnail: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Thumbnail is not a PNG: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 64 || b.Dy() != 64 {
+		t.Fatalf("Expected 64x64, got %v", b)
+	}
+	if r, _, _, _ := img.At(32, 32).RGBA(); r != 0 {
+		t.Fatal("Expected the fitted line to cross the center")
+	}
+	if r, _, _, _ := img.At(1, 1).RGBA(); r == 0 {
+		t.Fatal("Expected the margin to stay white")
+	}
+}
+
+func TestThumbnail_Empty(t *testing.T) {
+	if _, err := Thumbnail(nil, 32); err != nil {
+		t.Fatalf("Empty drawings should render a blank thumbnail: %v", err)
+	}
+}
+
+func TestThumbnail_RespectsMaxRasterPixels(t *testing.T) {
+	defer raster.SetMaxPixels(0)
+	raster.SetMaxPixels(32 * 32)
+	if _, err := Thumbnail(testStrokes, 33); !errors.Is(err, raster.ErrTooLarge) {
+		t.Fatalf("Expected ErrTooLarge, got %v", err)
+	}
+}
+
+func TestThumbnailCanvas_FitsCanvasNotDrawing(t *testing.T) {
+	// The line covers the left half of a 200x100 canvas, so it stays in the left half of the thumbnail
+	left := []db.Stroke{{Color: "#000000", Width: 10, Points: []db.StrokePoint{{X: 0, Y: 50}, {X: 100, Y: 50}}}}
+	out, err := ThumbnailCanvas(left, 64, db.BoardSettings{Width: 200, Height: 100, Background: "#ffeedd"})
+	if err != nil {
+		t.Fatalf("Failed to render thumbnail: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Thumbnail is not a PNG: %v", err)
+	}
+	if r, _, _, _ := img.At(16, 32).RGBA(); r != 0 {
+		t.Fatal("Expected the line in the left half")
+	}
+	if r, g, _, _ := img.At(48, 32).RGBA(); r>>8 != 0xff || g>>8 != 0xee {
+		t.Fatalf("Expected the right half to show the background, got %x %x", r>>8, g>>8)
+	}
+}
@@ -0,0 +1,84 @@
+package features
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Known feature names; each optional endpoint is gated on one of these
+const (
+	Export = "export"
+)
+
+// Defaults lists every known feature and whether it is on when not configured
+var Defaults = map[string]bool{
+	Export: true,
+}
+
+// Features is the set of toggles an operator configured; a nil *Features enables everything
+type Features struct {
+	// DisabledStatus is returned by Require for a disabled feature (404 or 501)
+	DisabledStatus int
+
+	mu      sync.RWMutex
+	enabled map[string]bool
+}
+
+// New starts from Defaults and applies spec, a comma-separated list like "export,-beautify"
+func New(spec string) (*Features, error) {
+	f := &Features{DisabledStatus: http.StatusNotFound, enabled: make(map[string]bool, len(Defaults))}
+	for name, on := range Defaults { f.enabled[name] = on }
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" { continue }
+		on := true
+		switch item[0] {
+		case '-': on, item = false, item[1:]
+		case '+': item = item[1:]
+		}
+		if _, ok := Defaults[item]; !ok { return nil, fmt.Errorf("unknown feature %q", item) }
+		f.enabled[item] = on
+	}
+	return f, nil
+}
+
+// IsEnabled reports whether name is switched on; unknown names are off
+func (f *Features) IsEnabled(name string) bool {
+	if f == nil { _, ok := Defaults[name]; return ok }
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.enabled[name]
+}
+
+// Set toggles name at runtime
+func (f *Features) Set(name string, on bool) {
+	f.mu.Lock()
+	f.enabled[name] = on
+	f.mu.Unlock()
+}
+
+// Enabled returns the sorted names of every enabled feature
+func (f *Features) Enabled() []string {
+	var out []string
+	for name := range Defaults {
+		if f.IsEnabled(name) { out = append(out, name) }
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Require serves next only while name is enabled and answers DisabledStatus otherwise
+func (f *Features) Require(name string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !f.IsEnabled(name) {
+			status := http.StatusNotFound
+			if f != nil && f.DisabledStatus != 0 { status = f.DisabledStatus }
+			http.Error(w, fmt.Sprintf("feature %q is not enabled", name), status)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
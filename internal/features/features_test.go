@@ -0,0 +1,83 @@
+package features
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+
+func TestNew_Defaults(t *testing.T) {
+	f, err := New("")
+	if err != nil {
+		t.Fatalf("Failed to build features: %v", err)
+	}
+	if !f.IsEnabled(Export) {
+		t.Fatal("Export should be enabled by default")
+	}
+	if f.IsEnabled("nope") {
+		t.Fatal("Unknown features should be disabled")
+	}
+}
+
+func TestNew_Spec(t *testing.T) {
+	f, err := New(" -export ")
+	if err != nil {
+		t.Fatalf("Failed to build features: %v", err)
+	}
+	if f.IsEnabled(Export) {
+		t.Fatal("Export should be disabled by -export")
+	}
+	if got := f.Enabled(); len(got) != 0 {
+		t.Fatalf("Expected no enabled features, got %v", got)
+	}
+	f, err = New("+export")
+	if err != nil || !reflect.DeepEqual(f.Enabled(), []string{Export}) {
+		t.Fatalf("Expected [export], got %v (%v)", f.Enabled(), err)
+	}
+	if _, err := New("export,teleport"); err == nil {
+		t.Fatal("Expected error for unknown feature")
+	}
+}
+
+func TestRequire_DisabledStatus(t *testing.T) {
+	for _, status := range []int{http.StatusNotFound, http.StatusNotImplemented} {
+		f, _ := New("-export")
+		f.DisabledStatus = status
+		rec := httptest.NewRecorder()
+		f.Require(Export, okHandler).ServeHTTP(rec, httptest.NewRequest("GET", "/api/export.png", nil))
+		if rec.Code != status {
+			t.Fatalf("Expected %d for disabled feature, got %d", status, rec.Code)
+		}
+	}
+}
+
+func TestRequire_EnabledAndToggled(t *testing.T) {
+	f, _ := New("")
+	h := f.Require(Export, okHandler)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 for enabled feature, got %d", rec.Code)
+	}
+	f.Set(Export, false)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("Expected 404 after disabling, got %d", rec.Code)
+	}
+}
+
+func TestNilFeatures(t *testing.T) {
+	var f *Features
+	if !f.IsEnabled(Export) {
+		t.Fatal("Nil features should enable known features")
+	}
+	rec := httptest.NewRecorder()
+	f.Require(Export, okHandler).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+}
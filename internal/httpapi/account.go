@@ -0,0 +1,54 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/deliium/drawing-board/internal/db"
+)
+
+// MergeRequest names the account to fold into another. A user merging one of their own accounts
+// into the signed-in one proves ownership with the source's credentials; an admin gives both IDs.
+type MergeRequest struct {
+	SourceEmail    string `json:"sourceEmail"`
+	SourcePassword string `json:"sourcePassword"`
+	SourceID       int64  `json:"sourceId"` // admin only, with TargetID
+	TargetID       int64  `json:"targetId"`
+}
+
+type MergeResponse struct {
+	SourceID int64 `json:"sourceId"`
+	TargetID int64 `json:"targetId"`
+	db.MergeResult
+}
+
+// MergeAccounts moves every stroke of the source account to the target and deletes the source
+func (a *API) MergeAccounts(w http.ResponseWriter, r *http.Request) {
+	uid, ok := a.Auth.UserIDFromRequest(r)
+	if !ok { writeJSON(w, 401, map[string]string{"error":"unauthorized"}); return }
+	var req MergeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil { writeJSON(w, 400, map[string]string{"error":"invalid json"}); return }
+
+	var source, target int64
+	if req.SourceID != 0 || req.TargetID != 0 {
+		admin, err := a.Auth.IsAdminContext(r.Context(), uid)
+		if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+		if !admin { writeJSON(w, 403, map[string]string{"error":"merging by id requires admin"}); return }
+		if req.SourceID <= 0 || req.TargetID <= 0 { writeJSON(w, 400, map[string]string{"error":"sourceId and targetId are required"}); return }
+		source, target = req.SourceID, req.TargetID
+	} else {
+		u, err := a.Auth.AuthenticateContext(r.Context(), req.SourceEmail, req.SourcePassword)
+		if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+		if u == nil { writeJSON(w, 403, map[string]string{"error":"invalid source credentials"}); return }
+		source, target = u.ID, uid
+	}
+	if source == target { writeJSON(w, 400, map[string]string{"error":"source and target are the same account"}); return }
+
+	res, err := a.Store.MergeUsersContext(r.Context(), source, target)
+	if errors.Is(err, db.ErrNotFound) { writeJSON(w, 404, map[string]string{"error":"user not found"}); return }
+	if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	log.Printf("account merge: user %d into %d, %d strokes moved", source, target, res.MovedStrokes)
+	writeJSON(w, 200, MergeResponse{SourceID: source, TargetID: target, MergeResult: res})
+}
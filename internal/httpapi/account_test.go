@@ -0,0 +1,79 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/deliium/drawing-board/internal/db"
+)
+
+func saveStrokes(t *testing.T, api *API, uid int64, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if _, err := api.Store.SaveStroke(uid, "#000000", 2, 0, []db.StrokePoint{{X: 1, Y: 1}, {X: 2, Y: 2}}); err != nil {
+			t.Fatalf("Failed to save stroke: %v", err)
+		}
+	}
+}
+
+func TestMergeAccounts_SelfService(t *testing.T) {
+	api := newTestAPI(t)
+	target, cookies := registerUser(t, api, "main@example.com")
+	source, _ := registerUser(t, api, "old@example.com")
+	saveStrokes(t, api, target, 1)
+	saveStrokes(t, api, source, 2)
+
+	if rec := do(api.MergeAccounts, "POST", "/api/account/merge", strings.NewReader(`{"sourceEmail":"old@example.com","sourcePassword":"wrong"}`), cookies); rec.Code != 403 {
+		t.Fatalf("Expected 403 for bad source credentials, got %d", rec.Code)
+	}
+	rec := do(api.MergeAccounts, "POST", "/api/account/merge", strings.NewReader(`{"sourceEmail":"OLD@example.com","sourcePassword":"password123"}`), cookies)
+	if rec.Code != 200 {
+		t.Fatalf("Expected 200, got %d %s", rec.Code, rec.Body.String())
+	}
+	var resp MergeResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.SourceID != source || resp.TargetID != target || resp.MovedStrokes != 2 {
+		t.Fatalf("Unexpected merge result %+v", resp)
+	}
+	if strokes, _ := api.Store.ListStrokesByUser(target); len(strokes) != 3 {
+		t.Fatalf("Expected 3 strokes on the target, got %d", len(strokes))
+	}
+	if u, _ := api.Store.GetUserByID(source); u != nil {
+		t.Fatal("Expected the source account to be removed")
+	}
+	if rec := do(api.MergeAccounts, "POST", "/api/account/merge", strings.NewReader(`{"sourceEmail":"main@example.com","sourcePassword":"password123"}`), cookies); rec.Code != 400 {
+		t.Fatalf("Expected 400 merging an account into itself, got %d", rec.Code)
+	}
+}
+
+func TestMergeAccounts_ByIDRequiresAdmin(t *testing.T) {
+	api := newTestAPI(t)
+	api.Auth.AdminEmails = []string{"root@example.com"}
+	_, adminCookies := registerUser(t, api, "root@example.com")
+	target, userCookies := registerUser(t, api, "a@example.com")
+	source, _ := registerUser(t, api, "b@example.com")
+	saveStrokes(t, api, source, 1)
+	body := func() *strings.Reader {
+		b, _ := json.Marshal(MergeRequest{SourceID: source, TargetID: target})
+		return strings.NewReader(string(b))
+	}
+
+	if rec := do(api.MergeAccounts, "POST", "/api/account/merge", body(), userCookies); rec.Code != 403 {
+		t.Fatalf("Expected 403 for a non-admin merging by id, got %d", rec.Code)
+	}
+	if rec := do(api.MergeAccounts, "POST", "/api/account/merge", body(), adminCookies); rec.Code != 200 {
+		t.Fatalf("Expected 200 for an admin, got %d %s", rec.Code, rec.Body.String())
+	}
+	if strokes, _ := api.Store.ListStrokesByUser(target); len(strokes) != 1 {
+		t.Fatalf("Expected the stroke to be reassigned, got %d", len(strokes))
+	}
+	if rec := do(api.MergeAccounts, "POST", "/api/account/merge", body(), adminCookies); rec.Code != 404 {
+		t.Fatalf("Expected 404 once the source is gone, got %d", rec.Code)
+	}
+	if rec := do(api.MergeAccounts, "POST", "/api/account/merge", strings.NewReader(`{}`), nil); rec.Code != 401 {
+		t.Fatalf("Expected 401 when signed out, got %d", rec.Code)
+	}
+}
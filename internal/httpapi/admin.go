@@ -0,0 +1,53 @@
+package httpapi
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/deliium/drawing-board/internal/db"
+)
+
+type MigrateResponse struct {
+	Applied []db.PendingMigration `json:"applied"` // migrations run by this call; empty when already up to date
+	Status  db.SchemaStatus       `json:"status"`
+}
+
+// SchemaStatus reports the applied and pending migrations; admin only
+func (a *API) SchemaStatus(w http.ResponseWriter, r *http.Request) {
+	st, err := a.Store.SchemaStatusContext(r.Context())
+	if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	writeJSON(w, 200, st)
+}
+
+// Migrate applies pending migrations at runtime; admin only and safe to repeat
+func (a *API) Migrate(w http.ResponseWriter, r *http.Request) {
+	ran, err := a.Store.Migrate()
+	if err != nil { writeJSON(w, 500, map[string]any{"error": err.Error(), "applied": ran}); return }
+	if len(ran) > 0 { log.Printf("admin migrate: applied %d migrations", len(ran)) }
+	st, err := a.Store.SchemaStatusContext(r.Context())
+	if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	writeJSON(w, 200, MigrateResponse{Applied: ran, Status: st})
+}
+
+type OptimizeResponse struct {
+	Steps      []db.OptimizeStep `json:"steps"`
+	DurationMs float64           `json:"durationMs"` // total, including waiting for a write slot
+}
+
+// Optimize refreshes SQLite's planner statistics, and rebuilds indexes with ?reindex=true; admin only
+func (a *API) Optimize(w http.ResponseWriter, r *http.Request) {
+	reindex := false
+	if v := r.URL.Query().Get("reindex"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil { writeJSON(w, 400, map[string]string{"error":"bad reindex"}); return }
+		reindex = b
+	}
+	start := time.Now()
+	steps, err := a.Store.OptimizeContext(r.Context(), reindex)
+	ms := float64(time.Since(start).Microseconds()) / 1000
+	if err != nil { writeJSON(w, 500, map[string]any{"error": err.Error(), "steps": steps}); return }
+	log.Printf("admin optimize: reindex=%v in %.1fms", reindex, ms)
+	writeJSON(w, 200, OptimizeResponse{Steps: steps, DurationMs: ms})
+}
@@ -0,0 +1,79 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/deliium/drawing-board/internal/db"
+)
+
+func TestAdminSchema_RequiresAdmin(t *testing.T) {
+	api := newTestAPI(t)
+	api.Auth.AdminEmails = []string{"Root@Example.com"}
+	_, userCookies := registerUser(t, api, "user@example.com")
+	_, adminCookies := registerUser(t, api, "root@example.com")
+	guarded := api.Auth.RequireAdmin(http.HandlerFunc(api.SchemaStatus)).ServeHTTP
+
+	if rec := do(guarded, "GET", "/api/admin/schema", nil, nil); rec.Code != 401 {
+		t.Fatalf("Expected 401 when signed out, got %d", rec.Code)
+	}
+	if rec := do(guarded, "GET", "/api/admin/schema", nil, userCookies); rec.Code != 403 {
+		t.Fatalf("Expected 403 for a non-admin, got %d", rec.Code)
+	}
+	rec := do(guarded, "GET", "/api/admin/schema", nil, adminCookies)
+	if rec.Code != 200 {
+		t.Fatalf("Expected 200 for an admin, got %d %s", rec.Code, rec.Body.String())
+	}
+	var st db.SchemaStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &st); err != nil {
+		t.Fatalf("Failed to decode status: %v", err)
+	}
+	if st.Version != db.LatestVersion() || len(st.Pending) != 0 || len(st.Applied) != db.LatestVersion() {
+		t.Fatalf("Expected an up-to-date schema, got %+v", st)
+	}
+}
+
+func TestAdminMigrate_Idempotent(t *testing.T) {
+	api := newTestAPI(t)
+	for i := 0; i < 2; i++ {
+		rec := do(api.Migrate, "POST", "/api/admin/migrate", nil, nil)
+		if rec.Code != 200 {
+			t.Fatalf("Expected 200, got %d %s", rec.Code, rec.Body.String())
+		}
+		var resp MigrateResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(resp.Applied) != 0 || resp.Status.Version != db.LatestVersion() {
+			t.Fatalf("Expected nothing to apply on an opened store, got %+v", resp)
+		}
+	}
+}
+
+func TestAdminOptimize_PopulatedDatabase(t *testing.T) {
+	api := newTestAPI(t)
+	api.Auth.AdminEmails = []string{"root@example.com"}
+	uid, userCookies := registerUser(t, api, "user@example.com")
+	_, adminCookies := registerUser(t, api, "root@example.com")
+	saveStrokes(t, api, uid, 25)
+	guarded := api.Auth.RequireAdmin(http.HandlerFunc(api.Optimize)).ServeHTTP
+
+	if rec := do(guarded, "POST", "/api/admin/optimize", nil, userCookies); rec.Code != 403 {
+		t.Fatalf("Expected 403 for a non-admin, got %d", rec.Code)
+	}
+	rec := do(guarded, "POST", "/api/admin/optimize?reindex=true", nil, adminCookies)
+	if rec.Code != 200 {
+		t.Fatalf("Expected 200, got %d %s", rec.Code, rec.Body.String())
+	}
+	var resp OptimizeResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Steps) != 3 || resp.Steps[0].Name != "reindex" || resp.DurationMs < 0 {
+		t.Fatalf("Expected reindex, analyze and optimize steps, got %+v", resp)
+	}
+	if rec := do(guarded, "POST", "/api/admin/optimize?reindex=maybe", nil, adminCookies); rec.Code != 400 {
+		t.Fatalf("Expected 400 for a bad reindex flag, got %d", rec.Code)
+	}
+}
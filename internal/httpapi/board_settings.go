@@ -0,0 +1,55 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/deliium/drawing-board/internal/db"
+	"github.com/gorilla/mux"
+)
+
+// BoardSettings is a board's canvas; Saved is false while it still has the defaults
+type BoardSettings struct {
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	Background string `json:"background"`
+	Saved      bool   `json:"saved"`
+}
+
+// maxSettingsBody bounds a settings request; the object itself is well under 100 bytes
+const maxSettingsBody = 4 << 10
+
+// defaultCanvas is what a board without saved settings is exported on
+var defaultCanvas = db.BoardSettings{Width: defaultExportSize, Height: defaultExportSize, Background: db.DefaultBackground}
+
+// canvasFor returns boardID's settings, or defaultCanvas and false when none are saved
+func (a *API) canvasFor(ctx context.Context, boardID int64) (db.BoardSettings, bool, error) {
+	bs, err := a.Store.GetBoardSettingsContext(ctx, boardID)
+	if err != nil || bs == nil { return defaultCanvas, false, err }
+	return *bs, true, nil
+}
+
+// GetBoardSettings returns the canvas of one of the caller's boards
+func (a *API) GetBoardSettings(w http.ResponseWriter, r *http.Request) {
+	uid, ok := a.Auth.UserIDFromRequest(r)
+	if !ok { writeJSON(w, 401, map[string]string{"error":"unauthorized"}); return }
+	b, status, err := a.ownedBoard(r.Context(), uid, mux.Vars(r)["id"])
+	if err != nil { writeJSON(w, status, map[string]string{"error":err.Error()}); return }
+	bs, saved, err := a.canvasFor(r.Context(), b.ID)
+	if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	writeJSON(w, 200, BoardSettings{Width: bs.Width, Height: bs.Height, Background: bs.Background, Saved: saved})
+}
+
+// SetBoardSettings replaces the canvas of one of the caller's boards; every field error is reported
+func (a *API) SetBoardSettings(w http.ResponseWriter, r *http.Request) {
+	uid, ok := a.Auth.UserIDFromRequest(r)
+	if !ok { writeJSON(w, 401, map[string]string{"error":"unauthorized"}); return }
+	b, status, err := a.ownedBoard(r.Context(), uid, mux.Vars(r)["id"])
+	if err != nil { writeJSON(w, status, map[string]string{"error":err.Error()}); return }
+	var req BoardSettings
+	if err := a.decodeLimited(w, r, maxSettingsBody, nil, &req); err != nil { writeJSON(w, 400, map[string]string{"error":err.Error()}); return }
+	bs := db.BoardSettings{Width: req.Width, Height: req.Height, Background: req.Background}
+	if errs := db.NormalizeBoardSettings(&bs); errs.Any() { writeJSON(w, 400, errs.Response()); return }
+	if err := a.Store.SetBoardSettingsContext(r.Context(), b.ID, bs); err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	writeJSON(w, 200, BoardSettings{Width: bs.Width, Height: bs.Height, Background: bs.Background, Saved: true})
+}
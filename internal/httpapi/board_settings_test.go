@@ -0,0 +1,131 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/deliium/drawing-board/internal/db"
+	"github.com/gorilla/mux"
+)
+
+// doSettings runs handler for board id's settings with the given cookies and body
+func doSettings(handler http.HandlerFunc, method string, id int64, body io.Reader, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/api/boards/"+strconv.FormatInt(id, 10)+"/settings", body)
+	req = mux.SetURLVars(req, map[string]string{"id": strconv.FormatInt(id, 10)})
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func TestBoardSettings_RoundTrip(t *testing.T) {
+	api := newTestAPI(t)
+	uid, cookies := registerUser(t, api, "canvas@example.com")
+	board, _ := api.Store.DefaultBoardID(uid)
+
+	var got BoardSettings
+	rec := doSettings(api.GetBoardSettings, "GET", board, nil, cookies)
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || rec.Code != 200 {
+		t.Fatalf("Expected 200, got %d %s", rec.Code, rec.Body.String())
+	}
+	if got != (BoardSettings{Width: defaultExportSize, Height: defaultExportSize, Background: db.DefaultBackground}) {
+		t.Fatalf("Expected unsaved defaults, got %+v", got)
+	}
+
+	rec = doSettings(api.SetBoardSettings, "POST", board, strings.NewReader(`{"width":640,"height":360,"background":"#0F0"}`), cookies)
+	if rec.Code != 200 {
+		t.Fatalf("Expected 200, got %d %s", rec.Code, rec.Body.String())
+	}
+	rec = doSettings(api.GetBoardSettings, "GET", board, nil, cookies)
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || got != (BoardSettings{Width: 640, Height: 360, Background: "#00ff00", Saved: true}) {
+		t.Fatalf("Expected the saved settings back, got %s", rec.Body.String())
+	}
+}
+
+func TestBoardSettings_Rejected(t *testing.T) {
+	api := newTestAPI(t)
+	uid, cookies := registerUser(t, api, "canvas-bad@example.com")
+	board, _ := api.Store.DefaultBoardID(uid)
+	rec := doSettings(api.SetBoardSettings, "POST", board, strings.NewReader(`{"width":0,"height":5000,"background":"red"}`), cookies)
+	var resp struct{ Errors []struct{ Field string } }
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != 400 || len(resp.Errors) != 3 {
+		t.Fatalf("Expected 400 listing three fields, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	other, _ := registerUser(t, api, "canvas-other@example.com")
+	foreign, _ := api.Store.DefaultBoardID(other)
+	if rec := doSettings(api.GetBoardSettings, "GET", foreign, nil, cookies); rec.Code != 404 {
+		t.Fatalf("Expected 404 for someone else's board, got %d", rec.Code)
+	}
+	if rec := doSettings(api.SetBoardSettings, "POST", foreign, strings.NewReader(`{"width":10,"height":10}`), cookies); rec.Code != 404 {
+		t.Fatalf("Expected 404 for someone else's board, got %d", rec.Code)
+	}
+}
+
+// endless is a request body that never ends
+type endless struct{}
+
+func (endless) Read(p []byte) (int, error) {
+	for i := range p { p[i] = ' ' }
+	return len(p), nil
+}
+
+func TestBoardSettings_BodyIsBounded(t *testing.T) {
+	api := newTestAPI(t)
+	uid, cookies := registerUser(t, api, "canvas-big@example.com")
+	board, _ := api.Store.DefaultBoardID(uid)
+	body := io.MultiReader(strings.NewReader(`{"width":640,"height":360,"background":"#0F0"`), endless{})
+	if rec := doSettings(api.SetBoardSettings, "POST", board, body, cookies); rec.Code != 400 {
+		t.Fatalf("Expected an oversized body to be refused with 400, got %d %s", rec.Code, rec.Body.String())
+	}
+	if bs, _ := api.Store.GetBoardSettings(board); bs != nil {
+		t.Fatalf("Expected nothing saved, got %+v", bs)
+	}
+}
+
+func TestExport_UsesBoardSettings(t *testing.T) {
+	api := newTestAPI(t)
+	uid, cookies := registerUser(t, api, "canvas-export@example.com")
+	board, _ := api.Store.DefaultBoardID(uid)
+	if err := api.Store.SetBoardSettings(board, db.BoardSettings{Width: 64, Height: 48, Background: "#102030"}); err != nil {
+		t.Fatalf("Failed to save settings: %v", err)
+	}
+
+	for target, want := range map[string][2]int{
+		"/api/export.png": {64, 48},
+		"/api/export.png?board=" + strconv.FormatInt(board, 10): {64, 48},
+		"/api/export.png?width=20":                              {20, 48},
+	} {
+		rec := do(api.ExportPNG, "GET", target, nil, cookies)
+		img, err := png.Decode(bytes.NewReader(rec.Body.Bytes()))
+		if err != nil {
+			t.Fatalf("%s: failed to decode PNG: %v (%d %s)", target, err, rec.Code, rec.Body.String())
+		}
+		if b := img.Bounds(); b.Dx() != want[0] || b.Dy() != want[1] {
+			t.Fatalf("%s: expected %dx%d, got %v", target, want[0], want[1], b)
+		}
+		if r, g, b, _ := img.At(0, 0).RGBA(); r>>8 != 0x10 || g>>8 != 0x20 || b>>8 != 0x30 {
+			t.Fatalf("%s: expected the board background, got %x %x %x", target, r>>8, g>>8, b>>8)
+		}
+	}
+	rec := do(api.ExportSVG, "GET", "/api/export.svg", nil, cookies)
+	if body := rec.Body.String(); !strings.Contains(body, `width="64" height="48"`) || !strings.Contains(body, `fill="#102030"`) {
+		t.Fatalf("Expected the SVG on the saved canvas, got %s", body)
+	}
+
+	// A board without settings keeps the old default size
+	second, _ := api.Store.CreateBoard(uid, "Second")
+	rec = do(api.ExportPNG, "GET", "/api/export.png?board="+strconv.FormatInt(second, 10), nil, cookies)
+	if img, err := png.Decode(bytes.NewReader(rec.Body.Bytes())); err != nil || img.Bounds().Dx() != defaultExportSize {
+		t.Fatalf("Expected a %dpx export for a board without settings, got %d %v", defaultExportSize, rec.Code, err)
+	}
+}
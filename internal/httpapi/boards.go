@@ -0,0 +1,73 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/deliium/drawing-board/internal/db"
+)
+
+type Board struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type BoardRequest struct {
+	Name string `json:"name"`
+}
+
+// ListBoards returns the caller's boards, default board first
+func (a *API) ListBoards(w http.ResponseWriter, r *http.Request) {
+	uid, ok := a.Auth.UserIDFromRequest(r)
+	if !ok { writeJSON(w, 401, map[string]string{"error":"unauthorized"}); return }
+	boards, err := a.Store.ListBoardsByUserContext(r.Context(), uid)
+	if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	out := make([]Board, 0, len(boards))
+	for _, b := range boards { out = append(out, Board{ID: b.ID, Name: b.Name, CreatedAt: b.CreatedAt}) }
+	writeJSON(w, 200, out)
+}
+
+// CreateBoard adds an empty board for the caller
+func (a *API) CreateBoard(w http.ResponseWriter, r *http.Request) {
+	uid, ok := a.Auth.UserIDFromRequest(r)
+	if !ok { writeJSON(w, 401, map[string]string{"error":"unauthorized"}); return }
+	var req BoardRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil { writeJSON(w, 400, map[string]string{"error":"invalid json"}); return }
+	name := strings.TrimSpace(req.Name)
+	if name == "" { writeJSON(w, 400, map[string]string{"error":"board name is required"}); return }
+	if utf8.RuneCountInString(name) > db.MaxBoardNameLength {
+		writeJSON(w, 400, map[string]string{"error":fmt.Sprintf("board name exceeds %d characters", db.MaxBoardNameLength)}); return
+	}
+	if strings.IndexFunc(name, unicode.IsControl) >= 0 { writeJSON(w, 400, map[string]string{"error":"board name contains control characters"}); return }
+	id, err := a.Store.CreateBoardContext(r.Context(), uid, name)
+	if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	b, err := a.Store.GetBoardContext(r.Context(), id)
+	if err != nil || b == nil { writeJSON(w, 500, map[string]string{"error":"board vanished after create"}); return }
+	writeJSON(w, 201, Board{ID: b.ID, Name: b.Name, CreatedAt: b.CreatedAt})
+}
+
+// ownedBoard resolves a ?board= value for uid; boards of other users are reported as not found
+// so their IDs cannot be probed
+func (a *API) ownedBoard(ctx context.Context, uid int64, v string) (*db.Board, int, error) {
+	id, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || id <= 0 { return nil, 400, fmt.Errorf("bad board") }
+	b, err := a.Store.GetBoardContext(ctx, id)
+	if err != nil { return nil, 500, err }
+	if b == nil || b.OwnerID != uid { return nil, 404, fmt.Errorf("board not found") }
+	return b, 0, nil
+}
+
+// strokesByBoard groups strokes by board, keeping each board's strokes in order
+func strokesByBoard(strokes []db.Stroke) map[int64][]db.Stroke {
+	out := map[int64][]db.Stroke{}
+	for _, s := range strokes { out[s.BoardID] = append(out[s.BoardID], s) }
+	return out
+}
@@ -0,0 +1,127 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/deliium/drawing-board/internal/db"
+)
+
+func TestBoards_CreateAndList(t *testing.T) {
+	api := newTestAPI(t)
+	_, cookies := registerUser(t, api, "boards@example.com")
+	rec := do(api.CreateBoard, "POST", "/api/boards", strings.NewReader(`{"name":"  Kana drills "}`), cookies)
+	if rec.Code != 201 {
+		t.Fatalf("Expected 201, got %d %s", rec.Code, rec.Body.String())
+	}
+	var created Board
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil || created.Name != "Kana drills" || created.ID == 0 {
+		t.Fatalf("Expected the trimmed board back, got %+v (%v)", created, err)
+	}
+
+	rec = do(api.ListBoards, "GET", "/api/boards", nil, cookies)
+	var boards []Board
+	if err := json.Unmarshal(rec.Body.Bytes(), &boards); err != nil {
+		t.Fatalf("Failed to decode boards: %v", err)
+	}
+	if len(boards) != 2 || boards[0].Name != db.DefaultBoardName || boards[1].ID != created.ID {
+		t.Fatalf("Expected the default board then the new one, got %+v", boards)
+	}
+
+	for _, body := range []string{`{"name":"   "}`, `{"name":"` + strings.Repeat("x", db.MaxBoardNameLength+1) + `"}`, `{"name":"a\u0007b"}`, `{`} {
+		if rec := do(api.CreateBoard, "POST", "/api/boards", strings.NewReader(body), cookies); rec.Code != 400 {
+			t.Fatalf("Expected 400 for %.30s, got %d", body, rec.Code)
+		}
+	}
+	if rec := do(api.ListBoards, "GET", "/api/boards", nil, nil); rec.Code != 401 {
+		t.Fatalf("Expected 401 without a session, got %d", rec.Code)
+	}
+}
+
+func TestListStrokes_ScopedToBoard(t *testing.T) {
+	api := newTestAPI(t)
+	uid, cookies := registerUser(t, api, "scoped@example.com")
+	second, err := api.Store.CreateBoard(uid, "Second")
+	if err != nil {
+		t.Fatalf("Failed to create board: %v", err)
+	}
+	saveStrokes(t, api, uid, 2)
+	if _, err := api.Store.SaveStrokeRecord(uid, db.Stroke{Color: "#000000", Width: 2, BoardID: second, Points: []db.StrokePoint{{X: 1, Y: 1}}}); err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+
+	rec := do(api.ListStrokes, "GET", fmt.Sprintf("/api/strokes?board=%d", second), nil, cookies)
+	var strokes []Stroke
+	if err := json.Unmarshal(rec.Body.Bytes(), &strokes); err != nil {
+		t.Fatalf("Failed to decode strokes: %v", err)
+	}
+	if rec.Code != 200 || len(strokes) != 1 || strokes[0].BoardID != second {
+		t.Fatalf("Expected only the second board's stroke, got %d %+v", rec.Code, strokes)
+	}
+	rec = do(api.ListStrokes, "GET", "/api/strokes", nil, cookies)
+	if err := json.Unmarshal(rec.Body.Bytes(), &strokes); err != nil || len(strokes) != 3 {
+		t.Fatalf("Expected every board without ?board, got %d (%v)", len(strokes), err)
+	}
+	if rec := do(api.ListStrokes, "GET", "/api/strokes?board=abc", nil, cookies); rec.Code != 400 {
+		t.Fatalf("Expected 400 for a malformed board, got %d", rec.Code)
+	}
+}
+
+func TestListStrokes_CannotReadAnotherUsersBoard(t *testing.T) {
+	api := newTestAPI(t)
+	alice, _ := registerUser(t, api, "alice@example.com")
+	_, mallory := registerUser(t, api, "mallory@example.com")
+	saveStrokes(t, api, alice, 1)
+	boards, _ := api.Store.ListBoardsByUser(alice)
+
+	for _, target := range []string{fmt.Sprintf("/api/strokes?board=%d", boards[0].ID), "/api/strokes?board=9999"} {
+		rec := do(api.ListStrokes, "GET", target, nil, mallory)
+		if rec.Code != 404 || strings.Contains(rec.Body.String(), "points") {
+			t.Fatalf("%s: expected 404 without strokes, got %d %s", target, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestListStrokes_BoardPagingKeepsBoardInLink(t *testing.T) {
+	api := newTestAPI(t)
+	api.DefaultStrokeLimit = 1
+	uid, cookies := registerUser(t, api, "pages@example.com")
+	saveStrokes(t, api, uid, 2)
+	boards, _ := api.Store.ListBoardsByUser(uid)
+	rec := do(api.ListStrokes, "GET", fmt.Sprintf("/api/strokes?board=%d", boards[0].ID), nil, cookies)
+	if link := rec.Header().Get("Link"); !strings.Contains(link, fmt.Sprintf("board=%d&after_id=", boards[0].ID)) {
+		t.Fatalf("Expected the next link to stay on the board, got %q", link)
+	}
+}
+
+func TestListStrokes_NamesEachAuthorOnASharedBoard(t *testing.T) {
+	api := newTestAPI(t)
+	owner, cookies := registerUser(t, api, "owner@example.com")
+	guest, _ := registerUser(t, api, "guest@example.com")
+	if err := api.Store.SetDisplayName(guest, "Guest G."); err != nil {
+		t.Fatalf("Failed to set display name: %v", err)
+	}
+	board, err := api.Store.DefaultBoardID(owner)
+	if err != nil {
+		t.Fatalf("Failed to find the default board: %v", err)
+	}
+	for _, by := range []int64{owner, guest} {
+		if _, err := api.Store.SaveStrokeRecord(owner, db.Stroke{Color: "#000000", Width: 2, BoardID: board, CreatedBy: by, Points: []db.StrokePoint{{X: 1, Y: 1}}}); err != nil {
+			t.Fatalf("Failed to save stroke: %v", err)
+		}
+	}
+
+	rec := do(api.ListStrokes, "GET", fmt.Sprintf("/api/strokes?board=%d", board), nil, cookies)
+	var strokes []Stroke
+	if err := json.Unmarshal(rec.Body.Bytes(), &strokes); err != nil || len(strokes) != 2 {
+		t.Fatalf("Expected both strokes, got %d %s", rec.Code, rec.Body.String())
+	}
+	if strokes[0].CreatedBy != owner || strokes[0].Author != "owner" || strokes[1].CreatedBy != guest || strokes[1].Author != "Guest G." {
+		t.Fatalf("Expected the owner's then the guest's stroke with their names, got %+v", strokes)
+	}
+	if strings.Contains(rec.Body.String(), "@example.com") {
+		t.Fatalf("Expected no emails in the listing, got %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,53 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deliium/drawing-board/internal/ws"
+	"github.com/gorilla/websocket"
+)
+
+func TestListStrokes_ReturnsClientIDFromWebSocket(t *testing.T) {
+	api := newTestAPI(t)
+	_, cookies := registerUser(t, api, "client@example.com")
+	srv := httptest.NewServer(ws.NewHub(api.Store, api.Auth))
+	defer srv.Close()
+
+	header := http.Header{}
+	for _, c := range cookies {
+		header.Add("Cookie", c.String())
+	}
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), header)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	stroke := map[string]any{"points": []map[string]float64{{"x": 1, "y": 2}, {"x": 3, "y": 4}}, "color": "#000000", "width": 2, "clientId": "tab-42", "startedAtUnixMs": 1690000000000}
+	if err := conn.WriteJSON(map[string]any{"type": "stroke", "stroke": stroke}); err != nil {
+		t.Fatalf("Failed to send stroke: %v", err)
+	}
+	// The ack carries the server ID, so the stroke has been saved by now
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var ack struct{ Ack ws.Ack }
+	if err := conn.ReadJSON(&ack); err != nil || ack.Ack.ClientID != "tab-42" {
+		t.Fatalf("Failed to read ack for tab-42: %+v (%v)", ack.Ack, err)
+	}
+
+	rec := do(api.ListStrokes, "GET", "/api/strokes", nil, cookies)
+	var strokes []Stroke
+	if err := json.Unmarshal(rec.Body.Bytes(), &strokes); err != nil {
+		t.Fatalf("Failed to decode strokes: %v", err)
+	}
+	if len(strokes) != 1 {
+		t.Fatalf("Expected 1 stroke, got %d", len(strokes))
+	}
+	if strokes[0].ClientID != "tab-42" || strokes[0].ID != ack.Ack.ID {
+		t.Fatalf("Expected stroke %d from client tab-42, got id=%d clientId=%q", ack.Ack.ID, strokes[0].ID, strokes[0].ClientID)
+	}
+}
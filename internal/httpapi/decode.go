@@ -0,0 +1,75 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Defaults for JSONLimits fields left at zero
+const (
+	DefaultJSONMaxDepth = 32
+	DefaultJSONMaxArray = 10000
+)
+
+// JSONLimits bounds the shape of request bodies. They are checked token by token before the body
+// is decoded into structs, so a huge or deeply nested payload is refused without being built.
+type JSONLimits struct {
+	MaxDepth int // nesting of objects and arrays; 0 uses DefaultJSONMaxDepth
+	MaxArray int // elements in any array without a per-key limit; 0 uses DefaultJSONMaxArray
+}
+
+var errInvalidJSON = errors.New("invalid json")
+
+// check scans data and fails at the first array or nesting level over the limits. keyed sets
+// tighter (or looser) limits for arrays held under a given object key, e.g. {"strokes": 5000}.
+func (l JSONLimits) check(data []byte, keyed map[string]int) error {
+	maxDepth, maxArray := l.MaxDepth, l.MaxArray
+	if maxDepth <= 0 { maxDepth = DefaultJSONMaxDepth }
+	if maxArray <= 0 { maxArray = DefaultJSONMaxArray }
+	type frame struct {
+		array   bool
+		n, max  int
+		key     string // array: the key it is held under; object: the key of the value being read
+		wantKey bool
+	}
+	var stack []frame
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF && len(stack) == 0 { return nil }
+		if err != nil { return errInvalidJSON }
+		d, isDelim := tok.(json.Delim)
+		if n := len(stack); n > 0 {
+			top := &stack[n-1]
+			if isDelim && (d == '}' || d == ']') { stack = stack[:n-1]; continue }
+			if !top.array && top.wantKey { top.key, top.wantKey = tok.(string), false; continue }
+			if top.array {
+				if top.n++; top.n > top.max { return fmt.Errorf("%s: more than %d elements", top.key, top.max) }
+			} else {
+				top.wantKey = true
+			}
+		}
+		if !isDelim { continue }
+		if len(stack) >= maxDepth { return fmt.Errorf("json nested deeper than %d levels", maxDepth) }
+		f := frame{array: d == '[', wantKey: d == '{', max: maxArray, key: "array"}
+		if n := len(stack); f.array && n > 0 && !stack[n-1].array {
+			f.key = stack[n-1].key
+			if m, ok := keyed[f.key]; ok { f.max = m }
+		}
+		stack = append(stack, f)
+	}
+}
+
+// decodeLimited reads at most maxBody bytes, checks them against a.JSONLimits and keyed, then
+// decodes into v. The error is meant for the client: a limit violation or "invalid json".
+func (a *API) decodeLimited(w http.ResponseWriter, r *http.Request, maxBody int64, keyed map[string]int, v any) error {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
+	if err != nil { return errInvalidJSON }
+	if err := a.JSONLimits.check(data, keyed); err != nil { return err }
+	if err := json.Unmarshal(data, v); err != nil { return errInvalidJSON }
+	return nil
+}
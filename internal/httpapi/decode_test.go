@@ -0,0 +1,54 @@
+package httpapi
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJSONLimits_Depth(t *testing.T) {
+	l := JSONLimits{MaxDepth: 3}
+	if err := l.check([]byte(`{"a":[{"b":1}]}`), nil); err != nil {
+		t.Fatalf("Three levels should pass: %v", err)
+	}
+	if err := l.check([]byte(`{"a":[{"b":[1]}]}`), nil); err == nil || !strings.Contains(err.Error(), "deeper than 3") {
+		t.Fatalf("Expected a depth error, got %v", err)
+	}
+	if err := (JSONLimits{}).check([]byte(strings.Repeat("[", 100)+strings.Repeat("]", 100)), nil); err == nil {
+		t.Fatal("Expected the default depth limit to apply")
+	}
+}
+
+func TestJSONLimits_ArrayLengths(t *testing.T) {
+	l := JSONLimits{MaxArray: 3}
+	if err := l.check([]byte(`{"xs":[1,2,3],"obj":{"k":"v","ys":[[1,2,3]]}}`), nil); err != nil {
+		t.Fatalf("Arrays at the limit should pass: %v", err)
+	}
+	if err := l.check([]byte(`{"obj":{"first":[1],"xs":[1,2,3,4]}}`), nil); err == nil || !strings.Contains(err.Error(), "xs: more than 3") {
+		t.Fatalf("Expected the over-long array to be named, got %v", err)
+	}
+	keyed := map[string]int{"points": 2, "big": 10}
+	if err := l.check([]byte(`{"points":[1,2,3]}`), keyed); err == nil || !strings.Contains(err.Error(), "points: more than 2") {
+		t.Fatalf("Expected the keyed limit to win, got %v", err)
+	}
+	if err := l.check([]byte(`{"big":[1,2,3,4,5]}`), keyed); err != nil {
+		t.Fatalf("A keyed limit may also be looser: %v", err)
+	}
+	if err := l.check([]byte(`{"xs":[1,2`), nil); err != errInvalidJSON {
+		t.Fatalf("Expected invalid json, got %v", err)
+	}
+}
+
+func TestImportStrokes_RejectsOverLongArrayBeforeDecoding(t *testing.T) {
+	api := newTestAPI(t)
+	_, cookies := registerUser(t, api, "huge@example.com")
+	stroke := `{"points":[{"x":1,"y":1}],"color":"#000","width":2},`
+	// The body is cut off after the limit: only a scan that stops early can report the limit
+	body := `{"strokes":[` + strings.Repeat(stroke, MaxImportStrokes+1) + `{"points":`
+	rec := do(api.ImportStrokes, "POST", "/api/strokes/import", strings.NewReader(body), cookies)
+	if rec.Code != 400 || !strings.Contains(rec.Body.String(), "strokes: more than 5000 elements") {
+		t.Fatalf("Expected 400 naming the strokes limit, got %d %s", rec.Code, rec.Body.String())
+	}
+	if strokes, _ := api.Store.ListStrokesByUser(1); len(strokes) != 0 {
+		t.Fatalf("Nothing should be imported, got %d strokes", len(strokes))
+	}
+}
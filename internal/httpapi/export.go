@@ -0,0 +1,98 @@
+package httpapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/deliium/drawing-board/internal/db"
+	"github.com/deliium/drawing-board/internal/export"
+	"github.com/deliium/drawing-board/internal/raster"
+)
+
+const (
+	defaultExportSize = 300
+	maxExportSize     = 4096
+)
+
+func (a *API) ExportPNG(w http.ResponseWriter, r *http.Request) { a.export(w, r, "png") }
+func (a *API) ExportSVG(w http.ResponseWriter, r *http.Request) { a.export(w, r, "svg") }
+
+// export renders the caller's strokes, or with ?board= one board's, on the board's saved canvas
+// (the default board's without ?board=); ?width= and ?height= override its size. With an
+// ExportStore each board version is rendered once and later requests are served the stored copy,
+// or redirected to it under ExportStoreURL.
+func (a *API) export(w http.ResponseWriter, r *http.Request, format string) {
+	uid, ok := a.Auth.UserIDFromRequest(r)
+	if !ok { writeJSON(w, 401, map[string]string{"error":"unauthorized"}); return }
+	width, err := exportDimension(r, "width")
+	if err != nil { writeJSON(w, 400, map[string]string{"error":err.Error()}); return }
+	height, err := exportDimension(r, "height")
+	if err != nil { writeJSON(w, 400, map[string]string{"error":err.Error()}); return }
+	var strokes []db.Stroke
+	var boardID int64
+	if v := r.URL.Query().Get("board"); v != "" {
+		b, status, err := a.ownedBoard(r.Context(), uid, v)
+		if err != nil { writeJSON(w, status, map[string]string{"error":err.Error()}); return }
+		boardID = b.ID
+		strokes, err = a.Store.ListStrokesByBoardContext(r.Context(), b.ID)
+		if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	} else {
+		if boardID, err = a.Store.DefaultBoardIDContext(r.Context(), uid); err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+		strokes, err = a.Store.ListStrokesByUserContext(r.Context(), uid)
+		if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	}
+	canvas, _, err := a.canvasFor(r.Context(), boardID)
+	if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	if width > 0 { canvas.Width = width }
+	if height > 0 { canvas.Height = height }
+
+	contentType := "image/svg+xml"
+	render := func() ([]byte, error) { return export.SVGCanvas(strokes, canvas), nil }
+	if format == "png" {
+		contentType = "image/png"
+		render = func() ([]byte, error) { return export.PNGCanvas(strokes, canvas) }
+	}
+	var body []byte
+	if a.ExportStore != nil {
+		key := exportKey(format, canvas, strokes)
+		var stored bool
+		body, stored, err = a.cachedExport(r.Context(), key, render)
+		if err == nil && stored && a.ExportStoreURL != "" { http.Redirect(w, r, a.exportURL(key), http.StatusFound); return }
+	} else {
+		body, err = render()
+	}
+	if err != nil { writeJSON(w, rasterStatus(err), map[string]string{"error":err.Error()}); return }
+	setDownloadHeaders(w, contentType, exportFilename(uid, format, time.Now()))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// rasterStatus maps an oversized-raster error to 400 and anything else to 500
+func rasterStatus(err error) int {
+	if errors.Is(err, raster.ErrTooLarge) { return 400 }
+	return 500
+}
+
+// exportDimension parses ?name=; 0 means it was not given and the board's canvas decides
+func exportDimension(r *http.Request, name string) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" { return 0, nil }
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 || n > maxExportSize { return 0, fmt.Errorf("bad %s", name) }
+	return n, nil
+}
+
+// setDownloadHeaders marks a binary response as an attachment of an explicit type so
+// browsers neither sniff it into something executable nor render it inline
+func setDownloadHeaders(w http.ResponseWriter, contentType, filename string) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+}
+
+func exportFilename(userID int64, ext string, at time.Time) string {
+	return fmt.Sprintf("drawing-board-user%d-%s.%s", userID, at.UTC().Format("20060102T150405Z"), ext)
+}
@@ -0,0 +1,67 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/deliium/drawing-board/internal/blobstore"
+	"github.com/deliium/drawing-board/internal/db"
+	"github.com/deliium/drawing-board/internal/metrics"
+)
+
+var (
+	exportCacheHits   = metrics.NewCounter("export_cache_hits_total", "Exports served from ExportStore without rendering.")
+	exportCacheMisses = metrics.NewCounter("export_cache_misses_total", "Exports rendered because ExportStore had no copy of that board version.")
+)
+
+// exportKey names a rendered export by everything that shows up in it: the format, the canvas and
+// each stroke's look. Any change to the board gives a new key, so a stored export never has to
+// be invalidated; versions nobody asks for again are left for the store's own expiry to collect.
+func exportKey(format string, canvas db.BoardSettings, strokes []db.Stroke) string {
+	h := sha256.New()
+	writeField(h, format)
+	writeInt(h, int64(canvas.Width)); writeInt(h, int64(canvas.Height))
+	writeField(h, canvas.Background)
+	writeInt(h, int64(len(strokes)))
+	for _, s := range strokes {
+		writeInt(h, s.ID)
+		writeField(h, s.Color)
+		writeInt(h, int64(s.Width))
+		writeField(h, s.LineStyle)
+		writeInt(h, int64(len(s.Points)))
+		for _, p := range s.Points { writeInt(h, int64(math.Float64bits(p.X))); writeInt(h, int64(math.Float64bits(p.Y))) }
+	}
+	return "exports/" + hex.EncodeToString(h.Sum(nil)) + "." + format
+}
+
+// writeField length-prefixes s so adjacent fields cannot run into each other
+func writeField(h hash.Hash, s string) { writeInt(h, int64(len(s))); h.Write([]byte(s)) }
+
+func writeInt(h hash.Hash, n int64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(n))
+	h.Write(b[:])
+}
+
+// cachedExport returns the export stored under key, calling render and storing its result when
+// there is none; stored reports whether the store now holds it. The store is best effort: when it
+// fails the export is rendered and served anyway.
+func (a *API) cachedExport(ctx context.Context, key string, render func() ([]byte, error)) (body []byte, stored bool, err error) {
+	body, err = a.ExportStore.Get(ctx, key)
+	if err == nil { exportCacheHits.Inc(); return body, true, nil }
+	if !errors.Is(err, blobstore.ErrNotFound) { log.Printf("Warning: export store get %s: %v", key, err) }
+	exportCacheMisses.Inc()
+	if body, err = render(); err != nil { return nil, false, err }
+	if err := a.ExportStore.Put(ctx, key, body); err != nil { log.Printf("Warning: export store put %s: %v", key, err); return body, false, nil }
+	return body, true, nil
+}
+
+// exportURL is where ExportStoreURL serves the blob stored under key
+func (a *API) exportURL(key string) string { return strings.TrimRight(a.ExportStoreURL, "/") + "/" + key }
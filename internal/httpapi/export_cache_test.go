@@ -0,0 +1,123 @@
+package httpapi
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/deliium/drawing-board/internal/blobstore"
+	"github.com/deliium/drawing-board/internal/db"
+)
+
+// countingStore counts the Puts reaching a blobstore.Dir
+type countingStore struct {
+	*blobstore.Dir
+	puts atomic.Int64
+}
+
+func (s *countingStore) Put(ctx context.Context, key string, data []byte) error {
+	s.puts.Add(1)
+	return s.Dir.Put(ctx, key, data)
+}
+
+func newExportStore(t *testing.T) *countingStore {
+	t.Helper()
+	d, err := blobstore.NewDir(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create export store: %v", err)
+	}
+	return &countingStore{Dir: d}
+}
+
+func TestExport_StoredOnceAndReused(t *testing.T) {
+	api := newTestAPI(t)
+	store := newExportStore(t)
+	api.ExportStore = store
+	uid, cookies := registerUser(t, api, "cache@example.com")
+	if _, err := api.Store.SaveStroke(uid, "#1d4ed8", 4, 0, []db.StrokePoint{{X: 10, Y: 10}, {X: 100, Y: 100}}); err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+
+	first := do(api.ExportSVG, "GET", "/api/export.svg", nil, cookies)
+	if first.Code != 200 || !strings.Contains(first.Body.String(), "<svg") {
+		t.Fatalf("Expected an SVG, got %d %q", first.Code, first.Body.String())
+	}
+	if n := store.puts.Load(); n != 1 {
+		t.Fatalf("Expected the export to be stored once, got %d puts", n)
+	}
+
+	// Swap the stored copy for a sentinel: serving it proves the second request did not re-render
+	strokes, _ := api.Store.ListStrokesByUser(uid)
+	canvas, _, _ := api.canvasFor(context.Background(), mustDefaultBoard(t, api, uid))
+	key := exportKey("svg", canvas, strokes)
+	if err := store.Dir.Put(context.Background(), key, []byte("sentinel")); err != nil {
+		t.Fatalf("Failed to replace stored export: %v", err)
+	}
+	hits := exportCacheHits.Value()
+	second := do(api.ExportSVG, "GET", "/api/export.svg", nil, cookies)
+	if second.Body.String() != "sentinel" {
+		t.Fatalf("Expected the stored export to be reused, got %q", second.Body.String())
+	}
+	if got := second.Header().Get("Content-Type"); got != "image/svg+xml" {
+		t.Fatalf("Expected a proxied export to keep its Content-Type, got %q", got)
+	}
+	if exportCacheHits.Value()-hits != 1 || store.puts.Load() != 1 {
+		t.Fatalf("Expected one hit and no new put, got %d hits and %d puts", exportCacheHits.Value()-hits, store.puts.Load())
+	}
+
+	// A new stroke is a new board version
+	if _, err := api.Store.SaveStroke(uid, "#000000", 2, 0, []db.StrokePoint{{X: 1, Y: 1}, {X: 5, Y: 5}}); err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+	third := do(api.ExportSVG, "GET", "/api/export.svg", nil, cookies)
+	if third.Body.String() == "sentinel" || store.puts.Load() != 2 {
+		t.Fatalf("Expected a changed board to be rendered and stored again, got %d puts", store.puts.Load())
+	}
+}
+
+func TestExport_RedirectsToStoreURL(t *testing.T) {
+	api := newTestAPI(t)
+	api.ExportStore = newExportStore(t)
+	api.ExportStoreURL = "https://cdn.example.com/boards/"
+	_, cookies := registerUser(t, api, "redirect@example.com")
+
+	rec := do(api.ExportPNG, "GET", "/api/export.png?width=64&height=64", nil, cookies)
+	if rec.Code != 302 {
+		t.Fatalf("Expected 302, got %d", rec.Code)
+	}
+	if loc := rec.Header().Get("Location"); !strings.HasPrefix(loc, "https://cdn.example.com/boards/exports/") || !strings.HasSuffix(loc, ".png") {
+		t.Fatalf("Unexpected Location %q", loc)
+	}
+}
+
+func TestExportKey_DependsOnWhatIsDrawn(t *testing.T) {
+	canvas := db.BoardSettings{Width: 100, Height: 100, Background: "#ffffff"}
+	strokes := []db.Stroke{{ID: 1, Color: "#000000", Width: 2, Points: []db.StrokePoint{{X: 1, Y: 2}}}}
+	base := exportKey("png", canvas, strokes)
+	if exportKey("png", canvas, []db.Stroke{{ID: 1, Color: "#000000", Width: 2, Points: []db.StrokePoint{{X: 1, Y: 2}}, Note: "hi"}}) != base {
+		t.Fatalf("Expected a note, which is not drawn, to keep the key")
+	}
+	for name, key := range map[string]string{
+		"format":     exportKey("svg", canvas, strokes),
+		"background": exportKey("png", db.BoardSettings{Width: 100, Height: 100, Background: "#000000"}, strokes),
+		"point":      exportKey("png", canvas, []db.Stroke{{ID: 1, Color: "#000000", Width: 2, Points: []db.StrokePoint{{X: 1, Y: 3}}}}),
+		"color":      exportKey("png", canvas, []db.Stroke{{ID: 1, Color: "#ff0000", Width: 2, Points: []db.StrokePoint{{X: 1, Y: 2}}}}),
+	} {
+		if key == base {
+			t.Fatalf("Expected a different %s to change the key", name)
+		}
+		if !blobstore.ValidKey(key) {
+			t.Fatalf("Expected %q to be a valid blob key", key)
+		}
+	}
+}
+
+func mustDefaultBoard(t *testing.T, api *API, uid int64) int64 {
+	t.Helper()
+	id, err := api.Store.DefaultBoardID(uid)
+	if err != nil {
+		t.Fatalf("Failed to get default board: %v", err)
+	}
+	return id
+}
@@ -0,0 +1,98 @@
+package httpapi
+
+import (
+	"bytes"
+	"image/png"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deliium/drawing-board/internal/db"
+	"github.com/deliium/drawing-board/internal/raster"
+	"github.com/deliium/drawing-board/internal/recognize"
+)
+
+func TestExport_Headers(t *testing.T) {
+	api := newTestAPI(t)
+	uid, cookies := registerUser(t, api, "export@example.com")
+	if _, err := api.Store.SaveStroke(uid, "#1d4ed8", 4, 0, []db.StrokePoint{{X: 10, Y: 10}, {X: 100, Y: 100}}); err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name        string
+		handler     func(http.ResponseWriter, *http.Request)
+		target      string
+		contentType string
+	}{
+		{"png", api.ExportPNG, "/api/export.png?width=120&height=80", "image/png"},
+		{"svg", api.ExportSVG, "/api/export.svg", "image/svg+xml"},
+	} {
+		rec := do(tc.handler, "GET", tc.target, nil, cookies)
+		if rec.Code != 200 {
+			t.Fatalf("%s: expected 200, got %d", tc.name, rec.Code)
+		}
+		if got := rec.Header().Get("Content-Type"); got != tc.contentType {
+			t.Fatalf("%s: expected Content-Type %s, got %s", tc.name, tc.contentType, got)
+		}
+		if got := rec.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+			t.Fatalf("%s: expected nosniff, got %q", tc.name, got)
+		}
+		want := regexp.MustCompile(`^attachment; filename="drawing-board-user\d+-\d{8}T\d{6}Z\.` + tc.name + `"$`)
+		if got := rec.Header().Get("Content-Disposition"); !want.MatchString(got) {
+			t.Fatalf("%s: unexpected Content-Disposition %q", tc.name, got)
+		}
+		if tc.name == "png" {
+			img, err := png.Decode(bytes.NewReader(rec.Body.Bytes()))
+			if err != nil {
+				t.Fatalf("Failed to decode PNG: %v", err)
+			}
+			if b := img.Bounds(); b.Dx() != 120 || b.Dy() != 80 {
+				t.Fatalf("Expected 120x80 PNG, got %v", b)
+			}
+		}
+	}
+}
+
+func TestExport_BadDimensions(t *testing.T) {
+	api := newTestAPI(t)
+	_, cookies := registerUser(t, api, "export-bad@example.com")
+	rec := do(api.ExportPNG, "GET", "/api/export.png?width=0", nil, cookies)
+	if rec.Code != 400 {
+		t.Fatalf("Expected 400, got %d", rec.Code)
+	}
+}
+
+func TestExportFilename(t *testing.T) {
+	at := time.Date(2024, 3, 5, 7, 8, 9, 0, time.UTC)
+	if got := exportFilename(42, "png", at); got != "drawing-board-user42-20240305T070809Z.png" {
+		t.Fatalf("Unexpected filename %q", got)
+	}
+}
+
+func TestRasterEndpoints_RespectMaxRasterPixels(t *testing.T) {
+	defer raster.SetMaxPixels(0)
+	raster.SetMaxPixels(200 * 200)
+	api := newTestAPI(t)
+	api.Recognizer = recognize.NewSimpleRecognizer()
+	_, cookies := registerUser(t, api, "raster@example.com")
+
+	if rec := do(api.ExportPNG, "GET", "/api/export.png?width=200&height=200", nil, cookies); rec.Code != 200 {
+		t.Fatalf("Expected 200 within the limit, got %d", rec.Code)
+	}
+	if rec := do(api.ExportPNG, "GET", "/api/export.png?width=300&height=300", nil, cookies); rec.Code != 400 {
+		t.Fatalf("Expected 400 for an oversized export, got %d", rec.Code)
+	}
+	if rec := do(api.ExportSVG, "GET", "/api/export.svg?width=300&height=300", nil, cookies); rec.Code != 200 {
+		t.Fatalf("SVG is not rasterized and should not be limited, got %d", rec.Code)
+	}
+	body := `{"width":300,"height":300}`
+	if rec := do(api.Recognize, "POST", "/api/recognize", strings.NewReader(body), cookies); rec.Code != 400 {
+		t.Fatalf("Expected 400 for an oversized recognition canvas, got %d", rec.Code)
+	}
+	if rec := do(api.RecognizeAll, "POST", "/api/recognize/all", strings.NewReader(body), cookies); rec.Code != 400 {
+		t.Fatalf("Expected 400 for an oversized bulk recognition canvas, got %d", rec.Code)
+	}
+}
@@ -1,20 +1,47 @@
 package httpapi
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
+	"time"
+	"unicode/utf8"
 
 	"github.com/deliium/drawing-board/internal/auth"
+	"github.com/deliium/drawing-board/internal/blobstore"
 	"github.com/deliium/drawing-board/internal/db"
+	"github.com/deliium/drawing-board/internal/raster"
 	"github.com/deliium/drawing-board/internal/recognize"
+	"github.com/deliium/drawing-board/internal/webhook"
+	"github.com/deliium/drawing-board/internal/ws"
 )
 
+// DefaultTimingHeader is the header Recognize uses for its compute time unless configured otherwise
+const DefaultTimingHeader = "X-Recognize-Duration-Ms"
+
 type API struct {
 	Auth  *auth.Service
 	Store *db.Store
 	Recognizer recognize.Recognizer
+	Webhook *webhook.Notifier // optional; nil disables stroke event webhooks
+	DefaultStrokeLimit int // page size for ListStrokes; 0 returns everything
+	Hub *ws.Hub // optional; nil skips broadcasting note changes to WebSocket clients
+	RecognizeConcurrency int // parallel recognitions in RecognizeAll; 0 uses DefaultRecognizeConcurrency
+	StrokeFilter recognize.StrokeFilter // strokes too small to be intentional are left out of recognition
+	Preprocess recognize.Pipeline // runs after StrokeFilter and before every recognizer
+	ImageFormats recognize.ImageFormats // accepted by RecognizeImage; nil uses recognize.DefaultImageFormats
+	TimingHeader string // Recognize reports its compute time in ms under this header; empty omits it
+	JSONLimits JSONLimits // shape limits for large request bodies (import, tensor recognition)
+	ColorLevels int // per-channel levels colors are quantized to for byColor recognition; 0 uses recognize.DefaultColorLevels
+	ExportStore blobstore.Store // optional; caches rendered PNG/SVG exports by board version, nil renders every request
+	ExportStoreURL string // public base URL of ExportStore; when set, exports redirect to the stored copy instead of being proxied
+	Glossary recognize.Glossary // readings and descriptions Recognize adds to candidates; nil uses recognize.DefaultGlossary
+	DebugLog *log.Logger // optional; receives the strokes and candidates of every Recognize call, nil logs nothing
+	DebugImages bool // honour returnImage in recognize requests; cmd/server sets it with -debug_recognize
 }
 
 type StrokePoint struct { X float64 `json:"x"`; Y float64 `json:"y"` }
@@ -26,16 +53,42 @@ type Stroke struct {
 	Width int `json:"width"`
 	ClientID string `json:"clientId"`
 	StartedAtUnixMs int64 `json:"startedAtUnixMs"`
+	Note string `json:"note,omitempty"`
+	CreatedBy int64 `json:"createdBy"` // user who drew the stroke; may delete or annotate it alongside the owner
+	Author string `json:"author,omitempty"` // display name of CreatedBy, never their email; set on listings
+	BoardID int64 `json:"boardId"`
+	LineStyle string `json:"lineStyle"` // solid, dashed or dotted; empty on import means solid
+}
+
+type NoteRequest struct {
+	Note string `json:"note"`
+}
+
+type RecolorRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
 }
 
 type RecognizeRequest struct {
 	TopN int `json:"topN"`
 	Width int `json:"width"`
 	Height int `json:"height"`
+	StrokeOrder bool `json:"strokeOrder"` // score drawn stroke order against each candidate's canonical order
+	Timing bool `json:"timing"` // also report the recognition time as durationMs in the body
+	Segment bool `json:"segment"` // also recognize each character-sized cluster and join the winners
+	ByColor bool `json:"byColor"` // also recognize each (quantized) stroke color on its own
+	Recency bool `json:"recency"` // weight strokes by start time so the latest ones count most
+	ReturnImage bool `json:"returnImage"` // also return the rasterized strokes as a PNG; needs API.DebugImages
+	Strokes []Stroke `json:"strokes,omitempty"` // recognize these instead of the saved strokes, e.g. to preview unsaved ink
 }
 
 type RecognizeResponse struct {
 	Candidates []recognize.Candidate `json:"candidates"`
+	DurationMs *float64 `json:"durationMs,omitempty"` // server-side filtering, preprocessing and recognition time
+	Segments [][]recognize.Candidate `json:"segments,omitempty"` // per-character candidates in reading order, when segment was requested
+	BestGuess *string `json:"bestGuess,omitempty"` // top candidate of each segment joined, when segment was requested
+	ColorGroups []ColorGroup `json:"colorGroups,omitempty"` // per-color candidates in order of first stroke, when byColor was requested
+	Image *string `json:"image,omitempty"` // base64 PNG of the tensor the strokes were rasterized to, when returnImage was requested
 }
 
 func writeJSON(w http.ResponseWriter, code int, v interface{}) {
@@ -44,24 +97,62 @@ func writeJSON(w http.ResponseWriter, code int, v interface{}) {
 	_ = json.NewEncoder(w).Encode(v)
 }
 
+// MaxStrokeLimit caps the page size a client may ask ListStrokes for with ?limit=
+const MaxStrokeLimit = 5000
+
+// ListStrokes pages through the caller's strokes, across every board or only ?board=ID
 func (a *API) ListStrokes(w http.ResponseWriter, r *http.Request) {
 	uid, ok := a.Auth.UserIDFromRequest(r)
 	if !ok { writeJSON(w, 401, map[string]string{"error":"unauthorized"}); return }
-	rows, err := a.Store.ListStrokesByUser(uid)
+	scope, list, nextQuery := uid, a.Store.ListStrokesByUserPagedContext, ""
+	if v := r.URL.Query().Get("board"); v != "" {
+		b, code, err := a.ownedBoard(r.Context(), uid, v)
+		if err != nil { writeJSON(w, code, map[string]string{"error":err.Error()}); return }
+		scope, list, nextQuery = b.ID, a.Store.ListStrokesByBoardPagedContext, "board="+strconv.FormatInt(b.ID, 10)+"&"
+	}
+	var afterID int64
+	if v := r.URL.Query().Get("after_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id < 0 { writeJSON(w, 400, map[string]string{"error":"bad after_id"}); return }
+		afterID = id
+	}
+	limit := a.DefaultStrokeLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > MaxStrokeLimit { writeJSON(w, 400, map[string]string{"error":fmt.Sprintf("limit must be between 1 and %d", MaxStrokeLimit)}); return }
+		limit = n
+		nextQuery += "limit=" + v + "&"
+	}
+	fetch := 0
+	if limit > 0 { fetch = limit + 1 } // one extra row tells us whether the page was truncated
+	rows, err := list(r.Context(), scope, fetch, afterID)
 	if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	if limit > 0 && len(rows) > limit {
+		rows = rows[:limit]
+		next := rows[len(rows)-1].ID
+		w.Header().Set("X-Truncated", "true")
+		w.Header().Set("X-Next-After-Id", strconv.FormatInt(next, 10))
+		w.Header().Set("Link", fmt.Sprintf("</api/strokes?%safter_id=%d>; rel=\"next\"", nextQuery, next))
+	}
+	writeJSON(w, 200, apiStrokes(rows))
+}
+
+// apiStrokes converts stored strokes to their JSON form
+func apiStrokes(rows []db.Stroke) []Stroke {
 	out := make([]Stroke, 0, len(rows))
 	for _, s := range rows {
 		pts := make([]StrokePoint, 0, len(s.Points))
 		for _, p := range s.Points { pts = append(pts, StrokePoint{X:p.X, Y:p.Y}) }
-		out = append(out, Stroke{ID: s.ID, Points: pts, Color: s.Color, Width: s.Width, ClientID: "", StartedAtUnixMs: s.StartedAtUnixMs})
+		out = append(out, Stroke{ID: s.ID, Points: pts, Color: s.Color, Width: s.Width, ClientID: s.ClientID, StartedAtUnixMs: s.StartedAtUnixMs, Note: s.Note, CreatedBy: s.CreatedBy, Author: s.AuthorName, BoardID: s.BoardID, LineStyle: s.LineStyle})
 	}
-	writeJSON(w, 200, out)
+	return out
 }
 
 func (a *API) ClearStrokes(w http.ResponseWriter, r *http.Request) {
 	uid, ok := a.Auth.UserIDFromRequest(r)
 	if !ok { writeJSON(w, 401, map[string]string{"error":"unauthorized"}); return }
-	if err := a.Store.ClearStrokesByUser(uid); err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	if err := a.Store.ClearStrokesByUserContext(r.Context(), uid); err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	a.Webhook.Notify(webhook.Event{Type: webhook.StrokesCleared, UserID: uid})
 	writeJSON(w, 200, map[string]string{"ok":"true"})
 }
 
@@ -71,39 +162,110 @@ func (a *API) DeleteStroke(w http.ResponseWriter, r *http.Request) {
 	idStr := r.URL.Query().Get("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil || id <= 0 { writeJSON(w, 400, map[string]string{"error":"bad id"}); return }
-	if err := a.Store.DeleteStroke(uid, id); err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	err = a.Store.DeleteStrokeContext(r.Context(), uid, id)
+	if errors.Is(err, db.ErrNotFound) { writeJSON(w, 404, map[string]string{"error":"stroke not found"}); return }
+	if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	a.Webhook.Notify(webhook.Event{Type: webhook.StrokeDeleted, UserID: uid, StrokeID: id})
 	writeJSON(w, 200, map[string]any{"ok": true, "id": id})
 }
 
+// SetStrokeNote attaches a text note to one of the caller's strokes and broadcasts the change
+func (a *API) SetStrokeNote(w http.ResponseWriter, r *http.Request) {
+	uid, ok := a.Auth.UserIDFromRequest(r)
+	if !ok { writeJSON(w, 401, map[string]string{"error":"unauthorized"}); return }
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil || id <= 0 { writeJSON(w, 400, map[string]string{"error":"bad id"}); return }
+	var req NoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil { writeJSON(w, 400, map[string]string{"error":"invalid json"}); return }
+	if utf8.RuneCountInString(req.Note) > db.MaxNoteLength {
+		writeJSON(w, 400, map[string]string{"error":fmt.Sprintf("note exceeds %d characters", db.MaxNoteLength)}); return
+	}
+	found, err := a.Store.SetStrokeNoteContext(r.Context(), uid, id, req.Note)
+	if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	if !found { writeJSON(w, 404, map[string]string{"error":"stroke not found"}); return }
+	if a.Hub != nil {
+		board, err := a.Store.StrokeBoardIDContext(r.Context(), id)
+		if err != nil { log.Printf("Warning: note broadcast for stroke %d: %v", id, err) } else { a.Hub.BroadcastNote(board, id, req.Note) }
+	}
+	writeJSON(w, 200, map[string]any{"ok": true, "id": id, "note": req.Note})
+}
+
+// RecolorStrokes changes every one of the caller's strokes drawn in one color to another and
+// broadcasts the change; colors are normalized like stroke colors before matching
+func (a *API) RecolorStrokes(w http.ResponseWriter, r *http.Request) {
+	uid, ok := a.Auth.UserIDFromRequest(r)
+	if !ok { writeJSON(w, 401, map[string]string{"error":"unauthorized"}); return }
+	var req RecolorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil { writeJSON(w, 400, map[string]string{"error":"invalid json"}); return }
+	if errs := db.NormalizeRecolor(&req.From, &req.To); errs.Any() { writeJSON(w, 400, errs.Response()); return }
+	byBoard, err := a.Store.RecolorStrokesContext(r.Context(), uid, req.From, req.To)
+	if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	count := 0
+	for _, ids := range byBoard { count += len(ids) }
+	if a.Hub != nil { a.Hub.BroadcastRecolor(byBoard, req.To) }
+	writeJSON(w, 200, map[string]any{"ok": true, "count": count, "from": req.From, "to": req.To})
+}
+
 func (a *API) Recognize(w http.ResponseWriter, r *http.Request) {
 	uid, ok := a.Auth.UserIDFromRequest(r)
 	if !ok { writeJSON(w, 401, map[string]string{"error":"unauthorized"}); return }
 	if a.Recognizer == nil { writeJSON(w, 503, map[string]string{"error":"recognizer unavailable"}); return }
-	var req RecognizeRequest
-	_ = json.NewDecoder(r.Body).Decode(&req)
-	strokes, err := a.Store.ListStrokesByUser(uid)
-	if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
-	
-	// Debug logging
-	fmt.Printf("Recognition request: analyzing %d strokes for user %d\n", len(strokes), uid)
-	for i, s := range strokes {
-		fmt.Printf("  Stroke %d: %d points\n", i, len(s.Points))
+	req, err := a.decodeRecognizeRequest(w, r)
+	if err != nil { writeJSON(w, 400, map[string]string{"error":err.Error()}); return }
+	if err := raster.Check(req.Width, req.Height); err != nil { writeJSON(w, 400, map[string]string{"error":err.Error()}); return }
+	if req.ReturnImage && !a.DebugImages { writeJSON(w, 403, map[string]string{"error":"returnImage needs debug mode"}); return }
+	strokes, errs := inlineStrokes(req.Strokes)
+	if errs.Any() { writeJSON(w, 400, errs.Response()); return }
+	if len(strokes) == 0 {
+		// One board at a time, like ListStrokes: ?board= when owned, otherwise the default board
+		var board int64
+		if v := r.URL.Query().Get("board"); v != "" {
+			b, code, err := a.ownedBoard(r.Context(), uid, v)
+			if err != nil { writeJSON(w, code, map[string]string{"error":err.Error()}); return }
+			board = b.ID
+		} else if board, err = a.Store.DefaultBoardIDContext(r.Context(), uid); err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+		if strokes, err = a.Store.ListStrokesByBoardContext(r.Context(), board); err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
 	}
-	
-	rs := make([]recognize.Stroke, 0, len(strokes))
-	for _, s := range strokes {
-		ps := make([]recognize.Point, 0, len(s.Points))
-		for _, p := range s.Points { ps = append(ps, recognize.Point{X:p.X, Y:p.Y}) }
-		rs = append(rs, recognize.Stroke{ Points: ps })
+	if a.DebugLog != nil {
+		a.DebugLog.Printf("Recognition request: analyzing %d strokes for user %d", len(strokes), uid)
+		for i, s := range strokes { a.DebugLog.Printf("  Stroke %d: %d points", i, len(s.Points)) }
 	}
-	cands, err := a.Recognizer.Recognize(rs, req.Width, req.Height, req.TopN)
-	if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
 	
-	// Debug logging
-	fmt.Printf("Recognition result: %d candidates\n", len(cands))
-	for i, c := range cands {
-		fmt.Printf("  %d: %s (%.2f)\n", i, c.Text, c.Score)
+	start := time.Now()
+	rs := a.recognizeInput(strokes, req.Width, req.Height, req.Recency)
+	cands, err := recognize.RecognizeContext(r.Context(), a.Recognizer, rs, req.Width, req.Height, req.TopN)
+	if err != nil { writeJSON(w, rasterStatus(err), map[string]string{"error":err.Error()}); return }
+	if req.StrokeOrder {
+		for i := range cands {
+			if score, ok := recognize.ScoreStrokeOrder(cands[i].Text, rs); ok { cands[i].StrokeOrderScore = &score }
+		}
 	}
-	
-	writeJSON(w, 200, RecognizeResponse{ Candidates: cands })
+	resp := RecognizeResponse{ Candidates: cands }
+	if req.Segment {
+		seg, err := recognize.RecognizeSegmentsContext(r.Context(), a.Recognizer, rs, req.Width, req.Height, req.TopN)
+		if err != nil { writeJSON(w, rasterStatus(err), map[string]string{"error":err.Error()}); return }
+		resp.Segments, resp.BestGuess = seg.Segments, &seg.BestGuess
+	}
+	if req.ByColor {
+		resp.ColorGroups, err = a.recognizeByColor(r.Context(), strokes, req.Width, req.Height, req.TopN, req.Recency)
+		if err != nil { writeJSON(w, rasterStatus(err), map[string]string{"error":err.Error()}); return }
+	}
+	ms := float64(time.Since(start).Microseconds()) / 1000
+	if a.TimingHeader != "" { w.Header().Set(a.TimingHeader, strconv.FormatFloat(ms, 'f', 3, 64)) }
+	if req.Timing { resp.DurationMs = &ms }
+	if req.ReturnImage {
+		// The filtered, preprocessed strokes the recognizer was given, not the saved ones
+		tensor, err := recognize.StrokesToTensor(rs, req.Width, req.Height)
+		if err != nil { writeJSON(w, rasterStatus(err), map[string]string{"error":err.Error()}); return }
+		img, err := recognize.TensorPNG(tensor, req.Width, req.Height)
+		if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+		enc := base64.StdEncoding.EncodeToString(img)
+		resp.Image = &enc
+	}
+	if a.DebugLog != nil {
+		a.DebugLog.Printf("Recognition result: %d candidates", len(cands))
+		for i, c := range cands { a.DebugLog.Printf("  %d: %s (%.2f)", i, c.Text, c.Score) }
+	}
+	a.localize(w, r, &resp)
+	writeJSON(w, 200, resp)
 }
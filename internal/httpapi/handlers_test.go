@@ -1,10 +1,27 @@
 package httpapi
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"image/png"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/deliium/drawing-board/internal/auth"
 	"github.com/deliium/drawing-board/internal/db"
+	"github.com/deliium/drawing-board/internal/recognize"
+	"github.com/deliium/drawing-board/internal/ws"
+	"github.com/gorilla/websocket"
 )
 
 func TestNewAPI(t *testing.T) {
@@ -27,4 +44,579 @@ func TestNewAPI(t *testing.T) {
 	if api.Store != store {
 		t.Fatal("Store should be set correctly")
 	}
-}
\ No newline at end of file
+}
+func TestListStrokes_TruncatesToDefaultLimit(t *testing.T) {
+	api := newTestAPI(t)
+	api.DefaultStrokeLimit = 2
+	uid, cookies := registerUser(t, api, "pager@example.com")
+
+	var ids []int64
+	for i := 0; i < 5; i++ {
+		id, err := api.Store.SaveStroke(uid, "#000000", 2, 0, []db.StrokePoint{{X: 1, Y: 1}, {X: 2, Y: 2}})
+		if err != nil {
+			t.Fatalf("Failed to save stroke: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	rec := do(api.ListStrokes, "GET", "/api/strokes", nil, cookies)
+	if rec.Code != 200 {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	var page []Stroke
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatalf("Failed to decode strokes: %v", err)
+	}
+	if len(page) != 2 {
+		t.Fatalf("Expected 2 strokes, got %d", len(page))
+	}
+	if rec.Header().Get("X-Truncated") != "true" {
+		t.Fatal("Expected X-Truncated header")
+	}
+	next := rec.Header().Get("X-Next-After-Id")
+	if next != strconv.FormatInt(ids[1], 10) {
+		t.Fatalf("Expected X-Next-After-Id %d, got %q", ids[1], next)
+	}
+
+	// The last page is not marked truncated
+	rec = do(api.ListStrokes, "GET", "/api/strokes?after_id="+strconv.FormatInt(ids[3], 10), nil, cookies)
+	page = nil
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatalf("Failed to decode strokes: %v", err)
+	}
+	if len(page) != 1 || page[0].ID != ids[4] {
+		t.Fatalf("Expected only the last stroke, got %v", page)
+	}
+	if rec.Header().Get("X-Truncated") != "" {
+		t.Fatal("Last page should not be marked truncated")
+	}
+}
+
+func TestListStrokes_LimitPages(t *testing.T) {
+	api := newTestAPI(t)
+	uid, cookies := registerUser(t, api, "limit@example.com")
+	var ids []int64
+	for i := 0; i < 5; i++ {
+		id, err := api.Store.SaveStroke(uid, "#000000", 2, 0, []db.StrokePoint{{X: 1, Y: 1}})
+		if err != nil {
+			t.Fatalf("Failed to save stroke: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	// Follow X-Next-After-Id from the first page through the middle one to the short last page
+	var got []int64
+	after, pages := "", 0
+	for {
+		target := "/api/strokes?limit=2"
+		if after != "" { target += "&after_id=" + after }
+		rec := do(api.ListStrokes, "GET", target, nil, cookies)
+		var page []Stroke
+		if err := json.NewDecoder(rec.Body).Decode(&page); err != nil || rec.Code != 200 {
+			t.Fatalf("Expected 200 with strokes, got %d (%v)", rec.Code, err)
+		}
+		pages++
+		for _, s := range page { got = append(got, s.ID) }
+		if after = rec.Header().Get("X-Next-After-Id"); after == "" { break }
+		if len(page) != 2 {
+			t.Fatalf("Expected full pages of 2 before the last, got %d", len(page))
+		}
+		if link := rec.Header().Get("Link"); !strings.Contains(link, "limit=2&after_id="+after) {
+			t.Fatalf("Expected the Link header to keep the limit, got %q", link)
+		}
+	}
+	if pages != 3 || fmt.Sprint(got) != fmt.Sprint(ids) {
+		t.Fatalf("Expected every stroke once over 3 pages, got %v over %d", got, pages)
+	}
+
+	// Paging past the end is an empty page, not an error
+	rec := do(api.ListStrokes, "GET", "/api/strokes?limit=2&after_id="+strconv.FormatInt(ids[4], 10), nil, cookies)
+	if body := strings.TrimSpace(rec.Body.String()); rec.Code != 200 || body != "[]" || rec.Header().Get("X-Truncated") != "" {
+		t.Fatalf("Expected an empty tail page, got %d %s", rec.Code, body)
+	}
+	for _, bad := range []string{"0", "-1", "x", strconv.Itoa(MaxStrokeLimit + 1)} {
+		if rec := do(api.ListStrokes, "GET", "/api/strokes?limit="+bad, nil, cookies); rec.Code != 400 {
+			t.Fatalf("Expected 400 for limit=%s, got %d", bad, rec.Code)
+		}
+	}
+}
+
+func TestRecognize_StrokeOrderScore(t *testing.T) {
+	api := newTestAPI(t)
+	api.Recognizer = recognize.NewSimpleRecognizer()
+	uid, cookies := registerUser(t, api, "order@example.com")
+
+	scoreFor := func(strokes [][]db.StrokePoint) float64 {
+		if err := api.Store.ClearStrokesByUser(uid); err != nil {
+			t.Fatalf("Failed to clear strokes: %v", err)
+		}
+		for _, pts := range strokes {
+			if _, err := api.Store.SaveStroke(uid, "#000000", 2, 0, pts); err != nil {
+				t.Fatalf("Failed to save stroke: %v", err)
+			}
+		}
+		rec := do(api.Recognize, "POST", "/api/recognize", strings.NewReader(`{"topN":5,"width":300,"height":300,"strokeOrder":true}`), cookies)
+		var resp RecognizeResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		for _, c := range resp.Candidates {
+			if c.Text == "十" {
+				if c.StrokeOrderScore == nil {
+					t.Fatal("Expected strokeOrderScore on 十")
+				}
+				return *c.StrokeOrderScore
+			}
+		}
+		t.Fatalf("Expected 十 among candidates, got %v", resp.Candidates)
+		return 0
+	}
+
+	horizontal := []db.StrokePoint{{X: 50, Y: 150}, {X: 250, Y: 150}}
+	vertical := []db.StrokePoint{{X: 150, Y: 50}, {X: 150, Y: 250}}
+	correct := scoreFor([][]db.StrokePoint{horizontal, vertical})
+	reversed := scoreFor([][]db.StrokePoint{vertical, horizontal})
+	if correct <= reversed {
+		t.Fatalf("Correct order should score higher: correct=%f reversed=%f", correct, reversed)
+	}
+}
+
+func TestRecognize_DebugLog(t *testing.T) {
+	api := newTestAPI(t)
+	api.Recognizer = recognize.NewSimpleRecognizer()
+	uid, cookies := registerUser(t, api, "debuglog@example.com")
+	if _, err := api.Store.SaveStroke(uid, "#000000", 2, 0, []db.StrokePoint{{X: 50, Y: 150}, {X: 250, Y: 150}}); err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+	recognizeWith := func(l *log.Logger) string {
+		api.DebugLog = l
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatalf("Failed to create pipe: %v", err)
+		}
+		orig := os.Stdout
+		os.Stdout = w
+		rec := do(api.Recognize, "POST", "/api/recognize", strings.NewReader(`{"topN":3}`), cookies)
+		os.Stdout = orig
+		w.Close()
+		if out, _ := io.ReadAll(r); len(out) != 0 {
+			t.Fatalf("Expected nothing on stdout, got %q", out)
+		}
+		if rec.Code != 200 {
+			t.Fatalf("Expected 200, got %d", rec.Code)
+		}
+		return rec.Body.String()
+	}
+
+	want := recognizeWith(nil)
+	if got := recognizeWith(log.New(io.Discard, "", 0)); got != want {
+		t.Fatalf("Expected a discarding logger not to change the result: %s vs %s", got, want)
+	}
+	var buf bytes.Buffer
+	recognizeWith(log.New(&buf, "", 0))
+	if !strings.Contains(buf.String(), fmt.Sprintf("analyzing 1 strokes for user %d", uid)) || !strings.Contains(buf.String(), "Recognition result:") {
+		t.Fatalf("Expected the request and result in the debug log, got %q", buf.String())
+	}
+}
+
+func TestSetStrokeNote(t *testing.T) {
+	api := newTestAPI(t)
+	uid, cookies := registerUser(t, api, "notes@example.com")
+	_, otherCookies := registerUser(t, api, "other@example.com")
+	id, err := api.Store.SaveStroke(uid, "#000000", 2, 0, []db.StrokePoint{{X: 1, Y: 1}})
+	if err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+	target := "/api/strokes/note?id=" + strconv.FormatInt(id, 10)
+
+	if rec := do(api.SetStrokeNote, "POST", target, strings.NewReader(`{"note":"origin"}`), cookies); rec.Code != 200 {
+		t.Fatalf("Expected 200, got %d %s", rec.Code, rec.Body.String())
+	}
+	rec := do(api.ListStrokes, "GET", "/api/strokes", nil, cookies)
+	var strokes []Stroke
+	if err := json.Unmarshal(rec.Body.Bytes(), &strokes); err != nil {
+		t.Fatalf("Failed to decode strokes: %v", err)
+	}
+	if len(strokes) != 1 || strokes[0].Note != "origin" {
+		t.Fatalf("Expected note to round-trip, got %+v", strokes)
+	}
+
+	if rec := do(api.SetStrokeNote, "POST", target, strings.NewReader(`{"note":"mine now"}`), otherCookies); rec.Code != 404 {
+		t.Fatalf("Expected 404 for another user's stroke, got %d", rec.Code)
+	}
+	long := `{"note":"` + strings.Repeat("a", db.MaxNoteLength+1) + `"}`
+	if rec := do(api.SetStrokeNote, "POST", target, strings.NewReader(long), cookies); rec.Code != 400 {
+		t.Fatalf("Expected 400 for an overlong note, got %d", rec.Code)
+	}
+}
+
+func TestRecognize_IgnoresTinyStrokes(t *testing.T) {
+	api := newTestAPI(t)
+	api.Recognizer = recognize.NewSimpleRecognizer()
+	api.StrokeFilter = recognize.StrokeFilter{MinPoints: 2, MinLength: 5}
+	uid, cookies := registerUser(t, api, "taps@example.com")
+
+	recognizeTexts := func() []string {
+		rec := do(api.Recognize, "POST", "/api/recognize", strings.NewReader(`{"topN":5,"width":300,"height":300}`), cookies)
+		var resp RecognizeResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		var out []string
+		for _, c := range resp.Candidates { out = append(out, c.Text) }
+		return out
+	}
+	save := func(pts ...db.StrokePoint) {
+		if _, err := api.Store.SaveStroke(uid, "#000000", 2, 0, pts); err != nil {
+			t.Fatalf("Failed to save stroke: %v", err)
+		}
+	}
+
+	save(db.StrokePoint{X: 50, Y: 100}, db.StrokePoint{X: 250, Y: 100})
+	base := recognizeTexts()
+
+	save(db.StrokePoint{X: 200, Y: 250})
+	save(db.StrokePoint{X: 20, Y: 20}, db.StrokePoint{X: 21, Y: 22})
+	if got := recognizeTexts(); strings.Join(got, ",") != strings.Join(base, ",") {
+		t.Fatalf("Stray taps changed candidates: %v -> %v", base, got)
+	}
+
+	save(db.StrokePoint{X: 50, Y: 200}, db.StrokePoint{X: 250, Y: 200})
+	if got := recognizeTexts(); strings.Join(got, ",") == strings.Join(base, ",") {
+		t.Fatalf("A real stroke should change candidates, still %v", got)
+	}
+
+	strokes, err := api.Store.ListStrokesByUser(uid)
+	if err != nil || len(strokes) != 4 {
+		t.Fatalf("Expected tiny strokes to stay stored (4 strokes), got %d (%v)", len(strokes), err)
+	}
+}
+
+func TestDeleteStroke_NotFound(t *testing.T) {
+	api := newTestAPI(t)
+	uid, cookies := registerUser(t, api, "deleter@example.com")
+	id, err := api.Store.SaveStroke(uid, "#000000", 2, 0, nil)
+	if err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+	target := "/api/strokes/delete?id=" + strconv.FormatInt(id, 10)
+	if rec := do(api.DeleteStroke, "POST", target, nil, cookies); rec.Code != 200 {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	if rec := do(api.DeleteStroke, "POST", target, nil, cookies); rec.Code != 404 {
+		t.Fatalf("Expected 404 for an already deleted stroke, got %d", rec.Code)
+	}
+}
+
+func TestDeleteStroke_NotOwned(t *testing.T) {
+	api := newTestAPI(t)
+	owner, _ := registerUser(t, api, "owner@example.com")
+	_, cookies := registerUser(t, api, "intruder@example.com")
+	id, err := api.Store.SaveStroke(owner, "#000000", 2, 0, nil)
+	if err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+	if rec := do(api.DeleteStroke, "POST", "/api/strokes/delete?id="+strconv.FormatInt(id, 10), nil, cookies); rec.Code != 404 {
+		t.Fatalf("Expected 404 for someone else's stroke, got %d", rec.Code)
+	}
+	if got, _ := api.Store.ListStrokesByUser(owner); len(got) != 1 {
+		t.Fatalf("Expected the owner's stroke to survive, got %d strokes", len(got))
+	}
+}
+
+type recordingRecognizer struct{ got []recognize.Stroke }
+
+func (r *recordingRecognizer) Recognize(strokes []recognize.Stroke, width, height int, topN int) ([]recognize.Candidate, error) {
+	r.got = strokes
+	return nil, nil
+}
+
+func (r *recordingRecognizer) Close() error { return nil }
+func (r *recordingRecognizer) Name() string { return "recording" }
+
+func TestRecognize_AppliesPreprocessing(t *testing.T) {
+	api := newTestAPI(t)
+	rec := &recordingRecognizer{}
+	api.Recognizer = rec
+	api.Preprocess, _ = recognize.ParsePipeline("resample:8")
+	uid, cookies := registerUser(t, api, "prep@example.com")
+	if _, err := api.Store.SaveStroke(uid, "#000000", 2, 0, []db.StrokePoint{{X: 0, Y: 0}, {X: 70, Y: 0}}); err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+	do(api.Recognize, "POST", "/api/recognize", strings.NewReader(`{"topN":5,"width":300,"height":300}`), cookies)
+	if len(rec.got) != 1 || len(rec.got[0].Points) != 8 || rec.got[0].Points[1].X != 10 {
+		t.Fatalf("Expected the recognizer to see 8 resampled points, got %+v", rec.got)
+	}
+}
+
+func TestRecognize_DurationHeader(t *testing.T) {
+	api := newTestAPI(t)
+	api.Recognizer = recognize.NewSimpleRecognizer()
+	api.TimingHeader = DefaultTimingHeader
+	uid, cookies := registerUser(t, api, "timing@example.com")
+	if _, err := api.Store.SaveStroke(uid, "#000000", 2, 0, []db.StrokePoint{{X: 50, Y: 150}, {X: 250, Y: 150}}); err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+
+	rec := do(api.Recognize, "POST", "/api/recognize", strings.NewReader(`{"topN":3,"width":300,"height":300}`), cookies)
+	ms, err := strconv.ParseFloat(rec.Header().Get("X-Recognize-Duration-Ms"), 64)
+	if err != nil || ms < 0 {
+		t.Fatalf("Expected a numeric X-Recognize-Duration-Ms header, got %q", rec.Header().Get("X-Recognize-Duration-Ms"))
+	}
+	if strings.Contains(rec.Body.String(), "durationMs") {
+		t.Fatalf("durationMs should only be in the body when requested, got %s", rec.Body.String())
+	}
+
+	rec = do(api.Recognize, "POST", "/api/recognize", strings.NewReader(`{"topN":3,"width":300,"height":300,"timing":true}`), cookies)
+	var resp RecognizeResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.DurationMs == nil || *resp.DurationMs < 0 {
+		t.Fatalf("Expected durationMs in the body, got %v", resp.DurationMs)
+	}
+
+	api.TimingHeader = ""
+	if rec := do(api.Recognize, "POST", "/api/recognize", strings.NewReader(`{"topN":3}`), cookies); rec.Header().Get("X-Recognize-Duration-Ms") != "" {
+		t.Fatal("Expected no timing header when disabled")
+	}
+}
+
+func TestRecognize_SegmentsBestGuess(t *testing.T) {
+	api := newTestAPI(t)
+	api.Recognizer = recognize.NewSimpleRecognizer()
+	uid, cookies := registerUser(t, api, "segments@example.com")
+	// The right-hand 一 is drawn before the left-hand 十: the guess must follow position, not drawing order
+	if _, err := api.Store.SaveStroke(uid, "#000000", 2, 0, []db.StrokePoint{{X: 200, Y: 100}, {X: 260, Y: 100}}); err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+	saveCross(t, api, uid, 80, 100)
+
+	rec := do(api.Recognize, "POST", "/api/recognize", strings.NewReader(`{"topN":3,"width":300,"height":300,"segment":true}`), cookies)
+	var resp RecognizeResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.BestGuess == nil || *resp.BestGuess != "十一" {
+		t.Fatalf("Expected best guess 十一, got %v", resp.BestGuess)
+	}
+	if len(resp.Segments) != 2 || len(resp.Segments[0]) == 0 || resp.Segments[0][0].Text != "十" || resp.Segments[1][0].Text != "一" {
+		t.Fatalf("Expected per-segment candidates in reading order, got %+v", resp.Segments)
+	}
+	if len(resp.Candidates) == 0 {
+		t.Fatal("Expected whole-board candidates alongside the segments")
+	}
+
+	rec = do(api.Recognize, "POST", "/api/recognize", strings.NewReader(`{"topN":3,"width":300,"height":300}`), cookies)
+	if strings.Contains(rec.Body.String(), "bestGuess") {
+		t.Fatalf("Segments should only be computed on request, got %s", rec.Body.String())
+	}
+}
+
+// segmentBlocker answers the whole board at once but waits on a single character until its
+// context ends
+type segmentBlocker struct{ whole int }
+
+func (b segmentBlocker) Recognize(strokes []recognize.Stroke, width, height, topN int) ([]recognize.Candidate, error) {
+	return b.RecognizeContext(context.Background(), strokes, width, height, topN)
+}
+
+func (b segmentBlocker) RecognizeContext(ctx context.Context, strokes []recognize.Stroke, width, height, topN int) ([]recognize.Candidate, error) {
+	if len(strokes) == b.whole { return []recognize.Candidate{{Text: "王", Score: 1}}, nil }
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (segmentBlocker) Close() error { return nil }
+func (segmentBlocker) Name() string { return "segmentBlocker" }
+
+func TestRecognize_SegmentsStopAtRequestDeadline(t *testing.T) {
+	api := newTestAPI(t)
+	api.Recognizer = segmentBlocker{whole: 3}
+	uid, cookies := registerUser(t, api, "segment-deadline@example.com")
+	if _, err := api.Store.SaveStroke(uid, "#000000", 2, 0, []db.StrokePoint{{X: 200, Y: 100}, {X: 260, Y: 100}}); err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+	saveCross(t, api, uid, 80, 100)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest("POST", "/api/recognize", strings.NewReader(`{"topN":3,"width":300,"height":300,"segment":true}`)).WithContext(ctx)
+	for _, c := range cookies { req.AddCookie(c) }
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() { api.Recognize(rec, req); close(done) }()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected segment recognition to stop at the request deadline")
+	}
+	if rec.Code == 200 || !strings.Contains(rec.Body.String(), context.DeadlineExceeded.Error()) {
+		t.Fatalf("Expected the deadline error, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRecognize_OneBoardAtATime(t *testing.T) {
+	api := newTestAPI(t)
+	api.Recognizer = &countingRecognizer{}
+	uid, cookies := registerUser(t, api, "recognize-boards@example.com")
+	_, otherCookies := registerUser(t, api, "recognize-other@example.com")
+	second, err := api.Store.CreateBoard(uid, "Second")
+	if err != nil {
+		t.Fatalf("Failed to create board: %v", err)
+	}
+	if _, err := api.Store.SaveStroke(uid, "#000000", 2, 0, []db.StrokePoint{{X: 1, Y: 1}, {X: 5, Y: 1}}); err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := api.Store.SaveStrokeRecord(uid, db.Stroke{Color: "#000000", Width: 2, BoardID: second, Points: []db.StrokePoint{{X: 1, Y: float64(i)}, {X: 5, Y: float64(i)}}}); err != nil {
+			t.Fatalf("Failed to save stroke: %v", err)
+		}
+	}
+	top := func(rec *httptest.ResponseRecorder) string {
+		t.Helper()
+		var resp RecognizeResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || len(resp.Candidates) == 0 {
+			t.Fatalf("Expected candidates, got %d (%v)", rec.Code, err)
+		}
+		return resp.Candidates[0].Text
+	}
+	// countingRecognizer names a board after its stroke count: 一 for the default board's one stroke
+	if got := top(do(api.Recognize, "POST", "/api/recognize", strings.NewReader(`{}`), cookies)); got != "一" {
+		t.Fatalf("Expected only the default board's stroke, got %q", got)
+	}
+	target := fmt.Sprintf("/api/recognize?board=%d", second)
+	if got := top(do(api.Recognize, "POST", target, strings.NewReader(`{}`), cookies)); got != "一一" {
+		t.Fatalf("Expected only the second board's strokes, got %q", got)
+	}
+	if rec := do(api.Recognize, "POST", target, strings.NewReader(`{}`), otherCookies); rec.Code != 404 {
+		t.Fatalf("Expected 404 for someone else's board, got %d", rec.Code)
+	}
+	if rec := do(api.Recognize, "POST", "/api/recognize?board=abc", strings.NewReader(`{}`), cookies); rec.Code != 400 {
+		t.Fatalf("Expected 400 for a bad board, got %d", rec.Code)
+	}
+}
+
+func TestRecognize_RecencyWeightsByStartTime(t *testing.T) {
+	api := newTestAPI(t)
+	api.Recognizer = recognize.NewSimpleRecognizer()
+	uid, cookies := registerUser(t, api, "recency@example.com")
+	// The vertical is saved first but started last, so it is the stroke that should decide
+	for _, s := range []struct {
+		started int64
+		pts     []db.StrokePoint
+	}{
+		{3000, []db.StrokePoint{{X: 150, Y: 80}, {X: 150, Y: 240}}},
+		{1000, []db.StrokePoint{{X: 100, Y: 120}, {X: 200, Y: 120}}},
+		{2000, []db.StrokePoint{{X: 90, Y: 180}, {X: 210, Y: 180}}},
+	} {
+		if _, err := api.Store.SaveStroke(uid, "#000000", 2, s.started, s.pts); err != nil {
+			t.Fatalf("Failed to save stroke: %v", err)
+		}
+	}
+	top := func(body string) string {
+		t.Helper()
+		var resp RecognizeResponse
+		rec := do(api.Recognize, "POST", "/api/recognize", strings.NewReader(body), cookies)
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || len(resp.Candidates) == 0 {
+			t.Fatalf("Expected candidates, got %d %s", rec.Code, rec.Body.String())
+		}
+		return resp.Candidates[0].Text
+	}
+	if got := top(`{"topN":3,"width":300,"height":300}`); got == "十" {
+		t.Fatalf("Expected equal weights not to read the drawing as 十, got %s", got)
+	}
+	if got := top(`{"topN":3,"width":300,"height":300,"recency":true}`); got != "十" {
+		t.Fatalf("Expected recency weighting to read the drawing as 十, got %s", got)
+	}
+}
+
+func TestRecolorStrokes_ChangesMatchingStrokesAndBroadcasts(t *testing.T) {
+	api := newTestAPI(t)
+	uid, cookies := registerUser(t, api, "painter@example.com")
+	api.Hub = ws.NewHub(api.Store, api.Auth)
+	srv := httptest.NewServer(api.Hub)
+	defer srv.Close()
+	red, _ := api.Store.SaveStroke(uid, "#ff0000", 2, 1, []db.StrokePoint{{X: 1, Y: 2}})
+	blue, _ := api.Store.SaveStroke(uid, "#0000ff", 2, 1, []db.StrokePoint{{X: 3, Y: 4}})
+
+	header := http.Header{}
+	for _, c := range cookies { header.Add("Cookie", c.String()) }
+	viewer, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), header)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer viewer.Close()
+
+	rec := do(api.RecolorStrokes, "POST", "/api/strokes/recolor", strings.NewReader(`{"from":"#F00","to":"#00ff00"}`), cookies)
+	var resp struct {
+		Count int
+		To    string
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || rec.Code != 200 || resp.Count != 1 || resp.To != "#00ff00" {
+		t.Fatalf("Expected 1 stroke recolored, got %d %s", rec.Code, rec.Body.String())
+	}
+	rows, _ := api.Store.ListStrokesByUser(uid)
+	for _, s := range rows {
+		if want := map[int64]string{red: "#00ff00", blue: "#0000ff"}[s.ID]; s.Color != want {
+			t.Fatalf("Expected stroke %d to be %s, got %s", s.ID, want, s.Color)
+		}
+	}
+
+	viewer.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		var m struct {
+			Type    string
+			Recolor *ws.Recolor
+		}
+		if err := viewer.ReadJSON(&m); err != nil {
+			t.Fatalf("Expected a recolor broadcast: %v", err)
+		}
+		if m.Type != "recolor" { continue }
+		if len(m.Recolor.IDs) != 1 || m.Recolor.IDs[0] != red || m.Recolor.Color != "#00ff00" {
+			t.Fatalf("Expected stroke %d recolored to #00ff00, got %+v", red, m.Recolor)
+		}
+		break
+	}
+
+	if rec := do(api.RecolorStrokes, "POST", "/api/strokes/recolor", strings.NewReader(`{"from":"red","to":"#00ff00"}`), cookies); rec.Code != 400 {
+		t.Fatalf("Expected 400 for an invalid color, got %d", rec.Code)
+	}
+}
+
+func TestRecognize_ReturnImageDecodesAtBoardSize(t *testing.T) {
+	api := newTestAPI(t)
+	api.Recognizer = recognize.NewSimpleRecognizer()
+	uid, cookies := registerUser(t, api, "seer@example.com")
+	if _, err := api.Store.SaveStroke(uid, "#000000", 2, 0, []db.StrokePoint{{X: 20, Y: 50}, {X: 180, Y: 50}}); err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+	body := `{"width":200,"height":120,"returnImage":true}`
+	if rec := do(api.Recognize, "POST", "/api/recognize", strings.NewReader(body), cookies); rec.Code != 403 {
+		t.Fatalf("Expected 403 outside debug mode, got %d", rec.Code)
+	}
+
+	api.DebugImages = true
+	rec := do(api.Recognize, "POST", "/api/recognize", strings.NewReader(body), cookies)
+	var resp RecognizeResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || rec.Code != 200 || resp.Image == nil {
+		t.Fatalf("Expected an image, got %d %s", rec.Code, rec.Body.String())
+	}
+	raw, err := base64.StdEncoding.DecodeString(*resp.Image)
+	if err != nil {
+		t.Fatalf("Expected base64, got %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("Expected a PNG, got %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 200 || b.Dy() != 120 {
+		t.Fatalf("Expected a 200x120 image, got %dx%d", b.Dx(), b.Dy())
+	}
+	if r, _, _, _ := img.At(100, 50).RGBA(); r == 0 {
+		t.Fatal("Expected ink along the stroke")
+	}
+	if r, _, _, _ := img.At(100, 100).RGBA(); r != 0 {
+		t.Fatal("Expected background away from the stroke")
+	}
+}
@@ -0,0 +1,52 @@
+package httpapi
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/deliium/drawing-board/internal/auth"
+	"github.com/deliium/drawing-board/internal/db"
+	"github.com/gorilla/sessions"
+)
+
+// newTestAPI returns an API backed by a fresh sqlite file in a temp dir
+func newTestAPI(t *testing.T) *API {
+	t.Helper()
+	store, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { store.SQL.Close() })
+	authSvc := auth.NewService(store, sessions.NewCookieStore([]byte("test-secret")))
+	return &API{Auth: authSvc, Store: store}
+}
+
+// registerUser signs up email via the auth handler and returns its id and session cookies
+func registerUser(t *testing.T, api *API, email string) (int64, []*http.Cookie) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	api.Auth.Register(rec, httptest.NewRequest("POST", "/api/register", strings.NewReader(`{"email":"`+email+`","password":"password123"}`)))
+	if rec.Code != 200 {
+		t.Fatalf("Failed to register %s: %d %s", email, rec.Code, rec.Body.String())
+	}
+	u, err := api.Store.GetUserByEmail(email)
+	if err != nil || u == nil {
+		t.Fatalf("Failed to load registered user: %v", err)
+	}
+	return u.ID, rec.Result().Cookies()
+}
+
+// do runs handler with the given cookies attached and returns the recorder
+func do(handler http.HandlerFunc, method, target string, body io.Reader, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, body)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
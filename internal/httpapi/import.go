@@ -0,0 +1,63 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/deliium/drawing-board/internal/db"
+	"github.com/deliium/drawing-board/internal/validate"
+)
+
+// maxImportBody bounds an import request; a full MaxStrokePoints stroke is roughly 400KB of JSON
+const maxImportBody = 32 << 20
+
+// MaxImportStrokes caps how many strokes one import request may carry
+const MaxImportStrokes = 5000
+
+// importArrayLimits refuses over-long strokes or points arrays while the body is scanned
+var importArrayLimits = map[string]int{"strokes": MaxImportStrokes, "points": db.MaxStrokePoints}
+
+type ImportRequest struct {
+	Strokes []Stroke `json:"strokes"`
+}
+
+// UnmarshalJSON also accepts a bare array of strokes, as written by tools that export one
+func (req *ImportRequest) UnmarshalJSON(b []byte) error {
+	if t := bytes.TrimLeft(b, " \t\r\n"); len(t) > 0 && t[0] == '[' { return json.Unmarshal(b, &req.Strokes) }
+	type plain ImportRequest
+	return json.Unmarshal(b, (*plain)(req))
+}
+
+type ImportResponse struct {
+	Imported int            `json:"imported"`
+	IDs      []db.IDMapping `json:"ids"`
+}
+
+// ImportStrokes adds strokes to the caller's board in one transaction. ?ids=preserve keeps
+// incoming IDs where they are free; the default assigns fresh ones. Every stroke goes through
+// db.NormalizeStroke, the same rules the WebSocket save path uses, and any failure rejects the batch.
+func (a *API) ImportStrokes(w http.ResponseWriter, r *http.Request) {
+	uid, ok := a.Auth.UserIDFromRequest(r)
+	if !ok { writeJSON(w, 401, map[string]string{"error":"unauthorized"}); return }
+	policy, err := db.ParseIDPolicy(r.URL.Query().Get("ids"))
+	if err != nil { writeJSON(w, 400, map[string]string{"error":err.Error()}); return }
+	var req ImportRequest
+	if err := a.decodeLimited(w, r, maxImportBody, importArrayLimits, &req); err != nil { writeJSON(w, 400, map[string]string{"error":err.Error()}); return }
+	// A bare array is not held under "strokes", so the scan above only applied the general array limit
+	if len(req.Strokes) > MaxImportStrokes { writeJSON(w, 400, map[string]string{"error":fmt.Sprintf("strokes: more than %d elements", MaxImportStrokes)}); return }
+	strokes := make([]db.Stroke, 0, len(req.Strokes))
+	var errs validate.Errors
+	for i, in := range req.Strokes {
+		pts := make([]db.StrokePoint, 0, len(in.Points))
+		for _, p := range in.Points { pts = append(pts, db.StrokePoint{X: p.X, Y: p.Y}) }
+		st := db.Stroke{ID: in.ID, Color: in.Color, Width: in.Width, StartedAtUnixMs: in.StartedAtUnixMs, Points: pts, Note: in.Note, ClientID: in.ClientID, LineStyle: in.LineStyle}
+		errs = append(errs, db.NormalizeStroke(&st, fmt.Sprintf("strokes[%d].", i))...)
+		strokes = append(strokes, st)
+	}
+	if errs.Any() { writeJSON(w, 400, errs.Response()); return }
+	ids, err := a.Store.SaveStrokesContext(r.Context(), uid, strokes, policy)
+	if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	writeJSON(w, 200, ImportResponse{Imported: len(ids), IDs: ids})
+}
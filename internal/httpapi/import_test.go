@@ -0,0 +1,174 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deliium/drawing-board/internal/validate"
+	"github.com/deliium/drawing-board/internal/ws"
+	"github.com/gorilla/websocket"
+)
+
+func TestImportStrokes_NormalizesAndSaves(t *testing.T) {
+	api := newTestAPI(t)
+	uid, cookies := registerUser(t, api, "importer@example.com")
+	body := `{"strokes":[{"id":77,"points":[{"x":1,"y":2},{"x":3,"y":4}],"color":" #ABC ","width":3,"note":"hi"}]}`
+	rec := do(api.ImportStrokes, "POST", "/api/strokes/import?ids=preserve", strings.NewReader(body), cookies)
+	if rec.Code != 200 {
+		t.Fatalf("Expected 200, got %d %s", rec.Code, rec.Body.String())
+	}
+	var resp ImportResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Imported != 1 || resp.IDs[0].New != 77 {
+		t.Fatalf("Expected stroke 77 to be imported, got %+v", resp)
+	}
+	saved, _ := api.Store.ListStrokesByUser(uid)
+	if len(saved) != 1 || saved[0].Color != "#aabbcc" || saved[0].Note != "hi" {
+		t.Fatalf("Expected a normalized color and kept note, got %+v", saved)
+	}
+}
+
+func TestImportStrokes_LineStyleRoundTrips(t *testing.T) {
+	api := newTestAPI(t)
+	_, cookies := registerUser(t, api, "styles@example.com")
+	body := `{"strokes":[{"points":[{"x":1,"y":2},{"x":30,"y":2}],"color":"#000000","width":2,"lineStyle":"dashed"},{"points":[{"x":1,"y":9}],"color":"#000000","width":2}]}`
+	if rec := do(api.ImportStrokes, "POST", "/api/strokes/import", strings.NewReader(body), cookies); rec.Code != 200 {
+		t.Fatalf("Expected 200, got %d %s", rec.Code, rec.Body.String())
+	}
+	rec := do(api.ListStrokes, "GET", "/api/strokes", nil, cookies)
+	var listed []Stroke
+	if err := json.Unmarshal(rec.Body.Bytes(), &listed); err != nil {
+		t.Fatalf("Failed to decode strokes: %v", err)
+	}
+	if len(listed) != 2 || listed[0].LineStyle != "dashed" || listed[1].LineStyle != "solid" {
+		t.Fatalf("Expected dashed then solid, got %+v", listed)
+	}
+	if svg := do(api.ExportSVG, "GET", "/api/export.svg", nil, cookies).Body.String(); strings.Count(svg, `stroke-dasharray="6 6"`) != 1 {
+		t.Fatalf("Expected one dashed polyline in the export, got %s", svg)
+	}
+	if rec := do(api.ImportStrokes, "POST", "/api/strokes/import", strings.NewReader(`{"strokes":[{"points":[{"x":1,"y":2}],"color":"#000000","width":2,"lineStyle":"zigzag"}]}`), cookies); rec.Code != 400 || !strings.Contains(rec.Body.String(), `"strokes[0].lineStyle"`) {
+		t.Fatalf("Expected 400 for an unknown line style, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestImportStrokes_AcceptsBareArray(t *testing.T) {
+	api := newTestAPI(t)
+	uid, cookies := registerUser(t, api, "array@example.com")
+	body := `[{"points":[{"x":1,"y":2}],"color":"#000000","width":2},{"points":[{"x":3,"y":4}],"color":"#ff0000","width":3}]`
+	rec := do(api.ImportStrokes, "POST", "/api/strokes/import", strings.NewReader(body), cookies)
+	if rec.Code != 200 {
+		t.Fatalf("Expected 200, got %d %s", rec.Code, rec.Body.String())
+	}
+	var resp ImportResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Imported != 2 || resp.IDs[0].New >= resp.IDs[1].New {
+		t.Fatalf("Expected two strokes with increasing IDs, got %+v (%v)", resp, err)
+	}
+	if saved, _ := api.Store.ListStrokesByUser(uid); len(saved) != 2 || saved[1].Color != "#ff0000" {
+		t.Fatalf("Expected both strokes saved in order, got %+v", saved)
+	}
+
+	over := "[" + strings.TrimSuffix(strings.Repeat(`{"points":[{"x":1,"y":2}],"color":"#000000","width":2},`, MaxImportStrokes+1), ",") + "]"
+	if rec := do(api.ImportStrokes, "POST", "/api/strokes/import", strings.NewReader(over), cookies); rec.Code != 400 {
+		t.Fatalf("Expected 400 for more than %d strokes in a bare array, got %d", MaxImportStrokes, rec.Code)
+	}
+}
+
+func TestImportStrokes_RejectsWholeBatch(t *testing.T) {
+	api := newTestAPI(t)
+	uid, cookies := registerUser(t, api, "importer@example.com")
+	body := `{"strokes":[{"points":[{"x":1,"y":2}],"color":"#000000","width":2},{"points":[],"color":"red","width":0}]}`
+	rec := do(api.ImportStrokes, "POST", "/api/strokes/import", strings.NewReader(body), cookies)
+	if rec.Code != 400 {
+		t.Fatalf("Expected 400, got %d", rec.Code)
+	}
+	for _, field := range []string{"strokes[1].color", "strokes[1].width", "strokes[1].points"} {
+		if !strings.Contains(rec.Body.String(), `"`+field+`"`) {
+			t.Fatalf("Expected an error for %s, got %s", field, rec.Body.String())
+		}
+	}
+	if saved, _ := api.Store.ListStrokesByUser(uid); len(saved) != 0 {
+		t.Fatalf("Expected nothing saved from a rejected batch, got %d strokes", len(saved))
+	}
+	if rec := do(api.ImportStrokes, "POST", "/api/strokes/import?ids=keep", strings.NewReader(`{"strokes":[]}`), cookies); rec.Code != 400 {
+		t.Fatalf("Expected 400 for an unknown id policy, got %d", rec.Code)
+	}
+}
+
+// The WebSocket save path and HTTP import share db.NormalizeStroke, so they agree on what is invalid
+func TestStrokeValidation_SameOverWebSocketAndHTTP(t *testing.T) {
+	api := newTestAPI(t)
+	uid, cookies := registerUser(t, api, "both@example.com")
+	srv := httptest.NewServer(ws.NewHub(api.Store, api.Auth))
+	defer srv.Close()
+	header := http.Header{}
+	for _, c := range cookies {
+		header.Add("Cookie", c.String())
+	}
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), header)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	viewer, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), header)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer viewer.Close()
+
+	bad := map[string]any{"points": []map[string]float64{{"x": 1, "y": 2}}, "color": "blue", "width": 500}
+	if err := conn.WriteJSON(map[string]any{"type": "stroke", "stroke": bad}); err != nil {
+		t.Fatalf("Failed to send stroke: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var got struct {
+		Type   string
+		Errors validate.Errors
+	}
+	if err := conn.ReadJSON(&got); err != nil {
+		t.Fatalf("Failed to read reply: %v", err)
+	}
+	if got.Type != "error" {
+		t.Fatalf("Expected an error reply, got %q", got.Type)
+	}
+
+	b, _ := json.Marshal(map[string]any{"strokes": []any{bad}})
+	rec := do(api.ImportStrokes, "POST", "/api/strokes/import", strings.NewReader(string(b)), cookies)
+	var viaHTTP struct{ Errors validate.Errors }
+	if err := json.Unmarshal(rec.Body.Bytes(), &viaHTTP); err != nil || rec.Code != 400 {
+		t.Fatalf("Expected 400 with errors, got %d %s", rec.Code, rec.Body.String())
+	}
+	if len(got.Errors) != len(viaHTTP.Errors) {
+		t.Fatalf("Expected the same errors, got WS %v and HTTP %v", got.Errors, viaHTTP.Errors)
+	}
+	for i := range got.Errors {
+		if strings.TrimPrefix(got.Errors[i].Field, "stroke.") != strings.TrimPrefix(viaHTTP.Errors[i].Field, "strokes[0].") || got.Errors[i].Message != viaHTTP.Errors[i].Message {
+			t.Fatalf("Error %d differs: WS %+v, HTTP %+v", i, got.Errors[i], viaHTTP.Errors[i])
+		}
+	}
+
+	// Normalization matches too: a valid #RGB color is stored expanded whichever way it arrives
+	good := map[string]any{"points": []map[string]float64{{"x": 1, "y": 2}}, "color": "#F0A", "width": 2}
+	if err := conn.WriteJSON(map[string]any{"type": "stroke", "stroke": good}); err != nil {
+		t.Fatalf("Failed to send stroke: %v", err)
+	}
+	var relayed struct{ Stroke ws.Stroke }
+	viewer.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := viewer.ReadJSON(&relayed); err != nil || relayed.Stroke.Color != "#ff00aa" {
+		t.Fatalf("Expected the relayed stroke to carry #ff00aa, got %+v (%v)", relayed.Stroke, err)
+	}
+	// The relay happens before the sender's ack, so reading the ack means the save is done
+	var ack struct{ Ack ws.Ack }
+	if err := conn.ReadJSON(&ack); err != nil || ack.Ack.ID == 0 {
+		t.Fatalf("Expected an ack for the saved stroke, got %+v (%v)", ack.Ack, err)
+	}
+	saved, _ := api.Store.ListStrokesByUser(uid)
+	if len(saved) != 1 || saved[0].Color != "#ff00aa" {
+		t.Fatalf("Expected only the valid stroke saved as #ff00aa, got %+v", saved)
+	}
+}
@@ -0,0 +1,20 @@
+package httpapi
+
+import (
+	"net/http"
+
+	"github.com/deliium/drawing-board/internal/recognize"
+)
+
+// localize fills in candidate readings and descriptions in the language the request's
+// Accept-Language prefers, and says which one it picked in Content-Language
+func (a *API) localize(w http.ResponseWriter, r *http.Request, resp *RecognizeResponse) {
+	g := a.Glossary
+	if g == nil { g = recognize.DefaultGlossary }
+	lang := g.Match(r.Header.Get("Accept-Language"))
+	g.Localize(resp.Candidates, lang)
+	for _, seg := range resp.Segments { g.Localize(seg, lang) }
+	for _, cg := range resp.ColorGroups { g.Localize(cg.Candidates, lang) }
+	w.Header().Set("Content-Language", lang)
+	w.Header().Add("Vary", "Accept-Language")
+}
@@ -0,0 +1,46 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/deliium/drawing-board/internal/db"
+	"github.com/deliium/drawing-board/internal/recognize"
+)
+
+func TestRecognize_LocalizesByAcceptLanguage(t *testing.T) {
+	api := newTestAPI(t)
+	api.Recognizer = recognize.NewSimpleRecognizer()
+	uid, cookies := registerUser(t, api, "locale@example.com")
+	for _, pts := range [][]db.StrokePoint{{{X: 50, Y: 150}, {X: 250, Y: 150}}, {{X: 150, Y: 50}, {X: 150, Y: 250}}} {
+		if _, err := api.Store.SaveStroke(uid, "#000000", 2, 0, pts); err != nil {
+			t.Fatalf("Failed to save stroke: %v", err)
+		}
+	}
+	recognizeIn := func(lang string) (string, recognize.Candidate) {
+		req := httptest.NewRequest("POST", "/api/recognize", strings.NewReader(`{"topN":3,"width":300,"height":300}`))
+		if lang != "" { req.Header.Set("Accept-Language", lang) }
+		for _, c := range cookies { req.AddCookie(c) }
+		rec := httptest.NewRecorder()
+		api.Recognize(rec, req)
+		var resp RecognizeResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || len(resp.Candidates) == 0 {
+			t.Fatalf("Failed to decode candidates: %v", err)
+		}
+		return rec.Header().Get("Content-Language"), resp.Candidates[0]
+	}
+
+	enLang, en := recognizeIn("")
+	jaLang, ja := recognizeIn("ja-JP,ja;q=0.9,en;q=0.8")
+	if enLang != "en" || jaLang != "ja" {
+		t.Fatalf("Expected Content-Language en and ja, got %q and %q", enLang, jaLang)
+	}
+	if en.Text != "十" || ja.Text != en.Text {
+		t.Fatalf("Expected 十 in both languages, got %q and %q", en.Text, ja.Text)
+	}
+	if en.Description != "ten" || ja.Description == "" || ja.Description == en.Description || ja.Reading == en.Reading {
+		t.Fatalf("Expected the gloss to follow the language, got en=%+v ja=%+v", en, ja)
+	}
+}
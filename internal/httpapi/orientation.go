@@ -0,0 +1,24 @@
+package httpapi
+
+import (
+	"net/http"
+
+	"github.com/deliium/drawing-board/internal/recognize"
+)
+
+type OrientationResponse struct {
+	Direction  recognize.Orientation `json:"direction"`
+	Confidence float64               `json:"confidence"`
+	Clusters   int                   `json:"clusters"` // character-sized stroke groups the guess is based on
+}
+
+// Orientation guesses whether the caller's board is written horizontally or vertically from how
+// its stroke clusters are arranged. Taps dropped by StrokeFilter are ignored here too.
+func (a *API) Orientation(w http.ResponseWriter, r *http.Request) {
+	uid, ok := a.Auth.UserIDFromRequest(r)
+	if !ok { writeJSON(w, 401, map[string]string{"error":"unauthorized"}); return }
+	strokes, err := a.Store.ListStrokesByUserContext(r.Context(), uid)
+	if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	dir, conf, clusters := recognize.DetectOrientation(a.StrokeFilter.Apply(toRecognizeStrokes(strokes)))
+	writeJSON(w, 200, OrientationResponse{Direction: dir, Confidence: conf, Clusters: clusters})
+}
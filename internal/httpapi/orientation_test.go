@@ -0,0 +1,50 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/deliium/drawing-board/internal/db"
+	"github.com/deliium/drawing-board/internal/recognize"
+)
+
+func saveCross(t *testing.T, api *API, uid int64, cx, cy float64) {
+	t.Helper()
+	for _, pts := range [][]db.StrokePoint{{{X: cx - 30, Y: cy}, {X: cx + 30, Y: cy}}, {{X: cx, Y: cy - 30}, {X: cx, Y: cy + 30}}} {
+		if _, err := api.Store.SaveStroke(uid, "#000000", 2, 0, pts); err != nil {
+			t.Fatalf("Failed to save stroke: %v", err)
+		}
+	}
+}
+
+func TestOrientation(t *testing.T) {
+	api := newTestAPI(t)
+	rowUID, rowCookies := registerUser(t, api, "row@example.com")
+	colUID, colCookies := registerUser(t, api, "column@example.com")
+	for i := 0; i < 3; i++ {
+		saveCross(t, api, rowUID, 50+float64(i)*100, 50)
+		saveCross(t, api, colUID, 50, 50+float64(i)*100)
+	}
+
+	for _, tc := range []struct{ name string; cookies []*http.Cookie; want recognize.Orientation }{
+		{"row", rowCookies, recognize.Horizontal},
+		{"column", colCookies, recognize.Vertical},
+	} {
+		rec := do(api.Orientation, "GET", "/api/strokes/orientation", nil, tc.cookies)
+		if rec.Code != 200 {
+			t.Fatalf("%s: expected 200, got %d %s", tc.name, rec.Code, rec.Body.String())
+		}
+		var resp OrientationResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: failed to decode response: %v", tc.name, err)
+		}
+		if resp.Direction != tc.want || resp.Clusters != 3 || resp.Confidence < 0.9 {
+			t.Fatalf("%s: expected %s from 3 clusters with high confidence, got %+v", tc.name, tc.want, resp)
+		}
+	}
+
+	if rec := do(api.Orientation, "GET", "/api/strokes/orientation", nil, nil); rec.Code != 401 {
+		t.Fatalf("Expected 401 when signed out, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,99 @@
+package httpapi
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/deliium/drawing-board/internal/db"
+	"github.com/deliium/drawing-board/internal/export"
+	"github.com/gorilla/mux"
+)
+
+type PublicRequest struct {
+	Public bool `json:"public"`
+}
+
+type DisplayNameRequest struct {
+	DisplayName string `json:"displayName"`
+}
+
+type ProfileBoard struct {
+	ID              int64  `json:"id"`
+	Name            string `json:"name"`
+	StrokeCount     int    `json:"strokeCount"`
+	UpdatedAtUnixMs int64  `json:"updatedAtUnixMs"`
+	Thumbnail       string `json:"thumbnail"` // data: URL of a PNG
+}
+
+type Profile struct {
+	ID          int64          `json:"id"`
+	DisplayName string         `json:"displayName"`
+	JoinedAt    time.Time      `json:"joinedAt"`
+	Boards      []ProfileBoard `json:"boards"`
+}
+
+// SetPublic opts the caller into or out of the public gallery
+func (a *API) SetPublic(w http.ResponseWriter, r *http.Request) {
+	uid, ok := a.Auth.UserIDFromRequest(r)
+	if !ok { writeJSON(w, 401, map[string]string{"error":"unauthorized"}); return }
+	var req PublicRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil { writeJSON(w, 400, map[string]string{"error":"invalid json"}); return }
+	if err := a.Store.SetUserPublicContext(r.Context(), uid, req.Public); err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	writeJSON(w, 200, map[string]any{"ok": true, "public": req.Public})
+}
+
+// SetDisplayName sets the name collaborators see; an empty name reverts to the email-derived default
+func (a *API) SetDisplayName(w http.ResponseWriter, r *http.Request) {
+	uid, ok := a.Auth.UserIDFromRequest(r)
+	if !ok { writeJSON(w, 401, map[string]string{"error":"unauthorized"}); return }
+	var req DisplayNameRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil { writeJSON(w, 400, map[string]string{"error":"invalid json"}); return }
+	name := strings.TrimSpace(req.DisplayName)
+	if utf8.RuneCountInString(name) > db.MaxDisplayNameLength {
+		writeJSON(w, 400, map[string]string{"error":fmt.Sprintf("display name exceeds %d characters", db.MaxDisplayNameLength)}); return
+	}
+	if strings.IndexFunc(name, unicode.IsControl) >= 0 { writeJSON(w, 400, map[string]string{"error":"display name contains control characters"}); return }
+	if err := a.Store.SetDisplayNameContext(r.Context(), uid, name); err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	u, err := a.Store.GetUserByIDContext(r.Context(), uid)
+	if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	if u == nil { writeJSON(w, 401, map[string]string{"error":"unauthorized"}); return }
+	writeJSON(w, 200, map[string]any{"ok": true, "displayName": u.PublicName()})
+}
+
+// UserProfile returns a public user's gallery profile; private and unknown users are both 404
+func (a *API) UserProfile(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil || id <= 0 { writeJSON(w, 404, map[string]string{"error":"not found"}); return }
+	u, err := a.Store.GetUserByIDContext(r.Context(), id)
+	if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	if u == nil || !u.Public { writeJSON(w, 404, map[string]string{"error":"not found"}); return }
+
+	// Empty boards are left out of the gallery
+	boards, err := a.Store.ListBoardsByUserContext(r.Context(), id)
+	if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	all, err := a.Store.ListStrokesByUserContext(r.Context(), id)
+	if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	byBoard := strokesByBoard(all)
+	p := Profile{ID: u.ID, DisplayName: u.PublicName(), JoinedAt: u.CreatedAt, Boards: []ProfileBoard{}}
+	for _, b := range boards {
+		strokes := byBoard[b.ID]
+		if len(strokes) == 0 { continue }
+		canvas, saved, err := a.canvasFor(r.Context(), b.ID)
+		if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+		var thumb []byte
+		if saved { thumb, err = export.ThumbnailCanvas(strokes, export.DefaultThumbnailSize, canvas) } else { thumb, err = export.Thumbnail(strokes, export.DefaultThumbnailSize) }
+		if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+		var updated time.Time
+		for _, s := range strokes { if s.CreatedAt.After(updated) { updated = s.CreatedAt } }
+		p.Boards = append(p.Boards, ProfileBoard{ID: b.ID, Name: b.Name, StrokeCount: len(strokes), UpdatedAtUnixMs: updated.UnixMilli(),
+			Thumbnail: "data:image/png;base64," + base64.StdEncoding.EncodeToString(thumb)})
+	}
+	writeJSON(w, 200, p)
+}
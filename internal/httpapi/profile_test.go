@@ -0,0 +1,105 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/deliium/drawing-board/internal/db"
+	"github.com/gorilla/mux"
+)
+
+func getProfile(api *API, id int64) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/api/users/"+strconv.FormatInt(id, 10)+"/profile", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": strconv.FormatInt(id, 10)})
+	rec := httptest.NewRecorder()
+	api.UserProfile(rec, req)
+	return rec
+}
+
+func TestUserProfile_Public(t *testing.T) {
+	api := newTestAPI(t)
+	uid, cookies := registerUser(t, api, "painter@example.com")
+	if _, err := api.Store.SaveStroke(uid, "#000000", 4, 0, []db.StrokePoint{{X: 10, Y: 10}, {X: 200, Y: 120}}); err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+	if rec := do(api.SetPublic, "POST", "/api/me/public", strings.NewReader(`{"public":true}`), cookies); rec.Code != 200 {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+
+	rec := getProfile(api, uid)
+	if rec.Code != 200 {
+		t.Fatalf("Expected 200, got %d %s", rec.Code, rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "@example.com") {
+		t.Fatal("Profile must not expose the email address")
+	}
+	var p Profile
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatalf("Failed to decode profile: %v", err)
+	}
+	if p.ID != uid || p.DisplayName != "painter" || p.JoinedAt.IsZero() {
+		t.Fatalf("Unexpected profile %+v", p)
+	}
+	if len(p.Boards) != 1 || p.Boards[0].StrokeCount != 1 || !strings.HasPrefix(p.Boards[0].Thumbnail, "data:image/png;base64,") {
+		t.Fatalf("Expected one board with a PNG thumbnail, got %+v", p.Boards)
+	}
+}
+
+func TestUserProfile_PrivateAndMissing(t *testing.T) {
+	api := newTestAPI(t)
+	uid, cookies := registerUser(t, api, "shy@example.com")
+
+	if rec := getProfile(api, uid); rec.Code != 404 {
+		t.Fatalf("Expected 404 for a private user, got %d", rec.Code)
+	}
+	if rec := getProfile(api, uid+100); rec.Code != 404 {
+		t.Fatalf("Expected 404 for a missing user, got %d", rec.Code)
+	}
+
+	do(api.SetPublic, "POST", "/api/me/public", strings.NewReader(`{"public":true}`), cookies)
+	do(api.SetPublic, "POST", "/api/me/public", strings.NewReader(`{"public":false}`), cookies)
+	if rec := getProfile(api, uid); rec.Code != 404 {
+		t.Fatalf("Expected 404 after opting out, got %d", rec.Code)
+	}
+}
+
+func TestSetDisplayName_RoundTrip(t *testing.T) {
+	api := newTestAPI(t)
+	_, cookies := registerUser(t, api, "ada.l@example.com")
+
+	me := func() map[string]any {
+		t.Helper()
+		rec := do(api.Auth.Me, "GET", "/api/me", nil, cookies)
+		var body map[string]any
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Failed to decode /api/me: %v", err)
+		}
+		return body
+	}
+	if got := me()["displayName"]; got != "ada.l" {
+		t.Fatalf("Expected derived display name %q, got %v", "ada.l", got)
+	}
+
+	rec := do(api.SetDisplayName, "POST", "/api/me/display_name", strings.NewReader(`{"displayName":"  Ada Lovelace "}`), cookies)
+	if rec.Code != 200 || !strings.Contains(rec.Body.String(), `"displayName":"Ada Lovelace"`) {
+		t.Fatalf("Expected trimmed name to be saved, got %d %s", rec.Code, rec.Body.String())
+	}
+	if got := me()["displayName"]; got != "Ada Lovelace" {
+		t.Fatalf("Expected %q from /api/me, got %v", "Ada Lovelace", got)
+	}
+
+	if rec := do(api.SetDisplayName, "POST", "/api/me/display_name", strings.NewReader(`{"displayName":"`+strings.Repeat("x", db.MaxDisplayNameLength+1)+`"}`), cookies); rec.Code != 400 {
+		t.Fatalf("Expected 400 for a long name, got %d", rec.Code)
+	}
+	if rec := do(api.SetDisplayName, "POST", "/api/me/display_name", strings.NewReader(`{"displayName":"a\nb"}`), cookies); rec.Code != 400 {
+		t.Fatalf("Expected 400 for control characters, got %d", rec.Code)
+	}
+
+	do(api.SetDisplayName, "POST", "/api/me/display_name", strings.NewReader(`{"displayName":""}`), cookies)
+	if got := me()["displayName"]; got != "ada.l" {
+		t.Fatalf("Expected empty name to revert to %q, got %v", "ada.l", got)
+	}
+}
@@ -0,0 +1,24 @@
+package httpapi
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// DefaultRecentStrokes is how many strokes RecentStrokes returns when ?n= is not given
+const DefaultRecentStrokes = 10
+
+// RecentStrokes returns the caller's last ?n= strokes in drawing order, e.g. to undo the last few
+func (a *API) RecentStrokes(w http.ResponseWriter, r *http.Request) {
+	uid, ok := a.Auth.UserIDFromRequest(r)
+	if !ok { writeJSON(w, 401, map[string]string{"error":"unauthorized"}); return }
+	n := DefaultRecentStrokes
+	if v := r.URL.Query().Get("n"); v != "" {
+		var err error
+		if n, err = strconv.Atoi(v); err != nil || n <= 0 || n > MaxStrokeLimit { writeJSON(w, 400, map[string]string{"error":fmt.Sprintf("n must be between 1 and %d", MaxStrokeLimit)}); return }
+	}
+	rows, err := a.Store.ListRecentStrokesByUserContext(r.Context(), uid, n)
+	if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	writeJSON(w, 200, apiStrokes(rows))
+}
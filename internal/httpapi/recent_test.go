@@ -0,0 +1,45 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/deliium/drawing-board/internal/db"
+)
+
+func TestRecentStrokes_LastNInDrawingOrder(t *testing.T) {
+	api := newTestAPI(t)
+	uid, cookies := registerUser(t, api, "recent@example.com")
+	var ids []int64
+	for i := 0; i < 15; i++ {
+		id, err := api.Store.SaveStroke(uid, "#000000", 2, int64(i), []db.StrokePoint{{X: float64(i), Y: 1}})
+		if err != nil {
+			t.Fatalf("Failed to save stroke: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	for target, want := range map[string][]int64{
+		"/api/strokes/recent?n=3":  ids[12:],
+		"/api/strokes/recent":      ids[5:],
+		"/api/strokes/recent?n=99": ids,
+	} {
+		rec := do(api.RecentStrokes, "GET", target, nil, cookies)
+		var got []Stroke
+		if err := json.NewDecoder(rec.Body).Decode(&got); err != nil || rec.Code != 200 {
+			t.Fatalf("%s: expected 200, got %d (%v)", target, rec.Code, err)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("%s: expected %d strokes, got %d", target, len(want), len(got))
+		}
+		for i := range want {
+			if got[i].ID != want[i] || len(got[i].Points) != 1 {
+				t.Fatalf("%s: expected stroke %d at %d with its point, got %+v", target, want[i], i, got[i])
+			}
+		}
+	}
+	for _, target := range []string{"/api/strokes/recent?n=0", "/api/strokes/recent?n=x", "/api/strokes/recent?n=5001"} {
+		if rec := do(api.RecentStrokes, "GET", target, nil, cookies); rec.Code != 400 {
+			t.Fatalf("%s: expected 400, got %d", target, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/deliium/drawing-board/internal/db"
+	"github.com/deliium/drawing-board/internal/raster"
+	"github.com/deliium/drawing-board/internal/recognize"
+)
+
+// DefaultRecognizeConcurrency bounds RecognizeAll when API.RecognizeConcurrency is zero
+const DefaultRecognizeConcurrency = 4
+
+type BulkRecognizeResponse struct {
+	// Results maps board ID to its top candidate; null when the board is empty or unrecognized
+	Results map[int64]*recognize.Candidate `json:"results"`
+	Errors  map[int64]string               `json:"errors,omitempty"`
+}
+
+// RecognizeAll recognizes every board of the caller and returns the top candidate per board
+func (a *API) RecognizeAll(w http.ResponseWriter, r *http.Request) {
+	uid, ok := a.Auth.UserIDFromRequest(r)
+	if !ok { writeJSON(w, 401, map[string]string{"error":"unauthorized"}); return }
+	if a.Recognizer == nil { writeJSON(w, 503, map[string]string{"error":"recognizer unavailable"}); return }
+	req, err := a.decodeRecognizeRequest(w, r)
+	if err != nil { writeJSON(w, 400, map[string]string{"error":err.Error()}); return }
+	if err := raster.Check(req.Width, req.Height); err != nil { writeJSON(w, 400, map[string]string{"error":err.Error()}); return }
+	owned, err := a.Store.ListBoardsByUserContext(r.Context(), uid)
+	if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	strokes, err := a.Store.ListStrokesByUserContext(r.Context(), uid)
+	if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	byBoard := strokesByBoard(strokes)
+	boards := make(map[int64][]recognize.Stroke, len(owned))
+	for _, b := range owned { boards[b.ID] = a.recognizeInput(byBoard[b.ID], req.Width, req.Height, req.Recency) }
+	writeJSON(w, 200, recognizeBoards(r.Context(), a.Recognizer, boards, req.Width, req.Height, a.RecognizeConcurrency))
+}
+
+// recognizeInput filters and preprocesses stored strokes into what the recognizer sees. Recency
+// weights are assigned first so filtered-out strokes still count as older than the ones kept.
+func (a *API) recognizeInput(strokes []db.Stroke, width, height int, recency bool) []recognize.Stroke {
+	rs := toRecognizeStrokes(strokes)
+	if recency {
+		started := make([]int64, len(strokes))
+		for i, s := range strokes { started[i] = s.StartedAtUnixMs }
+		rs = recognize.WeightByRecency(rs, started)
+	}
+	return a.Preprocess.Apply(a.StrokeFilter.Apply(rs), width, height)
+}
+
+// recognizeBoards runs at most limit recognitions at once; empty boards are not sent to the
+// recognizer, and once ctx is done the boards still waiting for a slot are reported with its error
+// instead of being recognized
+func recognizeBoards(ctx context.Context, rec recognize.Recognizer, boards map[int64][]recognize.Stroke, width, height, limit int) BulkRecognizeResponse {
+	if limit <= 0 { limit = DefaultRecognizeConcurrency }
+	resp := BulkRecognizeResponse{Results: make(map[int64]*recognize.Candidate, len(boards))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, limit)
+	// Empty boards are answered before any recognition starts writing to resp
+	for id, strokes := range boards {
+		if len(strokes) == 0 { resp.Results[id] = nil }
+	}
+	for id, strokes := range boards {
+		if len(strokes) == 0 { continue }
+		wg.Add(1)
+		go func(id int64, strokes []recognize.Stroke) {
+			defer wg.Done()
+			var cands []recognize.Candidate
+			var err error
+			select {
+			case sem <- struct{}{}:
+				cands, err = recognize.RecognizeContext(ctx, rec, strokes, width, height, 1)
+				<-sem
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if resp.Errors == nil { resp.Errors = make(map[int64]string) }
+				resp.Errors[id] = err.Error()
+				resp.Results[id] = nil
+				return
+			}
+			var top *recognize.Candidate
+			if len(cands) > 0 { top = &cands[0] }
+			resp.Results[id] = top
+		}(id, strokes)
+	}
+	wg.Wait()
+	return resp
+}
+
+func toRecognizeStrokes(strokes []db.Stroke) []recognize.Stroke {
+	rs := make([]recognize.Stroke, 0, len(strokes))
+	for _, s := range strokes {
+		ps := make([]recognize.Point, 0, len(s.Points))
+		for _, p := range s.Points { ps = append(ps, recognize.Point{X:p.X, Y:p.Y}) }
+		rs = append(rs, recognize.Stroke{ Points: ps })
+	}
+	return rs
+}
@@ -0,0 +1,173 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/deliium/drawing-board/internal/db"
+	"github.com/deliium/drawing-board/internal/recognize"
+)
+
+// countingRecognizer answers with one candidate per stroke count and records peak concurrency
+type countingRecognizer struct {
+	delay   time.Duration
+	active  int32
+	peak    int32
+	mu      sync.Mutex
+	calls   int
+}
+
+func (c *countingRecognizer) Recognize(strokes []recognize.Stroke, width, height, topN int) ([]recognize.Candidate, error) {
+	n := atomic.AddInt32(&c.active, 1)
+	defer atomic.AddInt32(&c.active, -1)
+	for {
+		p := atomic.LoadInt32(&c.peak)
+		if n <= p || atomic.CompareAndSwapInt32(&c.peak, p, n) { break }
+	}
+	c.mu.Lock()
+	c.calls++
+	c.mu.Unlock()
+	time.Sleep(c.delay)
+	if len(strokes) == 3 { return nil, errors.New("model exploded") }
+	return []recognize.Candidate{{Text: strings.Repeat("一", len(strokes)), Score: 1}, {Text: "x", Score: 0.1}}, nil
+}
+
+func (c *countingRecognizer) Close() error { return nil }
+func (c *countingRecognizer) Name() string { return "counting" }
+
+func boardOf(n int) []recognize.Stroke {
+	out := make([]recognize.Stroke, n)
+	for i := range out { out[i] = recognize.Stroke{Points: []recognize.Point{{X: 0, Y: float64(i)}, {X: 10, Y: float64(i)}}} }
+	return out
+}
+
+func TestRecognizeBoards_TwoBoards(t *testing.T) {
+	rec := &countingRecognizer{}
+	resp := recognizeBoards(context.Background(), rec, map[int64][]recognize.Stroke{1: boardOf(1), 2: boardOf(2)}, 300, 300, 2)
+	if len(resp.Results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(resp.Results))
+	}
+	if resp.Results[1] == nil || resp.Results[1].Text != "一" {
+		t.Fatalf("Expected 一 for board 1, got %+v", resp.Results[1])
+	}
+	if resp.Results[2] == nil || resp.Results[2].Text != "一一" {
+		t.Fatalf("Expected 一一 for board 2, got %+v", resp.Results[2])
+	}
+	if len(resp.Errors) != 0 {
+		t.Fatalf("Expected no errors, got %v", resp.Errors)
+	}
+}
+
+func TestRecognizeBoards_RespectsConcurrencyLimit(t *testing.T) {
+	rec := &countingRecognizer{delay: 20 * time.Millisecond}
+	boards := map[int64][]recognize.Stroke{}
+	for i := int64(1); i <= 8; i++ { boards[i] = boardOf(1) }
+	resp := recognizeBoards(context.Background(), rec, boards, 300, 300, 2)
+	if len(resp.Results) != 8 || rec.calls != 8 {
+		t.Fatalf("Expected 8 results and calls, got %d and %d", len(resp.Results), rec.calls)
+	}
+	if peak := atomic.LoadInt32(&rec.peak); peak > 2 {
+		t.Fatalf("Expected at most 2 concurrent recognitions, got %d", peak)
+	}
+}
+
+func TestRecognizeBoards_EmptyAndFailingBoards(t *testing.T) {
+	rec := &countingRecognizer{}
+	resp := recognizeBoards(context.Background(), rec, map[int64][]recognize.Stroke{1: nil, 2: boardOf(3)}, 300, 300, 0)
+	if rec.calls != 1 {
+		t.Fatalf("Expected empty board to skip the recognizer, got %d calls", rec.calls)
+	}
+	if v, ok := resp.Results[1]; !ok || v != nil {
+		t.Fatalf("Expected null result for empty board, got %+v", v)
+	}
+	if resp.Errors[2] == "" {
+		t.Fatal("Expected an error for the failing board")
+	}
+}
+
+func TestRecognizeBoards_StopsOnceContextIsDone(t *testing.T) {
+	rec := &countingRecognizer{delay: 50 * time.Millisecond}
+	boards := map[int64][]recognize.Stroke{}
+	for i := int64(1); i <= 6; i++ { boards[i] = boardOf(1) }
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	resp := recognizeBoards(ctx, rec, boards, 300, 300, 1)
+	// The one recognition running at the deadline finishes; the queued boards are skipped
+	if rec.calls != 1 {
+		t.Fatalf("Expected only the board in progress to reach the recognizer, got %d calls", rec.calls)
+	}
+	if len(resp.Results) != 6 || len(resp.Errors) != 5 {
+		t.Fatalf("Expected 6 results and 5 errors, got %d and %v", len(resp.Results), resp.Errors)
+	}
+	for id, msg := range resp.Errors {
+		if msg != context.DeadlineExceeded.Error() {
+			t.Fatalf("Expected board %d to report the deadline, got %q", id, msg)
+		}
+	}
+}
+
+func TestRecognizeAll_Handler(t *testing.T) {
+	api := newTestAPI(t)
+	api.Recognizer = &countingRecognizer{}
+	uid, cookies := registerUser(t, api, "bulk@example.com")
+	if _, err := api.Store.SaveStroke(uid, "#000000", 2, 0, []db.StrokePoint{{X: 1, Y: 1}, {X: 5, Y: 1}}); err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+
+	rec := do(api.RecognizeAll, "POST", "/api/recognize/all", strings.NewReader(`{"width":300,"height":300}`), cookies)
+	if rec.Code != 200 {
+		t.Fatalf("Expected 200, got %d %s", rec.Code, rec.Body.String())
+	}
+	var resp BulkRecognizeResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	boards, _ := api.Store.ListBoardsByUser(uid)
+	if top := resp.Results[boards[0].ID]; top == nil || top.Text != "一" {
+		t.Fatalf("Expected 一 for the default board, got %+v", top)
+	}
+
+	for _, body := range []string{`{"width":`, `{"width":"wide"}`} {
+		if rec := do(api.RecognizeAll, "POST", "/api/recognize/all", strings.NewReader(body), cookies); rec.Code != 400 {
+			t.Fatalf("Expected 400 for %s, got %d", body, rec.Code)
+		}
+	}
+	big := `{"width":300,"height":300,"pad":"` + strings.Repeat("x", maxImportBody) + `"}`
+	if rec := do(api.RecognizeAll, "POST", "/api/recognize/all", strings.NewReader(big), cookies); rec.Code != 400 || !strings.Contains(rec.Body.String(), "too large") {
+		t.Fatalf("Expected 400 for an oversized body, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	if rec := do(api.RecognizeAll, "POST", "/api/recognize/all", nil, nil); rec.Code != 401 {
+		t.Fatalf("Expected 401 without a session, got %d", rec.Code)
+	}
+}
+
+func TestRecognizeAll_OneResultPerBoard(t *testing.T) {
+	api := newTestAPI(t)
+	api.Recognizer = &countingRecognizer{}
+	uid, cookies := registerUser(t, api, "boards@example.com")
+	empty, err := api.Store.CreateBoard(uid, "Empty")
+	if err != nil {
+		t.Fatalf("Failed to create board: %v", err)
+	}
+	if _, err := api.Store.SaveStroke(uid, "#000000", 2, 0, []db.StrokePoint{{X: 1, Y: 1}, {X: 5, Y: 1}}); err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+	rec := do(api.RecognizeAll, "POST", "/api/recognize/all", strings.NewReader(`{"width":300,"height":300}`), cookies)
+	var resp BulkRecognizeResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Results) != 2 {
+		t.Fatalf("Expected a result for each of the 2 boards, got %+v", resp.Results)
+	}
+	if v, ok := resp.Results[empty]; !ok || v != nil {
+		t.Fatalf("Expected null for the empty board, got %+v", v)
+	}
+}
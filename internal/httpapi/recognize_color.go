@@ -0,0 +1,47 @@
+package httpapi
+
+import (
+	"context"
+	"strings"
+
+	"github.com/deliium/drawing-board/internal/db"
+	"github.com/deliium/drawing-board/internal/recognize"
+)
+
+// ColorGroup is the recognition of one pen color on a board, for byColor requests
+type ColorGroup struct {
+	Color       string                `json:"color"` // quantized #rrggbb, or the stored color when it does not parse
+	StrokeCount int                   `json:"strokeCount"`
+	Candidates  []recognize.Candidate `json:"candidates"`
+}
+
+// groupByColor buckets strokes by quantized color, groups ordered by their first stroke
+func groupByColor(strokes []db.Stroke, levels int) (colors []string, groups map[string][]db.Stroke) {
+	if levels <= 0 { levels = recognize.DefaultColorLevels }
+	groups = map[string][]db.Stroke{}
+	for _, s := range strokes {
+		c, ok := recognize.QuantizeColor(s.Color, levels)
+		if !ok { c = strings.ToLower(s.Color) }
+		if _, seen := groups[c]; !seen { colors = append(colors, c) }
+		groups[c] = append(groups[c], s)
+	}
+	return colors, groups
+}
+
+// recognizeByColor runs the recognizer once per color group; a group left empty by the stroke
+// filter is reported without candidates rather than sent to the recognizer. It stops with ctx's
+// error once ctx is done.
+func (a *API) recognizeByColor(ctx context.Context, strokes []db.Stroke, width, height, topN int, recency bool) ([]ColorGroup, error) {
+	colors, groups := groupByColor(strokes, a.ColorLevels)
+	out := make([]ColorGroup, 0, len(colors))
+	for _, c := range colors {
+		g := ColorGroup{Color: c, StrokeCount: len(groups[c]), Candidates: []recognize.Candidate{}}
+		if rs := a.recognizeInput(groups[c], width, height, recency); len(rs) > 0 {
+			cands, err := recognize.RecognizeContext(ctx, a.Recognizer, rs, width, height, topN)
+			if err != nil { return nil, err }
+			if cands != nil { g.Candidates = cands }
+		}
+		out = append(out, g)
+	}
+	return out, nil
+}
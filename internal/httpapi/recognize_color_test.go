@@ -0,0 +1,62 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/deliium/drawing-board/internal/db"
+	"github.com/deliium/drawing-board/internal/recognize"
+)
+
+func TestRecognize_ByColorRecognizesGroupsIndependently(t *testing.T) {
+	api := newTestAPI(t)
+	api.Recognizer = recognize.NewSimpleRecognizer()
+	uid, cookies := registerUser(t, api, "colors@example.com")
+	// A black 丨 and a red 一 drawn across it: together they read as 十
+	if _, err := api.Store.SaveStroke(uid, "#000000", 2, 0, []db.StrokePoint{{X: 150, Y: 100}, {X: 150, Y: 200}}); err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+	if _, err := api.Store.SaveStroke(uid, "#e01010", 2, 0, []db.StrokePoint{{X: 100, Y: 150}, {X: 200, Y: 150}}); err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+
+	rec := do(api.Recognize, "POST", "/api/recognize", strings.NewReader(`{"topN":3,"width":300,"height":300,"byColor":true}`), cookies)
+	var resp RecognizeResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Text != "十" {
+		t.Fatalf("Expected the whole board to read as 十, got %+v", resp.Candidates)
+	}
+	if len(resp.ColorGroups) != 2 {
+		t.Fatalf("Expected 2 color groups, got %+v", resp.ColorGroups)
+	}
+	black, red := resp.ColorGroups[0], resp.ColorGroups[1]
+	if black.Color != "#000000" || black.StrokeCount != 1 || len(black.Candidates) == 0 || black.Candidates[0].Text != "丨" {
+		t.Fatalf("Expected the black group to read as 丨, got %+v", black)
+	}
+	if red.Color != "#ff0000" || red.StrokeCount != 1 || len(red.Candidates) == 0 || red.Candidates[0].Text != "一" {
+		t.Fatalf("Expected the red group to read as 一, got %+v", red)
+	}
+
+	rec = do(api.Recognize, "POST", "/api/recognize", strings.NewReader(`{"topN":3,"width":300,"height":300}`), cookies)
+	if strings.Contains(rec.Body.String(), "colorGroups") {
+		t.Fatalf("Color groups should only be computed on request, got %s", rec.Body.String())
+	}
+}
+
+func TestGroupByColor_QuantizesShades(t *testing.T) {
+	strokes := []db.Stroke{{ID: 1, Color: "#ff0000"}, {ID: 2, Color: "#000000"}, {ID: 3, Color: "#e81818"}, {ID: 4, Color: "#101010"}, {ID: 5, Color: "bogus"}}
+	colors, groups := groupByColor(strokes, 4)
+	if strings.Join(colors, ",") != "#ff0000,#000000,bogus" {
+		t.Fatalf("Expected groups in first-stroke order, got %v", colors)
+	}
+	if len(groups["#ff0000"]) != 2 || groups["#ff0000"][1].ID != 3 || len(groups["#000000"]) != 2 {
+		t.Fatalf("Expected near-identical shades to share a group, got %+v", groups)
+	}
+	colors, _ = groupByColor(strokes, 256)
+	if len(colors) != 5 {
+		t.Fatalf("Expected 256 levels to keep every color apart, got %v", colors)
+	}
+}
@@ -0,0 +1,51 @@
+package httpapi
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/deliium/drawing-board/internal/raster"
+	"github.com/deliium/drawing-board/internal/recognize"
+)
+
+// maxImageBody bounds the uploaded file; the decoded size is bounded separately by the raster limit
+const maxImageBody = 10 << 20
+
+// RecognizeImage recognizes an uploaded image sent as the raw request body. The format is sniffed
+// from the data, and the dimensions are checked against the raster limit before anything is decoded.
+func (a *API) RecognizeImage(w http.ResponseWriter, r *http.Request) {
+	if _, ok := a.Auth.UserIDFromRequest(r); !ok { writeJSON(w, 401, map[string]string{"error":"unauthorized"}); return }
+	tr, ok := a.Recognizer.(recognize.TensorRecognizer)
+	if !ok { writeJSON(w, 501, map[string]string{"error":recognize.ErrTensorUnsupported.Error()}); return }
+	topN := 0
+	if v := r.URL.Query().Get("topN"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 { writeJSON(w, 400, map[string]string{"error":"bad topN"}); return }
+		topN = n
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImageBody))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) { writeJSON(w, 413, map[string]string{"error":"image too large"}); return }
+	if err != nil { writeJSON(w, 400, map[string]string{"error":"could not read image: " + err.Error()}); return }
+
+	formats := a.ImageFormats
+	if formats == nil { formats = recognize.DefaultImageFormats }
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(body))
+	if errors.Is(err, image.ErrFormat) || (err == nil && !formats[format]) {
+		writeJSON(w, 415, map[string]string{"error":"unsupported image format; allowed: " + formats.String()}); return
+	}
+	if err != nil { writeJSON(w, 400, map[string]string{"error":"invalid image: " + err.Error()}); return }
+	if err := raster.Check(cfg.Width, cfg.Height); err != nil { writeJSON(w, 400, map[string]string{"error":err.Error()}); return }
+	img, _, err := image.Decode(bytes.NewReader(body))
+	if err != nil { writeJSON(w, 400, map[string]string{"error":"invalid image: " + err.Error()}); return }
+
+	tensor, width, height := recognize.ImageToTensor(img)
+	cands, err := tr.RecognizeTensor(tensor, width, height, 0, topN)
+	if errors.Is(err, recognize.ErrTensorUnsupported) { writeJSON(w, 501, map[string]string{"error":err.Error()}); return }
+	if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	writeJSON(w, 200, RecognizeResponse{ Candidates: cands })
+}
@@ -0,0 +1,121 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/deliium/drawing-board/internal/raster"
+	"github.com/deliium/drawing-board/internal/recognize"
+)
+
+// barImage is a white 60x60 image with a 3px black vertical bar down the middle
+func barImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 60, 60))
+	for y := 0; y < 60; y++ {
+		for x := 0; x < 60; x++ {
+			c := color.RGBA{255, 255, 255, 255}
+			if y >= 5 && y < 55 && x >= 29 && x <= 31 { c = color.RGBA{0, 0, 0, 255} }
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func encodeImage(t *testing.T, encode func(*bytes.Buffer, image.Image) error) *bytes.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := encode(&buf, barImage()); err != nil {
+		t.Fatalf("Failed to encode image: %v", err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func pngEncode(b *bytes.Buffer, img image.Image) error  { return png.Encode(b, img) }
+func jpegEncode(b *bytes.Buffer, img image.Image) error { return jpeg.Encode(b, img, nil) }
+func gifEncode(b *bytes.Buffer, img image.Image) error  { return gif.Encode(b, img, nil) }
+
+func newImageAPI(t *testing.T) (*API, []*http.Cookie) {
+	t.Helper()
+	api := newTestAPI(t)
+	onnx, err := recognize.NewONNXRecognizer("test_model.onnx")
+	if err != nil {
+		t.Fatalf("Failed to create recognizer: %v", err)
+	}
+	api.Recognizer = recognize.NewFallbackRecognizer(onnx, recognize.NewSimpleRecognizer(), 0)
+	_, cookies := registerUser(t, api, "image@example.com")
+	return api, cookies
+}
+
+func TestRecognizeImage_AllowedFormats(t *testing.T) {
+	api, cookies := newImageAPI(t)
+	for name, encode := range map[string]func(*bytes.Buffer, image.Image) error{"png": pngEncode, "jpeg": jpegEncode} {
+		rec := do(api.RecognizeImage, "POST", "/api/recognize/image?topN=2", encodeImage(t, encode), cookies)
+		if rec.Code != 200 {
+			t.Fatalf("%s: expected 200, got %d %s", name, rec.Code, rec.Body.String())
+		}
+		var resp RecognizeResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: failed to decode response: %v", name, err)
+		}
+		if len(resp.Candidates) != 2 || resp.Candidates[0].Text != "丨" {
+			t.Fatalf("%s: expected 丨 first, got %v", name, resp.Candidates)
+		}
+	}
+}
+
+func TestRecognizeImage_RejectsDisallowedFormat(t *testing.T) {
+	api, cookies := newImageAPI(t)
+	if rec := do(api.RecognizeImage, "POST", "/api/recognize/image", encodeImage(t, gifEncode), cookies); rec.Code != 415 {
+		t.Fatalf("Expected 415 for a GIF, got %d %s", rec.Code, rec.Body.String())
+	}
+	api.ImageFormats = recognize.ImageFormats{"png": true}
+	if rec := do(api.RecognizeImage, "POST", "/api/recognize/image", encodeImage(t, jpegEncode), cookies); rec.Code != 415 {
+		t.Fatalf("Expected 415 for a JPEG when only PNG is allowed, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := do(api.RecognizeImage, "POST", "/api/recognize/image", bytes.NewReader([]byte("not an image")), cookies); rec.Code != 415 {
+		t.Fatalf("Expected 415 for unrecognizable data, got %d", rec.Code)
+	}
+}
+
+func TestRecognizeImage_RejectsOversized(t *testing.T) {
+	api, cookies := newImageAPI(t)
+	defer raster.SetMaxPixels(0)
+	raster.SetMaxPixels(50 * 50)
+	if rec := do(api.RecognizeImage, "POST", "/api/recognize/image", encodeImage(t, pngEncode), cookies); rec.Code != 400 {
+		t.Fatalf("Expected 400 for an image above the raster limit, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+// failingReader errors partway through, like a client that drops the upload
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, errors.New("connection reset") }
+
+func TestRecognizeImage_BodyErrors(t *testing.T) {
+	api, cookies := newImageAPI(t)
+	if rec := do(api.RecognizeImage, "POST", "/api/recognize/image", bytes.NewReader(make([]byte, maxImageBody+1)), cookies); rec.Code != 413 {
+		t.Fatalf("Expected 413 above the upload limit, got %d %s", rec.Code, rec.Body.String())
+	}
+	// Only the size limit is a 413; a read that fails for any other reason is the client's 400
+	if rec := do(api.RecognizeImage, "POST", "/api/recognize/image", failingReader{}, cookies); rec.Code != 400 || !strings.Contains(rec.Body.String(), "connection reset") {
+		t.Fatalf("Expected 400 for a failed read, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRecognizeImage_UnsupportedRecognizer(t *testing.T) {
+	api := newTestAPI(t)
+	api.Recognizer = recognize.NewSimpleRecognizer()
+	_, cookies := registerUser(t, api, "simple@example.com")
+	if rec := do(api.RecognizeImage, "POST", "/api/recognize/image", encodeImage(t, pngEncode), cookies); rec.Code != 501 {
+		t.Fatalf("Expected 501 for a recognizer without tensor support, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,48 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/deliium/drawing-board/internal/db"
+	"github.com/deliium/drawing-board/internal/validate"
+)
+
+// decodeRecognizeRequest reads a recognize body under the import limits, since it may carry
+// strokes inline. An empty body means the defaults; malformed JSON, or a field of the wrong type,
+// is an error rather than a silently zeroed width, height or topN.
+func (a *API) decodeRecognizeRequest(w http.ResponseWriter, r *http.Request) (RecognizeRequest, error) {
+	var req RecognizeRequest
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportBody))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) { return req, errors.New("request body too large") }
+	if err != nil { return req, fmt.Errorf("could not read request body: %w", err) }
+	if len(bytes.TrimSpace(data)) == 0 { return req, nil }
+	if err := a.JSONLimits.check(data, importArrayLimits); err != nil { return req, err }
+	if err := json.Unmarshal(data, &req); err != nil {
+		var te *json.UnmarshalTypeError
+		if errors.As(err, &te) && te.Field != "" { return RecognizeRequest{}, fmt.Errorf("%s: expected %s, got %s", te.Field, te.Type, te.Value) }
+		return RecognizeRequest{}, errInvalidJSON
+	}
+	return req, nil
+}
+
+// inlineStrokes converts request strokes to the stored form recognition works on and checks them
+// against the same rules as saved strokes, so an out-of-range coordinate is refused up front
+// rather than rasterized; they are never saved
+func inlineStrokes(in []Stroke) ([]db.Stroke, validate.Errors) {
+	var errs validate.Errors
+	out := make([]db.Stroke, 0, len(in))
+	for i, s := range in {
+		pts := make([]db.StrokePoint, 0, len(s.Points))
+		for _, p := range s.Points { pts = append(pts, db.StrokePoint{X: p.X, Y: p.Y}) }
+		st := db.Stroke{ID: s.ID, Color: s.Color, Width: s.Width, StartedAtUnixMs: s.StartedAtUnixMs, Points: pts, ClientID: s.ClientID, LineStyle: s.LineStyle}
+		errs = append(errs, db.NormalizeStroke(&st, fmt.Sprintf("strokes[%d].", i))...)
+		out = append(out, st)
+	}
+	return out, errs
+}
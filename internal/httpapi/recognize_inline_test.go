@@ -0,0 +1,104 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/deliium/drawing-board/internal/db"
+	"github.com/deliium/drawing-board/internal/recognize"
+)
+
+func recognizeTop(t *testing.T, api *API, body string, cookies []*http.Cookie) string {
+	t.Helper()
+	rec := do(api.Recognize, "POST", "/api/recognize", strings.NewReader(body), cookies)
+	var resp RecognizeResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || rec.Code != 200 || len(resp.Candidates) == 0 {
+		t.Fatalf("Expected candidates, got %d %s", rec.Code, rec.Body.String())
+	}
+	return resp.Candidates[0].Text
+}
+
+func TestRecognize_InlineStrokes(t *testing.T) {
+	api := newTestAPI(t)
+	api.Recognizer = recognize.NewSimpleRecognizer()
+	uid, cookies := registerUser(t, api, "inline@example.com")
+	if _, err := api.Store.SaveStroke(uid, "#000000", 2, 0, []db.StrokePoint{{X: 100, Y: 150}, {X: 200, Y: 150}}); err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+
+	// Omitted or empty strokes fall back to the saved 一
+	for _, body := range []string{`{"topN":3,"width":300,"height":300}`, `{"topN":3,"width":300,"height":300,"strokes":[]}`, ``} {
+		if got := recognizeTop(t, api, body, cookies); got != "一" {
+			t.Fatalf("Expected the saved stroke to be recognized for %q, got %s", body, got)
+		}
+	}
+
+	inline := `{"topN":3,"width":300,"height":300,"strokes":[
+		{"points":[{"x":100,"y":150},{"x":200,"y":150}],"color":"#000000","width":2},
+		{"points":[{"x":150,"y":100},{"x":150,"y":200}],"color":"#000000","width":2}]}`
+	if got := recognizeTop(t, api, inline, cookies); got != "十" {
+		t.Fatalf("Expected the inline strokes to be recognized instead of the saved one, got %s", got)
+	}
+	if rows, _ := api.Store.ListStrokesByUser(uid); len(rows) != 1 {
+		t.Fatalf("Expected inline strokes not to be saved, got %d strokes", len(rows))
+	}
+}
+
+func TestRecognize_InlineStrokesLimited(t *testing.T) {
+	api := newTestAPI(t)
+	api.Recognizer = recognize.NewSimpleRecognizer()
+	_, cookies := registerUser(t, api, "inline-limit@example.com")
+	strokes := strings.Repeat(`{"points":[{"x":1,"y":1}]},`, MaxImportStrokes)
+	body := fmt.Sprintf(`{"width":300,"height":300,"strokes":[%s{"points":[]}]}`, strokes)
+	if rec := do(api.Recognize, "POST", "/api/recognize", strings.NewReader(body), cookies); rec.Code != 400 {
+		t.Fatalf("Expected 400 for more than %d inline strokes, got %d", MaxImportStrokes, rec.Code)
+	}
+}
+
+func TestRecognize_MalformedBodyIsRejected(t *testing.T) {
+	api := newTestAPI(t)
+	api.Recognizer = recognize.NewSimpleRecognizer()
+	uid, cookies := registerUser(t, api, "malformed@example.com")
+	if _, err := api.Store.SaveStroke(uid, "#000000", 2, 0, []db.StrokePoint{{X: 100, Y: 150}, {X: 200, Y: 150}}); err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+
+	for body, want := range map[string]string{
+		`{"topN":3,"width":`:  "invalid json",
+		`topN=3`:              "invalid json",
+		`{"topN":3} trailing`: "invalid json",
+		`{"width":"wide"}`:    "width: expected int, got string",
+	} {
+		rec := do(api.Recognize, "POST", "/api/recognize", strings.NewReader(body), cookies)
+		if rec.Code != 400 || !strings.Contains(rec.Body.String(), want) {
+			t.Fatalf("Expected 400 %q for %q, got %d %s", want, body, rec.Code, rec.Body.String())
+		}
+	}
+	// A body of only whitespace is as empty as none and still uses the defaults
+	if got := recognizeTop(t, api, " \n", cookies); got != "一" {
+		t.Fatalf("Expected the defaults for a blank body, got %s", got)
+	}
+}
+
+func TestRecognize_InlineStrokesValidated(t *testing.T) {
+	api := newTestAPI(t)
+	api.Recognizer = recognize.NewSimpleRecognizer()
+	_, cookies := registerUser(t, api, "inline-range@example.com")
+	for body, field := range map[string]string{
+		`{"width":300,"height":300,"strokes":[{"points":[{"x":0,"y":0},{"x":3e9,"y":0}],"color":"#000000","width":2}]}`: `"strokes[0].points[1]"`,
+		`{"width":300,"height":300,"strokes":[{"points":[{"x":1,"y":1}],"color":"red","width":2}]}`:                       `"strokes[0].color"`,
+	} {
+		rec := do(api.Recognize, "POST", "/api/recognize", strings.NewReader(body), cookies)
+		if rec.Code != 400 || !strings.Contains(rec.Body.String(), field) {
+			t.Fatalf("Expected 400 naming %s, got %d %s", field, rec.Code, rec.Body.String())
+		}
+	}
+	// Inline colors are normalized like saved ones before recognition sees them
+	body := `{"topN":3,"width":300,"height":300,"strokes":[{"points":[{"x":100,"y":150},{"x":200,"y":150}],"color":" #ABC ","width":2}]}`
+	if got := recognizeTop(t, api, body, cookies); got != "一" {
+		t.Fatalf("Expected a normalized inline stroke to be recognized, got %s", got)
+	}
+}
@@ -0,0 +1,35 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/deliium/drawing-board/internal/recognize"
+)
+
+// maxTensorBody fits a MaxTensorSide² tensor written with generous float precision
+const maxTensorBody = 8 << 20
+
+var tensorArrayLimits = map[string]int{"tensor": recognize.MaxTensorSide * recognize.MaxTensorSide}
+
+type TensorRecognizeRequest struct {
+	Tensor      []float32 `json:"tensor"` // row-major grayscale, 1 is ink
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	TopN        int       `json:"topN"`
+	StrokeCount int       `json:"strokeCount"` // optional; estimated from the image when 0
+}
+
+// RecognizeTensor recognizes an image the client already rasterized, skipping stroke conversion
+func (a *API) RecognizeTensor(w http.ResponseWriter, r *http.Request) {
+	if _, ok := a.Auth.UserIDFromRequest(r); !ok { writeJSON(w, 401, map[string]string{"error":"unauthorized"}); return }
+	tr, ok := a.Recognizer.(recognize.TensorRecognizer)
+	if !ok { writeJSON(w, 501, map[string]string{"error":recognize.ErrTensorUnsupported.Error()}); return }
+	var req TensorRecognizeRequest
+	if err := a.decodeLimited(w, r, maxTensorBody, tensorArrayLimits, &req); err != nil { writeJSON(w, 400, map[string]string{"error":err.Error()}); return }
+	if err := recognize.ValidateTensor(req.Tensor, req.Width, req.Height); err != nil { writeJSON(w, 400, map[string]string{"error":err.Error()}); return }
+	cands, err := tr.RecognizeTensor(req.Tensor, req.Width, req.Height, req.StrokeCount, req.TopN)
+	if errors.Is(err, recognize.ErrTensorUnsupported) { writeJSON(w, 501, map[string]string{"error":err.Error()}); return }
+	if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	writeJSON(w, 200, RecognizeResponse{ Candidates: cands })
+}
@@ -0,0 +1,68 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/deliium/drawing-board/internal/recognize"
+)
+
+func tensorBody(t *testing.T, req TensorRecognizeRequest) *bytes.Reader {
+	t.Helper()
+	b, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("Failed to encode request: %v", err)
+	}
+	return bytes.NewReader(b)
+}
+
+func TestRecognizeTensor(t *testing.T) {
+	api := newTestAPI(t)
+	onnx, err := recognize.NewONNXRecognizer("test_model.onnx")
+	if err != nil {
+		t.Fatalf("Failed to create recognizer: %v", err)
+	}
+	api.Recognizer = recognize.NewFallbackRecognizer(onnx, recognize.NewSimpleRecognizer(), 0)
+	_, cookies := registerUser(t, api, "tensor@example.com")
+
+	// A 3px vertical bar down the middle of a 60x60 image
+	const size = 60
+	tensor := make([]float32, size*size)
+	for y := 5; y < 55; y++ {
+		for x := 29; x <= 31; x++ { tensor[y*size+x] = 1 }
+	}
+	rec := do(api.RecognizeTensor, "POST", "/api/recognize/tensor", tensorBody(t, TensorRecognizeRequest{Tensor: tensor, Width: size, Height: size, TopN: 2}), cookies)
+	if rec.Code != 200 {
+		t.Fatalf("Expected 200, got %d %s", rec.Code, rec.Body.String())
+	}
+	var resp RecognizeResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(resp.Candidates) != 2 || resp.Candidates[0].Text != "丨" {
+		t.Fatalf("Expected 丨 first, got %v", resp.Candidates)
+	}
+
+	short := TensorRecognizeRequest{Tensor: tensor[:10], Width: size, Height: size}
+	if rec := do(api.RecognizeTensor, "POST", "/api/recognize/tensor", tensorBody(t, short), cookies); rec.Code != 400 || !strings.Contains(rec.Body.String(), "width*height") {
+		t.Fatalf("Expected 400 for a short tensor, got %d %s", rec.Code, rec.Body.String())
+	}
+	bright := append([]float32(nil), tensor...)
+	bright[0] = 2
+	if rec := do(api.RecognizeTensor, "POST", "/api/recognize/tensor", tensorBody(t, TensorRecognizeRequest{Tensor: bright, Width: size, Height: size}), cookies); rec.Code != 400 {
+		t.Fatalf("Expected 400 for an out-of-range value, got %d", rec.Code)
+	}
+}
+
+func TestRecognizeTensor_UnsupportedRecognizer(t *testing.T) {
+	api := newTestAPI(t)
+	api.Recognizer = recognize.NewSimpleRecognizer()
+	_, cookies := registerUser(t, api, "simple@example.com")
+
+	rec := do(api.RecognizeTensor, "POST", "/api/recognize/tensor", tensorBody(t, TensorRecognizeRequest{Tensor: []float32{0}, Width: 1, Height: 1}), cookies)
+	if rec.Code != 501 {
+		t.Fatalf("Expected 501 for a recognizer without tensor support, got %d", rec.Code)
+	}
+}
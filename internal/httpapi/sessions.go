@@ -0,0 +1,30 @@
+package httpapi
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// DefaultSessionGapMs is the pause that ends a drawing session when ?gap_ms= is not given
+const DefaultSessionGapMs = 30 * 60 * 1000
+
+type SessionsResponse struct {
+	Sessions int   `json:"sessions"`
+	GapMs    int64 `json:"gapMs"` // the gap the strokes were split on
+}
+
+// CountSessions reports how many drawing sessions the caller's strokes fall into, split wherever
+// strokes start more than ?gap_ms= apart
+func (a *API) CountSessions(w http.ResponseWriter, r *http.Request) {
+	uid, ok := a.Auth.UserIDFromRequest(r)
+	if !ok { writeJSON(w, 401, map[string]string{"error":"unauthorized"}); return }
+	gap := int64(DefaultSessionGapMs)
+	if v := r.URL.Query().Get("gap_ms"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 { writeJSON(w, 400, map[string]string{"error":"gap_ms must be a positive integer"}); return }
+		gap = n
+	}
+	n, err := a.Store.CountSessionsContext(r.Context(), uid, gap)
+	if err != nil { writeJSON(w, 500, map[string]string{"error":err.Error()}); return }
+	writeJSON(w, 200, SessionsResponse{Sessions: n, GapMs: gap})
+}
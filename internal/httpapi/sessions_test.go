@@ -0,0 +1,39 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/deliium/drawing-board/internal/db"
+)
+
+func TestCountSessions_SplitsOnLargeGaps(t *testing.T) {
+	api := newTestAPI(t)
+	uid, cookies := registerUser(t, api, "sessions@example.com")
+	const hour = 60 * 60 * 1000
+	// Two strokes a second apart, then one a day later
+	for _, at := range []int64{1000, 2000, 24*hour + 1000} {
+		if _, err := api.Store.SaveStroke(uid, "#000000", 2, at, []db.StrokePoint{{X: 1, Y: 1}}); err != nil {
+			t.Fatalf("Failed to save stroke: %v", err)
+		}
+	}
+	for target, want := range map[string]SessionsResponse{
+		"/api/strokes/sessions":                 {Sessions: 2, GapMs: DefaultSessionGapMs},
+		"/api/strokes/sessions?gap_ms=500":      {Sessions: 3, GapMs: 500},
+		"/api/strokes/sessions?gap_ms=90000000": {Sessions: 1, GapMs: 90000000},
+	} {
+		rec := do(api.CountSessions, "GET", target, nil, cookies)
+		var got SessionsResponse
+		if err := json.NewDecoder(rec.Body).Decode(&got); err != nil || rec.Code != 200 || got != want {
+			t.Fatalf("%s: expected %+v, got %d %+v (%v)", target, want, rec.Code, got, err)
+		}
+	}
+	for _, v := range []string{"0", "-5", "soon"} {
+		if rec := do(api.CountSessions, "GET", "/api/strokes/sessions?gap_ms="+v, nil, cookies); rec.Code != 400 {
+			t.Fatalf("Expected 400 for gap_ms=%s, got %d", v, rec.Code)
+		}
+	}
+	if rec := do(api.CountSessions, "GET", "/api/strokes/sessions", nil, nil); rec.Code != 401 {
+		t.Fatalf("Expected 401 without a session, got %d", rec.Code)
+	}
+}
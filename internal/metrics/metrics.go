@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Counter is a monotonically increasing value exposed on /metrics
+type Counter struct {
+	name string
+	help string
+	v    int64
+}
+
+func (c *Counter) Inc()         { atomic.AddInt64(&c.v, 1) }
+func (c *Counter) Add(n int64)  { atomic.AddInt64(&c.v, n) }
+func (c *Counter) Value() int64 { return atomic.LoadInt64(&c.v) }
+
+var (
+	mu       sync.Mutex
+	counters = map[string]*Counter{}
+)
+
+// NewCounter registers a counter under name, returning the existing one if already registered
+func NewCounter(name, help string) *Counter {
+	mu.Lock()
+	defer mu.Unlock()
+	if c, ok := counters[name]; ok { return c }
+	c := &Counter{name: name, help: help}
+	counters[name] = c
+	return c
+}
+
+// Handler writes all registered metrics in the Prometheus text exposition format
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		names := make([]string, 0, len(counters))
+		for n := range counters { names = append(names, n) }
+		sort.Strings(names)
+		list := make([]*Counter, 0, len(names))
+		for _, n := range names { list = append(list, counters[n]) }
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		for _, c := range list {
+			fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", c.name, c.help, c.name, c.name, c.Value())
+		}
+	})
+}
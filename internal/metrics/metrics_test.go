@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewCounter_SameName(t *testing.T) {
+	a := NewCounter("test_same_total", "test counter")
+	b := NewCounter("test_same_total", "test counter")
+	if a != b {
+		t.Fatal("Registering the same name twice should return the same counter")
+	}
+}
+
+func TestHandler_ExposesCounter(t *testing.T) {
+	c := NewCounter("test_exposed_total", "exposed counter")
+	c.Add(3)
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	body := rec.Body.String()
+	if !strings.Contains(body, "# TYPE test_exposed_total counter") {
+		t.Fatalf("Expected TYPE line, got:\n%s", body)
+	}
+	if !strings.Contains(body, "test_exposed_total 3\n") {
+		t.Fatalf("Expected counter value 3, got:\n%s", body)
+	}
+}
@@ -0,0 +1,89 @@
+package nfc
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// normalizationTestExcerpt is a handful of lines from NormalizationTest.txt, so the checker runs
+// even where the full file is not at hand
+const normalizationTestExcerpt = `# NormalizationTest excerpt
+@Part0 # Specific cases
+1E0A;1E0A;0044 0307;1E0A;0044 0307; # (Ḋ; Ḋ; D◌̇; Ḋ; D◌̇; ) LATIN CAPITAL LETTER D WITH DOT ABOVE
+1E0A 0323;1E0C 0307;0044 0323 0307;1E0C 0307;0044 0323 0307; # (Ḋ◌̣; Ḍ◌̇; D◌̣◌̇; Ḍ◌̇; D◌̣◌̇; )
+1E0A 031B;1E0A 031B;0044 031B 0307;1E0A 031B;0044 031B 0307; # (Ḋ◌̛; Ḋ◌̛; D◌̛◌̇; Ḋ◌̛; D◌̛◌̇; )
+@Part1 # Character by character test
+00C5;00C5;0041 030A;00C5;0041 030A; # (Å; Å; A◌̊; Å; A◌̊; ) LATIN CAPITAL LETTER A WITH RING ABOVE
+0958;0915 093C;0915 093C;0915 093C;0915 093C; # (क़; क◌़; क◌़; क◌़; क◌़; ) DEVANAGARI LETTER QA
+212B;00C5;0041 030A;00C5;0041 030A; # (Å; Å; A◌̊; Å; A◌̊; ) ANGSTROM SIGN
+AC01;AC01;1100 1161 11A8;AC01;1100 1161 11A8; # (각; 각; 각; 각; 각; ) HANGUL SYLLABLE GAG
+FB01;FB01;FB01;0066 0069;0066 0069; # (ﬁ; ﬁ; ﬁ; fi; fi; ) LATIN SMALL LIGATURE FI
+`
+
+// checkNormalizationTest checks the NFC columns of a NormalizationTest.txt: c2 == NFC(c1) ==
+// NFC(c2) == NFC(c3) and c4 == NFC(c4) == NFC(c5). It returns the code points listed in Part 1,
+// the only ones NFC may change.
+func checkNormalizationTest(t *testing.T, r io.Reader) map[rune]bool {
+	t.Helper()
+	part1 := map[rune]bool{}
+	part, n := "", 0
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		n++
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		if line = strings.TrimSpace(line); line == "" { continue }
+		if strings.HasPrefix(line, "@") { part = line; continue }
+		fields := strings.Split(line, ";")
+		if len(fields) < 5 { t.Fatalf("line %d: expected 5 columns, got %q", n, line) }
+		var c [5]string
+		for i := range c { c[i] = parseSequence(t, n, fields[i]) }
+		for _, i := range []int{0, 1, 2} {
+			if got := String(c[i]); got != c[1] { t.Fatalf("line %d: expected NFC(c%d) = %+q, got %+q", n, i+1, c[1], got) }
+		}
+		for _, i := range []int{3, 4} {
+			if got := String(c[i]); got != c[3] { t.Fatalf("line %d: expected NFC(c%d) = %+q, got %+q", n, i+1, c[3], got) }
+		}
+		if part == "@Part1" {
+			r, _ := utf8.DecodeRuneInString(c[0])
+			part1[r] = true
+		}
+	}
+	if err := sc.Err(); err != nil { t.Fatalf("Failed to read: %v", err) }
+	return part1
+}
+
+func parseSequence(t *testing.T, line int, field string) string {
+	var b strings.Builder
+	for _, f := range strings.Fields(field) {
+		v, err := strconv.ParseUint(f, 16, 32)
+		if err != nil { t.Fatalf("line %d: bad code point %q", line, f) }
+		b.WriteRune(rune(v))
+	}
+	return b.String()
+}
+
+func TestNormalizationTest_Excerpt(t *testing.T) {
+	checkNormalizationTest(t, strings.NewReader(normalizationTestExcerpt))
+}
+
+// TestNormalizationTest runs the whole of Unicode's NormalizationTest.txt, for the version the
+// tables were generated from, when NORMALIZATION_TEST names it (see make nfc-conformance)
+func TestNormalizationTest(t *testing.T) {
+	path := os.Getenv("NORMALIZATION_TEST")
+	if path == "" { t.Skip("NORMALIZATION_TEST not set") }
+	f, err := os.Open(path)
+	if err != nil { t.Fatalf("Failed to open %s: %v", path, err) }
+	defer f.Close()
+	part1 := checkNormalizationTest(t, f)
+	if len(part1) == 0 { t.Fatalf("Expected %s to have a Part 1", path) }
+	// Everything Part 1 leaves out is its own normal form
+	for r := rune(0); r <= utf8.MaxRune; r++ {
+		if part1[r] || r >= 0xD800 && r <= 0xDFFF { continue }
+		if s := string(r); String(s) != s { t.Fatalf("Expected U+%04X to be unchanged, got %+q", r, String(s)) }
+	}
+}
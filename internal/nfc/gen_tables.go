@@ -0,0 +1,114 @@
+//go:build ignore
+
+// gen_tables writes tables.go from the Unicode Character Database: decompositions and combining
+// classes from UnicodeData.txt, script-specific exclusions from CompositionExclusions.txt. The
+// rest of the full composition exclusions (singletons and non-starter decompositions) are derived.
+//
+//	go run gen_tables.go -ucd path/to/ucd -version 15.0.0 > tables.go
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	ucd := flag.String("ucd", ".", "directory holding UnicodeData.txt and CompositionExclusions.txt")
+	version := flag.String("version", "", "Unicode version of the files, recorded in the output")
+	flag.Parse()
+	if *version == "" { log.Fatal("-version is required") }
+
+	ccc := map[rune]uint8{}
+	direct := map[rune][]rune{} // canonical mappings as listed, one level deep
+	eachLine(filepath.Join(*ucd, "UnicodeData.txt"), func(fields []string) {
+		r := parseRune(fields[0])
+		if c, err := strconv.Atoi(fields[3]); err != nil { log.Fatalf("%s: bad combining class %q", fields[0], fields[3]) } else if c != 0 { ccc[r] = uint8(c) }
+		// Compatibility mappings start with a <tag> and play no part in NFC
+		if d := fields[5]; d != "" && !strings.HasPrefix(d, "<") {
+			for _, f := range strings.Fields(d) { direct[r] = append(direct[r], parseRune(f)) }
+		}
+	})
+	excluded := map[rune]bool{}
+	eachLine(filepath.Join(*ucd, "CompositionExclusions.txt"), func(fields []string) { excluded[parseRune(fields[0])] = true })
+
+	full := map[rune][]rune{}
+	var expand func(r rune) []rune
+	expand = func(r rune) []rune {
+		d, ok := direct[r]
+		if !ok { return []rune{r} }
+		var out []rune
+		for _, c := range d { out = append(out, expand(c)...) }
+		return out
+	}
+	compose := map[[2]rune]rune{}
+	for r, d := range direct {
+		full[r] = expand(r)
+		if len(d) == 2 && !excluded[r] && ccc[d[0]] == 0 { compose[[2]rune{d[0], d[1]}] = r }
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by gen_tables.go from the Unicode %s character database. DO NOT EDIT.\n\npackage nfc\n\n", *version)
+	fmt.Fprintf(&b, "// UnicodeVersion is the version of the Unicode Character Database the tables were built from\nconst UnicodeVersion = %q\n\n", *version)
+	b.WriteString("// combiningClass holds the canonical combining class of every rune whose class is not 0\nvar combiningClass = map[rune]uint8{\n")
+	for _, r := range sortedKeys(ccc) { fmt.Fprintf(&b, "%#04x: %d,\n", r, ccc[r]) }
+	b.WriteString("}\n\n// decomposition holds the full canonical decomposition of every rune that has one, Hangul syllables aside\nvar decomposition = map[rune]string{\n")
+	for _, r := range sortedKeys(full) { fmt.Fprintf(&b, "%#04x: %s,\n", r, quote(full[r])) }
+	b.WriteString("}\n\n// composition maps a starter and the rune that follows it to their primary composite, Hangul syllables aside\nvar composition = map[[2]rune]rune{\n")
+	pairs := make([][2]rune, 0, len(compose))
+	for p := range compose { pairs = append(pairs, p) }
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i][0] < pairs[j][0] || pairs[i][0] == pairs[j][0] && pairs[i][1] < pairs[j][1] })
+	for _, p := range pairs { fmt.Fprintf(&b, "{%#04x, %#04x}: %#04x,\n", p[0], p[1], compose[p]) }
+	b.WriteString("}\n")
+	src, err := format.Source(b.Bytes())
+	if err != nil { log.Fatalf("format: %v", err) }
+	os.Stdout.Write(src)
+}
+
+// eachLine calls fn with the semicolon-separated fields of every data line, comments stripped
+func eachLine(path string, fn func(fields []string)) {
+	f, err := os.Open(path)
+	if err != nil { log.Fatal(err) }
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		if strings.TrimSpace(line) == "" { continue }
+		fields := strings.Split(line, ";")
+		for i := range fields { fields[i] = strings.TrimSpace(fields[i]) }
+		fn(fields)
+	}
+	if err := sc.Err(); err != nil { log.Fatal(err) }
+}
+
+func parseRune(s string) rune {
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil { log.Fatalf("bad code point %q", s) }
+	return rune(v)
+}
+
+func sortedKeys[V any](m map[rune]V) []rune {
+	out := make([]rune, 0, len(m))
+	for r := range m { out = append(out, r) }
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// quote writes runes as \u escapes so combining marks stay legible in the source
+func quote(rs []rune) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range rs {
+		if r > 0xFFFF { fmt.Fprintf(&b, `\U%08X`, r) } else { fmt.Fprintf(&b, `\u%04X`, r) }
+	}
+	b.WriteByte('"')
+	return b.String()
+}
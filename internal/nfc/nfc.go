@@ -0,0 +1,88 @@
+package nfc
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+// Hangul syllables are decomposed and composed arithmetically rather than through the tables
+const (
+	sBase, lBase, vBase, tBase = 0xAC00, 0x1100, 0x1161, 0x11A7
+	lCount, vCount, tCount     = 19, 21, 28
+	nCount                     = vCount * tCount
+	sCount                     = lCount * nCount
+)
+
+// String returns s in Unicode Normalization Form C, so canonically equivalent spellings such as
+// "é" and "e" followed by U+0301 come out byte for byte the same. Invalid UTF-8 is passed through
+// as U+FFFD, like ranging over the string.
+func String(s string) string {
+	if ascii(s) { return s }
+	rs := make([]rune, 0, len(s))
+	for _, r := range s { rs = decompose(rs, r) }
+	reorder(rs)
+	return string(compose(rs))
+}
+
+func ascii(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf { return false }
+	}
+	return true
+}
+
+func decompose(out []rune, r rune) []rune {
+	if r >= sBase && r < sBase+sCount {
+		i := r - sBase
+		out = append(out, lBase+i/nCount, vBase+i%nCount/tCount)
+		if t := i % tCount; t != 0 { out = append(out, tBase+t) }
+		return out
+	}
+	if d, ok := decomposition[r]; ok {
+		for _, c := range d { out = append(out, c) }
+		return out
+	}
+	return append(out, r)
+}
+
+// reorder puts each run of combining marks in canonical order: stable by combining class
+func reorder(rs []rune) {
+	for i := 0; i < len(rs); {
+		if combiningClass[rs[i]] == 0 { i++; continue }
+		j := i
+		for j < len(rs) && combiningClass[rs[j]] != 0 { j++ }
+		run := rs[i:j]
+		sort.SliceStable(run, func(a, b int) bool { return combiningClass[run[a]] < combiningClass[run[b]] })
+		i = j
+	}
+}
+
+// compose joins each starter with the following runes it is not blocked from, in place
+func compose(rs []rune) []rune {
+	out := rs[:0]
+	starter := -1
+	for _, r := range rs {
+		ccc := combiningClass[r]
+		if starter >= 0 {
+			prev := combiningClass[out[len(out)-1]]
+			// Unblocked: right after the starter, or after marks of a lower class
+			if len(out) == starter+1 || prev != 0 && prev < ccc {
+				if c, ok := pair(out[starter], r); ok { out[starter] = c; continue }
+			}
+		}
+		if ccc == 0 { starter = len(out) }
+		out = append(out, r)
+	}
+	return out
+}
+
+func pair(a, b rune) (rune, bool) {
+	if a >= lBase && a < lBase+lCount && b >= vBase && b < vBase+vCount {
+		return sBase + ((a-lBase)*vCount+b-vBase)*tCount, true
+	}
+	if a >= sBase && a < sBase+sCount && (a-sBase)%tCount == 0 && b > tBase && b < tBase+tCount {
+		return a + b - tBase, true
+	}
+	c, ok := composition[[2]rune{a, b}]
+	return c, ok
+}
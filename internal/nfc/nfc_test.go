@@ -0,0 +1,34 @@
+package nfc
+
+import "testing"
+
+func TestString(t *testing.T) {
+	for _, tc := range []struct{ name, in, want string }{
+		{"ascii", "alice@example.com", "alice@example.com"},
+		{"combining acute", "jose\u0301", "jos\u00E9"},
+		{"already composed", "jos\u00E9", "jos\u00E9"},
+		{"marks reordered", "a\u0302\u0323", "\u1EAD"},
+		{"marks in canonical order", "a\u0323\u0302", "\u1EAD"},
+		{"same class is blocked", "a\u0301\u0301", "\u00E1\u0301"},
+		{"singleton", "\u212B", "\u00C5"}, // ANGSTROM SIGN never composes back
+		{"excluded composite", "\u0958", "\u0915\u093C"},
+		{"hangul lv", "\u1100\u1161", "\uAC00"},
+		{"hangul lvt", "\u1100\u1161\u11A8", "\uAC01"},
+		{"hangul syllable plus t", "\uAC00\u11A8", "\uAC01"},
+		{"leading mark", "\u0301e", "\u0301e"},
+		{"compatibility untouched", "\uFB01", "\uFB01"},
+	} {
+		if got := String(tc.in); got != tc.want {
+			t.Fatalf("%s: expected %+q, got %+q", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestString_Idempotent(t *testing.T) {
+	for _, s := range []string{"e\u0327\u0301", "d\u0307\u0323", "\u212BA\u030A", "\uD55C\uAD6D\uC5B4"} {
+		once := String(s)
+		if twice := String(once); twice != once {
+			t.Fatalf("Expected %+q to stay normalized, got %+q", once, twice)
+		}
+	}
+}
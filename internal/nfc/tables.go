@@ -0,0 +1,3932 @@
+// Code generated by gen_tables.go from the Unicode 14.0.0 character database. DO NOT EDIT.
+
+package nfc
+
+// UnicodeVersion is the version of the Unicode Character Database the tables were built from
+const UnicodeVersion = "14.0.0"
+
+// combiningClass holds the canonical combining class of every rune whose class is not 0
+var combiningClass = map[rune]uint8{
+	0x0300:  230,
+	0x0301:  230,
+	0x0302:  230,
+	0x0303:  230,
+	0x0304:  230,
+	0x0305:  230,
+	0x0306:  230,
+	0x0307:  230,
+	0x0308:  230,
+	0x0309:  230,
+	0x030a:  230,
+	0x030b:  230,
+	0x030c:  230,
+	0x030d:  230,
+	0x030e:  230,
+	0x030f:  230,
+	0x0310:  230,
+	0x0311:  230,
+	0x0312:  230,
+	0x0313:  230,
+	0x0314:  230,
+	0x0315:  232,
+	0x0316:  220,
+	0x0317:  220,
+	0x0318:  220,
+	0x0319:  220,
+	0x031a:  232,
+	0x031b:  216,
+	0x031c:  220,
+	0x031d:  220,
+	0x031e:  220,
+	0x031f:  220,
+	0x0320:  220,
+	0x0321:  202,
+	0x0322:  202,
+	0x0323:  220,
+	0x0324:  220,
+	0x0325:  220,
+	0x0326:  220,
+	0x0327:  202,
+	0x0328:  202,
+	0x0329:  220,
+	0x032a:  220,
+	0x032b:  220,
+	0x032c:  220,
+	0x032d:  220,
+	0x032e:  220,
+	0x032f:  220,
+	0x0330:  220,
+	0x0331:  220,
+	0x0332:  220,
+	0x0333:  220,
+	0x0334:  1,
+	0x0335:  1,
+	0x0336:  1,
+	0x0337:  1,
+	0x0338:  1,
+	0x0339:  220,
+	0x033a:  220,
+	0x033b:  220,
+	0x033c:  220,
+	0x033d:  230,
+	0x033e:  230,
+	0x033f:  230,
+	0x0340:  230,
+	0x0341:  230,
+	0x0342:  230,
+	0x0343:  230,
+	0x0344:  230,
+	0x0345:  240,
+	0x0346:  230,
+	0x0347:  220,
+	0x0348:  220,
+	0x0349:  220,
+	0x034a:  230,
+	0x034b:  230,
+	0x034c:  230,
+	0x034d:  220,
+	0x034e:  220,
+	0x0350:  230,
+	0x0351:  230,
+	0x0352:  230,
+	0x0353:  220,
+	0x0354:  220,
+	0x0355:  220,
+	0x0356:  220,
+	0x0357:  230,
+	0x0358:  232,
+	0x0359:  220,
+	0x035a:  220,
+	0x035b:  230,
+	0x035c:  233,
+	0x035d:  234,
+	0x035e:  234,
+	0x035f:  233,
+	0x0360:  234,
+	0x0361:  234,
+	0x0362:  233,
+	0x0363:  230,
+	0x0364:  230,
+	0x0365:  230,
+	0x0366:  230,
+	0x0367:  230,
+	0x0368:  230,
+	0x0369:  230,
+	0x036a:  230,
+	0x036b:  230,
+	0x036c:  230,
+	0x036d:  230,
+	0x036e:  230,
+	0x036f:  230,
+	0x0483:  230,
+	0x0484:  230,
+	0x0485:  230,
+	0x0486:  230,
+	0x0487:  230,
+	0x0591:  220,
+	0x0592:  230,
+	0x0593:  230,
+	0x0594:  230,
+	0x0595:  230,
+	0x0596:  220,
+	0x0597:  230,
+	0x0598:  230,
+	0x0599:  230,
+	0x059a:  222,
+	0x059b:  220,
+	0x059c:  230,
+	0x059d:  230,
+	0x059e:  230,
+	0x059f:  230,
+	0x05a0:  230,
+	0x05a1:  230,
+	0x05a2:  220,
+	0x05a3:  220,
+	0x05a4:  220,
+	0x05a5:  220,
+	0x05a6:  220,
+	0x05a7:  220,
+	0x05a8:  230,
+	0x05a9:  230,
+	0x05aa:  220,
+	0x05ab:  230,
+	0x05ac:  230,
+	0x05ad:  222,
+	0x05ae:  228,
+	0x05af:  230,
+	0x05b0:  10,
+	0x05b1:  11,
+	0x05b2:  12,
+	0x05b3:  13,
+	0x05b4:  14,
+	0x05b5:  15,
+	0x05b6:  16,
+	0x05b7:  17,
+	0x05b8:  18,
+	0x05b9:  19,
+	0x05ba:  19,
+	0x05bb:  20,
+	0x05bc:  21,
+	0x05bd:  22,
+	0x05bf:  23,
+	0x05c1:  24,
+	0x05c2:  25,
+	0x05c4:  230,
+	0x05c5:  220,
+	0x05c7:  18,
+	0x0610:  230,
+	0x0611:  230,
+	0x0612:  230,
+	0x0613:  230,
+	0x0614:  230,
+	0x0615:  230,
+	0x0616:  230,
+	0x0617:  230,
+	0x0618:  30,
+	0x0619:  31,
+	0x061a:  32,
+	0x064b:  27,
+	0x064c:  28,
+	0x064d:  29,
+	0x064e:  30,
+	0x064f:  31,
+	0x0650:  32,
+	0x0651:  33,
+	0x0652:  34,
+	0x0653:  230,
+	0x0654:  230,
+	0x0655:  220,
+	0x0656:  220,
+	0x0657:  230,
+	0x0658:  230,
+	0x0659:  230,
+	0x065a:  230,
+	0x065b:  230,
+	0x065c:  220,
+	0x065d:  230,
+	0x065e:  230,
+	0x065f:  220,
+	0x0670:  35,
+	0x06d6:  230,
+	0x06d7:  230,
+	0x06d8:  230,
+	0x06d9:  230,
+	0x06da:  230,
+	0x06db:  230,
+	0x06dc:  230,
+	0x06df:  230,
+	0x06e0:  230,
+	0x06e1:  230,
+	0x06e2:  230,
+	0x06e3:  220,
+	0x06e4:  230,
+	0x06e7:  230,
+	0x06e8:  230,
+	0x06ea:  220,
+	0x06eb:  230,
+	0x06ec:  230,
+	0x06ed:  220,
+	0x0711:  36,
+	0x0730:  230,
+	0x0731:  220,
+	0x0732:  230,
+	0x0733:  230,
+	0x0734:  220,
+	0x0735:  230,
+	0x0736:  230,
+	0x0737:  220,
+	0x0738:  220,
+	0x0739:  220,
+	0x073a:  230,
+	0x073b:  220,
+	0x073c:  220,
+	0x073d:  230,
+	0x073e:  220,
+	0x073f:  230,
+	0x0740:  230,
+	0x0741:  230,
+	0x0742:  220,
+	0x0743:  230,
+	0x0744:  220,
+	0x0745:  230,
+	0x0746:  220,
+	0x0747:  230,
+	0x0748:  220,
+	0x0749:  230,
+	0x074a:  230,
+	0x07eb:  230,
+	0x07ec:  230,
+	0x07ed:  230,
+	0x07ee:  230,
+	0x07ef:  230,
+	0x07f0:  230,
+	0x07f1:  230,
+	0x07f2:  220,
+	0x07f3:  230,
+	0x07fd:  220,
+	0x0816:  230,
+	0x0817:  230,
+	0x0818:  230,
+	0x0819:  230,
+	0x081b:  230,
+	0x081c:  230,
+	0x081d:  230,
+	0x081e:  230,
+	0x081f:  230,
+	0x0820:  230,
+	0x0821:  230,
+	0x0822:  230,
+	0x0823:  230,
+	0x0825:  230,
+	0x0826:  230,
+	0x0827:  230,
+	0x0829:  230,
+	0x082a:  230,
+	0x082b:  230,
+	0x082c:  230,
+	0x082d:  230,
+	0x0859:  220,
+	0x085a:  220,
+	0x085b:  220,
+	0x0898:  230,
+	0x0899:  220,
+	0x089a:  220,
+	0x089b:  220,
+	0x089c:  230,
+	0x089d:  230,
+	0x089e:  230,
+	0x089f:  230,
+	0x08ca:  230,
+	0x08cb:  230,
+	0x08cc:  230,
+	0x08cd:  230,
+	0x08ce:  230,
+	0x08cf:  220,
+	0x08d0:  220,
+	0x08d1:  220,
+	0x08d2:  220,
+	0x08d3:  220,
+	0x08d4:  230,
+	0x08d5:  230,
+	0x08d6:  230,
+	0x08d7:  230,
+	0x08d8:  230,
+	0x08d9:  230,
+	0x08da:  230,
+	0x08db:  230,
+	0x08dc:  230,
+	0x08dd:  230,
+	0x08de:  230,
+	0x08df:  230,
+	0x08e0:  230,
+	0x08e1:  230,
+	0x08e3:  220,
+	0x08e4:  230,
+	0x08e5:  230,
+	0x08e6:  220,
+	0x08e7:  230,
+	0x08e8:  230,
+	0x08e9:  220,
+	0x08ea:  230,
+	0x08eb:  230,
+	0x08ec:  230,
+	0x08ed:  220,
+	0x08ee:  220,
+	0x08ef:  220,
+	0x08f0:  27,
+	0x08f1:  28,
+	0x08f2:  29,
+	0x08f3:  230,
+	0x08f4:  230,
+	0x08f5:  230,
+	0x08f6:  220,
+	0x08f7:  230,
+	0x08f8:  230,
+	0x08f9:  220,
+	0x08fa:  220,
+	0x08fb:  230,
+	0x08fc:  230,
+	0x08fd:  230,
+	0x08fe:  230,
+	0x08ff:  230,
+	0x093c:  7,
+	0x094d:  9,
+	0x0951:  230,
+	0x0952:  220,
+	0x0953:  230,
+	0x0954:  230,
+	0x09bc:  7,
+	0x09cd:  9,
+	0x09fe:  230,
+	0x0a3c:  7,
+	0x0a4d:  9,
+	0x0abc:  7,
+	0x0acd:  9,
+	0x0b3c:  7,
+	0x0b4d:  9,
+	0x0bcd:  9,
+	0x0c3c:  7,
+	0x0c4d:  9,
+	0x0c55:  84,
+	0x0c56:  91,
+	0x0cbc:  7,
+	0x0ccd:  9,
+	0x0d3b:  9,
+	0x0d3c:  9,
+	0x0d4d:  9,
+	0x0dca:  9,
+	0x0e38:  103,
+	0x0e39:  103,
+	0x0e3a:  9,
+	0x0e48:  107,
+	0x0e49:  107,
+	0x0e4a:  107,
+	0x0e4b:  107,
+	0x0eb8:  118,
+	0x0eb9:  118,
+	0x0eba:  9,
+	0x0ec8:  122,
+	0x0ec9:  122,
+	0x0eca:  122,
+	0x0ecb:  122,
+	0x0f18:  220,
+	0x0f19:  220,
+	0x0f35:  220,
+	0x0f37:  220,
+	0x0f39:  216,
+	0x0f71:  129,
+	0x0f72:  130,
+	0x0f74:  132,
+	0x0f7a:  130,
+	0x0f7b:  130,
+	0x0f7c:  130,
+	0x0f7d:  130,
+	0x0f80:  130,
+	0x0f82:  230,
+	0x0f83:  230,
+	0x0f84:  9,
+	0x0f86:  230,
+	0x0f87:  230,
+	0x0fc6:  220,
+	0x1037:  7,
+	0x1039:  9,
+	0x103a:  9,
+	0x108d:  220,
+	0x135d:  230,
+	0x135e:  230,
+	0x135f:  230,
+	0x1714:  9,
+	0x1715:  9,
+	0x1734:  9,
+	0x17d2:  9,
+	0x17dd:  230,
+	0x18a9:  228,
+	0x1939:  222,
+	0x193a:  230,
+	0x193b:  220,
+	0x1a17:  230,
+	0x1a18:  220,
+	0x1a60:  9,
+	0x1a75:  230,
+	0x1a76:  230,
+	0x1a77:  230,
+	0x1a78:  230,
+	0x1a79:  230,
+	0x1a7a:  230,
+	0x1a7b:  230,
+	0x1a7c:  230,
+	0x1a7f:  220,
+	0x1ab0:  230,
+	0x1ab1:  230,
+	0x1ab2:  230,
+	0x1ab3:  230,
+	0x1ab4:  230,
+	0x1ab5:  220,
+	0x1ab6:  220,
+	0x1ab7:  220,
+	0x1ab8:  220,
+	0x1ab9:  220,
+	0x1aba:  220,
+	0x1abb:  230,
+	0x1abc:  230,
+	0x1abd:  220,
+	0x1abf:  220,
+	0x1ac0:  220,
+	0x1ac1:  230,
+	0x1ac2:  230,
+	0x1ac3:  220,
+	0x1ac4:  220,
+	0x1ac5:  230,
+	0x1ac6:  230,
+	0x1ac7:  230,
+	0x1ac8:  230,
+	0x1ac9:  230,
+	0x1aca:  220,
+	0x1acb:  230,
+	0x1acc:  230,
+	0x1acd:  230,
+	0x1ace:  230,
+	0x1b34:  7,
+	0x1b44:  9,
+	0x1b6b:  230,
+	0x1b6c:  220,
+	0x1b6d:  230,
+	0x1b6e:  230,
+	0x1b6f:  230,
+	0x1b70:  230,
+	0x1b71:  230,
+	0x1b72:  230,
+	0x1b73:  230,
+	0x1baa:  9,
+	0x1bab:  9,
+	0x1be6:  7,
+	0x1bf2:  9,
+	0x1bf3:  9,
+	0x1c37:  7,
+	0x1cd0:  230,
+	0x1cd1:  230,
+	0x1cd2:  230,
+	0x1cd4:  1,
+	0x1cd5:  220,
+	0x1cd6:  220,
+	0x1cd7:  220,
+	0x1cd8:  220,
+	0x1cd9:  220,
+	0x1cda:  230,
+	0x1cdb:  230,
+	0x1cdc:  220,
+	0x1cdd:  220,
+	0x1cde:  220,
+	0x1cdf:  220,
+	0x1ce0:  230,
+	0x1ce2:  1,
+	0x1ce3:  1,
+	0x1ce4:  1,
+	0x1ce5:  1,
+	0x1ce6:  1,
+	0x1ce7:  1,
+	0x1ce8:  1,
+	0x1ced:  220,
+	0x1cf4:  230,
+	0x1cf8:  230,
+	0x1cf9:  230,
+	0x1dc0:  230,
+	0x1dc1:  230,
+	0x1dc2:  220,
+	0x1dc3:  230,
+	0x1dc4:  230,
+	0x1dc5:  230,
+	0x1dc6:  230,
+	0x1dc7:  230,
+	0x1dc8:  230,
+	0x1dc9:  230,
+	0x1dca:  220,
+	0x1dcb:  230,
+	0x1dcc:  230,
+	0x1dcd:  234,
+	0x1dce:  214,
+	0x1dcf:  220,
+	0x1dd0:  202,
+	0x1dd1:  230,
+	0x1dd2:  230,
+	0x1dd3:  230,
+	0x1dd4:  230,
+	0x1dd5:  230,
+	0x1dd6:  230,
+	0x1dd7:  230,
+	0x1dd8:  230,
+	0x1dd9:  230,
+	0x1dda:  230,
+	0x1ddb:  230,
+	0x1ddc:  230,
+	0x1ddd:  230,
+	0x1dde:  230,
+	0x1ddf:  230,
+	0x1de0:  230,
+	0x1de1:  230,
+	0x1de2:  230,
+	0x1de3:  230,
+	0x1de4:  230,
+	0x1de5:  230,
+	0x1de6:  230,
+	0x1de7:  230,
+	0x1de8:  230,
+	0x1de9:  230,
+	0x1dea:  230,
+	0x1deb:  230,
+	0x1dec:  230,
+	0x1ded:  230,
+	0x1dee:  230,
+	0x1def:  230,
+	0x1df0:  230,
+	0x1df1:  230,
+	0x1df2:  230,
+	0x1df3:  230,
+	0x1df4:  230,
+	0x1df5:  230,
+	0x1df6:  232,
+	0x1df7:  228,
+	0x1df8:  228,
+	0x1df9:  220,
+	0x1dfa:  218,
+	0x1dfb:  230,
+	0x1dfc:  233,
+	0x1dfd:  220,
+	0x1dfe:  230,
+	0x1dff:  220,
+	0x20d0:  230,
+	0x20d1:  230,
+	0x20d2:  1,
+	0x20d3:  1,
+	0x20d4:  230,
+	0x20d5:  230,
+	0x20d6:  230,
+	0x20d7:  230,
+	0x20d8:  1,
+	0x20d9:  1,
+	0x20da:  1,
+	0x20db:  230,
+	0x20dc:  230,
+	0x20e1:  230,
+	0x20e5:  1,
+	0x20e6:  1,
+	0x20e7:  230,
+	0x20e8:  220,
+	0x20e9:  230,
+	0x20ea:  1,
+	0x20eb:  1,
+	0x20ec:  220,
+	0x20ed:  220,
+	0x20ee:  220,
+	0x20ef:  220,
+	0x20f0:  230,
+	0x2cef:  230,
+	0x2cf0:  230,
+	0x2cf1:  230,
+	0x2d7f:  9,
+	0x2de0:  230,
+	0x2de1:  230,
+	0x2de2:  230,
+	0x2de3:  230,
+	0x2de4:  230,
+	0x2de5:  230,
+	0x2de6:  230,
+	0x2de7:  230,
+	0x2de8:  230,
+	0x2de9:  230,
+	0x2dea:  230,
+	0x2deb:  230,
+	0x2dec:  230,
+	0x2ded:  230,
+	0x2dee:  230,
+	0x2def:  230,
+	0x2df0:  230,
+	0x2df1:  230,
+	0x2df2:  230,
+	0x2df3:  230,
+	0x2df4:  230,
+	0x2df5:  230,
+	0x2df6:  230,
+	0x2df7:  230,
+	0x2df8:  230,
+	0x2df9:  230,
+	0x2dfa:  230,
+	0x2dfb:  230,
+	0x2dfc:  230,
+	0x2dfd:  230,
+	0x2dfe:  230,
+	0x2dff:  230,
+	0x302a:  218,
+	0x302b:  228,
+	0x302c:  232,
+	0x302d:  222,
+	0x302e:  224,
+	0x302f:  224,
+	0x3099:  8,
+	0x309a:  8,
+	0xa66f:  230,
+	0xa674:  230,
+	0xa675:  230,
+	0xa676:  230,
+	0xa677:  230,
+	0xa678:  230,
+	0xa679:  230,
+	0xa67a:  230,
+	0xa67b:  230,
+	0xa67c:  230,
+	0xa67d:  230,
+	0xa69e:  230,
+	0xa69f:  230,
+	0xa6f0:  230,
+	0xa6f1:  230,
+	0xa806:  9,
+	0xa82c:  9,
+	0xa8c4:  9,
+	0xa8e0:  230,
+	0xa8e1:  230,
+	0xa8e2:  230,
+	0xa8e3:  230,
+	0xa8e4:  230,
+	0xa8e5:  230,
+	0xa8e6:  230,
+	0xa8e7:  230,
+	0xa8e8:  230,
+	0xa8e9:  230,
+	0xa8ea:  230,
+	0xa8eb:  230,
+	0xa8ec:  230,
+	0xa8ed:  230,
+	0xa8ee:  230,
+	0xa8ef:  230,
+	0xa8f0:  230,
+	0xa8f1:  230,
+	0xa92b:  220,
+	0xa92c:  220,
+	0xa92d:  220,
+	0xa953:  9,
+	0xa9b3:  7,
+	0xa9c0:  9,
+	0xaab0:  230,
+	0xaab2:  230,
+	0xaab3:  230,
+	0xaab4:  220,
+	0xaab7:  230,
+	0xaab8:  230,
+	0xaabe:  230,
+	0xaabf:  230,
+	0xaac1:  230,
+	0xaaf6:  9,
+	0xabed:  9,
+	0xfb1e:  26,
+	0xfe20:  230,
+	0xfe21:  230,
+	0xfe22:  230,
+	0xfe23:  230,
+	0xfe24:  230,
+	0xfe25:  230,
+	0xfe26:  230,
+	0xfe27:  220,
+	0xfe28:  220,
+	0xfe29:  220,
+	0xfe2a:  220,
+	0xfe2b:  220,
+	0xfe2c:  220,
+	0xfe2d:  220,
+	0xfe2e:  230,
+	0xfe2f:  230,
+	0x101fd: 220,
+	0x102e0: 220,
+	0x10376: 230,
+	0x10377: 230,
+	0x10378: 230,
+	0x10379: 230,
+	0x1037a: 230,
+	0x10a0d: 220,
+	0x10a0f: 230,
+	0x10a38: 230,
+	0x10a39: 1,
+	0x10a3a: 220,
+	0x10a3f: 9,
+	0x10ae5: 230,
+	0x10ae6: 220,
+	0x10d24: 230,
+	0x10d25: 230,
+	0x10d26: 230,
+	0x10d27: 230,
+	0x10eab: 230,
+	0x10eac: 230,
+	0x10f46: 220,
+	0x10f47: 220,
+	0x10f48: 230,
+	0x10f49: 230,
+	0x10f4a: 230,
+	0x10f4b: 220,
+	0x10f4c: 230,
+	0x10f4d: 220,
+	0x10f4e: 220,
+	0x10f4f: 220,
+	0x10f50: 220,
+	0x10f82: 230,
+	0x10f83: 220,
+	0x10f84: 230,
+	0x10f85: 220,
+	0x11046: 9,
+	0x11070: 9,
+	0x1107f: 9,
+	0x110b9: 9,
+	0x110ba: 7,
+	0x11100: 230,
+	0x11101: 230,
+	0x11102: 230,
+	0x11133: 9,
+	0x11134: 9,
+	0x11173: 7,
+	0x111c0: 9,
+	0x111ca: 7,
+	0x11235: 9,
+	0x11236: 7,
+	0x112e9: 7,
+	0x112ea: 9,
+	0x1133b: 7,
+	0x1133c: 7,
+	0x1134d: 9,
+	0x11366: 230,
+	0x11367: 230,
+	0x11368: 230,
+	0x11369: 230,
+	0x1136a: 230,
+	0x1136b: 230,
+	0x1136c: 230,
+	0x11370: 230,
+	0x11371: 230,
+	0x11372: 230,
+	0x11373: 230,
+	0x11374: 230,
+	0x11442: 9,
+	0x11446: 7,
+	0x1145e: 230,
+	0x114c2: 9,
+	0x114c3: 7,
+	0x115bf: 9,
+	0x115c0: 7,
+	0x1163f: 9,
+	0x116b6: 9,
+	0x116b7: 7,
+	0x1172b: 9,
+	0x11839: 9,
+	0x1183a: 7,
+	0x1193d: 9,
+	0x1193e: 9,
+	0x11943: 7,
+	0x119e0: 9,
+	0x11a34: 9,
+	0x11a47: 9,
+	0x11a99: 9,
+	0x11c3f: 9,
+	0x11d42: 7,
+	0x11d44: 9,
+	0x11d45: 9,
+	0x11d97: 9,
+	0x16af0: 1,
+	0x16af1: 1,
+	0x16af2: 1,
+	0x16af3: 1,
+	0x16af4: 1,
+	0x16b30: 230,
+	0x16b31: 230,
+	0x16b32: 230,
+	0x16b33: 230,
+	0x16b34: 230,
+	0x16b35: 230,
+	0x16b36: 230,
+	0x16ff0: 6,
+	0x16ff1: 6,
+	0x1bc9e: 1,
+	0x1d165: 216,
+	0x1d166: 216,
+	0x1d167: 1,
+	0x1d168: 1,
+	0x1d169: 1,
+	0x1d16d: 226,
+	0x1d16e: 216,
+	0x1d16f: 216,
+	0x1d170: 216,
+	0x1d171: 216,
+	0x1d172: 216,
+	0x1d17b: 220,
+	0x1d17c: 220,
+	0x1d17d: 220,
+	0x1d17e: 220,
+	0x1d17f: 220,
+	0x1d180: 220,
+	0x1d181: 220,
+	0x1d182: 220,
+	0x1d185: 230,
+	0x1d186: 230,
+	0x1d187: 230,
+	0x1d188: 230,
+	0x1d189: 230,
+	0x1d18a: 220,
+	0x1d18b: 220,
+	0x1d1aa: 230,
+	0x1d1ab: 230,
+	0x1d1ac: 230,
+	0x1d1ad: 230,
+	0x1d242: 230,
+	0x1d243: 230,
+	0x1d244: 230,
+	0x1e000: 230,
+	0x1e001: 230,
+	0x1e002: 230,
+	0x1e003: 230,
+	0x1e004: 230,
+	0x1e005: 230,
+	0x1e006: 230,
+	0x1e008: 230,
+	0x1e009: 230,
+	0x1e00a: 230,
+	0x1e00b: 230,
+	0x1e00c: 230,
+	0x1e00d: 230,
+	0x1e00e: 230,
+	0x1e00f: 230,
+	0x1e010: 230,
+	0x1e011: 230,
+	0x1e012: 230,
+	0x1e013: 230,
+	0x1e014: 230,
+	0x1e015: 230,
+	0x1e016: 230,
+	0x1e017: 230,
+	0x1e018: 230,
+	0x1e01b: 230,
+	0x1e01c: 230,
+	0x1e01d: 230,
+	0x1e01e: 230,
+	0x1e01f: 230,
+	0x1e020: 230,
+	0x1e021: 230,
+	0x1e023: 230,
+	0x1e024: 230,
+	0x1e026: 230,
+	0x1e027: 230,
+	0x1e028: 230,
+	0x1e029: 230,
+	0x1e02a: 230,
+	0x1e130: 230,
+	0x1e131: 230,
+	0x1e132: 230,
+	0x1e133: 230,
+	0x1e134: 230,
+	0x1e135: 230,
+	0x1e136: 230,
+	0x1e2ae: 230,
+	0x1e2ec: 230,
+	0x1e2ed: 230,
+	0x1e2ee: 230,
+	0x1e2ef: 230,
+	0x1e8d0: 220,
+	0x1e8d1: 220,
+	0x1e8d2: 220,
+	0x1e8d3: 220,
+	0x1e8d4: 220,
+	0x1e8d5: 220,
+	0x1e8d6: 220,
+	0x1e944: 230,
+	0x1e945: 230,
+	0x1e946: 230,
+	0x1e947: 230,
+	0x1e948: 230,
+	0x1e949: 230,
+	0x1e94a: 7,
+}
+
+// decomposition holds the full canonical decomposition of every rune that has one, Hangul syllables aside
+var decomposition = map[rune]string{
+	0x00c0:  "\u0041\u0300",
+	0x00c1:  "\u0041\u0301",
+	0x00c2:  "\u0041\u0302",
+	0x00c3:  "\u0041\u0303",
+	0x00c4:  "\u0041\u0308",
+	0x00c5:  "\u0041\u030A",
+	0x00c7:  "\u0043\u0327",
+	0x00c8:  "\u0045\u0300",
+	0x00c9:  "\u0045\u0301",
+	0x00ca:  "\u0045\u0302",
+	0x00cb:  "\u0045\u0308",
+	0x00cc:  "\u0049\u0300",
+	0x00cd:  "\u0049\u0301",
+	0x00ce:  "\u0049\u0302",
+	0x00cf:  "\u0049\u0308",
+	0x00d1:  "\u004E\u0303",
+	0x00d2:  "\u004F\u0300",
+	0x00d3:  "\u004F\u0301",
+	0x00d4:  "\u004F\u0302",
+	0x00d5:  "\u004F\u0303",
+	0x00d6:  "\u004F\u0308",
+	0x00d9:  "\u0055\u0300",
+	0x00da:  "\u0055\u0301",
+	0x00db:  "\u0055\u0302",
+	0x00dc:  "\u0055\u0308",
+	0x00dd:  "\u0059\u0301",
+	0x00e0:  "\u0061\u0300",
+	0x00e1:  "\u0061\u0301",
+	0x00e2:  "\u0061\u0302",
+	0x00e3:  "\u0061\u0303",
+	0x00e4:  "\u0061\u0308",
+	0x00e5:  "\u0061\u030A",
+	0x00e7:  "\u0063\u0327",
+	0x00e8:  "\u0065\u0300",
+	0x00e9:  "\u0065\u0301",
+	0x00ea:  "\u0065\u0302",
+	0x00eb:  "\u0065\u0308",
+	0x00ec:  "\u0069\u0300",
+	0x00ed:  "\u0069\u0301",
+	0x00ee:  "\u0069\u0302",
+	0x00ef:  "\u0069\u0308",
+	0x00f1:  "\u006E\u0303",
+	0x00f2:  "\u006F\u0300",
+	0x00f3:  "\u006F\u0301",
+	0x00f4:  "\u006F\u0302",
+	0x00f5:  "\u006F\u0303",
+	0x00f6:  "\u006F\u0308",
+	0x00f9:  "\u0075\u0300",
+	0x00fa:  "\u0075\u0301",
+	0x00fb:  "\u0075\u0302",
+	0x00fc:  "\u0075\u0308",
+	0x00fd:  "\u0079\u0301",
+	0x00ff:  "\u0079\u0308",
+	0x0100:  "\u0041\u0304",
+	0x0101:  "\u0061\u0304",
+	0x0102:  "\u0041\u0306",
+	0x0103:  "\u0061\u0306",
+	0x0104:  "\u0041\u0328",
+	0x0105:  "\u0061\u0328",
+	0x0106:  "\u0043\u0301",
+	0x0107:  "\u0063\u0301",
+	0x0108:  "\u0043\u0302",
+	0x0109:  "\u0063\u0302",
+	0x010a:  "\u0043\u0307",
+	0x010b:  "\u0063\u0307",
+	0x010c:  "\u0043\u030C",
+	0x010d:  "\u0063\u030C",
+	0x010e:  "\u0044\u030C",
+	0x010f:  "\u0064\u030C",
+	0x0112:  "\u0045\u0304",
+	0x0113:  "\u0065\u0304",
+	0x0114:  "\u0045\u0306",
+	0x0115:  "\u0065\u0306",
+	0x0116:  "\u0045\u0307",
+	0x0117:  "\u0065\u0307",
+	0x0118:  "\u0045\u0328",
+	0x0119:  "\u0065\u0328",
+	0x011a:  "\u0045\u030C",
+	0x011b:  "\u0065\u030C",
+	0x011c:  "\u0047\u0302",
+	0x011d:  "\u0067\u0302",
+	0x011e:  "\u0047\u0306",
+	0x011f:  "\u0067\u0306",
+	0x0120:  "\u0047\u0307",
+	0x0121:  "\u0067\u0307",
+	0x0122:  "\u0047\u0327",
+	0x0123:  "\u0067\u0327",
+	0x0124:  "\u0048\u0302",
+	0x0125:  "\u0068\u0302",
+	0x0128:  "\u0049\u0303",
+	0x0129:  "\u0069\u0303",
+	0x012a:  "\u0049\u0304",
+	0x012b:  "\u0069\u0304",
+	0x012c:  "\u0049\u0306",
+	0x012d:  "\u0069\u0306",
+	0x012e:  "\u0049\u0328",
+	0x012f:  "\u0069\u0328",
+	0x0130:  "\u0049\u0307",
+	0x0134:  "\u004A\u0302",
+	0x0135:  "\u006A\u0302",
+	0x0136:  "\u004B\u0327",
+	0x0137:  "\u006B\u0327",
+	0x0139:  "\u004C\u0301",
+	0x013a:  "\u006C\u0301",
+	0x013b:  "\u004C\u0327",
+	0x013c:  "\u006C\u0327",
+	0x013d:  "\u004C\u030C",
+	0x013e:  "\u006C\u030C",
+	0x0143:  "\u004E\u0301",
+	0x0144:  "\u006E\u0301",
+	0x0145:  "\u004E\u0327",
+	0x0146:  "\u006E\u0327",
+	0x0147:  "\u004E\u030C",
+	0x0148:  "\u006E\u030C",
+	0x014c:  "\u004F\u0304",
+	0x014d:  "\u006F\u0304",
+	0x014e:  "\u004F\u0306",
+	0x014f:  "\u006F\u0306",
+	0x0150:  "\u004F\u030B",
+	0x0151:  "\u006F\u030B",
+	0x0154:  "\u0052\u0301",
+	0x0155:  "\u0072\u0301",
+	0x0156:  "\u0052\u0327",
+	0x0157:  "\u0072\u0327",
+	0x0158:  "\u0052\u030C",
+	0x0159:  "\u0072\u030C",
+	0x015a:  "\u0053\u0301",
+	0x015b:  "\u0073\u0301",
+	0x015c:  "\u0053\u0302",
+	0x015d:  "\u0073\u0302",
+	0x015e:  "\u0053\u0327",
+	0x015f:  "\u0073\u0327",
+	0x0160:  "\u0053\u030C",
+	0x0161:  "\u0073\u030C",
+	0x0162:  "\u0054\u0327",
+	0x0163:  "\u0074\u0327",
+	0x0164:  "\u0054\u030C",
+	0x0165:  "\u0074\u030C",
+	0x0168:  "\u0055\u0303",
+	0x0169:  "\u0075\u0303",
+	0x016a:  "\u0055\u0304",
+	0x016b:  "\u0075\u0304",
+	0x016c:  "\u0055\u0306",
+	0x016d:  "\u0075\u0306",
+	0x016e:  "\u0055\u030A",
+	0x016f:  "\u0075\u030A",
+	0x0170:  "\u0055\u030B",
+	0x0171:  "\u0075\u030B",
+	0x0172:  "\u0055\u0328",
+	0x0173:  "\u0075\u0328",
+	0x0174:  "\u0057\u0302",
+	0x0175:  "\u0077\u0302",
+	0x0176:  "\u0059\u0302",
+	0x0177:  "\u0079\u0302",
+	0x0178:  "\u0059\u0308",
+	0x0179:  "\u005A\u0301",
+	0x017a:  "\u007A\u0301",
+	0x017b:  "\u005A\u0307",
+	0x017c:  "\u007A\u0307",
+	0x017d:  "\u005A\u030C",
+	0x017e:  "\u007A\u030C",
+	0x01a0:  "\u004F\u031B",
+	0x01a1:  "\u006F\u031B",
+	0x01af:  "\u0055\u031B",
+	0x01b0:  "\u0075\u031B",
+	0x01cd:  "\u0041\u030C",
+	0x01ce:  "\u0061\u030C",
+	0x01cf:  "\u0049\u030C",
+	0x01d0:  "\u0069\u030C",
+	0x01d1:  "\u004F\u030C",
+	0x01d2:  "\u006F\u030C",
+	0x01d3:  "\u0055\u030C",
+	0x01d4:  "\u0075\u030C",
+	0x01d5:  "\u0055\u0308\u0304",
+	0x01d6:  "\u0075\u0308\u0304",
+	0x01d7:  "\u0055\u0308\u0301",
+	0x01d8:  "\u0075\u0308\u0301",
+	0x01d9:  "\u0055\u0308\u030C",
+	0x01da:  "\u0075\u0308\u030C",
+	0x01db:  "\u0055\u0308\u0300",
+	0x01dc:  "\u0075\u0308\u0300",
+	0x01de:  "\u0041\u0308\u0304",
+	0x01df:  "\u0061\u0308\u0304",
+	0x01e0:  "\u0041\u0307\u0304",
+	0x01e1:  "\u0061\u0307\u0304",
+	0x01e2:  "\u00C6\u0304",
+	0x01e3:  "\u00E6\u0304",
+	0x01e6:  "\u0047\u030C",
+	0x01e7:  "\u0067\u030C",
+	0x01e8:  "\u004B\u030C",
+	0x01e9:  "\u006B\u030C",
+	0x01ea:  "\u004F\u0328",
+	0x01eb:  "\u006F\u0328",
+	0x01ec:  "\u004F\u0328\u0304",
+	0x01ed:  "\u006F\u0328\u0304",
+	0x01ee:  "\u01B7\u030C",
+	0x01ef:  "\u0292\u030C",
+	0x01f0:  "\u006A\u030C",
+	0x01f4:  "\u0047\u0301",
+	0x01f5:  "\u0067\u0301",
+	0x01f8:  "\u004E\u0300",
+	0x01f9:  "\u006E\u0300",
+	0x01fa:  "\u0041\u030A\u0301",
+	0x01fb:  "\u0061\u030A\u0301",
+	0x01fc:  "\u00C6\u0301",
+	0x01fd:  "\u00E6\u0301",
+	0x01fe:  "\u00D8\u0301",
+	0x01ff:  "\u00F8\u0301",
+	0x0200:  "\u0041\u030F",
+	0x0201:  "\u0061\u030F",
+	0x0202:  "\u0041\u0311",
+	0x0203:  "\u0061\u0311",
+	0x0204:  "\u0045\u030F",
+	0x0205:  "\u0065\u030F",
+	0x0206:  "\u0045\u0311",
+	0x0207:  "\u0065\u0311",
+	0x0208:  "\u0049\u030F",
+	0x0209:  "\u0069\u030F",
+	0x020a:  "\u0049\u0311",
+	0x020b:  "\u0069\u0311",
+	0x020c:  "\u004F\u030F",
+	0x020d:  "\u006F\u030F",
+	0x020e:  "\u004F\u0311",
+	0x020f:  "\u006F\u0311",
+	0x0210:  "\u0052\u030F",
+	0x0211:  "\u0072\u030F",
+	0x0212:  "\u0052\u0311",
+	0x0213:  "\u0072\u0311",
+	0x0214:  "\u0055\u030F",
+	0x0215:  "\u0075\u030F",
+	0x0216:  "\u0055\u0311",
+	0x0217:  "\u0075\u0311",
+	0x0218:  "\u0053\u0326",
+	0x0219:  "\u0073\u0326",
+	0x021a:  "\u0054\u0326",
+	0x021b:  "\u0074\u0326",
+	0x021e:  "\u0048\u030C",
+	0x021f:  "\u0068\u030C",
+	0x0226:  "\u0041\u0307",
+	0x0227:  "\u0061\u0307",
+	0x0228:  "\u0045\u0327",
+	0x0229:  "\u0065\u0327",
+	0x022a:  "\u004F\u0308\u0304",
+	0x022b:  "\u006F\u0308\u0304",
+	0x022c:  "\u004F\u0303\u0304",
+	0x022d:  "\u006F\u0303\u0304",
+	0x022e:  "\u004F\u0307",
+	0x022f:  "\u006F\u0307",
+	0x0230:  "\u004F\u0307\u0304",
+	0x0231:  "\u006F\u0307\u0304",
+	0x0232:  "\u0059\u0304",
+	0x0233:  "\u0079\u0304",
+	0x0340:  "\u0300",
+	0x0341:  "\u0301",
+	0x0343:  "\u0313",
+	0x0344:  "\u0308\u0301",
+	0x0374:  "\u02B9",
+	0x037e:  "\u003B",
+	0x0385:  "\u00A8\u0301",
+	0x0386:  "\u0391\u0301",
+	0x0387:  "\u00B7",
+	0x0388:  "\u0395\u0301",
+	0x0389:  "\u0397\u0301",
+	0x038a:  "\u0399\u0301",
+	0x038c:  "\u039F\u0301",
+	0x038e:  "\u03A5\u0301",
+	0x038f:  "\u03A9\u0301",
+	0x0390:  "\u03B9\u0308\u0301",
+	0x03aa:  "\u0399\u0308",
+	0x03ab:  "\u03A5\u0308",
+	0x03ac:  "\u03B1\u0301",
+	0x03ad:  "\u03B5\u0301",
+	0x03ae:  "\u03B7\u0301",
+	0x03af:  "\u03B9\u0301",
+	0x03b0:  "\u03C5\u0308\u0301",
+	0x03ca:  "\u03B9\u0308",
+	0x03cb:  "\u03C5\u0308",
+	0x03cc:  "\u03BF\u0301",
+	0x03cd:  "\u03C5\u0301",
+	0x03ce:  "\u03C9\u0301",
+	0x03d3:  "\u03D2\u0301",
+	0x03d4:  "\u03D2\u0308",
+	0x0400:  "\u0415\u0300",
+	0x0401:  "\u0415\u0308",
+	0x0403:  "\u0413\u0301",
+	0x0407:  "\u0406\u0308",
+	0x040c:  "\u041A\u0301",
+	0x040d:  "\u0418\u0300",
+	0x040e:  "\u0423\u0306",
+	0x0419:  "\u0418\u0306",
+	0x0439:  "\u0438\u0306",
+	0x0450:  "\u0435\u0300",
+	0x0451:  "\u0435\u0308",
+	0x0453:  "\u0433\u0301",
+	0x0457:  "\u0456\u0308",
+	0x045c:  "\u043A\u0301",
+	0x045d:  "\u0438\u0300",
+	0x045e:  "\u0443\u0306",
+	0x0476:  "\u0474\u030F",
+	0x0477:  "\u0475\u030F",
+	0x04c1:  "\u0416\u0306",
+	0x04c2:  "\u0436\u0306",
+	0x04d0:  "\u0410\u0306",
+	0x04d1:  "\u0430\u0306",
+	0x04d2:  "\u0410\u0308",
+	0x04d3:  "\u0430\u0308",
+	0x04d6:  "\u0415\u0306",
+	0x04d7:  "\u0435\u0306",
+	0x04da:  "\u04D8\u0308",
+	0x04db:  "\u04D9\u0308",
+	0x04dc:  "\u0416\u0308",
+	0x04dd:  "\u0436\u0308",
+	0x04de:  "\u0417\u0308",
+	0x04df:  "\u0437\u0308",
+	0x04e2:  "\u0418\u0304",
+	0x04e3:  "\u0438\u0304",
+	0x04e4:  "\u0418\u0308",
+	0x04e5:  "\u0438\u0308",
+	0x04e6:  "\u041E\u0308",
+	0x04e7:  "\u043E\u0308",
+	0x04ea:  "\u04E8\u0308",
+	0x04eb:  "\u04E9\u0308",
+	0x04ec:  "\u042D\u0308",
+	0x04ed:  "\u044D\u0308",
+	0x04ee:  "\u0423\u0304",
+	0x04ef:  "\u0443\u0304",
+	0x04f0:  "\u0423\u0308",
+	0x04f1:  "\u0443\u0308",
+	0x04f2:  "\u0423\u030B",
+	0x04f3:  "\u0443\u030B",
+	0x04f4:  "\u0427\u0308",
+	0x04f5:  "\u0447\u0308",
+	0x04f8:  "\u042B\u0308",
+	0x04f9:  "\u044B\u0308",
+	0x0622:  "\u0627\u0653",
+	0x0623:  "\u0627\u0654",
+	0x0624:  "\u0648\u0654",
+	0x0625:  "\u0627\u0655",
+	0x0626:  "\u064A\u0654",
+	0x06c0:  "\u06D5\u0654",
+	0x06c2:  "\u06C1\u0654",
+	0x06d3:  "\u06D2\u0654",
+	0x0929:  "\u0928\u093C",
+	0x0931:  "\u0930\u093C",
+	0x0934:  "\u0933\u093C",
+	0x0958:  "\u0915\u093C",
+	0x0959:  "\u0916\u093C",
+	0x095a:  "\u0917\u093C",
+	0x095b:  "\u091C\u093C",
+	0x095c:  "\u0921\u093C",
+	0x095d:  "\u0922\u093C",
+	0x095e:  "\u092B\u093C",
+	0x095f:  "\u092F\u093C",
+	0x09cb:  "\u09C7\u09BE",
+	0x09cc:  "\u09C7\u09D7",
+	0x09dc:  "\u09A1\u09BC",
+	0x09dd:  "\u09A2\u09BC",
+	0x09df:  "\u09AF\u09BC",
+	0x0a33:  "\u0A32\u0A3C",
+	0x0a36:  "\u0A38\u0A3C",
+	0x0a59:  "\u0A16\u0A3C",
+	0x0a5a:  "\u0A17\u0A3C",
+	0x0a5b:  "\u0A1C\u0A3C",
+	0x0a5e:  "\u0A2B\u0A3C",
+	0x0b48:  "\u0B47\u0B56",
+	0x0b4b:  "\u0B47\u0B3E",
+	0x0b4c:  "\u0B47\u0B57",
+	0x0b5c:  "\u0B21\u0B3C",
+	0x0b5d:  "\u0B22\u0B3C",
+	0x0b94:  "\u0B92\u0BD7",
+	0x0bca:  "\u0BC6\u0BBE",
+	0x0bcb:  "\u0BC7\u0BBE",
+	0x0bcc:  "\u0BC6\u0BD7",
+	0x0c48:  "\u0C46\u0C56",
+	0x0cc0:  "\u0CBF\u0CD5",
+	0x0cc7:  "\u0CC6\u0CD5",
+	0x0cc8:  "\u0CC6\u0CD6",
+	0x0cca:  "\u0CC6\u0CC2",
+	0x0ccb:  "\u0CC6\u0CC2\u0CD5",
+	0x0d4a:  "\u0D46\u0D3E",
+	0x0d4b:  "\u0D47\u0D3E",
+	0x0d4c:  "\u0D46\u0D57",
+	0x0dda:  "\u0DD9\u0DCA",
+	0x0ddc:  "\u0DD9\u0DCF",
+	0x0ddd:  "\u0DD9\u0DCF\u0DCA",
+	0x0dde:  "\u0DD9\u0DDF",
+	0x0f43:  "\u0F42\u0FB7",
+	0x0f4d:  "\u0F4C\u0FB7",
+	0x0f52:  "\u0F51\u0FB7",
+	0x0f57:  "\u0F56\u0FB7",
+	0x0f5c:  "\u0F5B\u0FB7",
+	0x0f69:  "\u0F40\u0FB5",
+	0x0f73:  "\u0F71\u0F72",
+	0x0f75:  "\u0F71\u0F74",
+	0x0f76:  "\u0FB2\u0F80",
+	0x0f78:  "\u0FB3\u0F80",
+	0x0f81:  "\u0F71\u0F80",
+	0x0f93:  "\u0F92\u0FB7",
+	0x0f9d:  "\u0F9C\u0FB7",
+	0x0fa2:  "\u0FA1\u0FB7",
+	0x0fa7:  "\u0FA6\u0FB7",
+	0x0fac:  "\u0FAB\u0FB7",
+	0x0fb9:  "\u0F90\u0FB5",
+	0x1026:  "\u1025\u102E",
+	0x1b06:  "\u1B05\u1B35",
+	0x1b08:  "\u1B07\u1B35",
+	0x1b0a:  "\u1B09\u1B35",
+	0x1b0c:  "\u1B0B\u1B35",
+	0x1b0e:  "\u1B0D\u1B35",
+	0x1b12:  "\u1B11\u1B35",
+	0x1b3b:  "\u1B3A\u1B35",
+	0x1b3d:  "\u1B3C\u1B35",
+	0x1b40:  "\u1B3E\u1B35",
+	0x1b41:  "\u1B3F\u1B35",
+	0x1b43:  "\u1B42\u1B35",
+	0x1e00:  "\u0041\u0325",
+	0x1e01:  "\u0061\u0325",
+	0x1e02:  "\u0042\u0307",
+	0x1e03:  "\u0062\u0307",
+	0x1e04:  "\u0042\u0323",
+	0x1e05:  "\u0062\u0323",
+	0x1e06:  "\u0042\u0331",
+	0x1e07:  "\u0062\u0331",
+	0x1e08:  "\u0043\u0327\u0301",
+	0x1e09:  "\u0063\u0327\u0301",
+	0x1e0a:  "\u0044\u0307",
+	0x1e0b:  "\u0064\u0307",
+	0x1e0c:  "\u0044\u0323",
+	0x1e0d:  "\u0064\u0323",
+	0x1e0e:  "\u0044\u0331",
+	0x1e0f:  "\u0064\u0331",
+	0x1e10:  "\u0044\u0327",
+	0x1e11:  "\u0064\u0327",
+	0x1e12:  "\u0044\u032D",
+	0x1e13:  "\u0064\u032D",
+	0x1e14:  "\u0045\u0304\u0300",
+	0x1e15:  "\u0065\u0304\u0300",
+	0x1e16:  "\u0045\u0304\u0301",
+	0x1e17:  "\u0065\u0304\u0301",
+	0x1e18:  "\u0045\u032D",
+	0x1e19:  "\u0065\u032D",
+	0x1e1a:  "\u0045\u0330",
+	0x1e1b:  "\u0065\u0330",
+	0x1e1c:  "\u0045\u0327\u0306",
+	0x1e1d:  "\u0065\u0327\u0306",
+	0x1e1e:  "\u0046\u0307",
+	0x1e1f:  "\u0066\u0307",
+	0x1e20:  "\u0047\u0304",
+	0x1e21:  "\u0067\u0304",
+	0x1e22:  "\u0048\u0307",
+	0x1e23:  "\u0068\u0307",
+	0x1e24:  "\u0048\u0323",
+	0x1e25:  "\u0068\u0323",
+	0x1e26:  "\u0048\u0308",
+	0x1e27:  "\u0068\u0308",
+	0x1e28:  "\u0048\u0327",
+	0x1e29:  "\u0068\u0327",
+	0x1e2a:  "\u0048\u032E",
+	0x1e2b:  "\u0068\u032E",
+	0x1e2c:  "\u0049\u0330",
+	0x1e2d:  "\u0069\u0330",
+	0x1e2e:  "\u0049\u0308\u0301",
+	0x1e2f:  "\u0069\u0308\u0301",
+	0x1e30:  "\u004B\u0301",
+	0x1e31:  "\u006B\u0301",
+	0x1e32:  "\u004B\u0323",
+	0x1e33:  "\u006B\u0323",
+	0x1e34:  "\u004B\u0331",
+	0x1e35:  "\u006B\u0331",
+	0x1e36:  "\u004C\u0323",
+	0x1e37:  "\u006C\u0323",
+	0x1e38:  "\u004C\u0323\u0304",
+	0x1e39:  "\u006C\u0323\u0304",
+	0x1e3a:  "\u004C\u0331",
+	0x1e3b:  "\u006C\u0331",
+	0x1e3c:  "\u004C\u032D",
+	0x1e3d:  "\u006C\u032D",
+	0x1e3e:  "\u004D\u0301",
+	0x1e3f:  "\u006D\u0301",
+	0x1e40:  "\u004D\u0307",
+	0x1e41:  "\u006D\u0307",
+	0x1e42:  "\u004D\u0323",
+	0x1e43:  "\u006D\u0323",
+	0x1e44:  "\u004E\u0307",
+	0x1e45:  "\u006E\u0307",
+	0x1e46:  "\u004E\u0323",
+	0x1e47:  "\u006E\u0323",
+	0x1e48:  "\u004E\u0331",
+	0x1e49:  "\u006E\u0331",
+	0x1e4a:  "\u004E\u032D",
+	0x1e4b:  "\u006E\u032D",
+	0x1e4c:  "\u004F\u0303\u0301",
+	0x1e4d:  "\u006F\u0303\u0301",
+	0x1e4e:  "\u004F\u0303\u0308",
+	0x1e4f:  "\u006F\u0303\u0308",
+	0x1e50:  "\u004F\u0304\u0300",
+	0x1e51:  "\u006F\u0304\u0300",
+	0x1e52:  "\u004F\u0304\u0301",
+	0x1e53:  "\u006F\u0304\u0301",
+	0x1e54:  "\u0050\u0301",
+	0x1e55:  "\u0070\u0301",
+	0x1e56:  "\u0050\u0307",
+	0x1e57:  "\u0070\u0307",
+	0x1e58:  "\u0052\u0307",
+	0x1e59:  "\u0072\u0307",
+	0x1e5a:  "\u0052\u0323",
+	0x1e5b:  "\u0072\u0323",
+	0x1e5c:  "\u0052\u0323\u0304",
+	0x1e5d:  "\u0072\u0323\u0304",
+	0x1e5e:  "\u0052\u0331",
+	0x1e5f:  "\u0072\u0331",
+	0x1e60:  "\u0053\u0307",
+	0x1e61:  "\u0073\u0307",
+	0x1e62:  "\u0053\u0323",
+	0x1e63:  "\u0073\u0323",
+	0x1e64:  "\u0053\u0301\u0307",
+	0x1e65:  "\u0073\u0301\u0307",
+	0x1e66:  "\u0053\u030C\u0307",
+	0x1e67:  "\u0073\u030C\u0307",
+	0x1e68:  "\u0053\u0323\u0307",
+	0x1e69:  "\u0073\u0323\u0307",
+	0x1e6a:  "\u0054\u0307",
+	0x1e6b:  "\u0074\u0307",
+	0x1e6c:  "\u0054\u0323",
+	0x1e6d:  "\u0074\u0323",
+	0x1e6e:  "\u0054\u0331",
+	0x1e6f:  "\u0074\u0331",
+	0x1e70:  "\u0054\u032D",
+	0x1e71:  "\u0074\u032D",
+	0x1e72:  "\u0055\u0324",
+	0x1e73:  "\u0075\u0324",
+	0x1e74:  "\u0055\u0330",
+	0x1e75:  "\u0075\u0330",
+	0x1e76:  "\u0055\u032D",
+	0x1e77:  "\u0075\u032D",
+	0x1e78:  "\u0055\u0303\u0301",
+	0x1e79:  "\u0075\u0303\u0301",
+	0x1e7a:  "\u0055\u0304\u0308",
+	0x1e7b:  "\u0075\u0304\u0308",
+	0x1e7c:  "\u0056\u0303",
+	0x1e7d:  "\u0076\u0303",
+	0x1e7e:  "\u0056\u0323",
+	0x1e7f:  "\u0076\u0323",
+	0x1e80:  "\u0057\u0300",
+	0x1e81:  "\u0077\u0300",
+	0x1e82:  "\u0057\u0301",
+	0x1e83:  "\u0077\u0301",
+	0x1e84:  "\u0057\u0308",
+	0x1e85:  "\u0077\u0308",
+	0x1e86:  "\u0057\u0307",
+	0x1e87:  "\u0077\u0307",
+	0x1e88:  "\u0057\u0323",
+	0x1e89:  "\u0077\u0323",
+	0x1e8a:  "\u0058\u0307",
+	0x1e8b:  "\u0078\u0307",
+	0x1e8c:  "\u0058\u0308",
+	0x1e8d:  "\u0078\u0308",
+	0x1e8e:  "\u0059\u0307",
+	0x1e8f:  "\u0079\u0307",
+	0x1e90:  "\u005A\u0302",
+	0x1e91:  "\u007A\u0302",
+	0x1e92:  "\u005A\u0323",
+	0x1e93:  "\u007A\u0323",
+	0x1e94:  "\u005A\u0331",
+	0x1e95:  "\u007A\u0331",
+	0x1e96:  "\u0068\u0331",
+	0x1e97:  "\u0074\u0308",
+	0x1e98:  "\u0077\u030A",
+	0x1e99:  "\u0079\u030A",
+	0x1e9b:  "\u017F\u0307",
+	0x1ea0:  "\u0041\u0323",
+	0x1ea1:  "\u0061\u0323",
+	0x1ea2:  "\u0041\u0309",
+	0x1ea3:  "\u0061\u0309",
+	0x1ea4:  "\u0041\u0302\u0301",
+	0x1ea5:  "\u0061\u0302\u0301",
+	0x1ea6:  "\u0041\u0302\u0300",
+	0x1ea7:  "\u0061\u0302\u0300",
+	0x1ea8:  "\u0041\u0302\u0309",
+	0x1ea9:  "\u0061\u0302\u0309",
+	0x1eaa:  "\u0041\u0302\u0303",
+	0x1eab:  "\u0061\u0302\u0303",
+	0x1eac:  "\u0041\u0323\u0302",
+	0x1ead:  "\u0061\u0323\u0302",
+	0x1eae:  "\u0041\u0306\u0301",
+	0x1eaf:  "\u0061\u0306\u0301",
+	0x1eb0:  "\u0041\u0306\u0300",
+	0x1eb1:  "\u0061\u0306\u0300",
+	0x1eb2:  "\u0041\u0306\u0309",
+	0x1eb3:  "\u0061\u0306\u0309",
+	0x1eb4:  "\u0041\u0306\u0303",
+	0x1eb5:  "\u0061\u0306\u0303",
+	0x1eb6:  "\u0041\u0323\u0306",
+	0x1eb7:  "\u0061\u0323\u0306",
+	0x1eb8:  "\u0045\u0323",
+	0x1eb9:  "\u0065\u0323",
+	0x1eba:  "\u0045\u0309",
+	0x1ebb:  "\u0065\u0309",
+	0x1ebc:  "\u0045\u0303",
+	0x1ebd:  "\u0065\u0303",
+	0x1ebe:  "\u0045\u0302\u0301",
+	0x1ebf:  "\u0065\u0302\u0301",
+	0x1ec0:  "\u0045\u0302\u0300",
+	0x1ec1:  "\u0065\u0302\u0300",
+	0x1ec2:  "\u0045\u0302\u0309",
+	0x1ec3:  "\u0065\u0302\u0309",
+	0x1ec4:  "\u0045\u0302\u0303",
+	0x1ec5:  "\u0065\u0302\u0303",
+	0x1ec6:  "\u0045\u0323\u0302",
+	0x1ec7:  "\u0065\u0323\u0302",
+	0x1ec8:  "\u0049\u0309",
+	0x1ec9:  "\u0069\u0309",
+	0x1eca:  "\u0049\u0323",
+	0x1ecb:  "\u0069\u0323",
+	0x1ecc:  "\u004F\u0323",
+	0x1ecd:  "\u006F\u0323",
+	0x1ece:  "\u004F\u0309",
+	0x1ecf:  "\u006F\u0309",
+	0x1ed0:  "\u004F\u0302\u0301",
+	0x1ed1:  "\u006F\u0302\u0301",
+	0x1ed2:  "\u004F\u0302\u0300",
+	0x1ed3:  "\u006F\u0302\u0300",
+	0x1ed4:  "\u004F\u0302\u0309",
+	0x1ed5:  "\u006F\u0302\u0309",
+	0x1ed6:  "\u004F\u0302\u0303",
+	0x1ed7:  "\u006F\u0302\u0303",
+	0x1ed8:  "\u004F\u0323\u0302",
+	0x1ed9:  "\u006F\u0323\u0302",
+	0x1eda:  "\u004F\u031B\u0301",
+	0x1edb:  "\u006F\u031B\u0301",
+	0x1edc:  "\u004F\u031B\u0300",
+	0x1edd:  "\u006F\u031B\u0300",
+	0x1ede:  "\u004F\u031B\u0309",
+	0x1edf:  "\u006F\u031B\u0309",
+	0x1ee0:  "\u004F\u031B\u0303",
+	0x1ee1:  "\u006F\u031B\u0303",
+	0x1ee2:  "\u004F\u031B\u0323",
+	0x1ee3:  "\u006F\u031B\u0323",
+	0x1ee4:  "\u0055\u0323",
+	0x1ee5:  "\u0075\u0323",
+	0x1ee6:  "\u0055\u0309",
+	0x1ee7:  "\u0075\u0309",
+	0x1ee8:  "\u0055\u031B\u0301",
+	0x1ee9:  "\u0075\u031B\u0301",
+	0x1eea:  "\u0055\u031B\u0300",
+	0x1eeb:  "\u0075\u031B\u0300",
+	0x1eec:  "\u0055\u031B\u0309",
+	0x1eed:  "\u0075\u031B\u0309",
+	0x1eee:  "\u0055\u031B\u0303",
+	0x1eef:  "\u0075\u031B\u0303",
+	0x1ef0:  "\u0055\u031B\u0323",
+	0x1ef1:  "\u0075\u031B\u0323",
+	0x1ef2:  "\u0059\u0300",
+	0x1ef3:  "\u0079\u0300",
+	0x1ef4:  "\u0059\u0323",
+	0x1ef5:  "\u0079\u0323",
+	0x1ef6:  "\u0059\u0309",
+	0x1ef7:  "\u0079\u0309",
+	0x1ef8:  "\u0059\u0303",
+	0x1ef9:  "\u0079\u0303",
+	0x1f00:  "\u03B1\u0313",
+	0x1f01:  "\u03B1\u0314",
+	0x1f02:  "\u03B1\u0313\u0300",
+	0x1f03:  "\u03B1\u0314\u0300",
+	0x1f04:  "\u03B1\u0313\u0301",
+	0x1f05:  "\u03B1\u0314\u0301",
+	0x1f06:  "\u03B1\u0313\u0342",
+	0x1f07:  "\u03B1\u0314\u0342",
+	0x1f08:  "\u0391\u0313",
+	0x1f09:  "\u0391\u0314",
+	0x1f0a:  "\u0391\u0313\u0300",
+	0x1f0b:  "\u0391\u0314\u0300",
+	0x1f0c:  "\u0391\u0313\u0301",
+	0x1f0d:  "\u0391\u0314\u0301",
+	0x1f0e:  "\u0391\u0313\u0342",
+	0x1f0f:  "\u0391\u0314\u0342",
+	0x1f10:  "\u03B5\u0313",
+	0x1f11:  "\u03B5\u0314",
+	0x1f12:  "\u03B5\u0313\u0300",
+	0x1f13:  "\u03B5\u0314\u0300",
+	0x1f14:  "\u03B5\u0313\u0301",
+	0x1f15:  "\u03B5\u0314\u0301",
+	0x1f18:  "\u0395\u0313",
+	0x1f19:  "\u0395\u0314",
+	0x1f1a:  "\u0395\u0313\u0300",
+	0x1f1b:  "\u0395\u0314\u0300",
+	0x1f1c:  "\u0395\u0313\u0301",
+	0x1f1d:  "\u0395\u0314\u0301",
+	0x1f20:  "\u03B7\u0313",
+	0x1f21:  "\u03B7\u0314",
+	0x1f22:  "\u03B7\u0313\u0300",
+	0x1f23:  "\u03B7\u0314\u0300",
+	0x1f24:  "\u03B7\u0313\u0301",
+	0x1f25:  "\u03B7\u0314\u0301",
+	0x1f26:  "\u03B7\u0313\u0342",
+	0x1f27:  "\u03B7\u0314\u0342",
+	0x1f28:  "\u0397\u0313",
+	0x1f29:  "\u0397\u0314",
+	0x1f2a:  "\u0397\u0313\u0300",
+	0x1f2b:  "\u0397\u0314\u0300",
+	0x1f2c:  "\u0397\u0313\u0301",
+	0x1f2d:  "\u0397\u0314\u0301",
+	0x1f2e:  "\u0397\u0313\u0342",
+	0x1f2f:  "\u0397\u0314\u0342",
+	0x1f30:  "\u03B9\u0313",
+	0x1f31:  "\u03B9\u0314",
+	0x1f32:  "\u03B9\u0313\u0300",
+	0x1f33:  "\u03B9\u0314\u0300",
+	0x1f34:  "\u03B9\u0313\u0301",
+	0x1f35:  "\u03B9\u0314\u0301",
+	0x1f36:  "\u03B9\u0313\u0342",
+	0x1f37:  "\u03B9\u0314\u0342",
+	0x1f38:  "\u0399\u0313",
+	0x1f39:  "\u0399\u0314",
+	0x1f3a:  "\u0399\u0313\u0300",
+	0x1f3b:  "\u0399\u0314\u0300",
+	0x1f3c:  "\u0399\u0313\u0301",
+	0x1f3d:  "\u0399\u0314\u0301",
+	0x1f3e:  "\u0399\u0313\u0342",
+	0x1f3f:  "\u0399\u0314\u0342",
+	0x1f40:  "\u03BF\u0313",
+	0x1f41:  "\u03BF\u0314",
+	0x1f42:  "\u03BF\u0313\u0300",
+	0x1f43:  "\u03BF\u0314\u0300",
+	0x1f44:  "\u03BF\u0313\u0301",
+	0x1f45:  "\u03BF\u0314\u0301",
+	0x1f48:  "\u039F\u0313",
+	0x1f49:  "\u039F\u0314",
+	0x1f4a:  "\u039F\u0313\u0300",
+	0x1f4b:  "\u039F\u0314\u0300",
+	0x1f4c:  "\u039F\u0313\u0301",
+	0x1f4d:  "\u039F\u0314\u0301",
+	0x1f50:  "\u03C5\u0313",
+	0x1f51:  "\u03C5\u0314",
+	0x1f52:  "\u03C5\u0313\u0300",
+	0x1f53:  "\u03C5\u0314\u0300",
+	0x1f54:  "\u03C5\u0313\u0301",
+	0x1f55:  "\u03C5\u0314\u0301",
+	0x1f56:  "\u03C5\u0313\u0342",
+	0x1f57:  "\u03C5\u0314\u0342",
+	0x1f59:  "\u03A5\u0314",
+	0x1f5b:  "\u03A5\u0314\u0300",
+	0x1f5d:  "\u03A5\u0314\u0301",
+	0x1f5f:  "\u03A5\u0314\u0342",
+	0x1f60:  "\u03C9\u0313",
+	0x1f61:  "\u03C9\u0314",
+	0x1f62:  "\u03C9\u0313\u0300",
+	0x1f63:  "\u03C9\u0314\u0300",
+	0x1f64:  "\u03C9\u0313\u0301",
+	0x1f65:  "\u03C9\u0314\u0301",
+	0x1f66:  "\u03C9\u0313\u0342",
+	0x1f67:  "\u03C9\u0314\u0342",
+	0x1f68:  "\u03A9\u0313",
+	0x1f69:  "\u03A9\u0314",
+	0x1f6a:  "\u03A9\u0313\u0300",
+	0x1f6b:  "\u03A9\u0314\u0300",
+	0x1f6c:  "\u03A9\u0313\u0301",
+	0x1f6d:  "\u03A9\u0314\u0301",
+	0x1f6e:  "\u03A9\u0313\u0342",
+	0x1f6f:  "\u03A9\u0314\u0342",
+	0x1f70:  "\u03B1\u0300",
+	0x1f71:  "\u03B1\u0301",
+	0x1f72:  "\u03B5\u0300",
+	0x1f73:  "\u03B5\u0301",
+	0x1f74:  "\u03B7\u0300",
+	0x1f75:  "\u03B7\u0301",
+	0x1f76:  "\u03B9\u0300",
+	0x1f77:  "\u03B9\u0301",
+	0x1f78:  "\u03BF\u0300",
+	0x1f79:  "\u03BF\u0301",
+	0x1f7a:  "\u03C5\u0300",
+	0x1f7b:  "\u03C5\u0301",
+	0x1f7c:  "\u03C9\u0300",
+	0x1f7d:  "\u03C9\u0301",
+	0x1f80:  "\u03B1\u0313\u0345",
+	0x1f81:  "\u03B1\u0314\u0345",
+	0x1f82:  "\u03B1\u0313\u0300\u0345",
+	0x1f83:  "\u03B1\u0314\u0300\u0345",
+	0x1f84:  "\u03B1\u0313\u0301\u0345",
+	0x1f85:  "\u03B1\u0314\u0301\u0345",
+	0x1f86:  "\u03B1\u0313\u0342\u0345",
+	0x1f87:  "\u03B1\u0314\u0342\u0345",
+	0x1f88:  "\u0391\u0313\u0345",
+	0x1f89:  "\u0391\u0314\u0345",
+	0x1f8a:  "\u0391\u0313\u0300\u0345",
+	0x1f8b:  "\u0391\u0314\u0300\u0345",
+	0x1f8c:  "\u0391\u0313\u0301\u0345",
+	0x1f8d:  "\u0391\u0314\u0301\u0345",
+	0x1f8e:  "\u0391\u0313\u0342\u0345",
+	0x1f8f:  "\u0391\u0314\u0342\u0345",
+	0x1f90:  "\u03B7\u0313\u0345",
+	0x1f91:  "\u03B7\u0314\u0345",
+	0x1f92:  "\u03B7\u0313\u0300\u0345",
+	0x1f93:  "\u03B7\u0314\u0300\u0345",
+	0x1f94:  "\u03B7\u0313\u0301\u0345",
+	0x1f95:  "\u03B7\u0314\u0301\u0345",
+	0x1f96:  "\u03B7\u0313\u0342\u0345",
+	0x1f97:  "\u03B7\u0314\u0342\u0345",
+	0x1f98:  "\u0397\u0313\u0345",
+	0x1f99:  "\u0397\u0314\u0345",
+	0x1f9a:  "\u0397\u0313\u0300\u0345",
+	0x1f9b:  "\u0397\u0314\u0300\u0345",
+	0x1f9c:  "\u0397\u0313\u0301\u0345",
+	0x1f9d:  "\u0397\u0314\u0301\u0345",
+	0x1f9e:  "\u0397\u0313\u0342\u0345",
+	0x1f9f:  "\u0397\u0314\u0342\u0345",
+	0x1fa0:  "\u03C9\u0313\u0345",
+	0x1fa1:  "\u03C9\u0314\u0345",
+	0x1fa2:  "\u03C9\u0313\u0300\u0345",
+	0x1fa3:  "\u03C9\u0314\u0300\u0345",
+	0x1fa4:  "\u03C9\u0313\u0301\u0345",
+	0x1fa5:  "\u03C9\u0314\u0301\u0345",
+	0x1fa6:  "\u03C9\u0313\u0342\u0345",
+	0x1fa7:  "\u03C9\u0314\u0342\u0345",
+	0x1fa8:  "\u03A9\u0313\u0345",
+	0x1fa9:  "\u03A9\u0314\u0345",
+	0x1faa:  "\u03A9\u0313\u0300\u0345",
+	0x1fab:  "\u03A9\u0314\u0300\u0345",
+	0x1fac:  "\u03A9\u0313\u0301\u0345",
+	0x1fad:  "\u03A9\u0314\u0301\u0345",
+	0x1fae:  "\u03A9\u0313\u0342\u0345",
+	0x1faf:  "\u03A9\u0314\u0342\u0345",
+	0x1fb0:  "\u03B1\u0306",
+	0x1fb1:  "\u03B1\u0304",
+	0x1fb2:  "\u03B1\u0300\u0345",
+	0x1fb3:  "\u03B1\u0345",
+	0x1fb4:  "\u03B1\u0301\u0345",
+	0x1fb6:  "\u03B1\u0342",
+	0x1fb7:  "\u03B1\u0342\u0345",
+	0x1fb8:  "\u0391\u0306",
+	0x1fb9:  "\u0391\u0304",
+	0x1fba:  "\u0391\u0300",
+	0x1fbb:  "\u0391\u0301",
+	0x1fbc:  "\u0391\u0345",
+	0x1fbe:  "\u03B9",
+	0x1fc1:  "\u00A8\u0342",
+	0x1fc2:  "\u03B7\u0300\u0345",
+	0x1fc3:  "\u03B7\u0345",
+	0x1fc4:  "\u03B7\u0301\u0345",
+	0x1fc6:  "\u03B7\u0342",
+	0x1fc7:  "\u03B7\u0342\u0345",
+	0x1fc8:  "\u0395\u0300",
+	0x1fc9:  "\u0395\u0301",
+	0x1fca:  "\u0397\u0300",
+	0x1fcb:  "\u0397\u0301",
+	0x1fcc:  "\u0397\u0345",
+	0x1fcd:  "\u1FBF\u0300",
+	0x1fce:  "\u1FBF\u0301",
+	0x1fcf:  "\u1FBF\u0342",
+	0x1fd0:  "\u03B9\u0306",
+	0x1fd1:  "\u03B9\u0304",
+	0x1fd2:  "\u03B9\u0308\u0300",
+	0x1fd3:  "\u03B9\u0308\u0301",
+	0x1fd6:  "\u03B9\u0342",
+	0x1fd7:  "\u03B9\u0308\u0342",
+	0x1fd8:  "\u0399\u0306",
+	0x1fd9:  "\u0399\u0304",
+	0x1fda:  "\u0399\u0300",
+	0x1fdb:  "\u0399\u0301",
+	0x1fdd:  "\u1FFE\u0300",
+	0x1fde:  "\u1FFE\u0301",
+	0x1fdf:  "\u1FFE\u0342",
+	0x1fe0:  "\u03C5\u0306",
+	0x1fe1:  "\u03C5\u0304",
+	0x1fe2:  "\u03C5\u0308\u0300",
+	0x1fe3:  "\u03C5\u0308\u0301",
+	0x1fe4:  "\u03C1\u0313",
+	0x1fe5:  "\u03C1\u0314",
+	0x1fe6:  "\u03C5\u0342",
+	0x1fe7:  "\u03C5\u0308\u0342",
+	0x1fe8:  "\u03A5\u0306",
+	0x1fe9:  "\u03A5\u0304",
+	0x1fea:  "\u03A5\u0300",
+	0x1feb:  "\u03A5\u0301",
+	0x1fec:  "\u03A1\u0314",
+	0x1fed:  "\u00A8\u0300",
+	0x1fee:  "\u00A8\u0301",
+	0x1fef:  "\u0060",
+	0x1ff2:  "\u03C9\u0300\u0345",
+	0x1ff3:  "\u03C9\u0345",
+	0x1ff4:  "\u03C9\u0301\u0345",
+	0x1ff6:  "\u03C9\u0342",
+	0x1ff7:  "\u03C9\u0342\u0345",
+	0x1ff8:  "\u039F\u0300",
+	0x1ff9:  "\u039F\u0301",
+	0x1ffa:  "\u03A9\u0300",
+	0x1ffb:  "\u03A9\u0301",
+	0x1ffc:  "\u03A9\u0345",
+	0x1ffd:  "\u00B4",
+	0x2000:  "\u2002",
+	0x2001:  "\u2003",
+	0x2126:  "\u03A9",
+	0x212a:  "\u004B",
+	0x212b:  "\u0041\u030A",
+	0x219a:  "\u2190\u0338",
+	0x219b:  "\u2192\u0338",
+	0x21ae:  "\u2194\u0338",
+	0x21cd:  "\u21D0\u0338",
+	0x21ce:  "\u21D4\u0338",
+	0x21cf:  "\u21D2\u0338",
+	0x2204:  "\u2203\u0338",
+	0x2209:  "\u2208\u0338",
+	0x220c:  "\u220B\u0338",
+	0x2224:  "\u2223\u0338",
+	0x2226:  "\u2225\u0338",
+	0x2241:  "\u223C\u0338",
+	0x2244:  "\u2243\u0338",
+	0x2247:  "\u2245\u0338",
+	0x2249:  "\u2248\u0338",
+	0x2260:  "\u003D\u0338",
+	0x2262:  "\u2261\u0338",
+	0x226d:  "\u224D\u0338",
+	0x226e:  "\u003C\u0338",
+	0x226f:  "\u003E\u0338",
+	0x2270:  "\u2264\u0338",
+	0x2271:  "\u2265\u0338",
+	0x2274:  "\u2272\u0338",
+	0x2275:  "\u2273\u0338",
+	0x2278:  "\u2276\u0338",
+	0x2279:  "\u2277\u0338",
+	0x2280:  "\u227A\u0338",
+	0x2281:  "\u227B\u0338",
+	0x2284:  "\u2282\u0338",
+	0x2285:  "\u2283\u0338",
+	0x2288:  "\u2286\u0338",
+	0x2289:  "\u2287\u0338",
+	0x22ac:  "\u22A2\u0338",
+	0x22ad:  "\u22A8\u0338",
+	0x22ae:  "\u22A9\u0338",
+	0x22af:  "\u22AB\u0338",
+	0x22e0:  "\u227C\u0338",
+	0x22e1:  "\u227D\u0338",
+	0x22e2:  "\u2291\u0338",
+	0x22e3:  "\u2292\u0338",
+	0x22ea:  "\u22B2\u0338",
+	0x22eb:  "\u22B3\u0338",
+	0x22ec:  "\u22B4\u0338",
+	0x22ed:  "\u22B5\u0338",
+	0x2329:  "\u3008",
+	0x232a:  "\u3009",
+	0x2adc:  "\u2ADD\u0338",
+	0x304c:  "\u304B\u3099",
+	0x304e:  "\u304D\u3099",
+	0x3050:  "\u304F\u3099",
+	0x3052:  "\u3051\u3099",
+	0x3054:  "\u3053\u3099",
+	0x3056:  "\u3055\u3099",
+	0x3058:  "\u3057\u3099",
+	0x305a:  "\u3059\u3099",
+	0x305c:  "\u305B\u3099",
+	0x305e:  "\u305D\u3099",
+	0x3060:  "\u305F\u3099",
+	0x3062:  "\u3061\u3099",
+	0x3065:  "\u3064\u3099",
+	0x3067:  "\u3066\u3099",
+	0x3069:  "\u3068\u3099",
+	0x3070:  "\u306F\u3099",
+	0x3071:  "\u306F\u309A",
+	0x3073:  "\u3072\u3099",
+	0x3074:  "\u3072\u309A",
+	0x3076:  "\u3075\u3099",
+	0x3077:  "\u3075\u309A",
+	0x3079:  "\u3078\u3099",
+	0x307a:  "\u3078\u309A",
+	0x307c:  "\u307B\u3099",
+	0x307d:  "\u307B\u309A",
+	0x3094:  "\u3046\u3099",
+	0x309e:  "\u309D\u3099",
+	0x30ac:  "\u30AB\u3099",
+	0x30ae:  "\u30AD\u3099",
+	0x30b0:  "\u30AF\u3099",
+	0x30b2:  "\u30B1\u3099",
+	0x30b4:  "\u30B3\u3099",
+	0x30b6:  "\u30B5\u3099",
+	0x30b8:  "\u30B7\u3099",
+	0x30ba:  "\u30B9\u3099",
+	0x30bc:  "\u30BB\u3099",
+	0x30be:  "\u30BD\u3099",
+	0x30c0:  "\u30BF\u3099",
+	0x30c2:  "\u30C1\u3099",
+	0x30c5:  "\u30C4\u3099",
+	0x30c7:  "\u30C6\u3099",
+	0x30c9:  "\u30C8\u3099",
+	0x30d0:  "\u30CF\u3099",
+	0x30d1:  "\u30CF\u309A",
+	0x30d3:  "\u30D2\u3099",
+	0x30d4:  "\u30D2\u309A",
+	0x30d6:  "\u30D5\u3099",
+	0x30d7:  "\u30D5\u309A",
+	0x30d9:  "\u30D8\u3099",
+	0x30da:  "\u30D8\u309A",
+	0x30dc:  "\u30DB\u3099",
+	0x30dd:  "\u30DB\u309A",
+	0x30f4:  "\u30A6\u3099",
+	0x30f7:  "\u30EF\u3099",
+	0x30f8:  "\u30F0\u3099",
+	0x30f9:  "\u30F1\u3099",
+	0x30fa:  "\u30F2\u3099",
+	0x30fe:  "\u30FD\u3099",
+	0xf900:  "\u8C48",
+	0xf901:  "\u66F4",
+	0xf902:  "\u8ECA",
+	0xf903:  "\u8CC8",
+	0xf904:  "\u6ED1",
+	0xf905:  "\u4E32",
+	0xf906:  "\u53E5",
+	0xf907:  "\u9F9C",
+	0xf908:  "\u9F9C",
+	0xf909:  "\u5951",
+	0xf90a:  "\u91D1",
+	0xf90b:  "\u5587",
+	0xf90c:  "\u5948",
+	0xf90d:  "\u61F6",
+	0xf90e:  "\u7669",
+	0xf90f:  "\u7F85",
+	0xf910:  "\u863F",
+	0xf911:  "\u87BA",
+	0xf912:  "\u88F8",
+	0xf913:  "\u908F",
+	0xf914:  "\u6A02",
+	0xf915:  "\u6D1B",
+	0xf916:  "\u70D9",
+	0xf917:  "\u73DE",
+	0xf918:  "\u843D",
+	0xf919:  "\u916A",
+	0xf91a:  "\u99F1",
+	0xf91b:  "\u4E82",
+	0xf91c:  "\u5375",
+	0xf91d:  "\u6B04",
+	0xf91e:  "\u721B",
+	0xf91f:  "\u862D",
+	0xf920:  "\u9E1E",
+	0xf921:  "\u5D50",
+	0xf922:  "\u6FEB",
+	0xf923:  "\u85CD",
+	0xf924:  "\u8964",
+	0xf925:  "\u62C9",
+	0xf926:  "\u81D8",
+	0xf927:  "\u881F",
+	0xf928:  "\u5ECA",
+	0xf929:  "\u6717",
+	0xf92a:  "\u6D6A",
+	0xf92b:  "\u72FC",
+	0xf92c:  "\u90CE",
+	0xf92d:  "\u4F86",
+	0xf92e:  "\u51B7",
+	0xf92f:  "\u52DE",
+	0xf930:  "\u64C4",
+	0xf931:  "\u6AD3",
+	0xf932:  "\u7210",
+	0xf933:  "\u76E7",
+	0xf934:  "\u8001",
+	0xf935:  "\u8606",
+	0xf936:  "\u865C",
+	0xf937:  "\u8DEF",
+	0xf938:  "\u9732",
+	0xf939:  "\u9B6F",
+	0xf93a:  "\u9DFA",
+	0xf93b:  "\u788C",
+	0xf93c:  "\u797F",
+	0xf93d:  "\u7DA0",
+	0xf93e:  "\u83C9",
+	0xf93f:  "\u9304",
+	0xf940:  "\u9E7F",
+	0xf941:  "\u8AD6",
+	0xf942:  "\u58DF",
+	0xf943:  "\u5F04",
+	0xf944:  "\u7C60",
+	0xf945:  "\u807E",
+	0xf946:  "\u7262",
+	0xf947:  "\u78CA",
+	0xf948:  "\u8CC2",
+	0xf949:  "\u96F7",
+	0xf94a:  "\u58D8",
+	0xf94b:  "\u5C62",
+	0xf94c:  "\u6A13",
+	0xf94d:  "\u6DDA",
+	0xf94e:  "\u6F0F",
+	0xf94f:  "\u7D2F",
+	0xf950:  "\u7E37",
+	0xf951:  "\u964B",
+	0xf952:  "\u52D2",
+	0xf953:  "\u808B",
+	0xf954:  "\u51DC",
+	0xf955:  "\u51CC",
+	0xf956:  "\u7A1C",
+	0xf957:  "\u7DBE",
+	0xf958:  "\u83F1",
+	0xf959:  "\u9675",
+	0xf95a:  "\u8B80",
+	0xf95b:  "\u62CF",
+	0xf95c:  "\u6A02",
+	0xf95d:  "\u8AFE",
+	0xf95e:  "\u4E39",
+	0xf95f:  "\u5BE7",
+	0xf960:  "\u6012",
+	0xf961:  "\u7387",
+	0xf962:  "\u7570",
+	0xf963:  "\u5317",
+	0xf964:  "\u78FB",
+	0xf965:  "\u4FBF",
+	0xf966:  "\u5FA9",
+	0xf967:  "\u4E0D",
+	0xf968:  "\u6CCC",
+	0xf969:  "\u6578",
+	0xf96a:  "\u7D22",
+	0xf96b:  "\u53C3",
+	0xf96c:  "\u585E",
+	0xf96d:  "\u7701",
+	0xf96e:  "\u8449",
+	0xf96f:  "\u8AAA",
+	0xf970:  "\u6BBA",
+	0xf971:  "\u8FB0",
+	0xf972:  "\u6C88",
+	0xf973:  "\u62FE",
+	0xf974:  "\u82E5",
+	0xf975:  "\u63A0",
+	0xf976:  "\u7565",
+	0xf977:  "\u4EAE",
+	0xf978:  "\u5169",
+	0xf979:  "\u51C9",
+	0xf97a:  "\u6881",
+	0xf97b:  "\u7CE7",
+	0xf97c:  "\u826F",
+	0xf97d:  "\u8AD2",
+	0xf97e:  "\u91CF",
+	0xf97f:  "\u52F5",
+	0xf980:  "\u5442",
+	0xf981:  "\u5973",
+	0xf982:  "\u5EEC",
+	0xf983:  "\u65C5",
+	0xf984:  "\u6FFE",
+	0xf985:  "\u792A",
+	0xf986:  "\u95AD",
+	0xf987:  "\u9A6A",
+	0xf988:  "\u9E97",
+	0xf989:  "\u9ECE",
+	0xf98a:  "\u529B",
+	0xf98b:  "\u66C6",
+	0xf98c:  "\u6B77",
+	0xf98d:  "\u8F62",
+	0xf98e:  "\u5E74",
+	0xf98f:  "\u6190",
+	0xf990:  "\u6200",
+	0xf991:  "\u649A",
+	0xf992:  "\u6F23",
+	0xf993:  "\u7149",
+	0xf994:  "\u7489",
+	0xf995:  "\u79CA",
+	0xf996:  "\u7DF4",
+	0xf997:  "\u806F",
+	0xf998:  "\u8F26",
+	0xf999:  "\u84EE",
+	0xf99a:  "\u9023",
+	0xf99b:  "\u934A",
+	0xf99c:  "\u5217",
+	0xf99d:  "\u52A3",
+	0xf99e:  "\u54BD",
+	0xf99f:  "\u70C8",
+	0xf9a0:  "\u88C2",
+	0xf9a1:  "\u8AAA",
+	0xf9a2:  "\u5EC9",
+	0xf9a3:  "\u5FF5",
+	0xf9a4:  "\u637B",
+	0xf9a5:  "\u6BAE",
+	0xf9a6:  "\u7C3E",
+	0xf9a7:  "\u7375",
+	0xf9a8:  "\u4EE4",
+	0xf9a9:  "\u56F9",
+	0xf9aa:  "\u5BE7",
+	0xf9ab:  "\u5DBA",
+	0xf9ac:  "\u601C",
+	0xf9ad:  "\u73B2",
+	0xf9ae:  "\u7469",
+	0xf9af:  "\u7F9A",
+	0xf9b0:  "\u8046",
+	0xf9b1:  "\u9234",
+	0xf9b2:  "\u96F6",
+	0xf9b3:  "\u9748",
+	0xf9b4:  "\u9818",
+	0xf9b5:  "\u4F8B",
+	0xf9b6:  "\u79AE",
+	0xf9b7:  "\u91B4",
+	0xf9b8:  "\u96B8",
+	0xf9b9:  "\u60E1",
+	0xf9ba:  "\u4E86",
+	0xf9bb:  "\u50DA",
+	0xf9bc:  "\u5BEE",
+	0xf9bd:  "\u5C3F",
+	0xf9be:  "\u6599",
+	0xf9bf:  "\u6A02",
+	0xf9c0:  "\u71CE",
+	0xf9c1:  "\u7642",
+	0xf9c2:  "\u84FC",
+	0xf9c3:  "\u907C",
+	0xf9c4:  "\u9F8D",
+	0xf9c5:  "\u6688",
+	0xf9c6:  "\u962E",
+	0xf9c7:  "\u5289",
+	0xf9c8:  "\u677B",
+	0xf9c9:  "\u67F3",
+	0xf9ca:  "\u6D41",
+	0xf9cb:  "\u6E9C",
+	0xf9cc:  "\u7409",
+	0xf9cd:  "\u7559",
+	0xf9ce:  "\u786B",
+	0xf9cf:  "\u7D10",
+	0xf9d0:  "\u985E",
+	0xf9d1:  "\u516D",
+	0xf9d2:  "\u622E",
+	0xf9d3:  "\u9678",
+	0xf9d4:  "\u502B",
+	0xf9d5:  "\u5D19",
+	0xf9d6:  "\u6DEA",
+	0xf9d7:  "\u8F2A",
+	0xf9d8:  "\u5F8B",
+	0xf9d9:  "\u6144",
+	0xf9da:  "\u6817",
+	0xf9db:  "\u7387",
+	0xf9dc:  "\u9686",
+	0xf9dd:  "\u5229",
+	0xf9de:  "\u540F",
+	0xf9df:  "\u5C65",
+	0xf9e0:  "\u6613",
+	0xf9e1:  "\u674E",
+	0xf9e2:  "\u68A8",
+	0xf9e3:  "\u6CE5",
+	0xf9e4:  "\u7406",
+	0xf9e5:  "\u75E2",
+	0xf9e6:  "\u7F79",
+	0xf9e7:  "\u88CF",
+	0xf9e8:  "\u88E1",
+	0xf9e9:  "\u91CC",
+	0xf9ea:  "\u96E2",
+	0xf9eb:  "\u533F",
+	0xf9ec:  "\u6EBA",
+	0xf9ed:  "\u541D",
+	0xf9ee:  "\u71D0",
+	0xf9ef:  "\u7498",
+	0xf9f0:  "\u85FA",
+	0xf9f1:  "\u96A3",
+	0xf9f2:  "\u9C57",
+	0xf9f3:  "\u9E9F",
+	0xf9f4:  "\u6797",
+	0xf9f5:  "\u6DCB",
+	0xf9f6:  "\u81E8",
+	0xf9f7:  "\u7ACB",
+	0xf9f8:  "\u7B20",
+	0xf9f9:  "\u7C92",
+	0xf9fa:  "\u72C0",
+	0xf9fb:  "\u7099",
+	0xf9fc:  "\u8B58",
+	0xf9fd:  "\u4EC0",
+	0xf9fe:  "\u8336",
+	0xf9ff:  "\u523A",
+	0xfa00:  "\u5207",
+	0xfa01:  "\u5EA6",
+	0xfa02:  "\u62D3",
+	0xfa03:  "\u7CD6",
+	0xfa04:  "\u5B85",
+	0xfa05:  "\u6D1E",
+	0xfa06:  "\u66B4",
+	0xfa07:  "\u8F3B",
+	0xfa08:  "\u884C",
+	0xfa09:  "\u964D",
+	0xfa0a:  "\u898B",
+	0xfa0b:  "\u5ED3",
+	0xfa0c:  "\u5140",
+	0xfa0d:  "\u55C0",
+	0xfa10:  "\u585A",
+	0xfa12:  "\u6674",
+	0xfa15:  "\u51DE",
+	0xfa16:  "\u732A",
+	0xfa17:  "\u76CA",
+	0xfa18:  "\u793C",
+	0xfa19:  "\u795E",
+	0xfa1a:  "\u7965",
+	0xfa1b:  "\u798F",
+	0xfa1c:  "\u9756",
+	0xfa1d:  "\u7CBE",
+	0xfa1e:  "\u7FBD",
+	0xfa20:  "\u8612",
+	0xfa22:  "\u8AF8",
+	0xfa25:  "\u9038",
+	0xfa26:  "\u90FD",
+	0xfa2a:  "\u98EF",
+	0xfa2b:  "\u98FC",
+	0xfa2c:  "\u9928",
+	0xfa2d:  "\u9DB4",
+	0xfa2e:  "\u90DE",
+	0xfa2f:  "\u96B7",
+	0xfa30:  "\u4FAE",
+	0xfa31:  "\u50E7",
+	0xfa32:  "\u514D",
+	0xfa33:  "\u52C9",
+	0xfa34:  "\u52E4",
+	0xfa35:  "\u5351",
+	0xfa36:  "\u559D",
+	0xfa37:  "\u5606",
+	0xfa38:  "\u5668",
+	0xfa39:  "\u5840",
+	0xfa3a:  "\u58A8",
+	0xfa3b:  "\u5C64",
+	0xfa3c:  "\u5C6E",
+	0xfa3d:  "\u6094",
+	0xfa3e:  "\u6168",
+	0xfa3f:  "\u618E",
+	0xfa40:  "\u61F2",
+	0xfa41:  "\u654F",
+	0xfa42:  "\u65E2",
+	0xfa43:  "\u6691",
+	0xfa44:  "\u6885",
+	0xfa45:  "\u6D77",
+	0xfa46:  "\u6E1A",
+	0xfa47:  "\u6F22",
+	0xfa48:  "\u716E",
+	0xfa49:  "\u722B",
+	0xfa4a:  "\u7422",
+	0xfa4b:  "\u7891",
+	0xfa4c:  "\u793E",
+	0xfa4d:  "\u7949",
+	0xfa4e:  "\u7948",
+	0xfa4f:  "\u7950",
+	0xfa50:  "\u7956",
+	0xfa51:  "\u795D",
+	0xfa52:  "\u798D",
+	0xfa53:  "\u798E",
+	0xfa54:  "\u7A40",
+	0xfa55:  "\u7A81",
+	0xfa56:  "\u7BC0",
+	0xfa57:  "\u7DF4",
+	0xfa58:  "\u7E09",
+	0xfa59:  "\u7E41",
+	0xfa5a:  "\u7F72",
+	0xfa5b:  "\u8005",
+	0xfa5c:  "\u81ED",
+	0xfa5d:  "\u8279",
+	0xfa5e:  "\u8279",
+	0xfa5f:  "\u8457",
+	0xfa60:  "\u8910",
+	0xfa61:  "\u8996",
+	0xfa62:  "\u8B01",
+	0xfa63:  "\u8B39",
+	0xfa64:  "\u8CD3",
+	0xfa65:  "\u8D08",
+	0xfa66:  "\u8FB6",
+	0xfa67:  "\u9038",
+	0xfa68:  "\u96E3",
+	0xfa69:  "\u97FF",
+	0xfa6a:  "\u983B",
+	0xfa6b:  "\u6075",
+	0xfa6c:  "\U000242EE",
+	0xfa6d:  "\u8218",
+	0xfa70:  "\u4E26",
+	0xfa71:  "\u51B5",
+	0xfa72:  "\u5168",
+	0xfa73:  "\u4F80",
+	0xfa74:  "\u5145",
+	0xfa75:  "\u5180",
+	0xfa76:  "\u52C7",
+	0xfa77:  "\u52FA",
+	0xfa78:  "\u559D",
+	0xfa79:  "\u5555",
+	0xfa7a:  "\u5599",
+	0xfa7b:  "\u55E2",
+	0xfa7c:  "\u585A",
+	0xfa7d:  "\u58B3",
+	0xfa7e:  "\u5944",
+	0xfa7f:  "\u5954",
+	0xfa80:  "\u5A62",
+	0xfa81:  "\u5B28",
+	0xfa82:  "\u5ED2",
+	0xfa83:  "\u5ED9",
+	0xfa84:  "\u5F69",
+	0xfa85:  "\u5FAD",
+	0xfa86:  "\u60D8",
+	0xfa87:  "\u614E",
+	0xfa88:  "\u6108",
+	0xfa89:  "\u618E",
+	0xfa8a:  "\u6160",
+	0xfa8b:  "\u61F2",
+	0xfa8c:  "\u6234",
+	0xfa8d:  "\u63C4",
+	0xfa8e:  "\u641C",
+	0xfa8f:  "\u6452",
+	0xfa90:  "\u6556",
+	0xfa91:  "\u6674",
+	0xfa92:  "\u6717",
+	0xfa93:  "\u671B",
+	0xfa94:  "\u6756",
+	0xfa95:  "\u6B79",
+	0xfa96:  "\u6BBA",
+	0xfa97:  "\u6D41",
+	0xfa98:  "\u6EDB",
+	0xfa99:  "\u6ECB",
+	0xfa9a:  "\u6F22",
+	0xfa9b:  "\u701E",
+	0xfa9c:  "\u716E",
+	0xfa9d:  "\u77A7",
+	0xfa9e:  "\u7235",
+	0xfa9f:  "\u72AF",
+	0xfaa0:  "\u732A",
+	0xfaa1:  "\u7471",
+	0xfaa2:  "\u7506",
+	0xfaa3:  "\u753B",
+	0xfaa4:  "\u761D",
+	0xfaa5:  "\u761F",
+	0xfaa6:  "\u76CA",
+	0xfaa7:  "\u76DB",
+	0xfaa8:  "\u76F4",
+	0xfaa9:  "\u774A",
+	0xfaaa:  "\u7740",
+	0xfaab:  "\u78CC",
+	0xfaac:  "\u7AB1",
+	0xfaad:  "\u7BC0",
+	0xfaae:  "\u7C7B",
+	0xfaaf:  "\u7D5B",
+	0xfab0:  "\u7DF4",
+	0xfab1:  "\u7F3E",
+	0xfab2:  "\u8005",
+	0xfab3:  "\u8352",
+	0xfab4:  "\u83EF",
+	0xfab5:  "\u8779",
+	0xfab6:  "\u8941",
+	0xfab7:  "\u8986",
+	0xfab8:  "\u8996",
+	0xfab9:  "\u8ABF",
+	0xfaba:  "\u8AF8",
+	0xfabb:  "\u8ACB",
+	0xfabc:  "\u8B01",
+	0xfabd:  "\u8AFE",
+	0xfabe:  "\u8AED",
+	0xfabf:  "\u8B39",
+	0xfac0:  "\u8B8A",
+	0xfac1:  "\u8D08",
+	0xfac2:  "\u8F38",
+	0xfac3:  "\u9072",
+	0xfac4:  "\u9199",
+	0xfac5:  "\u9276",
+	0xfac6:  "\u967C",
+	0xfac7:  "\u96E3",
+	0xfac8:  "\u9756",
+	0xfac9:  "\u97DB",
+	0xfaca:  "\u97FF",
+	0xfacb:  "\u980B",
+	0xfacc:  "\u983B",
+	0xfacd:  "\u9B12",
+	0xface:  "\u9F9C",
+	0xfacf:  "\U0002284A",
+	0xfad0:  "\U00022844",
+	0xfad1:  "\U000233D5",
+	0xfad2:  "\u3B9D",
+	0xfad3:  "\u4018",
+	0xfad4:  "\u4039",
+	0xfad5:  "\U00025249",
+	0xfad6:  "\U00025CD0",
+	0xfad7:  "\U00027ED3",
+	0xfad8:  "\u9F43",
+	0xfad9:  "\u9F8E",
+	0xfb1d:  "\u05D9\u05B4",
+	0xfb1f:  "\u05F2\u05B7",
+	0xfb2a:  "\u05E9\u05C1",
+	0xfb2b:  "\u05E9\u05C2",
+	0xfb2c:  "\u05E9\u05BC\u05C1",
+	0xfb2d:  "\u05E9\u05BC\u05C2",
+	0xfb2e:  "\u05D0\u05B7",
+	0xfb2f:  "\u05D0\u05B8",
+	0xfb30:  "\u05D0\u05BC",
+	0xfb31:  "\u05D1\u05BC",
+	0xfb32:  "\u05D2\u05BC",
+	0xfb33:  "\u05D3\u05BC",
+	0xfb34:  "\u05D4\u05BC",
+	0xfb35:  "\u05D5\u05BC",
+	0xfb36:  "\u05D6\u05BC",
+	0xfb38:  "\u05D8\u05BC",
+	0xfb39:  "\u05D9\u05BC",
+	0xfb3a:  "\u05DA\u05BC",
+	0xfb3b:  "\u05DB\u05BC",
+	0xfb3c:  "\u05DC\u05BC",
+	0xfb3e:  "\u05DE\u05BC",
+	0xfb40:  "\u05E0\u05BC",
+	0xfb41:  "\u05E1\u05BC",
+	0xfb43:  "\u05E3\u05BC",
+	0xfb44:  "\u05E4\u05BC",
+	0xfb46:  "\u05E6\u05BC",
+	0xfb47:  "\u05E7\u05BC",
+	0xfb48:  "\u05E8\u05BC",
+	0xfb49:  "\u05E9\u05BC",
+	0xfb4a:  "\u05EA\u05BC",
+	0xfb4b:  "\u05D5\u05B9",
+	0xfb4c:  "\u05D1\u05BF",
+	0xfb4d:  "\u05DB\u05BF",
+	0xfb4e:  "\u05E4\u05BF",
+	0x1109a: "\U00011099\U000110BA",
+	0x1109c: "\U0001109B\U000110BA",
+	0x110ab: "\U000110A5\U000110BA",
+	0x1112e: "\U00011131\U00011127",
+	0x1112f: "\U00011132\U00011127",
+	0x1134b: "\U00011347\U0001133E",
+	0x1134c: "\U00011347\U00011357",
+	0x114bb: "\U000114B9\U000114BA",
+	0x114bc: "\U000114B9\U000114B0",
+	0x114be: "\U000114B9\U000114BD",
+	0x115ba: "\U000115B8\U000115AF",
+	0x115bb: "\U000115B9\U000115AF",
+	0x11938: "\U00011935\U00011930",
+	0x1d15e: "\U0001D157\U0001D165",
+	0x1d15f: "\U0001D158\U0001D165",
+	0x1d160: "\U0001D158\U0001D165\U0001D16E",
+	0x1d161: "\U0001D158\U0001D165\U0001D16F",
+	0x1d162: "\U0001D158\U0001D165\U0001D170",
+	0x1d163: "\U0001D158\U0001D165\U0001D171",
+	0x1d164: "\U0001D158\U0001D165\U0001D172",
+	0x1d1bb: "\U0001D1B9\U0001D165",
+	0x1d1bc: "\U0001D1BA\U0001D165",
+	0x1d1bd: "\U0001D1B9\U0001D165\U0001D16E",
+	0x1d1be: "\U0001D1BA\U0001D165\U0001D16E",
+	0x1d1bf: "\U0001D1B9\U0001D165\U0001D16F",
+	0x1d1c0: "\U0001D1BA\U0001D165\U0001D16F",
+	0x2f800: "\u4E3D",
+	0x2f801: "\u4E38",
+	0x2f802: "\u4E41",
+	0x2f803: "\U00020122",
+	0x2f804: "\u4F60",
+	0x2f805: "\u4FAE",
+	0x2f806: "\u4FBB",
+	0x2f807: "\u5002",
+	0x2f808: "\u507A",
+	0x2f809: "\u5099",
+	0x2f80a: "\u50E7",
+	0x2f80b: "\u50CF",
+	0x2f80c: "\u349E",
+	0x2f80d: "\U0002063A",
+	0x2f80e: "\u514D",
+	0x2f80f: "\u5154",
+	0x2f810: "\u5164",
+	0x2f811: "\u5177",
+	0x2f812: "\U0002051C",
+	0x2f813: "\u34B9",
+	0x2f814: "\u5167",
+	0x2f815: "\u518D",
+	0x2f816: "\U0002054B",
+	0x2f817: "\u5197",
+	0x2f818: "\u51A4",
+	0x2f819: "\u4ECC",
+	0x2f81a: "\u51AC",
+	0x2f81b: "\u51B5",
+	0x2f81c: "\U000291DF",
+	0x2f81d: "\u51F5",
+	0x2f81e: "\u5203",
+	0x2f81f: "\u34DF",
+	0x2f820: "\u523B",
+	0x2f821: "\u5246",
+	0x2f822: "\u5272",
+	0x2f823: "\u5277",
+	0x2f824: "\u3515",
+	0x2f825: "\u52C7",
+	0x2f826: "\u52C9",
+	0x2f827: "\u52E4",
+	0x2f828: "\u52FA",
+	0x2f829: "\u5305",
+	0x2f82a: "\u5306",
+	0x2f82b: "\u5317",
+	0x2f82c: "\u5349",
+	0x2f82d: "\u5351",
+	0x2f82e: "\u535A",
+	0x2f82f: "\u5373",
+	0x2f830: "\u537D",
+	0x2f831: "\u537F",
+	0x2f832: "\u537F",
+	0x2f833: "\u537F",
+	0x2f834: "\U00020A2C",
+	0x2f835: "\u7070",
+	0x2f836: "\u53CA",
+	0x2f837: "\u53DF",
+	0x2f838: "\U00020B63",
+	0x2f839: "\u53EB",
+	0x2f83a: "\u53F1",
+	0x2f83b: "\u5406",
+	0x2f83c: "\u549E",
+	0x2f83d: "\u5438",
+	0x2f83e: "\u5448",
+	0x2f83f: "\u5468",
+	0x2f840: "\u54A2",
+	0x2f841: "\u54F6",
+	0x2f842: "\u5510",
+	0x2f843: "\u5553",
+	0x2f844: "\u5563",
+	0x2f845: "\u5584",
+	0x2f846: "\u5584",
+	0x2f847: "\u5599",
+	0x2f848: "\u55AB",
+	0x2f849: "\u55B3",
+	0x2f84a: "\u55C2",
+	0x2f84b: "\u5716",
+	0x2f84c: "\u5606",
+	0x2f84d: "\u5717",
+	0x2f84e: "\u5651",
+	0x2f84f: "\u5674",
+	0x2f850: "\u5207",
+	0x2f851: "\u58EE",
+	0x2f852: "\u57CE",
+	0x2f853: "\u57F4",
+	0x2f854: "\u580D",
+	0x2f855: "\u578B",
+	0x2f856: "\u5832",
+	0x2f857: "\u5831",
+	0x2f858: "\u58AC",
+	0x2f859: "\U000214E4",
+	0x2f85a: "\u58F2",
+	0x2f85b: "\u58F7",
+	0x2f85c: "\u5906",
+	0x2f85d: "\u591A",
+	0x2f85e: "\u5922",
+	0x2f85f: "\u5962",
+	0x2f860: "\U000216A8",
+	0x2f861: "\U000216EA",
+	0x2f862: "\u59EC",
+	0x2f863: "\u5A1B",
+	0x2f864: "\u5A27",
+	0x2f865: "\u59D8",
+	0x2f866: "\u5A66",
+	0x2f867: "\u36EE",
+	0x2f868: "\u36FC",
+	0x2f869: "\u5B08",
+	0x2f86a: "\u5B3E",
+	0x2f86b: "\u5B3E",
+	0x2f86c: "\U000219C8",
+	0x2f86d: "\u5BC3",
+	0x2f86e: "\u5BD8",
+	0x2f86f: "\u5BE7",
+	0x2f870: "\u5BF3",
+	0x2f871: "\U00021B18",
+	0x2f872: "\u5BFF",
+	0x2f873: "\u5C06",
+	0x2f874: "\u5F53",
+	0x2f875: "\u5C22",
+	0x2f876: "\u3781",
+	0x2f877: "\u5C60",
+	0x2f878: "\u5C6E",
+	0x2f879: "\u5CC0",
+	0x2f87a: "\u5C8D",
+	0x2f87b: "\U00021DE4",
+	0x2f87c: "\u5D43",
+	0x2f87d: "\U00021DE6",
+	0x2f87e: "\u5D6E",
+	0x2f87f: "\u5D6B",
+	0x2f880: "\u5D7C",
+	0x2f881: "\u5DE1",
+	0x2f882: "\u5DE2",
+	0x2f883: "\u382F",
+	0x2f884: "\u5DFD",
+	0x2f885: "\u5E28",
+	0x2f886: "\u5E3D",
+	0x2f887: "\u5E69",
+	0x2f888: "\u3862",
+	0x2f889: "\U00022183",
+	0x2f88a: "\u387C",
+	0x2f88b: "\u5EB0",
+	0x2f88c: "\u5EB3",
+	0x2f88d: "\u5EB6",
+	0x2f88e: "\u5ECA",
+	0x2f88f: "\U0002A392",
+	0x2f890: "\u5EFE",
+	0x2f891: "\U00022331",
+	0x2f892: "\U00022331",
+	0x2f893: "\u8201",
+	0x2f894: "\u5F22",
+	0x2f895: "\u5F22",
+	0x2f896: "\u38C7",
+	0x2f897: "\U000232B8",
+	0x2f898: "\U000261DA",
+	0x2f899: "\u5F62",
+	0x2f89a: "\u5F6B",
+	0x2f89b: "\u38E3",
+	0x2f89c: "\u5F9A",
+	0x2f89d: "\u5FCD",
+	0x2f89e: "\u5FD7",
+	0x2f89f: "\u5FF9",
+	0x2f8a0: "\u6081",
+	0x2f8a1: "\u393A",
+	0x2f8a2: "\u391C",
+	0x2f8a3: "\u6094",
+	0x2f8a4: "\U000226D4",
+	0x2f8a5: "\u60C7",
+	0x2f8a6: "\u6148",
+	0x2f8a7: "\u614C",
+	0x2f8a8: "\u614E",
+	0x2f8a9: "\u614C",
+	0x2f8aa: "\u617A",
+	0x2f8ab: "\u618E",
+	0x2f8ac: "\u61B2",
+	0x2f8ad: "\u61A4",
+	0x2f8ae: "\u61AF",
+	0x2f8af: "\u61DE",
+	0x2f8b0: "\u61F2",
+	0x2f8b1: "\u61F6",
+	0x2f8b2: "\u6210",
+	0x2f8b3: "\u621B",
+	0x2f8b4: "\u625D",
+	0x2f8b5: "\u62B1",
+	0x2f8b6: "\u62D4",
+	0x2f8b7: "\u6350",
+	0x2f8b8: "\U00022B0C",
+	0x2f8b9: "\u633D",
+	0x2f8ba: "\u62FC",
+	0x2f8bb: "\u6368",
+	0x2f8bc: "\u6383",
+	0x2f8bd: "\u63E4",
+	0x2f8be: "\U00022BF1",
+	0x2f8bf: "\u6422",
+	0x2f8c0: "\u63C5",
+	0x2f8c1: "\u63A9",
+	0x2f8c2: "\u3A2E",
+	0x2f8c3: "\u6469",
+	0x2f8c4: "\u647E",
+	0x2f8c5: "\u649D",
+	0x2f8c6: "\u6477",
+	0x2f8c7: "\u3A6C",
+	0x2f8c8: "\u654F",
+	0x2f8c9: "\u656C",
+	0x2f8ca: "\U0002300A",
+	0x2f8cb: "\u65E3",
+	0x2f8cc: "\u66F8",
+	0x2f8cd: "\u6649",
+	0x2f8ce: "\u3B19",
+	0x2f8cf: "\u6691",
+	0x2f8d0: "\u3B08",
+	0x2f8d1: "\u3AE4",
+	0x2f8d2: "\u5192",
+	0x2f8d3: "\u5195",
+	0x2f8d4: "\u6700",
+	0x2f8d5: "\u669C",
+	0x2f8d6: "\u80AD",
+	0x2f8d7: "\u43D9",
+	0x2f8d8: "\u6717",
+	0x2f8d9: "\u671B",
+	0x2f8da: "\u6721",
+	0x2f8db: "\u675E",
+	0x2f8dc: "\u6753",
+	0x2f8dd: "\U000233C3",
+	0x2f8de: "\u3B49",
+	0x2f8df: "\u67FA",
+	0x2f8e0: "\u6785",
+	0x2f8e1: "\u6852",
+	0x2f8e2: "\u6885",
+	0x2f8e3: "\U0002346D",
+	0x2f8e4: "\u688E",
+	0x2f8e5: "\u681F",
+	0x2f8e6: "\u6914",
+	0x2f8e7: "\u3B9D",
+	0x2f8e8: "\u6942",
+	0x2f8e9: "\u69A3",
+	0x2f8ea: "\u69EA",
+	0x2f8eb: "\u6AA8",
+	0x2f8ec: "\U000236A3",
+	0x2f8ed: "\u6ADB",
+	0x2f8ee: "\u3C18",
+	0x2f8ef: "\u6B21",
+	0x2f8f0: "\U000238A7",
+	0x2f8f1: "\u6B54",
+	0x2f8f2: "\u3C4E",
+	0x2f8f3: "\u6B72",
+	0x2f8f4: "\u6B9F",
+	0x2f8f5: "\u6BBA",
+	0x2f8f6: "\u6BBB",
+	0x2f8f7: "\U00023A8D",
+	0x2f8f8: "\U00021D0B",
+	0x2f8f9: "\U00023AFA",
+	0x2f8fa: "\u6C4E",
+	0x2f8fb: "\U00023CBC",
+	0x2f8fc: "\u6CBF",
+	0x2f8fd: "\u6CCD",
+	0x2f8fe: "\u6C67",
+	0x2f8ff: "\u6D16",
+	0x2f900: "\u6D3E",
+	0x2f901: "\u6D77",
+	0x2f902: "\u6D41",
+	0x2f903: "\u6D69",
+	0x2f904: "\u6D78",
+	0x2f905: "\u6D85",
+	0x2f906: "\U00023D1E",
+	0x2f907: "\u6D34",
+	0x2f908: "\u6E2F",
+	0x2f909: "\u6E6E",
+	0x2f90a: "\u3D33",
+	0x2f90b: "\u6ECB",
+	0x2f90c: "\u6EC7",
+	0x2f90d: "\U00023ED1",
+	0x2f90e: "\u6DF9",
+	0x2f90f: "\u6F6E",
+	0x2f910: "\U00023F5E",
+	0x2f911: "\U00023F8E",
+	0x2f912: "\u6FC6",
+	0x2f913: "\u7039",
+	0x2f914: "\u701E",
+	0x2f915: "\u701B",
+	0x2f916: "\u3D96",
+	0x2f917: "\u704A",
+	0x2f918: "\u707D",
+	0x2f919: "\u7077",
+	0x2f91a: "\u70AD",
+	0x2f91b: "\U00020525",
+	0x2f91c: "\u7145",
+	0x2f91d: "\U00024263",
+	0x2f91e: "\u719C",
+	0x2f91f: "\U000243AB",
+	0x2f920: "\u7228",
+	0x2f921: "\u7235",
+	0x2f922: "\u7250",
+	0x2f923: "\U00024608",
+	0x2f924: "\u7280",
+	0x2f925: "\u7295",
+	0x2f926: "\U00024735",
+	0x2f927: "\U00024814",
+	0x2f928: "\u737A",
+	0x2f929: "\u738B",
+	0x2f92a: "\u3EAC",
+	0x2f92b: "\u73A5",
+	0x2f92c: "\u3EB8",
+	0x2f92d: "\u3EB8",
+	0x2f92e: "\u7447",
+	0x2f92f: "\u745C",
+	0x2f930: "\u7471",
+	0x2f931: "\u7485",
+	0x2f932: "\u74CA",
+	0x2f933: "\u3F1B",
+	0x2f934: "\u7524",
+	0x2f935: "\U00024C36",
+	0x2f936: "\u753E",
+	0x2f937: "\U00024C92",
+	0x2f938: "\u7570",
+	0x2f939: "\U0002219F",
+	0x2f93a: "\u7610",
+	0x2f93b: "\U00024FA1",
+	0x2f93c: "\U00024FB8",
+	0x2f93d: "\U00025044",
+	0x2f93e: "\u3FFC",
+	0x2f93f: "\u4008",
+	0x2f940: "\u76F4",
+	0x2f941: "\U000250F3",
+	0x2f942: "\U000250F2",
+	0x2f943: "\U00025119",
+	0x2f944: "\U00025133",
+	0x2f945: "\u771E",
+	0x2f946: "\u771F",
+	0x2f947: "\u771F",
+	0x2f948: "\u774A",
+	0x2f949: "\u4039",
+	0x2f94a: "\u778B",
+	0x2f94b: "\u4046",
+	0x2f94c: "\u4096",
+	0x2f94d: "\U0002541D",
+	0x2f94e: "\u784E",
+	0x2f94f: "\u788C",
+	0x2f950: "\u78CC",
+	0x2f951: "\u40E3",
+	0x2f952: "\U00025626",
+	0x2f953: "\u7956",
+	0x2f954: "\U0002569A",
+	0x2f955: "\U000256C5",
+	0x2f956: "\u798F",
+	0x2f957: "\u79EB",
+	0x2f958: "\u412F",
+	0x2f959: "\u7A40",
+	0x2f95a: "\u7A4A",
+	0x2f95b: "\u7A4F",
+	0x2f95c: "\U0002597C",
+	0x2f95d: "\U00025AA7",
+	0x2f95e: "\U00025AA7",
+	0x2f95f: "\u7AEE",
+	0x2f960: "\u4202",
+	0x2f961: "\U00025BAB",
+	0x2f962: "\u7BC6",
+	0x2f963: "\u7BC9",
+	0x2f964: "\u4227",
+	0x2f965: "\U00025C80",
+	0x2f966: "\u7CD2",
+	0x2f967: "\u42A0",
+	0x2f968: "\u7CE8",
+	0x2f969: "\u7CE3",
+	0x2f96a: "\u7D00",
+	0x2f96b: "\U00025F86",
+	0x2f96c: "\u7D63",
+	0x2f96d: "\u4301",
+	0x2f96e: "\u7DC7",
+	0x2f96f: "\u7E02",
+	0x2f970: "\u7E45",
+	0x2f971: "\u4334",
+	0x2f972: "\U00026228",
+	0x2f973: "\U00026247",
+	0x2f974: "\u4359",
+	0x2f975: "\U000262D9",
+	0x2f976: "\u7F7A",
+	0x2f977: "\U0002633E",
+	0x2f978: "\u7F95",
+	0x2f979: "\u7FFA",
+	0x2f97a: "\u8005",
+	0x2f97b: "\U000264DA",
+	0x2f97c: "\U00026523",
+	0x2f97d: "\u8060",
+	0x2f97e: "\U000265A8",
+	0x2f97f: "\u8070",
+	0x2f980: "\U0002335F",
+	0x2f981: "\u43D5",
+	0x2f982: "\u80B2",
+	0x2f983: "\u8103",
+	0x2f984: "\u440B",
+	0x2f985: "\u813E",
+	0x2f986: "\u5AB5",
+	0x2f987: "\U000267A7",
+	0x2f988: "\U000267B5",
+	0x2f989: "\U00023393",
+	0x2f98a: "\U0002339C",
+	0x2f98b: "\u8201",
+	0x2f98c: "\u8204",
+	0x2f98d: "\u8F9E",
+	0x2f98e: "\u446B",
+	0x2f98f: "\u8291",
+	0x2f990: "\u828B",
+	0x2f991: "\u829D",
+	0x2f992: "\u52B3",
+	0x2f993: "\u82B1",
+	0x2f994: "\u82B3",
+	0x2f995: "\u82BD",
+	0x2f996: "\u82E6",
+	0x2f997: "\U00026B3C",
+	0x2f998: "\u82E5",
+	0x2f999: "\u831D",
+	0x2f99a: "\u8363",
+	0x2f99b: "\u83AD",
+	0x2f99c: "\u8323",
+	0x2f99d: "\u83BD",
+	0x2f99e: "\u83E7",
+	0x2f99f: "\u8457",
+	0x2f9a0: "\u8353",
+	0x2f9a1: "\u83CA",
+	0x2f9a2: "\u83CC",
+	0x2f9a3: "\u83DC",
+	0x2f9a4: "\U00026C36",
+	0x2f9a5: "\U00026D6B",
+	0x2f9a6: "\U00026CD5",
+	0x2f9a7: "\u452B",
+	0x2f9a8: "\u84F1",
+	0x2f9a9: "\u84F3",
+	0x2f9aa: "\u8516",
+	0x2f9ab: "\U000273CA",
+	0x2f9ac: "\u8564",
+	0x2f9ad: "\U00026F2C",
+	0x2f9ae: "\u455D",
+	0x2f9af: "\u4561",
+	0x2f9b0: "\U00026FB1",
+	0x2f9b1: "\U000270D2",
+	0x2f9b2: "\u456B",
+	0x2f9b3: "\u8650",
+	0x2f9b4: "\u865C",
+	0x2f9b5: "\u8667",
+	0x2f9b6: "\u8669",
+	0x2f9b7: "\u86A9",
+	0x2f9b8: "\u8688",
+	0x2f9b9: "\u870E",
+	0x2f9ba: "\u86E2",
+	0x2f9bb: "\u8779",
+	0x2f9bc: "\u8728",
+	0x2f9bd: "\u876B",
+	0x2f9be: "\u8786",
+	0x2f9bf: "\u45D7",
+	0x2f9c0: "\u87E1",
+	0x2f9c1: "\u8801",
+	0x2f9c2: "\u45F9",
+	0x2f9c3: "\u8860",
+	0x2f9c4: "\u8863",
+	0x2f9c5: "\U00027667",
+	0x2f9c6: "\u88D7",
+	0x2f9c7: "\u88DE",
+	0x2f9c8: "\u4635",
+	0x2f9c9: "\u88FA",
+	0x2f9ca: "\u34BB",
+	0x2f9cb: "\U000278AE",
+	0x2f9cc: "\U00027966",
+	0x2f9cd: "\u46BE",
+	0x2f9ce: "\u46C7",
+	0x2f9cf: "\u8AA0",
+	0x2f9d0: "\u8AED",
+	0x2f9d1: "\u8B8A",
+	0x2f9d2: "\u8C55",
+	0x2f9d3: "\U00027CA8",
+	0x2f9d4: "\u8CAB",
+	0x2f9d5: "\u8CC1",
+	0x2f9d6: "\u8D1B",
+	0x2f9d7: "\u8D77",
+	0x2f9d8: "\U00027F2F",
+	0x2f9d9: "\U00020804",
+	0x2f9da: "\u8DCB",
+	0x2f9db: "\u8DBC",
+	0x2f9dc: "\u8DF0",
+	0x2f9dd: "\U000208DE",
+	0x2f9de: "\u8ED4",
+	0x2f9df: "\u8F38",
+	0x2f9e0: "\U000285D2",
+	0x2f9e1: "\U000285ED",
+	0x2f9e2: "\u9094",
+	0x2f9e3: "\u90F1",
+	0x2f9e4: "\u9111",
+	0x2f9e5: "\U0002872E",
+	0x2f9e6: "\u911B",
+	0x2f9e7: "\u9238",
+	0x2f9e8: "\u92D7",
+	0x2f9e9: "\u92D8",
+	0x2f9ea: "\u927C",
+	0x2f9eb: "\u93F9",
+	0x2f9ec: "\u9415",
+	0x2f9ed: "\U00028BFA",
+	0x2f9ee: "\u958B",
+	0x2f9ef: "\u4995",
+	0x2f9f0: "\u95B7",
+	0x2f9f1: "\U00028D77",
+	0x2f9f2: "\u49E6",
+	0x2f9f3: "\u96C3",
+	0x2f9f4: "\u5DB2",
+	0x2f9f5: "\u9723",
+	0x2f9f6: "\U00029145",
+	0x2f9f7: "\U0002921A",
+	0x2f9f8: "\u4A6E",
+	0x2f9f9: "\u4A76",
+	0x2f9fa: "\u97E0",
+	0x2f9fb: "\U0002940A",
+	0x2f9fc: "\u4AB2",
+	0x2f9fd: "\U00029496",
+	0x2f9fe: "\u980B",
+	0x2f9ff: "\u980B",
+	0x2fa00: "\u9829",
+	0x2fa01: "\U000295B6",
+	0x2fa02: "\u98E2",
+	0x2fa03: "\u4B33",
+	0x2fa04: "\u9929",
+	0x2fa05: "\u99A7",
+	0x2fa06: "\u99C2",
+	0x2fa07: "\u99FE",
+	0x2fa08: "\u4BCE",
+	0x2fa09: "\U00029B30",
+	0x2fa0a: "\u9B12",
+	0x2fa0b: "\u9C40",
+	0x2fa0c: "\u9CFD",
+	0x2fa0d: "\u4CCE",
+	0x2fa0e: "\u4CED",
+	0x2fa0f: "\u9D67",
+	0x2fa10: "\U0002A0CE",
+	0x2fa11: "\u4CF8",
+	0x2fa12: "\U0002A105",
+	0x2fa13: "\U0002A20E",
+	0x2fa14: "\U0002A291",
+	0x2fa15: "\u9EBB",
+	0x2fa16: "\u4D56",
+	0x2fa17: "\u9EF9",
+	0x2fa18: "\u9EFE",
+	0x2fa19: "\u9F05",
+	0x2fa1a: "\u9F0F",
+	0x2fa1b: "\u9F16",
+	0x2fa1c: "\u9F3B",
+	0x2fa1d: "\U0002A600",
+}
+
+// composition maps a starter and the rune that follows it to their primary composite, Hangul syllables aside
+var composition = map[[2]rune]rune{
+	{0x003c, 0x0338}:   0x226e,
+	{0x003d, 0x0338}:   0x2260,
+	{0x003e, 0x0338}:   0x226f,
+	{0x0041, 0x0300}:   0x00c0,
+	{0x0041, 0x0301}:   0x00c1,
+	{0x0041, 0x0302}:   0x00c2,
+	{0x0041, 0x0303}:   0x00c3,
+	{0x0041, 0x0304}:   0x0100,
+	{0x0041, 0x0306}:   0x0102,
+	{0x0041, 0x0307}:   0x0226,
+	{0x0041, 0x0308}:   0x00c4,
+	{0x0041, 0x0309}:   0x1ea2,
+	{0x0041, 0x030a}:   0x00c5,
+	{0x0041, 0x030c}:   0x01cd,
+	{0x0041, 0x030f}:   0x0200,
+	{0x0041, 0x0311}:   0x0202,
+	{0x0041, 0x0323}:   0x1ea0,
+	{0x0041, 0x0325}:   0x1e00,
+	{0x0041, 0x0328}:   0x0104,
+	{0x0042, 0x0307}:   0x1e02,
+	{0x0042, 0x0323}:   0x1e04,
+	{0x0042, 0x0331}:   0x1e06,
+	{0x0043, 0x0301}:   0x0106,
+	{0x0043, 0x0302}:   0x0108,
+	{0x0043, 0x0307}:   0x010a,
+	{0x0043, 0x030c}:   0x010c,
+	{0x0043, 0x0327}:   0x00c7,
+	{0x0044, 0x0307}:   0x1e0a,
+	{0x0044, 0x030c}:   0x010e,
+	{0x0044, 0x0323}:   0x1e0c,
+	{0x0044, 0x0327}:   0x1e10,
+	{0x0044, 0x032d}:   0x1e12,
+	{0x0044, 0x0331}:   0x1e0e,
+	{0x0045, 0x0300}:   0x00c8,
+	{0x0045, 0x0301}:   0x00c9,
+	{0x0045, 0x0302}:   0x00ca,
+	{0x0045, 0x0303}:   0x1ebc,
+	{0x0045, 0x0304}:   0x0112,
+	{0x0045, 0x0306}:   0x0114,
+	{0x0045, 0x0307}:   0x0116,
+	{0x0045, 0x0308}:   0x00cb,
+	{0x0045, 0x0309}:   0x1eba,
+	{0x0045, 0x030c}:   0x011a,
+	{0x0045, 0x030f}:   0x0204,
+	{0x0045, 0x0311}:   0x0206,
+	{0x0045, 0x0323}:   0x1eb8,
+	{0x0045, 0x0327}:   0x0228,
+	{0x0045, 0x0328}:   0x0118,
+	{0x0045, 0x032d}:   0x1e18,
+	{0x0045, 0x0330}:   0x1e1a,
+	{0x0046, 0x0307}:   0x1e1e,
+	{0x0047, 0x0301}:   0x01f4,
+	{0x0047, 0x0302}:   0x011c,
+	{0x0047, 0x0304}:   0x1e20,
+	{0x0047, 0x0306}:   0x011e,
+	{0x0047, 0x0307}:   0x0120,
+	{0x0047, 0x030c}:   0x01e6,
+	{0x0047, 0x0327}:   0x0122,
+	{0x0048, 0x0302}:   0x0124,
+	{0x0048, 0x0307}:   0x1e22,
+	{0x0048, 0x0308}:   0x1e26,
+	{0x0048, 0x030c}:   0x021e,
+	{0x0048, 0x0323}:   0x1e24,
+	{0x0048, 0x0327}:   0x1e28,
+	{0x0048, 0x032e}:   0x1e2a,
+	{0x0049, 0x0300}:   0x00cc,
+	{0x0049, 0x0301}:   0x00cd,
+	{0x0049, 0x0302}:   0x00ce,
+	{0x0049, 0x0303}:   0x0128,
+	{0x0049, 0x0304}:   0x012a,
+	{0x0049, 0x0306}:   0x012c,
+	{0x0049, 0x0307}:   0x0130,
+	{0x0049, 0x0308}:   0x00cf,
+	{0x0049, 0x0309}:   0x1ec8,
+	{0x0049, 0x030c}:   0x01cf,
+	{0x0049, 0x030f}:   0x0208,
+	{0x0049, 0x0311}:   0x020a,
+	{0x0049, 0x0323}:   0x1eca,
+	{0x0049, 0x0328}:   0x012e,
+	{0x0049, 0x0330}:   0x1e2c,
+	{0x004a, 0x0302}:   0x0134,
+	{0x004b, 0x0301}:   0x1e30,
+	{0x004b, 0x030c}:   0x01e8,
+	{0x004b, 0x0323}:   0x1e32,
+	{0x004b, 0x0327}:   0x0136,
+	{0x004b, 0x0331}:   0x1e34,
+	{0x004c, 0x0301}:   0x0139,
+	{0x004c, 0x030c}:   0x013d,
+	{0x004c, 0x0323}:   0x1e36,
+	{0x004c, 0x0327}:   0x013b,
+	{0x004c, 0x032d}:   0x1e3c,
+	{0x004c, 0x0331}:   0x1e3a,
+	{0x004d, 0x0301}:   0x1e3e,
+	{0x004d, 0x0307}:   0x1e40,
+	{0x004d, 0x0323}:   0x1e42,
+	{0x004e, 0x0300}:   0x01f8,
+	{0x004e, 0x0301}:   0x0143,
+	{0x004e, 0x0303}:   0x00d1,
+	{0x004e, 0x0307}:   0x1e44,
+	{0x004e, 0x030c}:   0x0147,
+	{0x004e, 0x0323}:   0x1e46,
+	{0x004e, 0x0327}:   0x0145,
+	{0x004e, 0x032d}:   0x1e4a,
+	{0x004e, 0x0331}:   0x1e48,
+	{0x004f, 0x0300}:   0x00d2,
+	{0x004f, 0x0301}:   0x00d3,
+	{0x004f, 0x0302}:   0x00d4,
+	{0x004f, 0x0303}:   0x00d5,
+	{0x004f, 0x0304}:   0x014c,
+	{0x004f, 0x0306}:   0x014e,
+	{0x004f, 0x0307}:   0x022e,
+	{0x004f, 0x0308}:   0x00d6,
+	{0x004f, 0x0309}:   0x1ece,
+	{0x004f, 0x030b}:   0x0150,
+	{0x004f, 0x030c}:   0x01d1,
+	{0x004f, 0x030f}:   0x020c,
+	{0x004f, 0x0311}:   0x020e,
+	{0x004f, 0x031b}:   0x01a0,
+	{0x004f, 0x0323}:   0x1ecc,
+	{0x004f, 0x0328}:   0x01ea,
+	{0x0050, 0x0301}:   0x1e54,
+	{0x0050, 0x0307}:   0x1e56,
+	{0x0052, 0x0301}:   0x0154,
+	{0x0052, 0x0307}:   0x1e58,
+	{0x0052, 0x030c}:   0x0158,
+	{0x0052, 0x030f}:   0x0210,
+	{0x0052, 0x0311}:   0x0212,
+	{0x0052, 0x0323}:   0x1e5a,
+	{0x0052, 0x0327}:   0x0156,
+	{0x0052, 0x0331}:   0x1e5e,
+	{0x0053, 0x0301}:   0x015a,
+	{0x0053, 0x0302}:   0x015c,
+	{0x0053, 0x0307}:   0x1e60,
+	{0x0053, 0x030c}:   0x0160,
+	{0x0053, 0x0323}:   0x1e62,
+	{0x0053, 0x0326}:   0x0218,
+	{0x0053, 0x0327}:   0x015e,
+	{0x0054, 0x0307}:   0x1e6a,
+	{0x0054, 0x030c}:   0x0164,
+	{0x0054, 0x0323}:   0x1e6c,
+	{0x0054, 0x0326}:   0x021a,
+	{0x0054, 0x0327}:   0x0162,
+	{0x0054, 0x032d}:   0x1e70,
+	{0x0054, 0x0331}:   0x1e6e,
+	{0x0055, 0x0300}:   0x00d9,
+	{0x0055, 0x0301}:   0x00da,
+	{0x0055, 0x0302}:   0x00db,
+	{0x0055, 0x0303}:   0x0168,
+	{0x0055, 0x0304}:   0x016a,
+	{0x0055, 0x0306}:   0x016c,
+	{0x0055, 0x0308}:   0x00dc,
+	{0x0055, 0x0309}:   0x1ee6,
+	{0x0055, 0x030a}:   0x016e,
+	{0x0055, 0x030b}:   0x0170,
+	{0x0055, 0x030c}:   0x01d3,
+	{0x0055, 0x030f}:   0x0214,
+	{0x0055, 0x0311}:   0x0216,
+	{0x0055, 0x031b}:   0x01af,
+	{0x0055, 0x0323}:   0x1ee4,
+	{0x0055, 0x0324}:   0x1e72,
+	{0x0055, 0x0328}:   0x0172,
+	{0x0055, 0x032d}:   0x1e76,
+	{0x0055, 0x0330}:   0x1e74,
+	{0x0056, 0x0303}:   0x1e7c,
+	{0x0056, 0x0323}:   0x1e7e,
+	{0x0057, 0x0300}:   0x1e80,
+	{0x0057, 0x0301}:   0x1e82,
+	{0x0057, 0x0302}:   0x0174,
+	{0x0057, 0x0307}:   0x1e86,
+	{0x0057, 0x0308}:   0x1e84,
+	{0x0057, 0x0323}:   0x1e88,
+	{0x0058, 0x0307}:   0x1e8a,
+	{0x0058, 0x0308}:   0x1e8c,
+	{0x0059, 0x0300}:   0x1ef2,
+	{0x0059, 0x0301}:   0x00dd,
+	{0x0059, 0x0302}:   0x0176,
+	{0x0059, 0x0303}:   0x1ef8,
+	{0x0059, 0x0304}:   0x0232,
+	{0x0059, 0x0307}:   0x1e8e,
+	{0x0059, 0x0308}:   0x0178,
+	{0x0059, 0x0309}:   0x1ef6,
+	{0x0059, 0x0323}:   0x1ef4,
+	{0x005a, 0x0301}:   0x0179,
+	{0x005a, 0x0302}:   0x1e90,
+	{0x005a, 0x0307}:   0x017b,
+	{0x005a, 0x030c}:   0x017d,
+	{0x005a, 0x0323}:   0x1e92,
+	{0x005a, 0x0331}:   0x1e94,
+	{0x0061, 0x0300}:   0x00e0,
+	{0x0061, 0x0301}:   0x00e1,
+	{0x0061, 0x0302}:   0x00e2,
+	{0x0061, 0x0303}:   0x00e3,
+	{0x0061, 0x0304}:   0x0101,
+	{0x0061, 0x0306}:   0x0103,
+	{0x0061, 0x0307}:   0x0227,
+	{0x0061, 0x0308}:   0x00e4,
+	{0x0061, 0x0309}:   0x1ea3,
+	{0x0061, 0x030a}:   0x00e5,
+	{0x0061, 0x030c}:   0x01ce,
+	{0x0061, 0x030f}:   0x0201,
+	{0x0061, 0x0311}:   0x0203,
+	{0x0061, 0x0323}:   0x1ea1,
+	{0x0061, 0x0325}:   0x1e01,
+	{0x0061, 0x0328}:   0x0105,
+	{0x0062, 0x0307}:   0x1e03,
+	{0x0062, 0x0323}:   0x1e05,
+	{0x0062, 0x0331}:   0x1e07,
+	{0x0063, 0x0301}:   0x0107,
+	{0x0063, 0x0302}:   0x0109,
+	{0x0063, 0x0307}:   0x010b,
+	{0x0063, 0x030c}:   0x010d,
+	{0x0063, 0x0327}:   0x00e7,
+	{0x0064, 0x0307}:   0x1e0b,
+	{0x0064, 0x030c}:   0x010f,
+	{0x0064, 0x0323}:   0x1e0d,
+	{0x0064, 0x0327}:   0x1e11,
+	{0x0064, 0x032d}:   0x1e13,
+	{0x0064, 0x0331}:   0x1e0f,
+	{0x0065, 0x0300}:   0x00e8,
+	{0x0065, 0x0301}:   0x00e9,
+	{0x0065, 0x0302}:   0x00ea,
+	{0x0065, 0x0303}:   0x1ebd,
+	{0x0065, 0x0304}:   0x0113,
+	{0x0065, 0x0306}:   0x0115,
+	{0x0065, 0x0307}:   0x0117,
+	{0x0065, 0x0308}:   0x00eb,
+	{0x0065, 0x0309}:   0x1ebb,
+	{0x0065, 0x030c}:   0x011b,
+	{0x0065, 0x030f}:   0x0205,
+	{0x0065, 0x0311}:   0x0207,
+	{0x0065, 0x0323}:   0x1eb9,
+	{0x0065, 0x0327}:   0x0229,
+	{0x0065, 0x0328}:   0x0119,
+	{0x0065, 0x032d}:   0x1e19,
+	{0x0065, 0x0330}:   0x1e1b,
+	{0x0066, 0x0307}:   0x1e1f,
+	{0x0067, 0x0301}:   0x01f5,
+	{0x0067, 0x0302}:   0x011d,
+	{0x0067, 0x0304}:   0x1e21,
+	{0x0067, 0x0306}:   0x011f,
+	{0x0067, 0x0307}:   0x0121,
+	{0x0067, 0x030c}:   0x01e7,
+	{0x0067, 0x0327}:   0x0123,
+	{0x0068, 0x0302}:   0x0125,
+	{0x0068, 0x0307}:   0x1e23,
+	{0x0068, 0x0308}:   0x1e27,
+	{0x0068, 0x030c}:   0x021f,
+	{0x0068, 0x0323}:   0x1e25,
+	{0x0068, 0x0327}:   0x1e29,
+	{0x0068, 0x032e}:   0x1e2b,
+	{0x0068, 0x0331}:   0x1e96,
+	{0x0069, 0x0300}:   0x00ec,
+	{0x0069, 0x0301}:   0x00ed,
+	{0x0069, 0x0302}:   0x00ee,
+	{0x0069, 0x0303}:   0x0129,
+	{0x0069, 0x0304}:   0x012b,
+	{0x0069, 0x0306}:   0x012d,
+	{0x0069, 0x0308}:   0x00ef,
+	{0x0069, 0x0309}:   0x1ec9,
+	{0x0069, 0x030c}:   0x01d0,
+	{0x0069, 0x030f}:   0x0209,
+	{0x0069, 0x0311}:   0x020b,
+	{0x0069, 0x0323}:   0x1ecb,
+	{0x0069, 0x0328}:   0x012f,
+	{0x0069, 0x0330}:   0x1e2d,
+	{0x006a, 0x0302}:   0x0135,
+	{0x006a, 0x030c}:   0x01f0,
+	{0x006b, 0x0301}:   0x1e31,
+	{0x006b, 0x030c}:   0x01e9,
+	{0x006b, 0x0323}:   0x1e33,
+	{0x006b, 0x0327}:   0x0137,
+	{0x006b, 0x0331}:   0x1e35,
+	{0x006c, 0x0301}:   0x013a,
+	{0x006c, 0x030c}:   0x013e,
+	{0x006c, 0x0323}:   0x1e37,
+	{0x006c, 0x0327}:   0x013c,
+	{0x006c, 0x032d}:   0x1e3d,
+	{0x006c, 0x0331}:   0x1e3b,
+	{0x006d, 0x0301}:   0x1e3f,
+	{0x006d, 0x0307}:   0x1e41,
+	{0x006d, 0x0323}:   0x1e43,
+	{0x006e, 0x0300}:   0x01f9,
+	{0x006e, 0x0301}:   0x0144,
+	{0x006e, 0x0303}:   0x00f1,
+	{0x006e, 0x0307}:   0x1e45,
+	{0x006e, 0x030c}:   0x0148,
+	{0x006e, 0x0323}:   0x1e47,
+	{0x006e, 0x0327}:   0x0146,
+	{0x006e, 0x032d}:   0x1e4b,
+	{0x006e, 0x0331}:   0x1e49,
+	{0x006f, 0x0300}:   0x00f2,
+	{0x006f, 0x0301}:   0x00f3,
+	{0x006f, 0x0302}:   0x00f4,
+	{0x006f, 0x0303}:   0x00f5,
+	{0x006f, 0x0304}:   0x014d,
+	{0x006f, 0x0306}:   0x014f,
+	{0x006f, 0x0307}:   0x022f,
+	{0x006f, 0x0308}:   0x00f6,
+	{0x006f, 0x0309}:   0x1ecf,
+	{0x006f, 0x030b}:   0x0151,
+	{0x006f, 0x030c}:   0x01d2,
+	{0x006f, 0x030f}:   0x020d,
+	{0x006f, 0x0311}:   0x020f,
+	{0x006f, 0x031b}:   0x01a1,
+	{0x006f, 0x0323}:   0x1ecd,
+	{0x006f, 0x0328}:   0x01eb,
+	{0x0070, 0x0301}:   0x1e55,
+	{0x0070, 0x0307}:   0x1e57,
+	{0x0072, 0x0301}:   0x0155,
+	{0x0072, 0x0307}:   0x1e59,
+	{0x0072, 0x030c}:   0x0159,
+	{0x0072, 0x030f}:   0x0211,
+	{0x0072, 0x0311}:   0x0213,
+	{0x0072, 0x0323}:   0x1e5b,
+	{0x0072, 0x0327}:   0x0157,
+	{0x0072, 0x0331}:   0x1e5f,
+	{0x0073, 0x0301}:   0x015b,
+	{0x0073, 0x0302}:   0x015d,
+	{0x0073, 0x0307}:   0x1e61,
+	{0x0073, 0x030c}:   0x0161,
+	{0x0073, 0x0323}:   0x1e63,
+	{0x0073, 0x0326}:   0x0219,
+	{0x0073, 0x0327}:   0x015f,
+	{0x0074, 0x0307}:   0x1e6b,
+	{0x0074, 0x0308}:   0x1e97,
+	{0x0074, 0x030c}:   0x0165,
+	{0x0074, 0x0323}:   0x1e6d,
+	{0x0074, 0x0326}:   0x021b,
+	{0x0074, 0x0327}:   0x0163,
+	{0x0074, 0x032d}:   0x1e71,
+	{0x0074, 0x0331}:   0x1e6f,
+	{0x0075, 0x0300}:   0x00f9,
+	{0x0075, 0x0301}:   0x00fa,
+	{0x0075, 0x0302}:   0x00fb,
+	{0x0075, 0x0303}:   0x0169,
+	{0x0075, 0x0304}:   0x016b,
+	{0x0075, 0x0306}:   0x016d,
+	{0x0075, 0x0308}:   0x00fc,
+	{0x0075, 0x0309}:   0x1ee7,
+	{0x0075, 0x030a}:   0x016f,
+	{0x0075, 0x030b}:   0x0171,
+	{0x0075, 0x030c}:   0x01d4,
+	{0x0075, 0x030f}:   0x0215,
+	{0x0075, 0x0311}:   0x0217,
+	{0x0075, 0x031b}:   0x01b0,
+	{0x0075, 0x0323}:   0x1ee5,
+	{0x0075, 0x0324}:   0x1e73,
+	{0x0075, 0x0328}:   0x0173,
+	{0x0075, 0x032d}:   0x1e77,
+	{0x0075, 0x0330}:   0x1e75,
+	{0x0076, 0x0303}:   0x1e7d,
+	{0x0076, 0x0323}:   0x1e7f,
+	{0x0077, 0x0300}:   0x1e81,
+	{0x0077, 0x0301}:   0x1e83,
+	{0x0077, 0x0302}:   0x0175,
+	{0x0077, 0x0307}:   0x1e87,
+	{0x0077, 0x0308}:   0x1e85,
+	{0x0077, 0x030a}:   0x1e98,
+	{0x0077, 0x0323}:   0x1e89,
+	{0x0078, 0x0307}:   0x1e8b,
+	{0x0078, 0x0308}:   0x1e8d,
+	{0x0079, 0x0300}:   0x1ef3,
+	{0x0079, 0x0301}:   0x00fd,
+	{0x0079, 0x0302}:   0x0177,
+	{0x0079, 0x0303}:   0x1ef9,
+	{0x0079, 0x0304}:   0x0233,
+	{0x0079, 0x0307}:   0x1e8f,
+	{0x0079, 0x0308}:   0x00ff,
+	{0x0079, 0x0309}:   0x1ef7,
+	{0x0079, 0x030a}:   0x1e99,
+	{0x0079, 0x0323}:   0x1ef5,
+	{0x007a, 0x0301}:   0x017a,
+	{0x007a, 0x0302}:   0x1e91,
+	{0x007a, 0x0307}:   0x017c,
+	{0x007a, 0x030c}:   0x017e,
+	{0x007a, 0x0323}:   0x1e93,
+	{0x007a, 0x0331}:   0x1e95,
+	{0x00a8, 0x0300}:   0x1fed,
+	{0x00a8, 0x0301}:   0x0385,
+	{0x00a8, 0x0342}:   0x1fc1,
+	{0x00c2, 0x0300}:   0x1ea6,
+	{0x00c2, 0x0301}:   0x1ea4,
+	{0x00c2, 0x0303}:   0x1eaa,
+	{0x00c2, 0x0309}:   0x1ea8,
+	{0x00c4, 0x0304}:   0x01de,
+	{0x00c5, 0x0301}:   0x01fa,
+	{0x00c6, 0x0301}:   0x01fc,
+	{0x00c6, 0x0304}:   0x01e2,
+	{0x00c7, 0x0301}:   0x1e08,
+	{0x00ca, 0x0300}:   0x1ec0,
+	{0x00ca, 0x0301}:   0x1ebe,
+	{0x00ca, 0x0303}:   0x1ec4,
+	{0x00ca, 0x0309}:   0x1ec2,
+	{0x00cf, 0x0301}:   0x1e2e,
+	{0x00d4, 0x0300}:   0x1ed2,
+	{0x00d4, 0x0301}:   0x1ed0,
+	{0x00d4, 0x0303}:   0x1ed6,
+	{0x00d4, 0x0309}:   0x1ed4,
+	{0x00d5, 0x0301}:   0x1e4c,
+	{0x00d5, 0x0304}:   0x022c,
+	{0x00d5, 0x0308}:   0x1e4e,
+	{0x00d6, 0x0304}:   0x022a,
+	{0x00d8, 0x0301}:   0x01fe,
+	{0x00dc, 0x0300}:   0x01db,
+	{0x00dc, 0x0301}:   0x01d7,
+	{0x00dc, 0x0304}:   0x01d5,
+	{0x00dc, 0x030c}:   0x01d9,
+	{0x00e2, 0x0300}:   0x1ea7,
+	{0x00e2, 0x0301}:   0x1ea5,
+	{0x00e2, 0x0303}:   0x1eab,
+	{0x00e2, 0x0309}:   0x1ea9,
+	{0x00e4, 0x0304}:   0x01df,
+	{0x00e5, 0x0301}:   0x01fb,
+	{0x00e6, 0x0301}:   0x01fd,
+	{0x00e6, 0x0304}:   0x01e3,
+	{0x00e7, 0x0301}:   0x1e09,
+	{0x00ea, 0x0300}:   0x1ec1,
+	{0x00ea, 0x0301}:   0x1ebf,
+	{0x00ea, 0x0303}:   0x1ec5,
+	{0x00ea, 0x0309}:   0x1ec3,
+	{0x00ef, 0x0301}:   0x1e2f,
+	{0x00f4, 0x0300}:   0x1ed3,
+	{0x00f4, 0x0301}:   0x1ed1,
+	{0x00f4, 0x0303}:   0x1ed7,
+	{0x00f4, 0x0309}:   0x1ed5,
+	{0x00f5, 0x0301}:   0x1e4d,
+	{0x00f5, 0x0304}:   0x022d,
+	{0x00f5, 0x0308}:   0x1e4f,
+	{0x00f6, 0x0304}:   0x022b,
+	{0x00f8, 0x0301}:   0x01ff,
+	{0x00fc, 0x0300}:   0x01dc,
+	{0x00fc, 0x0301}:   0x01d8,
+	{0x00fc, 0x0304}:   0x01d6,
+	{0x00fc, 0x030c}:   0x01da,
+	{0x0102, 0x0300}:   0x1eb0,
+	{0x0102, 0x0301}:   0x1eae,
+	{0x0102, 0x0303}:   0x1eb4,
+	{0x0102, 0x0309}:   0x1eb2,
+	{0x0103, 0x0300}:   0x1eb1,
+	{0x0103, 0x0301}:   0x1eaf,
+	{0x0103, 0x0303}:   0x1eb5,
+	{0x0103, 0x0309}:   0x1eb3,
+	{0x0112, 0x0300}:   0x1e14,
+	{0x0112, 0x0301}:   0x1e16,
+	{0x0113, 0x0300}:   0x1e15,
+	{0x0113, 0x0301}:   0x1e17,
+	{0x014c, 0x0300}:   0x1e50,
+	{0x014c, 0x0301}:   0x1e52,
+	{0x014d, 0x0300}:   0x1e51,
+	{0x014d, 0x0301}:   0x1e53,
+	{0x015a, 0x0307}:   0x1e64,
+	{0x015b, 0x0307}:   0x1e65,
+	{0x0160, 0x0307}:   0x1e66,
+	{0x0161, 0x0307}:   0x1e67,
+	{0x0168, 0x0301}:   0x1e78,
+	{0x0169, 0x0301}:   0x1e79,
+	{0x016a, 0x0308}:   0x1e7a,
+	{0x016b, 0x0308}:   0x1e7b,
+	{0x017f, 0x0307}:   0x1e9b,
+	{0x01a0, 0x0300}:   0x1edc,
+	{0x01a0, 0x0301}:   0x1eda,
+	{0x01a0, 0x0303}:   0x1ee0,
+	{0x01a0, 0x0309}:   0x1ede,
+	{0x01a0, 0x0323}:   0x1ee2,
+	{0x01a1, 0x0300}:   0x1edd,
+	{0x01a1, 0x0301}:   0x1edb,
+	{0x01a1, 0x0303}:   0x1ee1,
+	{0x01a1, 0x0309}:   0x1edf,
+	{0x01a1, 0x0323}:   0x1ee3,
+	{0x01af, 0x0300}:   0x1eea,
+	{0x01af, 0x0301}:   0x1ee8,
+	{0x01af, 0x0303}:   0x1eee,
+	{0x01af, 0x0309}:   0x1eec,
+	{0x01af, 0x0323}:   0x1ef0,
+	{0x01b0, 0x0300}:   0x1eeb,
+	{0x01b0, 0x0301}:   0x1ee9,
+	{0x01b0, 0x0303}:   0x1eef,
+	{0x01b0, 0x0309}:   0x1eed,
+	{0x01b0, 0x0323}:   0x1ef1,
+	{0x01b7, 0x030c}:   0x01ee,
+	{0x01ea, 0x0304}:   0x01ec,
+	{0x01eb, 0x0304}:   0x01ed,
+	{0x0226, 0x0304}:   0x01e0,
+	{0x0227, 0x0304}:   0x01e1,
+	{0x0228, 0x0306}:   0x1e1c,
+	{0x0229, 0x0306}:   0x1e1d,
+	{0x022e, 0x0304}:   0x0230,
+	{0x022f, 0x0304}:   0x0231,
+	{0x0292, 0x030c}:   0x01ef,
+	{0x0391, 0x0300}:   0x1fba,
+	{0x0391, 0x0301}:   0x0386,
+	{0x0391, 0x0304}:   0x1fb9,
+	{0x0391, 0x0306}:   0x1fb8,
+	{0x0391, 0x0313}:   0x1f08,
+	{0x0391, 0x0314}:   0x1f09,
+	{0x0391, 0x0345}:   0x1fbc,
+	{0x0395, 0x0300}:   0x1fc8,
+	{0x0395, 0x0301}:   0x0388,
+	{0x0395, 0x0313}:   0x1f18,
+	{0x0395, 0x0314}:   0x1f19,
+	{0x0397, 0x0300}:   0x1fca,
+	{0x0397, 0x0301}:   0x0389,
+	{0x0397, 0x0313}:   0x1f28,
+	{0x0397, 0x0314}:   0x1f29,
+	{0x0397, 0x0345}:   0x1fcc,
+	{0x0399, 0x0300}:   0x1fda,
+	{0x0399, 0x0301}:   0x038a,
+	{0x0399, 0x0304}:   0x1fd9,
+	{0x0399, 0x0306}:   0x1fd8,
+	{0x0399, 0x0308}:   0x03aa,
+	{0x0399, 0x0313}:   0x1f38,
+	{0x0399, 0x0314}:   0x1f39,
+	{0x039f, 0x0300}:   0x1ff8,
+	{0x039f, 0x0301}:   0x038c,
+	{0x039f, 0x0313}:   0x1f48,
+	{0x039f, 0x0314}:   0x1f49,
+	{0x03a1, 0x0314}:   0x1fec,
+	{0x03a5, 0x0300}:   0x1fea,
+	{0x03a5, 0x0301}:   0x038e,
+	{0x03a5, 0x0304}:   0x1fe9,
+	{0x03a5, 0x0306}:   0x1fe8,
+	{0x03a5, 0x0308}:   0x03ab,
+	{0x03a5, 0x0314}:   0x1f59,
+	{0x03a9, 0x0300}:   0x1ffa,
+	{0x03a9, 0x0301}:   0x038f,
+	{0x03a9, 0x0313}:   0x1f68,
+	{0x03a9, 0x0314}:   0x1f69,
+	{0x03a9, 0x0345}:   0x1ffc,
+	{0x03ac, 0x0345}:   0x1fb4,
+	{0x03ae, 0x0345}:   0x1fc4,
+	{0x03b1, 0x0300}:   0x1f70,
+	{0x03b1, 0x0301}:   0x03ac,
+	{0x03b1, 0x0304}:   0x1fb1,
+	{0x03b1, 0x0306}:   0x1fb0,
+	{0x03b1, 0x0313}:   0x1f00,
+	{0x03b1, 0x0314}:   0x1f01,
+	{0x03b1, 0x0342}:   0x1fb6,
+	{0x03b1, 0x0345}:   0x1fb3,
+	{0x03b5, 0x0300}:   0x1f72,
+	{0x03b5, 0x0301}:   0x03ad,
+	{0x03b5, 0x0313}:   0x1f10,
+	{0x03b5, 0x0314}:   0x1f11,
+	{0x03b7, 0x0300}:   0x1f74,
+	{0x03b7, 0x0301}:   0x03ae,
+	{0x03b7, 0x0313}:   0x1f20,
+	{0x03b7, 0x0314}:   0x1f21,
+	{0x03b7, 0x0342}:   0x1fc6,
+	{0x03b7, 0x0345}:   0x1fc3,
+	{0x03b9, 0x0300}:   0x1f76,
+	{0x03b9, 0x0301}:   0x03af,
+	{0x03b9, 0x0304}:   0x1fd1,
+	{0x03b9, 0x0306}:   0x1fd0,
+	{0x03b9, 0x0308}:   0x03ca,
+	{0x03b9, 0x0313}:   0x1f30,
+	{0x03b9, 0x0314}:   0x1f31,
+	{0x03b9, 0x0342}:   0x1fd6,
+	{0x03bf, 0x0300}:   0x1f78,
+	{0x03bf, 0x0301}:   0x03cc,
+	{0x03bf, 0x0313}:   0x1f40,
+	{0x03bf, 0x0314}:   0x1f41,
+	{0x03c1, 0x0313}:   0x1fe4,
+	{0x03c1, 0x0314}:   0x1fe5,
+	{0x03c5, 0x0300}:   0x1f7a,
+	{0x03c5, 0x0301}:   0x03cd,
+	{0x03c5, 0x0304}:   0x1fe1,
+	{0x03c5, 0x0306}:   0x1fe0,
+	{0x03c5, 0x0308}:   0x03cb,
+	{0x03c5, 0x0313}:   0x1f50,
+	{0x03c5, 0x0314}:   0x1f51,
+	{0x03c5, 0x0342}:   0x1fe6,
+	{0x03c9, 0x0300}:   0x1f7c,
+	{0x03c9, 0x0301}:   0x03ce,
+	{0x03c9, 0x0313}:   0x1f60,
+	{0x03c9, 0x0314}:   0x1f61,
+	{0x03c9, 0x0342}:   0x1ff6,
+	{0x03c9, 0x0345}:   0x1ff3,
+	{0x03ca, 0x0300}:   0x1fd2,
+	{0x03ca, 0x0301}:   0x0390,
+	{0x03ca, 0x0342}:   0x1fd7,
+	{0x03cb, 0x0300}:   0x1fe2,
+	{0x03cb, 0x0301}:   0x03b0,
+	{0x03cb, 0x0342}:   0x1fe7,
+	{0x03ce, 0x0345}:   0x1ff4,
+	{0x03d2, 0x0301}:   0x03d3,
+	{0x03d2, 0x0308}:   0x03d4,
+	{0x0406, 0x0308}:   0x0407,
+	{0x0410, 0x0306}:   0x04d0,
+	{0x0410, 0x0308}:   0x04d2,
+	{0x0413, 0x0301}:   0x0403,
+	{0x0415, 0x0300}:   0x0400,
+	{0x0415, 0x0306}:   0x04d6,
+	{0x0415, 0x0308}:   0x0401,
+	{0x0416, 0x0306}:   0x04c1,
+	{0x0416, 0x0308}:   0x04dc,
+	{0x0417, 0x0308}:   0x04de,
+	{0x0418, 0x0300}:   0x040d,
+	{0x0418, 0x0304}:   0x04e2,
+	{0x0418, 0x0306}:   0x0419,
+	{0x0418, 0x0308}:   0x04e4,
+	{0x041a, 0x0301}:   0x040c,
+	{0x041e, 0x0308}:   0x04e6,
+	{0x0423, 0x0304}:   0x04ee,
+	{0x0423, 0x0306}:   0x040e,
+	{0x0423, 0x0308}:   0x04f0,
+	{0x0423, 0x030b}:   0x04f2,
+	{0x0427, 0x0308}:   0x04f4,
+	{0x042b, 0x0308}:   0x04f8,
+	{0x042d, 0x0308}:   0x04ec,
+	{0x0430, 0x0306}:   0x04d1,
+	{0x0430, 0x0308}:   0x04d3,
+	{0x0433, 0x0301}:   0x0453,
+	{0x0435, 0x0300}:   0x0450,
+	{0x0435, 0x0306}:   0x04d7,
+	{0x0435, 0x0308}:   0x0451,
+	{0x0436, 0x0306}:   0x04c2,
+	{0x0436, 0x0308}:   0x04dd,
+	{0x0437, 0x0308}:   0x04df,
+	{0x0438, 0x0300}:   0x045d,
+	{0x0438, 0x0304}:   0x04e3,
+	{0x0438, 0x0306}:   0x0439,
+	{0x0438, 0x0308}:   0x04e5,
+	{0x043a, 0x0301}:   0x045c,
+	{0x043e, 0x0308}:   0x04e7,
+	{0x0443, 0x0304}:   0x04ef,
+	{0x0443, 0x0306}:   0x045e,
+	{0x0443, 0x0308}:   0x04f1,
+	{0x0443, 0x030b}:   0x04f3,
+	{0x0447, 0x0308}:   0x04f5,
+	{0x044b, 0x0308}:   0x04f9,
+	{0x044d, 0x0308}:   0x04ed,
+	{0x0456, 0x0308}:   0x0457,
+	{0x0474, 0x030f}:   0x0476,
+	{0x0475, 0x030f}:   0x0477,
+	{0x04d8, 0x0308}:   0x04da,
+	{0x04d9, 0x0308}:   0x04db,
+	{0x04e8, 0x0308}:   0x04ea,
+	{0x04e9, 0x0308}:   0x04eb,
+	{0x0627, 0x0653}:   0x0622,
+	{0x0627, 0x0654}:   0x0623,
+	{0x0627, 0x0655}:   0x0625,
+	{0x0648, 0x0654}:   0x0624,
+	{0x064a, 0x0654}:   0x0626,
+	{0x06c1, 0x0654}:   0x06c2,
+	{0x06d2, 0x0654}:   0x06d3,
+	{0x06d5, 0x0654}:   0x06c0,
+	{0x0928, 0x093c}:   0x0929,
+	{0x0930, 0x093c}:   0x0931,
+	{0x0933, 0x093c}:   0x0934,
+	{0x09c7, 0x09be}:   0x09cb,
+	{0x09c7, 0x09d7}:   0x09cc,
+	{0x0b47, 0x0b3e}:   0x0b4b,
+	{0x0b47, 0x0b56}:   0x0b48,
+	{0x0b47, 0x0b57}:   0x0b4c,
+	{0x0b92, 0x0bd7}:   0x0b94,
+	{0x0bc6, 0x0bbe}:   0x0bca,
+	{0x0bc6, 0x0bd7}:   0x0bcc,
+	{0x0bc7, 0x0bbe}:   0x0bcb,
+	{0x0c46, 0x0c56}:   0x0c48,
+	{0x0cbf, 0x0cd5}:   0x0cc0,
+	{0x0cc6, 0x0cc2}:   0x0cca,
+	{0x0cc6, 0x0cd5}:   0x0cc7,
+	{0x0cc6, 0x0cd6}:   0x0cc8,
+	{0x0cca, 0x0cd5}:   0x0ccb,
+	{0x0d46, 0x0d3e}:   0x0d4a,
+	{0x0d46, 0x0d57}:   0x0d4c,
+	{0x0d47, 0x0d3e}:   0x0d4b,
+	{0x0dd9, 0x0dca}:   0x0dda,
+	{0x0dd9, 0x0dcf}:   0x0ddc,
+	{0x0dd9, 0x0ddf}:   0x0dde,
+	{0x0ddc, 0x0dca}:   0x0ddd,
+	{0x1025, 0x102e}:   0x1026,
+	{0x1b05, 0x1b35}:   0x1b06,
+	{0x1b07, 0x1b35}:   0x1b08,
+	{0x1b09, 0x1b35}:   0x1b0a,
+	{0x1b0b, 0x1b35}:   0x1b0c,
+	{0x1b0d, 0x1b35}:   0x1b0e,
+	{0x1b11, 0x1b35}:   0x1b12,
+	{0x1b3a, 0x1b35}:   0x1b3b,
+	{0x1b3c, 0x1b35}:   0x1b3d,
+	{0x1b3e, 0x1b35}:   0x1b40,
+	{0x1b3f, 0x1b35}:   0x1b41,
+	{0x1b42, 0x1b35}:   0x1b43,
+	{0x1e36, 0x0304}:   0x1e38,
+	{0x1e37, 0x0304}:   0x1e39,
+	{0x1e5a, 0x0304}:   0x1e5c,
+	{0x1e5b, 0x0304}:   0x1e5d,
+	{0x1e62, 0x0307}:   0x1e68,
+	{0x1e63, 0x0307}:   0x1e69,
+	{0x1ea0, 0x0302}:   0x1eac,
+	{0x1ea0, 0x0306}:   0x1eb6,
+	{0x1ea1, 0x0302}:   0x1ead,
+	{0x1ea1, 0x0306}:   0x1eb7,
+	{0x1eb8, 0x0302}:   0x1ec6,
+	{0x1eb9, 0x0302}:   0x1ec7,
+	{0x1ecc, 0x0302}:   0x1ed8,
+	{0x1ecd, 0x0302}:   0x1ed9,
+	{0x1f00, 0x0300}:   0x1f02,
+	{0x1f00, 0x0301}:   0x1f04,
+	{0x1f00, 0x0342}:   0x1f06,
+	{0x1f00, 0x0345}:   0x1f80,
+	{0x1f01, 0x0300}:   0x1f03,
+	{0x1f01, 0x0301}:   0x1f05,
+	{0x1f01, 0x0342}:   0x1f07,
+	{0x1f01, 0x0345}:   0x1f81,
+	{0x1f02, 0x0345}:   0x1f82,
+	{0x1f03, 0x0345}:   0x1f83,
+	{0x1f04, 0x0345}:   0x1f84,
+	{0x1f05, 0x0345}:   0x1f85,
+	{0x1f06, 0x0345}:   0x1f86,
+	{0x1f07, 0x0345}:   0x1f87,
+	{0x1f08, 0x0300}:   0x1f0a,
+	{0x1f08, 0x0301}:   0x1f0c,
+	{0x1f08, 0x0342}:   0x1f0e,
+	{0x1f08, 0x0345}:   0x1f88,
+	{0x1f09, 0x0300}:   0x1f0b,
+	{0x1f09, 0x0301}:   0x1f0d,
+	{0x1f09, 0x0342}:   0x1f0f,
+	{0x1f09, 0x0345}:   0x1f89,
+	{0x1f0a, 0x0345}:   0x1f8a,
+	{0x1f0b, 0x0345}:   0x1f8b,
+	{0x1f0c, 0x0345}:   0x1f8c,
+	{0x1f0d, 0x0345}:   0x1f8d,
+	{0x1f0e, 0x0345}:   0x1f8e,
+	{0x1f0f, 0x0345}:   0x1f8f,
+	{0x1f10, 0x0300}:   0x1f12,
+	{0x1f10, 0x0301}:   0x1f14,
+	{0x1f11, 0x0300}:   0x1f13,
+	{0x1f11, 0x0301}:   0x1f15,
+	{0x1f18, 0x0300}:   0x1f1a,
+	{0x1f18, 0x0301}:   0x1f1c,
+	{0x1f19, 0x0300}:   0x1f1b,
+	{0x1f19, 0x0301}:   0x1f1d,
+	{0x1f20, 0x0300}:   0x1f22,
+	{0x1f20, 0x0301}:   0x1f24,
+	{0x1f20, 0x0342}:   0x1f26,
+	{0x1f20, 0x0345}:   0x1f90,
+	{0x1f21, 0x0300}:   0x1f23,
+	{0x1f21, 0x0301}:   0x1f25,
+	{0x1f21, 0x0342}:   0x1f27,
+	{0x1f21, 0x0345}:   0x1f91,
+	{0x1f22, 0x0345}:   0x1f92,
+	{0x1f23, 0x0345}:   0x1f93,
+	{0x1f24, 0x0345}:   0x1f94,
+	{0x1f25, 0x0345}:   0x1f95,
+	{0x1f26, 0x0345}:   0x1f96,
+	{0x1f27, 0x0345}:   0x1f97,
+	{0x1f28, 0x0300}:   0x1f2a,
+	{0x1f28, 0x0301}:   0x1f2c,
+	{0x1f28, 0x0342}:   0x1f2e,
+	{0x1f28, 0x0345}:   0x1f98,
+	{0x1f29, 0x0300}:   0x1f2b,
+	{0x1f29, 0x0301}:   0x1f2d,
+	{0x1f29, 0x0342}:   0x1f2f,
+	{0x1f29, 0x0345}:   0x1f99,
+	{0x1f2a, 0x0345}:   0x1f9a,
+	{0x1f2b, 0x0345}:   0x1f9b,
+	{0x1f2c, 0x0345}:   0x1f9c,
+	{0x1f2d, 0x0345}:   0x1f9d,
+	{0x1f2e, 0x0345}:   0x1f9e,
+	{0x1f2f, 0x0345}:   0x1f9f,
+	{0x1f30, 0x0300}:   0x1f32,
+	{0x1f30, 0x0301}:   0x1f34,
+	{0x1f30, 0x0342}:   0x1f36,
+	{0x1f31, 0x0300}:   0x1f33,
+	{0x1f31, 0x0301}:   0x1f35,
+	{0x1f31, 0x0342}:   0x1f37,
+	{0x1f38, 0x0300}:   0x1f3a,
+	{0x1f38, 0x0301}:   0x1f3c,
+	{0x1f38, 0x0342}:   0x1f3e,
+	{0x1f39, 0x0300}:   0x1f3b,
+	{0x1f39, 0x0301}:   0x1f3d,
+	{0x1f39, 0x0342}:   0x1f3f,
+	{0x1f40, 0x0300}:   0x1f42,
+	{0x1f40, 0x0301}:   0x1f44,
+	{0x1f41, 0x0300}:   0x1f43,
+	{0x1f41, 0x0301}:   0x1f45,
+	{0x1f48, 0x0300}:   0x1f4a,
+	{0x1f48, 0x0301}:   0x1f4c,
+	{0x1f49, 0x0300}:   0x1f4b,
+	{0x1f49, 0x0301}:   0x1f4d,
+	{0x1f50, 0x0300}:   0x1f52,
+	{0x1f50, 0x0301}:   0x1f54,
+	{0x1f50, 0x0342}:   0x1f56,
+	{0x1f51, 0x0300}:   0x1f53,
+	{0x1f51, 0x0301}:   0x1f55,
+	{0x1f51, 0x0342}:   0x1f57,
+	{0x1f59, 0x0300}:   0x1f5b,
+	{0x1f59, 0x0301}:   0x1f5d,
+	{0x1f59, 0x0342}:   0x1f5f,
+	{0x1f60, 0x0300}:   0x1f62,
+	{0x1f60, 0x0301}:   0x1f64,
+	{0x1f60, 0x0342}:   0x1f66,
+	{0x1f60, 0x0345}:   0x1fa0,
+	{0x1f61, 0x0300}:   0x1f63,
+	{0x1f61, 0x0301}:   0x1f65,
+	{0x1f61, 0x0342}:   0x1f67,
+	{0x1f61, 0x0345}:   0x1fa1,
+	{0x1f62, 0x0345}:   0x1fa2,
+	{0x1f63, 0x0345}:   0x1fa3,
+	{0x1f64, 0x0345}:   0x1fa4,
+	{0x1f65, 0x0345}:   0x1fa5,
+	{0x1f66, 0x0345}:   0x1fa6,
+	{0x1f67, 0x0345}:   0x1fa7,
+	{0x1f68, 0x0300}:   0x1f6a,
+	{0x1f68, 0x0301}:   0x1f6c,
+	{0x1f68, 0x0342}:   0x1f6e,
+	{0x1f68, 0x0345}:   0x1fa8,
+	{0x1f69, 0x0300}:   0x1f6b,
+	{0x1f69, 0x0301}:   0x1f6d,
+	{0x1f69, 0x0342}:   0x1f6f,
+	{0x1f69, 0x0345}:   0x1fa9,
+	{0x1f6a, 0x0345}:   0x1faa,
+	{0x1f6b, 0x0345}:   0x1fab,
+	{0x1f6c, 0x0345}:   0x1fac,
+	{0x1f6d, 0x0345}:   0x1fad,
+	{0x1f6e, 0x0345}:   0x1fae,
+	{0x1f6f, 0x0345}:   0x1faf,
+	{0x1f70, 0x0345}:   0x1fb2,
+	{0x1f74, 0x0345}:   0x1fc2,
+	{0x1f7c, 0x0345}:   0x1ff2,
+	{0x1fb6, 0x0345}:   0x1fb7,
+	{0x1fbf, 0x0300}:   0x1fcd,
+	{0x1fbf, 0x0301}:   0x1fce,
+	{0x1fbf, 0x0342}:   0x1fcf,
+	{0x1fc6, 0x0345}:   0x1fc7,
+	{0x1ff6, 0x0345}:   0x1ff7,
+	{0x1ffe, 0x0300}:   0x1fdd,
+	{0x1ffe, 0x0301}:   0x1fde,
+	{0x1ffe, 0x0342}:   0x1fdf,
+	{0x2190, 0x0338}:   0x219a,
+	{0x2192, 0x0338}:   0x219b,
+	{0x2194, 0x0338}:   0x21ae,
+	{0x21d0, 0x0338}:   0x21cd,
+	{0x21d2, 0x0338}:   0x21cf,
+	{0x21d4, 0x0338}:   0x21ce,
+	{0x2203, 0x0338}:   0x2204,
+	{0x2208, 0x0338}:   0x2209,
+	{0x220b, 0x0338}:   0x220c,
+	{0x2223, 0x0338}:   0x2224,
+	{0x2225, 0x0338}:   0x2226,
+	{0x223c, 0x0338}:   0x2241,
+	{0x2243, 0x0338}:   0x2244,
+	{0x2245, 0x0338}:   0x2247,
+	{0x2248, 0x0338}:   0x2249,
+	{0x224d, 0x0338}:   0x226d,
+	{0x2261, 0x0338}:   0x2262,
+	{0x2264, 0x0338}:   0x2270,
+	{0x2265, 0x0338}:   0x2271,
+	{0x2272, 0x0338}:   0x2274,
+	{0x2273, 0x0338}:   0x2275,
+	{0x2276, 0x0338}:   0x2278,
+	{0x2277, 0x0338}:   0x2279,
+	{0x227a, 0x0338}:   0x2280,
+	{0x227b, 0x0338}:   0x2281,
+	{0x227c, 0x0338}:   0x22e0,
+	{0x227d, 0x0338}:   0x22e1,
+	{0x2282, 0x0338}:   0x2284,
+	{0x2283, 0x0338}:   0x2285,
+	{0x2286, 0x0338}:   0x2288,
+	{0x2287, 0x0338}:   0x2289,
+	{0x2291, 0x0338}:   0x22e2,
+	{0x2292, 0x0338}:   0x22e3,
+	{0x22a2, 0x0338}:   0x22ac,
+	{0x22a8, 0x0338}:   0x22ad,
+	{0x22a9, 0x0338}:   0x22ae,
+	{0x22ab, 0x0338}:   0x22af,
+	{0x22b2, 0x0338}:   0x22ea,
+	{0x22b3, 0x0338}:   0x22eb,
+	{0x22b4, 0x0338}:   0x22ec,
+	{0x22b5, 0x0338}:   0x22ed,
+	{0x3046, 0x3099}:   0x3094,
+	{0x304b, 0x3099}:   0x304c,
+	{0x304d, 0x3099}:   0x304e,
+	{0x304f, 0x3099}:   0x3050,
+	{0x3051, 0x3099}:   0x3052,
+	{0x3053, 0x3099}:   0x3054,
+	{0x3055, 0x3099}:   0x3056,
+	{0x3057, 0x3099}:   0x3058,
+	{0x3059, 0x3099}:   0x305a,
+	{0x305b, 0x3099}:   0x305c,
+	{0x305d, 0x3099}:   0x305e,
+	{0x305f, 0x3099}:   0x3060,
+	{0x3061, 0x3099}:   0x3062,
+	{0x3064, 0x3099}:   0x3065,
+	{0x3066, 0x3099}:   0x3067,
+	{0x3068, 0x3099}:   0x3069,
+	{0x306f, 0x3099}:   0x3070,
+	{0x306f, 0x309a}:   0x3071,
+	{0x3072, 0x3099}:   0x3073,
+	{0x3072, 0x309a}:   0x3074,
+	{0x3075, 0x3099}:   0x3076,
+	{0x3075, 0x309a}:   0x3077,
+	{0x3078, 0x3099}:   0x3079,
+	{0x3078, 0x309a}:   0x307a,
+	{0x307b, 0x3099}:   0x307c,
+	{0x307b, 0x309a}:   0x307d,
+	{0x309d, 0x3099}:   0x309e,
+	{0x30a6, 0x3099}:   0x30f4,
+	{0x30ab, 0x3099}:   0x30ac,
+	{0x30ad, 0x3099}:   0x30ae,
+	{0x30af, 0x3099}:   0x30b0,
+	{0x30b1, 0x3099}:   0x30b2,
+	{0x30b3, 0x3099}:   0x30b4,
+	{0x30b5, 0x3099}:   0x30b6,
+	{0x30b7, 0x3099}:   0x30b8,
+	{0x30b9, 0x3099}:   0x30ba,
+	{0x30bb, 0x3099}:   0x30bc,
+	{0x30bd, 0x3099}:   0x30be,
+	{0x30bf, 0x3099}:   0x30c0,
+	{0x30c1, 0x3099}:   0x30c2,
+	{0x30c4, 0x3099}:   0x30c5,
+	{0x30c6, 0x3099}:   0x30c7,
+	{0x30c8, 0x3099}:   0x30c9,
+	{0x30cf, 0x3099}:   0x30d0,
+	{0x30cf, 0x309a}:   0x30d1,
+	{0x30d2, 0x3099}:   0x30d3,
+	{0x30d2, 0x309a}:   0x30d4,
+	{0x30d5, 0x3099}:   0x30d6,
+	{0x30d5, 0x309a}:   0x30d7,
+	{0x30d8, 0x3099}:   0x30d9,
+	{0x30d8, 0x309a}:   0x30da,
+	{0x30db, 0x3099}:   0x30dc,
+	{0x30db, 0x309a}:   0x30dd,
+	{0x30ef, 0x3099}:   0x30f7,
+	{0x30f0, 0x3099}:   0x30f8,
+	{0x30f1, 0x3099}:   0x30f9,
+	{0x30f2, 0x3099}:   0x30fa,
+	{0x30fd, 0x3099}:   0x30fe,
+	{0x11099, 0x110ba}: 0x1109a,
+	{0x1109b, 0x110ba}: 0x1109c,
+	{0x110a5, 0x110ba}: 0x110ab,
+	{0x11131, 0x11127}: 0x1112e,
+	{0x11132, 0x11127}: 0x1112f,
+	{0x11347, 0x1133e}: 0x1134b,
+	{0x11347, 0x11357}: 0x1134c,
+	{0x114b9, 0x114b0}: 0x114bc,
+	{0x114b9, 0x114ba}: 0x114bb,
+	{0x114b9, 0x114bd}: 0x114be,
+	{0x115b8, 0x115af}: 0x115ba,
+	{0x115b9, 0x115af}: 0x115bb,
+	{0x11935, 0x11930}: 0x11938,
+}
@@ -0,0 +1,40 @@
+package raster
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync/atomic"
+)
+
+// DefaultMaxPixels allows up to a 4096x4096 raster
+const DefaultMaxPixels = 4096 * 4096
+
+// ErrTooLarge is wrapped by Check; handlers map it to 400
+var ErrTooLarge = errors.New("raster too large")
+
+var maxPixels atomic.Int64
+
+func init() { maxPixels.Store(DefaultMaxPixels) }
+
+// MaxPixels returns the current limit on width*height
+func MaxPixels() int64 { return maxPixels.Load() }
+
+// SetMaxPixels changes the limit; n <= 0 restores DefaultMaxPixels
+func SetMaxPixels(n int64) {
+	if n <= 0 { n = DefaultMaxPixels }
+	maxPixels.Store(n)
+}
+
+// Check rejects a width x height raster whose area exceeds MaxPixels. A raster that passes can be
+// indexed with y*width+x in int arithmetic: the limit is also capped at math.MaxInt, which only
+// matters on 32-bit platforms.
+func Check(width, height int) error {
+	if width < 0 || height < 0 { return fmt.Errorf("%w: negative size %dx%d", ErrTooLarge, width, height) }
+	// Divided rather than multiplied, so sides near math.MaxInt cannot wrap the area into range
+	limit := min(MaxPixels(), int64(math.MaxInt))
+	if height > 0 && int64(width) > limit/int64(height) {
+		return fmt.Errorf("%w: %dx%d is over the limit of %d pixels", ErrTooLarge, width, height, limit)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package raster
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	defer SetMaxPixels(0)
+	SetMaxPixels(100)
+
+	if err := Check(10, 10); err != nil {
+		t.Fatalf("Expected 10x10 to fit a 100 pixel limit: %v", err)
+	}
+	if err := Check(10, 11); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("Expected ErrTooLarge for 10x11, got %v", err)
+	}
+	if err := Check(-1, 5); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("Expected ErrTooLarge for a negative size, got %v", err)
+	}
+	if err := Check(1<<31, 1<<31); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("Expected ErrTooLarge for a huge size, got %v", err)
+	}
+	if err := Check(math.MaxInt, 2); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("Expected ErrTooLarge for a size whose area overflows, got %v", err)
+	}
+	if err := Check(0, math.MaxInt); err != nil {
+		t.Fatalf("Expected an empty raster to fit: %v", err)
+	}
+}
+
+func TestSetMaxPixels_Default(t *testing.T) {
+	SetMaxPixels(5)
+	SetMaxPixels(0)
+	if got := MaxPixels(); got != DefaultMaxPixels {
+		t.Fatalf("Expected default %d, got %d", DefaultMaxPixels, got)
+	}
+}
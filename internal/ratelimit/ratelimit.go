@@ -0,0 +1,105 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Limiter is a set of token buckets keyed by an arbitrary string (IP, user id, ...)
+type Limiter struct {
+	Rate  float64 // tokens added per second
+	Burst int     // bucket capacity
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	pruned  time.Time
+	now     func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// idleTTL is how long an untouched bucket is kept; a full bucket carries no state worth keeping
+const idleTTL = 10 * time.Minute
+
+func New(rate float64, burst int) *Limiter {
+	if burst < 1 { burst = 1 }
+	return &Limiter{Rate: rate, Burst: burst, buckets: make(map[string]*bucket), now: time.Now}
+}
+
+// Allow takes one token from key's bucket, reporting whether one was available
+func (l *Limiter) Allow(key string) bool {
+	ok, _ := l.Reserve(key)
+	return ok
+}
+
+// Reserve is Allow that also returns how long until the next token when refused
+func (l *Limiter) Reserve(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok {
+		l.prune(now)
+		b = &bucket{tokens: float64(l.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(l.Burst), b.tokens+now.Sub(b.last).Seconds()*l.Rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	if l.Rate <= 0 { return false, idleTTL }
+	return false, time.Duration((1 - b.tokens) / l.Rate * float64(time.Second))
+}
+
+// Reset forgets key's bucket, restoring a full burst
+func (l *Limiter) Reset(key string) {
+	l.mu.Lock()
+	delete(l.buckets, key)
+	l.mu.Unlock()
+}
+
+func (l *Limiter) prune(now time.Time) {
+	if now.Sub(l.pruned) < time.Minute { return }
+	l.pruned = now
+	for k, b := range l.buckets {
+		if now.Sub(b.last) > idleTTL { delete(l.buckets, k) }
+	}
+}
+
+// Middleware refuses requests with 429 and Retry-After once key(r) runs out of tokens
+func (l *Limiter) Middleware(key func(*http.Request) string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ok, wait := l.Reserve(key(r)); !ok {
+			TooManyRequests(w, wait)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// TooManyRequests writes a 429 JSON error with a whole-second Retry-After header
+func TooManyRequests(w http.ResponseWriter, wait time.Duration) {
+	secs := int(math.Ceil(wait.Seconds()))
+	if secs < 1 { secs = 1 }
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error":"too many requests"})
+}
+
+// ClientIP returns the host part of r.RemoteAddr
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil { return r.RemoteAddr }
+	return host
+}
@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLimiter_BurstThenRefill(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := New(1, 2)
+	l.now = func() time.Time { return now }
+
+	if !l.Allow("a") || !l.Allow("a") {
+		t.Fatal("Burst of 2 should be allowed")
+	}
+	if ok, wait := l.Reserve("a"); ok || wait != time.Second {
+		t.Fatalf("Third request should wait 1s, got ok=%v wait=%v", ok, wait)
+	}
+	if !l.Allow("b") {
+		t.Fatal("Other keys should have their own bucket")
+	}
+
+	now = now.Add(time.Second)
+	if !l.Allow("a") {
+		t.Fatal("A token should refill after 1s")
+	}
+}
+
+func TestLimiter_Reset(t *testing.T) {
+	l := New(0, 1)
+	l.Allow("a")
+	if l.Allow("a") {
+		t.Fatal("Bucket should be empty")
+	}
+	l.Reset("a")
+	if !l.Allow("a") {
+		t.Fatal("Reset should restore the burst")
+	}
+}
+
+func TestMiddleware_ThrottlesRapidRequests(t *testing.T) {
+	l := New(0.1, 3)
+	h := l.Middleware(ClientIP, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusSwitchingProtocols)
+	}))
+
+	codes := make([]int, 0, 5)
+	for i := 0; i < 5; i++ {
+		req := httptest.NewRequest("GET", "/ws", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		codes = append(codes, rec.Code)
+		if rec.Code == http.StatusTooManyRequests && rec.Header().Get("Retry-After") == "" {
+			t.Fatal("429 should carry Retry-After")
+		}
+	}
+	want := []int{101, 101, 101, 429, 429}
+	for i := range want {
+		if codes[i] != want[i] {
+			t.Fatalf("Expected %v, got %v", want, codes)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/ws", nil)
+	req.RemoteAddr = "10.0.0.2:1234"
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusSwitchingProtocols {
+		t.Fatalf("A different client should not be throttled, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,78 @@
+package recognize
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/deliium/drawing-board/internal/clock"
+	"github.com/deliium/drawing-board/internal/metrics"
+)
+
+// ErrCircuitOpen is returned without contacting the backend while a Breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+var breakerTrips = metrics.NewCounter("recognizer_breaker_trips_total", "Times the remote recognizer circuit breaker opened.")
+
+// Breaker stops calling a failing backend: after Threshold consecutive failures it opens for
+// Cooldown, then lets a single trial call through. The trial closes it on success and reopens it
+// on failure. A nil Breaker allows every call.
+type Breaker struct {
+	Threshold int
+	Cooldown  time.Duration
+	Clock     clock.Clock // nil uses the wall clock
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	open     bool
+	trial    bool // a half-open trial call is in flight
+}
+
+// NewBreaker returns nil when threshold is 0 or less, which disables breaking
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	if threshold <= 0 { return nil }
+	return &Breaker{Threshold: threshold, Cooldown: cooldown}
+}
+
+// Allow reports whether a call may go ahead; every allowed call must be followed by Record or Cancel
+func (b *Breaker) Allow() bool {
+	if b == nil { return true }
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.open { return true }
+	if b.trial || clock.Or(b.Clock).Now().Sub(b.openedAt) < b.Cooldown { return false }
+	b.trial = true
+	return true
+}
+
+// Record feeds the outcome of an allowed call back into the breaker
+func (b *Breaker) Record(err error) {
+	if b == nil { return }
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+	if err == nil { b.failures, b.open = 0, false; return }
+	b.failures++
+	if b.open || b.failures >= b.Threshold {
+		if !b.open { breakerTrips.Inc(); log.Printf("Warning: recognizer circuit open after %d failures: %v", b.failures, err) }
+		b.open, b.openedAt = true, clock.Or(b.Clock).Now()
+	}
+}
+
+// Cancel releases an allowed call whose outcome says nothing about the backend, e.g. the caller gave up
+func (b *Breaker) Cancel() {
+	if b == nil { return }
+	b.mu.Lock()
+	b.trial = false
+	b.mu.Unlock()
+}
+
+// Open reports whether calls are currently being refused
+func (b *Breaker) Open() bool {
+	if b == nil { return false }
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.open
+}
@@ -0,0 +1,66 @@
+package recognize
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/deliium/drawing-board/internal/clock"
+)
+
+func TestBreaker_OpensAfterThresholdAndRecovers(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	b := NewBreaker(3, time.Minute)
+	b.Clock = fake
+	boom := errors.New("boom")
+	for i := 0; i < 3; i++ {
+		if !b.Allow() {
+			t.Fatalf("Call %d should be allowed before the threshold", i)
+		}
+		b.Record(boom)
+	}
+	if !b.Open() || b.Allow() {
+		t.Fatal("Expected the breaker to open after 3 failures")
+	}
+
+	fake.Advance(time.Minute)
+	if !b.Allow() {
+		t.Fatal("Expected one trial call after the cooldown")
+	}
+	if b.Allow() {
+		t.Fatal("Only one trial may be in flight")
+	}
+	b.Record(boom)
+	if !b.Open() || b.Allow() {
+		t.Fatal("A failed trial should reopen the breaker for another cooldown")
+	}
+
+	fake.Advance(time.Minute)
+	b.Allow()
+	b.Record(nil)
+	if b.Open() || !b.Allow() {
+		t.Fatal("A successful trial should close the breaker")
+	}
+}
+
+func TestBreaker_SuccessResetsFailures(t *testing.T) {
+	b := NewBreaker(2, time.Minute)
+	b.Record(errors.New("boom"))
+	b.Record(nil)
+	b.Record(errors.New("boom"))
+	if b.Open() {
+		t.Fatal("Failures must be consecutive to open the breaker")
+	}
+}
+
+func TestBreaker_NilAllowsEverything(t *testing.T) {
+	var b *Breaker
+	if NewBreaker(0, time.Minute) != nil {
+		t.Fatal("Expected threshold 0 to disable the breaker")
+	}
+	b.Record(errors.New("boom"))
+	b.Cancel()
+	if !b.Allow() || b.Open() {
+		t.Fatal("A nil breaker should always allow calls")
+	}
+}
@@ -0,0 +1,125 @@
+package recognize
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/deliium/drawing-board/internal/clock"
+	"github.com/deliium/drawing-board/internal/metrics"
+)
+
+var (
+	cacheHits   = metrics.NewCounter("recognize_cache_hits_total", "Recognitions answered from the cache.")
+	cacheMisses = metrics.NewCounter("recognize_cache_misses_total", "Recognitions that missed the cache or found an expired entry.")
+)
+
+type cacheEntry struct {
+	cands   []Candidate
+	expires time.Time
+}
+
+// CachedRecognizer remembers Inner's results for identical input for up to TTL
+type CachedRecognizer struct {
+	Inner Recognizer
+	TTL   time.Duration
+	Clock clock.Clock // nil uses the real clock
+
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]cacheEntry
+}
+
+func NewCachedRecognizer(inner Recognizer, ttl time.Duration) *CachedRecognizer {
+	return &CachedRecognizer{Inner: inner, TTL: ttl, entries: make(map[[sha256.Size]byte]cacheEntry)}
+}
+
+// cacheKey hashes everything that can change the result: the points and weights, the canvas size and topN.
+// Stroke colour and width never reach a recognizer (Stroke has no fields for them), so two drawings
+// differing only in those share an entry; a field added to Stroke must be hashed here too.
+func cacheKey(strokes []Stroke, width, height, topN int) [sha256.Size]byte {
+	h := sha256.New()
+	var buf [8]byte
+	put := func(v uint64) { binary.LittleEndian.PutUint64(buf[:], v); h.Write(buf[:]) }
+	put(uint64(width)); put(uint64(height)); put(uint64(topN)); put(uint64(len(strokes)))
+	for _, s := range strokes {
+		put(uint64(len(s.Points))); put(math.Float64bits(s.Weight))
+		for _, p := range s.Points { put(math.Float64bits(p.X)); put(math.Float64bits(p.Y)) }
+	}
+	var key [sha256.Size]byte
+	copy(key[:], h.Sum(nil))
+	return key
+}
+
+func (c *CachedRecognizer) Recognize(strokes []Stroke, width, height int, topN int) ([]Candidate, error) {
+	return c.RecognizeContext(context.Background(), strokes, width, height, topN)
+}
+
+// RecognizeContext answers from the cache or asks Inner bounded by ctx
+func (c *CachedRecognizer) RecognizeContext(ctx context.Context, strokes []Stroke, width, height int, topN int) ([]Candidate, error) {
+	key := cacheKey(strokes, width, height, topN)
+	now := clock.Or(c.Clock).Now()
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if ok && !now.Before(e.expires) { delete(c.entries, key); ok = false }
+	c.mu.Unlock()
+	if ok {
+		cacheHits.Inc()
+		return append([]Candidate(nil), e.cands...), nil
+	}
+	cacheMisses.Inc()
+
+	cands, err := RecognizeContext(ctx, c.Inner, strokes, width, height, topN)
+	if err != nil { return nil, err }
+	c.mu.Lock()
+	c.entries[key] = cacheEntry{cands: append([]Candidate(nil), cands...), expires: clock.Or(c.Clock).Now().Add(c.TTL)}
+	c.mu.Unlock()
+	return cands, nil
+}
+
+// RecognizeTensor passes through uncached; tensors are too large to be worth hashing per request
+func (c *CachedRecognizer) RecognizeTensor(tensor []float32, width, height, strokeCount, topN int) ([]Candidate, error) {
+	tr, ok := c.Inner.(TensorRecognizer)
+	if !ok { return nil, ErrTensorUnsupported }
+	return tr.RecognizeTensor(tensor, width, height, strokeCount, topN)
+}
+
+// Len reports how many entries are held, expired or not
+func (c *CachedRecognizer) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Sweep drops every expired entry and returns how many were removed
+func (c *CachedRecognizer) Sweep() int {
+	now := clock.Or(c.Clock).Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for k, e := range c.entries {
+		if !now.Before(e.expires) { delete(c.entries, k); n++ }
+	}
+	return n
+}
+
+// RunSweeper calls Sweep every interval until stop is closed, so entries that are never
+// looked up again do not pile up
+func (c *CachedRecognizer) RunSweeper(interval time.Duration, stop <-chan struct{}) {
+	if interval <= 0 { return }
+	ticker := clock.Or(c.Clock).NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C():
+			c.Sweep()
+		}
+	}
+}
+
+func (c *CachedRecognizer) Name() string { return c.Inner.Name() }
+func (c *CachedRecognizer) Close() error { return c.Inner.Close() }
@@ -0,0 +1,113 @@
+package recognize
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/deliium/drawing-board/internal/clock"
+)
+
+type countingRecognizer struct {
+	calls int
+}
+
+func (c *countingRecognizer) Recognize(strokes []Stroke, width, height int, topN int) ([]Candidate, error) {
+	c.calls++
+	return []Candidate{{Text: "一", Score: float64(c.calls)}}, nil
+}
+
+func (c *countingRecognizer) Close() error { return nil }
+func (c *countingRecognizer) Name() string { return "counting" }
+
+func newTestCache(ttl time.Duration) (*CachedRecognizer, *countingRecognizer, *clock.Fake) {
+	inner := &countingRecognizer{}
+	fake := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	c := NewCachedRecognizer(inner, ttl)
+	c.Clock = fake
+	return c, inner, fake
+}
+
+var cacheStrokes = []Stroke{{Points: []Point{{X: 10, Y: 10}, {X: 100, Y: 10}}}}
+
+func TestCachedRecognizer_HitWithinTTL(t *testing.T) {
+	c, inner, fake := newTestCache(time.Minute)
+	first, _ := c.Recognize(cacheStrokes, 300, 300, 5)
+	fake.Advance(30 * time.Second)
+	second, _ := c.Recognize(cacheStrokes, 300, 300, 5)
+	if inner.calls != 1 {
+		t.Fatalf("Expected 1 inner call, got %d", inner.calls)
+	}
+	if second[0].Score != first[0].Score {
+		t.Fatalf("Expected cached result, got %v then %v", first, second)
+	}
+	if _, _ = c.Recognize(cacheStrokes, 300, 300, 3); inner.calls != 2 {
+		t.Fatalf("Different topN should miss the cache, got %d calls", inner.calls)
+	}
+}
+
+func TestCachedRecognizer_RecomputesAfterTTL(t *testing.T) {
+	c, inner, fake := newTestCache(time.Minute)
+	c.Recognize(cacheStrokes, 300, 300, 5)
+	fake.Advance(time.Minute)
+	cands, err := c.Recognize(cacheStrokes, 300, 300, 5)
+	if err != nil {
+		t.Fatalf("Should not return error: %v", err)
+	}
+	if inner.calls != 2 || cands[0].Score != 2 {
+		t.Fatalf("Expected expired entry to be recomputed, got %d calls and %v", inner.calls, cands)
+	}
+}
+
+func TestCachedRecognizer_Sweep(t *testing.T) {
+	c, _, fake := newTestCache(time.Minute)
+	c.Recognize(cacheStrokes, 300, 300, 5)
+	fake.Advance(30 * time.Second)
+	c.Recognize(cacheStrokes, 200, 200, 5)
+	fake.Advance(40 * time.Second)
+	if n := c.Sweep(); n != 1 {
+		t.Fatalf("Expected 1 expired entry swept, got %d", n)
+	}
+	if c.Len() != 1 {
+		t.Fatalf("Expected the fresh entry to remain, got %d entries", c.Len())
+	}
+}
+
+func TestCachedRecognizer_RunSweeper(t *testing.T) {
+	c, _, fake := newTestCache(time.Minute)
+	c.Recognize(cacheStrokes, 300, 300, 5)
+	stop := make(chan struct{})
+	defer close(stop)
+	go c.RunSweeper(time.Minute, stop)
+	deadline := time.Now().Add(2 * time.Second)
+	for fake.Tickers() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	fake.Advance(time.Minute)
+	for c.Len() != 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if c.Len() != 0 {
+		t.Fatalf("Expected sweeper to evict the expired entry, got %d entries", c.Len())
+	}
+}
+
+func TestCachedRecognizer_WeightsAreKeyed(t *testing.T) {
+	c, inner, _ := newTestCache(time.Minute)
+	weighted := []Stroke{{Points: cacheStrokes[0].Points, Weight: 0.5}}
+	c.Recognize(cacheStrokes, 300, 300, 5)
+	if _, _ = c.Recognize(weighted, 300, 300, 5); inner.calls != 2 {
+		t.Fatalf("Expected differently weighted strokes to miss the cache, got %d calls", inner.calls)
+	}
+}
+
+// TestCacheKey_CoversStrokeFields fails when Stroke gains a field, such as a colour or width, that
+// cacheKey does not hash yet
+func TestCacheKey_CoversStrokeFields(t *testing.T) {
+	var fields []string
+	st := reflect.TypeOf(Stroke{})
+	for i := 0; i < st.NumField(); i++ { fields = append(fields, st.Field(i).Name) }
+	if !reflect.DeepEqual(fields, []string{"Points", "Weight"}) {
+		t.Fatalf("Expected cacheKey to be updated for Stroke fields %v", fields)
+	}
+}
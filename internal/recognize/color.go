@@ -0,0 +1,27 @@
+package recognize
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultColorLevels snaps each channel to 0, 85, 170 or 255: black, red, green and blue pens stay
+// apart while near-identical shades of one pen merge
+const DefaultColorLevels = 4
+
+// QuantizeColor snaps a #rgb or #rrggbb color to levels evenly spaced values per channel and
+// returns it as lowercase #rrggbb. Levels below 2 are treated as 2; 256 keeps colors exact.
+// ok is false when color cannot be parsed.
+func QuantizeColor(color string, levels int) (string, bool) {
+	hex := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(color)), "#")
+	if len(hex) == 3 { hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]}) }
+	if len(hex) != 6 { return "", false }
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil { return "", false }
+	levels = min(max(levels, 2), 256)
+	step := 255 / float64(levels-1)
+	snap := func(c uint64) int { return int(math.Round(math.Round(float64(c)/step) * step)) }
+	return fmt.Sprintf("#%02x%02x%02x", snap(v>>16&0xff), snap(v>>8&0xff), snap(v&0xff)), true
+}
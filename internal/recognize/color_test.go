@@ -0,0 +1,32 @@
+package recognize
+
+import "testing"
+
+func TestQuantizeColor(t *testing.T) {
+	cases := []struct {
+		in     string
+		levels int
+		want   string
+	}{
+		{"#000000", 4, "#000000"},
+		{"#202020", 4, "#000000"},
+		{"#E01010", 4, "#ff0000"},
+		{"#f00", 4, "#ff0000"},
+		{"#2a7fd4", 4, "#0055aa"},
+		{"#808080", 2, "#ffffff"},
+		{"#7f7f7f", 2, "#000000"},
+		{"#123456", 256, "#123456"},
+		{"#123456", 1, "#000000"},
+	}
+	for _, c := range cases {
+		got, ok := QuantizeColor(c.in, c.levels)
+		if !ok || got != c.want {
+			t.Fatalf("QuantizeColor(%q, %d): expected %s, got %s (ok=%v)", c.in, c.levels, c.want, got, ok)
+		}
+	}
+	for _, bad := range []string{"", "red", "#12345", "#gggggg"} {
+		if _, ok := QuantizeColor(bad, 4); ok {
+			t.Fatalf("Expected %q to be rejected", bad)
+		}
+	}
+}
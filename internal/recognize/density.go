@@ -0,0 +1,20 @@
+package recognize
+
+// DefaultMinDensity is the ink density cmd/server configures unless told otherwise: a few stray
+// dots on a typical canvas fall below it, a short line does not
+const DefaultMinDensity = 0.0001
+
+// InkDensity is the share of tensor pixels holding ink, counted with the same 0.1 cut as the
+// "density" feature
+func InkDensity(tensor []float32) float64 {
+	if len(tensor) == 0 { return 0 }
+	ink := 0
+	for _, v := range tensor {
+		if v > 0.1 { ink++ }
+	}
+	return float64(ink) / float64(len(tensor))
+}
+
+// nearlyBlank reports whether tensor holds less ink than min, in which case the recognizers
+// return no candidates rather than guess; min <= 0 never treats a board as blank
+func nearlyBlank(tensor []float32, min float64) bool { return min > 0 && InkDensity(tensor) < min }
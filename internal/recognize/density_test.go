@@ -0,0 +1,64 @@
+package recognize
+
+import "testing"
+
+// strayDot is a single tap, 9 ink pixels once rasterized
+var strayDot = []Stroke{{Points: []Point{{X: 200, Y: 200}}}}
+
+var horizontalLine = []Stroke{{Points: []Point{{X: 100, Y: 200}, {X: 300, Y: 200}}}}
+
+func TestInkDensity(t *testing.T) {
+	if got := InkDensity([]float32{0, 0.05, 0.5, 1}); got != 0.5 {
+		t.Fatalf("Expected density 0.5, got %v", got)
+	}
+	if got := InkDensity(nil); got != 0 {
+		t.Fatalf("Expected an empty tensor to have density 0, got %v", got)
+	}
+	if nearlyBlank(make([]float32, 10), 0) {
+		t.Fatalf("Expected a zero minimum never to treat a board as blank")
+	}
+}
+
+func TestSimpleRecognizer_NearlyBlankBoardHasNoCandidates(t *testing.T) {
+	recognizer := &SimpleRecognizer{MinDensity: DefaultMinDensity}
+	cands, err := recognizer.Recognize(strayDot, 400, 400, 5)
+	if err != nil {
+		t.Fatalf("Recognize failed: %v", err)
+	}
+	if len(cands) != 0 {
+		t.Fatalf("Expected no candidates for a stray dot, got %v", cands)
+	}
+	if cands, err := recognizer.Recognize(horizontalLine, 400, 400, 5); err != nil || len(cands) == 0 {
+		t.Fatalf("Expected candidates for a line, got %v, %v", cands, err)
+	}
+	// Without a canvas size the density cannot be measured, so the check is skipped
+	if cands, _ := recognizer.Recognize(strayDot, 0, 0, 5); len(cands) == 0 {
+		t.Fatalf("Expected candidates when the canvas size is unknown")
+	}
+}
+
+func TestONNXRecognizer_NearlyBlankBoardHasNoCandidates(t *testing.T) {
+	recognizer, err := NewONNXRecognizer("test_model.onnx")
+	if err != nil {
+		t.Fatalf("Failed to create recognizer: %v", err)
+	}
+	recognizer.MinDensity = DefaultMinDensity
+	if cands, err := recognizer.Recognize(strayDot, 400, 400, 5); err != nil || len(cands) != 0 {
+		t.Fatalf("Expected no candidates for a stray dot, got %v, %v", cands, err)
+	}
+	if cands, err := recognizer.Recognize(horizontalLine, 400, 400, 5); err != nil || len(cands) == 0 {
+		t.Fatalf("Expected candidates for a line, got %v, %v", cands, err)
+	}
+
+	dot, err := StrokesToTensor(strayDot, 400, 400)
+	if err != nil {
+		t.Fatalf("Failed to rasterize: %v", err)
+	}
+	if cands, err := recognizer.RecognizeTensor(dot, 400, 400, 1, 5); err != nil || len(cands) != 0 {
+		t.Fatalf("Expected no candidates for a stray dot tensor, got %v, %v", cands, err)
+	}
+	line, _ := StrokesToTensor(horizontalLine, 400, 400)
+	if cands, err := recognizer.RecognizeTensor(line, 400, 400, 1, 5); err != nil || len(cands) == 0 {
+		t.Fatalf("Expected candidates for a line tensor, got %v, %v", cands, err)
+	}
+}
@@ -0,0 +1,77 @@
+package recognize
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/deliium/drawing-board/internal/metrics"
+)
+
+// FallbackTotal counts every time recognition falls back to the simple recognizer
+var FallbackTotal = metrics.NewCounter("recognizer_fallback_total", "Number of times recognition fell back to the simple recognizer.")
+
+// RecordFallback increments the fallback counter and logs the reason
+func RecordFallback(reason string) {
+	FallbackTotal.Inc()
+	log.Printf("Warning: recognizer fallback: %s", reason)
+}
+
+// FallbackRecognizer runs Primary and switches to Fallback when it fails or exceeds Timeout
+type FallbackRecognizer struct {
+	Primary  Recognizer
+	Fallback Recognizer
+	Timeout  time.Duration
+}
+
+func NewFallbackRecognizer(primary, fallback Recognizer, timeout time.Duration) *FallbackRecognizer {
+	return &FallbackRecognizer{Primary: primary, Fallback: fallback, Timeout: timeout}
+}
+
+func (f *FallbackRecognizer) Recognize(strokes []Stroke, width, height int, topN int) ([]Candidate, error) {
+	return f.RecognizeContext(context.Background(), strokes, width, height, topN)
+}
+
+// RecognizeContext hands ctx to the primary. A caller that gives up gets ctx's error rather than
+// the fallback's answer, and is not counted as a fallback. The primary's context is cancelled
+// once RecognizeContext returns, so a context-aware primary that timed out stops working too.
+func (f *FallbackRecognizer) RecognizeContext(ctx context.Context, strokes []Stroke, width, height int, topN int) ([]Candidate, error) {
+	pctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type result struct { cands []Candidate; err error }
+	done := make(chan result, 1)
+	go func() {
+		cands, err := RecognizeContext(pctx, f.Primary, strokes, width, height, topN)
+		done <- result{cands, err}
+	}()
+
+	var timeout <-chan time.Time
+	if f.Timeout > 0 {
+		timer := time.NewTimer(f.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case res := <-done:
+		if res.err == nil { return res.cands, nil }
+		if ctx.Err() != nil { return nil, ctx.Err() }
+		RecordFallback(fmt.Sprintf("primary recognizer failed: %v", res.err))
+	case <-timeout:
+		cancel()
+		RecordFallback(fmt.Sprintf("primary recognizer timed out after %v", f.Timeout))
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return f.Fallback.Recognize(strokes, width, height, topN)
+}
+
+// Name is the primary's; Fallback only answers the requests the primary fails or is too slow for
+func (f *FallbackRecognizer) Name() string { return f.Primary.Name() }
+
+func (f *FallbackRecognizer) Close() error {
+	err := f.Primary.Close()
+	if ferr := f.Fallback.Close(); err == nil { err = ferr }
+	return err
+}
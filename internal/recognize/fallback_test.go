@@ -0,0 +1,124 @@
+package recognize
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type stubRecognizer struct {
+	delay time.Duration
+	err   error
+	cands []Candidate
+}
+
+func (s *stubRecognizer) Recognize(strokes []Stroke, width, height int, topN int) ([]Candidate, error) {
+	time.Sleep(s.delay)
+	return s.cands, s.err
+}
+
+func (s *stubRecognizer) Close() error { return nil }
+func (s *stubRecognizer) Name() string { return "stub" }
+
+func TestFallbackRecognizer_PrimarySucceeds(t *testing.T) {
+	primary := &stubRecognizer{cands: []Candidate{{Text: "十", Score: 0.9}}}
+	rec := NewFallbackRecognizer(primary, NewSimpleRecognizer(), time.Second)
+
+	before := FallbackTotal.Value()
+	candidates, err := rec.Recognize([]Stroke{{Points: []Point{{X: 1, Y: 1}}}}, 300, 300, 5)
+	if err != nil {
+		t.Fatalf("Should not return error: %v", err)
+	}
+	if len(candidates) != 1 || candidates[0].Text != "十" {
+		t.Fatalf("Expected primary candidates, got %v", candidates)
+	}
+	if FallbackTotal.Value() != before {
+		t.Fatal("Fallback counter should not change when primary succeeds")
+	}
+}
+
+func TestFallbackRecognizer_ErrorIncrementsCounter(t *testing.T) {
+	primary := &stubRecognizer{err: errors.New("boom")}
+	rec := NewFallbackRecognizer(primary, NewSimpleRecognizer(), time.Second)
+
+	before := FallbackTotal.Value()
+	strokes := []Stroke{{Points: []Point{{X: 10, Y: 10}, {X: 100, Y: 10}}}}
+	candidates, err := rec.Recognize(strokes, 300, 300, 5)
+	if err != nil {
+		t.Fatalf("Fallback should hide primary error: %v", err)
+	}
+	if len(candidates) == 0 {
+		t.Fatal("Fallback should return candidates")
+	}
+	if FallbackTotal.Value() != before+1 {
+		t.Fatalf("Expected fallback counter %d, got %d", before+1, FallbackTotal.Value())
+	}
+}
+
+func TestFallbackRecognizer_TimeoutIncrementsCounter(t *testing.T) {
+	primary := &stubRecognizer{delay: 200 * time.Millisecond}
+	rec := NewFallbackRecognizer(primary, NewSimpleRecognizer(), 10*time.Millisecond)
+
+	before := FallbackTotal.Value()
+	strokes := []Stroke{{Points: []Point{{X: 10, Y: 10}, {X: 100, Y: 10}}}}
+	if _, err := rec.Recognize(strokes, 300, 300, 5); err != nil {
+		t.Fatalf("Should not return error: %v", err)
+	}
+	if FallbackTotal.Value() != before+1 {
+		t.Fatalf("Expected fallback counter %d, got %d", before+1, FallbackTotal.Value())
+	}
+}
+
+func TestFallbackRecognizer_TimeoutCancelsPrimary(t *testing.T) {
+	primary := &ctxRecognizer{stopped: make(chan struct{})}
+	rec := NewFallbackRecognizer(primary, NewSimpleRecognizer(), 10*time.Millisecond)
+	strokes := []Stroke{{Points: []Point{{X: 10, Y: 10}, {X: 100, Y: 10}}}}
+	if _, err := rec.Recognize(strokes, 300, 300, 5); err != nil {
+		t.Fatalf("Should not return error: %v", err)
+	}
+	select {
+	case <-primary.stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the timed-out primary's context to be cancelled")
+	}
+}
+
+func TestFallbackRecognizer_NameIsPrimary(t *testing.T) {
+	f := NewFallbackRecognizer(&stubRecognizer{}, NewSimpleRecognizer(), 0)
+	if got := NewCachedRecognizer(f, time.Minute).Name(); got != "stub" {
+		t.Fatalf("Expected wrappers to report the primary, got %q", got)
+	}
+}
+
+// ctxRecognizer blocks until its context ends, then closes stopped when it is set
+type ctxRecognizer struct {
+	stubRecognizer
+	stopped chan struct{}
+}
+
+func (c *ctxRecognizer) RecognizeContext(ctx context.Context, strokes []Stroke, width, height int, topN int) ([]Candidate, error) {
+	<-ctx.Done()
+	if c.stopped != nil { close(c.stopped) }
+	return nil, ctx.Err()
+}
+
+func TestFallbackRecognizer_CallerDeadlineIsNotAFallback(t *testing.T) {
+	strokes := []Stroke{{Points: []Point{{X: 10, Y: 10}, {X: 100, Y: 10}}}}
+	for name, primary := range map[string]Recognizer{"context aware": &ctxRecognizer{}, "context unaware": &stubRecognizer{delay: time.Second}} {
+		rec := NewCachedRecognizer(NewFallbackRecognizer(primary, NewSimpleRecognizer(), 0), time.Minute)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		before, start := FallbackTotal.Value(), time.Now()
+		cands, err := RecognizeContext(ctx, rec, strokes, 300, 300, 5)
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) || cands != nil {
+			t.Fatalf("%s: expected the caller's deadline, got %v, %v", name, cands, err)
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Fatalf("%s: expected to give up at the deadline, took %v", name, elapsed)
+		}
+		if FallbackTotal.Value() != before || rec.Len() != 0 {
+			t.Fatalf("%s: expected no fallback and nothing cached", name)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package recognize
+
+import "math"
+
+// StrokeFilter drops accidental taps before recognition; zero values disable each check
+type StrokeFilter struct {
+	MinPoints int     // strokes with fewer points are ignored
+	MinLength float64 // strokes whose path is shorter than this (canvas pixels) are ignored
+}
+
+// Apply returns the strokes that pass the filter, keeping their order
+func (f StrokeFilter) Apply(strokes []Stroke) []Stroke {
+	if f.MinPoints <= 0 && f.MinLength <= 0 { return strokes }
+	out := make([]Stroke, 0, len(strokes))
+	for _, s := range strokes {
+		if len(s.Points) < f.MinPoints { continue }
+		if f.MinLength > 0 && PathLength(s) < f.MinLength { continue }
+		out = append(out, s)
+	}
+	return out
+}
+
+// PathLength is the sum of segment lengths along the stroke
+func PathLength(s Stroke) float64 {
+	total := 0.0
+	for i := 1; i < len(s.Points); i++ {
+		total += math.Hypot(s.Points[i].X-s.Points[i-1].X, s.Points[i].Y-s.Points[i-1].Y)
+	}
+	return total
+}
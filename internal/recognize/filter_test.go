@@ -0,0 +1,30 @@
+package recognize
+
+import "testing"
+
+func TestStrokeFilter_Apply(t *testing.T) {
+	tap := Stroke{Points: []Point{{X: 10, Y: 10}}}
+	jitter := Stroke{Points: []Point{{X: 10, Y: 10}, {X: 11, Y: 11}}}
+	line := Stroke{Points: []Point{{X: 0, Y: 0}, {X: 30, Y: 40}}}
+
+	got := StrokeFilter{MinPoints: 2, MinLength: 5}.Apply([]Stroke{tap, line, jitter})
+	if len(got) != 1 || PathLength(got[0]) != 50 {
+		t.Fatalf("Expected only the 50px line to survive, got %v", got)
+	}
+	if got := (StrokeFilter{}).Apply([]Stroke{tap, jitter}); len(got) != 2 {
+		t.Fatalf("Zero filter should keep everything, got %d", len(got))
+	}
+	if got := (StrokeFilter{MinPoints: 2}).Apply([]Stroke{tap, jitter}); len(got) != 1 {
+		t.Fatalf("Expected the one-point tap to be dropped, got %d", len(got))
+	}
+}
+
+func TestPathLength(t *testing.T) {
+	s := Stroke{Points: []Point{{X: 0, Y: 0}, {X: 3, Y: 4}, {X: 3, Y: 10}}}
+	if got := PathLength(s); got != 11 {
+		t.Fatalf("Expected 11, got %f", got)
+	}
+	if got := PathLength(Stroke{}); got != 0 {
+		t.Fatalf("Expected 0 for empty stroke, got %f", got)
+	}
+}
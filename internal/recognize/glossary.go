@@ -0,0 +1,96 @@
+package recognize
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Gloss is what a candidate means in one language: how it is read and a short description
+type Gloss struct {
+	Reading     string `json:"reading,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Glossary maps a language (a primary tag such as "en" or "ja") to the glosses of candidate texts
+type Glossary map[string]map[string]Gloss
+
+// DefaultLanguage answers requests whose Accept-Language names nothing the glossary has
+const DefaultLanguage = "en"
+
+// DefaultGlossary covers the characters the built-in recognizers suggest, in English and Japanese
+var DefaultGlossary = Glossary{
+	"en": {
+		"一": {"ichi", "one"}, "二": {"ni", "two"}, "三": {"san", "three"}, "十": {"juu", "ten"},
+		"丨": {"kon", "vertical stroke radical"}, "丶": {"chu", "dot radical"},
+		"人": {"hito", "person"}, "入": {"iru", "enter"}, "大": {"oo", "big"}, "太": {"futoi", "thick, plump"},
+		"小": {"chiisai", "small"}, "口": {"kuchi", "mouth"}, "回": {"kai", "times, to turn"}, "国": {"kuni", "country"},
+		"中": {"naka", "middle, inside"}, "田": {"ta", "rice field"}, "由": {"yu", "reason, cause"}, "日": {"hi", "sun, day"},
+		"川": {"kawa", "river"}, "林": {"hayashi", "grove"}, "森": {"mori", "forest"}, "生": {"sei", "life, birth"},
+		"学": {"gaku", "study, learning"}, "字": {"ji", "character, letter"}, "書": {"kaku", "to write"},
+	},
+	"ja": {
+		"一": {"いち", "数の一"}, "二": {"に", "数の二"}, "三": {"さん", "数の三"}, "十": {"じゅう", "数の十"},
+		"丨": {"こん", "部首「たてぼう」"}, "丶": {"ちゅ", "部首「てん」"},
+		"人": {"ひと", "ひと、人間"}, "入": {"いる", "はいる、いれる"}, "大": {"おお", "おおきい"}, "太": {"ふとい", "ふとい、おおきい"},
+		"小": {"ちいさい", "ちいさい"}, "口": {"くち", "くち、出入り口"}, "回": {"かい", "まわる、回数"}, "国": {"くに", "くに、国家"},
+		"中": {"なか", "なか、まんなか"}, "田": {"た", "たんぼ"}, "由": {"ゆ", "よし、理由"}, "日": {"ひ", "太陽、一日"},
+		"川": {"かわ", "かわ"}, "林": {"はやし", "はやし"}, "森": {"もり", "もり"}, "生": {"せい", "いきる、うまれる"},
+		"学": {"がく", "まなぶ、学問"}, "字": {"じ", "もじ"}, "書": {"かく", "かく、本"},
+	},
+}
+
+// LoadGlossary reads a JSON glossary ({"fr": {"十": {"reading": "...", "description": "dix"}}}) and
+// lays it over DefaultGlossary: its entries replace built-in ones and its languages are added
+func LoadGlossary(path string) (Glossary, error) {
+	data, err := os.ReadFile(path)
+	if err != nil { return nil, err }
+	var loaded Glossary
+	if err := json.Unmarshal(data, &loaded); err != nil { return nil, err }
+	out := Glossary{}
+	for _, g := range []Glossary{DefaultGlossary, loaded} {
+		for lang, entries := range g {
+			lang = primaryTag(lang)
+			if out[lang] == nil { out[lang] = map[string]Gloss{} }
+			for text, gloss := range entries { out[lang][text] = gloss }
+		}
+	}
+	return out, nil
+}
+
+// Match picks the language for an Accept-Language header: the highest-weighted range the glossary
+// has, comparing primary tags ("ja-JP" matches "ja"), else DefaultLanguage
+func (g Glossary) Match(acceptLanguage string) string {
+	type pref struct { lang string; q float64 }
+	var prefs []pref
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil { q = f }
+		}
+		if tag = primaryTag(tag); tag != "" && tag != "*" && q > 0 { prefs = append(prefs, pref{tag, q}) }
+	}
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+	for _, p := range prefs {
+		if _, ok := g[p.lang]; ok { return p.lang }
+	}
+	return DefaultLanguage
+}
+
+// Localize fills in the reading and description of each candidate in lang; Text is not touched,
+// and candidates the glossary does not know are left without either
+func (g Glossary) Localize(cands []Candidate, lang string) {
+	entries := g[lang]
+	for i := range cands {
+		gloss := entries[cands[i].Text]
+		cands[i].Reading, cands[i].Description = gloss.Reading, gloss.Description
+	}
+}
+
+func primaryTag(tag string) string {
+	tag, _, _ = strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+	return tag
+}
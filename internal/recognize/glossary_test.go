@@ -0,0 +1,59 @@
+package recognize
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGlossary_Match(t *testing.T) {
+	for header, want := range map[string]string{
+		"":                          "en",
+		"ja":                        "ja",
+		"ja-JP,en;q=0.8":            "ja",
+		"fr-CH, fr;q=0.9, ja;q=0.5": "ja",
+		"en;q=0.3, ja;q=0.7":        "ja",
+		"ja;q=0, en":                "en",
+		"*":                         "en",
+		"de":                        "en",
+	} {
+		if got := DefaultGlossary.Match(header); got != want {
+			t.Fatalf("Match(%q): expected %s, got %s", header, want, got)
+		}
+	}
+}
+
+func TestGlossary_LocalizeKeepsText(t *testing.T) {
+	cands := []Candidate{{Text: "十", Score: 0.9}, {Text: "？", Score: 0.1}}
+	DefaultGlossary.Localize(cands, "ja")
+	if cands[0].Text != "十" || cands[0].Reading != "じゅう" || cands[0].Description == "" {
+		t.Fatalf("Expected a Japanese gloss for 十, got %+v", cands[0])
+	}
+	if cands[1].Reading != "" || cands[1].Description != "" {
+		t.Fatalf("Expected no gloss for an unknown candidate, got %+v", cands[1])
+	}
+	DefaultGlossary.Localize(cands, "en")
+	if cands[0].Text != "十" || cands[0].Description != "ten" {
+		t.Fatalf("Expected relocalizing to replace the gloss, got %+v", cands[0])
+	}
+}
+
+func TestLoadGlossary_LaysOverDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "glossary.json")
+	if err := os.WriteFile(path, []byte(`{"FR": {"十": {"reading": "dix", "description": "dix"}}, "en": {"十": {"description": "ten (numeral)"}}}`), 0o644); err != nil {
+		t.Fatalf("Failed to write glossary: %v", err)
+	}
+	g, err := LoadGlossary(path)
+	if err != nil {
+		t.Fatalf("Failed to load glossary: %v", err)
+	}
+	if g["fr"]["十"].Description != "dix" || g["en"]["十"].Description != "ten (numeral)" || g["ja"]["十"].Reading != "じゅう" {
+		t.Fatalf("Expected the file laid over the defaults, got fr=%+v en=%+v ja=%+v", g["fr"]["十"], g["en"]["十"], g["ja"]["十"])
+	}
+	if DefaultGlossary["en"]["十"].Description != "ten" {
+		t.Fatal("Expected DefaultGlossary to be left alone")
+	}
+	if g.Match("fr-FR") != "fr" {
+		t.Fatal("Expected the loaded language to be matchable")
+	}
+}
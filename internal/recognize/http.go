@@ -0,0 +1,142 @@
+package recognize
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// Defaults for HTTPRecognizer backoff between attempts
+const (
+	DefaultHTTPBackoff    = 50 * time.Millisecond
+	DefaultHTTPMaxBackoff = 2 * time.Second
+)
+
+// HTTPRecognizer delegates recognition to a remote service that accepts the strokes as JSON
+// and answers with {"candidates":[{"text":..,"score":..}]}
+type HTTPRecognizer struct {
+	URL     string
+	Client  *http.Client
+	Timeout time.Duration // per attempt
+	Retries int           // extra attempts after the first failure
+	Deadline time.Duration // bounds all attempts and the waits between them; 0 is unbounded
+	Backoff  time.Duration // wait before the first retry, doubling for each one after; 0 retries at once
+	MaxBackoff time.Duration // caps the doubling; 0 uses DefaultHTTPMaxBackoff
+	Breaker  *Breaker      // optional; counts each Recognize call (after its retries) as one outcome
+}
+
+type httpRecognizeRequest struct {
+	Strokes []Stroke `json:"strokes"`
+	Width   int      `json:"width"`
+	Height  int      `json:"height"`
+	TopN    int      `json:"topN"`
+}
+
+type httpRecognizeResponse struct {
+	Candidates []Candidate `json:"candidates"`
+}
+
+func NewHTTPRecognizer(url string, timeout time.Duration, retries int) (*HTTPRecognizer, error) {
+	if url == "" {
+		return nil, fmt.Errorf("no recognizer url provided")
+	}
+	if timeout <= 0 { timeout = 5 * time.Second }
+	if retries < 0 { retries = 0 }
+	return &HTTPRecognizer{URL: url, Client: &http.Client{}, Timeout: timeout, Retries: retries, Backoff: DefaultHTTPBackoff}, nil
+}
+
+func (r *HTTPRecognizer) Name() string { return "http" }
+
+func (r *HTTPRecognizer) Close() error {
+	r.Client.CloseIdleConnections()
+	return nil
+}
+
+func (r *HTTPRecognizer) Recognize(strokes []Stroke, width, height int, topN int) ([]Candidate, error) {
+	return r.RecognizeContext(context.Background(), strokes, width, height, topN)
+}
+
+// RecognizeContext is Recognize bounded by ctx in addition to the per-attempt timeout and Deadline
+func (r *HTTPRecognizer) RecognizeContext(ctx context.Context, strokes []Stroke, width, height int, topN int) ([]Candidate, error) {
+	if topN <= 0 {
+		topN = 10
+	}
+
+	if len(strokes) == 0 {
+		return []Candidate{}, nil
+	}
+
+	body, err := json.Marshal(httpRecognizeRequest{Strokes: strokes, Width: width, Height: height, TopN: topN})
+	if err != nil { return nil, err }
+
+	if err := ctx.Err(); err != nil { return nil, err }
+	if !r.Breaker.Allow() { return nil, fmt.Errorf("http recognizer: %w", ErrCircuitOpen) }
+	caller := ctx
+	if r.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Deadline)
+		defer cancel()
+	}
+	cands, err := r.withRetries(ctx, body)
+	// A caller giving up says nothing about the backend's health
+	if caller.Err() == nil { r.Breaker.Record(err) } else { r.Breaker.Cancel() }
+	if err != nil { return nil, err }
+	if len(cands) > topN { cands = cands[:topN] }
+	return cands, nil
+}
+
+func (r *HTTPRecognizer) withRetries(ctx context.Context, body []byte) ([]Candidate, error) {
+	var lastErr error
+	for attempt := 0; attempt <= r.Retries; attempt++ {
+		if attempt > 0 {
+			wait := time.NewTimer(r.backoff(attempt))
+			select {
+			case <-ctx.Done():
+				wait.Stop()
+				return nil, fmt.Errorf("http recognizer: %w (last error: %v)", ctx.Err(), lastErr)
+			case <-wait.C:
+			}
+		}
+		if err := ctx.Err(); err != nil { return nil, err }
+		cands, err := r.attempt(ctx, body)
+		if err == nil { return cands, nil }
+		lastErr = err
+	}
+	return nil, fmt.Errorf("http recognizer: %w", lastErr)
+}
+
+// backoff is the wait before retry n (1-based): Backoff doubled n-1 times and capped, then
+// jittered into [d/2, d] so clients that failed together do not retry in lockstep
+func (r *HTTPRecognizer) backoff(n int) time.Duration {
+	if r.Backoff <= 0 { return 0 }
+	max := r.MaxBackoff
+	if max <= 0 { max = DefaultHTTPMaxBackoff }
+	d := r.Backoff
+	for i := 1; i < n && d < max; i++ { d *= 2 }
+	if d > max { d = max }
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func (r *HTTPRecognizer) attempt(ctx context.Context, body []byte) ([]Candidate, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(body))
+	if err != nil { return nil, err }
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.Client.Do(req)
+	if err != nil { return nil, err }
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil, fmt.Errorf("remote returned %d", resp.StatusCode)
+	}
+	var out httpRecognizeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil { return nil, fmt.Errorf("decode response: %w", err) }
+	if out.Candidates == nil { out.Candidates = []Candidate{} }
+	return out.Candidates, nil
+}
@@ -0,0 +1,211 @@
+package recognize
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testStrokes = []Stroke{{Points: []Point{{X: 10, Y: 10}, {X: 100, Y: 10}}}}
+
+func TestNewHTTPRecognizer_EmptyURL(t *testing.T) {
+	if _, err := NewHTTPRecognizer("", time.Second, 0); err == nil {
+		t.Fatal("Should return error for empty url")
+	}
+}
+
+func TestHTTPRecognizer_CannedCandidates(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req httpRecognizeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Bad request body: %v", err)
+		}
+		if len(req.Strokes) != 1 || req.Width != 300 || req.TopN != 2 {
+			t.Errorf("Unexpected request: %+v", req)
+		}
+		_ = json.NewEncoder(w).Encode(httpRecognizeResponse{Candidates: []Candidate{{Text: "一", Score: 0.9}, {Text: "ー", Score: 0.7}, {Text: "二", Score: 0.1}}})
+	}))
+	defer srv.Close()
+
+	rec, err := NewHTTPRecognizer(srv.URL, time.Second, 0)
+	if err != nil {
+		t.Fatalf("Failed to create recognizer: %v", err)
+	}
+	candidates, err := rec.Recognize(testStrokes, 300, 300, 2)
+	if err != nil {
+		t.Fatalf("Should not return error: %v", err)
+	}
+	if len(candidates) != 2 || candidates[0].Text != "一" {
+		t.Fatalf("Expected first two canned candidates, got %v", candidates)
+	}
+}
+
+func TestHTTPRecognizer_Timeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	rec, _ := NewHTTPRecognizer(srv.URL, 20*time.Millisecond, 0)
+	start := time.Now()
+	if _, err := rec.Recognize(testStrokes, 300, 300, 5); err == nil {
+		t.Fatal("Should return error when the remote times out")
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatalf("Timeout not honored, took %v", time.Since(start))
+	}
+}
+
+func TestHTTPRecognizer_RetriesServerErrors(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(httpRecognizeResponse{Candidates: []Candidate{{Text: "十", Score: 0.8}}})
+	}))
+	defer srv.Close()
+
+	rec, _ := NewHTTPRecognizer(srv.URL, time.Second, 2)
+	candidates, err := rec.Recognize(testStrokes, 300, 300, 5)
+	if err != nil {
+		t.Fatalf("Should succeed on third attempt: %v", err)
+	}
+	if len(candidates) != 1 || atomic.LoadInt32(&calls) != 3 {
+		t.Fatalf("Expected 1 candidate after 3 calls, got %v after %d", candidates, calls)
+	}
+}
+
+func TestHTTPRecognizer_PersistentError(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	rec, _ := NewHTTPRecognizer(srv.URL, time.Second, 1)
+	if _, err := rec.Recognize(testStrokes, 300, 300, 5); err == nil {
+		t.Fatal("Should return error when every attempt fails")
+	}
+	if atomic.LoadInt32(&calls) != 2 {
+		t.Fatalf("Expected 2 attempts, got %d", calls)
+	}
+}
+
+func TestHTTPRecognizer_CanceledContext(t *testing.T) {
+	rec, _ := NewHTTPRecognizer("http://127.0.0.1:0", time.Second, 3)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := rec.RecognizeContext(ctx, testStrokes, 300, 300, 5); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestHTTPRecognizer_BackoffGrowsBetweenRetries(t *testing.T) {
+	var stamps []time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stamps = append(stamps, time.Now())
+		if len(stamps) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(httpRecognizeResponse{Candidates: []Candidate{{Text: "十", Score: 0.8}}})
+	}))
+	defer srv.Close()
+
+	rec, _ := NewHTTPRecognizer(srv.URL, time.Second, 3)
+	rec.Backoff = 40 * time.Millisecond
+	if _, err := rec.Recognize(testStrokes, 300, 300, 5); err != nil {
+		t.Fatalf("Should succeed after transient failures: %v", err)
+	}
+	if len(stamps) != 3 {
+		t.Fatalf("Expected 3 attempts, got %d", len(stamps))
+	}
+	// Jitter keeps each wait within [d/2, d]: >= 20ms, then >= 40ms once doubled
+	if first, second := stamps[1].Sub(stamps[0]), stamps[2].Sub(stamps[1]); first < 20*time.Millisecond || second < 40*time.Millisecond {
+		t.Fatalf("Expected exponential backoff, waited %v then %v", first, second)
+	}
+}
+
+func TestHTTPRecognizer_BackoffIsCappedAndJittered(t *testing.T) {
+	rec := &HTTPRecognizer{Backoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}
+	for n := 1; n <= 6; n++ {
+		want := 100 * time.Millisecond << (n - 1)
+		if want > 300*time.Millisecond { want = 300 * time.Millisecond }
+		if d := rec.backoff(n); d < want/2 || d > want {
+			t.Fatalf("Retry %d: expected a wait in [%v, %v], got %v", n, want/2, want, d)
+		}
+	}
+	if d := (&HTTPRecognizer{}).backoff(3); d != 0 {
+		t.Fatalf("Expected no wait without a backoff, got %v", d)
+	}
+}
+
+func TestHTTPRecognizer_TotalDeadline(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	rec, _ := NewHTTPRecognizer(srv.URL, time.Second, 20)
+	rec.Backoff, rec.Deadline = 30*time.Millisecond, 120*time.Millisecond
+	start := time.Now()
+	_, err := rec.Recognize(testStrokes, 300, 300, 5)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the total deadline to end the retries, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("Deadline not honored, took %v", elapsed)
+	}
+	if n := atomic.LoadInt32(&calls); n >= 21 {
+		t.Fatalf("Expected the deadline to cut retries short, got %d attempts", n)
+	}
+}
+
+func TestHTTPRecognizer_BreakerTripsAndFallsBack(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	remote, _ := NewHTTPRecognizer(srv.URL, time.Second, 1)
+	remote.Backoff = time.Millisecond
+	remote.Breaker = NewBreaker(2, time.Hour)
+	rec := NewFallbackRecognizer(remote, NewSimpleRecognizer(), time.Second)
+	for i := 0; i < 2; i++ {
+		if cands, err := rec.Recognize(testStrokes, 300, 300, 5); err != nil || len(cands) == 0 {
+			t.Fatalf("Call %d: expected simple recognizer candidates, got %v (%v)", i, cands, err)
+		}
+	}
+	if atomic.LoadInt32(&calls) != 4 || !remote.Breaker.Open() {
+		t.Fatalf("Expected 2 calls of 2 attempts to open the breaker, got %d attempts", calls)
+	}
+
+	before := FallbackTotal.Value()
+	if _, err := remote.Recognize(testStrokes, 300, 300, 5); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected ErrCircuitOpen while open, got %v", err)
+	}
+	if cands, err := rec.Recognize(testStrokes, 300, 300, 5); err != nil || len(cands) == 0 {
+		t.Fatalf("Expected the fallback to answer while open, got %v (%v)", cands, err)
+	}
+	if atomic.LoadInt32(&calls) != 4 {
+		t.Fatalf("An open breaker must not reach the remote, got %d attempts", calls)
+	}
+	if FallbackTotal.Value() != before+1 {
+		t.Fatal("Expected the open circuit to count as a fallback")
+	}
+}
@@ -0,0 +1,69 @@
+package recognize
+
+import (
+	"fmt"
+	"image"
+	_ "image/jpeg" // register decoders for the formats ParseImageFormats accepts
+	_ "image/png"
+	"sort"
+	"strings"
+)
+
+// ImageFormats is the set of image.DecodeConfig format names accepted for image recognition
+type ImageFormats map[string]bool
+
+// DefaultImageFormats is used when no set is configured
+var DefaultImageFormats = ImageFormats{"png": true, "jpeg": true}
+
+// ParseImageFormats reads a list like "png,jpeg"; "jpg" is accepted as an alias for jpeg
+func ParseImageFormats(spec string) (ImageFormats, error) {
+	out := ImageFormats{}
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "":
+			continue
+		case "png", "jpeg":
+			out[name] = true
+		case "jpg":
+			out["jpeg"] = true
+		default:
+			return nil, fmt.Errorf("unknown image format %q (want png or jpeg)", name)
+		}
+	}
+	if len(out) == 0 { return nil, fmt.Errorf("no image formats allowed") }
+	return out, nil
+}
+
+// String lists the formats in a stable order, e.g. "jpeg,png"
+func (f ImageFormats) String() string {
+	names := make([]string, 0, len(f))
+	for name := range f { names = append(names, name) }
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// ImageToTensor converts img to a row-major ink tensor (1 is ink) over a white background, so both
+// dark-on-light scans and strokes on a transparent canvas work. Images larger than MaxTensorSide
+// are box-downscaled by a whole factor until they fit.
+func ImageToTensor(img image.Image) (tensor []float32, width, height int) {
+	b := img.Bounds()
+	factor := 1
+	for (b.Dx()+factor-1)/factor > MaxTensorSide || (b.Dy()+factor-1)/factor > MaxTensorSide { factor++ }
+	width, height = (b.Dx()+factor-1)/factor, (b.Dy()+factor-1)/factor
+	tensor = make([]float32, width*height)
+	counts := make([]float32, width*height)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			// RGBA is alpha-premultiplied, so adding the uncovered white gives the composited color
+			r, g, bl, a := img.At(x, y).RGBA()
+			white := float64(0xffff - a)
+			lum := (0.299*(float64(r)+white) + 0.587*(float64(g)+white) + 0.114*(float64(bl)+white)) / 0xffff
+			i := ((y-b.Min.Y)/factor)*width + (x-b.Min.X)/factor
+			tensor[i] += float32(1 - lum)
+			counts[i]++
+		}
+	}
+	for i := range tensor { tensor[i] = min(max(tensor[i]/counts[i], 0), 1) }
+	return tensor, width, height
+}
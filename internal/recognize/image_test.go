@@ -0,0 +1,54 @@
+package recognize
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestParseImageFormats(t *testing.T) {
+	f, err := ParseImageFormats(" PNG, jpg ")
+	if err != nil {
+		t.Fatalf("Should not return error: %v", err)
+	}
+	if f.String() != "jpeg,png" {
+		t.Fatalf("Expected jpeg,png, got %q", f.String())
+	}
+	for _, spec := range []string{"webp", "bmp", ""} {
+		if _, err := ParseImageFormats(spec); err == nil {
+			t.Fatalf("Expected error for %q", spec)
+		}
+	}
+}
+
+func TestImageToTensor_InkOnTransparentAndWhite(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, color.NRGBA{0, 0, 0, 255}) // opaque black ink
+	img.Set(1, 0, color.NRGBA{0, 0, 0, 0})   // transparent reads as white paper
+	tensor, w, h := ImageToTensor(img)
+	if w != 2 || h != 1 {
+		t.Fatalf("Expected 2x1, got %dx%d", w, h)
+	}
+	if tensor[0] < 0.99 || tensor[1] > 0.01 {
+		t.Fatalf("Expected ink then blank, got %v", tensor)
+	}
+}
+
+func TestImageToTensor_DownscalesToMaxSide(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 1200, 300))
+	for i := range img.Pix { img.Pix[i] = 255 }
+	for y := 0; y < 300; y++ { img.Pix[y*img.Stride] = 0 } // a 1px black column at x=0
+	tensor, w, h := ImageToTensor(img)
+	if w > MaxTensorSide || h > MaxTensorSide {
+		t.Fatalf("Expected at most %d per side, got %dx%d", MaxTensorSide, w, h)
+	}
+	if w != 400 || h != 100 {
+		t.Fatalf("Expected a 3x downscale to 400x100, got %dx%d", w, h)
+	}
+	if err := ValidateTensor(tensor, w, h); err != nil {
+		t.Fatalf("Downscaled tensor should be valid: %v", err)
+	}
+	if v := tensor[0]; v < 0.3 || v > 0.4 {
+		t.Fatalf("Expected the ink column averaged over 3 pixels (~0.33), got %f", v)
+	}
+}
@@ -1,12 +1,48 @@
 package recognize
 
+import "context"
+
 // Recognizer interface for different recognition implementations
 type Recognizer interface {
 	Recognize(strokes []Stroke, width, height int, topN int) ([]Candidate, error)
 	Close() error
+	Name() string // implementation serving requests, e.g. "onnx" or "simple", for diagnostics
+}
+
+// ContextRecognizer is implemented by recognizers that can give up when their caller does
+type ContextRecognizer interface {
+	RecognizeContext(ctx context.Context, strokes []Stroke, width, height int, topN int) ([]Candidate, error)
+}
+
+// RecognizeContext runs rec bounded by ctx when it is a ContextRecognizer; any other recognizer
+// runs to completion once started
+func RecognizeContext(ctx context.Context, rec Recognizer, strokes []Stroke, width, height int, topN int) ([]Candidate, error) {
+	if err := ctx.Err(); err != nil { return nil, err }
+	if cr, ok := rec.(ContextRecognizer); ok { return cr.RecognizeContext(ctx, strokes, width, height, topN) }
+	return rec.Recognize(strokes, width, height, topN)
 }
 
+var (
+	_ ContextRecognizer = (*HTTPRecognizer)(nil)
+	_ ContextRecognizer = (*FallbackRecognizer)(nil)
+	_ ContextRecognizer = (*CachedRecognizer)(nil)
+	_ Recognizer = (*ONNXRecognizer)(nil)
+	_ Recognizer = (*SimpleRecognizer)(nil)
+	_ Recognizer = (*HTTPRecognizer)(nil)
+	_ Recognizer = (*FallbackRecognizer)(nil)
+	_ Recognizer = (*CachedRecognizer)(nil)
+)
+
 // Types for stroke recognition
 type Point struct { X float64 `json:"x"`; Y float64 `json:"y"` }
-type Stroke struct { Points []Point `json:"points"` }
-type Candidate struct { Text string `json:"text"`; Score float64 `json:"score"` }
+type Stroke struct {
+	Points []Point `json:"points"`
+	Weight float64 `json:"weight,omitempty"` // recency weight in (0,1]; 0 counts as 1
+}
+type Candidate struct {
+	Text string `json:"text"`
+	Score float64 `json:"score"`
+	StrokeOrderScore *float64 `json:"strokeOrderScore,omitempty"` // set when stroke order scoring was requested and a template exists
+	Reading string `json:"reading,omitempty"` // in the requested language, see Glossary.Localize
+	Description string `json:"description,omitempty"`
+}
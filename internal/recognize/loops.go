@@ -0,0 +1,63 @@
+package recognize
+
+// detectLoops counts enclosed background regions (the holes in 口, 回, O, ...).
+// Background reachable from the border is flood-filled away; every remaining background
+// component big enough not to be a gap between ink pixels counts as one loop.
+func (r *ONNXRecognizer) detectLoops(tensor []float32, width, height int) int {
+	if !tensorFits(tensor, width, height) {
+		return 0
+	}
+	minArea := width * height / 2000
+	if minArea < 4 {
+		minArea = 4
+	}
+
+	seen := make([]bool, width*height)
+	stack := make([]int, 0, 64)
+	// fill marks the 4-connected background component containing start and returns its size
+	fill := func(start int) int {
+		size := 0
+		stack = append(stack[:0], start)
+		seen[start] = true
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			size++
+			x, y := i%width, i/width
+			for _, n := range [4][2]int{{x - 1, y}, {x + 1, y}, {x, y - 1}, {x, y + 1}} {
+				if n[0] < 0 || n[0] >= width || n[1] < 0 || n[1] >= height {
+					continue
+				}
+				j := n[1]*width + n[0]
+				if !seen[j] && tensor[j] <= 0.1 {
+					seen[j] = true
+					stack = append(stack, j)
+				}
+			}
+		}
+		return size
+	}
+
+	for x := 0; x < width; x++ {
+		for _, y := range []int{0, height - 1} {
+			if i := y*width + x; !seen[i] && tensor[i] <= 0.1 {
+				fill(i)
+			}
+		}
+	}
+	for y := 0; y < height; y++ {
+		for _, x := range []int{0, width - 1} {
+			if i := y*width + x; !seen[i] && tensor[i] <= 0.1 {
+				fill(i)
+			}
+		}
+	}
+
+	loops := 0
+	for i := range tensor[:width*height] {
+		if !seen[i] && tensor[i] <= 0.1 && fill(i) >= minArea {
+			loops++
+		}
+	}
+	return loops
+}
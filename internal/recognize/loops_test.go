@@ -0,0 +1,79 @@
+package recognize
+
+import (
+	"math"
+	"testing"
+)
+
+func polyline(pts ...Point) Stroke { return Stroke{Points: pts} }
+
+func circle(cx, cy, r float64) Stroke {
+	var pts []Point
+	for i := 0; i <= 36; i++ {
+		a := float64(i) / 36 * 2 * math.Pi
+		pts = append(pts, Point{X: cx + r*math.Cos(a), Y: cy + r*math.Sin(a)})
+	}
+	return Stroke{Points: pts}
+}
+
+func loopsFor(t *testing.T, strokes ...Stroke) int {
+	t.Helper()
+	recognizer, err := NewONNXRecognizer("test_model.onnx")
+	if err != nil {
+		t.Fatalf("Failed to create recognizer: %v", err)
+	}
+	tensor, err := recognizer.strokesToTensor(strokes, 300, 300)
+	if err != nil {
+		t.Fatalf("Failed to rasterize: %v", err)
+	}
+	return recognizer.detectLoops(tensor, 300, 300)
+}
+
+func TestDetectLoops(t *testing.T) {
+	square := polyline(Point{X: 50, Y: 50}, Point{X: 250, Y: 50}, Point{X: 250, Y: 250}, Point{X: 50, Y: 250}, Point{X: 50, Y: 50})
+	if n := loopsFor(t, square); n != 1 {
+		t.Fatalf("Expected 1 loop for a square, got %d", n)
+	}
+	if n := loopsFor(t, circle(150, 150, 80)); n != 1 {
+		t.Fatalf("Expected 1 loop for a circle, got %d", n)
+	}
+	plus := []Stroke{polyline(Point{X: 50, Y: 150}, Point{X: 250, Y: 150}), polyline(Point{X: 150, Y: 50}, Point{X: 150, Y: 250})}
+	if n := loopsFor(t, plus...); n != 0 {
+		t.Fatalf("Expected 0 loops for a plus sign, got %d", n)
+	}
+	nested := []Stroke{square, polyline(Point{X: 110, Y: 110}, Point{X: 190, Y: 110}, Point{X: 190, Y: 190}, Point{X: 110, Y: 190}, Point{X: 110, Y: 110})}
+	if n := loopsFor(t, nested...); n != 2 {
+		t.Fatalf("Expected 2 loops for a box in a box, got %d", n)
+	}
+	field := []Stroke{square, polyline(Point{X: 50, Y: 150}, Point{X: 250, Y: 150}), polyline(Point{X: 150, Y: 50}, Point{X: 150, Y: 250})}
+	if n := loopsFor(t, field...); n != 4 {
+		t.Fatalf("Expected 4 loops for 田, got %d", n)
+	}
+}
+
+func TestDetectLoops_OpenShape(t *testing.T) {
+	// A square missing its left side encloses nothing
+	cup := polyline(Point{X: 50, Y: 50}, Point{X: 250, Y: 50}, Point{X: 250, Y: 250}, Point{X: 50, Y: 250})
+	if n := loopsFor(t, cup); n != 0 {
+		t.Fatalf("Expected 0 loops for an open shape, got %d", n)
+	}
+}
+
+func TestONNXRecognizer_Recognize_Square(t *testing.T) {
+	recognizer, err := NewONNXRecognizer("test_model.onnx")
+	if err != nil {
+		t.Fatalf("Failed to create recognizer: %v", err)
+	}
+	square := []Stroke{
+		polyline(Point{X: 50, Y: 50}, Point{X: 50, Y: 250}),
+		polyline(Point{X: 50, Y: 50}, Point{X: 250, Y: 50}, Point{X: 250, Y: 250}),
+		polyline(Point{X: 50, Y: 250}, Point{X: 250, Y: 250}),
+	}
+	cands, err := recognizer.Recognize(square, 300, 300, 3)
+	if err != nil {
+		t.Fatalf("Should not return error: %v", err)
+	}
+	if len(cands) == 0 || cands[0].Text != "口" {
+		t.Fatalf("Expected 口 first, got %v", cands)
+	}
+}
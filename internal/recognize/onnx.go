@@ -1,53 +1,229 @@
 package recognize
 
 import (
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
+	"io/fs"
+	"log"
 	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 
+	"github.com/deliium/drawing-board/internal/raster"
 	"github.com/yalue/onnxruntime_go"
 )
 
 
+// Backends reported by ONNXRecognizer.Backend
+const (
+	BackendModel    = "model"    // inference through onnxruntime
+	BackendPatterns = "patterns" // feature heuristics, used when the model could not be loaded
+)
+
 type ONNXRecognizer struct {
 	session *onnxruntime_go.Session[float32]
 	modelPath string
 	inputName string
 	outputName string
 	inputShape []int64
+	labels []string // one per output logit, read from the file next to the model
+	loadErr error // why the model is not in use; nil when serving from it
+	DebugLog *log.Logger // optional; receives the tensor dump of every pattern-based recognition, nil logs nothing
+	NoStrokeTieBreak bool // keep equal-score candidates in order instead of preferring a matching canonical stroke count
+	MinDensity float64 // share of the canvas that must hold ink before candidates are suggested, see InkDensity; 0 disables
+
+	mu     sync.Mutex // a session runs on its bound tensors, so one inference at a time
+	input  *onnxruntime_go.Tensor[float32]
+	output *onnxruntime_go.Tensor[float32]
 }
 
+var (
+	runtimeLibrary string
+	runtimeOnce    sync.Once
+	runtimeErr     error
+)
+
+// SetONNXRuntimeLibrary sets the onnxruntime shared library to load; call it before the first
+// NewONNXRecognizer. Empty keeps the platform default (onnxruntime.so on the loader path).
+func SetONNXRuntimeLibrary(path string) { runtimeLibrary = path }
+
+// initRuntime loads the shared library once per process; the environment is never torn down
+// because further recognizers may be built on it
+func initRuntime() error {
+	runtimeOnce.Do(func() {
+		if runtimeLibrary != "" { onnxruntime_go.SetSharedLibraryPath(runtimeLibrary) }
+		runtimeErr = onnxruntime_go.InitializeEnvironment()
+	})
+	return runtimeErr
+}
+
+// NewONNXRecognizer loads modelPath and its labels. A model that cannot be loaded (missing file,
+// no runtime library, bad labels) is not an error: the recognizer falls back to pattern analysis
+// and Backend reports which path is taken.
 func NewONNXRecognizer(modelPath string) (*ONNXRecognizer, error) {
-	// Check if the model file exists and is valid
 	if modelPath == "" {
 		return nil, fmt.Errorf("no model path provided")
 	}
-	
-	// For now, we'll use the improved pattern-based recognition
-	// In the future, this could load a real ONNX model
-	fmt.Printf("ONNX Recognizer initialized with model path: %s\n", modelPath)
-	fmt.Printf("Using advanced pattern-based recognition (ONNX model loading not implemented yet)\n")
-	
-	return &ONNXRecognizer{
-		session: nil,
+	r := &ONNXRecognizer{
 		modelPath: modelPath,
 		inputName: "input",
-		outputName: "output", 
+		outputName: "output",
 		inputShape: []int64{1, 1, 28, 28}, // MNIST-like input shape
-	}, nil
+	}
+	if r.loadErr = r.load(); r.loadErr != nil {
+		log.Printf("Warning: ONNX model %s not loaded, using pattern-based recognition: %v", modelPath, r.loadErr)
+	}
+	return r, nil
 }
 
+// load starts a session whose output is one logit per label, shaped {1, len(labels)}
+func (r *ONNXRecognizer) load() error {
+	if _, err := os.Stat(r.modelPath); err != nil { return err }
+	labels, err := loadLabels(r.modelPath)
+	if err != nil { return err }
+	if err := initRuntime(); err != nil { return fmt.Errorf("onnxruntime: %w", err) }
+	input, err := onnxruntime_go.NewEmptyTensor[float32](onnxruntime_go.NewShape(r.inputShape...))
+	if err != nil { return err }
+	output, err := onnxruntime_go.NewEmptyTensor[float32](onnxruntime_go.NewShape(1, int64(len(labels))))
+	if err != nil { input.Destroy(); return err }
+	session, err := onnxruntime_go.NewSession[float32](r.modelPath, []string{r.inputName}, []string{r.outputName}, []*onnxruntime_go.Tensor[float32]{input}, []*onnxruntime_go.Tensor[float32]{output})
+	if err != nil { input.Destroy(); output.Destroy(); return err }
+	r.session, r.input, r.output, r.labels = session, input, output, labels
+	return nil
+}
+
+// labelPaths lists where the labels for a model may live: model.labels, then labels.txt beside it
+func labelPaths(modelPath string) []string {
+	return []string{strings.TrimSuffix(modelPath, filepath.Ext(modelPath)) + ".labels", filepath.Join(filepath.Dir(modelPath), "labels.txt")}
+}
+
+// loadLabels reads one label per line; blank lines are skipped
+func loadLabels(modelPath string) ([]string, error) {
+	for _, p := range labelPaths(modelPath) {
+		data, err := os.ReadFile(p)
+		if errors.Is(err, fs.ErrNotExist) { continue }
+		if err != nil { return nil, err }
+		var labels []string
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" { labels = append(labels, line) }
+		}
+		if len(labels) == 0 { return nil, fmt.Errorf("label file %s is empty", p) }
+		return labels, nil
+	}
+	return nil, fmt.Errorf("no label file (tried %s)", strings.Join(labelPaths(modelPath), ", "))
+}
+
+// Backend reports whether recognition runs the model or the pattern fallback
+func (r *ONNXRecognizer) Backend() string {
+	if r.session != nil { return BackendModel }
+	return BackendPatterns
+}
+
+// LoadError is why the model is not in use, nil when Backend is BackendModel
+func (r *ONNXRecognizer) LoadError() error { return r.loadErr }
+
+// Name is "onnx" whichever Backend serves; Backend tells the model from the pattern heuristics
+func (r *ONNXRecognizer) Name() string { return "onnx" }
+
 func (r *ONNXRecognizer) Close() error {
-	if r.session != nil {
-		r.session.Destroy()
+	if r.session == nil { return nil }
+	err := r.session.Destroy()
+	r.input.Destroy()
+	r.output.Destroy()
+	r.session = nil
+	return err
+}
+
+// infer downscales a width*height tensor to the model input and returns the topN labels by softmax;
+// strokeCount breaks score ties, 0 when unknown
+func (r *ONNXRecognizer) infer(tensor []float32, width, height, strokeCount, topN int) ([]Candidate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copy(r.input.GetData(), r.modelInput(tensor, width, height))
+	if err := r.session.Run(); err != nil { return nil, fmt.Errorf("onnx inference: %w", err) }
+	return logitsToCandidates(r.output.GetData(), r.labels, strokeCount, !r.NoStrokeTieBreak, topN), nil
+}
+
+// modelInput downscales a full-resolution width*height tensor to the model's inputShape
+// (height inputShape[2], width inputShape[3]); the full-size tensor stays with the pattern analyzer
+func (r *ONNXRecognizer) modelInput(tensor []float32, width, height int) []float32 {
+	return resizeTensor(tensor, width, height, int(r.inputShape[3]), int(r.inputShape[2]))
+}
+
+// resizeTensor area-averages a row-major width*height tensor onto an outW*outH grid. The aspect
+// ratio is kept: the scaled image is centered and the leftover border is padded with zeros.
+func resizeTensor(tensor []float32, width, height, outW, outH int) []float32 {
+	out := make([]float32, outW*outH)
+	if !tensorFits(tensor, width, height) { return out }
+	scale := math.Min(float64(outW)/float64(width), float64(outH)/float64(height))
+	sw, sh := max(1, int(math.Round(float64(width)*scale))), max(1, int(math.Round(float64(height)*scale)))
+	ox, oy := (outW-sw)/2, (outH-sh)/2
+	counts := make([]float32, outW*outH)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := (oy+y*sh/height)*outW + ox + x*sw/width
+			out[i] += tensor[y*width+x]
+			counts[i]++
+		}
 	}
-	// Skip ONNX cleanup for mock implementation
-	return nil
+	for i := range out {
+		if counts[i] > 0 { out[i] /= counts[i] }
+	}
+	return out
+}
+
+// logitsToCandidates applies a softmax and keeps the topN labels, best first; see rankCandidates
+// for strokeCount and tieBreak
+func logitsToCandidates(logits []float32, labels []string, strokeCount int, tieBreak bool, topN int) []Candidate {
+	n := min(len(logits), len(labels))
+	if n == 0 { return []Candidate{} }
+	maxLogit := logits[0]
+	for _, l := range logits[:n] { maxLogit = max(maxLogit, l) }
+	cands := make([]Candidate, n)
+	sum := 0.0
+	for i := 0; i < n; i++ {
+		e := math.Exp(float64(logits[i] - maxLogit))
+		cands[i] = Candidate{Text: labels[i], Score: e}
+		sum += e
+	}
+	for i := range cands { cands[i].Score /= sum }
+	rankCandidates(cands, strokeCount, tieBreak)
+	if len(cands) > topN { cands = cands[:topN] }
+	return cands
+}
+
+// clipSegment returns the parameter range [t0, t1] of the segment (x1,y1)-(x2,y2) that lies
+// within a pixel of a width*height canvas (Liang-Barsky), or visible false when none of it does
+func clipSegment(x1, y1, x2, y2 float64, width, height int) (t0, t1 float64, visible bool) {
+	t0, t1 = 0, 1
+	dx, dy := x2-x1, y2-y1
+	for _, e := range [4][2]float64{{-dx, x1 + 1}, {dx, float64(width) - x1}, {-dy, y1 + 1}, {dy, float64(height) - y1}} {
+		p, q := e[0], e[1]
+		if p == 0 {
+			if q < 0 { return 0, 0, false }
+			continue
+		}
+		r := q / p
+		if p < 0 { t0 = math.Max(t0, r) } else { t1 = math.Min(t1, r) }
+		if t0 > t1 { return 0, 0, false }
+	}
+	return t0, t1, true
 }
 
-// Convert strokes to a normalized image tensor
 func (r *ONNXRecognizer) strokesToTensor(strokes []Stroke, width, height int) ([]float32, error) {
+	return StrokesToTensor(strokes, width, height)
+}
+
+// StrokesToTensor rasterizes strokes onto a width*height row-major tensor the way the ONNX
+// recognizer sees them: ink is 1 on a 0 background, fainter for recency-weighted older strokes
+func StrokesToTensor(strokes []Stroke, width, height int) ([]float32, error) {
+	if err := raster.Check(width, height); err != nil {
+		return nil, err
+	}
 	// Create a grayscale image
 	img := image.NewGray(image.Rect(0, 0, width, height))
 	
@@ -56,6 +232,8 @@ func (r *ONNXRecognizer) strokesToTensor(strokes []Stroke, width, height int) ([
 		if len(stroke.Points) < 1 {
 			continue
 		}
+		// Older strokes are drawn fainter when recency weighted; overlaps keep the brightest ink
+		ink := uint8(math.Round(255 * strokeWeight(stroke)))
 		
 		// Draw all individual points first to ensure nothing is missed
 		for _, point := range stroke.Points {
@@ -68,7 +246,7 @@ func (r *ONNXRecognizer) strokesToTensor(strokes []Stroke, width, height int) ([
 					nx := x + dx
 					ny := y + dy
 					if nx >= 0 && nx < width && ny >= 0 && ny < height {
-						img.SetGray(nx, ny, color.Gray{Y: 255}) // White stroke
+						if img.GrayAt(nx, ny).Y < ink { img.SetGray(nx, ny, color.Gray{Y: ink}) } // White stroke
 					}
 				}
 			}
@@ -85,14 +263,18 @@ func (r *ONNXRecognizer) strokesToTensor(strokes []Stroke, width, height int) ([
 			x2 := p2.X
 			y2 := p2.Y
 			
-			// Draw thick line with more steps for smoother lines
+			// Draw thick line with more steps for smoother lines. Only the part of the segment
+			// inside the canvas is walked, so the step count never exceeds its diagonal however
+			// far away the endpoints lie.
+			t0, t1, visible := clipSegment(x1, y1, x2, y2, width, height)
+			if !visible { continue }
 			dx := x2 - x1
 			dy := y2 - y1
-			distance := math.Sqrt(dx*dx + dy*dy)
-			steps := int(distance) + 1
+			distance := math.Sqrt(dx*dx+dy*dy) * (t1 - t0)
+			steps := int(math.Min(distance, math.Hypot(float64(width), float64(height)))) + 1
 			
 			for j := 0; j <= steps; j++ {
-				t := float64(j) / float64(steps)
+				t := t0 + (t1-t0)*float64(j)/float64(steps)
 				x := int(x1 + t*dx)
 				y := int(y1 + t*dy)
 				
@@ -102,7 +284,7 @@ func (r *ONNXRecognizer) strokesToTensor(strokes []Stroke, width, height int) ([
 						nx := x + dx
 						ny := y + dy
 						if nx >= 0 && nx < width && ny >= 0 && ny < height {
-							img.SetGray(nx, ny, color.Gray{Y: 255}) // White stroke
+							if img.GrayAt(nx, ny).Y < ink { img.SetGray(nx, ny, color.Gray{Y: ink}) } // White stroke
 						}
 					}
 				}
@@ -136,24 +318,39 @@ func (r *ONNXRecognizer) Recognize(strokes []Stroke, width, height int, topN int
 	if err != nil {
 		return nil, err
 	}
+	if nearlyBlank(tensor, r.MinDensity) { return []Candidate{}, nil }
+	if r.session != nil {
+		return r.infer(tensor, width, height, len(strokes), topN)
+	}
 	
 	// Analyze the image tensor to extract features
 	features := r.analyzeTensorFeatures(tensor, width, height)
 	
-	// Debug logging with visual representation
-	fmt.Printf("Recognition analysis for %d strokes:\n", len(strokes))
-	fmt.Printf("  Features: horizontal_lines=%.1f, vertical_lines=%.1f, diagonal_lines=%.1f\n", 
+	// Generate candidates based on extracted features
+	candidates := r.generateCandidatesFromFeatures(features, len(strokes), topN)
+	if r.DebugLog != nil { r.DebugLog.Print(describeAnalysis(strokes, tensor, width, height, features, candidates)) }
+	
+	return candidates, nil
+}
+
+// describeAnalysis renders the features, an ASCII view of the tensor and the stroke endpoints
+// behind a pattern-based recognition, for DebugLog
+func describeAnalysis(strokes []Stroke, tensor []float32, width, height int, features map[string]float64, candidates []Candidate) string {
+	var b strings.Builder
+	if !tensorFits(tensor, width, height) { return fmt.Sprintf("Recognition analysis skipped: %d values do not fit a %dx%d canvas\n", len(tensor), width, height) }
+	fmt.Fprintf(&b, "Recognition analysis for %d strokes:\n", len(strokes))
+	fmt.Fprintf(&b, "  Features: horizontal_lines=%.1f, vertical_lines=%.1f, diagonal_lines=%.1f\n", 
 		features["horizontal_lines"], features["vertical_lines"], features["diagonal_lines"])
-	fmt.Printf("  Patterns: has_cross=%.1f, has_three_horizontal=%.1f, has_two_horizontal=%.1f\n", 
+	fmt.Fprintf(&b, "  Patterns: has_cross=%.1f, has_three_horizontal=%.1f, has_two_horizontal=%.1f\n", 
 		features["has_cross"], features["has_three_horizontal"], features["has_two_horizontal"])
-	fmt.Printf("  Single: has_single_horizontal=%.1f, has_single_vertical=%.1f\n", 
-		features["has_single_horizontal"], features["has_single_vertical"])
-	fmt.Printf("  Canvas: width=%d, height=%d, density=%.3f, aspect_ratio=%.2f\n", 
+	fmt.Fprintf(&b, "  Single: has_single_horizontal=%.1f, has_single_vertical=%.1f, loops=%.0f\n", 
+		features["has_single_horizontal"], features["has_single_vertical"], features["loops"])
+	fmt.Fprintf(&b, "  Canvas: width=%d, height=%d, density=%.3f, aspect_ratio=%.2f\n", 
 		width, height, features["density"], features["aspect_ratio"])
 	
 	// Visual debug - show the actual image tensor
-	fmt.Printf("  Visual representation (showing active pixels):\n")
-	fmt.Printf("  Canvas size: %dx%d, Tensor size: %d\n", width, height, len(tensor))
+	b.WriteString("  Visual representation (showing active pixels):\n")
+	fmt.Fprintf(&b, "  Canvas size: %dx%d, Tensor size: %d\n", width, height, len(tensor))
 	
 	// Show full canvas with better resolution for debugging
 	stepY := 1
@@ -166,28 +363,28 @@ func (r *ONNXRecognizer) Recognize(strokes []Stroke, width, height int, topN int
 	}
 	
 	for y := 0; y < height; y += stepY {
-		fmt.Printf("  ")
+		b.WriteString("  ")
 		for x := 0; x < width; x += stepX {
 			// Sample the pixel value
 			idx := y*width + x
 			if idx < len(tensor) && tensor[idx] > 0.1 {
-				fmt.Printf("█")
+				b.WriteString("█")
 			} else {
-				fmt.Printf(".")
+				b.WriteString(".")
 			}
 		}
-		fmt.Printf("\n")
+		b.WriteString("\n")
 	}
 	
 	// Debug: show actual stroke coordinates and pixel coverage
-	fmt.Printf("  Stroke coordinates:\n")
+	b.WriteString("  Stroke coordinates:\n")
 	totalPixels := 0
 	for i, stroke := range strokes {
-		fmt.Printf("    Stroke %d: %d points\n", i, len(stroke.Points))
+		fmt.Fprintf(&b, "    Stroke %d: %d points\n", i, len(stroke.Points))
 		if len(stroke.Points) > 0 {
 			first := stroke.Points[0]
 			last := stroke.Points[len(stroke.Points)-1]
-			fmt.Printf("      First: (%.1f, %.1f), Last: (%.1f, %.1f)\n", 
+			fmt.Fprintf(&b, "      First: (%.1f, %.1f), Last: (%.1f, %.1f)\n", 
 				first.X, first.Y, last.X, last.Y)
 		}
 	}
@@ -200,24 +397,23 @@ func (r *ONNXRecognizer) Recognize(strokes []Stroke, width, height int, topN int
 			}
 		}
 	}
-	fmt.Printf("  Total pixels drawn: %d (%.2f%% of canvas)\n", totalPixels, float64(totalPixels)/float64(width*height)*100)
+	fmt.Fprintf(&b, "  Total pixels drawn: %d (%.2f%% of canvas)\n", totalPixels, float64(totalPixels)/float64(width*height)*100)
 	
-	// Generate candidates based on extracted features
-	candidates := r.generateCandidatesFromFeatures(features, len(strokes), topN)
-	
-	fmt.Printf("  Generated %d candidates: ", len(candidates))
+	fmt.Fprintf(&b, "  Generated %d candidates: ", len(candidates))
 	for i, c := range candidates {
-		if i > 0 { fmt.Printf(", ") }
-		fmt.Printf("%s(%.2f)", c.Text, c.Score)
+		if i > 0 { b.WriteString(", ") }
+		fmt.Fprintf(&b, "%s(%.2f)", c.Text, c.Score)
 	}
-	fmt.Printf("\n")
+	b.WriteString("\n")
 	
-	return candidates, nil
+	return b.String()
 }
 
 // analyzeTensorFeatures extracts meaningful features from the image tensor
 func (r *ONNXRecognizer) analyzeTensorFeatures(tensor []float32, width, height int) map[string]float64 {
 	features := make(map[string]float64)
+	// Every detector below indexes tensor[y*width+x]; a tensor that does not fit reads as blank
+	if !tensorFits(tensor, width, height) { return features }
 	
 	// Calculate basic statistics
 	totalPixels := float64(width * height)
@@ -261,6 +457,7 @@ func (r *ONNXRecognizer) analyzeTensorFeatures(tensor []float32, width, height i
 	features["has_two_horizontal"] = r.detectTwoHorizontal(tensor, width, height)
 	features["has_single_horizontal"] = r.detectSingleHorizontal(tensor, width, height)
 	features["has_single_vertical"] = r.detectSingleVertical(tensor, width, height)
+	features["loops"] = float64(r.detectLoops(tensor, width, height))
 	
 	return features
 }
@@ -350,7 +547,7 @@ func (r *ONNXRecognizer) detectVerticalLines(tensor []float32, width, height int
 // detectDiagonalLines finds diagonal line segments
 func (r *ONNXRecognizer) detectDiagonalLines(tensor []float32, width, height int) int {
 	lines := 0
-	minLineLength := int(math.Sqrt(float64(width*width + height*height))) / 8
+	minLineLength := int(math.Hypot(float64(width), float64(height))) / 8
 	
 	// Check diagonal directions
 	directions := [][]int{{1, 1}, {1, -1}, {-1, 1}, {-1, -1}}
@@ -483,6 +680,25 @@ func (r *ONNXRecognizer) generateCandidatesFromFeatures(features map[string]floa
 	
 	// Priority-based pattern matching using the new detection functions
 	
+	// Closed loops (口, 回, 田) - line counts alone cannot tell these from 二 or 十
+	switch loops := features["loops"]; {
+	case loops >= 4:
+		candidates = append(candidates,
+			Candidate{Text: "田", Score: 0.9}, // field: four cells
+			Candidate{Text: "由", Score: 0.5}, // reason
+		)
+	case loops >= 2:
+		candidates = append(candidates,
+			Candidate{Text: "回", Score: 0.85}, // times: box in a box
+			Candidate{Text: "日", Score: 0.8},  // sun: divided box
+		)
+	case loops >= 1:
+		candidates = append(candidates,
+			Candidate{Text: "口", Score: 0.9}, // mouth
+			Candidate{Text: "O", Score: 0.7}, // circle
+		)
+	}
+	
 	// Cross detection (十) - highest priority for 2 strokes
 	if strokeCount == 2 && features["has_cross"] > 0.5 {
 		candidates = append(candidates,
@@ -628,6 +844,9 @@ func (r *ONNXRecognizer) generateCandidatesFromFeatures(features map[string]floa
 		}
 	}
 	
+	// Best first, so truncating to topN keeps the highest scores
+	rankCandidates(candidates, strokeCount, !r.NoStrokeTieBreak)
+	
 	// Limit to topN results
 	if len(candidates) > topN {
 		candidates = candidates[:topN]
@@ -1,7 +1,16 @@
 package recognize
 
 import (
+	"bytes"
+	"io"
+	"log"
+	"math"
+	"reflect"
+	"strings"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestNewONNXRecognizer(t *testing.T) {
@@ -286,6 +295,30 @@ func TestStrokesToTensor_EmptyStrokes(t *testing.T) {
 	}
 }
 
+func TestStrokesToTensor_FarOffSegmentIsClipped(t *testing.T) {
+	// A segment billions of pixels long must only be walked across the canvas
+	start := time.Now()
+	tensor, err := StrokesToTensor([]Stroke{{Points: []Point{{X: 0, Y: 150}, {X: 3e9, Y: 150}}}}, 300, 300)
+	if err != nil {
+		t.Fatalf("Should not return error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Expected a far-off segment to rasterize quickly, took %v", elapsed)
+	}
+	for x := 0; x < 300; x++ {
+		if tensor[150*300+x] == 0 {
+			t.Fatalf("Expected the segment to be drawn across the canvas, gap at x=%d", x)
+		}
+	}
+	// Segments wholly outside the canvas draw nothing
+	tensor, _ = StrokesToTensor([]Stroke{{Points: []Point{{X: -5e9, Y: -10}, {X: 5e9, Y: -10}}}}, 300, 300)
+	for i, v := range tensor {
+		if v != 0 {
+			t.Fatalf("Expected an off-canvas segment to leave the tensor blank, got ink at %d", i)
+		}
+	}
+}
+
 func TestDetectHorizontalLines(t *testing.T) {
 	recognizer, err := NewONNXRecognizer("test_model.onnx")
 	if err != nil {
@@ -382,3 +415,211 @@ func TestDetectThreeHorizontal(t *testing.T) {
 		t.Fatalf("Expected three horizontal detection > 0.5, got %f", three)
 	}
 }
+
+func TestNewONNXRecognizer_MissingModelUsesPatterns(t *testing.T) {
+	recognizer, err := NewONNXRecognizer("test_model.onnx")
+	if err != nil {
+		t.Fatalf("Failed to create recognizer: %v", err)
+	}
+	if recognizer.Backend() != BackendPatterns {
+		t.Fatalf("Expected backend %q for a missing model, got %q", BackendPatterns, recognizer.Backend())
+	}
+	if recognizer.LoadError() == nil {
+		t.Fatal("Expected a load error for a missing model")
+	}
+}
+
+func TestLoadLabels(t *testing.T) {
+	dir := t.TempDir()
+	model := filepath.Join(dir, "chars.onnx")
+	if _, err := loadLabels(model); err == nil {
+		t.Fatal("Expected an error without a label file")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "labels.txt"), []byte("甲\n乙\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	labels, err := loadLabels(model)
+	if err != nil || len(labels) != 2 || labels[1] != "乙" {
+		t.Fatalf("Expected labels.txt to be used, got %v (%v)", labels, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "chars.labels"), []byte("一\r\n\r\n二\r\n三\r\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	labels, err = loadLabels(model)
+	if err != nil || len(labels) != 3 || labels[0] != "一" || labels[2] != "三" {
+		t.Fatalf("Expected chars.labels to win, got %v (%v)", labels, err)
+	}
+}
+
+func TestLogitsToCandidates(t *testing.T) {
+	cands := logitsToCandidates([]float32{1, 3, 2}, []string{"一", "二", "三"}, 0, true, 2)
+	if len(cands) != 2 || cands[0].Text != "二" || cands[1].Text != "三" {
+		t.Fatalf("Expected [二 三], got %v", cands)
+	}
+	all := logitsToCandidates([]float32{1, 3, 2}, []string{"一", "二", "三"}, 0, true, 10)
+	sum := 0.0
+	for _, c := range all { sum += c.Score }
+	if math.Abs(sum-1) > 1e-9 {
+		t.Fatalf("Expected softmax scores to sum to 1, got %f", sum)
+	}
+}
+
+func TestLogitsToCandidates_TiePrefersMatchingStrokeCount(t *testing.T) {
+	labels := []string{"大", "中", "一"}
+	cands := logitsToCandidates([]float32{2, 2, 1}, labels, 4, true, 2)
+	if len(cands) != 2 || cands[0].Text != "中" || cands[1].Text != "大" {
+		t.Fatalf("Expected [中 大] for 4 strokes, got %v", cands)
+	}
+	if cands = logitsToCandidates([]float32{2, 2, 1}, labels, 4, false, 2); cands[0].Text != "大" {
+		t.Fatalf("Expected label order with the tie-break off, got %v", cands)
+	}
+}
+
+func TestResizeTensor(t *testing.T) {
+	tensor := make([]float32, 300*300)
+	for x := 50; x < 250; x++ {
+		for y := 149; y <= 151; y++ { tensor[y*300+x] = 1 }
+	}
+	out := resizeTensor(tensor, 300, 300, 28, 28)
+	if len(out) != 28*28 {
+		t.Fatalf("Expected %d values, got %d", 28*28, len(out))
+	}
+	if out[14*28+14] == 0 {
+		t.Fatal("Expected the line to survive downscaling")
+	}
+	if out[2*28+14] != 0 {
+		t.Fatalf("Expected empty rows to stay empty, got %f", out[2*28+14])
+	}
+}
+
+// TestONNXRecognizer_TinyModel runs testdata/tiny_model.onnx (see gen_tiny_model.go) when
+// ONNXRUNTIME_LIB points at an onnxruntime shared library
+func TestONNXRecognizer_TinyModel(t *testing.T) {
+	lib := os.Getenv("ONNXRUNTIME_LIB")
+	if lib == "" {
+		t.Skip("ONNXRUNTIME_LIB not set")
+	}
+	SetONNXRuntimeLibrary(lib)
+	recognizer, err := NewONNXRecognizer(filepath.Join("testdata", "tiny_model.onnx"))
+	if err != nil {
+		t.Fatalf("Failed to create recognizer: %v", err)
+	}
+	defer recognizer.Close()
+	if recognizer.Backend() != BackendModel {
+		t.Fatalf("Expected the model to load, got %v", recognizer.LoadError())
+	}
+	strokes := []Stroke{{Points: []Point{{X: 50, Y: 150}, {X: 250, Y: 150}}}}
+	candidates, err := recognizer.Recognize(strokes, 300, 300, 5)
+	if err != nil {
+		t.Fatalf("Inference failed: %v", err)
+	}
+	if len(candidates) == 0 || candidates[0].Text != "一" {
+		t.Fatalf("Expected 一 first, got %v", candidates)
+	}
+}
+
+func TestModelInput_MatchesInputShape(t *testing.T) {
+	recognizer, err := NewONNXRecognizer("test_model.onnx")
+	if err != nil {
+		t.Fatalf("Failed to create recognizer: %v", err)
+	}
+	strokes := []Stroke{{Points: []Point{{X: 60, Y: 150}, {X: 240, Y: 150}}}}
+	full, err := recognizer.strokesToTensor(strokes, 300, 300)
+	if err != nil {
+		t.Fatalf("Failed to rasterize: %v", err)
+	}
+	if len(full) != 300*300 {
+		t.Fatalf("Expected the full-resolution tensor to keep %d values, got %d", 300*300, len(full))
+	}
+	in := recognizer.modelInput(full, 300, 300)
+	side := int(recognizer.inputShape[3])
+	if want := int(recognizer.inputShape[2] * recognizer.inputShape[3]); len(in) != want {
+		t.Fatalf("Expected %d values, got %d", want, len(in))
+	}
+	active := 0
+	for x := 0; x < side; x++ {
+		if in[14*side+x] > 0 { active++ }
+	}
+	if active < side/2 {
+		t.Fatalf("Expected the centered line to cover the middle row, got %d active pixels", active)
+	}
+}
+
+func TestResizeTensor_KeepsAspectRatio(t *testing.T) {
+	// A 600x300 canvas fills 28x14, centered with 7 padding rows above and below
+	tensor := make([]float32, 600*300)
+	for i := range tensor { tensor[i] = 1 }
+	out := resizeTensor(tensor, 600, 300, 28, 28)
+	for y := 0; y < 28; y++ {
+		want := float32(0)
+		if y >= 7 && y < 21 { want = 1 }
+		for x := 0; x < 28; x++ {
+			if out[y*28+x] != want {
+				t.Fatalf("Expected %v at (%d,%d), got %v", want, x, y, out[y*28+x])
+			}
+		}
+	}
+}
+
+// captureStdout returns what f writes to os.Stdout
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	orig := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = orig }()
+	f()
+	w.Close()
+	out, _ := io.ReadAll(r)
+	return string(out)
+}
+
+func TestONNXRecognizer_DebugLog(t *testing.T) {
+	strokes := []Stroke{
+		{Points: []Point{{X: 10, Y: 50}, {X: 90, Y: 50}}},
+		{Points: []Point{{X: 50, Y: 10}, {X: 50, Y: 90}}},
+	}
+	recognize := func(l *log.Logger) []Candidate {
+		r, err := NewONNXRecognizer("test_model.onnx")
+		if err != nil {
+			t.Fatalf("Failed to create recognizer: %v", err)
+		}
+		r.DebugLog = l
+		var cands []Candidate
+		if out := captureStdout(t, func() { cands, err = r.Recognize(strokes, 100, 100, 5) }); out != "" {
+			t.Fatalf("Expected nothing on stdout, got %q", out)
+		}
+		if err != nil {
+			t.Fatalf("Failed to recognize: %v", err)
+		}
+		return cands
+	}
+
+	want := recognize(nil)
+	if len(want) == 0 {
+		t.Fatal("Expected candidates")
+	}
+	if got := recognize(log.New(io.Discard, "", 0)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected a discarding logger not to change the result: %v vs %v", got, want)
+	}
+	var buf bytes.Buffer
+	if got := recognize(log.New(&buf, "", 0)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Expected a debug logger not to change the result: %v vs %v", got, want)
+	}
+	if !strings.Contains(buf.String(), "Visual representation") || !strings.Contains(buf.String(), "Stroke 1: 2 points") {
+		t.Fatalf("Expected the tensor dump in the debug log, got %q", buf.String())
+	}
+}
+
+func TestONNXRecognizer_Name(t *testing.T) {
+	r, err := NewONNXRecognizer("test_model.onnx")
+	if err != nil {
+		t.Fatalf("Failed to create recognizer: %v", err)
+	}
+	if got := r.Name(); got != "onnx" {
+		t.Fatalf("Expected onnx, got %q", got)
+	}
+}
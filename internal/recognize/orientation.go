@@ -0,0 +1,90 @@
+package recognize
+
+import "math"
+
+// Orientation is the writing direction guessed from how character clusters are laid out
+type Orientation string
+
+const (
+	Horizontal         Orientation = "horizontal"
+	Vertical           Orientation = "vertical"
+	UnknownOrientation Orientation = "unknown" // fewer than two clusters, or no clear axis
+)
+
+// clusterPadding grows each box by this fraction of the typical stroke size before testing overlap,
+// so strokes of one character that almost touch still join while the gaps between characters stay
+const clusterPadding = 0.15
+
+type box struct{ minX, minY, maxX, maxY float64 }
+
+func (b box) overlaps(o box, pad float64) bool {
+	return b.minX-pad <= o.maxX && o.minX-pad <= b.maxX && b.minY-pad <= o.maxY && o.minY-pad <= b.maxY
+}
+
+func (b box) contains(o box) bool {
+	return b.minX <= o.minX && b.minY <= o.minY && o.maxX <= b.maxX && o.maxY <= b.maxY
+}
+
+func strokeBox(s Stroke) box {
+	b := box{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+	for _, p := range s.Points { b = b.union(box{p.X, p.Y, p.X, p.Y}) }
+	return b
+}
+
+func (b box) union(o box) box {
+	return box{math.Min(b.minX, o.minX), math.Min(b.minY, o.minY), math.Max(b.maxX, o.maxX), math.Max(b.maxY, o.maxY)}
+}
+
+// clusterStrokes groups strokes whose bounding boxes overlap (after padding) into character-sized
+// boxes. Merging repeats until stable, so a stroke bridging two groups pulls them together.
+func clusterStrokes(strokes []Stroke) []box {
+	var boxes []box
+	size := 0.0
+	for _, s := range strokes {
+		if len(s.Points) == 0 { continue }
+		b := strokeBox(s)
+		boxes = append(boxes, b)
+		size += math.Max(b.maxX-b.minX, b.maxY-b.minY)
+	}
+	if len(boxes) == 0 { return nil }
+	pad := clusterPadding * size / float64(len(boxes))
+	for merged := true; merged; {
+		merged = false
+		for i := 0; i < len(boxes) && !merged; i++ {
+			for j := i + 1; j < len(boxes); j++ {
+				if !boxes[i].overlaps(boxes[j], pad) { continue }
+				boxes[i] = boxes[i].union(boxes[j])
+				boxes = append(boxes[:j], boxes[j+1:]...)
+				merged = true
+				break
+			}
+		}
+	}
+	return boxes
+}
+
+// DetectOrientation compares how far apart the cluster centers spread along each axis. Confidence
+// is in [0,1]: 1 when the clusters lie on a perfect row or column, 0 when the spread is even.
+func DetectOrientation(strokes []Stroke) (dir Orientation, confidence float64, clusters int) {
+	return orientationOf(clusterStrokes(strokes))
+}
+
+func orientationOf(boxes []box) (Orientation, float64, int) {
+	if len(boxes) < 2 { return UnknownOrientation, 0, len(boxes) }
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, c := range boxes {
+		cx, cy := (c.minX+c.maxX)/2, (c.minY+c.maxY)/2
+		minX, maxX = math.Min(minX, cx), math.Max(maxX, cx)
+		minY, maxY = math.Min(minY, cy), math.Max(maxY, cy)
+	}
+	sx, sy := maxX-minX, maxY-minY
+	if sx+sy == 0 { return UnknownOrientation, 0, len(boxes) }
+	conf := math.Abs(sx-sy) / (sx + sy)
+	switch {
+	case sx > sy:
+		return Horizontal, conf, len(boxes)
+	case sy > sx:
+		return Vertical, conf, len(boxes)
+	}
+	return UnknownOrientation, 0, len(boxes)
+}
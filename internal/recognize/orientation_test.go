@@ -0,0 +1,57 @@
+package recognize
+
+import "testing"
+
+// cross draws a 十-like character of the given size centered at (cx, cy)
+func cross(cx, cy, size float64) []Stroke {
+	h := size / 2
+	return []Stroke{
+		{Points: []Point{{X: cx - h, Y: cy}, {X: cx + h, Y: cy}}},
+		{Points: []Point{{X: cx, Y: cy - h}, {X: cx, Y: cy + h}}},
+	}
+}
+
+func TestDetectOrientation_Horizontal(t *testing.T) {
+	var strokes []Stroke
+	for i := 0; i < 3; i++ { strokes = append(strokes, cross(50+float64(i)*100, 50+float64(i)*4, 60)...) }
+	dir, conf, clusters := DetectOrientation(strokes)
+	if dir != Horizontal {
+		t.Fatalf("Expected horizontal, got %s", dir)
+	}
+	if clusters != 3 {
+		t.Fatalf("Expected 3 clusters, got %d", clusters)
+	}
+	if conf < 0.8 {
+		t.Fatalf("Expected high confidence for a clean row, got %f", conf)
+	}
+}
+
+func TestDetectOrientation_Vertical(t *testing.T) {
+	var strokes []Stroke
+	for i := 0; i < 4; i++ { strokes = append(strokes, cross(50, 50+float64(i)*90, 60)...) }
+	dir, conf, clusters := DetectOrientation(strokes)
+	if dir != Vertical || clusters != 4 {
+		t.Fatalf("Expected vertical from 4 clusters, got %s from %d", dir, clusters)
+	}
+	if conf < 0.8 {
+		t.Fatalf("Expected high confidence for a clean column, got %f", conf)
+	}
+}
+
+func TestDetectOrientation_SingleCharacterUnknown(t *testing.T) {
+	dir, conf, clusters := DetectOrientation(cross(100, 100, 80))
+	if dir != UnknownOrientation || conf != 0 || clusters != 1 {
+		t.Fatalf("Expected unknown with no confidence for one character, got %s %f (%d clusters)", dir, conf, clusters)
+	}
+	if dir, _, clusters := DetectOrientation(nil); dir != UnknownOrientation || clusters != 0 {
+		t.Fatalf("Expected unknown for an empty board, got %s (%d clusters)", dir, clusters)
+	}
+}
+
+func TestDetectOrientation_DiagonalIsLowConfidence(t *testing.T) {
+	var strokes []Stroke
+	for i := 0; i < 3; i++ { strokes = append(strokes, cross(50+float64(i)*100, 50+float64(i)*95, 60)...) }
+	if _, conf, _ := DetectOrientation(strokes); conf > 0.1 {
+		t.Fatalf("Expected low confidence for a diagonal layout, got %f", conf)
+	}
+}
@@ -0,0 +1,159 @@
+package recognize
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/deliium/drawing-board/internal/smooth"
+)
+
+// Stage is one preprocessing step. Stages must not modify their input; they return new strokes.
+type Stage struct {
+	Name  string
+	Apply func(strokes []Stroke, width, height int) []Stroke
+}
+
+// Pipeline runs its stages in order before recognition; an empty pipeline passes strokes through
+type Pipeline []Stage
+
+func (p Pipeline) Apply(strokes []Stroke, width, height int) []Stroke {
+	for _, s := range p { strokes = s.Apply(strokes, width, height) }
+	return strokes
+}
+
+// String lists the stage names, e.g. "dedupe,resample,normalize"
+func (p Pipeline) String() string {
+	names := make([]string, 0, len(p))
+	for _, s := range p { names = append(names, s.Name) }
+	return strings.Join(names, ",")
+}
+
+// ParsePipeline builds a pipeline from a spec like "dedupe:0.5,smooth:3,resample:32,normalize".
+// Arguments are optional and default to the values documented on each stage constructor.
+func ParsePipeline(spec string) (Pipeline, error) {
+	var p Pipeline
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" { continue }
+		name, arg, hasArg := strings.Cut(item, ":")
+		num := func(def float64) (float64, error) {
+			if !hasArg { return def, nil }
+			v, err := strconv.ParseFloat(arg, 64)
+			if err != nil || v <= 0 { return 0, fmt.Errorf("stage %q: bad argument %q", name, arg) }
+			return v, nil
+		}
+		var v float64
+		var err error
+		switch name {
+		case "normalize":
+			if hasArg { return nil, fmt.Errorf("stage %q takes no argument", name) }
+			p = append(p, Normalize())
+		case "resample":
+			if v, err = num(32); err == nil { p = append(p, Resample(int(v))) }
+		case "smooth":
+			if v, err = num(3); err == nil {
+				if _, serr := smooth.Parse(smooth.Moving, int(v)); serr != nil { err = fmt.Errorf("stage %q: %w", name, serr) }
+			}
+			if err == nil { p = append(p, Smooth(int(v))) }
+		case "dedupe":
+			if v, err = num(0.5); err == nil { p = append(p, Dedupe(v)) }
+		default:
+			return nil, fmt.Errorf("unknown preprocessing stage %q (want normalize, resample, smooth or dedupe)", name)
+		}
+		if err != nil { return nil, err }
+	}
+	return p, nil
+}
+
+func mapStrokes(strokes []Stroke, f func(pts []Point) []Point) []Stroke {
+	out := make([]Stroke, len(strokes))
+	for i, s := range strokes { out[i] = Stroke{Points: f(s.Points), Weight: s.Weight} }
+	return out
+}
+
+// Normalize scales and centers the drawing so its bounding box fills 80% of the canvas,
+// keeping the aspect ratio. It needs a canvas size and is a no-op without one.
+func Normalize() Stage {
+	return Stage{Name: "normalize", Apply: func(strokes []Stroke, width, height int) []Stroke {
+		if width <= 0 || height <= 0 { return strokes }
+		minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+		for _, s := range strokes {
+			for _, p := range s.Points {
+				minX, minY, maxX, maxY = math.Min(minX, p.X), math.Min(minY, p.Y), math.Max(maxX, p.X), math.Max(maxY, p.Y)
+			}
+		}
+		if math.IsInf(minX, 1) { return strokes }
+		span := math.Max(maxX-minX, maxY-minY)
+		scale := 1.0
+		if span > 0 { scale = 0.8 * math.Min(float64(width), float64(height)) / span }
+		cx, cy := (minX+maxX)/2, (minY+maxY)/2
+		return mapStrokes(strokes, func(pts []Point) []Point {
+			out := make([]Point, len(pts))
+			for i, p := range pts {
+				out[i] = Point{X: float64(width)/2 + (p.X-cx)*scale, Y: float64(height)/2 + (p.Y-cy)*scale}
+			}
+			return out
+		})
+	}}
+}
+
+// Resample redistributes each stroke to n points evenly spaced along its path (default 32).
+// Single-point strokes are left alone.
+func Resample(n int) Stage {
+	if n < 2 { n = 2 }
+	return Stage{Name: "resample", Apply: func(strokes []Stroke, _, _ int) []Stroke {
+		return mapStrokes(strokes, func(pts []Point) []Point {
+			total := PathLength(Stroke{Points: pts})
+			if len(pts) < 2 || total == 0 { return append([]Point(nil), pts...) }
+			step := total / float64(n-1)
+			out := make([]Point, 0, n)
+			out = append(out, pts[0])
+			seg, segStart := 1, 0.0 // segStart is the path distance at pts[seg-1]
+			for k := 1; k < n-1; k++ {
+				target := step * float64(k)
+				for seg < len(pts)-1 && segStart+dist(pts[seg-1], pts[seg]) < target {
+					segStart += dist(pts[seg-1], pts[seg])
+					seg++
+				}
+				d := dist(pts[seg-1], pts[seg])
+				t := 0.0
+				if d > 0 { t = (target - segStart) / d }
+				out = append(out, Point{X: pts[seg-1].X + t*(pts[seg].X-pts[seg-1].X), Y: pts[seg-1].Y + t*(pts[seg].Y-pts[seg-1].Y)})
+			}
+			return append(out, pts[len(pts)-1])
+		})
+	}}
+}
+
+// Smooth applies a centered moving average over window points (default 3, at least 3), keeping
+// the endpoints; it is the same smoothing the WebSocket hub can apply at save time
+func Smooth(window int) Stage {
+	s := smooth.Smoothing{Method: smooth.Moving, Window: window}
+	return Stage{Name: "smooth", Apply: func(strokes []Stroke, _, _ int) []Stroke {
+		return mapStrokes(strokes, func(pts []Point) []Point {
+			in := make([]smooth.Point, len(pts))
+			for i, p := range pts { in[i] = smooth.Point(p) }
+			out := make([]Point, len(pts))
+			for i, p := range s.Apply(in) { out[i] = Point(p) }
+			return out
+		})
+	}}
+}
+
+// Dedupe drops points closer than eps (default 0.5 canvas pixels) to the previous kept point
+func Dedupe(eps float64) Stage {
+	return Stage{Name: "dedupe", Apply: func(strokes []Stroke, _, _ int) []Stroke {
+		return mapStrokes(strokes, func(pts []Point) []Point {
+			if len(pts) == 0 { return nil }
+			out := []Point{pts[0]}
+			for _, p := range pts[1:] {
+				if dist(out[len(out)-1], p) >= eps { out = append(out, p) }
+			}
+			return out
+		})
+	}}
+}
+
+func dist(a, b Point) float64 { return math.Hypot(b.X-a.X, b.Y-a.Y) }
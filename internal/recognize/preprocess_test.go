@@ -0,0 +1,80 @@
+package recognize
+
+import (
+	"math"
+	"testing"
+)
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+func TestPipeline_NormalizeResample(t *testing.T) {
+	// An L drawn small in the corner with uneven point spacing
+	in := []Stroke{{Points: []Point{{X: 10, Y: 10}, {X: 10, Y: 11}, {X: 10, Y: 30}, {X: 30, Y: 30}}}}
+	p, err := ParsePipeline("normalize,resample:5")
+	if err != nil {
+		t.Fatalf("Failed to parse pipeline: %v", err)
+	}
+	out := p.Apply(in, 300, 300)
+	pts := out[0].Points
+	if len(pts) != 5 {
+		t.Fatalf("Expected 5 resampled points, got %d", len(pts))
+	}
+	// The 20x20 box is scaled to 80% of 300 and centered: corners at 30 and 270
+	if !near(pts[0].X, 30) || !near(pts[0].Y, 30) || !near(pts[4].X, 270) || !near(pts[4].Y, 270) {
+		t.Fatalf("Expected endpoints (30,30) and (270,270), got %v and %v", pts[0], pts[4])
+	}
+	// Evenly spaced along the 480px path: the middle point is the corner of the L
+	if !near(pts[2].X, 30) || !near(pts[2].Y, 270) {
+		t.Fatalf("Expected the middle point at the corner (30,270), got %v", pts[2])
+	}
+	for i := 1; i < len(pts); i++ {
+		if d := dist(pts[i-1], pts[i]); !near(d, 120) {
+			t.Fatalf("Expected spacing 120 between points %d and %d, got %f", i-1, i, d)
+		}
+	}
+	if in[0].Points[1].Y != 11 {
+		t.Fatal("Pipeline must not modify its input")
+	}
+}
+
+func TestPipeline_StageOrderMatters(t *testing.T) {
+	// A tiny drawing whose points are 0.4px apart
+	in := []Stroke{{Points: []Point{{X: 0, Y: 0}, {X: 0.4, Y: 0}, {X: 0.8, Y: 0}, {X: 1.2, Y: 0}}}}
+	dedupeFirst, _ := ParsePipeline("dedupe:0.5,normalize")
+	normalizeFirst, _ := ParsePipeline("normalize,dedupe:0.5")
+
+	a := dedupeFirst.Apply(in, 100, 100)[0].Points
+	b := normalizeFirst.Apply(in, 100, 100)[0].Points
+	if len(a) != 2 {
+		t.Fatalf("Deduping before scaling should drop the near points, got %d points", len(a))
+	}
+	if len(b) != 4 {
+		t.Fatalf("Scaling before deduping should keep every point, got %d points", len(b))
+	}
+}
+
+func TestParsePipeline(t *testing.T) {
+	p, err := ParsePipeline(" dedupe , smooth:5,resample,normalize ")
+	if err != nil || p.String() != "dedupe,smooth,resample,normalize" {
+		t.Fatalf("Expected four stages, got %q (%v)", p, err)
+	}
+	if p, err := ParsePipeline(""); err != nil || len(p) != 0 {
+		t.Fatalf("Expected an empty pipeline, got %v (%v)", p, err)
+	}
+	for _, bad := range []string{"blur", "resample:x", "resample:-1", "normalize:2", "smooth:2"} {
+		if _, err := ParsePipeline(bad); err == nil {
+			t.Fatalf("Expected error for %q", bad)
+		}
+	}
+}
+
+func TestSmoothStage_KeepsEndpoints(t *testing.T) {
+	in := []Stroke{{Points: []Point{{X: 0, Y: 0}, {X: 1, Y: 3}, {X: 2, Y: -3}, {X: 3, Y: 3}, {X: 4, Y: 0}}}}
+	out := Smooth(3).Apply(in, 0, 0)[0].Points
+	if out[0] != in[0].Points[0] || out[4] != in[0].Points[4] {
+		t.Fatalf("Expected endpoints kept, got %v", out)
+	}
+	if math.Abs(out[2].Y) >= 3 {
+		t.Fatalf("Expected the spike to be flattened, got %v", out[2])
+	}
+}
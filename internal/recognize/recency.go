@@ -0,0 +1,40 @@
+package recognize
+
+import "sort"
+
+// RecencyDecay is the factor each stroke's weight is smaller than that of the stroke started
+// after it; MinRecencyWeight keeps the oldest strokes visible to raster features
+const (
+	RecencyDecay     = 0.7
+	MinRecencyWeight = 0.25
+)
+
+// WeightByRecency returns a copy of strokes weighted by start time, startedAt being parallel to
+// strokes: the newest gets 1 and each older one RecencyDecay times the next, down to
+// MinRecencyWeight. Strokes started at the same time are ranked by their position.
+func WeightByRecency(strokes []Stroke, startedAt []int64) []Stroke {
+	order := make([]int, len(strokes))
+	for i := range order { order[i] = i }
+	sort.SliceStable(order, func(i, j int) bool { return startedAt[order[i]] > startedAt[order[j]] || startedAt[order[i]] == startedAt[order[j]] && order[i] > order[j] })
+	out := append([]Stroke(nil), strokes...)
+	w := 1.0
+	for _, i := range order {
+		out[i].Weight = w
+		if w *= RecencyDecay; w < MinRecencyWeight { w = MinRecencyWeight }
+	}
+	return out
+}
+
+// strokeWeight is s's recency weight; unweighted strokes count fully
+func strokeWeight(s Stroke) float64 {
+	if s.Weight <= 0 { return 1 }
+	return s.Weight
+}
+
+// weighted reports whether any stroke carries a recency weight
+func weighted(strokes []Stroke) bool {
+	for _, s := range strokes {
+		if s.Weight > 0 { return true }
+	}
+	return false
+}
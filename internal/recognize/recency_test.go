@@ -0,0 +1,78 @@
+package recognize
+
+import (
+	"math"
+	"testing"
+)
+
+var (
+	recencyH1 = Stroke{Points: []Point{{X: 100, Y: 120}, {X: 200, Y: 120}}}
+	recencyH2 = Stroke{Points: []Point{{X: 90, Y: 180}, {X: 210, Y: 180}}}
+	recencyV  = Stroke{Points: []Point{{X: 150, Y: 80}, {X: 150, Y: 240}}}
+)
+
+func TestWeightByRecency_NewestCountsMost(t *testing.T) {
+	strokes := make([]Stroke, 6)
+	// Stored order differs from drawing order; the last two were started together
+	got := WeightByRecency(strokes, []int64{3000, 1000, 6000, 2000, 5000, 5000})
+	want := []float64{0.343, 0.25, 1, 0.25, 0.49, 0.7}
+	for i := range want {
+		if math.Abs(got[i].Weight-want[i]) > 1e-9 {
+			t.Fatalf("Stroke %d: expected weight %v, got %v (all: %+v)", i, want[i], got[i].Weight, got)
+		}
+	}
+	if strokes[0].Weight != 0 {
+		t.Fatal("Expected the input strokes to be left unweighted")
+	}
+}
+
+func TestSimpleRecognizer_RecencyLetsFinalStrokeDecide(t *testing.T) {
+	r := NewSimpleRecognizer()
+	started := []int64{1000, 2000, 3000}
+	top := func(strokes []Stroke) string {
+		t.Helper()
+		cands, err := r.Recognize(strokes, 300, 300, 3)
+		if err != nil || len(cands) == 0 {
+			t.Fatalf("Expected candidates, got %v (%v)", cands, err)
+		}
+		return cands[0].Text
+	}
+
+	if got := top(WeightByRecency([]Stroke{recencyH1, recencyH2}, started[:2])); got != "二" {
+		t.Fatalf("Expected two horizontals to read as 二, got %s", got)
+	}
+	drawn := []Stroke{recencyH1, recencyH2, recencyV}
+	// Unweighted, no three-stroke pattern matches and the generic guess ignores the new stroke
+	if got := top(drawn); got != "三" {
+		t.Fatalf("Expected the unweighted fallback 三, got %s", got)
+	}
+	if got := top(WeightByRecency(drawn, started)); got != "十" {
+		t.Fatalf("Expected the final vertical to shift the top candidate to 十, got %s", got)
+	}
+}
+
+func TestSimpleRecognizer_RecencyKeepsWholeDrawingMatches(t *testing.T) {
+	r := NewSimpleRecognizer()
+	plain, _ := r.Recognize([]Stroke{recencyH1, recencyV}, 300, 300, 3)
+	weighted, _ := r.Recognize(WeightByRecency([]Stroke{recencyH1, recencyV}, []int64{1, 2}), 300, 300, 3)
+	if len(plain) != len(weighted) || plain[0] != weighted[0] {
+		t.Fatalf("Expected weights not to change a whole-drawing match, got %v and %v", plain, weighted)
+	}
+}
+
+func TestStrokesToTensor_OlderStrokesFainter(t *testing.T) {
+	r := &ONNXRecognizer{}
+	old, recent := recencyH1, recencyV
+	old.Weight, recent.Weight = 0.5, 1
+	tensor, err := r.strokesToTensor([]Stroke{old, recent}, 300, 300)
+	if err != nil {
+		t.Fatalf("Failed to rasterize: %v", err)
+	}
+	if v := tensor[120*300+110]; math.Abs(float64(v)-0.5) > 0.01 {
+		t.Fatalf("Expected the older stroke at half intensity, got %v", v)
+	}
+	// Where the strokes cross, the brighter ink wins
+	if v := tensor[120*300+150]; v != 1 {
+		t.Fatalf("Expected full intensity at the crossing, got %v", v)
+	}
+}
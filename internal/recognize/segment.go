@@ -0,0 +1,65 @@
+package recognize
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// SegmentStrokes splits a board into characters using the clusters DetectOrientation lays out,
+// returned in reading order: left to right, or top to bottom when the board is written vertically.
+// Strokes without points belong to no segment.
+func SegmentStrokes(strokes []Stroke) [][]Stroke {
+	boxes := clusterStrokes(strokes)
+	segs := make([][]Stroke, len(boxes))
+	for _, s := range strokes {
+		if len(s.Points) == 0 { continue }
+		b := strokeBox(s)
+		// Clusters are unions of stroke boxes, so every stroke lies inside exactly one
+		for i, c := range boxes {
+			if c.contains(b) { segs[i] = append(segs[i], s); break }
+		}
+	}
+	dir, _, _ := orientationOf(boxes)
+	order := make([]int, len(boxes))
+	for i := range order { order[i] = i }
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := boxes[order[i]], boxes[order[j]]
+		if dir == Vertical { return a.minY < b.minY }
+		return a.minX < b.minX
+	})
+	out := make([][]Stroke, len(order))
+	for i, k := range order { out[i] = segs[k] }
+	return out
+}
+
+// SegmentedResult ranks each character of a board on its own and combines the winners
+type SegmentedResult struct {
+	Segments  [][]Candidate `json:"segments"`  // up to topN candidates per segment, in reading order
+	BestGuess string        `json:"bestGuess"` // top candidate of each segment joined; unrecognized segments are skipped
+	Score     float64       `json:"score"`     // mean top score over all segments, 0 counting for unrecognized ones
+}
+
+// RecognizeSegments runs rec once per segment of strokes. The first recognizer error aborts the
+// whole result, as a partial guess would silently drop characters.
+func RecognizeSegments(rec Recognizer, strokes []Stroke, width, height, topN int) (SegmentedResult, error) {
+	return RecognizeSegmentsContext(context.Background(), rec, strokes, width, height, topN)
+}
+
+// RecognizeSegmentsContext is RecognizeSegments with each segment run through RecognizeContext, so
+// the caller's deadline ends the whole result with ctx's error
+func RecognizeSegmentsContext(ctx context.Context, rec Recognizer, strokes []Stroke, width, height, topN int) (SegmentedResult, error) {
+	segs := SegmentStrokes(strokes)
+	res := SegmentedResult{Segments: make([][]Candidate, 0, len(segs))}
+	var guess strings.Builder
+	for _, seg := range segs {
+		cands, err := RecognizeContext(ctx, rec, seg, width, height, topN)
+		if err != nil { return SegmentedResult{}, err }
+		if cands == nil { cands = []Candidate{} }
+		res.Segments = append(res.Segments, cands)
+		if len(cands) > 0 { guess.WriteString(cands[0].Text); res.Score += cands[0].Score }
+	}
+	res.BestGuess = guess.String()
+	if len(segs) > 0 { res.Score /= float64(len(segs)) }
+	return res, nil
+}
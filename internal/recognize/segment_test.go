@@ -0,0 +1,80 @@
+package recognize
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// byStrokeCount names a segment after how many strokes it has, so tests can tell segments apart
+type byStrokeCount struct{ err error }
+
+func (b byStrokeCount) Recognize(strokes []Stroke, width, height int, topN int) ([]Candidate, error) {
+	if b.err != nil { return nil, b.err }
+	texts := map[int]string{1: "一", 2: "十", 3: "三"}
+	if t, ok := texts[len(strokes)]; ok { return []Candidate{{Text: t, Score: 0.8}, {Text: "?", Score: 0.1}}, nil }
+	return nil, nil
+}
+
+func (byStrokeCount) Close() error { return nil }
+func (byStrokeCount) Name() string { return "byStrokeCount" }
+
+func line(x, y float64) Stroke { return Stroke{Points: []Point{{X: x - 30, Y: y}, {X: x + 30, Y: y}}} }
+
+func TestSegmentStrokes_ReadingOrder(t *testing.T) {
+	// Drawn right character first: segments must still come out left to right
+	strokes := append([]Stroke{line(250, 50)}, cross(50, 50, 60)...)
+	segs := SegmentStrokes(strokes)
+	if len(segs) != 2 || len(segs[0]) != 2 || len(segs[1]) != 1 {
+		t.Fatalf("Expected the cross then the line, got %d segments %v", len(segs), segs)
+	}
+
+	column := append(cross(50, 250, 60), line(50, 50))
+	if segs := SegmentStrokes(column); len(segs) != 2 || len(segs[0]) != 1 || len(segs[1]) != 2 {
+		t.Fatalf("Expected a vertical board to read top to bottom, got %v", segs)
+	}
+}
+
+func TestRecognizeSegments_JoinsTopCandidates(t *testing.T) {
+	strokes := append([]Stroke{line(250, 50)}, cross(50, 50, 60)...)
+	res, err := RecognizeSegments(byStrokeCount{}, strokes, 300, 300, 5)
+	if err != nil {
+		t.Fatalf("Should not return error: %v", err)
+	}
+	if res.BestGuess != "十一" {
+		t.Fatalf("Expected 十一 in left-to-right order, got %q", res.BestGuess)
+	}
+	if len(res.Segments) != 2 || len(res.Segments[0]) != 2 || res.Segments[0][0].Text != "十" {
+		t.Fatalf("Expected ranked candidates per segment, got %+v", res.Segments)
+	}
+	if res.Score != 0.8 {
+		t.Fatalf("Expected the mean top score, got %f", res.Score)
+	}
+}
+
+func TestRecognizeSegments_EmptyAndErrors(t *testing.T) {
+	res, err := RecognizeSegments(byStrokeCount{}, nil, 300, 300, 5)
+	if err != nil || res.BestGuess != "" || len(res.Segments) != 0 {
+		t.Fatalf("Expected an empty result for an empty board, got %+v (%v)", res, err)
+	}
+	boom := errors.New("boom")
+	if _, err := RecognizeSegments(byStrokeCount{err: boom}, cross(50, 50, 60), 300, 300, 5); !errors.Is(err, boom) {
+		t.Fatalf("Expected the recognizer error, got %v", err)
+	}
+}
+
+func TestRecognizeSegmentsContext_StopsAtDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	rec := &ctxRecognizer{stopped: make(chan struct{})}
+	strokes := append([]Stroke{line(250, 50)}, cross(50, 50, 60)...)
+	if _, err := RecognizeSegmentsContext(ctx, rec, strokes, 300, 300, 5); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the deadline, got %v", err)
+	}
+	select {
+	case <-rec.stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the segment's recognition to be cancelled")
+	}
+}
@@ -1,16 +1,59 @@
 package recognize
 
 import (
+	"fmt"
 	"math"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 // SimpleRecognizer provides basic stroke pattern matching without external dependencies
-type SimpleRecognizer struct{}
+type SimpleRecognizer struct {
+	// ComplexCandidates are suggested for every 4+ stroke input; nil uses DefaultComplexCandidates
+	ComplexCandidates []Candidate
+	// NoStrokeTieBreak keeps equal-score candidates in insertion order instead of preferring
+	// those whose canonical stroke count matches the drawing
+	NoStrokeTieBreak bool
+	// MinDensity is the share of the canvas that must hold ink, measured on the ONNX recognizer's
+	// raster, before any candidate is suggested; 0 disables the check, as does an unknown canvas size
+	MinDensity float64
+}
+
+// DefaultComplexCandidates is the generic 4+ stroke suggestion set
+var DefaultComplexCandidates = []Candidate{
+	{Text: "国", Score: 0.5}, // country
+	{Text: "学", Score: 0.4}, // study
+	{Text: "生", Score: 0.3}, // life
+}
+
+// ParseCandidates reads a "text:score,text:score" list such as "国:0.5,学:0.4"
+func ParseCandidates(spec string) ([]Candidate, error) {
+	var out []Candidate
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		text, score, ok := strings.Cut(item, ":")
+		if !ok || text == "" {
+			return nil, fmt.Errorf("candidate %q: want text:score", item)
+		}
+		f, err := strconv.ParseFloat(score, 64)
+		if err != nil || f < 0 || f > 1 {
+			return nil, fmt.Errorf("candidate %q: score must be a number in [0,1]", item)
+		}
+		out = append(out, Candidate{Text: text, Score: f})
+	}
+	return out, nil
+}
 
 func NewSimpleRecognizer() *SimpleRecognizer {
 	return &SimpleRecognizer{}
 }
 
+func (s *SimpleRecognizer) Name() string { return "simple" }
+
 func (s *SimpleRecognizer) Close() error {
 	return nil
 }
@@ -86,6 +129,11 @@ func (s *SimpleRecognizer) Recognize(strokes []Stroke, width, height int, topN i
 	if len(strokes) == 0 {
 		return []Candidate{}, nil
 	}
+	if s.MinDensity > 0 && width > 0 && height > 0 {
+		tensor, err := StrokesToTensor(strokes, width, height)
+		if err != nil { return nil, err }
+		if nearlyBlank(tensor, s.MinDensity) { return []Candidate{}, nil }
+	}
 	
 	// Analyze stroke patterns
 	totalPoints := 0
@@ -98,12 +146,81 @@ func (s *SimpleRecognizer) Recognize(strokes []Stroke, width, height int, topN i
 		strokeShapes[i] = analyzeStrokeShape(stroke)
 	}
 	
+	candidates := strokePatterns(strokeDirections, strokeShapes)
+	
+	// A drawing being refined may match nothing as a whole; with recency weights the strokes just
+	// drawn are matched instead
+	if len(candidates) == 0 && len(strokes) > 1 && weighted(strokes) {
+		candidates = recentPatterns(strokes, strokeDirections, strokeShapes)
+	}
+	
+	// Complex characters (4+ strokes)
+	if len(strokes) >= 4 {
+		// Analyze complexity patterns
+		horizontalCount := 0
+		verticalCount := 0
+		for _, dir := range strokeDirections {
+			if dir == "horizontal" {
+				horizontalCount++
+			} else if dir == "vertical" {
+				verticalCount++
+			}
+		}
+		
+		if horizontalCount >= 2 && verticalCount >= 2 {
+			candidates = append(candidates,
+				Candidate{Text: "中", Score: 0.6}, // middle
+				Candidate{Text: "田", Score: 0.5}, // field
+			)
+		}
+		
+		complex := s.ComplexCandidates
+		if complex == nil {
+			complex = DefaultComplexCandidates
+		}
+		candidates = append(candidates, complex...)
+	}
+	
+	// Add complexity-based characters
+	if totalPoints > 20 {
+		candidates = append(candidates,
+			Candidate{Text: "書", Score: 0.3}, // write
+			Candidate{Text: "字", Score: 0.2}, // character
+		)
+	}
+	
+	// If no specific matches, provide generic suggestions based on stroke count
+	if len(candidates) == 0 {
+		if len(strokes) == 1 {
+			candidates = append(candidates, Candidate{Text: "一", Score: 0.5})
+		} else if len(strokes) == 2 {
+			candidates = append(candidates, Candidate{Text: "二", Score: 0.5})
+		} else if len(strokes) == 3 {
+			candidates = append(candidates, Candidate{Text: "三", Score: 0.5})
+		} else {
+			candidates = append(candidates, Candidate{Text: "中", Score: 0.4})
+		}
+	}
+	
+	// Best first, so truncating to topN keeps the highest scores
+	rankCandidates(candidates, len(strokes), !s.NoStrokeTieBreak)
+	
+	// Limit to topN results
+	if len(candidates) > topN {
+		candidates = candidates[:topN]
+	}
+	
+	return candidates, nil
+}
+
+// strokePatterns matches the direction and shape sequence of a 1-3 stroke drawing
+func strokePatterns(dirs, shapes []string) []Candidate {
 	candidates := []Candidate{}
 	
 	// Single stroke analysis
-	if len(strokes) == 1 {
-		dir := strokeDirections[0]
-		shape := strokeShapes[0]
+	if len(dirs) == 1 {
+		dir := dirs[0]
+		shape := shapes[0]
 		
 		if dir == "horizontal" && shape == "straight" {
 			candidates = append(candidates, 
@@ -129,8 +246,8 @@ func (s *SimpleRecognizer) Recognize(strokes []Stroke, width, height int, topN i
 	}
 	
 	// Two stroke analysis
-	if len(strokes) == 2 {
-		dir1, dir2 := strokeDirections[0], strokeDirections[1]
+	if len(dirs) == 2 {
+		dir1, dir2 := dirs[0], dirs[1]
 		
 		if dir1 == "horizontal" && dir2 == "horizontal" {
 			candidates = append(candidates,
@@ -151,8 +268,8 @@ func (s *SimpleRecognizer) Recognize(strokes []Stroke, width, height int, topN i
 	}
 	
 	// Three stroke analysis
-	if len(strokes) == 3 {
-		dir1, dir2, dir3 := strokeDirections[0], strokeDirections[1], strokeDirections[2]
+	if len(dirs) == 3 {
+		dir1, dir2, dir3 := dirs[0], dirs[1], dirs[2]
 		
 		if dir1 == "horizontal" && dir2 == "horizontal" && dir3 == "horizontal" {
 			candidates = append(candidates,
@@ -167,58 +284,27 @@ func (s *SimpleRecognizer) Recognize(strokes []Stroke, width, height int, topN i
 		}
 	}
 	
-	// Complex characters (4+ strokes)
-	if len(strokes) >= 4 {
-		// Analyze complexity patterns
-		horizontalCount := 0
-		verticalCount := 0
-		for _, dir := range strokeDirections {
-			if dir == "horizontal" {
-				horizontalCount++
-			} else if dir == "vertical" {
-				verticalCount++
-			}
-		}
-		
-		if horizontalCount >= 2 && verticalCount >= 2 {
-			candidates = append(candidates,
-				Candidate{Text: "中", Score: 0.6}, // middle
-				Candidate{Text: "田", Score: 0.5}, // field
-			)
-		}
-		
-		candidates = append(candidates,
-			Candidate{Text: "国", Score: 0.5}, // country
-			Candidate{Text: "学", Score: 0.4}, // study
-			Candidate{Text: "生", Score: 0.3}, // life
-		)
-	}
-	
-	// Add complexity-based characters
-	if totalPoints > 20 {
-		candidates = append(candidates,
-			Candidate{Text: "書", Score: 0.3}, // write
-			Candidate{Text: "字", Score: 0.2}, // character
-		)
-	}
-	
-	// If no specific matches, provide generic suggestions based on stroke count
-	if len(candidates) == 0 {
-		if len(strokes) == 1 {
-			candidates = append(candidates, Candidate{Text: "一", Score: 0.5})
-		} else if len(strokes) == 2 {
-			candidates = append(candidates, Candidate{Text: "二", Score: 0.5})
-		} else if len(strokes) == 3 {
-			candidates = append(candidates, Candidate{Text: "三", Score: 0.5})
-		} else {
-			candidates = append(candidates, Candidate{Text: "中", Score: 0.4})
-		}
-	}
-	
-	// Limit to topN results
-	if len(candidates) > topN {
-		candidates = candidates[:topN]
+	return candidates
+}
+
+// recentPatterns matches the longest run of latest strokes that forms a pattern, scaling its
+// scores by the share of the drawing's recency weight those strokes carry. Strokes are taken in
+// weight order, as stored order need not be drawing order.
+func recentPatterns(strokes []Stroke, dirs, shapes []string) []Candidate {
+	order := make([]int, len(strokes))
+	for i := range order { order[i] = i }
+	sort.SliceStable(order, func(i, j int) bool { return strokeWeight(strokes[order[i]]) < strokeWeight(strokes[order[j]]) })
+	total := 0.0
+	for _, s := range strokes { total += strokeWeight(s) }
+	for k := min(3, len(strokes)-1); k >= 1; k-- {
+		recent := order[len(order)-k:]
+		rd, rs := make([]string, k), make([]string, k)
+		share := 0.0
+		for i, j := range recent { rd[i], rs[i] = dirs[j], shapes[j]; share += strokeWeight(strokes[j]) }
+		cands := strokePatterns(rd, rs)
+		if len(cands) == 0 { continue }
+		for i := range cands { cands[i].Score *= share / total }
+		return cands
 	}
-	
-	return candidates, nil
+	return nil
 }
@@ -230,3 +230,92 @@ func TestSimpleRecognizer_Close(t *testing.T) {
 // that may not be exposed in the current implementation.
 // These tests are commented out until the methods are made public or
 // the tests are restructured to test the public interface.
+
+// complexBoard draws 田-like strokes: two horizontals, two verticals, plenty of points
+func complexBoard() []Stroke {
+	line := func(x0, y0, x1, y1 float64) Stroke {
+		var pts []Point
+		for i := 0; i <= 10; i++ {
+			f := float64(i) / 10
+			pts = append(pts, Point{X: x0 + (x1-x0)*f, Y: y0 + (y1-y0)*f})
+		}
+		return Stroke{Points: pts}
+	}
+	return []Stroke{line(50, 100, 250, 100), line(50, 200, 250, 200), line(100, 50, 100, 250), line(200, 50, 200, 250)}
+}
+
+func TestSimpleRecognizer_Recognize_ComplexTopNKeepsHighestScores(t *testing.T) {
+	recognizer := NewSimpleRecognizer()
+	all, err := recognizer.Recognize(complexBoard(), 300, 300, 20)
+	if err != nil {
+		t.Fatalf("Should not return error: %v", err)
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i].Score > all[i-1].Score {
+			t.Fatalf("Candidates should be sorted by score, got %v", all)
+		}
+	}
+
+	top, err := recognizer.Recognize(complexBoard(), 300, 300, 2)
+	if err != nil {
+		t.Fatalf("Should not return error: %v", err)
+	}
+	if len(top) != 2 || top[0].Text != "中" || top[1].Text != "田" {
+		t.Fatalf("Expected [中 田], got %v", top)
+	}
+}
+
+func TestSimpleRecognizer_Recognize_CustomComplexCandidates(t *testing.T) {
+	recognizer := &SimpleRecognizer{ComplexCandidates: []Candidate{{Text: "森", Score: 0.95}, {Text: "林", Score: 0.1}}}
+	top, err := recognizer.Recognize(complexBoard(), 300, 300, 2)
+	if err != nil {
+		t.Fatalf("Should not return error: %v", err)
+	}
+	if len(top) != 2 || top[0].Text != "森" || top[1].Text != "中" {
+		t.Fatalf("Expected [森 中], got %v", top)
+	}
+	for _, c := range top {
+		if c.Text == "国" {
+			t.Fatal("Default complex candidates should be replaced")
+		}
+	}
+}
+
+func TestParseCandidates(t *testing.T) {
+	got, err := ParseCandidates(" 国:0.5, 学:0.4 ")
+	if err != nil {
+		t.Fatalf("Should parse: %v", err)
+	}
+	if len(got) != 2 || got[0].Text != "国" || got[1].Score != 0.4 {
+		t.Fatalf("Unexpected candidates %v", got)
+	}
+	for _, bad := range []string{"国", ":0.5", "国:high", "国:1.5"} {
+		if _, err := ParseCandidates(bad); err == nil {
+			t.Fatalf("Expected error for %q", bad)
+		}
+	}
+}
+
+func TestSimpleRecognizer_Name(t *testing.T) {
+	if got := NewSimpleRecognizer().Name(); got != "simple" {
+		t.Fatalf("Expected simple, got %q", got)
+	}
+}
+
+func TestSimpleRecognizer_Recognize_TiePrefersMatchingStrokeCount(t *testing.T) {
+	complex := []Candidate{{Text: "大", Score: 0.9}, {Text: "水", Score: 0.9}}
+	top, err := (&SimpleRecognizer{ComplexCandidates: complex}).Recognize(complexBoard(), 300, 300, 2)
+	if err != nil {
+		t.Fatalf("Should not return error: %v", err)
+	}
+	if len(top) != 2 || top[0].Text != "水" || top[1].Text != "大" {
+		t.Fatalf("Expected the 4-stroke 水 ahead of 大 for 4 strokes, got %v", top)
+	}
+	top, err = (&SimpleRecognizer{ComplexCandidates: complex, NoStrokeTieBreak: true}).Recognize(complexBoard(), 300, 300, 2)
+	if err != nil {
+		t.Fatalf("Should not return error: %v", err)
+	}
+	if len(top) != 2 || top[0].Text != "大" {
+		t.Fatalf("Expected insertion order with the tie-break off, got %v", top)
+	}
+}
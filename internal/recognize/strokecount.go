@@ -0,0 +1,14 @@
+package recognize
+
+import "sort"
+
+// rankCandidates sorts cands best first. With tieBreak set, a candidate whose canonical stroke
+// count (see characters) equals strokeCount goes ahead of others with the same score; otherwise,
+// and for strokeCount <= 0, ties keep insertion order.
+func rankCandidates(cands []Candidate, strokeCount int, tieBreak bool) {
+	matches := func(c Candidate) bool { return characters[c.Text].Strokes == strokeCount }
+	sort.SliceStable(cands, func(i, j int) bool {
+		if cands[i].Score != cands[j].Score { return cands[i].Score > cands[j].Score }
+		return tieBreak && strokeCount > 0 && matches(cands[i]) && !matches(cands[j])
+	})
+}
@@ -0,0 +1,20 @@
+package recognize
+
+import "testing"
+
+func TestRankCandidates_StrokeCountBreaksTies(t *testing.T) {
+	cands := []Candidate{{Text: "大", Score: 0.5}, {Text: "書", Score: 0.6}, {Text: "中", Score: 0.5}}
+	rankCandidates(cands, 4, true)
+	if cands[0].Text != "書" || cands[1].Text != "中" || cands[2].Text != "大" {
+		t.Fatalf("Expected [書 中 大], got %v", cands)
+	}
+	rankCandidates(cands, 4, false)
+	if cands[1].Text != "中" || cands[2].Text != "大" {
+		t.Fatalf("Expected the tie order to be kept without the tie-break, got %v", cands)
+	}
+	unknown := []Candidate{{Text: "大", Score: 0.5}, {Text: "中", Score: 0.5}}
+	rankCandidates(unknown, 0, true)
+	if unknown[0].Text != "大" {
+		t.Fatalf("Expected insertion order for an unknown stroke count, got %v", unknown)
+	}
+}
@@ -0,0 +1,91 @@
+package recognize
+
+import "math"
+
+// strokeTemplate is one canonical stroke, from start to end, in a unit box centered on the character
+type strokeTemplate struct{ X0, Y0, X1, Y1 float64 }
+
+// character is what the recognizers know about a character they emit: its canonical stroke count
+// and, for some, the strokes themselves in canonical order (then len(Order) == Strokes)
+type character struct {
+	Strokes int
+	Order   []strokeTemplate
+}
+
+// characters is keyed by candidate text
+var characters = map[string]character{
+	"一": {1, []strokeTemplate{{0, 0.5, 1, 0.5}}},
+	"丨": {1, []strokeTemplate{{0.5, 0, 0.5, 1}}},
+	"二": {2, []strokeTemplate{{0.15, 0.25, 0.85, 0.25}, {0, 0.75, 1, 0.75}}},
+	"三": {3, []strokeTemplate{{0.15, 0, 0.85, 0}, {0.2, 0.5, 0.8, 0.5}, {0, 1, 1, 1}}},
+	"十": {2, []strokeTemplate{{0, 0.5, 1, 0.5}, {0.5, 0, 0.5, 1}}},
+	"人": {2, []strokeTemplate{{0.5, 0, 0, 1}, {0.5, 0.4, 1, 1}}},
+	"大": {3, []strokeTemplate{{0, 0.35, 1, 0.35}, {0.5, 0, 0, 1}, {0.5, 0.35, 1, 1}}},
+
+	"｜": {Strokes: 1}, "ー": {Strokes: 1}, "丶": {Strokes: 1}, "。": {Strokes: 1}, "し": {Strokes: 1}, "く": {Strokes: 1}, "O": {Strokes: 1},
+	"ニ": {Strokes: 2}, "＋": {Strokes: 2}, "入": {Strokes: 2},
+	"ミ": {Strokes: 3}, "小": {Strokes: 3}, "川": {Strokes: 3}, "口": {Strokes: 3},
+	"太": {Strokes: 4}, "中": {Strokes: 4}, "水": {Strokes: 4}, "日": {Strokes: 4},
+	"田": {Strokes: 5}, "由": {Strokes: 5}, "生": {Strokes: 5}, "字": {Strokes: 6}, "回": {Strokes: 6},
+	"国": {Strokes: 8}, "学": {Strokes: 8}, "書": {Strokes: 10},
+}
+
+// ScoreStrokeOrder compares the drawn stroke sequence with text's canonical order and returns
+// a score in [0,1]. ok is false when no template exists for text.
+func ScoreStrokeOrder(text string, strokes []Stroke) (score float64, ok bool) {
+	tmpl := characters[text].Order
+	if tmpl == nil { return 0, false }
+
+	drawn := normalizeStrokeEnds(strokes)
+	if len(drawn) == 0 { return 0, true }
+
+	n := len(drawn)
+	if len(tmpl) < n { n = len(tmpl) }
+	total := 0.0
+	for i := 0; i < n; i++ {
+		total += compareStroke(drawn[i], tmpl[i])
+	}
+	// Missing or extra strokes count as zero-score positions
+	longest := len(drawn)
+	if len(tmpl) > longest { longest = len(tmpl) }
+	return total / float64(longest), true
+}
+
+// normalizeStrokeEnds maps each stroke's endpoints into a unit box around all strokes, keeping aspect ratio
+func normalizeStrokeEnds(strokes []Stroke) []strokeTemplate {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, s := range strokes {
+		for _, p := range s.Points {
+			minX, maxX = math.Min(minX, p.X), math.Max(maxX, p.X)
+			minY, maxY = math.Min(minY, p.Y), math.Max(maxY, p.Y)
+		}
+	}
+	size := math.Max(maxX-minX, maxY-minY)
+	if math.IsInf(size, 0) { return nil }
+	if size == 0 { size = 1 }
+	cx, cy := (minX+maxX)/2, (minY+maxY)/2
+	norm := func(p Point) (float64, float64) { return 0.5 + (p.X-cx)/size, 0.5 + (p.Y-cy)/size }
+
+	out := make([]strokeTemplate, 0, len(strokes))
+	for _, s := range strokes {
+		if len(s.Points) == 0 { continue }
+		x0, y0 := norm(s.Points[0])
+		x1, y1 := norm(s.Points[len(s.Points)-1])
+		out = append(out, strokeTemplate{x0, y0, x1, y1})
+	}
+	return out
+}
+
+// compareStroke blends direction agreement and endpoint proximity into [0,1]
+func compareStroke(a, b strokeTemplate) float64 {
+	adx, ady := a.X1-a.X0, a.Y1-a.Y0
+	bdx, bdy := b.X1-b.X0, b.Y1-b.Y0
+	dir := 0.5
+	if la, lb := math.Hypot(adx, ady), math.Hypot(bdx, bdy); la > 0 && lb > 0 {
+		dir = ((adx*bdx+ady*bdy)/(la*lb) + 1) / 2
+	}
+	dist := (math.Hypot(a.X0-b.X0, a.Y0-b.Y0) + math.Hypot(a.X1-b.X1, a.Y1-b.Y1)) / 2
+	pos := math.Max(0, 1-dist)
+	return (dir + pos) / 2
+}
@@ -0,0 +1,57 @@
+package recognize
+
+import "testing"
+
+func TestScoreStrokeOrder_CrossCorrectVsReversed(t *testing.T) {
+	horizontal := Stroke{Points: []Point{{X: 10, Y: 50}, {X: 90, Y: 50}}}
+	vertical := Stroke{Points: []Point{{X: 50, Y: 10}, {X: 50, Y: 90}}}
+
+	correct, ok := ScoreStrokeOrder("十", []Stroke{horizontal, vertical})
+	if !ok {
+		t.Fatal("Expected a template for 十")
+	}
+	reversed, _ := ScoreStrokeOrder("十", []Stroke{vertical, horizontal})
+
+	if correct < 0.9 {
+		t.Fatalf("Expected near-perfect score for correct order, got %f", correct)
+	}
+	if reversed >= correct {
+		t.Fatalf("Reversed order should score lower: correct=%f reversed=%f", correct, reversed)
+	}
+	if reversed > 0.6 {
+		t.Fatalf("Reversed order should score poorly, got %f", reversed)
+	}
+}
+
+func TestScoreStrokeOrder_ReversedDirection(t *testing.T) {
+	forward, _ := ScoreStrokeOrder("一", []Stroke{{Points: []Point{{X: 10, Y: 50}, {X: 90, Y: 50}}}})
+	backward, _ := ScoreStrokeOrder("一", []Stroke{{Points: []Point{{X: 90, Y: 50}, {X: 10, Y: 50}}}})
+	if backward >= forward {
+		t.Fatalf("Right-to-left stroke should score lower: forward=%f backward=%f", forward, backward)
+	}
+}
+
+func TestScoreStrokeOrder_MissingStrokeLowersScore(t *testing.T) {
+	full, _ := ScoreStrokeOrder("十", []Stroke{
+		{Points: []Point{{X: 10, Y: 50}, {X: 90, Y: 50}}},
+		{Points: []Point{{X: 50, Y: 10}, {X: 50, Y: 90}}},
+	})
+	partial, _ := ScoreStrokeOrder("十", []Stroke{{Points: []Point{{X: 10, Y: 50}, {X: 90, Y: 50}}}})
+	if partial >= full {
+		t.Fatalf("Missing stroke should lower score: full=%f partial=%f", full, partial)
+	}
+}
+
+func TestScoreStrokeOrder_UnknownCharacter(t *testing.T) {
+	if _, ok := ScoreStrokeOrder("書", []Stroke{{Points: []Point{{X: 1, Y: 1}}}}); ok {
+		t.Fatal("Expected no template for 書")
+	}
+}
+
+func TestCharacters_OrderMatchesStrokeCount(t *testing.T) {
+	for text, c := range characters {
+		if c.Strokes <= 0 || c.Order != nil && len(c.Order) != c.Strokes {
+			t.Fatalf("%s: expected a positive stroke count matching its %d template strokes, got %d", text, len(c.Order), c.Strokes)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package recognize
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+
+	"github.com/deliium/drawing-board/internal/raster"
+)
+
+// MaxTensorSide bounds each dimension of a client-supplied tensor
+const MaxTensorSide = 512
+
+// ErrTensorUnsupported is returned when no recognizer in the chain accepts raw tensors
+var ErrTensorUnsupported = errors.New("recognizer does not accept tensor input")
+
+// TensorRecognizer is implemented by recognizers that can skip stroke rasterization and
+// work on a row-major width*height grayscale tensor with values in [0,1]
+type TensorRecognizer interface {
+	// RecognizeTensor treats strokeCount <= 0 as unknown and estimates it from the image
+	RecognizeTensor(tensor []float32, width, height, strokeCount, topN int) ([]Candidate, error)
+}
+
+// ValidateTensor checks the dimensions and value range of a client-supplied tensor
+func ValidateTensor(tensor []float32, width, height int) error {
+	if width <= 0 || height <= 0 || width > MaxTensorSide || height > MaxTensorSide {
+		return fmt.Errorf("width and height must be between 1 and %d", MaxTensorSide)
+	}
+	if err := raster.Check(width, height); err != nil {
+		return err
+	}
+	if len(tensor) != width*height {
+		return fmt.Errorf("tensor has %d values, want width*height = %d", len(tensor), width*height)
+	}
+	for i, v := range tensor {
+		if math.IsNaN(float64(v)) || v < 0 || v > 1 {
+			return fmt.Errorf("tensor[%d] = %v is outside [0,1]", i, v)
+		}
+	}
+	return nil
+}
+
+// tensorFits reports whether tensor can be indexed as a width*height grid: both sides positive,
+// the area within raster.MaxPixels so y*width+x cannot overflow, and enough values for every
+// index. The feature detectors check it once up front instead of bounds-checking each pixel.
+func tensorFits(tensor []float32, width, height int) bool {
+	return width > 0 && height > 0 && raster.Check(width, height) == nil && len(tensor) >= width*height
+}
+
+// TensorPNG encodes a width*height tensor as a grayscale PNG, ink (1) white on black, so a
+// client can see exactly what a recognizer was given
+func TensorPNG(tensor []float32, width, height int) ([]byte, error) {
+	if err := raster.Check(width, height); err != nil { return nil, err }
+	if len(tensor) != width*height { return nil, fmt.Errorf("tensor has %d values, want width*height = %d", len(tensor), width*height) }
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for i, v := range tensor { img.Pix[i] = uint8(math.Round(float64(min(max(v, 0), 1)) * 255)) }
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil { return nil, err }
+	return buf.Bytes(), nil
+}
+
+// RecognizeTensor runs the model, or the feature analysis, directly on a caller-rasterized image
+func (r *ONNXRecognizer) RecognizeTensor(tensor []float32, width, height, strokeCount, topN int) ([]Candidate, error) {
+	if topN <= 0 {
+		topN = 10
+	}
+	if err := ValidateTensor(tensor, width, height); err != nil {
+		return nil, err
+	}
+	if nearlyBlank(tensor, r.MinDensity) { return []Candidate{}, nil }
+	if r.session != nil { return r.infer(tensor, width, height, strokeCount, topN) }
+	features := r.analyzeTensorFeatures(tensor, width, height)
+	if features["density"] == 0 {
+		return []Candidate{}, nil
+	}
+	if strokeCount <= 0 {
+		strokeCount = estimateStrokeCount(features)
+	}
+	return r.generateCandidatesFromFeatures(features, strokeCount, topN), nil
+}
+
+// estimateStrokeCount guesses strokes from detected straight lines; curves and dots count as one
+func estimateStrokeCount(features map[string]float64) int {
+	n := int(features["horizontal_lines"] + features["vertical_lines"])
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// RecognizeTensor tries Primary, then Fallback, skipping either when it cannot take tensors
+func (f *FallbackRecognizer) RecognizeTensor(tensor []float32, width, height, strokeCount, topN int) ([]Candidate, error) {
+	err := ErrTensorUnsupported
+	if tr, ok := f.Primary.(TensorRecognizer); ok {
+		var cands []Candidate
+		if cands, err = tr.RecognizeTensor(tensor, width, height, strokeCount, topN); err == nil {
+			return cands, nil
+		}
+		RecordFallback(fmt.Sprintf("primary recognizer failed on tensor input: %v", err))
+	}
+	if tr, ok := f.Fallback.(TensorRecognizer); ok {
+		return tr.RecognizeTensor(tensor, width, height, strokeCount, topN)
+	}
+	return nil, err
+}
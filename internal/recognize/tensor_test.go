@@ -0,0 +1,188 @@
+package recognize
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"math"
+	"testing"
+
+	"github.com/deliium/drawing-board/internal/raster"
+)
+
+// paint sets a 3px-thick axis-aligned line in a size*size tensor
+func paint(tensor []float32, size int, horizontal bool, at, from, to int) {
+	for i := from; i < to; i++ {
+		for d := -1; d <= 1; d++ {
+			if horizontal {
+				tensor[(at+d)*size+i] = 1
+			} else {
+				tensor[i*size+at+d] = 1
+			}
+		}
+	}
+}
+
+func TestValidateTensor(t *testing.T) {
+	if err := ValidateTensor(make([]float32, 6), 3, 2); err != nil {
+		t.Fatalf("Valid tensor rejected: %v", err)
+	}
+	bad := map[string]struct {
+		tensor []float32
+		w, h   int
+	}{
+		"short":     {make([]float32, 5), 3, 2},
+		"zero dim":  {nil, 0, 2},
+		"too large": {make([]float32, MaxTensorSide+1), MaxTensorSide + 1, 1},
+		"negative":  {[]float32{0, -0.1}, 2, 1},
+		"above one": {[]float32{0, 1.5}, 2, 1},
+		"nan":       {[]float32{0, float32(math.NaN())}, 2, 1},
+	}
+	for name, tc := range bad {
+		if err := ValidateTensor(tc.tensor, tc.w, tc.h); err == nil {
+			t.Fatalf("Expected error for %s tensor", name)
+		}
+	}
+}
+
+func TestONNXRecognizer_RecognizeTensor(t *testing.T) {
+	recognizer, err := NewONNXRecognizer("test_model.onnx")
+	if err != nil {
+		t.Fatalf("Failed to create recognizer: %v", err)
+	}
+	const size = 100
+
+	line := make([]float32, size*size)
+	paint(line, size, true, 50, 10, 90)
+	features := recognizer.analyzeTensorFeatures(line, size, size)
+	if features["horizontal_lines"] != 1 || features["vertical_lines"] != 0 {
+		t.Fatalf("Expected one horizontal line, got h=%v v=%v", features["horizontal_lines"], features["vertical_lines"])
+	}
+	cands, err := recognizer.RecognizeTensor(line, size, size, 0, 3)
+	if err != nil {
+		t.Fatalf("Should not return error: %v", err)
+	}
+	if len(cands) == 0 || cands[0].Text != "一" {
+		t.Fatalf("Expected 一 first, got %v", cands)
+	}
+
+	cross := make([]float32, size*size)
+	paint(cross, size, true, 50, 10, 90)
+	paint(cross, size, false, 50, 10, 90)
+	cands, err = recognizer.RecognizeTensor(cross, size, size, 0, 3)
+	if err != nil {
+		t.Fatalf("Should not return error: %v", err)
+	}
+	if len(cands) == 0 || cands[0].Text != "十" {
+		t.Fatalf("Expected 十 first, got %v", cands)
+	}
+
+	if cands, err := recognizer.RecognizeTensor(make([]float32, size*size), size, size, 0, 3); err != nil || len(cands) != 0 {
+		t.Fatalf("Expected no candidates for a blank tensor, got %v (%v)", cands, err)
+	}
+	if _, err := recognizer.RecognizeTensor(line, size, size-1, 0, 3); err == nil {
+		t.Fatal("Expected error for mismatched dimensions")
+	}
+}
+
+func TestFallbackRecognizer_RecognizeTensor(t *testing.T) {
+	onnx, err := NewONNXRecognizer("test_model.onnx")
+	if err != nil {
+		t.Fatalf("Failed to create recognizer: %v", err)
+	}
+	tensor := make([]float32, 100*100)
+	paint(tensor, 100, true, 50, 10, 90)
+
+	f := NewFallbackRecognizer(NewSimpleRecognizer(), onnx, 0)
+	if cands, err := f.RecognizeTensor(tensor, 100, 100, 1, 3); err != nil || len(cands) == 0 {
+		t.Fatalf("Expected fallback to handle tensor input, got %v (%v)", cands, err)
+	}
+
+	f = NewFallbackRecognizer(NewSimpleRecognizer(), NewSimpleRecognizer(), 0)
+	if _, err := f.RecognizeTensor(tensor, 100, 100, 1, 3); !errors.Is(err, ErrTensorUnsupported) {
+		t.Fatalf("Expected ErrTensorUnsupported, got %v", err)
+	}
+}
+
+func TestRasterPathsRespectMaxRasterPixels(t *testing.T) {
+	defer raster.SetMaxPixels(0)
+	raster.SetMaxPixels(50 * 50)
+	recognizer, err := NewONNXRecognizer("test_model.onnx")
+	if err != nil {
+		t.Fatalf("Failed to create recognizer: %v", err)
+	}
+	strokes := []Stroke{{Points: []Point{{X: 1, Y: 1}, {X: 40, Y: 1}}}}
+
+	if _, err := recognizer.strokesToTensor(strokes, 50, 50); err != nil {
+		t.Fatalf("Expected 50x50 to fit the limit: %v", err)
+	}
+	if _, err := recognizer.strokesToTensor(strokes, 51, 50); !errors.Is(err, raster.ErrTooLarge) {
+		t.Fatalf("Expected ErrTooLarge from strokesToTensor, got %v", err)
+	}
+	if _, err := recognizer.Recognize(strokes, 300, 300, 3); !errors.Is(err, raster.ErrTooLarge) {
+		t.Fatalf("Expected ErrTooLarge from Recognize, got %v", err)
+	}
+	if err := ValidateTensor(make([]float32, 60*60), 60, 60); !errors.Is(err, raster.ErrTooLarge) {
+		t.Fatalf("Expected ErrTooLarge from ValidateTensor, got %v", err)
+	}
+}
+
+func TestTensorPNG(t *testing.T) {
+	data, err := TensorPNG([]float32{0, 0.5, 1, 2}, 2, 2)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Expected a PNG, got %v", err)
+	}
+	gray, ok := img.(*image.Gray)
+	if !ok || gray.Bounds().Dx() != 2 || gray.Bounds().Dy() != 2 {
+		t.Fatalf("Expected a 2x2 grayscale image, got %T %v", img, img.Bounds())
+	}
+	if got := []uint8{gray.GrayAt(0, 0).Y, gray.GrayAt(1, 0).Y, gray.GrayAt(0, 1).Y, gray.GrayAt(1, 1).Y}; got[0] != 0 || got[1] != 128 || got[2] != 255 || got[3] != 255 {
+		t.Fatalf("Expected [0 128 255 255], got %v", got)
+	}
+	if _, err := TensorPNG([]float32{0}, 2, 2); err == nil {
+		t.Fatal("Expected an error for a tensor of the wrong size")
+	}
+}
+
+func TestFeatureDetectors_BoundaryDimensionsDoNotPanic(t *testing.T) {
+	recognizer, err := NewONNXRecognizer("test_model.onnx")
+	if err != nil {
+		t.Fatalf("Failed to create recognizer: %v", err)
+	}
+	line := make([]float32, raster.DefaultMaxPixels)
+	for i := range line[:100] { line[i] = 1 }
+	for _, c := range []struct {
+		name          string
+		tensor        []float32
+		width, height int
+	}{
+		{"zero width", make([]float32, 4), 0, 4},
+		{"negative height", make([]float32, 4), 4, -1},
+		{"short tensor", make([]float32, 10), 4, 4},
+		{"oversized area", make([]float32, 4), 1 << 31, 1 << 31},
+		{"overflowing product", make([]float32, 4), math.MaxInt, 2},
+		{"single row at the limit", line, raster.DefaultMaxPixels, 1},
+		{"single column at the limit", line, 1, raster.DefaultMaxPixels},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			features := recognizer.analyzeTensorFeatures(c.tensor, c.width, c.height)
+			fits := tensorFits(c.tensor, c.width, c.height)
+			if !fits && len(features) != 0 {
+				t.Fatalf("Expected no features for a tensor that does not fit, got %v", features)
+			}
+			if fits && features["density"] == 0 {
+				t.Fatalf("Expected the line to be seen, got %v", features)
+			}
+			resizeTensor(c.tensor, c.width, c.height, 28, 28)
+			describeAnalysis(nil, c.tensor, c.width, c.height, features, nil)
+			if _, err := TensorPNG(c.tensor, c.width, c.height); err == nil && !fits {
+				t.Fatalf("Expected TensorPNG to refuse a %dx%d tensor of %d values", c.width, c.height, len(c.tensor))
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+//go:build ignore
+
+// gen_tiny_model writes tiny_model.onnx and tiny_model.labels, a hand-weighted linear classifier
+// used by the ONNX recognizer tests. It encodes the protobuf by hand so regenerating the model
+// needs nothing beyond the Go toolchain:
+//
+//	go run gen_tiny_model.go
+package main
+
+import (
+	"encoding/binary"
+	"log"
+	"math"
+	"os"
+	"strings"
+)
+
+const side = 28
+
+// labels index the output logits; each one gets a weight mask that lights up on its shape
+var labels = []string{"一", "丨", "口"}
+
+func mask(label string, x, y int) float32 {
+	mid := side / 2
+	switch label {
+	case "一":
+		if y >= mid-2 && y <= mid+1 && x >= 4 && x < side-4 { return 1 }
+	case "丨":
+		if x >= mid-2 && x <= mid+1 && y >= 4 && y < side-4 { return 1 }
+	case "口":
+		inside := x >= 4 && x < side-4 && y >= 4 && y < side-4
+		if inside && (x < 8 || x >= side-8 || y < 8 || y >= side-8) { return 1 }
+	}
+	return -0.1
+}
+
+type buf []byte
+
+func (b buf) varint(field int, v uint64) buf {
+	b = binary.AppendUvarint(b, uint64(field<<3))
+	return binary.AppendUvarint(b, v)
+}
+
+func (b buf) bytes(field int, v []byte) buf {
+	b = binary.AppendUvarint(b, uint64(field<<3|2))
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func (b buf) str(field int, s string) buf { return b.bytes(field, []byte(s)) }
+
+func valueInfo(name string, dims ...int64) []byte {
+	var shape buf
+	for _, d := range dims { shape = shape.bytes(1, buf(nil).varint(1, uint64(d))) }
+	tensor := buf(nil).varint(1, 1).bytes(2, shape) // elem_type FLOAT
+	return buf(nil).str(1, name).bytes(2, buf(nil).bytes(1, tensor))
+}
+
+func node(op string, inputs []string, output string) []byte {
+	var n buf
+	for _, in := range inputs { n = n.str(1, in) }
+	return n.str(2, output).str(3, strings.ToLower(op)).str(4, op)
+}
+
+func main() {
+	weights := make([]byte, 0, side*side*len(labels)*4)
+	for y := 0; y < side; y++ {
+		for x := 0; x < side; x++ {
+			for _, l := range labels { weights = binary.LittleEndian.AppendUint32(weights, math.Float32bits(mask(l, x, y))) }
+		}
+	}
+	// TensorProto: dims, data_type FLOAT, name, raw_data
+	init := buf(nil).varint(1, side*side).varint(1, uint64(len(labels))).varint(2, 1).str(8, "weights").bytes(9, weights)
+	graph := buf(nil).
+		bytes(1, node("Flatten", []string{"input"}, "flat")).
+		bytes(1, node("MatMul", []string{"flat", "weights"}, "output")).
+		str(2, "tiny").
+		bytes(5, init).
+		bytes(11, valueInfo("input", 1, 1, side, side)).
+		bytes(12, valueInfo("output", 1, int64(len(labels))))
+	model := buf(nil).varint(1, 7).str(2, "drawing-board").bytes(7, graph).bytes(8, buf(nil).str(1, "").varint(2, 13))
+	if err := os.WriteFile("tiny_model.onnx", model, 0o644); err != nil { log.Fatal(err) }
+	if err := os.WriteFile("tiny_model.labels", []byte(strings.Join(labels, "\n")+"\n"), 0o644); err != nil { log.Fatal(err) }
+}
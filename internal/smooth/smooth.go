@@ -0,0 +1,60 @@
+package smooth
+
+import (
+	"fmt"
+	"math"
+)
+
+// Methods accepted by Parse
+const (
+	None     = "none"
+	Moving   = "moving"   // unweighted moving average
+	Gaussian = "gaussian" // Gaussian-weighted average with sigma = Window/6
+)
+
+type Point struct{ X, Y float64 }
+
+// Smoothing averages each point of a stroke with its neighbours. The zero value is off.
+type Smoothing struct {
+	Method string
+	Window int // points in the averaging window; values below 3 disable smoothing
+}
+
+// Parse validates a method name and window from configuration
+func Parse(method string, window int) (Smoothing, error) {
+	switch method {
+	case "", None: return Smoothing{}, nil
+	case Moving, Gaussian:
+		if window < 3 { return Smoothing{}, fmt.Errorf("smoothing window must be at least 3, got %d", window) }
+		return Smoothing{Method: method, Window: window}, nil
+	}
+	return Smoothing{}, fmt.Errorf("unknown smoothing method %q (want none, moving or gaussian)", method)
+}
+
+// Enabled reports whether Apply changes anything
+func (s Smoothing) Enabled() bool {
+	return (s.Method == Moving || s.Method == Gaussian) && s.Window >= 3
+}
+
+// Apply returns a smoothed copy of points. The first and last points are kept exactly, and
+// the window shrinks symmetrically near the ends so the stroke is not pulled toward its middle.
+func (s Smoothing) Apply(points []Point) []Point {
+	if !s.Enabled() || len(points) < 3 { return points }
+	half := s.Window / 2
+	sigma := float64(s.Window) / 6
+	out := make([]Point, len(points))
+	out[0], out[len(points)-1] = points[0], points[len(points)-1]
+	for i := 1; i < len(points)-1; i++ {
+		k := min(half, i, len(points)-1-i)
+		var sx, sy, sw float64
+		for j := -k; j <= k; j++ {
+			w := 1.0
+			if s.Method == Gaussian { w = math.Exp(-float64(j*j) / (2 * sigma * sigma)) }
+			sx += w * points[i+j].X
+			sy += w * points[i+j].Y
+			sw += w
+		}
+		out[i] = Point{X: sx / sw, Y: sy / sw}
+	}
+	return out
+}
@@ -0,0 +1,44 @@
+package validate
+
+import (
+	"regexp"
+	"strings"
+)
+
+// FieldError describes one problem with one request field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors collects every validation failure so a request can report them all at once
+type Errors []FieldError
+
+func (e *Errors) Add(field, message string) { *e = append(*e, FieldError{Field: field, Message: message}) }
+
+// Check records message for field when ok is false
+func (e *Errors) Check(ok bool, field, message string) {
+	if !ok { e.Add(field, message) }
+}
+
+func (e Errors) Any() bool { return len(e) > 0 }
+
+func (e Errors) Error() string {
+	parts := make([]string, 0, len(e))
+	for _, fe := range e { parts = append(parts, fe.Field+": "+fe.Message) }
+	return strings.Join(parts, "; ")
+}
+
+// Response is the 400 body shape: { "errors": [{field, message}] }
+func (e Errors) Response() map[string]interface{} { return map[string]interface{}{"errors": e} }
+
+var hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// IsHexColor reports whether s is a #rgb or #rrggbb color
+func IsHexColor(s string) bool { return hexColor.MatchString(s) }
+
+// IsEmail is a deliberately loose shape check: something@something.tld
+func IsEmail(s string) bool {
+	at := strings.LastIndex(s, "@")
+	return at > 0 && at < len(s)-1 && strings.Contains(s[at+1:], ".") && !strings.ContainsAny(s, " \t\r\n")
+}
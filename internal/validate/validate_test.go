@@ -0,0 +1,53 @@
+package validate
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestErrors_CollectsAll(t *testing.T) {
+	var errs Errors
+	errs.Check(false, "email", "is required")
+	errs.Check(true, "color", "never added")
+	errs.Check(false, "password", "is too short")
+
+	if !errs.Any() {
+		t.Fatal("Expected errors to be reported")
+	}
+	if len(errs) != 2 {
+		t.Fatalf("Expected 2 errors, got %d", len(errs))
+	}
+
+	data, err := json.Marshal(errs.Response())
+	if err != nil {
+		t.Fatalf("Failed to marshal response: %v", err)
+	}
+	want := `{"errors":[{"field":"email","message":"is required"},{"field":"password","message":"is too short"}]}`
+	if string(data) != want {
+		t.Fatalf("Expected %s, got %s", want, data)
+	}
+}
+
+func TestIsHexColor(t *testing.T) {
+	for _, c := range []string{"#000", "#1d4ed8", "#FFFFFF"} {
+		if !IsHexColor(c) {
+			t.Fatalf("Expected %q to be valid", c)
+		}
+	}
+	for _, c := range []string{"", "red", "#12", "#gggggg", "1d4ed8"} {
+		if IsHexColor(c) {
+			t.Fatalf("Expected %q to be invalid", c)
+		}
+	}
+}
+
+func TestIsEmail(t *testing.T) {
+	if !IsEmail("user@example.com") {
+		t.Fatal("Expected valid email")
+	}
+	for _, e := range []string{"", "user", "@example.com", "user@", "user@localhost", "a b@example.com"} {
+		if IsEmail(e) {
+			t.Fatalf("Expected %q to be invalid", e)
+		}
+	}
+}
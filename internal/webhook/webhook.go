@@ -0,0 +1,106 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Event types delivered to the webhook receiver
+const (
+	StrokeCreated = "stroke.created"
+	StrokeDeleted = "stroke.deleted"
+	StrokesCleared = "strokes.cleared"
+)
+
+type Event struct {
+	Type     string      `json:"type"`
+	UserID   int64       `json:"userId"`
+	StrokeID int64       `json:"strokeId,omitempty"`
+	Stroke   interface{} `json:"stroke,omitempty"`
+	AtUnixMs int64       `json:"atUnixMs"`
+}
+
+// Notifier POSTs events to URL from a background worker so callers never block on delivery.
+// A nil *Notifier is valid and drops every event, which is how webhooks stay disabled by default.
+type Notifier struct {
+	URL        string
+	Client     *http.Client
+	MaxRetries int
+	Backoff    time.Duration
+
+	queue chan Event
+	wg    sync.WaitGroup
+
+	mu     sync.Mutex // guards sends on queue against Close
+	closed bool
+}
+
+func New(url string, queueSize int) *Notifier {
+	if queueSize <= 0 { queueSize = 256 }
+	n := &Notifier{
+		URL:        url,
+		Client:     &http.Client{Timeout: 10 * time.Second},
+		MaxRetries: 3,
+		Backoff:    500 * time.Millisecond,
+		queue:      make(chan Event, queueSize),
+	}
+	n.wg.Add(1)
+	go n.run()
+	return n
+}
+
+// Notify enqueues e for delivery, dropping it if the queue is full or the Notifier is closed
+func (n *Notifier) Notify(e Event) {
+	if n == nil { return }
+	if e.AtUnixMs == 0 { e.AtUnixMs = time.Now().UnixMilli() }
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed { return }
+	select {
+	case n.queue <- e:
+	default:
+		log.Printf("webhook queue full, dropping %s event", e.Type)
+	}
+}
+
+// Close stops accepting events and waits for queued ones to be delivered. Calling it again is a
+// no-op.
+func (n *Notifier) Close() {
+	if n == nil { return }
+	n.mu.Lock()
+	if !n.closed { n.closed = true; close(n.queue) }
+	n.mu.Unlock()
+	n.wg.Wait()
+}
+
+func (n *Notifier) run() {
+	defer n.wg.Done()
+	for e := range n.queue {
+		if err := n.deliver(e); err != nil { log.Printf("webhook %s: %v", e.Type, err) }
+	}
+}
+
+func (n *Notifier) deliver(e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil { return err }
+	backoff := n.Backoff
+	for attempt := 0; ; attempt++ {
+		err = n.post(body)
+		if err == nil || attempt >= n.MaxRetries { return err }
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (n *Notifier) post(body []byte) error {
+	resp, err := n.Client.Post(n.URL, "application/json", bytes.NewReader(body))
+	if err != nil { return err }
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 { return fmt.Errorf("receiver returned %d", resp.StatusCode) }
+	return nil
+}
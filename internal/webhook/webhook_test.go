@@ -0,0 +1,99 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeReceiver struct {
+	mu     sync.Mutex
+	events []Event
+	fail   int // number of requests to reject before accepting
+}
+
+func (f *fakeReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail > 0 {
+		f.fail--
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	var e Event
+	if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	f.events = append(f.events, e)
+}
+
+func (f *fakeReceiver) received() []Event {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Event(nil), f.events...)
+}
+
+func TestNotifier_DeliversPayload(t *testing.T) {
+	recv := &fakeReceiver{}
+	srv := httptest.NewServer(recv)
+	defer srv.Close()
+
+	n := New(srv.URL, 8)
+	n.Notify(Event{Type: StrokeCreated, UserID: 7, StrokeID: 42})
+	n.Notify(Event{Type: StrokesCleared, UserID: 7})
+	n.Close()
+
+	events := recv.received()
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+	if events[0].Type != StrokeCreated || events[0].UserID != 7 || events[0].StrokeID != 42 {
+		t.Fatalf("Unexpected first event: %+v", events[0])
+	}
+	if events[0].AtUnixMs == 0 {
+		t.Fatal("Event timestamp should be set")
+	}
+	if events[1].Type != StrokesCleared {
+		t.Fatalf("Expected cleared event, got %s", events[1].Type)
+	}
+}
+
+func TestNotifier_RetriesFailedDelivery(t *testing.T) {
+	recv := &fakeReceiver{fail: 2}
+	srv := httptest.NewServer(recv)
+	defer srv.Close()
+
+	n := New(srv.URL, 8)
+	n.Backoff = time.Millisecond
+	n.Notify(Event{Type: StrokeDeleted, UserID: 1, StrokeID: 3})
+	n.Close()
+
+	events := recv.received()
+	if len(events) != 1 || events[0].StrokeID != 3 {
+		t.Fatalf("Expected the event to arrive after retries, got %+v", events)
+	}
+}
+
+func TestNotifier_NilIsDisabled(t *testing.T) {
+	var n *Notifier
+	n.Notify(Event{Type: StrokeCreated})
+	n.Close()
+}
+
+func TestNotifier_NotifyAfterCloseIsDropped(t *testing.T) {
+	recv := &fakeReceiver{}
+	srv := httptest.NewServer(recv)
+	defer srv.Close()
+
+	n := New(srv.URL, 8)
+	n.Close()
+	n.Notify(Event{Type: StrokeCreated, UserID: 1})
+	n.Close()
+	if events := recv.received(); len(events) != 0 {
+		t.Fatalf("Expected no events after Close, got %+v", events)
+	}
+}
@@ -0,0 +1,43 @@
+package ws
+
+import "github.com/gorilla/websocket"
+
+// Activity tells collaborators that someone has a stroke in progress, without sending its points
+type Activity struct {
+	UserID   int64  `json:"userId"`
+	ClientID string `json:"clientId"`
+	Drawing  bool   `json:"drawing"`
+	DisplayName string `json:"displayName,omitempty"`
+}
+
+// startDrawing marks c as drawing, reporting false when it already was so repeats are not rebroadcast
+func (h *Hub) startDrawing(c *websocket.Conn, userID int64, clientID, displayName string) (Activity, bool) {
+	a := Activity{UserID: userID, ClientID: clientID, Drawing: true, DisplayName: displayName}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.drawing[c]; ok { return a, false }
+	h.drawing[c] = a
+	return a, true
+}
+
+// endDrawing clears c's drawing state, returning the cleared activity and whether there was one
+func (h *Hub) endDrawing(c *websocket.Conn) (Activity, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	a, ok := h.drawing[c]
+	if !ok { return Activity{}, false }
+	delete(h.drawing, c)
+	a.Drawing = false
+	return a, true
+}
+
+// drawingRoster lists everyone currently drawing in room, for the join snapshot
+func (h *Hub) drawingRoster(room int64) []Activity {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([]Activity, 0, len(h.drawing))
+	for c, a := range h.drawing {
+		if h.roomLocked(c) == room { out = append(out, a) }
+	}
+	return out
+}
@@ -0,0 +1,116 @@
+package ws
+
+import (
+	"testing"
+
+	"github.com/deliium/drawing-board/internal/auth"
+	"github.com/deliium/drawing-board/internal/db"
+	"github.com/gorilla/websocket"
+)
+
+func TestHub_StartEndDrawingState(t *testing.T) {
+	hub := NewHub(&db.Store{}, &auth.Service{})
+	conn := &websocket.Conn{}
+
+	if _, changed := hub.startDrawing(conn, 7, "abc", ""); !changed {
+		t.Fatal("First stroke_start should change state")
+	}
+	if _, changed := hub.startDrawing(conn, 7, "abc", ""); changed {
+		t.Fatal("Repeated stroke_start should not change state")
+	}
+	if roster := hub.drawingRoster(Lobby); len(roster) != 1 || roster[0].UserID != 7 || !roster[0].Drawing {
+		t.Fatalf("Expected user 7 drawing, got %+v", roster)
+	}
+	a, changed := hub.endDrawing(conn)
+	if !changed || a.Drawing || a.ClientID != "abc" {
+		t.Fatalf("Expected cleared activity for abc, got %+v (%v)", a, changed)
+	}
+	if _, changed := hub.endDrawing(conn); changed {
+		t.Fatal("stroke_end without a stroke in progress should be a no-op")
+	}
+}
+
+func TestHub_DrawingIndicatorBroadcastsToOthers(t *testing.T) {
+	hub, srv, cookies := newAuthedHub(t)
+	drawer := dialAuthed(t, srv, "", cookies)
+	watcher := dialAuthed(t, srv, "", cookies)
+	waitForClients(t, hub, 2)
+
+	if err := drawer.WriteJSON(message{Type: "stroke_start", Activity: &Activity{ClientID: "pen"}}); err != nil {
+		t.Fatalf("Failed to send stroke_start: %v", err)
+	}
+	m := readMessage(t, watcher)
+	if m.Type != "drawing" || m.Activity == nil || !m.Activity.Drawing || m.Activity.ClientID != "pen" || m.Activity.UserID == 0 {
+		t.Fatalf("Expected drawing=true for pen, got %+v", m.Activity)
+	}
+
+	late := dialAuthed(t, srv, "?snapshot=full", cookies)
+	if snap := readMessage(t, late); len(snap.Activities) != 1 || snap.Activities[0].ClientID != "pen" {
+		t.Fatalf("Expected pen in snapshot activities, got %+v", snap.Activities)
+	}
+
+	if err := drawer.WriteJSON(message{Type: "stroke_end"}); err != nil {
+		t.Fatalf("Failed to send stroke_end: %v", err)
+	}
+	m = readMessage(t, watcher)
+	if m.Type != "drawing" || m.Activity == nil || m.Activity.Drawing {
+		t.Fatalf("Expected drawing=false, got %+v", m.Activity)
+	}
+
+	// The drawer never hears its own indicator: the relayed cursor is the first thing it reads
+	if err := drawer.WriteJSON(message{Type: "cursor", Cursor: &Cursor{ClientID: "pen"}}); err != nil {
+		t.Fatalf("Failed to send cursor: %v", err)
+	}
+	if m := readMessage(t, drawer); m.Type != "cursor" {
+		t.Fatalf("Expected cursor, got %q", m.Type)
+	}
+}
+
+func TestHub_DisconnectWhileDrawingClearsIndicator(t *testing.T) {
+	hub, srv, cookies := newAuthedHub(t)
+	drawer := dialAuthed(t, srv, "", cookies)
+	watcher := dialAuthed(t, srv, "", cookies)
+	waitForClients(t, hub, 2)
+
+	if err := drawer.WriteJSON(message{Type: "stroke_start", Activity: &Activity{ClientID: "pen"}}); err != nil {
+		t.Fatalf("Failed to send stroke_start: %v", err)
+	}
+	if m := readMessage(t, watcher); m.Activity == nil || !m.Activity.Drawing {
+		t.Fatalf("Expected drawing=true, got %+v", m.Activity)
+	}
+	drawer.Close()
+	if m := readMessage(t, watcher); m.Type != "drawing" || m.Activity == nil || m.Activity.Drawing {
+		t.Fatalf("Expected drawing=false after disconnect, got %+v", m)
+	}
+}
+
+func TestHub_PresenceCarriesDisplayName(t *testing.T) {
+	hub, srv, cookies := newAuthedHub(t)
+	u, _ := hub.Store.GetUserByEmail("ws@example.com")
+	if err := hub.Store.SetDisplayName(u.ID, "Ada"); err != nil {
+		t.Fatalf("Failed to set display name: %v", err)
+	}
+	drawer := dialAuthed(t, srv, "", cookies)
+	watcher := dialAuthed(t, srv, "", cookies)
+	waitForClients(t, hub, 2)
+
+	// Whatever the client claims, the server attributes events to the account's display name
+	if err := drawer.WriteJSON(message{Type: "stroke_start", Activity: &Activity{ClientID: "pen", DisplayName: "mallory"}}); err != nil {
+		t.Fatalf("Failed to send stroke_start: %v", err)
+	}
+	if m := readMessage(t, watcher); m.Activity == nil || m.Activity.DisplayName != "Ada" {
+		t.Fatalf("Expected drawing event from Ada, got %+v", m.Activity)
+	}
+	if err := drawer.WriteJSON(message{Type: "cursor", Cursor: &Cursor{ClientID: "pen", DisplayName: "mallory"}}); err != nil {
+		t.Fatalf("Failed to send cursor: %v", err)
+	}
+	if m := readMessage(t, watcher); m.Cursor == nil || m.Cursor.DisplayName != "Ada" {
+		t.Fatalf("Expected cursor from Ada, got %+v", m.Cursor)
+	}
+	if err := drawer.WriteJSON(message{Type: "stroke", Stroke: &Stroke{Color: "#000000", Width: 2, Points: []Point{{X: 1, Y: 1}}, CreatedBy: u.ID + 1}}); err != nil {
+		t.Fatalf("Failed to send stroke: %v", err)
+	}
+	if m := readMessage(t, watcher); m.Stroke == nil || m.Stroke.DisplayName != "Ada" || m.Stroke.CreatedBy != u.ID {
+		t.Fatalf("Expected stroke attributed to Ada (user %d), got %+v", u.ID, m.Stroke)
+	}
+}
@@ -0,0 +1,108 @@
+package ws
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/deliium/drawing-board/internal/db"
+	"github.com/deliium/drawing-board/internal/metrics"
+	"github.com/deliium/drawing-board/internal/webhook"
+	"github.com/gorilla/websocket"
+)
+
+var strokeFlushes = metrics.NewCounter("ws_stroke_flushes_total", "Transactions used to save WebSocket strokes.")
+
+// DefaultBatchSize is used when Hub.BatchWindow is set but Hub.BatchSize is zero
+const DefaultBatchSize = 50
+
+type pendingStroke struct {
+	userID int64
+	room   int64 // relayed to this room once saved
+	from   *websocket.Conn // acked instead of receiving the stroke
+	msg    message
+	st     db.Stroke
+}
+
+// strokeBatch buffers one connection's strokes and saves them together once the connection has
+// been quiet for window or size strokes are waiting. Strokes are relayed after they are saved so
+// every collaborator receives them, and the sender its ack, with their server ID, exactly as in
+// unbatched mode.
+type strokeBatch struct {
+	h      *Hub
+	window time.Duration
+	size   int
+
+	saving  sync.Mutex // held across take+save so flushes reach the store and clients in order
+	mu      sync.Mutex
+	pending []pendingStroke
+	timer   *time.Timer
+}
+
+// newStrokeBatch returns nil when batching is off, and a nil batch saves every stroke immediately
+func (h *Hub) newStrokeBatch() *strokeBatch {
+	if h.BatchWindow <= 0 { return nil }
+	size := h.BatchSize
+	if size <= 0 { size = DefaultBatchSize }
+	return &strokeBatch{h: h, window: h.BatchWindow, size: size}
+}
+
+// add queues a stroke, flushing now if the buffer is full and otherwise restarting the quiet timer
+func (b *strokeBatch) add(p pendingStroke) {
+	b.mu.Lock()
+	b.pending = append(b.pending, p)
+	if len(b.pending) >= b.size {
+		b.mu.Unlock()
+		b.flush()
+		return
+	}
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.window, b.flush)
+	} else {
+		b.timer.Reset(b.window)
+	}
+	b.mu.Unlock()
+}
+
+// flush saves whatever is buffered; it is safe to call from the timer and on disconnect
+func (b *strokeBatch) flush() {
+	if b == nil { return }
+	b.saving.Lock()
+	defer b.saving.Unlock()
+	b.mu.Lock()
+	batch := b.takeLocked()
+	b.mu.Unlock()
+	b.h.saveStrokes(batch)
+}
+
+func (b *strokeBatch) takeLocked() []pendingStroke {
+	if b.timer != nil { b.timer.Stop() }
+	batch := b.pending
+	b.pending = nil
+	return batch
+}
+
+// saveStrokes writes each user's strokes in one transaction, then notifies and relays them in order.
+// It runs from the quiet timer and on disconnect, so it is deliberately not bound to the request context.
+func (h *Hub) saveStrokes(batch []pendingStroke) {
+	if len(batch) == 0 { return }
+	byUser := map[int64][]int{}
+	var order []int64
+	for i, p := range batch {
+		if _, ok := byUser[p.userID]; !ok { order = append(order, p.userID) }
+		byUser[p.userID] = append(byUser[p.userID], i)
+	}
+	for _, uid := range order {
+		idx := byUser[uid]
+		strokes := make([]db.Stroke, 0, len(idx))
+		for _, i := range idx { strokes = append(strokes, batch[i].st) }
+		strokeFlushes.Inc()
+		ids, err := h.Store.SaveStrokes(uid, strokes, db.IDFresh)
+		if err != nil { log.Printf("save strokes: %v", err); continue }
+		for j, i := range idx {
+			batch[i].msg.Stroke.ID = ids[j].New
+			h.Webhook.Notify(webhook.Event{Type: webhook.StrokeCreated, UserID: uid, StrokeID: ids[j].New, Stroke: batch[i].msg.Stroke})
+		}
+	}
+	for _, p := range batch { h.relayStroke(p.room, p.from, p.msg) }
+}
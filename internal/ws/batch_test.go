@@ -0,0 +1,84 @@
+package ws
+
+import (
+	"testing"
+	"time"
+)
+
+func batchStroke(i int) message {
+	return message{Type: "stroke", Stroke: &Stroke{Color: "#000000", Width: 2, StartedAtUnixMs: int64(i + 1), Points: []Point{{X: float64(i), Y: 1}}}}
+}
+
+func TestHub_BatchesRapidStrokes(t *testing.T) {
+	hub, srv, cookies := newAuthedHub(t)
+	hub.BatchWindow = 100 * time.Millisecond
+	hub.BatchSize = 100
+	c := dialAuthed(t, srv, "", cookies)
+	waitForClients(t, hub, 1)
+
+	before := strokeFlushes.Value()
+	const n = 20
+	for i := 0; i < n; i++ {
+		if err := c.WriteJSON(batchStroke(i)); err != nil {
+			t.Fatalf("Failed to send stroke: %v", err)
+		}
+	}
+	seen := map[int64]bool{}
+	for i := 0; i < n; i++ {
+		m := readMessage(t, c)
+		if m.Ack == nil || m.Ack.ID == 0 || m.Ack.StartedAtUnixMs != int64(i+1) {
+			t.Fatalf("Expected the ack for saved stroke %d in order, got %+v", i, m.Ack)
+		}
+		seen[m.Ack.ID] = true
+	}
+	if len(seen) != n {
+		t.Fatalf("Expected %d distinct IDs, got %d", n, len(seen))
+	}
+	if flushes := strokeFlushes.Value() - before; flushes >= n || flushes == 0 {
+		t.Fatalf("Expected rapid strokes to share transactions, got %d for %d strokes", flushes, n)
+	}
+	u, _ := hub.Store.GetUserByEmail("ws@example.com")
+	if saved, _ := hub.Store.ListStrokesByUser(u.ID); len(saved) != n {
+		t.Fatalf("Expected %d strokes persisted, got %d", n, len(saved))
+	}
+}
+
+func TestHub_BatchFlushesWhenFull(t *testing.T) {
+	hub, srv, cookies := newAuthedHub(t)
+	hub.BatchWindow = time.Hour
+	hub.BatchSize = 3
+	c := dialAuthed(t, srv, "", cookies)
+	waitForClients(t, hub, 1)
+
+	for i := 0; i < 3; i++ {
+		if err := c.WriteJSON(batchStroke(i)); err != nil {
+			t.Fatalf("Failed to send stroke: %v", err)
+		}
+	}
+	// The window is an hour, so only the size limit can have flushed these
+	for i := 0; i < 3; i++ {
+		if m := readMessage(t, c); m.Ack == nil || m.Ack.ID == 0 {
+			t.Fatalf("Expected the ack for a saved stroke, got %+v", m)
+		}
+	}
+}
+
+func TestHub_BatchFlushesOnDisconnect(t *testing.T) {
+	hub, srv, cookies := newAuthedHub(t)
+	hub.BatchWindow = time.Hour
+	c := dialAuthed(t, srv, "", cookies)
+	waitForClients(t, hub, 1)
+
+	for i := 0; i < 5; i++ {
+		if err := c.WriteJSON(batchStroke(i)); err != nil {
+			t.Fatalf("Failed to send stroke: %v", err)
+		}
+	}
+	c.Close()
+	waitForClients(t, hub, 0)
+
+	u, _ := hub.Store.GetUserByEmail("ws@example.com")
+	if saved, _ := hub.Store.ListStrokesByUser(u.ID); len(saved) != 5 {
+		t.Fatalf("Expected buffered strokes to be saved on disconnect, got %d", len(saved))
+	}
+}
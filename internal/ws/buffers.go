@@ -0,0 +1,51 @@
+package ws
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// DefaultReadBufferSize and DefaultWriteBufferSize are the per-connection upgrader buffers used
+// when the Hub fields are zero
+const (
+	DefaultReadBufferSize  = 1024
+	DefaultWriteBufferSize = 1024
+)
+
+// maxPooledMessage keeps the occasional huge message from pinning its buffer in the pool
+const maxPooledMessage = 64 << 10
+
+// messageBuffers holds the buffers incoming messages are read into before decoding; a connection
+// reads one message at a time, so the buffer goes back as soon as the message is decoded
+var messageBuffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// readMessageBuffer reads one message from r into a pooled buffer; hand it back with putMessageBuffer once
+// nothing refers to its bytes (json.Unmarshal copies what it keeps)
+func readMessageBuffer(r io.Reader) (*bytes.Buffer, error) {
+	buf := messageBuffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	if _, err := buf.ReadFrom(r); err != nil { putMessageBuffer(buf); return nil, err }
+	return buf, nil
+}
+
+func putMessageBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledMessage { return }
+	messageBuffers.Put(buf)
+}
+
+// upgrader is built once from the buffer fields. With PoolWriteBuffers, write buffers come from a
+// pool of the hub's own, as gorilla wants one pool per WriteBufferSize, and are only held while a
+// message is being written rather than for the life of the connection.
+func (h *Hub) upgrader() *websocket.Upgrader {
+	h.upgraderOnce.Do(func() {
+		u := upgrader
+		if h.ReadBufferSize > 0 { u.ReadBufferSize = h.ReadBufferSize }
+		if h.WriteBufferSize > 0 { u.WriteBufferSize = h.WriteBufferSize }
+		if h.PoolWriteBuffers { u.WriteBufferPool = &sync.Pool{} }
+		h.up = &u
+	})
+	return h.up
+}
@@ -0,0 +1,79 @@
+package ws
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+)
+
+func TestHubUpgrader_UsesConfiguredBuffers(t *testing.T) {
+	hub := &Hub{ReadBufferSize: 512, WriteBufferSize: 2048, PoolWriteBuffers: true}
+	u := hub.upgrader()
+	if u.ReadBufferSize != 512 || u.WriteBufferSize != 2048 || u.WriteBufferPool == nil {
+		t.Fatalf("Expected 512/2048 with a write pool, got %d/%d pool %v", u.ReadBufferSize, u.WriteBufferSize, u.WriteBufferPool)
+	}
+	if hub.upgrader() != u {
+		t.Fatal("Expected the upgrader to be built once")
+	}
+	def := (&Hub{}).upgrader()
+	if def.ReadBufferSize != DefaultReadBufferSize || def.WriteBufferSize != DefaultWriteBufferSize || def.WriteBufferPool != nil {
+		t.Fatalf("Expected the defaults without a pool, got %d/%d pool %v", def.ReadBufferSize, def.WriteBufferSize, def.WriteBufferPool)
+	}
+}
+
+func TestReadMessageBuffer_DropsOversizedBuffers(t *testing.T) {
+	buf, err := readMessageBuffer(bytes.NewReader(make([]byte, maxPooledMessage+1)))
+	if err != nil || buf.Len() != maxPooledMessage+1 {
+		t.Fatalf("Expected the whole message, got %v", err)
+	}
+	putMessageBuffer(buf)
+	if again := messageBuffers.Get().(*bytes.Buffer); again == buf {
+		t.Fatal("Expected an oversized buffer to stay out of the pool")
+	}
+}
+
+// strokeMessage is a typical 200-point stroke as a client sends it
+func strokeMessage(b *testing.B) []byte {
+	pts := make([]Point, 200)
+	for i := range pts { pts[i] = Point{X: float64(i) * 1.5, Y: float64(i%17) * 3.25} }
+	data, err := json.Marshal(message{Type: "stroke", Stroke: &Stroke{Points: pts, Color: "#1d4ed8", Width: 4, ClientID: "abc"}})
+	if err != nil {
+		b.Fatal(err)
+	}
+	return data
+}
+
+// BenchmarkDecodeMessage compares reading each message into a fresh slice, as conn.ReadMessage
+// does, with the pooled buffer the read loop uses; run with -benchmem to see the allocations saved
+func BenchmarkDecodeMessage(b *testing.B) {
+	data := strokeMessage(b)
+	b.Run("ReadAll", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			raw, err := io.ReadAll(bytes.NewReader(data))
+			if err != nil {
+				b.Fatal(err)
+			}
+			var m message
+			if err := json.Unmarshal(raw, &m); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buf, err := readMessageBuffer(bytes.NewReader(data))
+			if err != nil {
+				b.Fatal(err)
+			}
+			var m message
+			err = json.Unmarshal(buf.Bytes(), &m)
+			putMessageBuffer(buf)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
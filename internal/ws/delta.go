@@ -0,0 +1,50 @@
+package ws
+
+import "math"
+
+// DeltaScale is the precision of delta-encoded points: coordinates are sent as whole multiples
+// of 1/DeltaScale pixel, so decoding is exact on the quantized values and within
+// 0.5/DeltaScale of the originals. Snapshots only delta-encode strokes ExactDelta accepts, so
+// rounding is left to callers of EncodeDelta that ask for it.
+const DeltaScale = 100
+
+// EncodeDelta flattens points into integers [x0, y0, dx1, dy1, ...] in units of 1/DeltaScale
+// pixel, where each pair after the first is the difference from the previous quantized point.
+// Integer differences sum back without the rounding drift float differences accumulate.
+func EncodeDelta(points []Point) []int64 {
+	if len(points) == 0 { return nil }
+	out := make([]int64, 0, len(points)*2)
+	var px, py int64
+	for i, p := range points {
+		x, y := quantize(p.X), quantize(p.Y)
+		if i == 0 { out = append(out, x, y) } else { out = append(out, x-px, y-py) }
+		px, py = x, y
+	}
+	return out
+}
+
+// DecodeDelta reverses EncodeDelta by summing consecutive differences
+func DecodeDelta(d []int64) []Point {
+	if len(d) < 2 { return nil }
+	out := make([]Point, 0, len(d)/2)
+	var x, y int64
+	for i := 0; i+1 < len(d); i += 2 {
+		if i == 0 { x, y = d[0], d[1] } else { x, y = x+d[i], y+d[i+1] }
+		out = append(out, Point{X: float64(x) / DeltaScale, Y: float64(y) / DeltaScale})
+	}
+	return out
+}
+
+// ExactDelta is EncodeDelta for points already on the 1/DeltaScale grid, which DecodeDelta gives
+// back unchanged; ok is false, and nothing is encoded, when quantizing any coordinate would move it
+func ExactDelta(points []Point) (d []int64, ok bool) {
+	for _, p := range points {
+		if !onGrid(p.X) || !onGrid(p.Y) { return nil, false }
+	}
+	return EncodeDelta(points), true
+}
+
+// onGrid reports whether v decodes from its quantized value bit for bit
+func onGrid(v float64) bool { return float64(quantize(v))/DeltaScale == v }
+
+func quantize(v float64) int64 { return int64(math.Round(v * DeltaScale)) }
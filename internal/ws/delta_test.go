@@ -0,0 +1,102 @@
+package ws
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestDelta_RoundTrip(t *testing.T) {
+	points := []Point{{X: 10, Y: 20}, {X: 12.5, Y: 21}, {X: 15.25, Y: 19.75}, {X: 299, Y: 0.5}, {X: 0, Y: 300}}
+
+	encoded := EncodeDelta(points)
+	if len(encoded) != len(points)*2 {
+		t.Fatalf("Expected %d encoded values, got %d", len(points)*2, len(encoded))
+	}
+	if encoded[0] != 10*DeltaScale || encoded[2] != 2.5*DeltaScale || encoded[3] != DeltaScale {
+		t.Fatalf("Expected quantized values and differences, got %v", encoded)
+	}
+
+	decoded := DecodeDelta(encoded)
+	if len(decoded) != len(points) {
+		t.Fatalf("Expected %d decoded points, got %d", len(points), len(decoded))
+	}
+	for i := range points {
+		if decoded[i] != points[i] {
+			t.Fatalf("Point %d: expected %v, got %v", i, points[i], decoded[i])
+		}
+	}
+}
+
+func TestDelta_RoundTripThroughJSON(t *testing.T) {
+	var points []Point
+	for i := 0; i < 200; i++ {
+		points = append(points, Point{X: float64(i%37) * 1.5, Y: float64(i*7%101) / 4})
+	}
+
+	data, err := json.Marshal(EncodeDelta(points))
+	if err != nil {
+		t.Fatalf("Failed to marshal delta: %v", err)
+	}
+	var encoded []int64
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		t.Fatalf("Failed to unmarshal delta: %v", err)
+	}
+
+	decoded := DecodeDelta(encoded)
+	for i := range points {
+		if decoded[i] != points[i] {
+			t.Fatalf("Point %d: expected %v, got %v", i, points[i], decoded[i])
+		}
+	}
+}
+
+func TestDelta_Empty(t *testing.T) {
+	if EncodeDelta(nil) != nil {
+		t.Fatal("Encoding no points should return nil")
+	}
+	if DecodeDelta([]int64{1}) != nil {
+		t.Fatal("Decoding an incomplete pair should return nil")
+	}
+}
+
+func TestDelta_RandomRoundTripWithinTolerance(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for n := 0; n < 100; n++ {
+		points := make([]Point, 100)
+		for i := range points {
+			// Arbitrary decimals such as 0.1 have no exact binary form, unlike the fixtures above
+			points[i] = Point{X: rng.Float64()*2000 - 1000, Y: math.Round(rng.Float64()*3e5) / 1000}
+		}
+		encoded := EncodeDelta(points)
+		decoded := DecodeDelta(encoded)
+		const tolerance = 0.5/DeltaScale + 1e-9 // half a unit, plus the error of scaling a float
+		for i := range points {
+			if math.Abs(decoded[i].X-points[i].X) > tolerance || math.Abs(decoded[i].Y-points[i].Y) > tolerance {
+				t.Fatalf("Point %d: expected %v within %g, got %v", i, points[i], tolerance, decoded[i])
+			}
+		}
+		// Quantized points survive any number of further round trips unchanged
+		if again := EncodeDelta(decoded); !reflect.DeepEqual(again, encoded) {
+			t.Fatalf("Expected re-encoding the decoded points to be exact, got %v, want %v", again, encoded)
+		}
+		if !reflect.DeepEqual(DecodeDelta(EncodeDelta(decoded)), decoded) {
+			t.Fatal("Expected decoding quantized points to be exact")
+		}
+	}
+}
+
+func TestExactDelta_RefusesPointsItWouldRound(t *testing.T) {
+	onGrid := []Point{{X: 10, Y: 20}, {X: 12.25, Y: 0.01}}
+	d, ok := ExactDelta(onGrid)
+	if !ok || !reflect.DeepEqual(DecodeDelta(d), onGrid) {
+		t.Fatalf("Expected points on the grid to encode exactly, got %v, %v", d, ok)
+	}
+	for _, p := range []Point{{X: 1.0 / 3, Y: 1}, {X: 1, Y: 0.005}, {X: 1, Y: math.NaN()}} {
+		if d, ok := ExactDelta([]Point{{X: 10, Y: 20}, p}); ok || d != nil {
+			t.Fatalf("Expected %v to be refused, got %v", p, d)
+		}
+	}
+}
@@ -1,22 +1,32 @@
 package ws
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net"
 	"net/http"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/deliium/drawing-board/internal/auth"
+	"github.com/deliium/drawing-board/internal/clock"
 	"github.com/deliium/drawing-board/internal/db"
+	"github.com/deliium/drawing-board/internal/metrics"
+	"github.com/deliium/drawing-board/internal/validate"
+	"github.com/deliium/drawing-board/internal/webhook"
 	"github.com/gorilla/websocket"
 )
 
+// upgrader holds the defaults each Hub's upgrader starts from, see Hub.upgrader
 var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
+	ReadBufferSize:  DefaultReadBufferSize,
+	WriteBufferSize: DefaultWriteBufferSize,
 	CheckOrigin: func(r *http.Request) bool { return true },
 }
 
@@ -32,84 +42,605 @@ type Stroke struct {
 	Width           int     `json:"width"`
 	ClientID        string  `json:"clientId"`
 	StartedAtUnixMs int64   `json:"startedAtUnixMs"`
+	Delta           []int64 `json:"delta,omitempty"` // delta-encoded points in place of Points, see ExactDelta
+	Note            string  `json:"note,omitempty"`
+	DisplayName     string  `json:"displayName,omitempty"` // author attribution, filled in by the server
+	CreatedBy       int64   `json:"createdBy,omitempty"`   // author's user ID, filled in by the server
+	LineStyle       string  `json:"lineStyle,omitempty"`   // solid, dashed or dotted; empty means solid
+}
+
+// NoteUpdate sets the text note (label) attached to a stroke; an empty note clears it
+type NoteUpdate struct {
+	ID   int64  `json:"id"`
+	Note string `json:"note"`
+}
+
+// Recolor lists strokes that now have Color, sent after a bulk recolor
+type Recolor struct {
+	IDs   []int64 `json:"ids"`
+	Color string  `json:"color"`
 }
 
 type message struct {
 	Type    string   `json:"type"`
 	Stroke  *Stroke  `json:"stroke"`
 	Delete  *int64   `json:"delete"` // stroke id to delete
+	Note     *NoteUpdate `json:"note,omitempty"`
+	Recolor  *Recolor `json:"recolor,omitempty"`
+	Strokes  []Stroke `json:"strokes,omitempty"`  // snapshot contents
+	Encoding string   `json:"encoding,omitempty"` // snapshot point encoding: "full" or "delta"
+	Cursor   *Cursor  `json:"cursor,omitempty"`
+	Cursors  []Cursor `json:"cursors,omitempty"` // snapshot roster of live collaborator cursors
+	Activity   *Activity  `json:"activity,omitempty"`
+	Activities []Activity `json:"activities,omitempty"` // snapshot of collaborators currently drawing
+	Errors     validate.Errors `json:"errors,omitempty"` // why the sender's last message was rejected
+	Subscribe  []string `json:"subscribe,omitempty"` // event categories for a subscribe message, see EventStrokes
+	Presence   *Presence `json:"presence,omitempty"`
+	Ack        *Ack      `json:"ack,omitempty"`
+	Canvas     *Canvas   `json:"canvas,omitempty"` // in a snapshot, the board's saved canvas settings
+}
+
+// Canvas is the size and background a board is drawn on, see db.BoardSettings
+type Canvas struct {
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	Background string `json:"background"`
+}
+
+// Ack tells the sender of a stroke the ID it was saved under; the stroke itself is relayed to
+// everyone else only. ClientID and StartedAtUnixMs are echoed from the stroke to match it up.
+type Ack struct {
+	ClientID        string `json:"clientId"`
+	ID              int64  `json:"id"`
+	StartedAtUnixMs int64  `json:"startedAtUnixMs"`
+}
+
+// Cursor is a collaborator's pointer position; it is relayed and remembered in memory, never persisted
+type Cursor struct {
+	ClientID string  `json:"clientId"`
+	X        float64 `json:"x"`
+	Y        float64 `json:"y"`
+	Color    string  `json:"color"`
+	DisplayName string `json:"displayName,omitempty"` // filled in by the server
+}
+
+type cursorState struct {
+	Cursor
+	at time.Time
 }
 
+// Lobby is the room of connections that name no board and have none to default to, such as
+// anonymous viewers; it keeps the original shared canvas where everyone sees everyone's strokes
+const Lobby int64 = 0
+
 type Hub struct {
 	mu      sync.Mutex
-	clients map[*websocket.Conn]struct{}
+	clients map[*websocket.Conn]*client // every connection with the room it joined and its send queue
+	rooms   map[int64]map[*websocket.Conn]struct{} // room members; empty rooms are deleted
+	streams map[int64]map[chan []byte]struct{} // NDJSON stream subscribers per room, see ServeStream
+	cursors map[*websocket.Conn]cursorState // latest cursor per connection, for late joiners
+	CursorTTL time.Duration // cursors not updated within this window are dropped from the roster
+	drawing map[*websocket.Conn]Activity // connections with a stroke in progress
+	subs    map[*websocket.Conn]map[string]bool // event categories of connections that narrowed them; absent means all
+	clientIDs map[*websocket.Conn]string // presence identity of each connection, see connClientID
+	Presence bool // announce each room's client IDs whenever a connection joins or leaves it
+	CursorRate  float64 // cursor messages relayed per second per connection, extra ones are dropped; 0 uses DefaultCursorRate
+	CursorBurst int     // 0 uses DefaultCursorBurst
 	Store   *db.Store
 	Auth    *auth.Service
+	Webhook *webhook.Notifier // optional; nil disables stroke event webhooks
+	WriteDeadline time.Duration // per-write deadline before a client is considered dead
+	SendBuffer int // messages queued per connection before it is dropped as too slow; 0 uses DefaultSendBuffer
+	Clock clock.Clock // nil uses the wall clock; drives stroke timestamps, cursor expiry and pings
+	MaxClients int // connections beyond this are refused; 0 means unlimited
+	Smoothing Smoothing // applied to stroke points before they are saved and broadcast; off by default
+	BatchWindow time.Duration // when set, a connection's strokes are saved together after this much quiet; 0 saves each at once
+	BatchSize   int           // flush a batch early once this many strokes wait; 0 uses DefaultBatchSize
+	PingInterval time.Duration // how often clients are pinged; 0 uses DefaultPingInterval
+	PongWait     time.Duration // a client whose last pong is older than this is dropped; 0 uses DefaultPongWait
+	ReadBufferSize   int  // upgrader read buffer per connection; 0 uses DefaultReadBufferSize
+	WriteBufferSize  int  // upgrader write buffer; 0 uses DefaultWriteBufferSize
+	PoolWriteBuffers bool // share write buffers between connections instead of keeping one per connection
+	upgraderOnce sync.Once
+	up           *websocket.Upgrader
+	closing bool           // set by Shutdown; new connections and streams are refused
+	quitCh  chan struct{}  // closed by Shutdown, see quit
+	active  sync.WaitGroup // running ServeHTTP connection handlers, see Wait
+}
+
+// DefaultWriteDeadline is used when Hub.WriteDeadline is zero
+const DefaultWriteDeadline = 5 * time.Second
+
+// DefaultSendBuffer is used when Hub.SendBuffer is zero
+const DefaultSendBuffer = 256
+
+// DefaultCursorTTL is used when Hub.CursorTTL is zero
+const DefaultCursorTTL = 30 * time.Second
+
+// DefaultPingInterval and DefaultPongWait are used when the Hub fields are zero. PongWait must
+// exceed PingInterval or every idle client is dropped between pings.
+const (
+	DefaultPingInterval = 30 * time.Second
+	DefaultPongWait     = 60 * time.Second
+)
+
+// DefaultMaxClients caps concurrent connections so a cleanup bug cannot grow the client map without bound
+const DefaultMaxClients = 1000
+
+var (
+	droppedMessages = metrics.NewCounter("ws_dropped_messages_total", "Broadcast messages that could not be delivered and caused the client to be dropped.")
+	slowWrites      = metrics.NewCounter("ws_slow_writes_total", "WebSocket writes that took longer than half the write deadline.")
+	rejectedClients = metrics.NewCounter("ws_rejected_connections_total", "Connections refused because the hub was at MaxClients.")
+)
+
+// client is one registered connection. Its writer goroutine is the only code writing data frames
+// to conn; everything else queues on send, so a stalled socket never holds up the hub lock.
+type client struct {
+	conn *websocket.Conn
+	room int64 // board ID or Lobby
+	send chan []byte
+	first chan []byte // when non-nil, the writer waits for one message here (the snapshot) before draining send
+	done chan struct{} // closed when the client leaves the hub, which stops its writer
+}
+
+var errSendBufferFull = errors.New("ws: send buffer full")
+
+func NewHub(store *db.Store, authSvc *auth.Service) *Hub { return &Hub{clients: make(map[*websocket.Conn]*client), rooms: make(map[int64]map[*websocket.Conn]struct{}), cursors: make(map[*websocket.Conn]cursorState), drawing: make(map[*websocket.Conn]Activity), Store: store, Auth: authSvc, WriteDeadline: DefaultWriteDeadline, CursorTTL: DefaultCursorTTL, MaxClients: DefaultMaxClients} }
+
+func (h *Hub) writeDeadline() time.Duration {
+	if h.WriteDeadline > 0 { return h.WriteDeadline }
+	return DefaultWriteDeadline
+}
+
+func (h *Hub) sendBuffer() int {
+	if h.SendBuffer > 0 { return h.SendBuffer }
+	return DefaultSendBuffer
+}
+
+func (h *Hub) pingInterval() time.Duration {
+	if h.PingInterval > 0 { return h.PingInterval }
+	return DefaultPingInterval
+}
+
+func (h *Hub) pongWait() time.Duration {
+	if h.PongWait > 0 { return h.PongWait }
+	return DefaultPongWait
+}
+
+// add registers c in the Lobby, see addTo
+func (h *Hub) add(c *websocket.Conn) bool { return h.addTo(Lobby, c) }
+
+func (h *Hub) addTo(room int64, c *websocket.Conn) bool { return h.addAs(room, c, "") }
+
+// addAs registers c in room under presence ID id, see register
+func (h *Hub) addAs(room int64, c *websocket.Conn, id string) bool { return h.register(room, c, id, false) }
+
+// register adds c to room under presence ID id unless the hub is full or shutting down, reporting
+// whether it was added, and starts the writer that delivers its queued messages. With
+// holdForSnapshot the writer sends nothing until queueSnapshot is called, so broadcasts queued in
+// the meantime follow the snapshot. MaxClients counts connections across all rooms; a conn
+// already registered stays in its room.
+func (h *Hub) register(room int64, c *websocket.Conn, id string, holdForSnapshot bool) bool {
+	if c == nil { return false }
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.clients[c]; ok { return true }
+	if h.closing || (h.MaxClients > 0 && len(h.clients) >= h.MaxClients) { return false }
+	cl := &client{conn: c, room: room, send: make(chan []byte, h.sendBuffer()), done: make(chan struct{})}
+	if holdForSnapshot { cl.first = make(chan []byte, 1) }
+	h.clients[c] = cl
+	if h.clientIDs == nil { h.clientIDs = make(map[*websocket.Conn]string) }
+	h.clientIDs[c] = id
+	if h.rooms[room] == nil { h.rooms[room] = make(map[*websocket.Conn]struct{}) }
+	h.rooms[room][c] = struct{}{}
+	// A conn with no network connection could never be written; queueLocked drops it instead
+	if c.UnderlyingConn() != nil { go h.writePump(cl) }
+	return true
+}
+
+// writePump writes cl's queued messages until it leaves the hub, dropping it when a write fails
+// or misses the write deadline
+func (h *Hub) writePump(cl *client) {
+	if cl.first != nil {
+		select {
+		case <-cl.done:
+			return
+		case b := <-cl.first:
+			if b != nil && !h.write(cl, b) { return }
+		}
+	}
+	for {
+		select {
+		case <-cl.done:
+			return
+		case b := <-cl.send:
+			if !h.write(cl, b) { return }
+		}
+	}
+}
+
+// write sends b on cl's conn, dropping cl from the hub and reporting false when that fails
+func (h *Hub) write(cl *client, b []byte) bool {
+	deadline := h.writeDeadline()
+	start := time.Now()
+	err := safeWrite(cl.conn, start.Add(deadline), b)
+	if time.Since(start) > deadline/2 { slowWrites.Inc() }
+	if err == nil { return true }
+	droppedMessages.Inc()
+	if !isBenignNetErr(err) {
+		log.Printf("ws write error: %v", err)
+	}
+	h.mu.Lock()
+	if h.clients[cl.conn] == cl { h.dropLocked(cl.room, cl.conn) }
+	h.mu.Unlock()
+	safeClose(cl.conn)
+	return false
+}
+
+// queueSnapshot hands c's writer the snapshot it was registered to wait for; nil releases the
+// writer without one. A conn registered without holdForSnapshot ignores it.
+func (h *Hub) queueSnapshot(c *websocket.Conn, b []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if cl, ok := h.clients[c]; ok && cl.first != nil {
+		select {
+		case cl.first <- b:
+		default:
+		}
+	}
+}
+
+// remove unregisters c from whichever room it joined; an unknown or already-removed conn is a no-op
+func (h *Hub) remove(c *websocket.Conn) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	cl, ok := h.clients[c]
+	if !ok { return false }
+	h.dropLocked(cl.room, c)
+	return true
+}
+
+// removeFrom unregisters c if it is in room, reporting whether it was
+func (h *Hub) removeFrom(room int64, c *websocket.Conn) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if cl, ok := h.clients[c]; !ok || cl.room != room { return false }
+	h.dropLocked(room, c)
+	return true
+}
+
+func (h *Hub) dropLocked(room int64, c *websocket.Conn) {
+	if cl, ok := h.clients[c]; ok { close(cl.done) }
+	delete(h.clients, c)
+	delete(h.cursors, c)
+	delete(h.drawing, c)
+	delete(h.subs, c)
+	delete(h.clientIDs, c)
+	if members := h.rooms[room]; members != nil {
+		delete(members, c)
+		if len(members) == 0 { delete(h.rooms, room) }
+	}
+}
+
+// roomLocked is the room c joined; a conn that is not registered reads as the Lobby
+func (h *Hub) roomLocked(c *websocket.Conn) int64 {
+	if cl, ok := h.clients[c]; ok { return cl.room }
+	return Lobby
 }
 
-func NewHub(store *db.Store, authSvc *auth.Service) *Hub { return &Hub{clients: make(map[*websocket.Conn]struct{}), Store: store, Auth: authSvc} }
+// ClientCount returns the number of registered connections
+func (h *Hub) ClientCount() int { h.mu.Lock(); defer h.mu.Unlock(); return len(h.clients) }
 
-func (h *Hub) add(c *websocket.Conn)    { h.mu.Lock(); h.clients[c] = struct{}{}; h.mu.Unlock() }
-func (h *Hub) remove(c *websocket.Conn) { h.mu.Lock(); delete(h.clients, c); h.mu.Unlock() }
+func (h *Hub) full() bool { return h.MaxClients > 0 && h.ClientCount() >= h.MaxClients }
 
+// LogClientCount logs the connection count every interval until stop is closed, so leaks show up in logs
+func (h *Hub) LogClientCount(interval time.Duration, stop <-chan struct{}) {
+	if interval <= 0 { return }
+	ticker := clock.Or(h.Clock).NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C():
+			n := h.ClientCount()
+			if h.MaxClients > 0 && n >= h.MaxClients*9/10 {
+				log.Printf("Warning: ws clients %d of max %d", n, h.MaxClients)
+			} else {
+				log.Printf("ws clients: %d", n)
+			}
+		}
+	}
+}
+
+func (h *Hub) setCursor(c *websocket.Conn, cur Cursor) {
+	h.mu.Lock()
+	h.cursors[c] = cursorState{Cursor: cur, at: clock.Or(h.Clock).Now()}
+	h.mu.Unlock()
+}
+
+// cursorRoster returns the live cursors in room, pruning any older than CursorTTL
+func (h *Hub) cursorRoster(room int64) []Cursor {
+	ttl := h.CursorTTL
+	if ttl <= 0 { ttl = DefaultCursorTTL }
+	now := clock.Or(h.Clock).Now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([]Cursor, 0, len(h.cursors))
+	for c, st := range h.cursors {
+		if now.Sub(st.at) > ttl { delete(h.cursors, c); continue }
+		if h.roomLocked(c) == room { out = append(out, st.Cursor) }
+	}
+	return out
+}
+
+// broadcast sends v to every client in every room
 func (h *Hub) broadcast(v interface{}) {
 	b, err := json.Marshal(v)
 	if err != nil { return }
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	for c := range h.clients {
-		c.SetWriteDeadline(time.Now().Add(5 * time.Second))
-		if err := c.WriteMessage(websocket.TextMessage, b); err != nil {
-			if !isBenignNetErr(err) {
-				log.Printf("ws write error: %v", err)
-			}
-			c.Close()
-			delete(h.clients, c)
+	for room, members := range h.rooms { h.writeLocked(room, members, nil, v, b) }
+	for room := range h.streams { h.publishLocked(room, v, b) }
+}
+
+// broadcastTo sends v to the clients in room only
+func (h *Hub) broadcastTo(room int64, v interface{}) { h.broadcastExcept(room, nil, v) }
+
+// broadcastExcept sends v to every client in room but skip (nil sends to the whole room)
+func (h *Hub) broadcastExcept(room int64, skip *websocket.Conn, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil { return }
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.writeLocked(room, h.rooms[room], skip, v, b)
+	h.publishLocked(room, v, b)
+}
+
+// relayStroke sends a stroke message to room except its sender, who gets an Ack once it has an ID.
+// A sender already drew the stroke, so an echo would draw it twice.
+func (h *Hub) relayStroke(room int64, from *websocket.Conn, m message) {
+	h.broadcastExcept(room, from, m)
+	if from != nil && m.Stroke.ID != 0 {
+		h.sendTo(from, message{Type: "ack", Ack: &Ack{ClientID: m.Stroke.ClientID, ID: m.Stroke.ID, StartedAtUnixMs: m.Stroke.StartedAtUnixMs}})
+	}
+}
+
+// writeLocked queues b, the encoding of v, for the members of room subscribed to its type. It never
+// blocks: a client whose buffer is full has fallen too far behind and is dropped.
+func (h *Hub) writeLocked(room int64, members map[*websocket.Conn]struct{}, skip *websocket.Conn, v interface{}, b []byte) {
+	var typ string
+	if m, ok := v.(message); ok { typ = m.Type }
+	var dead []*websocket.Conn
+	for c := range members {
+		if c == skip || !h.wantsLocked(c, typ) { continue }
+		if !h.queueLocked(h.clients[c], b) { dead = append(dead, c) }
+	}
+	// Failed conns are dropped after the loop rather than mutating the map mid-range
+	for _, c := range dead {
+		safeClose(c)
+		h.dropLocked(room, c)
+	}
+}
+
+// queueLocked hands b to cl's writer without waiting, reporting false (and counting the message
+// as dropped) when cl cannot take it
+func (h *Hub) queueLocked(cl *client, b []byte) bool {
+	err := errBadConn
+	if cl != nil && cl.conn.UnderlyingConn() != nil {
+		select {
+		case cl.send <- b:
+			return true
+		default:
+			err = errSendBufferFull
+		}
+	}
+	droppedMessages.Inc()
+	if errors.Is(err, errSendBufferFull) { log.Printf("Warning: ws dropping %s: %v", cl.conn.RemoteAddr(), err) }
+	return false
+}
+
+// sendTo queues v for c alone, behind anything already queued for it, so a reply never overtakes
+// an earlier broadcast; a client that cannot take it is dropped as in a broadcast
+func (h *Hub) sendTo(c *websocket.Conn, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil { return }
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	cl, ok := h.clients[c]
+	if !ok { return }
+	if !h.queueLocked(cl, b) {
+		safeClose(c)
+		h.dropLocked(cl.room, c)
+	}
+}
+
+var errBadConn = errors.New("ws: connection has no underlying network conn")
+
+// safeWrite writes one text frame, turning an unusable conn (nil, zero value, or one that
+// panics mid-write) into an error so a single bad client cannot take down its writer
+func safeWrite(c *websocket.Conn, deadline time.Time, b []byte) (err error) {
+	if c == nil || c.UnderlyingConn() == nil { return errBadConn }
+	defer func() {
+		if p := recover(); p != nil { err = fmt.Errorf("ws: write panicked: %v", p) }
+	}()
+	c.SetWriteDeadline(deadline)
+	return c.WriteMessage(websocket.TextMessage, b)
+}
+
+func safeClose(c *websocket.Conn) {
+	if c == nil || c.UnderlyingConn() == nil { return }
+	defer func() { _ = recover() }()
+	c.Close()
+}
+
+// snapshot encodes the room's persisted strokes (the requesting user's own in the Lobby),
+// delta-encoding points when asked and the encoding is exact, and the board's canvas when it has one saved (the user's
+// default board's in the Lobby); nil for an anonymous request
+func (h *Hub) snapshot(r *http.Request, room int64, delta bool) ([]byte, error) {
+	uid, ok := h.Auth.UserIDFromRequest(r)
+	if !ok { return nil, nil }
+	var rows []db.Stroke
+	var err error
+	if room == Lobby { rows, err = h.Store.ListStrokesByUserContext(r.Context(), uid) } else { rows, err = h.Store.ListStrokesByBoardContext(r.Context(), room) }
+	if err != nil { return nil, err }
+	m := message{Type: "snapshot", Encoding: "full", Strokes: make([]Stroke, 0, len(rows)), Cursors: h.cursorRoster(room), Activities: h.drawingRoster(room)}
+	if delta { m.Encoding = "delta" }
+	board := room
+	if room == Lobby { if board, err = h.Store.DefaultBoardIDContext(r.Context(), uid); err != nil { return nil, err } }
+	bs, err := h.Store.GetBoardSettingsContext(r.Context(), board)
+	if err != nil { return nil, err }
+	if bs != nil { m.Canvas = &Canvas{Width: bs.Width, Height: bs.Height, Background: bs.Background} }
+	for _, s := range rows {
+		pts := make([]Point, 0, len(s.Points))
+		for _, p := range s.Points { pts = append(pts, Point{X: p.X, Y: p.Y}) }
+		st := Stroke{ID: s.ID, Color: s.Color, Width: s.Width, StartedAtUnixMs: s.StartedAtUnixMs, Note: s.Note, ClientID: s.ClientID, DisplayName: s.AuthorName, CreatedBy: s.CreatedBy, LineStyle: s.LineStyle}
+		st.Points = pts
+		// A stroke off the delta grid keeps its points, so no snapshot rounds coordinates
+		if delta {
+			if d, ok := ExactDelta(pts); ok { st.Delta, st.Points = d, nil }
 		}
+		m.Strokes = append(m.Strokes, st)
+	}
+	return json.Marshal(m)
+}
+
+// displayName is how the requesting user is attributed to collaborators; "" when anonymous
+func (h *Hub) displayName(r *http.Request) string {
+	uid, ok := h.Auth.UserIDFromRequest(r)
+	if !ok { return "" }
+	u, err := h.Store.GetUserByIDContext(r.Context(), uid)
+	if err != nil { log.Printf("ws display name: %v", err); return "" }
+	if u == nil { return "" }
+	return u.PublicName()
+}
+
+// BroadcastNote tells the clients on boardID, and the Lobby that relays every user's default
+// board, that a stroke's note changed; safe to call on a nil hub
+func (h *Hub) BroadcastNote(boardID, strokeID int64, note string) {
+	if h == nil { return }
+	m := message{Type: "note", Note: &NoteUpdate{ID: strokeID, Note: note}}
+	h.broadcastTo(boardID, m)
+	if boardID != Lobby { h.broadcastTo(Lobby, m) }
+}
+
+// BroadcastRecolor tells the clients on each board of byBoard (stroke IDs by board, as returned by
+// db.RecolorStrokes) that those strokes are now color. The Lobby gets every ID in one message,
+// like BroadcastNote; safe to call on a nil hub.
+func (h *Hub) BroadcastRecolor(byBoard map[int64][]int64, color string) {
+	if h == nil { return }
+	var all []int64
+	for board, ids := range byBoard {
+		all = append(all, ids...)
+		if board != Lobby { h.broadcastTo(board, message{Type: "recolor", Recolor: &Recolor{IDs: ids, Color: color}}) }
+	}
+	if len(all) > 0 {
+		sort.Slice(all, func(i, j int) bool { return all[i] < all[j] })
+		h.broadcastTo(Lobby, message{Type: "recolor", Recolor: &Recolor{IDs: all, Color: color}})
 	}
 }
 
+// roomFor picks the room named by ?board=, which must be a board the caller owns; without it a
+// signed-in caller joins their default board, and only an anonymous one (or an account with no
+// board) the Lobby. A non-zero status means the upgrade is refused.
+func (h *Hub) roomFor(r *http.Request) (int64, int) {
+	v := r.URL.Query().Get("board")
+	uid, ok := h.Auth.UserIDFromRequest(r)
+	if v == "" {
+		if !ok { return Lobby, 0 }
+		id, err := h.Store.DefaultBoardIDContext(r.Context(), uid)
+		if err != nil { log.Printf("ws default board: %v", err); return 0, http.StatusInternalServerError }
+		return id, 0
+	}
+	id, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || id <= 0 { return 0, http.StatusBadRequest }
+	if !ok { return 0, http.StatusUnauthorized }
+	b, err := h.Store.GetBoardContext(r.Context(), id)
+	if err != nil { log.Printf("ws board: %v", err); return 0, http.StatusInternalServerError }
+	// Someone else's board is indistinguishable from a missing one
+	if b == nil || b.OwnerID != uid { return 0, http.StatusNotFound }
+	return id, 0
+}
+
 var globalHub *Hub
 
-func Init(store *db.Store, authSvc *auth.Service) { globalHub = NewHub(store, authSvc) }
+func Init(store *db.Store, authSvc *auth.Service) *Hub { globalHub = NewHub(store, authSvc); return globalHub }
 
-func Handle(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+func Handle(w http.ResponseWriter, r *http.Request) { globalHub.ServeHTTP(w, r) }
+
+// ServeHTTP upgrades the request and runs the connection until it closes
+func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.begin() { http.Error(w, "server shutting down", http.StatusServiceUnavailable); return }
+	defer h.active.Done()
+	if h.full() {
+		rejectedClients.Inc()
+		log.Printf("Warning: ws rejecting %s, hub is at max clients (%d)", r.RemoteAddr, h.MaxClients)
+		http.Error(w, "too many connections", http.StatusServiceUnavailable)
+		return
+	}
+	room, status := h.roomFor(r)
+	if status != 0 { http.Error(w, http.StatusText(status), status); return }
+	conn, err := h.upgrader().Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("ws upgrade: %v", err)
 		return
 	}
 	log.Printf("ws connected: %s", r.RemoteAddr)
-	globalHub.add(conn)
+	mode := r.URL.Query().Get("snapshot")
+	// Re-checked after the upgrade: concurrent upgrades can all pass the pre-check above
+	if !h.register(room, conn, connClientID(r), mode != "") {
+		reason := "too many connections"
+		if h.isClosing() { reason = "server shutting down" } else { rejectedClients.Inc() }
+		_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, reason), time.Now().Add(time.Second))
+		conn.Close()
+		return
+	}
+	// The snapshot is read after the conn joined, so no stroke saved in between is missed, and
+	// written ahead of the broadcasts queued meanwhile; a stroke can arrive in both
+	if mode != "" {
+		b, err := h.snapshot(r, room, mode == "delta")
+		if err != nil { log.Printf("ws snapshot: %v", err) }
+		h.queueSnapshot(conn, b)
+	}
+	h.announcePresence(room)
+	// Resolved once per connection; a renamed user is picked up on reconnect
+	name := h.displayName(r)
+	batch := h.newStrokeBatch()
+	cursorLimit := h.newCursorLimiter()
 	defer func() {
-		globalHub.remove(conn)
+		batch.flush() // buffered strokes are saved and relayed before the conn leaves the hub
+		if a, ok := h.endDrawing(conn); ok { h.broadcastExcept(room, conn, message{Type: "drawing", Activity: &a}) }
+		if h.removeFrom(room, conn) { h.announcePresence(room) }
 		conn.Close()
 		log.Printf("ws disconnected: %s", r.RemoteAddr)
 	}()
 
 	conn.SetReadLimit(1 << 20)
-	conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	pongWait := h.pongWait()
+	conn.SetReadDeadline(time.Now().Add(pongWait))
 	conn.SetPongHandler(func(string) error {
-		conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		conn.SetReadDeadline(time.Now().Add(pongWait))
 		return nil
 	})
 
 	done := make(chan struct{})
+	quit := h.quit()
 	conn.SetCloseHandler(func(code int, text string) error {
 		select { case <-done: default: close(done) }
 		return nil
 	})
 
 	go func() {
-		ticker := time.NewTicker(30 * time.Second)
+		ticker := clock.Or(h.Clock).NewTicker(h.pingInterval())
 		defer ticker.Stop()
 		for {
 			select {
 			case <-done:
 				return
-			case <-ticker.C:
-				conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
-				if err := conn.WriteControl(websocket.PingMessage, []byte("ping"), time.Now().Add(5*time.Second)); err != nil {
+			case <-quit:
+				return
+			case <-ticker.C():
+				if err := conn.WriteControl(websocket.PingMessage, []byte("ping"), time.Now().Add(h.writeDeadline())); err != nil {
 					if !isBenignNetErr(err) {
 						log.Printf("ws ping write error: %v", err)
 					}
@@ -122,7 +653,11 @@ func Handle(w http.ResponseWriter, r *http.Request) {
 	}()
 
 	for {
-		t, data, err := conn.ReadMessage()
+		t, rd, err := conn.NextReader()
+		// Anything but text is skipped unread; the next NextReader discards it
+		if err == nil && t != websocket.TextMessage { continue }
+		var buf *bytes.Buffer
+		if err == nil { buf, err = readMessageBuffer(rd) }
 		if err != nil {
 			if !isBenignNetErr(err) && !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
 				log.Printf("ws read: %v", err)
@@ -130,28 +665,86 @@ func Handle(w http.ResponseWriter, r *http.Request) {
 			select { case <-done: default: close(done) }
 			return
 		}
-		if t != websocket.TextMessage { continue }
 
 		var m message
-		if err := json.Unmarshal(data, &m); err != nil { log.Printf("ws bad json: %v", err); continue }
+		err = json.Unmarshal(buf.Bytes(), &m)
+		putMessageBuffer(buf)
+		if err != nil { log.Printf("ws bad json: %v", err); continue }
 
 		switch m.Type {
 		case "stroke":
 			if m.Stroke == nil { continue }
-			if m.Stroke.StartedAtUnixMs == 0 { m.Stroke.StartedAtUnixMs = time.Now().UnixMilli() }
-			uid, ok := globalHub.Auth.UserIDFromRequest(r)
-			if ok {
-				pts := make([]db.StrokePoint, 0, len(m.Stroke.Points))
-				for _, p := range m.Stroke.Points { pts = append(pts, db.StrokePoint{X:p.X, Y:p.Y}) }
-				id, err := globalHub.Store.SaveStroke(uid, m.Stroke.Color, m.Stroke.Width, m.Stroke.StartedAtUnixMs, pts)
-				if err != nil { log.Printf("save stroke: %v", err) } else { m.Stroke.ID = id }
+			m.Stroke.Points = h.Smoothing.Apply(m.Stroke.Points)
+			if m.Stroke.StartedAtUnixMs == 0 { m.Stroke.StartedAtUnixMs = clock.Or(h.Clock).Now().UnixMilli() }
+			pts := make([]db.StrokePoint, 0, len(m.Stroke.Points))
+			for _, p := range m.Stroke.Points { pts = append(pts, db.StrokePoint{X:p.X, Y:p.Y}) }
+			st := db.Stroke{Color: m.Stroke.Color, Width: m.Stroke.Width, StartedAtUnixMs: m.Stroke.StartedAtUnixMs, Points: pts, ClientID: m.Stroke.ClientID, BoardID: room, LineStyle: m.Stroke.LineStyle}
+			// Same rules as HTTP import; a rejected stroke is neither saved nor relayed
+			if errs := db.NormalizeStroke(&st, "stroke."); errs.Any() {
+				h.sendTo(conn, message{Type: "error", Errors: errs})
+				continue
+			}
+			m.Stroke.Color, m.Stroke.LineStyle = st.Color, st.LineStyle
+			uid, ok := h.Auth.UserIDFromRequest(r)
+			if ok { m.Stroke.DisplayName, m.Stroke.CreatedBy = name, uid }
+			if ok && batch != nil {
+				batch.add(pendingStroke{userID: uid, room: room, from: conn, msg: m, st: st})
+			} else if ok {
+				strokeFlushes.Inc()
+				id, err := h.Store.SaveStrokeRecordContext(r.Context(), uid, st)
+				if err != nil { log.Printf("save stroke: %v", err) } else {
+					m.Stroke.ID = id
+					h.Webhook.Notify(webhook.Event{Type: webhook.StrokeCreated, UserID: uid, StrokeID: id, Stroke: m.Stroke})
+				}
+				h.relayStroke(room, conn, m)
+			} else {
+				h.relayStroke(room, conn, m)
 			}
-			globalHub.broadcast(m)
+			// A saved stroke ends the drawing state even if stroke_end was never sent
+			if a, ok := h.endDrawing(conn); ok { h.broadcastExcept(room, conn, message{Type: "drawing", Activity: &a}) }
 		case "delete":
 			if m.Delete == nil { continue }
-			uid, ok := globalHub.Auth.UserIDFromRequest(r)
-			if ok { if err := globalHub.Store.DeleteStroke(uid, *m.Delete); err != nil { log.Printf("delete stroke: %v", err) } }
-			globalHub.broadcast(m)
+			uid, ok := h.Auth.UserIDFromRequest(r)
+			if !ok { continue }
+			// Relayed only once a row is gone, so nobody can erase strokes they may not delete from others' screens
+			err := h.Store.DeleteStrokeContext(r.Context(), uid, *m.Delete)
+			if errors.Is(err, db.ErrNotFound) {
+				var errs validate.Errors
+				errs.Add("delete", "stroke not found")
+				h.sendTo(conn, message{Type: "error", Errors: errs})
+				continue
+			}
+			if err != nil { log.Printf("delete stroke: %v", err); continue }
+			h.Webhook.Notify(webhook.Event{Type: webhook.StrokeDeleted, UserID: uid, StrokeID: *m.Delete})
+			// The sender already removed it locally
+			h.broadcastExcept(room, conn, m)
+		case "note":
+			if m.Note == nil || m.Note.ID <= 0 || utf8.RuneCountInString(m.Note.Note) > db.MaxNoteLength { continue }
+			uid, ok := h.Auth.UserIDFromRequest(r)
+			if !ok { continue }
+			found, err := h.Store.SetStrokeNoteContext(r.Context(), uid, m.Note.ID, m.Note.Note)
+			if err != nil { log.Printf("set stroke note: %v", err); continue }
+			if found { h.broadcastTo(room, m) }
+		case "stroke_start", "stroke_end":
+			uid, _ := h.Auth.UserIDFromRequest(r)
+			var clientID string
+			if m.Activity != nil { clientID = m.Activity.ClientID }
+			var a Activity
+			var changed bool
+			if m.Type == "stroke_start" { a, changed = h.startDrawing(conn, uid, clientID, name) } else { a, changed = h.endDrawing(conn) }
+			if changed { h.broadcastExcept(room, conn, message{Type: "drawing", Activity: &a}) }
+		case "subscribe":
+			cats, errs := parseSubscription(m.Subscribe)
+			if errs.Any() { h.sendTo(conn, message{Type: "error", Errors: errs}); continue }
+			h.setSubscription(conn, cats)
+			h.sendTo(conn, message{Type: "subscribed", Subscribe: subscriptionNames(cats)})
+		case "cursor":
+			if m.Cursor == nil { continue }
+			// Cursors are superseded by the next one, so a flood is thinned rather than queued
+			if !cursorLimit.Allow("cursor") { throttledCursors.Inc(); continue }
+			m.Cursor.DisplayName = name
+			h.setCursor(conn, *m.Cursor)
+			h.broadcastTo(room, m)
 		}
 	}
 }
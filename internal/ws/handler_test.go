@@ -2,11 +2,18 @@ package ws
 
 import (
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/deliium/drawing-board/internal/auth"
+	"github.com/deliium/drawing-board/internal/clock"
 	"github.com/deliium/drawing-board/internal/db"
+	"github.com/gorilla/sessions"
 	"github.com/gorilla/websocket"
 )
 
@@ -213,4 +220,606 @@ func TestPoint_JSON(t *testing.T) {
 	if unmarshaled.Y != 20.5 {
 		t.Fatalf("Expected Y 20.5, got %f", unmarshaled.Y)
 	}
-}
\ No newline at end of file
+}
+// newHubServer serves a WebSocket endpoint that registers every conn with hub
+func newHubServer(t *testing.T, hub *Hub) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		hub.add(conn)
+		defer hub.remove(conn)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func dialHub(t *testing.T, srv *httptest.Server) *websocket.Conn {
+	t.Helper()
+	c, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func waitForClients(t *testing.T, hub *Hub, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		hub.mu.Lock()
+		got := len(hub.clients)
+		hub.mu.Unlock()
+		if got == n {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %d clients", n)
+}
+
+// slowBroadcast sends a message large enough to fill the socket buffers while the
+// client waits pause before it starts reading
+func slowBroadcast(t *testing.T, deadline, pause time.Duration) (delivered bool, remaining int) {
+	hub := NewHub(&db.Store{}, &auth.Service{})
+	hub.WriteDeadline = deadline
+	client := dialHub(t, newHubServer(t, hub))
+	waitForClients(t, hub, 1)
+
+	got := make(chan bool, 1)
+	go func() {
+		time.Sleep(pause)
+		_, data, err := client.ReadMessage()
+		got <- err == nil && len(data) > 0
+	}()
+
+	big := strings.Repeat("x", 16<<20)
+	hub.broadcast(message{Type: "stroke", Stroke: &Stroke{Color: big}})
+
+	select {
+	case delivered = <-got:
+	case <-time.After(5 * time.Second):
+	}
+	hub.mu.Lock()
+	remaining = len(hub.clients)
+	hub.mu.Unlock()
+	return delivered, remaining
+}
+
+func TestHub_LongWriteDeadlineToleratesSlowClient(t *testing.T) {
+	before := droppedMessages.Value()
+	delivered, remaining := slowBroadcast(t, 3*time.Second, 200*time.Millisecond)
+	if !delivered {
+		t.Fatal("Slow client should receive the message with a long deadline")
+	}
+	if remaining != 1 {
+		t.Fatalf("Slow client should stay connected, got %d clients", remaining)
+	}
+	if droppedMessages.Value() != before {
+		t.Fatal("No message should be counted as dropped")
+	}
+}
+
+func TestHub_ShortWriteDeadlineDropsSlowClient(t *testing.T) {
+	before := droppedMessages.Value()
+	_, remaining := slowBroadcast(t, 20*time.Millisecond, 300*time.Millisecond)
+	if remaining != 0 {
+		t.Fatalf("Slow client should be dropped with a short deadline, got %d clients", remaining)
+	}
+	if droppedMessages.Value() != before+1 {
+		t.Fatalf("Expected dropped counter %d, got %d", before+1, droppedMessages.Value())
+	}
+}
+
+// broadcastAndRead broadcasts n large strokes, waiting for reader to receive each, and returns
+// how long that took
+func broadcastAndRead(t *testing.T, hub *Hub, reader *websocket.Conn, n int) time.Duration {
+	t.Helper()
+	// Large enough that a client which never reads fills its socket buffers and then its send queue
+	big := strings.Repeat("x", 256<<10)
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		hub.broadcast(message{Type: "stroke", Stroke: &Stroke{ID: int64(i + 1), Color: big}})
+		reader.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var m message
+		if err := reader.ReadJSON(&m); err != nil {
+			t.Fatalf("Fast client failed to read message %d: %v", i+1, err)
+		}
+		if m.Stroke == nil || m.Stroke.ID != int64(i+1) {
+			t.Fatalf("Expected stroke %d, got %+v", i+1, m.Stroke)
+		}
+	}
+	return time.Since(start)
+}
+
+func TestHub_StalledClientDoesNotDelayOthers(t *testing.T) {
+	const writeDeadline = 10 * time.Second // long enough that only the full buffer can drop the stalled client
+	newHub := func() (*Hub, *httptest.Server) {
+		hub := NewHub(&db.Store{}, &auth.Service{})
+		hub.WriteDeadline = writeDeadline
+		hub.SendBuffer = 4
+		return hub, newHubServer(t, hub)
+	}
+
+	// The same broadcasts with nobody stalled, so the bound below scales with the machine (and -race)
+	alone, aloneSrv := newHub()
+	reader := dialHub(t, aloneSrv)
+	waitForClients(t, alone, 1)
+	baseline := broadcastAndRead(t, alone, reader, 64)
+
+	hub, srv := newHub()
+	dialHub(t, srv) // stalled: never reads
+	fast := dialHub(t, srv)
+	waitForClients(t, hub, 2)
+	elapsed := broadcastAndRead(t, hub, fast, 64)
+	// Waiting on the stalled client would cost a write deadline; anything near the baseline is fine
+	if limit := 3*baseline + time.Second; elapsed > limit || elapsed > writeDeadline/2 {
+		t.Fatalf("Fast client should not wait on the stalled one, took %v against a baseline of %v", elapsed, baseline)
+	}
+	if n := hub.ClientCount(); n != 1 {
+		t.Fatalf("Expected the stalled client to be dropped, got %d clients", n)
+	}
+}
+
+// newAuthedHub returns a hub backed by a real store plus session cookies for one registered user
+func newAuthedHub(t *testing.T) (*Hub, *httptest.Server, []*http.Cookie) {
+	t.Helper()
+	store, err := db.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { store.SQL.Close() })
+	authSvc := auth.NewService(store, sessions.NewCookieStore([]byte("test-secret")))
+
+	rec := httptest.NewRecorder()
+	authSvc.Register(rec, httptest.NewRequest("POST", "/api/register", strings.NewReader(`{"email":"ws@example.com","password":"password123"}`)))
+	if rec.Code != 200 {
+		t.Fatalf("Failed to register: %d %s", rec.Code, rec.Body.String())
+	}
+
+	hub := NewHub(store, authSvc)
+	srv := httptest.NewServer(hub)
+	t.Cleanup(srv.Close)
+	return hub, srv, rec.Result().Cookies()
+}
+
+func dialAuthed(t *testing.T, srv *httptest.Server, query string, cookies []*http.Cookie) *websocket.Conn {
+	t.Helper()
+	header := http.Header{}
+	for _, c := range cookies {
+		header.Add("Cookie", c.String())
+	}
+	c, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+query, header)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func readMessage(t *testing.T, c *websocket.Conn) message {
+	t.Helper()
+	c.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var m message
+	if err := c.ReadJSON(&m); err != nil {
+		t.Fatalf("Failed to read message: %v", err)
+	}
+	return m
+}
+
+func TestHub_LateJoinerReceivesCursorRoster(t *testing.T) {
+	hub, srv, cookies := newAuthedHub(t)
+
+	early := dialAuthed(t, srv, "", cookies)
+	if err := early.WriteJSON(message{Type: "cursor", Cursor: &Cursor{ClientID: "early", X: 12, Y: 34, Color: "#ff0000"}}); err != nil {
+		t.Fatalf("Failed to send cursor: %v", err)
+	}
+	// The sender also receives the relayed cursor, which means the hub has stored it
+	if m := readMessage(t, early); m.Type != "cursor" {
+		t.Fatalf("Expected relayed cursor, got %q", m.Type)
+	}
+
+	late := dialAuthed(t, srv, "?snapshot=full", cookies)
+	m := readMessage(t, late)
+	if m.Type != "snapshot" {
+		t.Fatalf("Expected snapshot, got %q", m.Type)
+	}
+	if len(m.Cursors) != 1 || m.Cursors[0].ClientID != "early" || m.Cursors[0].X != 12 {
+		t.Fatalf("Expected early cursor in roster, got %v", m.Cursors)
+	}
+
+	// Disconnected clients leave the roster of the default board both joined
+	owner, _ := hub.Store.GetUserByEmail("ws@example.com")
+	board, _ := hub.Store.DefaultBoardID(owner.ID)
+	early.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for len(hub.cursorRoster(board)) != 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := len(hub.cursorRoster(board)); n != 0 {
+		t.Fatalf("Expected empty roster after disconnect, got %d", n)
+	}
+}
+
+func TestHub_CursorRosterExpiresStaleEntries(t *testing.T) {
+	fake := clock.NewFake(time.Unix(0, 0))
+	hub := NewHub(&db.Store{}, &auth.Service{})
+	hub.Clock = fake
+	hub.CursorTTL = 10 * time.Second
+	hub.setCursor(&websocket.Conn{}, Cursor{ClientID: "stale"})
+
+	if len(hub.cursorRoster(Lobby)) != 1 {
+		t.Fatal("Fresh cursor should be in the roster")
+	}
+	fake.Advance(11 * time.Second)
+	if len(hub.cursorRoster(Lobby)) != 0 {
+		t.Fatal("Stale cursor should be expired")
+	}
+}
+
+func TestHub_PingsOnFakeClockTick(t *testing.T) {
+	hub, srv, cookies := newAuthedHub(t)
+	fake := clock.NewFake(time.Unix(0, 0))
+	hub.Clock = fake
+
+	client := dialAuthed(t, srv, "", cookies)
+	pings := make(chan string, 4)
+	client.SetPingHandler(func(data string) error {
+		pings <- data
+		return nil
+	})
+	go func() {
+		for {
+			if _, _, err := client.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for fake.Tickers() == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if fake.Tickers() != 1 {
+		t.Fatal("Expected the connection to start a ping ticker")
+	}
+
+	fake.Advance(DefaultPingInterval - time.Second)
+	select {
+	case <-pings:
+		t.Fatal("No ping should be sent before the interval elapses")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	fake.Advance(time.Second)
+	select {
+	case data := <-pings:
+		if data != "ping" {
+			t.Fatalf("Unexpected ping payload %q", data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a ping once the interval elapsed")
+	}
+}
+
+func TestHub_NoteRoundTripsAndBroadcasts(t *testing.T) {
+	hub, srv, cookies := newAuthedHub(t)
+	author := dialAuthed(t, srv, "", cookies)
+	collaborator := dialAuthed(t, srv, "", cookies)
+	waitForClients(t, hub, 2)
+
+	if err := author.WriteJSON(message{Type: "stroke", Stroke: &Stroke{Points: []Point{{X: 1, Y: 2}}, Color: "#000000", Width: 2}}); err != nil {
+		t.Fatalf("Failed to send stroke: %v", err)
+	}
+	saved := readMessage(t, collaborator)
+	if saved.Stroke == nil || saved.Stroke.ID == 0 {
+		t.Fatalf("Expected saved stroke with id, got %+v", saved.Stroke)
+	}
+	if ack := readMessage(t, author); ack.Ack == nil || ack.Ack.ID != saved.Stroke.ID {
+		t.Fatalf("Expected the author to get an ack for stroke %d, got %+v", saved.Stroke.ID, ack)
+	}
+
+	if err := author.WriteJSON(message{Type: "note", Note: &NoteUpdate{ID: saved.Stroke.ID, Note: "y-axis"}}); err != nil {
+		t.Fatalf("Failed to send note: %v", err)
+	}
+	m := readMessage(t, collaborator)
+	if m.Type != "note" || m.Note == nil || m.Note.ID != saved.Stroke.ID || m.Note.Note != "y-axis" {
+		t.Fatalf("Expected note broadcast, got %+v", m)
+	}
+
+	late := dialAuthed(t, srv, "?snapshot=full", cookies)
+	snap := readMessage(t, late)
+	if len(snap.Strokes) != 1 || snap.Strokes[0].Note != "y-axis" {
+		t.Fatalf("Expected note in snapshot, got %+v", snap.Strokes)
+	}
+}
+
+func TestHub_SnapshotMissesNoConcurrentStroke(t *testing.T) {
+	_, srv, cookies := newAuthedHub(t)
+	author := dialAuthed(t, srv, "", cookies)
+	const n = 40
+	acked := make(chan int64, n)
+	go func() {
+		for i := 0; i < n; i++ {
+			if err := author.WriteJSON(message{Type: "stroke", Stroke: &Stroke{Points: []Point{{X: 1, Y: float64(i)}}, Color: "#000000", Width: 2, ClientID: "author", StartedAtUnixMs: int64(i + 1)}}); err != nil { return }
+		}
+	}()
+	go func() {
+		for {
+			var m message
+			if err := author.ReadJSON(&m); err != nil { return }
+			if m.Ack != nil { acked <- m.Ack.ID }
+		}
+	}()
+	// Joining while strokes are being saved: each is in the snapshot, relayed after it, or both
+	<-acked
+	late := dialAuthed(t, srv, "?snapshot=full", cookies)
+	snap := readMessage(t, late)
+	if snap.Type != "snapshot" {
+		t.Fatalf("Expected the snapshot before any broadcast, got %q", snap.Type)
+	}
+	seen := map[int64]bool{}
+	for _, st := range snap.Strokes { seen[st.ID] = true }
+	want := []int64{}
+	for len(want) < n-1 { want = append(want, <-acked) }
+	for _, id := range want {
+		for !seen[id] {
+			m := readMessage(t, late)
+			if m.Stroke != nil { seen[m.Stroke.ID] = true }
+		}
+	}
+}
+
+func TestHub_SenderGetsAckInsteadOfEcho(t *testing.T) {
+	hub, srv, cookies := newAuthedHub(t)
+	author := dialAuthed(t, srv, "", cookies)
+	viewer := dialAuthed(t, srv, "", cookies)
+	waitForClients(t, hub, 2)
+
+	if err := author.WriteJSON(message{Type: "stroke", Stroke: &Stroke{Points: []Point{{X: 1, Y: 2}}, Color: "#000000", Width: 2, ClientID: "tab-1", StartedAtUnixMs: 42}}); err != nil {
+		t.Fatalf("Failed to send stroke: %v", err)
+	}
+	relayed := readMessage(t, viewer)
+	if relayed.Type != "stroke" || relayed.Stroke.ID == 0 {
+		t.Fatalf("Expected the saved stroke relayed to the viewer, got %+v", relayed)
+	}
+	// The relay is written before the ack, so an echo would arrive first
+	ack := readMessage(t, author)
+	if ack.Type != "ack" || ack.Ack == nil {
+		t.Fatalf("Expected an ack rather than the echoed stroke, got %+v", ack)
+	}
+	if *ack.Ack != (Ack{ClientID: "tab-1", ID: relayed.Stroke.ID, StartedAtUnixMs: 42}) {
+		t.Fatalf("Expected the ack to carry the stroke's clientId and ID %d, got %+v", relayed.Stroke.ID, *ack.Ack)
+	}
+
+	if err := author.WriteJSON(message{Type: "delete", Delete: &relayed.Stroke.ID}); err != nil {
+		t.Fatalf("Failed to send delete: %v", err)
+	}
+	if m := readMessage(t, viewer); m.Type != "delete" || m.Delete == nil || *m.Delete != relayed.Stroke.ID {
+		t.Fatalf("Expected the delete relayed to the viewer, got %+v", m)
+	}
+	expectSilence(t, author)
+}
+
+func TestHub_DeleteOfUnownedOrMissingStrokeIsNotRelayed(t *testing.T) {
+	hub, srv, cookies := newAuthedHub(t)
+	other, _ := hub.Store.CreateUser("other@example.com", "hash")
+	foreign, err := hub.Store.SaveStroke(other, "#000000", 2, 0, []db.StrokePoint{{X: 1, Y: 1}})
+	if err != nil {
+		t.Fatalf("Failed to save stroke: %v", err)
+	}
+	author := dialAuthed(t, srv, "", cookies)
+	viewer := dialAuthed(t, srv, "", cookies)
+	waitForClients(t, hub, 2)
+
+	for name, id := range map[string]int64{"someone else's": foreign, "a missing": foreign + 100} {
+		id := id
+		if err := author.WriteJSON(message{Type: "delete", Delete: &id}); err != nil {
+			t.Fatalf("Failed to send delete: %v", err)
+		}
+		if m := readMessage(t, author); m.Type != "error" || len(m.Errors) != 1 || m.Errors[0].Field != "delete" {
+			t.Fatalf("Expected a delete error for %s stroke, got %+v", name, m)
+		}
+	}
+	expectSilence(t, viewer)
+	if got, _ := hub.Store.ListStrokesByUser(other); len(got) != 1 {
+		t.Fatalf("Expected the other user's stroke to survive, got %d strokes", len(got))
+	}
+}
+
+func TestHub_LineStyleRoundTrips(t *testing.T) {
+	hub, srv, cookies := newAuthedHub(t)
+	c := dialAuthed(t, srv, "", cookies)
+	viewer := dialAuthed(t, srv, "", cookies)
+	waitForClients(t, hub, 2)
+
+	if err := c.WriteJSON(message{Type: "stroke", Stroke: &Stroke{Points: []Point{{X: 1, Y: 2}}, Color: "#000000", Width: 2, LineStyle: "Dotted"}}); err != nil {
+		t.Fatalf("Failed to send stroke: %v", err)
+	}
+	if m := readMessage(t, viewer); m.Stroke == nil || m.Stroke.LineStyle != db.LineDotted {
+		t.Fatalf("Expected the relayed stroke to be dotted, got %+v", m.Stroke)
+	}
+	late := dialAuthed(t, srv, "?snapshot=full", cookies)
+	snap := readMessage(t, late)
+	if len(snap.Strokes) != 1 || snap.Strokes[0].LineStyle != db.LineDotted {
+		t.Fatalf("Expected a dotted stroke in the snapshot, got %+v", snap.Strokes)
+	}
+}
+
+func TestHub_NoteForUnknownStrokeIsNotBroadcast(t *testing.T) {
+	_, srv, cookies := newAuthedHub(t)
+	c := dialAuthed(t, srv, "", cookies)
+
+	if err := c.WriteJSON(message{Type: "note", Note: &NoteUpdate{ID: 999, Note: "ghost"}}); err != nil {
+		t.Fatalf("Failed to send note: %v", err)
+	}
+	// A cursor sent afterwards must be the next message, proving the note was dropped
+	if err := c.WriteJSON(message{Type: "cursor", Cursor: &Cursor{ClientID: "c"}}); err != nil {
+		t.Fatalf("Failed to send cursor: %v", err)
+	}
+	if m := readMessage(t, c); m.Type != "cursor" {
+		t.Fatalf("Expected cursor, got %q", m.Type)
+	}
+}
+
+func TestHub_RemoveIsIdempotent(t *testing.T) {
+	hub := NewHub(&db.Store{}, &auth.Service{})
+	conn := &websocket.Conn{}
+	hub.add(conn)
+
+	if !hub.remove(conn) {
+		t.Fatal("First remove should report the conn was registered")
+	}
+	if hub.remove(conn) {
+		t.Fatal("Second remove should be a no-op")
+	}
+	if hub.remove(&websocket.Conn{}) {
+		t.Fatal("Removing an unknown conn should be a no-op")
+	}
+	if n := hub.ClientCount(); n != 0 {
+		t.Fatalf("Expected 0 clients, got %d", n)
+	}
+}
+
+func TestHub_AddRespectsMaxClients(t *testing.T) {
+	hub := NewHub(&db.Store{}, &auth.Service{})
+	hub.MaxClients = 2
+	a, b := &websocket.Conn{}, &websocket.Conn{}
+	if !hub.add(a) || !hub.add(b) {
+		t.Fatal("Conns under the cap should be added")
+	}
+	if hub.add(&websocket.Conn{}) {
+		t.Fatal("Conn over the cap should be refused")
+	}
+	if !hub.add(a) {
+		t.Fatal("Re-adding a registered conn should not count against the cap")
+	}
+	hub.remove(a)
+	if !hub.add(&websocket.Conn{}) {
+		t.Fatal("A freed slot should be reusable")
+	}
+}
+
+func TestHub_RejectsUpgradeAtMaxClients(t *testing.T) {
+	hub, srv, cookies := newAuthedHub(t)
+	hub.MaxClients = 1
+	dialAuthed(t, srv, "", cookies)
+	waitForClients(t, hub, 1)
+
+	header := http.Header{}
+	for _, c := range cookies {
+		header.Add("Cookie", c.String())
+	}
+	_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), header)
+	if err == nil {
+		t.Fatal("Expected the second connection to be refused")
+	}
+	if resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503, got %v", resp)
+	}
+	if n := hub.ClientCount(); n != 1 {
+		t.Fatalf("Expected 1 client, got %d", n)
+	}
+}
+
+func TestHub_BroadcastRemovesBadConns(t *testing.T) {
+	hub := NewHub(&db.Store{}, &auth.Service{})
+	srv := newHubServer(t, hub)
+	good := dialHub(t, srv)
+	waitForClients(t, hub, 1)
+
+	zero := &websocket.Conn{}
+	hub.add(zero)
+	hub.setCursor(zero, Cursor{ClientID: "zero"})
+	if hub.add(nil) {
+		t.Fatal("A nil conn should never be registered")
+	}
+
+	hub.broadcast(message{Type: "cursor", Cursor: &Cursor{ClientID: "x"}})
+
+	if n := hub.ClientCount(); n != 1 {
+		t.Fatalf("Expected the bad conn to be removed, got %d clients", n)
+	}
+	if _, ok := hub.clients[zero]; ok {
+		t.Fatal("Zero-value conn should have been dropped")
+	}
+	if len(hub.cursorRoster(Lobby)) != 0 {
+		t.Fatal("Dropped conn's cursor should be removed")
+	}
+	if m := readMessage(t, good); m.Type != "cursor" {
+		t.Fatalf("Healthy client should still get the broadcast, got %q", m.Type)
+	}
+}
+
+// newKeepaliveHub is an authed hub with intervals short enough to watch several pings in a test
+func newKeepaliveHub(t *testing.T) (*Hub, *httptest.Server, []*http.Cookie) {
+	t.Helper()
+	hub, srv, cookies := newAuthedHub(t)
+	hub.PingInterval = 30 * time.Millisecond
+	hub.PongWait = 100 * time.Millisecond
+	return hub, srv, cookies
+}
+
+// readUntilClosed reads from c in the background and reports the first read error
+func readUntilClosed(c *websocket.Conn) <-chan error {
+	closed := make(chan error, 1)
+	go func() {
+		for {
+			if _, _, err := c.ReadMessage(); err != nil { closed <- err; return }
+		}
+	}()
+	return closed
+}
+
+func TestHub_KeepaliveSurvivesWhileClientPongs(t *testing.T) {
+	hub, srv, cookies := newKeepaliveHub(t)
+	client := dialAuthed(t, srv, "", cookies)
+	var pings atomic.Int32
+	client.SetPingHandler(func(data string) error {
+		pings.Add(1)
+		return client.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	closed := readUntilClosed(client)
+	waitForClients(t, hub, 1)
+
+	// Several pong waits pass; only the pongs keep pushing the server's read deadline out
+	select {
+	case err := <-closed:
+		t.Fatalf("Connection should stay open while the client answers pings: %v", err)
+	case <-time.After(400 * time.Millisecond):
+	}
+	if n := pings.Load(); n < 3 {
+		t.Fatalf("Expected several pings, got %d", n)
+	}
+	waitForClients(t, hub, 1)
+}
+
+func TestHub_KeepaliveDropsClientThatStopsPonging(t *testing.T) {
+	hub, srv, cookies := newKeepaliveHub(t)
+	client := dialAuthed(t, srv, "", cookies)
+	var answering atomic.Bool
+	answering.Store(true)
+	client.SetPingHandler(func(data string) error {
+		if !answering.Load() { return nil }
+		return client.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	closed := readUntilClosed(client)
+	waitForClients(t, hub, 1)
+	time.Sleep(150 * time.Millisecond)
+
+	answering.Store(false)
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Server should close a connection whose pongs stop")
+	}
+	waitForClients(t, hub, 0)
+}
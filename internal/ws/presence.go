@@ -0,0 +1,65 @@
+package ws
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/deliium/drawing-board/internal/metrics"
+	"github.com/deliium/drawing-board/internal/ratelimit"
+)
+
+var throttledCursors = metrics.NewCounter("ws_throttled_cursors_total", "Cursor messages dropped because their connection exceeded the cursor rate.")
+
+// Presence lists the client IDs connected to a room, sorted
+type Presence struct {
+	ClientIDs []string `json:"clientIds"`
+}
+
+// DefaultCursorRate and DefaultCursorBurst are used when the Hub fields are zero; a pointer
+// moving at display rate stays under them
+const (
+	DefaultCursorRate  = 30
+	DefaultCursorBurst = 30
+)
+
+// maxClientIDLength bounds ?client_id=; longer values get a generated ID instead
+const maxClientIDLength = 64
+
+// connClientID is the ID a connection is listed under in presence messages: ?client_id= when
+// usable, which lets clients match it to the clientId on their strokes and cursors, random otherwise
+func connClientID(r *http.Request) string {
+	id := r.URL.Query().Get("client_id")
+	if id != "" && utf8.RuneCountInString(id) <= maxClientIDLength && strings.IndexFunc(id, unicode.IsControl) < 0 { return id }
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// newCursorLimiter returns the bucket one connection's cursor messages are drawn from
+func (h *Hub) newCursorLimiter() *ratelimit.Limiter {
+	rate, burst := h.CursorRate, h.CursorBurst
+	if rate <= 0 { rate = DefaultCursorRate }
+	if burst <= 0 { burst = DefaultCursorBurst }
+	return ratelimit.New(rate, burst)
+}
+
+// announcePresence tells room who is connected to it when Presence is on. The roster is read and
+// written under one lock so concurrent joins cannot deliver a stale list last.
+func (h *Hub) announcePresence(room int64) {
+	if !h.Presence { return }
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ids := make([]string, 0, len(h.rooms[room]))
+	for c := range h.rooms[room] { ids = append(ids, h.clientIDs[c]) }
+	sort.Strings(ids)
+	m := message{Type: "presence", Presence: &Presence{ClientIDs: ids}}
+	b, err := json.Marshal(m)
+	if err != nil { return }
+	h.writeLocked(room, h.rooms[room], nil, m, b)
+}
@@ -0,0 +1,89 @@
+package ws
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCursor_RelayedButNotPersisted(t *testing.T) {
+	hub, srv, cookies := newAuthedHub(t)
+	author := dialAuthed(t, srv, "", cookies)
+	viewer := dialAuthed(t, srv, "", cookies)
+	waitForClients(t, hub, 2)
+
+	if err := author.WriteJSON(message{Type: "cursor", Cursor: &Cursor{ClientID: "a", X: 5, Y: 6}}); err != nil {
+		t.Fatalf("Failed to send cursor: %v", err)
+	}
+	if m := readMessage(t, viewer); m.Type != "cursor" || m.Cursor == nil || m.Cursor.X != 5 || m.Cursor.Y != 6 {
+		t.Fatalf("Expected the cursor to be relayed, got %+v", m)
+	}
+	rows, err := hub.Store.ListStrokesByUser(1)
+	if err != nil {
+		t.Fatalf("Failed to list strokes: %v", err)
+	}
+	if len(rows) != 0 {
+		t.Fatalf("Expected no strokes to be saved for a cursor, got %d", len(rows))
+	}
+}
+
+func TestCursor_RateLimitDropsExcess(t *testing.T) {
+	hub, srv, cookies := newAuthedHub(t)
+	hub.CursorRate, hub.CursorBurst = 0.001, 2
+	author := dialAuthed(t, srv, "", cookies)
+	viewer := dialAuthed(t, srv, "", cookies)
+	waitForClients(t, hub, 2)
+
+	before := throttledCursors.Value()
+	for i := 0; i < 5; i++ {
+		if err := author.WriteJSON(message{Type: "cursor", Cursor: &Cursor{ClientID: "a", X: float64(i)}}); err != nil {
+			t.Fatalf("Failed to send cursor: %v", err)
+		}
+	}
+	if err := author.WriteJSON(message{Type: "stroke", Stroke: &Stroke{Points: []Point{{X: 1, Y: 2}}, Color: "#000000", Width: 2}}); err != nil {
+		t.Fatalf("Failed to send stroke: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if m := readMessage(t, viewer); m.Type != "cursor" {
+			t.Fatalf("Expected cursor %d within the burst, got %q", i, m.Type)
+		}
+	}
+	// Messages are relayed in order, so the stroke arriving next means the rest were dropped
+	if m := readMessage(t, viewer); m.Type != "stroke" {
+		t.Fatalf("Expected the stroke after the burst, got %q", m.Type)
+	}
+	if got := throttledCursors.Value() - before; got != 3 {
+		t.Fatalf("Expected 3 throttled cursors, got %d", got)
+	}
+}
+
+func TestPresence_AnnouncedOnJoinAndLeave(t *testing.T) {
+	hub, srv, cookies := newAuthedHub(t)
+	hub.Presence = true
+	a := dialAuthed(t, srv, "?client_id=a", cookies)
+	if m := readMessage(t, a); m.Type != "presence" || !reflect.DeepEqual(m.Presence.ClientIDs, []string{"a"}) {
+		t.Fatalf("Expected presence [a], got %+v", m)
+	}
+	b := dialAuthed(t, srv, "?client_id=b", cookies)
+	for _, c := range []struct {
+		name string
+		m    message
+	}{{"a", readMessage(t, a)}, {"b", readMessage(t, b)}} {
+		if c.m.Type != "presence" || !reflect.DeepEqual(c.m.Presence.ClientIDs, []string{"a", "b"}) {
+			t.Fatalf("Expected %s to see presence [a b], got %+v", c.name, c.m)
+		}
+	}
+	b.Close()
+	if m := readMessage(t, a); m.Type != "presence" || !reflect.DeepEqual(m.Presence.ClientIDs, []string{"a"}) {
+		t.Fatalf("Expected presence [a] after b left, got %+v", m)
+	}
+}
+
+func TestConnClientID_RejectsUnusableValues(t *testing.T) {
+	hub, srv, cookies := newAuthedHub(t)
+	hub.Presence = true
+	c := dialAuthed(t, srv, "?client_id=%0A", cookies)
+	m := readMessage(t, c)
+	if m.Type != "presence" || len(m.Presence.ClientIDs) != 1 || len(m.Presence.ClientIDs[0]) != 16 {
+		t.Fatalf("Expected a generated client ID, got %+v", m)
+	}
+}
@@ -0,0 +1,252 @@
+package ws
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deliium/drawing-board/internal/auth"
+	"github.com/deliium/drawing-board/internal/db"
+	"github.com/gorilla/websocket"
+)
+
+// newRoomServer registers each connection in the room named by its ?room= query
+func newRoomServer(t *testing.T, hub *Hub) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		room, _ := strconv.ParseInt(r.URL.Query().Get("room"), 10, 64)
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		hub.addTo(room, conn)
+		defer hub.removeFrom(room, conn)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func dialRoom(t *testing.T, srv *httptest.Server, room int64) *websocket.Conn {
+	t.Helper()
+	c, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws%s?room=%d", strings.TrimPrefix(srv.URL, "http"), room), nil)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// expectSilence fails if c receives anything within a short window; c is unusable afterwards
+func expectSilence(t *testing.T, c *websocket.Conn) {
+	t.Helper()
+	c.SetReadDeadline(time.Now().Add(150 * time.Millisecond))
+	var m message
+	if err := c.ReadJSON(&m); err == nil {
+		t.Fatalf("Expected nothing, got %+v", m)
+	}
+}
+
+func TestHub_BroadcastToStaysInRoom(t *testing.T) {
+	hub := NewHub(&db.Store{}, &auth.Service{})
+	srv := newRoomServer(t, hub)
+	a := dialRoom(t, srv, 1)
+	b := dialRoom(t, srv, 2)
+	waitForClients(t, hub, 2)
+
+	hub.broadcastTo(1, message{Type: "stroke", Stroke: &Stroke{ID: 11}})
+	if m := readMessage(t, a); m.Stroke == nil || m.Stroke.ID != 11 {
+		t.Fatalf("Expected room 1 to get its stroke, got %+v", m)
+	}
+	// Messages arrive in order, so b's first message being room 2's proves room 1's never reached it
+	hub.broadcastTo(2, message{Type: "stroke", Stroke: &Stroke{ID: 22}})
+	if m := readMessage(t, b); m.Stroke == nil || m.Stroke.ID != 22 {
+		t.Fatalf("Expected room 2's stroke first, got %+v", m)
+	}
+	expectSilence(t, a)
+}
+
+func TestHub_RoomMembership(t *testing.T) {
+	hub := NewHub(&db.Store{}, &auth.Service{})
+	a, b := &websocket.Conn{}, &websocket.Conn{}
+	if !hub.addTo(1, a) || !hub.addTo(2, b) {
+		t.Fatal("Expected both conns to be added")
+	}
+	if hub.removeFrom(2, a) {
+		t.Fatal("A conn should not be removable from a room it never joined")
+	}
+	if !hub.removeFrom(1, a) || hub.removeFrom(1, a) {
+		t.Fatal("Expected removeFrom to succeed once")
+	}
+	if _, ok := hub.rooms[1]; ok {
+		t.Fatal("Expected the empty room to be deleted")
+	}
+	if !hub.remove(b) || hub.ClientCount() != 0 || len(hub.rooms) != 0 {
+		t.Fatalf("Expected remove to find b's room, got %d clients and %d rooms", hub.ClientCount(), len(hub.rooms))
+	}
+}
+
+func TestHub_MaxClientsSpansRooms(t *testing.T) {
+	hub := NewHub(&db.Store{}, &auth.Service{})
+	hub.MaxClients = 2
+	if !hub.addTo(1, &websocket.Conn{}) || !hub.addTo(2, &websocket.Conn{}) {
+		t.Fatal("Expected two conns in different rooms to fit")
+	}
+	if hub.addTo(3, &websocket.Conn{}) {
+		t.Fatal("Expected the third conn to be refused whatever its room")
+	}
+}
+
+func TestHub_BoardQueryJoinsOwnedBoardOnly(t *testing.T) {
+	hub, srv, cookies := newAuthedHub(t)
+	owner, _ := hub.Store.GetUserByEmail("ws@example.com")
+	board, err := hub.Store.CreateBoard(owner.ID, "Room")
+	if err != nil {
+		t.Fatalf("Failed to create board: %v", err)
+	}
+	other, _ := hub.Store.CreateUser("other@example.com", "hash")
+	foreign, _ := hub.Store.CreateBoard(other, "Not yours")
+
+	onBoard := dialAuthed(t, srv, fmt.Sprintf("?board=%d", board), cookies)
+	onDefault := dialAuthed(t, srv, "", cookies)
+	waitForClients(t, hub, 2)
+	if err := onBoard.WriteJSON(message{Type: "stroke", Stroke: &Stroke{Color: "#000000", Width: 2, Points: []Point{{X: 1, Y: 1}, {X: 9, Y: 9}}}}); err != nil {
+		t.Fatalf("Failed to send stroke: %v", err)
+	}
+	if m := readMessage(t, onBoard); m.Type != "ack" || m.Ack.ID == 0 {
+		t.Fatalf("Expected the saved stroke acked on the board, got %+v", m)
+	}
+	expectSilence(t, onDefault)
+	if strokes, _ := hub.Store.ListStrokesByBoard(board); len(strokes) != 1 {
+		t.Fatalf("Expected the stroke saved on the board, got %d", len(strokes))
+	}
+
+	for query, want := range map[string]int{fmt.Sprintf("?board=%d", foreign): 404, "?board=999": 404, "?board=x": 400} {
+		header := http.Header{}
+		for _, c := range cookies {
+			header.Add("Cookie", c.String())
+		}
+		_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+query, header)
+		if err == nil || resp == nil || resp.StatusCode != want {
+			t.Fatalf("%s: expected %d, got %v", query, want, resp)
+		}
+	}
+}
+
+func TestHub_SnapshotCarriesBoardCanvas(t *testing.T) {
+	hub, srv, cookies := newAuthedHub(t)
+	owner, _ := hub.Store.GetUserByEmail("ws@example.com")
+	board, _ := hub.Store.CreateBoard(owner.ID, "Wide")
+	if err := hub.Store.SetBoardSettings(board, db.BoardSettings{Width: 1920, Height: 1080, Background: "#222222"}); err != nil {
+		t.Fatalf("Failed to save settings: %v", err)
+	}
+	snap := readMessage(t, dialAuthed(t, srv, fmt.Sprintf("?board=%d&snapshot=full", board), cookies))
+	if snap.Canvas == nil || *snap.Canvas != (Canvas{Width: 1920, Height: 1080, Background: "#222222"}) {
+		t.Fatalf("Expected the board canvas in the snapshot, got %+v", snap.Canvas)
+	}
+	// Without ?board= the caller is on their default board, which has no settings saved
+	if snap := readMessage(t, dialAuthed(t, srv, "?snapshot=full", cookies)); snap.Type != "snapshot" || snap.Canvas != nil {
+		t.Fatalf("Expected a default board snapshot without a canvas, got %+v", snap)
+	}
+}
+
+func TestHub_SnapshotNamesEachStrokesAuthor(t *testing.T) {
+	hub, srv, cookies := newAuthedHub(t)
+	owner, _ := hub.Store.GetUserByEmail("ws@example.com")
+	guest, err := hub.Store.CreateUser("guest@example.com", "x")
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	if err := hub.Store.SetDisplayName(owner.ID, "Owner"); err != nil {
+		t.Fatalf("Failed to set display name: %v", err)
+	}
+	if err := hub.Store.SetDisplayName(guest, "Guest"); err != nil {
+		t.Fatalf("Failed to set display name: %v", err)
+	}
+	board, _ := hub.Store.CreateBoard(owner.ID, "Shared")
+	for _, by := range []int64{owner.ID, guest} {
+		if _, err := hub.Store.SaveStrokeRecord(owner.ID, db.Stroke{Color: "#000000", Width: 2, BoardID: board, CreatedBy: by, Points: []db.StrokePoint{{X: 1, Y: 1}}}); err != nil {
+			t.Fatalf("Failed to save stroke: %v", err)
+		}
+	}
+	snap := readMessage(t, dialAuthed(t, srv, fmt.Sprintf("?board=%d&snapshot=full", board), cookies))
+	if len(snap.Strokes) != 2 {
+		t.Fatalf("Expected both strokes, got %+v", snap.Strokes)
+	}
+	for _, st := range snap.Strokes {
+		want := map[int64]string{owner.ID: "Owner", guest: "Guest"}[st.CreatedBy]
+		if st.DisplayName != want {
+			t.Fatalf("Expected stroke %d by user %d to carry %q, got %q", st.ID, st.CreatedBy, want, st.DisplayName)
+		}
+	}
+}
+
+func TestHub_DeltaSnapshotKeepsExactCoordinates(t *testing.T) {
+	hub, srv, cookies := newAuthedHub(t)
+	owner, _ := hub.Store.GetUserByEmail("ws@example.com")
+	board, _ := hub.Store.CreateBoard(owner.ID, "Precise")
+	grid := []db.StrokePoint{{X: 10, Y: 20}, {X: 12.5, Y: 21}}
+	fine := []db.StrokePoint{{X: 10, Y: 20}, {X: 100.0 / 3, Y: 21.004}}
+	for _, pts := range [][]db.StrokePoint{grid, fine} {
+		if _, err := hub.Store.SaveStrokeRecord(owner.ID, db.Stroke{Color: "#000000", Width: 2, BoardID: board, Points: pts}); err != nil {
+			t.Fatalf("Failed to save stroke: %v", err)
+		}
+	}
+	snap := readMessage(t, dialAuthed(t, srv, fmt.Sprintf("?board=%d&snapshot=delta", board), cookies))
+	if snap.Encoding != "delta" || len(snap.Strokes) != 2 {
+		t.Fatalf("Expected a delta snapshot of both strokes, got %+v", snap)
+	}
+	for i, want := range [][]db.StrokePoint{grid, fine} {
+		st := snap.Strokes[i]
+		got := st.Points
+		if st.Delta != nil { got = DecodeDelta(st.Delta) }
+		if len(got) != len(want) {
+			t.Fatalf("Stroke %d: expected %d points, got %+v", i, len(want), st)
+		}
+		for j := range want {
+			if got[j] != (Point{X: want[j].X, Y: want[j].Y}) {
+				t.Fatalf("Stroke %d point %d: expected %v exactly, got %v", i, j, want[j], got[j])
+			}
+		}
+	}
+	if snap.Strokes[0].Delta == nil || snap.Strokes[1].Delta != nil {
+		t.Fatalf("Expected only the stroke on the delta grid to be delta-encoded, got %+v", snap.Strokes)
+	}
+}
+
+func TestHub_NoBoardQueryJoinsDefaultBoard(t *testing.T) {
+	hub, srv, cookies := newAuthedHub(t)
+	owner, _ := hub.Store.GetUserByEmail("ws@example.com")
+	def, err := hub.Store.DefaultBoardID(owner.ID)
+	if err != nil || def == Lobby {
+		t.Fatalf("Expected the account to have a default board, got %d, %v", def, err)
+	}
+	signedIn := dialAuthed(t, srv, "", cookies)
+	anonymous := dialAuthed(t, srv, "", nil)
+	waitForClients(t, hub, 2)
+	hub.mu.Lock()
+	rooms := map[int64]int{}
+	for _, cl := range hub.clients { rooms[cl.room]++ }
+	hub.mu.Unlock()
+	if rooms[def] != 1 || rooms[Lobby] != 1 {
+		t.Fatalf("Expected one conn on the default board and the anonymous one in the Lobby, got %v", rooms)
+	}
+
+	// Another user's lobby strokes no longer reach a signed-in caller
+	hub.broadcastTo(Lobby, message{Type: "stroke", Stroke: &Stroke{ID: 1}})
+	hub.broadcastTo(def, message{Type: "stroke", Stroke: &Stroke{ID: 2}})
+	if m := readMessage(t, signedIn); m.Stroke == nil || m.Stroke.ID != 2 {
+		t.Fatalf("Expected only the default board's stroke, got %+v", m)
+	}
+	if m := readMessage(t, anonymous); m.Stroke == nil || m.Stroke.ID != 1 {
+		t.Fatalf("Expected the anonymous conn to stay in the Lobby, got %+v", m)
+	}
+}
@@ -0,0 +1,67 @@
+package ws
+
+import (
+	"context"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Shutdown stops the hub taking new WebSocket connections and NDJSON streams, ends every open
+// stream and sends each WebSocket a going-away close frame. Clients get one write deadline to
+// answer before their reads fail; use Wait for the connection handlers to flush and return.
+// Calling it again is a no-op.
+func (h *Hub) Shutdown() {
+	h.mu.Lock()
+	if h.closing { h.mu.Unlock(); return }
+	h.closing = true
+	close(h.quitLocked())
+	for room, subs := range h.streams {
+		for ch := range subs { h.dropStreamLocked(room, ch) }
+	}
+	conns := make([]*websocket.Conn, 0, len(h.clients))
+	for c := range h.clients { conns = append(conns, c) }
+	h.mu.Unlock()
+	// Written outside the lock like every other frame; WriteControl may run alongside the writers
+	deadline := time.Now().Add(h.writeDeadline())
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	for _, c := range conns {
+		if c.UnderlyingConn() == nil { continue }
+		_ = c.WriteControl(websocket.CloseMessage, msg, deadline)
+		_ = c.SetReadDeadline(deadline)
+	}
+}
+
+// Wait blocks until every WebSocket handler has returned (batched strokes saved, clients
+// removed) or ctx is done
+func (h *Hub) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() { h.active.Wait(); close(done) }()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// begin counts a connection handler for Wait, refusing once Shutdown has been called. The check
+// and the count share the lock so no handler starts after Wait has begun draining.
+func (h *Hub) begin() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closing { return false }
+	h.active.Add(1)
+	return true
+}
+
+// isClosing reports whether Shutdown has been called
+func (h *Hub) isClosing() bool { h.mu.Lock(); defer h.mu.Unlock(); return h.closing }
+
+// quit is closed by Shutdown; connection goroutines such as the pinger select on it
+func (h *Hub) quit() <-chan struct{} { h.mu.Lock(); defer h.mu.Unlock(); return h.quitLocked() }
+
+func (h *Hub) quitLocked() chan struct{} {
+	if h.quitCh == nil { h.quitCh = make(chan struct{}) }
+	return h.quitCh
+}
@@ -0,0 +1,66 @@
+package ws
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestHub_ShutdownSendsCloseFramesAndWaits(t *testing.T) {
+	hub, srv, cookies := newAuthedHub(t)
+	c := dialAuthed(t, srv, "", cookies)
+	waitForClients(t, hub, 1)
+
+	hub.Shutdown()
+	hub.Shutdown() // idempotent
+	c.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, _, err := c.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Fatalf("Expected a going-away close frame, got %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := hub.Wait(ctx); err != nil {
+		t.Fatalf("Expected handlers to return after the close handshake: %v", err)
+	}
+	if hub.ClientCount() != 0 {
+		t.Fatalf("Expected no clients after shutdown, got %d", hub.ClientCount())
+	}
+
+	header := http.Header{}
+	for _, ck := range cookies { header.Add("Cookie", ck.String()) }
+	_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), header)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 for a connection after shutdown, got %v", err)
+	}
+}
+
+func TestHub_WaitTimesOutOnStuckClient(t *testing.T) {
+	hub, srv, cookies := newAuthedHub(t)
+	hub.WriteDeadline = time.Minute // the client gets far longer than Wait allows to answer
+	dialAuthed(t, srv, "", cookies)
+	waitForClients(t, hub, 1)
+	hub.Shutdown()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := hub.Wait(ctx); err == nil {
+		t.Fatal("Expected Wait to give up while a client has not answered the close frame")
+	}
+}
+
+func TestStream_EndsOnShutdown(t *testing.T) {
+	hub, _, cookies := newAuthedHub(t)
+	lines, _ := openStream(t, hub, cookies)
+	hub.Shutdown()
+	if _, err := lines.ReadBytes('\n'); err == nil {
+		t.Fatal("Expected the stream to end on shutdown")
+	}
+	waitForStreams(t, hub, 0)
+	if ch := hub.subscribe(Lobby); ch != nil {
+		t.Fatal("Expected subscriptions to be refused after shutdown")
+	}
+}
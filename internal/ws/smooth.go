@@ -0,0 +1,35 @@
+package ws
+
+import "github.com/deliium/drawing-board/internal/smooth"
+
+// Smoothing methods accepted by ParseSmoothing
+const (
+	SmoothNone     = smooth.None
+	SmoothMoving   = smooth.Moving
+	SmoothGaussian = smooth.Gaussian
+)
+
+// Smoothing is applied to incoming stroke points before they are saved and broadcast, see
+// smooth.Smoothing. The zero value is off.
+type Smoothing struct {
+	Method string
+	Window int // points in the averaging window; values below 3 disable smoothing
+}
+
+// ParseSmoothing validates a method name and window from configuration
+func ParseSmoothing(method string, window int) (Smoothing, error) {
+	s, err := smooth.Parse(method, window)
+	return Smoothing(s), err
+}
+
+func (s Smoothing) enabled() bool { return smooth.Smoothing(s).Enabled() }
+
+// Apply returns a smoothed copy of points, see smooth.Smoothing.Apply
+func (s Smoothing) Apply(points []Point) []Point {
+	if !s.enabled() { return points }
+	in := make([]smooth.Point, len(points))
+	for i, p := range points { in[i] = smooth.Point(p) }
+	out := make([]Point, len(points))
+	for i, p := range smooth.Smoothing(s).Apply(in) { out[i] = Point(p) }
+	return out
+}
@@ -0,0 +1,93 @@
+package ws
+
+import (
+	"math"
+	"testing"
+)
+
+// jitteryLine is a horizontal line from (0, 50) to (100, 50) with alternating vertical noise
+func jitteryLine() []Point {
+	pts := make([]Point, 0, 51)
+	for i := 0; i <= 50; i++ {
+		y := 50.0
+		if i > 0 && i < 50 { y += 3 * float64(1-2*(i%2)) }
+		pts = append(pts, Point{X: float64(i * 2), Y: y})
+	}
+	return pts
+}
+
+// meanDeviation is the average distance of points from the line through the first and last point
+func meanDeviation(pts []Point) float64 {
+	a, b := pts[0], pts[len(pts)-1]
+	dx, dy := b.X-a.X, b.Y-a.Y
+	norm := math.Hypot(dx, dy)
+	var sum float64
+	for _, p := range pts { sum += math.Abs(dy*(p.X-a.X)-dx*(p.Y-a.Y)) / norm }
+	return sum / float64(len(pts))
+}
+
+func TestSmoothing_ReducesDeviationKeepsEndpoints(t *testing.T) {
+	raw := jitteryLine()
+	for _, method := range []string{SmoothMoving, SmoothGaussian} {
+		s, err := ParseSmoothing(method, 5)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", method, err)
+		}
+		got := s.Apply(raw)
+		if len(got) != len(raw) {
+			t.Fatalf("%s: expected %d points, got %d", method, len(raw), len(got))
+		}
+		if got[0] != raw[0] || got[len(got)-1] != raw[len(raw)-1] {
+			t.Fatalf("%s: endpoints moved: %v %v", method, got[0], got[len(got)-1])
+		}
+		if before, after := meanDeviation(raw), meanDeviation(got); after >= before/2 {
+			t.Fatalf("%s: expected deviation to drop well below %.2f, got %.2f", method, before, after)
+		}
+	}
+	if raw[1].Y != 47 {
+		t.Fatal("Apply must not modify its input")
+	}
+}
+
+func TestSmoothing_OffByDefault(t *testing.T) {
+	raw := jitteryLine()
+	if got := (Smoothing{}).Apply(raw); meanDeviation(got) != meanDeviation(raw) {
+		t.Fatal("Zero Smoothing should leave points unchanged")
+	}
+	if s, err := ParseSmoothing("", 0); err != nil || s.enabled() {
+		t.Fatalf("Expected empty method to disable smoothing, got %+v, %v", s, err)
+	}
+	if _, err := ParseSmoothing("moving", 1); err == nil {
+		t.Fatal("Expected error for a window below 3")
+	}
+	if _, err := ParseSmoothing("spline", 5); err == nil {
+		t.Fatal("Expected error for an unknown method")
+	}
+}
+
+func TestHub_SmoothsStrokesBeforeSaving(t *testing.T) {
+	hub, srv, cookies := newAuthedHub(t)
+	hub.Smoothing = Smoothing{Method: SmoothMoving, Window: 5}
+	c := dialAuthed(t, srv, "", cookies)
+	viewer := dialAuthed(t, srv, "", cookies)
+	waitForClients(t, hub, 2)
+
+	raw := jitteryLine()
+	if err := c.WriteJSON(message{Type: "stroke", Stroke: &Stroke{Color: "#000000", Width: 2, Points: raw}}); err != nil {
+		t.Fatalf("Failed to send stroke: %v", err)
+	}
+	m := readMessage(t, viewer)
+	if m.Stroke == nil || meanDeviation(m.Stroke.Points) >= meanDeviation(raw) {
+		t.Fatalf("Expected the broadcast stroke to be smoothed, got %+v", m.Stroke)
+	}
+	u, _ := hub.Store.GetUserByEmail("ws@example.com")
+	saved, err := hub.Store.ListStrokesByUser(u.ID)
+	if err != nil || len(saved) != 1 {
+		t.Fatalf("Expected 1 saved stroke, got %d (%v)", len(saved), err)
+	}
+	for i, p := range saved[0].Points {
+		if p.X != m.Stroke.Points[i].X || p.Y != m.Stroke.Points[i].Y {
+			t.Fatalf("Point %d: stored %v differs from broadcast %v", i, p, m.Stroke.Points[i])
+		}
+	}
+}
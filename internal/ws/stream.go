@@ -0,0 +1,95 @@
+package ws
+
+import (
+	"log"
+	"net/http"
+	"time"
+)
+
+// StreamBuffer is how many lines a stream subscriber may fall behind before it is dropped
+const StreamBuffer = 64
+
+// streamed lists the message types relayed to NDJSON streams; cursors and drawing state stay on WebSocket
+var streamed = map[string]bool{"stroke": true, "delete": true}
+
+// subscribe registers a stream in room; the channel is closed if the subscriber falls behind or
+// the hub shuts down. It returns nil once Shutdown has been called.
+func (h *Hub) subscribe(room int64) chan []byte {
+	ch := make(chan []byte, StreamBuffer)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closing { return nil }
+	if h.streams == nil { h.streams = make(map[int64]map[chan []byte]struct{}) }
+	if h.streams[room] == nil { h.streams[room] = make(map[chan []byte]struct{}) }
+	h.streams[room][ch] = struct{}{}
+	return ch
+}
+
+// unsubscribe removes ch from room; a stream already dropped for falling behind is a no-op
+func (h *Hub) unsubscribe(room int64, ch chan []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.streams[room][ch]; ok { h.dropStreamLocked(room, ch) }
+}
+
+func (h *Hub) dropStreamLocked(room int64, ch chan []byte) {
+	close(ch)
+	delete(h.streams[room], ch)
+	if len(h.streams[room]) == 0 { delete(h.streams, room) }
+}
+
+// StreamCount returns the number of open NDJSON streams
+func (h *Hub) StreamCount() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := 0
+	for _, subs := range h.streams { n += len(subs) }
+	return n
+}
+
+// publishLocked queues b for room's streams when v is a streamed message type. A full buffer
+// drops the subscriber instead of blocking the broadcast, like a failed WebSocket write.
+func (h *Hub) publishLocked(room int64, v interface{}, b []byte) {
+	m, ok := v.(message)
+	if !ok || !streamed[m.Type] { return }
+	for ch := range h.streams[room] {
+		select {
+		case ch <- b:
+		default:
+			droppedMessages.Inc()
+			h.dropStreamLocked(room, ch)
+		}
+	}
+}
+
+// ServeStream writes each stroke and delete broadcast to the caller's room (?board= as for the
+// WebSocket, the Lobby otherwise) as one JSON line, flushing after every line, until the client
+// disconnects, falls more than StreamBuffer lines behind or the hub shuts down
+func (h *Hub) ServeStream(w http.ResponseWriter, r *http.Request) {
+	room, status := h.roomFor(r)
+	if status != 0 { http.Error(w, http.StatusText(status), status); return }
+	rc := http.NewResponseController(w)
+	// The server's WriteTimeout would cut the stream short; each line gets its own deadline instead
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && err != http.ErrNotSupported { log.Printf("stream deadline: %v", err) }
+	ch := h.subscribe(room)
+	if ch == nil { http.Error(w, "server shutting down", http.StatusServiceUnavailable); return }
+	defer h.unsubscribe(room, ch)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // keep proxies like nginx from holding lines back
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil { log.Printf("stream flush: %v", err); return }
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case b, ok := <-ch:
+			if !ok { return }
+			_ = rc.SetWriteDeadline(time.Now().Add(h.writeDeadline()))
+			// b is shared by every subscriber, so the newline is a separate write rather than an append
+			if _, err := w.Write(b); err != nil { return }
+			if _, err := w.Write([]byte{'\n'}); err != nil { return }
+			if err := rc.Flush(); err != nil { return }
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package ws
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/deliium/drawing-board/internal/auth"
+	"github.com/deliium/drawing-board/internal/db"
+)
+
+// openStream starts an NDJSON stream on hub for the given cookies and waits until it is subscribed
+func openStream(t *testing.T, hub *Hub, cookies []*http.Cookie) (*bufio.Reader, context.CancelFunc) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(hub.ServeStream))
+	t.Cleanup(srv.Close)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	for _, c := range cookies { req.AddCookie(c) }
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("Expected 200 application/x-ndjson, got %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	waitForStreams(t, hub, 1)
+	return bufio.NewReader(resp.Body), cancel
+}
+
+func waitForStreams(t *testing.T, hub *Hub, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for hub.StreamCount() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d streams, got %d", n, hub.StreamCount())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestStream_StrokeSavedOverWebSocketAppears(t *testing.T) {
+	hub, srv, cookies := newAuthedHub(t)
+	lines, _ := openStream(t, hub, cookies)
+
+	c := dialAuthed(t, srv, "", cookies)
+	if err := c.WriteJSON(message{Type: "stroke", Stroke: &Stroke{Points: []Point{{X: 1, Y: 2}, {X: 3, Y: 4}}, Color: "#000000", Width: 2}}); err != nil {
+		t.Fatalf("Failed to send stroke: %v", err)
+	}
+	// Cursors are not streamed, so the next line must be the stroke
+	if err := c.WriteJSON(message{Type: "cursor", Cursor: &Cursor{ClientID: "c1", X: 1, Y: 1}}); err != nil {
+		t.Fatalf("Failed to send cursor: %v", err)
+	}
+	line, err := lines.ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("Failed to read stream line: %v", err)
+	}
+	var m message
+	if err := json.Unmarshal(line, &m); err != nil {
+		t.Fatalf("Stream line is not JSON: %v (%q)", err, line)
+	}
+	if m.Type != "stroke" || m.Stroke == nil || m.Stroke.ID == 0 || len(m.Stroke.Points) != 2 {
+		t.Fatalf("Expected the saved stroke, got %s", line)
+	}
+
+	if err := c.WriteJSON(message{Type: "delete", Delete: &m.Stroke.ID}); err != nil {
+		t.Fatalf("Failed to send delete: %v", err)
+	}
+	line, err = lines.ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("Failed to read stream line: %v", err)
+	}
+	if err := json.Unmarshal(line, &m); err != nil || m.Type != "delete" {
+		t.Fatalf("Expected a delete line, got %q (%v)", line, err)
+	}
+}
+
+func TestStream_DisconnectUnsubscribes(t *testing.T) {
+	hub, _, cookies := newAuthedHub(t)
+	_, cancel := openStream(t, hub, cookies)
+	cancel()
+	waitForStreams(t, hub, 0)
+}
+
+func TestStream_SlowSubscriberIsDropped(t *testing.T) {
+	hub := NewHub(&db.Store{}, &auth.Service{})
+	ch := hub.subscribe(Lobby)
+	for i := 0; i <= StreamBuffer; i++ {
+		hub.broadcastTo(Lobby, message{Type: "stroke", Stroke: &Stroke{ID: int64(i + 1)}})
+	}
+	if hub.StreamCount() != 0 {
+		t.Fatalf("Expected the full stream to be dropped, got %d streams", hub.StreamCount())
+	}
+	n := 0
+	for range ch { n++ }
+	if n != StreamBuffer {
+		t.Fatalf("Expected %d buffered lines before the drop, got %d", StreamBuffer, n)
+	}
+	hub.unsubscribe(Lobby, ch) // already dropped; must not close twice
+}
+
+func TestStream_UnownedBoardRejected(t *testing.T) {
+	hub, _, cookies := newAuthedHub(t)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/strokes/stream?board=999", nil)
+	for _, c := range cookies { req.AddCookie(c) }
+	hub.ServeStream(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("Expected 404 for a board the caller does not own, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,66 @@
+package ws
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/deliium/drawing-board/internal/validate"
+	"github.com/gorilla/websocket"
+)
+
+// Event categories a connection can subscribe to; messages of other types (errors, snapshots,
+// replies) are always delivered
+const (
+	EventStrokes  = "strokes"  // stroke, delete, note and recolor
+	EventCursors  = "cursors"  // cursor
+	EventPresence = "presence" // drawing and presence
+)
+
+var eventCategory = map[string]string{
+	"stroke": EventStrokes, "delete": EventStrokes, "note": EventStrokes, "recolor": EventStrokes,
+	"cursor":  EventCursors,
+	"drawing": EventPresence, "presence": EventPresence,
+}
+
+// parseSubscription validates the categories of a subscribe message; nil means every category
+func parseSubscription(names []string) (map[string]bool, validate.Errors) {
+	if len(names) == 0 { return nil, nil }
+	var errs validate.Errors
+	out := map[string]bool{}
+	for _, n := range names {
+		n = strings.ToLower(strings.TrimSpace(n))
+		switch n {
+		case EventStrokes, EventCursors, EventPresence:
+			out[n] = true
+		default:
+			errs.Add("subscribe", "must list strokes, cursors or presence, got "+n)
+		}
+	}
+	return out, errs
+}
+
+// setSubscription replaces c's categories; nil restores the default of receiving everything
+func (h *Hub) setSubscription(c *websocket.Conn, cats map[string]bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if cats == nil { delete(h.subs, c); return }
+	if h.subs == nil { h.subs = make(map[*websocket.Conn]map[string]bool) }
+	h.subs[c] = cats
+}
+
+// wantsLocked reports whether c receives messages of type typ
+func (h *Hub) wantsLocked(c *websocket.Conn, typ string) bool {
+	cats, ok := h.subs[c]
+	if !ok { return true }
+	cat, ok := eventCategory[typ]
+	return !ok || cats[cat]
+}
+
+// subscriptionNames lists cats in a stable order for the reply; nil lists every category
+func subscriptionNames(cats map[string]bool) []string {
+	if cats == nil { return []string{EventStrokes, EventCursors, EventPresence} }
+	out := make([]string, 0, len(cats))
+	for c := range cats { out = append(out, c) }
+	sort.Strings(out)
+	return out
+}
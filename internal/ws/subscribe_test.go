@@ -0,0 +1,82 @@
+package ws
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func subscribeTo(t *testing.T, c *websocket.Conn, cats ...string) {
+	t.Helper()
+	if err := c.WriteJSON(message{Type: "subscribe", Subscribe: cats}); err != nil {
+		t.Fatalf("Failed to send subscribe: %v", err)
+	}
+}
+
+func TestSubscribe_StrokesOnlySkipsCursors(t *testing.T) {
+	hub, srv, cookies := newAuthedHub(t)
+	author := dialAuthed(t, srv, "", cookies)
+	viewer := dialAuthed(t, srv, "", cookies)
+	waitForClients(t, hub, 2)
+
+	subscribeTo(t, viewer, "strokes")
+	if m := readMessage(t, viewer); m.Type != "subscribed" || !reflect.DeepEqual(m.Subscribe, []string{EventStrokes}) {
+		t.Fatalf("Expected a subscribed reply for strokes, got %+v", m)
+	}
+	if err := author.WriteJSON(message{Type: "cursor", Cursor: &Cursor{ClientID: "a", X: 1, Y: 1}}); err != nil {
+		t.Fatalf("Failed to send cursor: %v", err)
+	}
+	if err := author.WriteJSON(message{Type: "stroke_start", Activity: &Activity{ClientID: "a"}}); err != nil {
+		t.Fatalf("Failed to send stroke_start: %v", err)
+	}
+	if err := author.WriteJSON(message{Type: "stroke", Stroke: &Stroke{Points: []Point{{X: 1, Y: 2}}, Color: "#000000", Width: 2}}); err != nil {
+		t.Fatalf("Failed to send stroke: %v", err)
+	}
+	// Messages are relayed in order, so anything ahead of the stroke would be a leaked cursor or drawing event
+	if m := readMessage(t, viewer); m.Type != "stroke" {
+		t.Fatalf("Expected the stroke first, got %q", m.Type)
+	}
+	if m := readMessage(t, author); m.Type != "cursor" {
+		t.Fatalf("Expected unsubscribed connections to still get cursors, got %q", m.Type)
+	}
+}
+
+func TestSubscribe_EmptyListRestoresEverything(t *testing.T) {
+	hub, srv, cookies := newAuthedHub(t)
+	author := dialAuthed(t, srv, "", cookies)
+	viewer := dialAuthed(t, srv, "", cookies)
+	waitForClients(t, hub, 2)
+
+	subscribeTo(t, viewer, "strokes")
+	readMessage(t, viewer)
+	subscribeTo(t, viewer)
+	if m := readMessage(t, viewer); m.Type != "subscribed" || len(m.Subscribe) != 3 {
+		t.Fatalf("Expected every category after an empty subscribe, got %+v", m)
+	}
+	if err := author.WriteJSON(message{Type: "cursor", Cursor: &Cursor{ClientID: "a"}}); err != nil {
+		t.Fatalf("Failed to send cursor: %v", err)
+	}
+	if m := readMessage(t, viewer); m.Type != "cursor" {
+		t.Fatalf("Expected the cursor, got %q", m.Type)
+	}
+}
+
+func TestSubscribe_UnknownCategoryRejected(t *testing.T) {
+	hub, srv, cookies := newAuthedHub(t)
+	c := dialAuthed(t, srv, "", cookies)
+	waitForClients(t, hub, 1)
+
+	subscribeTo(t, c, "strokes", "gossip")
+	m := readMessage(t, c)
+	if m.Type != "error" || len(m.Errors) != 1 || m.Errors[0].Field != "subscribe" {
+		t.Fatalf("Expected a subscribe error, got %+v", m)
+	}
+	// The rejected subscription leaves the default in place
+	if err := c.WriteJSON(message{Type: "cursor", Cursor: &Cursor{ClientID: "a"}}); err != nil {
+		t.Fatalf("Failed to send cursor: %v", err)
+	}
+	if m := readMessage(t, c); m.Type != "cursor" {
+		t.Fatalf("Expected the cursor, got %q", m.Type)
+	}
+}
@@ -1,7 +1,10 @@
 import React, { useEffect, useRef, useState, useCallback } from 'react'
+import { isHandledMessage } from './messages'
 
 type Point = { x: number; y: number }
 
+type LineStyle = 'solid' | 'dashed' | 'dotted'
+
 type Stroke = {
   id?: number
   points: Point[]
@@ -9,20 +12,80 @@ type Stroke = {
   width: number
   clientId: string
   startedAtUnixMs: number
+  delta?: number[]
+  note?: string
+  createdBy?: number
+  author?: string
+  boardId?: number
+  lineStyle?: LineStyle
 }
 
 type MsgStroke = { type: 'stroke'; stroke: Stroke }
 
 type MsgDelete = { type: 'delete'; delete: number }
 
-type Message = MsgStroke | MsgDelete
+type MsgSnapshot = { type: 'snapshot'; strokes: Stroke[]; encoding: 'full' | 'delta'; activities?: Activity[] }
+
+type MsgNote = { type: 'note'; note: { id: number; note: string } }
+
+type MsgRecolor = { type: 'recolor'; recolor: { ids: number[]; color: string } }
+
+type Activity = { userId: number; clientId: string; drawing: boolean; displayName?: string }
+
+type MsgDrawing = { type: 'drawing'; activity: Activity }
+
+type MsgStrokeStart = { type: 'stroke_start'; activity: { clientId: string } }
+
+type MsgStrokeEnd = { type: 'stroke_end' }
+
+type MsgError = { type: 'error'; errors: { field: string; message: string }[] }
+
+type MsgPresence = { type: 'presence'; presence: { clientIds: string[] } }
+
+type MsgAck = { type: 'ack'; ack: { clientId: string; id: number; startedAtUnixMs: number } }
+
+type Message = MsgStroke | MsgDelete | MsgSnapshot | MsgNote | MsgRecolor | MsgDrawing | MsgStrokeStart | MsgStrokeEnd | MsgError | MsgPresence | MsgAck
 
-type User = { id: number; email: string }
+type User = { id: number; email: string; displayName?: string }
 
 type Candidate = { text: string; score: number }
 
+// Reverse the server's delta encoding: integers [x0, y0, dx1, dy1, ...] in 1/DELTA_SCALE pixel,
+// summed before scaling so they never drift
+const DELTA_SCALE = 100
+const decodeDelta = (d: number[]): Point[] => {
+  const out: Point[] = []
+  let x = 0, y = 0
+  for (let i = 0; i + 1 < d.length; i += 2) {
+    if (i === 0) { x = d[0]; y = d[1] } else { x += d[i]; y += d[i + 1] }
+    out.push({ x: x / DELTA_SCALE, y: y / DELTA_SCALE })
+  }
+  return out
+}
+
+// Same dash lengths as the SVG/PNG export: scaled with the width, dots are zero-length dashes with round caps
+const dashFor = (style: LineStyle | undefined, width: number): number[] => {
+  const w = Math.max(1, width)
+  if (style === 'dashed') return [3 * w, 3 * w]
+  if (style === 'dotted') return [0, 2 * w]
+  return []
+}
+
 const randomId = () => Math.random().toString(36).slice(2)
 
+// Keep the client ID across reloads of this tab so strokes returned by the server can be matched to us
+const persistentClientId = (): string => {
+  try {
+    const saved = sessionStorage.getItem('clientId')
+    if (saved) return saved
+    const id = randomId()
+    sessionStorage.setItem('clientId', id)
+    return id
+  } catch {
+    return randomId()
+  }
+}
+
 const apiBase = ''
 
 async function apiFetch(path: string, init?: RequestInit) {
@@ -58,7 +121,7 @@ function useWebSocket(url: string, onMsg: (msg: Message) => void) {
       ws.onmessage = (ev) => {
         try {
           const data = JSON.parse(ev.data)
-          if (data && (data.type === 'stroke' || data.type === 'delete')) onMsg(data)
+          if (isHandledMessage(data)) onMsg(data as Message)
         } catch {}
       }
     }
@@ -91,21 +154,31 @@ export const App: React.FC = () => {
   const canvasRef = useRef<HTMLCanvasElement | null>(null)
   const [color, setColor] = useState('#1d4ed8')
   const [width, setWidth] = useState(4)
+  const [lineStyle, setLineStyle] = useState<LineStyle>('solid')
   const [tool, setTool] = useState<Tool>('pencil')
-  const clientIdRef = useRef<string>(randomId())
+  const clientIdRef = useRef<string>(persistentClientId())
   const [strokes, setStrokes] = useState<Stroke[]>([])
   const [user, setUser] = useState<User | null>(null)
   const [authErr, setAuthErr] = useState<string | null>(null)
   const [candidates, setCandidates] = useState<Candidate[] | null>(null)
+  const [drawingPeers, setDrawingPeers] = useState<Activity[]>([])
+  const [online, setOnline] = useState<string[]>([])
+  // The board on screen: undefined while it loads, null for an account without one
+  const [boardId, setBoardId] = useState<number | null | undefined>(undefined)
 
   const isDev = location.port === '5173'
+  // client_id lists this tab in presence messages under the same ID its strokes carry; board joins
+  // the room of the board on screen so only its strokes arrive
+  const wsQuery = `snapshot=delta&client_id=${encodeURIComponent(clientIdRef.current)}${typeof boardId === 'number' ? `&board=${boardId}` : ''}`
   const wsUrl = isDev
-    ? `ws://${location.hostname}:5173/ws`
-    : `${location.protocol === 'https:' ? 'wss' : 'ws'}://${location.host}/ws`
+    ? `ws://${location.hostname}:5173/ws?${wsQuery}`
+    : `${location.protocol === 'https:' ? 'wss' : 'ws'}://${location.host}/ws?${wsQuery}`
 
   const handleIncoming = useCallback((m: Message) => {
     if (m.type === 'stroke') {
       setStrokes((s) => {
+        // A stroke saved while we joined can arrive both in the snapshot and relayed after it
+        if (m.stroke.id && s.some((st) => st.id === m.stroke.id)) return s
         // If this is our own stroke (same clientId), update the existing one with the ID
         const existingIndex = s.findIndex(st => 
           st.clientId === m.stroke.clientId && 
@@ -126,12 +199,45 @@ export const App: React.FC = () => {
     } else if (m.type === 'delete') {
       const id = m.delete
       setStrokes((s) => s.filter((st) => st.id !== id))
+    } else if (m.type === 'error') {
+      console.warn('Server rejected message:', m.errors)
+    } else if (m.type === 'note') {
+      const { id, note } = m.note
+      setStrokes((s) => s.map((st) => (st.id === id ? { ...st, note } : st)))
+    } else if (m.type === 'recolor') {
+      const ids = new Set(m.recolor.ids)
+      const { color } = m.recolor
+      setStrokes((s) => s.map((st) => (st.id !== undefined && ids.has(st.id) ? { ...st, color } : st)))
+    } else if (m.type === 'snapshot') {
+      const list = (m.strokes || []).map((st) => ({
+        ...st,
+        // A delta snapshot still sends points for strokes the encoding would round
+        points: m.encoding === 'delta' && st.delta ? decodeDelta(st.delta) : st.points,
+        clientId: st.clientId || '',
+        delta: undefined,
+      }))
+      setStrokes(list)
+      setDrawingPeers(m.activities || [])
+    } else if (m.type === 'drawing') {
+      const a = m.activity
+      setDrawingPeers((d) => (a.drawing ? [...d.filter((p) => p.clientId !== a.clientId), a] : d.filter((p) => p.clientId !== a.clientId)))
+    } else if (m.type === 'ack') {
+      // Our own strokes are not echoed; the ack carries the ID the server saved one under
+      const { clientId, id, startedAtUnixMs } = m.ack
+      setStrokes((s) => s.map((st) => (st.clientId === clientId && st.startedAtUnixMs === startedAtUnixMs && !st.id ? { ...st, id } : st)))
+    } else if (m.type === 'presence') {
+      setOnline(m.presence.clientIds || [])
     }
   }, [])
-  const { send, ready, close } = useWebSocket(user ? wsUrl : 'ws://invalid', handleIncoming)
+  const { send, ready, close } = useWebSocket(user && boardId !== undefined ? wsUrl : 'ws://invalid', handleIncoming)
 
   useEffect(() => { apiFetch('/api/me').then((u) => setUser(u)).catch(() => setUser(null)) }, [])
-  useEffect(() => { if (!user) return; apiFetch('/api/strokes').then((list: Stroke[]) => setStrokes(list)).catch(() => setStrokes([])) }, [user])
+
+  // Boards are listed oldest first, so the first is the default one strokes land on
+  useEffect(() => {
+    if (!user) { setBoardId(undefined); return }
+    apiFetch('/api/boards').then((bs: { id: number }[]) => setBoardId(bs.length > 0 ? bs[0].id : null)).catch(() => setBoardId(null))
+  }, [user])
 
   useEffect(() => {
     const cvs = canvasRef.current
@@ -142,6 +248,7 @@ export const App: React.FC = () => {
       if (s.points.length < 2) return
       ctx.strokeStyle = s.color
       ctx.lineWidth = s.width
+      ctx.setLineDash(dashFor(s.lineStyle, s.width))
       ctx.lineCap = 'round'
       ctx.lineJoin = 'round'
       ctx.beginPath()
@@ -218,9 +325,11 @@ export const App: React.FC = () => {
       points = [p]
       ctx.strokeStyle = color
       ctx.lineWidth = width
+      ctx.setLineDash(dashFor(lineStyle, width))
       ctx.beginPath()
       ctx.moveTo(p.x, p.y)
       cvs.setPointerCapture(e.pointerId)
+      send({ type: 'stroke_start', activity: { clientId: clientIdRef.current } })
     }
 
     const onMove = (e: PointerEvent) => {
@@ -235,9 +344,11 @@ export const App: React.FC = () => {
       if (!drawing || tool !== 'pencil') { drawing = false; points = []; return }
       drawing = false
       if (points.length >= 2) {
-        const stroke: Stroke = { points: [...points], color, width, clientId: clientIdRef.current, startedAtUnixMs: Date.now() }
+        const stroke: Stroke = { points: [...points], color, width, lineStyle, clientId: clientIdRef.current, startedAtUnixMs: Date.now() }
         setStrokes((s) => [...s, stroke])
         send({ type: 'stroke', stroke })
+      } else {
+        send({ type: 'stroke_end' })
       }
       points = []
       ctx.closePath()
@@ -253,7 +364,7 @@ export const App: React.FC = () => {
       cvs.removeEventListener('pointerup', onUp)
       cvs.removeEventListener('pointercancel', onUp)
     }
-  }, [color, width, send, user, tool, strokes])
+  }, [color, width, lineStyle, send, user, tool, strokes])
 
   const [email, setEmail] = useState('')
   const [password, setPassword] = useState('')
@@ -293,7 +404,7 @@ export const App: React.FC = () => {
     try {
       const cvs = canvasRef.current
       if (!cvs) return
-      const res = await apiFetch('/api/recognize', { method: 'POST', body: JSON.stringify({ topN: 10, width: cvs.width, height: cvs.height }) })
+      const res = await apiFetch(typeof boardId === 'number' ? `/api/recognize?board=${boardId}` : '/api/recognize', { method: 'POST', body: JSON.stringify({ topN: 10, width: cvs.width, height: cvs.height }) })
       setCandidates(res.candidates || [])
     } catch (e) {
       setCandidates([])
@@ -350,9 +461,16 @@ export const App: React.FC = () => {
         <b>Drawing Board</b>
         <label>Color <input type="color" value={color} onChange={(e) => setColor(e.target.value)} disabled={tool !== 'pencil'} /></label>
         <label>Width <input type="range" min={1} max={20} value={width} onChange={(e) => setWidth(parseInt(e.target.value, 10))} /></label>
+        <label>Line <select value={lineStyle} onChange={(e) => setLineStyle(e.target.value as LineStyle)} disabled={tool !== 'pencil'}>
+          <option value="solid">Solid</option>
+          <option value="dashed">Dashed</option>
+          <option value="dotted">Dotted</option>
+        </select></label>
         <button onClick={() => setTool('pencil')} disabled={tool==='pencil'}>Pencil</button>
         <button onClick={() => setTool('eraser')} disabled={tool==='eraser'}>Eraser</button>
         {user && <button onClick={doUndo} disabled={strokes.length === 0} title="Undo last stroke (Ctrl+Z)">Undo</button>}
+        {online.length > 1 && <span style={{ opacity: 0.7 }}>{online.length} online</span>}
+        {drawingPeers.length > 0 && <span style={{ opacity: 0.7 }}>{drawingPeers.length === 1 ? `${drawingPeers[0].displayName || 'Someone'} is drawing…` : `${drawingPeers.length} people are drawing…`}</span>}
         <span style={{ marginLeft: 'auto', opacity: 0.7 }}>{user ? (ready ? 'Connected' : 'Connecting...') : 'Sign in to draw'}</span>
         {user && <button onClick={doClear}>Clear</button>}
         {user && <button onClick={doLogout}>Logout</button>}
@@ -0,0 +1,9 @@
+// Server message types the app handles; anything else on the socket (cursors, subscription
+// replies, types added by newer servers) is ignored
+export const handledMessageTypes = ['stroke', 'delete', 'snapshot', 'presence', 'ack', 'error', 'note', 'drawing', 'recolor'] as const
+
+export type HandledMessageType = (typeof handledMessageTypes)[number]
+
+// isHandledMessage reports whether parsed socket data is a message the app should act on
+export const isHandledMessage = (data: unknown): data is { type: HandledMessageType } =>
+  typeof data === 'object' && data !== null && (handledMessageTypes as readonly unknown[]).includes((data as { type?: unknown }).type)
@@ -0,0 +1,31 @@
+import test from 'node:test'
+import assert from 'node:assert/strict'
+import { isHandledMessage } from '../src/messages.js'
+
+test('handles the messages the app acts on', () => {
+  for (const type of ['stroke', 'delete', 'snapshot', 'presence', 'ack', 'error', 'note', 'drawing', 'recolor']) {
+    assert.ok(isHandledMessage({ type }), `expected ${type} to be handled`)
+  }
+})
+
+test('passes validation errors through', () => {
+  assert.ok(isHandledMessage({ type: 'error', errors: [{ field: 'stroke.color', message: 'must be a #rgb or #rrggbb color' }] }))
+})
+
+test('passes note updates through', () => {
+  assert.ok(isHandledMessage({ type: 'note', note: { id: 7, note: 'y-axis' } }))
+})
+
+test('passes drawing indicators through', () => {
+  assert.ok(isHandledMessage({ type: 'drawing', activity: { userId: 1, clientId: 'tab-1', drawing: true } }))
+})
+
+test('passes bulk recolors through', () => {
+  assert.ok(isHandledMessage({ type: 'recolor', recolor: { ids: [1, 2], color: '#ff0000' } }))
+})
+
+test('ignores anything else', () => {
+  for (const data of [null, 'stroke', 42, {}, { type: 'cursor' }, { type: 'subscribed' }, { type: 'future' }]) {
+    assert.equal(isHandledMessage(data), false, `expected ${JSON.stringify(data)} to be ignored`)
+  }
+})
@@ -0,0 +1,12 @@
+// The few Node built-ins the tests use; the app itself has no Node types
+declare module 'node:test' {
+  export default function test(name: string, fn: () => void | Promise<void>): void
+}
+
+declare module 'node:assert/strict' {
+  const assert: {
+    ok(value: unknown, message?: string): void
+    equal(actual: unknown, expected: unknown, message?: string): void
+  }
+  export default assert
+}